@@ -6,9 +6,13 @@
 package main
 
 import (
+	"fmt"
+	"os"
+
 	"warehousecontrol/internal/app/history"
 	"warehousecontrol/internal/app/item"
 	"warehousecontrol/internal/app/user"
+	"warehousecontrol/internal/app/warehouse"
 	"warehousecontrol/internal/auth"
 	"warehousecontrol/internal/config"
 	"warehousecontrol/internal/di"
@@ -21,11 +25,21 @@ import (
 
 func main() {
 	wbzlog.Init()
+	if len(os.Args) > 1 && os.Args[1] == "migrate" {
+		if err := runMigrate(os.Args[2:]); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		return
+	}
+
 	app := fx.New(
 		fx.Provide(
 			config.NewAppConfig,
 			postgres.NewPostgres,
 			auth.NewJWTService,
+			auth.NewOIDCProvider,
+			auth.NewLDAPDirectory,
 
 			func(db *postgres.Postgres) history.HistoryStorageProvider {
 				return db
@@ -37,13 +51,28 @@ func main() {
 			},
 			item.NewItemService,
 
+			func(db *postgres.Postgres) warehouse.WarehouseStorageProvider {
+				return db
+			},
+			warehouse.NewWarehouseService,
+
 			func(db *postgres.Postgres) user.UserStorageProvider {
 				return db
 			},
 			func(auth *auth.JWTService) user.JwtAuthProvider {
 				return auth
 			},
-			user.NewUserService,
+			func(dir *auth.LDAPDirectory) user.DirectoryProvider {
+				return dir
+			},
+			user.NewAuthenticators,
+			func(repo user.UserStorageProvider, jwt user.JwtAuthProvider, cfg *config.AppConfig, authenticators []user.Authenticator) *user.UserService {
+				return user.NewUserService(repo, jwt, cfg, authenticators...)
+			},
+			func(p *auth.OIDCProvider) user.SSOProvider {
+				return p
+			},
+			user.NewSSOService,
 
 			func(app *user.UserService) handlers.UserIFace {
 				return app
@@ -59,8 +88,24 @@ func main() {
 				return app
 			},
 			handlers.NewHistoryHandler,
+
+			func(app *warehouse.WarehouseService) handlers.WarehouseIFace {
+				return app
+			},
+			handlers.NewWarehouseHandler,
+
+			func(auth *auth.JWTService) handlers.KeysIFace {
+				return auth
+			},
+			handlers.NewKeysHandler,
+
+			func(app *user.SSOService) handlers.SSOIFace {
+				return app
+			},
+			handlers.NewSSOHandler,
 		),
 		fx.Invoke(
+			di.MigrateOnStart,
 			di.StartHTTPServer,
 			di.ClosePostgresOnStop,
 		),
@@ -0,0 +1,90 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/signal"
+	"strconv"
+
+	"warehousecontrol/internal/config"
+	"warehousecontrol/internal/di"
+)
+
+const migrateUsage = `usage: warehousecontrol migrate <command>
+
+commands:
+  up          применить все новые миграции
+  down [N]    откатить N последних миграций (по умолчанию 1)
+  status      текущая версия схемы и неприменённые миграции
+  force V     записать версию V без выполнения миграций (0 — схема пуста)`
+
+// runMigrate выполняет `warehousecontrol migrate ...` с настройками базы из config/local.yaml и .env
+func runMigrate(args []string) error {
+	if len(args) == 0 {
+		return errors.New(migrateUsage)
+	}
+	cfg, err := config.NewAppConfig()
+	if err != nil {
+		return err
+	}
+	m, closeDB, err := di.OpenMigrator(cfg)
+	if err != nil {
+		return err
+	}
+	defer closeDB()
+
+	// Ctrl+C прерывает миграцию вместе с её транзакцией
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	switch cmd, rest := args[0], args[1:]; {
+	case cmd == "up" && len(rest) == 0:
+		applied, err := m.Up(ctx)
+		for _, mg := range applied {
+			fmt.Println("applied", mg)
+		}
+		if err == nil && len(applied) == 0 {
+			fmt.Println("no change")
+		}
+		return err
+	case cmd == "down" && len(rest) <= 1:
+		steps := 1
+		if len(rest) == 1 {
+			if steps, err = strconv.Atoi(rest[0]); err != nil || steps <= 0 {
+				return fmt.Errorf("down: N must be a positive number, got %q", rest[0])
+			}
+		}
+		reverted, err := m.Down(ctx, steps)
+		for _, mg := range reverted {
+			fmt.Println("reverted", mg)
+		}
+		if err == nil && len(reverted) == 0 {
+			fmt.Println("no change")
+		}
+		return err
+	case cmd == "status" && len(rest) == 0:
+		st, err := m.Status(ctx)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("version: %d\ndirty: %t\n", st.Version, st.Dirty)
+		fmt.Printf("pending: %d\n", len(st.Pending))
+		for _, mg := range st.Pending {
+			fmt.Println("  ", mg)
+		}
+		return nil
+	case cmd == "force" && len(rest) == 1:
+		version, err := strconv.ParseUint(rest[0], 10, 64)
+		if err != nil {
+			return fmt.Errorf("force: V must be a version number, got %q", rest[0])
+		}
+		if err := m.Force(ctx, version); err != nil {
+			return err
+		}
+		fmt.Println("version forced to", version)
+		return nil
+	}
+	return errors.New(migrateUsage)
+}
@@ -1,8 +1,10 @@
 package history
 
 import (
+	"warehousecontrol/internal/domain/errs"
 	"warehousecontrol/internal/domain/history"
 
+	"context"
 	"encoding/csv"
 	"encoding/json"
 	"fmt"
@@ -13,66 +15,106 @@ import (
 	wbzlog "github.com/wb-go/wbf/zlog"
 )
 
+const (
+	defaultPageSize = 100
+	maxPageSize     = 1000
+	// csvFlushEvery — через сколько строк сбрасывать буфер CSV, чтобы клиент получал выгрузку по мере чтения
+	csvFlushEvery = 500
+)
+
+var validActions = map[string]bool{
+	"created":                  true,
+	"updated":                  true,
+	"deleted":                  true,
+	history.ActionAdjusted:     true,
+	history.ActionReverted:     true,
+	history.ActionStockCreated: true,
+	history.ActionStockUpdated: true,
+	history.ActionStockRemoved: true,
+}
+
 type HistoryService struct {
 	repo HistoryStorageProvider
 }
 
 type HistoryStorageProvider interface {
-	GetItemsHistory(id string, from, to time.Time, action string, login string) ([]*history.History, error)
+	GetItemsHistory(ctx context.Context, f history.Filter, limit int, cursor *history.Cursor) (*history.Page, error)
+	StreamItemsHistory(ctx context.Context, f history.Filter, fn func(*history.History) error) error
 }
 
 func NewHistoryService(repo HistoryStorageProvider) *HistoryService {
 	return &HistoryService{repo: repo}
 }
 
-func (s *HistoryService) GetItems(id string, from, to time.Time, action string, login string) ([]*history.History, error) {
-	if !from.IsZero() && !to.IsZero() && from.After(to) {
-		err := fmt.Errorf("'from' date cannot be after 'to'")
-		wbzlog.Logger.Warn().Err(err).Msg("invalid date range in history request")
+// GetItems возвращает страницу истории; limit = 0 означает размер страницы по умолчанию
+func (s *HistoryService) GetItems(ctx context.Context, f history.Filter, limit int, cursor *history.Cursor) (*history.Page, error) {
+	err := s.validateFilter(f)
+	if err != nil {
 		return nil, err
 	}
-	if action != "" && action != "created" && action != "updated" && action != "deleted" {
-		err := fmt.Errorf("invalid action filter")
+	if limit == 0 {
+		limit = defaultPageSize
+	}
+	if limit < 1 || limit > maxPageSize {
+		return nil, errs.Errorf(errs.ErrInvalidInput, "limit must be between 1 and %d", maxPageSize)
+	}
+	return s.repo.GetItemsHistory(ctx, f, limit, cursor)
+}
+
+func (s *HistoryService) validateFilter(f history.Filter) error {
+	if !f.From.IsZero() && !f.To.IsZero() && f.From.After(f.To) {
+		err := errs.New(errs.ErrInvalidInput, "'from' date cannot be after 'to'")
+		wbzlog.Logger.Warn().Err(err).Msg("invalid date range in history request")
+		return err
+	}
+	if f.Action != "" && !validActions[f.Action] {
+		err := errs.New(errs.ErrInvalidInput, "invalid action filter")
 		wbzlog.Logger.Warn().Err(err).Msg("invalid action filter in history request")
-		return nil, err
+		return err
 	}
-	if login != "" && len(login) < 3 {
-		err := fmt.Errorf("login filter must be at least 3 characters long")
+	if f.Login != "" && len(f.Login) < 3 {
+		err := errs.New(errs.ErrInvalidInput, "login filter must be at least 3 characters long")
 		wbzlog.Logger.Warn().Err(err).Msg("invalid login filter in history request")
-		return nil, err
+		return err
 	}
-	if id != "" {
-		_, err := uuid.Parse(id)
+	if f.ItemID != "" {
+		_, err := uuid.Parse(f.ItemID)
 		if err != nil {
 			wbzlog.Logger.Warn().Err(err).Msg("invalid UUID format in history request")
-			return nil, fmt.Errorf("invalid UUID format: %w", err)
+			return errs.Errorf(errs.ErrInvalidInput, "invalid UUID format: %v", err)
 		}
 	}
-	return s.repo.GetItemsHistory(id, from, to, action, login)
+	return nil
 }
 
-func (s *HistoryService) GetItemsCSV(id string, from, to time.Time, action string, login string, output io.Writer) error {
-	histories, err := s.GetItems(id, from, to, action, login)
+// GetItemsCSV пишет историю в output построчно, по мере чтения из базы, не держа выборку в памяти
+func (s *HistoryService) GetItemsCSV(ctx context.Context, f history.Filter, output io.Writer) error {
+	err := s.validateFilter(f)
 	if err != nil {
-		wbzlog.Logger.Error().Err(err).Msg("repo get history error")
 		return err
 	}
 
+	// заголовок остаётся в буфере до первых строк: если запрос к базе упадёт сразу,
+	// в ответ ещё ничего не записано, и клиент получит ошибку, а не пустой файл
 	writer := csv.NewWriter(output)
-	defer writer.Flush()
 
-	headers := []string{"ID", "ItemID", "Action", "ChangedBy", "ChangedByLogin", "ChangedAt", "OldItemSnapshot", "NewItemSnapshot", "ItemDiff"}
+	headers := []string{"ID", "ItemID", "Action", "ChangedBy", "ChangedByLogin", "ChangedAt", "OldItemSnapshot", "NewItemSnapshot", "ItemDiff", "LocationID"}
 	if err := writer.Write(headers); err != nil {
 		wbzlog.Logger.Error().Err(err).Msg("Error writing CSV headers")
 		return err
 	}
 
-	for _, group := range histories {
+	written := 0
+	err = s.repo.StreamItemsHistory(ctx, f, func(group *history.History) error {
 		itemDiffJSON, err := json.Marshal(group.ItemDiff)
 		if err != nil {
 			wbzlog.Logger.Error().Err(err).Msg("Error marshalling ItemDiff to JSON")
 			return err
 		}
+		locationID := ""
+		if group.LocationID != nil {
+			locationID = group.LocationID.String()
+		}
 		row := []string{
 			group.ID.String(),
 			group.ItemID.String(),
@@ -83,13 +125,30 @@ func (s *HistoryService) GetItemsCSV(id string, from, to time.Time, action strin
 			fmt.Sprintf("%v", group.OldItemSnapshot),
 			fmt.Sprintf("%v", group.NewItemSnapshot),
 			string(itemDiffJSON),
+			locationID,
 		}
 		if err := writer.Write(row); err != nil {
 			wbzlog.Logger.Error().Err(err).Msg("Error writing CSV row")
 			return err
 		}
+		written++
+		if written%csvFlushEvery == 0 {
+			writer.Flush()
+			return writer.Error()
+		}
+		return nil
+	})
+	if err != nil {
+		wbzlog.Logger.Error().Err(err).Msg("repo stream history error")
+		return err
+	}
+
+	writer.Flush()
+	if err := writer.Error(); err != nil {
+		wbzlog.Logger.Error().Err(err).Msg("Error flushing CSV")
+		return err
 	}
 
-	wbzlog.Logger.Info().Msg("CSV report generation completed")
+	wbzlog.Logger.Info().Int("rows", written).Msg("CSV report generation completed")
 	return nil
 }
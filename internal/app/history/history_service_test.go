@@ -2,50 +2,64 @@ package history_test
 
 import (
 	"bytes"
+	"context"
 	"errors"
+	"strings"
 	"testing"
 	"time"
 
 	"github.com/google/uuid"
 
 	"warehousecontrol/internal/app/history"
+	"warehousecontrol/internal/domain/errs"
 	dhist "warehousecontrol/internal/domain/history"
 	"warehousecontrol/internal/domain/item"
 )
 
-type fakeRepo struct{ called bool }
+type fakeRepo struct {
+	called bool
+	limit  int
+	cursor *dhist.Cursor
+}
+
+func (f *fakeRepo) GetItemsHistory(_ context.Context, filter dhist.Filter, limit int, cursor *dhist.Cursor) (*dhist.Page, error) {
+	f.called = true
+	f.limit = limit
+	f.cursor = cursor
+	return &dhist.Page{Items: []*dhist.History{}}, nil
+}
 
-func (f *fakeRepo) GetItemsHistory(id string, from, to time.Time, action string, login string) ([]*dhist.History, error) {
+func (f *fakeRepo) StreamItemsHistory(_ context.Context, filter dhist.Filter, fn func(*dhist.History) error) error {
 	f.called = true
-	return []*dhist.History{}, nil
+	return nil
 }
 
 func TestGetItems_ValidatesRange(t *testing.T) {
 	svc := history.NewHistoryService(&fakeRepo{})
 	from := time.Date(2025, 1, 2, 0, 0, 0, 0, time.UTC)
 	to := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
-	if _, err := svc.GetItems("", from, to, "", ""); err == nil {
+	if _, err := svc.GetItems(t.Context(), dhist.Filter{From: from, To: to}, 0, nil); err == nil {
 		t.Fatalf("expected error for from > to")
 	}
 }
 
 func TestGetItems_ValidatesAction(t *testing.T) {
 	svc := history.NewHistoryService(&fakeRepo{})
-	if _, err := svc.GetItems("", time.Time{}, time.Time{}, "bad", ""); err == nil {
+	if _, err := svc.GetItems(t.Context(), dhist.Filter{Action: "bad"}, 0, nil); err == nil {
 		t.Fatalf("expected invalid action error")
 	}
 }
 
 func TestGetItems_ValidatesLoginMinLen(t *testing.T) {
 	svc := history.NewHistoryService(&fakeRepo{})
-	if _, err := svc.GetItems("", time.Time{}, time.Time{}, "", "ab"); err == nil {
+	if _, err := svc.GetItems(t.Context(), dhist.Filter{Login: "ab"}, 0, nil); err == nil {
 		t.Fatalf("expected login length error")
 	}
 }
 
 func TestGetItems_ValidatesUUID(t *testing.T) {
 	svc := history.NewHistoryService(&fakeRepo{})
-	if _, err := svc.GetItems("not-a-uuid", time.Time{}, time.Time{}, "", ""); err == nil {
+	if _, err := svc.GetItems(t.Context(), dhist.Filter{ItemID: "not-a-uuid"}, 0, nil); err == nil {
 		t.Fatalf("expected uuid parse error")
 	}
 }
@@ -56,7 +70,7 @@ func TestGetItems_PassesThroughOnValid(t *testing.T) {
 	from := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
 	to := time.Date(2025, 1, 2, 0, 0, 0, 0, time.UTC)
 
-	if _, err := svc.GetItems("", from, to, "created", "john"); err != nil {
+	if _, err := svc.GetItems(t.Context(), dhist.Filter{From: from, To: to, Action: "created", Login: "john"}, 0, nil); err != nil {
 		t.Fatalf("unexpected error: %v", err)
 	}
 	if !fr.called {
@@ -68,7 +82,7 @@ func TestGetItems_AllowsEmptyLogin(t *testing.T) {
 	fr := &fakeRepo{}
 	svc := history.NewHistoryService(fr)
 
-	if _, err := svc.GetItems("", time.Time{}, time.Time{}, "", ""); err != nil {
+	if _, err := svc.GetItems(t.Context(), dhist.Filter{}, 0, nil); err != nil {
 		t.Fatalf("did not expect error for empty login, got %v", err)
 	}
 }
@@ -79,19 +93,19 @@ func TestGetItems_AllowsValidUUID(t *testing.T) {
 
 	validUUID := "123e4567-e89b-12d3-a456-426614174000"
 
-	if _, err := svc.GetItems(validUUID, time.Time{}, time.Time{}, "", ""); err != nil {
+	if _, err := svc.GetItems(t.Context(), dhist.Filter{ItemID: validUUID}, 0, nil); err != nil {
 		t.Fatalf("did not expect error for valid UUID: %v", err)
 	}
 }
 
 func TestGetItems_ValidActions(t *testing.T) {
-	actions := []string{"created", "updated", "deleted"}
+	actions := []string{"created", "updated", "deleted", "adjusted", "reverted", "stock_created", "stock_updated", "stock_removed"}
 
 	for _, a := range actions {
 		fr := &fakeRepo{}
 		svc := history.NewHistoryService(fr)
 
-		if _, err := svc.GetItems("", time.Time{}, time.Time{}, a, ""); err != nil {
+		if _, err := svc.GetItems(t.Context(), dhist.Filter{Action: a}, 0, nil); err != nil {
 			t.Fatalf("expected action %s to be valid, got error %v", a, err)
 		}
 		if !fr.called {
@@ -104,7 +118,7 @@ func TestGetItems_NoFilters(t *testing.T) {
 	fr := &fakeRepo{}
 	svc := history.NewHistoryService(fr)
 
-	if _, err := svc.GetItems("", time.Time{}, time.Time{}, "", ""); err != nil {
+	if _, err := svc.GetItems(t.Context(), dhist.Filter{}, 0, nil); err != nil {
 		t.Fatalf("unexpected error for empty filters: %v", err)
 	}
 	if !fr.called {
@@ -117,8 +131,17 @@ type fakeRepoCSV struct {
 	err    error
 }
 
-func (f *fakeRepoCSV) GetItemsHistory(id string, from, to time.Time, action string, login string) ([]*dhist.History, error) {
-	return f.result, f.err
+func (f *fakeRepoCSV) GetItemsHistory(_ context.Context, filter dhist.Filter, limit int, cursor *dhist.Cursor) (*dhist.Page, error) {
+	return nil, errors.New("not used")
+}
+
+func (f *fakeRepoCSV) StreamItemsHistory(_ context.Context, filter dhist.Filter, fn func(*dhist.History) error) error {
+	for _, h := range f.result {
+		if err := fn(h); err != nil {
+			return err
+		}
+	}
+	return f.err
 }
 
 func TestGetItemsCSV_WritesHeader(t *testing.T) {
@@ -126,7 +149,7 @@ func TestGetItemsCSV_WritesHeader(t *testing.T) {
 	svc := history.NewHistoryService(repo)
 
 	var buf bytes.Buffer
-	err := svc.GetItemsCSV("", time.Time{}, time.Time{}, "", "", &buf)
+	err := svc.GetItemsCSV(t.Context(), dhist.Filter{}, &buf)
 	if err != nil {
 		t.Fatalf("unexpected error: %v", err)
 	}
@@ -153,7 +176,7 @@ func TestGetItemsCSV_WritesRows(t *testing.T) {
 	svc := history.NewHistoryService(repo)
 
 	var buf bytes.Buffer
-	err := svc.GetItemsCSV("", time.Time{}, time.Time{}, "", "", &buf)
+	err := svc.GetItemsCSV(t.Context(), dhist.Filter{}, &buf)
 	if err != nil {
 		t.Fatalf("unexpected error: %v", err)
 	}
@@ -169,12 +192,73 @@ func TestGetItemsCSV_WritesRows(t *testing.T) {
 	}
 }
 
+func TestGetItemsCSV_LocationRow(t *testing.T) {
+	locationID := uuid.New()
+	h := &dhist.History{
+		ID:              uuid.New(),
+		ItemID:          uuid.New(),
+		LocationID:      &locationID,
+		Action:          dhist.ActionStockRemoved,
+		ChangedBy:       uuid.New(),
+		ChangedByLogin:  "john",
+		ChangedAt:       time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC),
+		OldItemSnapshot: item.Item{Count: 3},
+	}
+	repo := &fakeRepoCSV{result: []*dhist.History{h}}
+	svc := history.NewHistoryService(repo)
+
+	var buf bytes.Buffer
+	if err := svc.GetItemsCSV(t.Context(), dhist.Filter{}, &buf); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != 2 || !strings.HasSuffix(lines[0], ",LocationID") {
+		t.Fatalf("expected header with LocationID and one row, got:\n%s", buf.String())
+	}
+	if !strings.HasSuffix(lines[1], ","+locationID.String()) || !contains(lines[1], "stock_removed") {
+		t.Fatalf("expected location row, got %s", lines[1])
+	}
+}
+
+func TestGetItems_PageSize(t *testing.T) {
+	fr := &fakeRepo{}
+	svc := history.NewHistoryService(fr)
+
+	cursor := &dhist.Cursor{ChangedAt: time.Now(), Seq: 10}
+	if _, err := svc.GetItems(t.Context(), dhist.Filter{}, 0, cursor); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if fr.limit != 100 || fr.cursor != cursor {
+		t.Fatalf("expected default limit and cursor to be passed, got %d %v", fr.limit, fr.cursor)
+	}
+
+	for _, limit := range []int{-1, 1001} {
+		if _, err := svc.GetItems(t.Context(), dhist.Filter{}, limit, nil); !errors.Is(err, errs.ErrInvalidInput) {
+			t.Fatalf("expected invalid input for limit %d, got %v", limit, err)
+		}
+	}
+}
+
+func TestGetItemsCSV_ValidatesBeforeStreaming(t *testing.T) {
+	fr := &fakeRepo{}
+	svc := history.NewHistoryService(fr)
+
+	var buf bytes.Buffer
+	if err := svc.GetItemsCSV(t.Context(), dhist.Filter{Action: "bad"}, &buf); err == nil {
+		t.Fatalf("expected invalid action error")
+	}
+	if fr.called || buf.Len() != 0 {
+		t.Fatalf("expected nothing to be streamed on invalid filter")
+	}
+}
+
 func TestGetItemsCSV_ErrFromGetItems(t *testing.T) {
 	repo := &fakeRepoCSV{err: errors.New("boom")}
 	svc := history.NewHistoryService(repo)
 
 	var buf bytes.Buffer
-	err := svc.GetItemsCSV("", time.Time{}, time.Time{}, "", "", &buf)
+	err := svc.GetItemsCSV(t.Context(), dhist.Filter{}, &buf)
 	if err == nil {
 		t.Fatalf("expected error from GetItems")
 	}
@@ -2,26 +2,47 @@ package item
 
 import (
 	"warehousecontrol/internal/config"
+	"warehousecontrol/internal/domain/errs"
+	"warehousecontrol/internal/domain/history"
 	"warehousecontrol/internal/domain/item"
+	"warehousecontrol/internal/domain/movement"
 
 	"github.com/google/uuid"
 	wbzlog "github.com/wb-go/wbf/zlog"
 
-	"fmt"
+	"context"
+	"errors"
+	"math"
+	"time"
 	"unicode/utf8"
 )
 
+const (
+	defaultPageSize    = 50
+	defaultMaxPageSize = 500
+)
+
 type ItemService struct {
 	repo ItemStorageProvider
 	cfg  *config.AppConfig
 }
 
 type ItemStorageProvider interface {
-	CreateItem(item *item.Item, userID string, login string) error
-	GetItems() ([]*item.Item, error)
-	GetItem(uuid string) (*item.Item, error)
-	PutItem(item *item.Item, userID string, login string) error
-	DeleteItem(uuid string, userID string, login string) error
+	CreateItem(ctx context.Context, item *item.Item, userID string, login string) error
+	GetItems(ctx context.Context, opts item.ListOptions) (*item.Page, error)
+	GetItem(ctx context.Context, uuid string) (*item.Item, error)
+	GetItemAsOf(ctx context.Context, uuid string, asOf time.Time) (*item.Item, error)
+	PutItem(ctx context.Context, item *item.Item, userID string, login string) error
+	DeleteItem(ctx context.Context, uuid string, version int, userID string, login string) error
+	GetItemStocks(ctx context.Context, uuid string) ([]item.LocationStock, error)
+	GetItemStocksAsOf(ctx context.Context, uuid string, asOf time.Time) ([]item.LocationStock, error)
+	SetItemStock(ctx context.Context, itemID string, locationID string, quantity int, userID string, login string) error
+	ApplyMovement(ctx context.Context, m *movement.Movement, allowNegative bool, userID string, login string) (int, error)
+	GetItemMovements(ctx context.Context, itemID string) ([]*movement.Movement, error)
+	GetHistoryRecord(ctx context.Context, id string) (*history.History, error)
+	RevertItem(ctx context.Context, it *item.Item, expectedVersion int, sourceID string, userID string, login string) error
+	GetItemStateAt(ctx context.Context, itemID string, at history.Cursor) (*history.History, error)
+	GetItemChanges(ctx context.Context, itemID string, after history.Cursor, upTo history.Cursor) ([]*history.History, error)
 }
 
 func NewItemService(repo ItemStorageProvider, cfg *config.AppConfig) *ItemService {
@@ -31,7 +52,7 @@ func NewItemService(repo ItemStorageProvider, cfg *config.AppConfig) *ItemServic
 	}
 }
 
-func (s *ItemService) Create(name string, count int, price float64, userID string, login string) (*item.Item, error) {
+func (s *ItemService) Create(ctx context.Context, name string, count int, price float64, userID string, login string) (*item.Item, error) {
 	err := s.isNameValid(name)
 	if err != nil {
 		wbzlog.Logger.Warn().Err(err).Msg("item name is invalid")
@@ -43,7 +64,7 @@ func (s *ItemService) Create(name string, count int, price float64, userID strin
 		wbzlog.Logger.Warn().Err(err).Msg("cant create item")
 		return nil, err
 	}
-	err = s.repo.CreateItem(item, userID, login)
+	err = s.repo.CreateItem(ctx, item, userID, login)
 	if err != nil {
 		return nil, err
 	}
@@ -51,24 +72,93 @@ func (s *ItemService) Create(name string, count int, price float64, userID strin
 	return item, nil
 }
 
-func (s *ItemService) GetItems() ([]*item.Item, error) {
-	return s.repo.GetItems()
+// GetItems возвращает страницу списка товаров; незаданные сортировка и размер страницы берутся по умолчанию
+func (s *ItemService) GetItems(ctx context.Context, opts item.ListOptions) (*item.Page, error) {
+	if opts.SortBy == "" {
+		opts.SortBy = item.SortByName
+	}
+	if opts.SortBy != item.SortByName && opts.SortBy != item.SortByCount && opts.SortBy != item.SortByPrice {
+		return nil, errs.New(errs.ErrInvalidInput, "sort must be one of name, count, price")
+	}
+
+	if opts.Limit == 0 {
+		opts.Limit = s.cfg.ItemConfig.DefaultPageSize
+		if opts.Limit <= 0 {
+			opts.Limit = defaultPageSize
+		}
+	}
+	maxLimit := s.cfg.ItemConfig.MaxPageSize
+	if maxLimit <= 0 {
+		maxLimit = defaultMaxPageSize
+	}
+	if opts.Limit < 0 || opts.Limit > maxLimit {
+		return nil, errs.Errorf(errs.ErrInvalidInput, "limit must be between 1 and %d", maxLimit)
+	}
+	if opts.Offset < 0 {
+		return nil, errs.New(errs.ErrInvalidInput, "offset cant be negative")
+	}
+
+	if opts.Cursor != nil {
+		if opts.Cursor.SortBy != opts.SortBy || opts.Cursor.Desc != opts.Desc {
+			return nil, errs.New(errs.ErrInvalidInput, "cursor does not match sort order")
+		}
+		opts.Offset = 0
+	}
+
+	if opts.MinCount != nil && opts.MaxCount != nil && *opts.MinCount > *opts.MaxCount {
+		return nil, errs.New(errs.ErrInvalidInput, "min_count cant be greater than max_count")
+	}
+	if opts.MinPrice != nil && opts.MaxPrice != nil && *opts.MinPrice > *opts.MaxPrice {
+		return nil, errs.New(errs.ErrInvalidInput, "min_price cant be greater than max_price")
+	}
+
+	return s.repo.GetItems(ctx, opts)
+}
+
+func (s *ItemService) GetItem(ctx context.Context, id string) (*item.Item, error) {
+	_, err := uuid.Parse(id)
+	if err != nil {
+		wbzlog.Logger.Warn().Err(err).Msg("invalid UUID format")
+		return nil, errs.Errorf(errs.ErrInvalidInput, "invalid UUID format: %v", err)
+	}
+	it, err := s.repo.GetItem(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	it.Locations, err = s.repo.GetItemStocks(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	return it, nil
 }
 
-func (s *ItemService) GetItem(id string) (*item.Item, error) {
+// GetItemAsOf возвращает товар и его остатки по ячейкам в том виде, в каком они были на момент asOf
+func (s *ItemService) GetItemAsOf(ctx context.Context, id string, asOf time.Time) (*item.Item, error) {
 	_, err := uuid.Parse(id)
 	if err != nil {
 		wbzlog.Logger.Warn().Err(err).Msg("invalid UUID format")
-		return nil, fmt.Errorf("invalid UUID format: %w", err)
+		return nil, errs.Errorf(errs.ErrInvalidInput, "invalid UUID format: %v", err)
 	}
-	return s.repo.GetItem(id)
+	if asOf.IsZero() {
+		return nil, errs.New(errs.ErrInvalidInput, "as_of required")
+	}
+	it, err := s.repo.GetItemAsOf(ctx, id, asOf)
+	if err != nil {
+		return nil, err
+	}
+	it.Locations, err = s.repo.GetItemStocksAsOf(ctx, id, asOf)
+	if err != nil {
+		return nil, err
+	}
+	return it, nil
 }
 
-func (s *ItemService) PutItem(id string, name string, count int, price float64, userID string, login string) (*item.Item, error) {
+// PutItem обновляет товар, если его текущая версия совпадает с version
+func (s *ItemService) PutItem(ctx context.Context, id string, version int, name string, count int, price float64, userID string, login string) (*item.Item, error) {
 	_, err := uuid.Parse(id)
 	if err != nil {
 		wbzlog.Logger.Warn().Err(err).Msg("invalid UUID format")
-		return nil, fmt.Errorf("invalid UUID format: %w", err)
+		return nil, errs.Errorf(errs.ErrInvalidInput, "invalid UUID format: %v", err)
 	}
 
 	err = s.isNameValid(name)
@@ -77,37 +167,273 @@ func (s *ItemService) PutItem(id string, name string, count int, price float64,
 		return nil, err
 	}
 
-	item, err := s.repo.GetItem(id)
+	it, err := s.repo.GetItem(ctx, id)
 	if err != nil {
 		return nil, err
 	}
+	if it.Version != version {
+		return nil, item.ErrVersionMismatch
+	}
 
-	err = item.ChangeItem(name, count, price)
+	err = it.ChangeItem(name, count, price)
 	if err != nil {
 		wbzlog.Logger.Warn().Err(err).Msg("cant change item")
 		return nil, err
 	}
 
-	err = s.repo.PutItem(item, userID, login)
+	err = s.repo.PutItem(ctx, it, userID, login)
 	if err != nil {
 		return nil, err
 	}
 
-	return item, nil
+	return it, nil
 }
 
-func (s *ItemService) DeleteItem(id string, userID string, login string) error {
+// DeleteItem удаляет товар, если его текущая версия совпадает с version
+func (s *ItemService) DeleteItem(ctx context.Context, id string, version int, userID string, login string) error {
 	_, err := uuid.Parse(id)
 	if err != nil {
 		wbzlog.Logger.Warn().Err(err).Msg("invalid UUID format")
-		return fmt.Errorf("invalid UUID format: %w", err)
+		return errs.Errorf(errs.ErrInvalidInput, "invalid UUID format: %v", err)
+	}
+	return s.repo.DeleteItem(ctx, id, version, userID, login)
+}
+
+// Снимки записи истории, из которых можно восстановить товар
+const (
+	SnapshotOld = "old"
+	SnapshotNew = "new"
+)
+
+// Revert восстанавливает товар из снимка записи истории historyID и пересоздаёт его, если он был удалён.
+// Без snapshot берётся новый снимок, а если он пуст (запись об удалении) — старый.
+// version = 0 отключает проверку текущей версии.
+func (s *ItemService) Revert(ctx context.Context, id string, historyID string, snapshot string, version int, userID string, login string) (*item.Item, error) {
+	itemID, err := uuid.Parse(id)
+	if err != nil {
+		wbzlog.Logger.Warn().Err(err).Msg("invalid UUID format")
+		return nil, errs.Errorf(errs.ErrInvalidInput, "invalid UUID format: %v", err)
+	}
+	_, err = uuid.Parse(historyID)
+	if err != nil {
+		wbzlog.Logger.Warn().Err(err).Msg("invalid history UUID format")
+		return nil, errs.Errorf(errs.ErrInvalidInput, "invalid history UUID format: %v", err)
+	}
+	if snapshot != "" && snapshot != SnapshotOld && snapshot != SnapshotNew {
+		return nil, errs.New(errs.ErrInvalidInput, "snapshot must be old or new")
+	}
+
+	record, err := s.repo.GetHistoryRecord(ctx, historyID)
+	if err != nil {
+		return nil, err
+	}
+	if record.ItemID != itemID {
+		return nil, errs.New(errs.ErrValidation, "history record belongs to another item")
+	}
+	if record.LocationID != nil {
+		return nil, errs.New(errs.ErrValidation, "history record is a location stock change, not an item snapshot")
+	}
+
+	target := record.NewItemSnapshot
+	if snapshot == SnapshotOld || (snapshot == "" && target.ID == uuid.Nil) {
+		target = record.OldItemSnapshot
+	}
+	if target.ID == uuid.Nil {
+		return nil, errs.New(errs.ErrValidation, "selected snapshot is empty")
+	}
+
+	it := &item.Item{ID: itemID}
+	err = it.ChangeItem(target.Name, target.Count, target.Price)
+	if err != nil {
+		wbzlog.Logger.Warn().Err(err).Msg("history snapshot is not a valid item")
+		return nil, err
+	}
+
+	err = s.repo.RevertItem(ctx, it, version, historyID, userID, login)
+	if err != nil {
+		return nil, err
+	}
+	return it, nil
+}
+
+// Diff сравнивает состояния товара в точках from и to и возвращает изменения между ними.
+// Точка — ID записи истории (состояние сразу после неё) или время в RFC3339; пустой to означает текущий момент.
+func (s *ItemService) Diff(ctx context.Context, id string, from string, to string) (*history.VersionDiff, error) {
+	itemID, err := uuid.Parse(id)
+	if err != nil {
+		wbzlog.Logger.Warn().Err(err).Msg("invalid UUID format")
+		return nil, errs.Errorf(errs.ErrInvalidInput, "invalid UUID format: %v", err)
+	}
+	if from == "" {
+		return nil, errs.New(errs.ErrInvalidInput, "from required")
+	}
+	fromPoint, err := s.historyPoint(ctx, itemID, from)
+	if err != nil {
+		return nil, err
+	}
+	toPoint := history.Cursor{ChangedAt: time.Now(), Seq: math.MaxInt64}
+	if to != "" {
+		toPoint, err = s.historyPoint(ctx, itemID, to)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if toPoint.ChangedAt.Before(fromPoint.ChangedAt) || (toPoint.ChangedAt.Equal(fromPoint.ChangedAt) && toPoint.Seq < fromPoint.Seq) {
+		return nil, errs.New(errs.ErrInvalidInput, "'from' cannot be after 'to'")
+	}
+
+	diff := &history.VersionDiff{ItemID: itemID}
+	state, err := s.repo.GetItemStateAt(ctx, id, fromPoint)
+	switch {
+	case errors.Is(err, history.ErrNotFound):
+		// товара ещё не было — сравниваем с пустым состоянием
+	case err != nil:
+		return nil, err
+	default:
+		diff.From = state.NewItemSnapshot
+	}
+
+	diff.Changes, err = s.repo.GetItemChanges(ctx, id, fromPoint, toPoint)
+	if err != nil {
+		return nil, err
+	}
+	diff.To = diff.From
+	if n := len(diff.Changes); n > 0 {
+		diff.To = diff.Changes[n-1].NewItemSnapshot
+	}
+	diff.Diff = diff.From.Diff(diff.To)
+	return diff, nil
+}
+
+// historyPoint переводит ID записи истории или время в позицию истории товара
+func (s *ItemService) historyPoint(ctx context.Context, itemID uuid.UUID, raw string) (history.Cursor, error) {
+	if recordID, err := uuid.Parse(raw); err == nil {
+		record, err := s.repo.GetHistoryRecord(ctx, recordID.String())
+		if err != nil {
+			return history.Cursor{}, err
+		}
+		if record.ItemID != itemID {
+			return history.Cursor{}, errs.New(errs.ErrValidation, "history record belongs to another item")
+		}
+		if record.LocationID != nil {
+			return history.Cursor{}, errs.New(errs.ErrValidation, "history record is a location stock change, not an item snapshot")
+		}
+		return *history.NewCursor(record), nil
+	}
+	at, err := time.Parse(time.RFC3339, raw)
+	if err != nil {
+		return history.Cursor{}, errs.Errorf(errs.ErrInvalidInput, "invalid point %q, expected history ID or RFC3339 time", raw)
+	}
+	// все записи в этот момент включительно
+	return history.Cursor{ChangedAt: at, Seq: math.MaxInt64}, nil
+}
+
+func (s *ItemService) SetStock(ctx context.Context, id string, locationID string, quantity int, userID string, login string) (*item.Item, error) {
+	_, err := uuid.Parse(id)
+	if err != nil {
+		wbzlog.Logger.Warn().Err(err).Msg("invalid UUID format")
+		return nil, errs.Errorf(errs.ErrInvalidInput, "invalid UUID format: %v", err)
+	}
+	_, err = uuid.Parse(locationID)
+	if err != nil {
+		wbzlog.Logger.Warn().Err(err).Msg("invalid location UUID format")
+		return nil, errs.Errorf(errs.ErrInvalidInput, "invalid location UUID format: %v", err)
+	}
+	if quantity < 0 {
+		err := errs.New(errs.ErrValidation, "quantity cant be negative")
+		wbzlog.Logger.Warn().Err(err).Msg("invalid stock quantity")
+		return nil, err
+	}
+
+	err = s.repo.SetItemStock(ctx, id, locationID, quantity, userID, login)
+	if err != nil {
+		return nil, err
+	}
+	return s.GetItem(ctx, id)
+}
+
+// Receive — поступление товара (в ячейку, если указана)
+func (s *ItemService) Receive(ctx context.Context, id string, locationID string, quantity int, reason string, reference string, userID string, login string) (*item.Item, error) {
+	_, err := s.move(ctx, movement.Receipt, id, locationID, "", quantity, false, reason, reference, userID, login)
+	if err != nil {
+		return nil, err
+	}
+	return s.GetItem(ctx, id)
+}
+
+// Issue — списание/отгрузка товара
+func (s *ItemService) Issue(ctx context.Context, id string, locationID string, quantity int, reason string, reference string, userID string, login string) (*item.Item, error) {
+	_, err := s.move(ctx, movement.Issue, id, locationID, "", quantity, false, reason, reference, userID, login)
+	if err != nil {
+		return nil, err
+	}
+	return s.GetItem(ctx, id)
+}
+
+// Transfer — перемещение между ячейками, общий остаток не меняется
+func (s *ItemService) Transfer(ctx context.Context, id string, fromLocationID string, toLocationID string, quantity int, reason string, reference string, userID string, login string) (*item.Item, error) {
+	_, err := s.move(ctx, movement.Transfer, id, fromLocationID, toLocationID, quantity, false, reason, reference, userID, login)
+	if err != nil {
+		return nil, err
+	}
+	return s.GetItem(ctx, id)
+}
+
+// Adjust — атомарная корректировка остатка на знаковую дельту, возвращает новый остаток.
+// force разрешает уйти в минус по общему остатку; остаток в ячейке отрицательным не бывает.
+func (s *ItemService) Adjust(ctx context.Context, id string, locationID string, delta int, force bool, reason string, reference string, userID string, login string) (int, error) {
+	return s.move(ctx, movement.Adjustment, id, locationID, "", delta, force, reason, reference, userID, login)
+}
+
+func (s *ItemService) GetMovements(ctx context.Context, id string) ([]*movement.Movement, error) {
+	_, err := uuid.Parse(id)
+	if err != nil {
+		wbzlog.Logger.Warn().Err(err).Msg("invalid UUID format")
+		return nil, errs.Errorf(errs.ErrInvalidInput, "invalid UUID format: %v", err)
+	}
+	return s.repo.GetItemMovements(ctx, id)
+}
+
+func (s *ItemService) move(ctx context.Context, t movement.Type, id string, locationID string, toLocationID string, quantity int, allowNegative bool, reason string, reference string, userID string, login string) (int, error) {
+	itemID, err := uuid.Parse(id)
+	if err != nil {
+		wbzlog.Logger.Warn().Err(err).Msg("invalid UUID format")
+		return 0, errs.Errorf(errs.ErrInvalidInput, "invalid UUID format: %v", err)
+	}
+	from, err := parseOptionalUUID(locationID)
+	if err != nil {
+		wbzlog.Logger.Warn().Err(err).Msg("invalid location UUID format")
+		return 0, errs.Errorf(errs.ErrInvalidInput, "invalid location UUID format: %v", err)
+	}
+	to, err := parseOptionalUUID(toLocationID)
+	if err != nil {
+		wbzlog.Logger.Warn().Err(err).Msg("invalid location UUID format")
+		return 0, errs.Errorf(errs.ErrInvalidInput, "invalid location UUID format: %v", err)
+	}
+
+	m, err := movement.NewMovement(t, itemID, from, to, quantity, reason, reference)
+	if err != nil {
+		wbzlog.Logger.Warn().Err(err).Msg("cant create movement")
+		return 0, err
+	}
+
+	return s.repo.ApplyMovement(ctx, m, allowNegative, userID, login)
+}
+
+func parseOptionalUUID(id string) (*uuid.UUID, error) {
+	if id == "" {
+		return nil, nil
+	}
+	parsed, err := uuid.Parse(id)
+	if err != nil {
+		return nil, err
 	}
-	return s.repo.DeleteItem(id, userID, login)
+	return &parsed, nil
 }
 
 func (s *ItemService) isNameValid(name string) error {
 	if name == "" || utf8.RuneCountInString(name) < s.cfg.ItemConfig.NameMinLength || utf8.RuneCountInString(name) > s.cfg.ItemConfig.NameMaxLegth {
-		return fmt.Errorf("name cant be empty, should be bigger than %d, and smaller than %d", s.cfg.ItemConfig.NameMinLength, s.cfg.ItemConfig.NameMaxLegth)
+		return errs.Errorf(errs.ErrValidation, "name cant be empty, should be bigger than %d, and smaller than %d", s.cfg.ItemConfig.NameMinLength, s.cfg.ItemConfig.NameMaxLegth)
 	}
 	return nil
 }
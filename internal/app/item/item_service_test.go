@@ -1,12 +1,17 @@
 package item_test
 
 import (
+	"context"
 	"errors"
 	"testing"
+	"time"
 
 	"warehousecontrol/internal/app/item"
 	"warehousecontrol/internal/config"
+	"warehousecontrol/internal/domain/errs"
+	"warehousecontrol/internal/domain/history"
 	domain "warehousecontrol/internal/domain/item"
+	"warehousecontrol/internal/domain/movement"
 
 	"github.com/google/uuid"
 )
@@ -16,31 +21,99 @@ type fakeRepo struct {
 	getItemCalled    bool
 	putItemCalled    bool
 	deleteItemCalled bool
+	setStockCalled   bool
+
+	appliedMovement *movement.Movement
+	asOf            time.Time
+	historyRecord   *history.History
+	reverted        *domain.Item
+	revertVersion   int
+	stateAt         *history.History
+	changes         []*history.History
+	changesAfter    history.Cursor
+	listOptions     domain.ListOptions
+	allowNegative   bool
+	countToReturn   int
+	// inLocations — сумма остатков в ячейках; PutItem и RevertItem проверяют её, как репозиторий
+	inLocations int
 
 	itemToReturn *domain.Item
 	errToReturn  error
 }
 
-func (f *fakeRepo) CreateItem(i *domain.Item, userID string, login string) error {
+func (f *fakeRepo) CreateItem(_ context.Context, i *domain.Item, userID string, login string) error {
 	f.createItemCalled = true
 	return f.errToReturn
 }
-func (f *fakeRepo) GetItems() ([]*domain.Item, error) {
-	return []*domain.Item{f.itemToReturn}, f.errToReturn
+func (f *fakeRepo) GetItems(_ context.Context, opts domain.ListOptions) (*domain.Page, error) {
+	f.listOptions = opts
+	return &domain.Page{Items: []*domain.Item{f.itemToReturn}, Total: 1}, f.errToReturn
 }
-func (f *fakeRepo) GetItem(id string) (*domain.Item, error) {
+func (f *fakeRepo) GetItem(_ context.Context, id string) (*domain.Item, error) {
 	f.getItemCalled = true
 	return f.itemToReturn, f.errToReturn
 }
-func (f *fakeRepo) PutItem(i *domain.Item, userID string, login string) error {
+func (f *fakeRepo) GetItemAsOf(_ context.Context, id string, asOf time.Time) (*domain.Item, error) {
+	f.asOf = asOf
+	return f.itemToReturn, f.errToReturn
+}
+func (f *fakeRepo) PutItem(_ context.Context, i *domain.Item, userID string, login string) error {
 	f.putItemCalled = true
+	if err := domain.CheckLocationStock(i.Count, f.inLocations); err != nil {
+		return err
+	}
 	return f.errToReturn
 }
-func (f *fakeRepo) DeleteItem(id string, userID string, login string) error {
+func (f *fakeRepo) DeleteItem(_ context.Context, id string, version int, userID string, login string) error {
 	f.deleteItemCalled = true
 	return f.errToReturn
 }
 
+func (f *fakeRepo) GetItemStocks(_ context.Context, id string) ([]domain.LocationStock, error) {
+	return nil, nil
+}
+func (f *fakeRepo) GetItemStocksAsOf(_ context.Context, id string, asOf time.Time) ([]domain.LocationStock, error) {
+	return []domain.LocationStock{{Quantity: 3}}, nil
+}
+func (f *fakeRepo) SetItemStock(_ context.Context, itemID string, locationID string, quantity int, userID string, login string) error {
+	f.setStockCalled = true
+	return f.errToReturn
+}
+func (f *fakeRepo) ApplyMovement(_ context.Context, m *movement.Movement, allowNegative bool, userID string, login string) (int, error) {
+	f.appliedMovement = m
+	f.allowNegative = allowNegative
+	return f.countToReturn, f.errToReturn
+}
+func (f *fakeRepo) GetItemMovements(_ context.Context, itemID string) ([]*movement.Movement, error) {
+	return []*movement.Movement{}, f.errToReturn
+}
+
+func (f *fakeRepo) GetHistoryRecord(_ context.Context, id string) (*history.History, error) {
+	if f.historyRecord == nil {
+		return nil, history.ErrNotFound
+	}
+	return f.historyRecord, nil
+}
+func (f *fakeRepo) RevertItem(_ context.Context, it *domain.Item, expectedVersion int, sourceID string, userID string, login string) error {
+	f.reverted = it
+	f.revertVersion = expectedVersion
+	if err := domain.CheckLocationStock(it.Count, f.inLocations); err != nil {
+		return err
+	}
+	return f.errToReturn
+}
+
+func (f *fakeRepo) GetItemStateAt(_ context.Context, itemID string, at history.Cursor) (*history.History, error) {
+	if f.stateAt == nil {
+		return nil, history.ErrNotFound
+	}
+	return f.stateAt, nil
+}
+func (f *fakeRepo) GetItemChanges(_ context.Context, itemID string, after history.Cursor, upTo history.Cursor) ([]*history.History, error) {
+	f.changesAfter = after
+	return f.changes, nil
+}
+
 func testCfg() *config.AppConfig {
 	return &config.AppConfig{
 		ItemConfig: config.ItemConfig{
@@ -54,7 +127,7 @@ func TestCreate_Success(t *testing.T) {
 	repo := &fakeRepo{}
 	svc := item.NewItemService(repo, testCfg())
 
-	obj, err := svc.Create("Apple", 5, 10.0, "uid", "login")
+	obj, err := svc.Create(t.Context(), "Apple", 5, 10.0, "uid", "login")
 	if err != nil {
 		t.Fatalf("unexpected error: %v", err)
 	}
@@ -70,7 +143,7 @@ func TestCreate_InvalidName(t *testing.T) {
 	repo := &fakeRepo{}
 	svc := item.NewItemService(repo, testCfg())
 
-	_, err := svc.Create("A", 5, 10.0, "uid", "login")
+	_, err := svc.Create(t.Context(), "A", 5, 10.0, "uid", "login")
 	if err == nil {
 		t.Fatalf("expected validation error")
 	}
@@ -80,7 +153,7 @@ func TestGetItem_InvalidUUID(t *testing.T) {
 	repo := &fakeRepo{}
 	svc := item.NewItemService(repo, testCfg())
 
-	_, err := svc.GetItem("not-uuid")
+	_, err := svc.GetItem(t.Context(), "not-uuid")
 	if err == nil {
 		t.Fatalf("expected uuid error")
 	}
@@ -90,7 +163,7 @@ func TestGetItem_Success(t *testing.T) {
 	repo := &fakeRepo{itemToReturn: &domain.Item{ID: uuid.New(), Name: "Test", Count: 1, Price: 1}}
 	svc := item.NewItemService(repo, testCfg())
 
-	_, err := svc.GetItem(uuid.NewString())
+	_, err := svc.GetItem(t.Context(), uuid.NewString())
 	if err != nil {
 		t.Fatalf("unexpected error: %v", err)
 	}
@@ -102,15 +175,16 @@ func TestGetItem_Success(t *testing.T) {
 func TestPutItem_Success(t *testing.T) {
 	repo := &fakeRepo{
 		itemToReturn: &domain.Item{
-			ID:    uuid.New(),
-			Name:  "OldName",
-			Count: 1,
-			Price: 1,
+			ID:      uuid.New(),
+			Name:    "OldName",
+			Count:   1,
+			Price:   1,
+			Version: 2,
 		},
 	}
 	svc := item.NewItemService(repo, testCfg())
 
-	_, err := svc.PutItem(repo.itemToReturn.ID.String(), "NewName", 10, 5.5, "uid", "login")
+	_, err := svc.PutItem(t.Context(), repo.itemToReturn.ID.String(), 2, "NewName", 10, 5.5, "uid", "login")
 	if err != nil {
 		t.Fatalf("unexpected error: %v", err)
 	}
@@ -119,11 +193,24 @@ func TestPutItem_Success(t *testing.T) {
 	}
 }
 
+func TestPutItem_BelowLocationStock(t *testing.T) {
+	repo := &fakeRepo{
+		itemToReturn: &domain.Item{ID: uuid.New(), Name: "OldName", Count: 10, Price: 1, Version: 1},
+		inLocations:  10,
+	}
+	svc := item.NewItemService(repo, testCfg())
+
+	_, err := svc.PutItem(t.Context(), repo.itemToReturn.ID.String(), 1, "OldName", 5, 1, "uid", "login")
+	if !errors.Is(err, domain.ErrStockBelowLocations) || !errors.Is(err, errs.ErrConflict) {
+		t.Fatalf("expected stock below locations conflict, got %v", err)
+	}
+}
+
 func TestPutItem_InvalidUUID(t *testing.T) {
 	repo := &fakeRepo{}
 	svc := item.NewItemService(repo, testCfg())
 
-	_, err := svc.PutItem("bad-uuid", "GoodName", 1, 1, "uid", "login")
+	_, err := svc.PutItem(t.Context(), "bad-uuid", 1, "GoodName", 1, 1, "uid", "login")
 	if err == nil {
 		t.Fatalf("expected uuid error")
 	}
@@ -133,7 +220,7 @@ func TestPutItem_InvalidName(t *testing.T) {
 	repo := &fakeRepo{}
 	svc := item.NewItemService(repo, testCfg())
 
-	_, err := svc.PutItem(uuid.NewString(), "A", 1, 1, "uid", "login")
+	_, err := svc.PutItem(t.Context(), uuid.NewString(), 1, "A", 1, 1, "uid", "login")
 	if err == nil {
 		t.Fatalf("expected name error")
 	}
@@ -143,17 +230,30 @@ func TestPutItem_RepoGetError(t *testing.T) {
 	repo := &fakeRepo{errToReturn: errors.New("fail")}
 	svc := item.NewItemService(repo, testCfg())
 
-	_, err := svc.PutItem(uuid.NewString(), "ValidName", 1, 1, "uid", "login")
+	_, err := svc.PutItem(t.Context(), uuid.NewString(), 1, "ValidName", 1, 1, "uid", "login")
 	if err == nil {
 		t.Fatalf("expected repo get error")
 	}
 }
 
+func TestPutItem_StaleVersion(t *testing.T) {
+	repo := &fakeRepo{itemToReturn: &domain.Item{ID: uuid.New(), Name: "OldName", Count: 1, Price: 1, Version: 3}}
+	svc := item.NewItemService(repo, testCfg())
+
+	_, err := svc.PutItem(t.Context(), repo.itemToReturn.ID.String(), 2, "NewName", 10, 5.5, "uid", "login")
+	if !errors.Is(err, domain.ErrVersionMismatch) {
+		t.Fatalf("expected version mismatch, got %v", err)
+	}
+	if repo.putItemCalled {
+		t.Fatalf("PutItem should not be called on stale version")
+	}
+}
+
 func TestDeleteItem_Success(t *testing.T) {
 	repo := &fakeRepo{}
 	svc := item.NewItemService(repo, testCfg())
 
-	err := svc.DeleteItem(uuid.NewString(), "uid", "login")
+	err := svc.DeleteItem(t.Context(), uuid.NewString(), 1, "uid", "login")
 	if err != nil {
 		t.Fatalf("unexpected error: %v", err)
 	}
@@ -166,7 +266,7 @@ func TestDeleteItem_InvalidUUID(t *testing.T) {
 	repo := &fakeRepo{}
 	svc := item.NewItemService(repo, testCfg())
 
-	err := svc.DeleteItem("bad-uuid", "uid", "login")
+	err := svc.DeleteItem(t.Context(), "bad-uuid", 1, "uid", "login")
 	if err == nil {
 		t.Fatalf("expected uuid error")
 	}
@@ -175,15 +275,346 @@ func TestDeleteItem_InvalidUUID(t *testing.T) {
 func TestIsNameValid(t *testing.T) {
 	svc := item.NewItemService(&fakeRepo{}, testCfg())
 
-	if _, err := svc.Create("X", 1, 1, "u", "l"); err == nil {
+	if _, err := svc.Create(t.Context(), "X", 1, 1, "u", "l"); err == nil {
 		t.Fatalf("expected name too short error")
 	}
 
-	if _, err := svc.Create("VeryLongNameHere", 1, 1, "u", "l"); err == nil {
+	if _, err := svc.Create(t.Context(), "VeryLongNameHere", 1, 1, "u", "l"); err == nil {
 		t.Fatalf("expected name too long error")
 	}
 
-	if _, err := svc.Create("OkName", 1, 1, "u", "l"); err != nil {
+	if _, err := svc.Create(t.Context(), "OkName", 1, 1, "u", "l"); err != nil {
 		t.Fatalf("unexpected error for valid name: %v", err)
 	}
 }
+
+func TestSetStock_Success(t *testing.T) {
+	repo := &fakeRepo{itemToReturn: &domain.Item{ID: uuid.New(), Name: "Test", Count: 5, Price: 1}}
+	svc := item.NewItemService(repo, testCfg())
+
+	_, err := svc.SetStock(t.Context(), repo.itemToReturn.ID.String(), uuid.NewString(), 3, "uid", "login")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !repo.setStockCalled {
+		t.Fatalf("expected SetItemStock to be called")
+	}
+}
+
+func TestSetStock_Invalid(t *testing.T) {
+	repo := &fakeRepo{}
+	svc := item.NewItemService(repo, testCfg())
+
+	if _, err := svc.SetStock(t.Context(), "bad", uuid.NewString(), 1, "uid", "login"); err == nil {
+		t.Fatalf("expected uuid error")
+	}
+	if _, err := svc.SetStock(t.Context(), uuid.NewString(), "bad", 1, "uid", "login"); err == nil {
+		t.Fatalf("expected location uuid error")
+	}
+	if _, err := svc.SetStock(t.Context(), uuid.NewString(), uuid.NewString(), -1, "uid", "login"); err == nil {
+		t.Fatalf("expected negative quantity error")
+	}
+	if repo.setStockCalled {
+		t.Fatalf("repo should not be called on invalid input")
+	}
+}
+
+func TestReceive_Success(t *testing.T) {
+	repo := &fakeRepo{itemToReturn: &domain.Item{ID: uuid.New(), Name: "Test", Count: 5, Price: 1}}
+	svc := item.NewItemService(repo, testCfg())
+
+	loc := uuid.NewString()
+	_, err := svc.Receive(t.Context(), repo.itemToReturn.ID.String(), loc, 3, "supply", "INV-1", "uid", "login")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	m := repo.appliedMovement
+	if m == nil || m.Type != movement.Receipt || m.Quantity != 3 || m.LocationID == nil || m.LocationID.String() != loc {
+		t.Fatalf("unexpected movement: %+v", m)
+	}
+	if m.Reason != "supply" || m.Reference != "INV-1" {
+		t.Fatalf("expected reason and reference to be kept, got %+v", m)
+	}
+}
+
+func TestIssue_NegativeDelta(t *testing.T) {
+	repo := &fakeRepo{itemToReturn: &domain.Item{ID: uuid.New(), Name: "Test", Count: 5, Price: 1}}
+	svc := item.NewItemService(repo, testCfg())
+
+	_, err := svc.Issue(t.Context(), repo.itemToReturn.ID.String(), "", 2, "", "", "uid", "login")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if repo.appliedMovement.CountDelta() != -2 || repo.appliedMovement.LocationID != nil {
+		t.Fatalf("unexpected movement: %+v", repo.appliedMovement)
+	}
+}
+
+func TestTransfer_RequiresLocations(t *testing.T) {
+	repo := &fakeRepo{}
+	svc := item.NewItemService(repo, testCfg())
+
+	if _, err := svc.Transfer(t.Context(), uuid.NewString(), uuid.NewString(), "", 1, "", "", "uid", "login"); err == nil {
+		t.Fatalf("expected error for missing destination")
+	}
+	if _, err := svc.Transfer(t.Context(), uuid.NewString(), "bad", uuid.NewString(), 1, "", "", "uid", "login"); err == nil {
+		t.Fatalf("expected location uuid error")
+	}
+	if repo.appliedMovement != nil {
+		t.Fatalf("repo should not be called on invalid input")
+	}
+}
+
+func TestAdjust_RepoError(t *testing.T) {
+	repo := &fakeRepo{errToReturn: errors.New("insufficient stock")}
+	svc := item.NewItemService(repo, testCfg())
+
+	if _, err := svc.Adjust(t.Context(), uuid.NewString(), "", -10, false, "", "", "uid", "login"); err == nil {
+		t.Fatalf("expected repo error")
+	}
+}
+
+func TestAdjust_ReturnsNewCount(t *testing.T) {
+	repo := &fakeRepo{countToReturn: 7}
+	svc := item.NewItemService(repo, testCfg())
+
+	count, err := svc.Adjust(t.Context(), uuid.NewString(), "", -3, true, "recount", "", "uid", "login")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if count != 7 {
+		t.Fatalf("expected count 7, got %d", count)
+	}
+	if repo.appliedMovement.Type != movement.Adjustment || repo.appliedMovement.CountDelta() != -3 || !repo.allowNegative {
+		t.Fatalf("unexpected movement: %+v", repo.appliedMovement)
+	}
+}
+
+func TestAdjust_ZeroDelta(t *testing.T) {
+	repo := &fakeRepo{}
+	svc := item.NewItemService(repo, testCfg())
+
+	if _, err := svc.Adjust(t.Context(), uuid.NewString(), "", 0, false, "", "", "uid", "login"); err == nil {
+		t.Fatalf("expected zero delta error")
+	}
+	if repo.appliedMovement != nil {
+		t.Fatalf("repo should not be called on invalid input")
+	}
+}
+
+func TestGetMovements_InvalidUUID(t *testing.T) {
+	svc := item.NewItemService(&fakeRepo{}, testCfg())
+	if _, err := svc.GetMovements(t.Context(), "bad"); !errors.Is(err, errs.ErrInvalidInput) {
+		t.Fatalf("expected invalid input error, got %v", err)
+	}
+}
+
+func TestGetItems_Defaults(t *testing.T) {
+	repo := &fakeRepo{}
+	svc := item.NewItemService(repo, testCfg())
+
+	if _, err := svc.GetItems(t.Context(), domain.ListOptions{}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if repo.listOptions.SortBy != domain.SortByName || repo.listOptions.Limit != 50 {
+		t.Fatalf("unexpected defaults: %+v", repo.listOptions)
+	}
+}
+
+func TestGetItems_CursorDropsOffset(t *testing.T) {
+	repo := &fakeRepo{}
+	svc := item.NewItemService(repo, testCfg())
+
+	cursor := domain.NewCursor(&domain.Item{ID: uuid.New(), Price: 5}, domain.SortByPrice, true)
+	_, err := svc.GetItems(t.Context(), domain.ListOptions{SortBy: domain.SortByPrice, Desc: true, Cursor: cursor, Offset: 20, Limit: 10})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if repo.listOptions.Offset != 0 || repo.listOptions.Cursor != cursor {
+		t.Fatalf("expected cursor to replace offset, got %+v", repo.listOptions)
+	}
+}
+
+func TestGetItems_InvalidOptions(t *testing.T) {
+	minCount, maxCount := 10, 1
+	cursor := domain.NewCursor(&domain.Item{ID: uuid.New(), Name: "A"}, domain.SortByName, false)
+	cases := []domain.ListOptions{
+		{SortBy: "id"},
+		{Limit: 501},
+		{Limit: -1},
+		{Offset: -5},
+		{MinCount: &minCount, MaxCount: &maxCount},
+		{SortBy: domain.SortByCount, Cursor: cursor},
+	}
+	for _, opts := range cases {
+		repo := &fakeRepo{}
+		svc := item.NewItemService(repo, testCfg())
+		if _, err := svc.GetItems(t.Context(), opts); !errors.Is(err, errs.ErrInvalidInput) {
+			t.Fatalf("expected invalid input for %+v, got %v", opts, err)
+		}
+	}
+}
+
+func TestGetItemAsOf(t *testing.T) {
+	repo := &fakeRepo{itemToReturn: &domain.Item{Name: "Bolt", Count: 3}}
+	svc := item.NewItemService(repo, testCfg())
+	asOf := time.Date(2026, 1, 31, 23, 59, 59, 0, time.UTC)
+
+	it, err := svc.GetItemAsOf(t.Context(), uuid.NewString(), asOf)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !repo.asOf.Equal(asOf) || len(it.Locations) != 1 {
+		t.Fatalf("expected snapshot with locations as of %v, got %+v", repo.asOf, it)
+	}
+}
+
+func TestGetItemAsOf_Invalid(t *testing.T) {
+	svc := item.NewItemService(&fakeRepo{}, testCfg())
+	if _, err := svc.GetItemAsOf(t.Context(), "not-uuid", time.Now()); !errors.Is(err, errs.ErrInvalidInput) {
+		t.Fatalf("expected invalid input for bad uuid, got %v", err)
+	}
+	if _, err := svc.GetItemAsOf(t.Context(), uuid.NewString(), time.Time{}); !errors.Is(err, errs.ErrInvalidInput) {
+		t.Fatalf("expected invalid input for zero as_of, got %v", err)
+	}
+}
+
+func TestRevert_DeletedItemUsesOldSnapshot(t *testing.T) {
+	itemID := uuid.New()
+	repo := &fakeRepo{historyRecord: &history.History{
+		ID:              uuid.New(),
+		ItemID:          itemID,
+		Action:          "deleted",
+		OldItemSnapshot: domain.Item{ID: itemID, Name: "Bolt", Count: 4, Price: 2.5},
+	}}
+	svc := item.NewItemService(repo, testCfg())
+
+	it, err := svc.Revert(t.Context(), itemID.String(), repo.historyRecord.ID.String(), "", 0, "u", "l")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if repo.reverted == nil || it.Name != "Bolt" || it.Count != 4 || it.ID != itemID {
+		t.Fatalf("expected item restored from old snapshot, got %+v", it)
+	}
+}
+
+func TestRevert_PassesVersion(t *testing.T) {
+	itemID := uuid.New()
+	repo := &fakeRepo{historyRecord: &history.History{
+		ID:              uuid.New(),
+		ItemID:          itemID,
+		OldItemSnapshot: domain.Item{ID: itemID, Name: "Old", Count: 1, Price: 1},
+		NewItemSnapshot: domain.Item{ID: itemID, Name: "New", Count: 2, Price: 2},
+	}}
+	svc := item.NewItemService(repo, testCfg())
+
+	it, err := svc.Revert(t.Context(), itemID.String(), repo.historyRecord.ID.String(), item.SnapshotNew, 5, "u", "l")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if it.Name != "New" || repo.revertVersion != 5 {
+		t.Fatalf("expected new snapshot with version 5, got %+v, %d", it, repo.revertVersion)
+	}
+}
+
+func TestRevert_BelowLocationStock(t *testing.T) {
+	itemID := uuid.New()
+	repo := &fakeRepo{
+		historyRecord: &history.History{
+			ID:              uuid.New(),
+			ItemID:          itemID,
+			NewItemSnapshot: domain.Item{ID: itemID, Name: "Bolt", Count: 2, Price: 1},
+		},
+		inLocations: 10,
+	}
+	svc := item.NewItemService(repo, testCfg())
+
+	_, err := svc.Revert(t.Context(), itemID.String(), repo.historyRecord.ID.String(), item.SnapshotNew, 0, "u", "l")
+	if !errors.Is(err, domain.ErrStockBelowLocations) {
+		t.Fatalf("expected stock below locations, got %v", err)
+	}
+}
+
+func TestRevert_Invalid(t *testing.T) {
+	itemID := uuid.New()
+	locationID := uuid.New()
+	cases := []struct {
+		name     string
+		record   *history.History
+		snapshot string
+		kind     error
+	}{
+		{"not found", nil, "", errs.ErrNotFound},
+		{"bad snapshot", &history.History{ItemID: itemID}, "latest", errs.ErrInvalidInput},
+		{"other item", &history.History{ItemID: uuid.New(), NewItemSnapshot: domain.Item{ID: itemID, Name: "A", Price: 1}}, "", errs.ErrValidation},
+		{"stock record", &history.History{ItemID: itemID, LocationID: &locationID}, "", errs.ErrValidation},
+		{"empty snapshot", &history.History{ItemID: itemID, NewItemSnapshot: domain.Item{ID: itemID, Name: "A", Price: 1}}, item.SnapshotOld, errs.ErrValidation},
+	}
+	for _, c := range cases {
+		repo := &fakeRepo{historyRecord: c.record}
+		svc := item.NewItemService(repo, testCfg())
+		_, err := svc.Revert(t.Context(), itemID.String(), uuid.NewString(), c.snapshot, 0, "u", "l")
+		if !errors.Is(err, c.kind) {
+			t.Fatalf("%s: expected %v, got %v", c.name, c.kind, err)
+		}
+		if repo.reverted != nil {
+			t.Fatalf("%s: revert must not reach the repository", c.name)
+		}
+	}
+}
+
+func TestDiff_BetweenTimestamps(t *testing.T) {
+	itemID := uuid.New()
+	repo := &fakeRepo{
+		stateAt: &history.History{NewItemSnapshot: domain.Item{ID: itemID, Name: "Bolt", Count: 1, Price: 2}},
+		changes: []*history.History{
+			{Action: "updated", ChangedByLogin: "anna", NewItemSnapshot: domain.Item{ID: itemID, Name: "Bolt", Count: 3, Price: 2}},
+			{Action: "adjusted", ChangedByLogin: "ivan", NewItemSnapshot: domain.Item{ID: itemID, Name: "Bolt M8", Count: 5, Price: 2}},
+		},
+	}
+	svc := item.NewItemService(repo, testCfg())
+
+	diff, err := svc.Diff(t.Context(), itemID.String(), "2026-01-05T00:00:00Z", "2026-01-09T23:59:59Z")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(diff.Changes) != 2 || diff.To.Count != 5 {
+		t.Fatalf("unexpected diff: %+v", diff)
+	}
+	if diff.Diff["count"].Old != 1 || diff.Diff["count"].New != 5 || diff.Diff["name"].New != "Bolt M8" {
+		t.Fatalf("unexpected aggregated diff: %+v", diff.Diff)
+	}
+	if _, ok := diff.Diff["price"]; ok {
+		t.Fatalf("unchanged price must not be in diff")
+	}
+}
+
+func TestDiff_FromHistoryRecord(t *testing.T) {
+	itemID := uuid.New()
+	record := &history.History{ID: uuid.New(), ItemID: itemID, Seq: 10, ChangedAt: time.Now().Add(-time.Hour)}
+	repo := &fakeRepo{historyRecord: record}
+	svc := item.NewItemService(repo, testCfg())
+
+	diff, err := svc.Diff(t.Context(), itemID.String(), record.ID.String(), "")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if repo.changesAfter.Seq != 10 || len(diff.Diff) != 0 {
+		t.Fatalf("expected changes after the record, got %+v", repo.changesAfter)
+	}
+}
+
+func TestDiff_Invalid(t *testing.T) {
+	svc := item.NewItemService(&fakeRepo{}, testCfg())
+	id := uuid.NewString()
+	cases := [][2]string{
+		{"", ""},
+		{"monday", ""},
+		{"2026-01-09T00:00:00Z", "2026-01-05T00:00:00Z"},
+	}
+	for _, c := range cases {
+		if _, err := svc.Diff(t.Context(), id, c[0], c[1]); !errors.Is(err, errs.ErrInvalidInput) {
+			t.Fatalf("%v: expected invalid input, got %v", c, err)
+		}
+	}
+}
@@ -0,0 +1,143 @@
+package user
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"warehousecontrol/internal/domain/errs"
+	"warehousecontrol/internal/domain/user"
+
+	"github.com/google/uuid"
+	wbzlog "github.com/wb-go/wbf/zlog"
+)
+
+// maxAPIKeyGrace ограничивает, сколько заменённый при ротации ключ продолжает действовать
+const maxAPIKeyGrace = 7 * 24 * time.Hour
+
+// CreateServiceAccount создаёт учётную запись интеграции. Войти по паролю она не может,
+// доступ выдаётся только API ключами.
+func (s *UserService) CreateServiceAccount(ctx context.Context, login, role string) (*user.User, error) {
+	if err := s.isValidLogin(login); err != nil {
+		wbzlog.Logger.Debug().Err(err).Msg("invalid login")
+		return nil, err
+	}
+	if err := s.checkRole(ctx, user.Role(role)); err != nil {
+		return nil, err
+	}
+	u, err := user.NewServiceAccount(login, user.Role(role))
+	if err != nil {
+		return nil, err
+	}
+	err = s.repo.SaveUser(ctx, u)
+	if err != nil {
+		return nil, err
+	}
+	wbzlog.Logger.Info().Str("login", u.Login).Str("role", role).Msg("service account created")
+	return u, nil
+}
+
+// CreateAPIKey выпускает ключ сервисной учётной записи. Значение ключа возвращается
+// только здесь: в базе хранится его хэш.
+func (s *UserService) CreateAPIKey(ctx context.Context, userID, name string, scopes []string, ttl time.Duration, actorID, actorLogin string) (*user.APIKey, string, error) {
+	id, err := uuid.Parse(userID)
+	if err != nil {
+		return nil, "", errs.Errorf(errs.ErrInvalidInput, "invalid UUID format: %v", err)
+	}
+	k, token, err := user.NewAPIKey(id, name, scopes, ttl)
+	if err != nil {
+		return nil, "", err
+	}
+	err = s.repo.CreateAPIKey(ctx, k, actorID, actorLogin)
+	if err != nil {
+		return nil, "", err
+	}
+	wbzlog.Logger.Info().Str("user_id", userID).Str("key", k.Prefix).Str("actor", actorLogin).Msg("api key created")
+	return k, token, nil
+}
+
+func (s *UserService) GetAPIKeys(ctx context.Context, userID string) ([]*user.APIKey, error) {
+	if _, err := uuid.Parse(userID); err != nil {
+		return nil, errs.Errorf(errs.ErrInvalidInput, "invalid UUID format: %v", err)
+	}
+	if _, err := s.repo.GetUserByID(ctx, userID); err != nil {
+		return nil, err
+	}
+	return s.repo.GetAPIKeys(ctx, userID)
+}
+
+// RotateAPIKey заменяет ключ новым с теми же именем и областями. Старый ключ действует
+// ещё grace, чтобы интеграцию можно было перенастроить без простоя.
+func (s *UserService) RotateAPIKey(ctx context.Context, userID, keyID string, ttl, grace time.Duration, actorID, actorLogin string) (*user.APIKey, string, error) {
+	if grace < 0 || grace > maxAPIKeyGrace {
+		return nil, "", errs.Errorf(errs.ErrValidation, "grace period must be between 0 and %s", maxAPIKeyGrace)
+	}
+	if _, err := uuid.Parse(keyID); err != nil {
+		return nil, "", errs.Errorf(errs.ErrInvalidInput, "invalid UUID format: %v", err)
+	}
+	if _, err := uuid.Parse(userID); err != nil {
+		return nil, "", errs.Errorf(errs.ErrInvalidInput, "invalid UUID format: %v", err)
+	}
+	old, err := s.repo.GetAPIKey(ctx, userID, keyID)
+	if err != nil {
+		return nil, "", err
+	}
+	next, token, err := user.NewAPIKey(old.UserID, old.Name, old.Scopes, ttl)
+	if err != nil {
+		return nil, "", err
+	}
+	err = s.repo.RotateAPIKey(ctx, keyID, next, grace, actorID, actorLogin)
+	if err != nil {
+		return nil, "", err
+	}
+	wbzlog.Logger.Info().Str("user_id", userID).Str("old_key", old.Prefix).Str("key", next.Prefix).Str("actor", actorLogin).Msg("api key rotated")
+	return next, token, nil
+}
+
+func (s *UserService) RevokeAPIKey(ctx context.Context, userID, keyID, actorID, actorLogin string) error {
+	if _, err := uuid.Parse(keyID); err != nil {
+		return errs.Errorf(errs.ErrInvalidInput, "invalid UUID format: %v", err)
+	}
+	if _, err := uuid.Parse(userID); err != nil {
+		return errs.Errorf(errs.ErrInvalidInput, "invalid UUID format: %v", err)
+	}
+	err := s.repo.RevokeAPIKey(ctx, userID, keyID, actorID, actorLogin)
+	if err != nil {
+		return err
+	}
+	wbzlog.Logger.Info().Str("user_id", userID).Str("key_id", keyID).Str("actor", actorLogin).Msg("api key revoked")
+	return nil
+}
+
+// AuthenticateAPIKey находит действующий ключ и его учётную запись. Неизвестный, истёкший
+// и отозванный ключи не различаются.
+func (s *UserService) AuthenticateAPIKey(ctx context.Context, token string) (*user.APIKey, *user.User, error) {
+	k, err := s.repo.GetAPIKeyByHash(ctx, user.HashAPIKey(token))
+	if errors.Is(err, user.ErrAPIKeyNotFound) {
+		return nil, nil, user.ErrAPIKeyInvalid
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+	now := time.Now()
+	if !k.Active(now) {
+		return nil, nil, user.ErrAPIKeyInvalid
+	}
+	u, err := s.repo.GetUserByID(ctx, k.UserID.String())
+	if errors.Is(err, user.ErrNotFound) {
+		return nil, nil, user.ErrAPIKeyInvalid
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+	if u.Disabled() {
+		return nil, nil, user.ErrDisabled
+	}
+	// время последнего использования обновляется не чаще раза в минуту
+	if k.LastUsedAt == nil || now.Sub(*k.LastUsedAt) > sessionTouchInterval {
+		if err := s.repo.TouchAPIKey(ctx, k.ID.String()); err != nil {
+			wbzlog.Logger.Warn().Err(err).Msg("cant update api key last use")
+		}
+	}
+	return k, u, nil
+}
@@ -0,0 +1,149 @@
+package user_test
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+
+	"warehousecontrol/internal/app/user"
+	domain "warehousecontrol/internal/domain/user"
+)
+
+func TestServiceAccount_NoPasswordLogin(t *testing.T) {
+	repo := &fakeRepo{}
+	svc := user.NewUserService(repo, &fakeJwt{}, testCfg())
+
+	u, err := svc.CreateServiceAccount(t.Context(), "erp-sync", "manager")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !u.ServiceAccount || u.Role != domain.Manager {
+		t.Fatalf("unexpected account: %+v", u)
+	}
+	if _, err := svc.CreateServiceAccount(t.Context(), "erp-sync", "root"); err == nil {
+		t.Fatal("expected invalid role error")
+	}
+	if _, err := svc.CreateServiceAccount(t.Context(), "a b", "viewer"); err == nil {
+		t.Fatal("expected invalid login error")
+	}
+	if _, err := svc.Login(t.Context(), "erp-sync", "Password1", domain.Client{}); !errors.Is(err, domain.ErrInvalidCredentials) {
+		t.Fatalf("expected invalid credentials, got %v", err)
+	}
+	// даже с паролем, заданным администратором, вход закрыт
+	if err := svc.ResetPassword(t.Context(), u.Id.String(), "Password1", uuid.NewString(), "admin"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if _, err := svc.Login(t.Context(), "erp-sync", "Password1", domain.Client{}); !errors.Is(err, domain.ErrServiceAccountLogin) {
+		t.Fatalf("expected service account login error, got %v", err)
+	}
+}
+
+func TestAPIKey_CreateAndAuthenticate(t *testing.T) {
+	repo, person := lockoutRepo(t)
+	svc := user.NewUserService(repo, &fakeJwt{}, testCfg())
+	account, _ := svc.CreateServiceAccount(t.Context(), "scanner", "viewer")
+	id := account.Id.String()
+	admin := uuid.NewString()
+
+	if _, _, err := svc.CreateAPIKey(t.Context(), person.Id.String(), "key", []string{domain.ScopeItemsRead}, 0, admin, "admin"); !errors.Is(err, domain.ErrNotServiceAccount) {
+		t.Fatalf("expected not a service account, got %v", err)
+	}
+	if _, _, err := svc.CreateAPIKey(t.Context(), id, "key", []string{"items:delete"}, 0, admin, "admin"); err == nil {
+		t.Fatal("expected unknown scope error")
+	}
+
+	k, token, err := svc.CreateAPIKey(t.Context(), id, "scanner", []string{domain.ScopeItemsRead, domain.ScopeItemsRead}, 0, admin, "admin")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !domain.IsAPIKey(token) || len(k.Scopes) != 1 || k.ExpiresAt != nil {
+		t.Fatalf("unexpected key %+v (%s)", k, token)
+	}
+
+	key, u, err := svc.AuthenticateAPIKey(t.Context(), token)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if key.ID != k.ID || u.Login != "scanner" || !key.Allows("items", false) || key.Allows("items", true) {
+		t.Fatalf("unexpected auth result %+v %+v", key, u)
+	}
+	// время использования обновляется не чаще раза в минуту
+	if _, _, err := svc.AuthenticateAPIKey(t.Context(), token); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if repo.keyTouches != 1 {
+		t.Fatalf("expected single touch, got %d", repo.keyTouches)
+	}
+
+	if _, _, err := svc.AuthenticateAPIKey(t.Context(), token+"x"); !errors.Is(err, domain.ErrAPIKeyInvalid) {
+		t.Fatalf("expected invalid key, got %v", err)
+	}
+	if _, err := svc.SetDisabled(t.Context(), id, true, admin, "admin"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if _, _, err := svc.AuthenticateAPIKey(t.Context(), token); !errors.Is(err, domain.ErrDisabled) {
+		t.Fatalf("expected disabled account, got %v", err)
+	}
+}
+
+func TestAPIKey_RotateAndRevoke(t *testing.T) {
+	repo := &fakeRepo{}
+	svc := user.NewUserService(repo, &fakeJwt{}, testCfg())
+	account, _ := svc.CreateServiceAccount(t.Context(), "erp-sync", "manager")
+	id := account.Id.String()
+	admin := uuid.NewString()
+
+	k, oldToken, err := svc.CreateAPIKey(t.Context(), id, "erp", []string{domain.ScopeItemsWrite}, 90*24*time.Hour, admin, "admin")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if _, _, err := svc.RotateAPIKey(t.Context(), id, k.ID.String(), 0, 30*24*time.Hour, admin, "admin"); err == nil {
+		t.Fatal("expected grace period limit error")
+	}
+
+	next, newToken, err := svc.RotateAPIKey(t.Context(), id, k.ID.String(), 0, time.Hour, admin, "admin")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if next.Name != "erp" || next.Scopes[0] != domain.ScopeItemsWrite || next.ExpiresAt != nil {
+		t.Fatalf("unexpected rotated key: %+v", next)
+	}
+	// старый ключ действует до конца льготного периода
+	if _, _, err := svc.AuthenticateAPIKey(t.Context(), oldToken); err != nil {
+		t.Fatalf("expected old key to work during grace period, got %v", err)
+	}
+	if k.ExpiresAt == nil || time.Until(*k.ExpiresAt) > time.Hour {
+		t.Fatalf("expected old key to expire within grace period, got %v", k.ExpiresAt)
+	}
+
+	if _, _, err := svc.RotateAPIKey(t.Context(), id, next.ID.String(), 0, 0, admin, "admin"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if _, _, err := svc.AuthenticateAPIKey(t.Context(), newToken); !errors.Is(err, domain.ErrAPIKeyInvalid) {
+		t.Fatalf("expected key rotated without grace to stop working, got %v", err)
+	}
+	if _, _, err := svc.RotateAPIKey(t.Context(), id, next.ID.String(), 0, 0, admin, "admin"); !errors.Is(err, domain.ErrAPIKeyInactive) {
+		t.Fatalf("expected inactive key error, got %v", err)
+	}
+
+	if err := svc.RevokeAPIKey(t.Context(), id, k.ID.String(), admin, "admin"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if _, _, err := svc.AuthenticateAPIKey(t.Context(), oldToken); !errors.Is(err, domain.ErrAPIKeyInvalid) {
+		t.Fatalf("expected revoked key to fail, got %v", err)
+	}
+	if err := svc.RevokeAPIKey(t.Context(), id, k.ID.String(), admin, "admin"); !errors.Is(err, domain.ErrAPIKeyNotFound) {
+		t.Fatalf("expected not found for revoked key, got %v", err)
+	}
+
+	keys, _ := svc.GetAPIKeys(t.Context(), id)
+	if len(keys) != 3 {
+		t.Fatalf("expected 3 keys, got %d", len(keys))
+	}
+	audit, _ := svc.GetUserAudit(t.Context(), id)
+	if len(audit) != 4 || audit[3].Action != domain.AuditAPIKeyRevoked {
+		t.Fatalf("unexpected audit: %+v", audit)
+	}
+}
@@ -0,0 +1,205 @@
+package user
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+
+	"warehousecontrol/internal/auth"
+	"warehousecontrol/internal/config"
+	"warehousecontrol/internal/domain/errs"
+	"warehousecontrol/internal/domain/user"
+
+	wbzlog "github.com/wb-go/wbf/zlog"
+	"golang.org/x/crypto/bcrypt"
+)
+
+const (
+	AuthenticatorLocal = "local"
+	AuthenticatorLDAP  = "ldap"
+)
+
+// errUnknownLogin — источнику учётных записей логин неизвестен, пароль проверяет следующий
+var errUnknownLogin = errors.New("login is unknown to authenticator")
+
+// Authenticator — источник учётных записей для входа по паролю. Login опрашивает источники
+// по порядку: первый, которому логин известен, и решает, подошёл ли пароль.
+type Authenticator interface {
+	Name() string
+	// Authenticate возвращает подтверждённого пользователя, errUnknownLogin, если логин
+	// источнику неизвестен, или user.ErrInvalidCredentials, если пароль не подошёл
+	Authenticate(ctx context.Context, login, password string) (*Principal, error)
+}
+
+// Principal — пользователь, пароль которого подтвердил источник: либо локальная учётная
+// запись, либо запись внешнего каталога с ролью по её группам. Учётную запись для внешней
+// записи заводит или синхронизирует UserService.
+type Principal struct {
+	User     *user.User
+	Identity *auth.ExternalIdentity
+	Role     user.Role
+}
+
+type DirectoryProvider interface {
+	Enabled() bool
+	Authenticate(login, password string) (*auth.ExternalIdentity, error)
+}
+
+// NewAuthenticators собирает источники из auth_config.authenticators. По умолчанию — пароли
+// в базе, а при включённом ldap и каталог после них.
+func NewAuthenticators(repo UserStorageProvider, dir DirectoryProvider, cfg *config.AppConfig) ([]Authenticator, error) {
+	names := cfg.AuthConfig.Authenticators
+	if len(names) == 0 {
+		names = []string{AuthenticatorLocal}
+		if dir.Enabled() {
+			names = append(names, AuthenticatorLDAP)
+		}
+	}
+	chain := make([]Authenticator, 0, len(names))
+	seen := make(map[string]bool, len(names))
+	for _, name := range names {
+		name = strings.ToLower(strings.TrimSpace(name))
+		if seen[name] {
+			return nil, fmt.Errorf("authenticator %q is listed twice", name)
+		}
+		seen[name] = true
+		switch name {
+		case AuthenticatorLocal:
+			chain = append(chain, NewPasswordAuthenticator(repo))
+		case AuthenticatorLDAP:
+			if !dir.Enabled() {
+				return nil, errors.New("authenticator ldap requires ldap.enabled")
+			}
+			chain = append(chain, NewLDAPAuthenticator(dir, cfg))
+		default:
+			return nil, fmt.Errorf("unknown authenticator %q", name)
+		}
+	}
+	return chain, nil
+}
+
+// passwordAuthenticator проверяет bcrypt-хэш пароля, хранящийся в базе
+type passwordAuthenticator struct {
+	repo UserStorageProvider
+}
+
+func NewPasswordAuthenticator(repo UserStorageProvider) Authenticator {
+	return &passwordAuthenticator{repo: repo}
+}
+
+func (a *passwordAuthenticator) Name() string {
+	return AuthenticatorLocal
+}
+
+func (a *passwordAuthenticator) Authenticate(ctx context.Context, login, password string) (*Principal, error) {
+	u, err := a.repo.GetUser(ctx, login)
+	if errors.Is(err, user.ErrNotFound) {
+		return nil, errUnknownLogin
+	}
+	if err != nil {
+		return nil, err
+	}
+	// у учётных записей внешних каталогов и провайдеров нет локального пароля
+	if len(u.Password) == 0 {
+		return nil, errUnknownLogin
+	}
+	if err := bcrypt.CompareHashAndPassword(u.Password, []byte(password)); err != nil {
+		wbzlog.Logger.Debug().Err(err).Msg("invalid password")
+		return nil, user.ErrInvalidCredentials
+	}
+	return &Principal{User: u}, nil
+}
+
+// ldapAuthenticator проверяет пароль в LDAP / Active Directory и выбирает роль по группам
+type ldapAuthenticator struct {
+	dir DirectoryProvider
+	cfg *config.AppConfig
+}
+
+func NewLDAPAuthenticator(dir DirectoryProvider, cfg *config.AppConfig) Authenticator {
+	return &ldapAuthenticator{dir: dir, cfg: cfg}
+}
+
+func (a *ldapAuthenticator) Name() string {
+	return AuthenticatorLDAP
+}
+
+func (a *ldapAuthenticator) Authenticate(ctx context.Context, login, password string) (*Principal, error) {
+	ident, err := a.dir.Authenticate(login, password)
+	switch {
+	case errors.Is(err, auth.ErrLDAPUnknownUser):
+		return nil, errUnknownLogin
+	case errors.Is(err, auth.ErrLDAPInvalidCredentials):
+		wbzlog.Logger.Debug().Err(err).Msg("invalid ldap password")
+		return nil, user.ErrInvalidCredentials
+	case err != nil:
+		wbzlog.Logger.Error().Err(err).Msg("ldap authentication failed")
+		return nil, user.ErrDirectoryUnavailable
+	}
+	c := a.cfg.LDAPConfig
+	role, ok := groupRole(c.GroupRoles, c.DefaultRole, ident.Groups, auth.SameDN)
+	if !ok {
+		wbzlog.Logger.Debug().Str("subject", ident.Subject).Strs("groups", ident.Groups).Msg("ldap login without role")
+		return nil, user.ErrNoSSORole
+	}
+	return &Principal{Identity: ident, Role: role}, nil
+}
+
+// groupRole выбирает роль по группам: первое совпадение в порядке mapping, без совпадений —
+// defaultRole; false — роль не выбрана
+func groupRole(mapping []config.GroupRole, defaultRole string, groups []string, same func(a, b string) bool) (user.Role, bool) {
+	for _, gr := range mapping {
+		if slices.ContainsFunc(groups, func(g string) bool { return same(g, gr.Group) }) {
+			return user.Role(gr.Role), true
+		}
+	}
+	return user.Role(defaultRole), defaultRole != ""
+}
+
+// authenticate опрашивает источники учётных записей по порядку. Недоступный источник
+// пропускается, но если логин не знает ни один из оставшихся, вход отвечает его ошибкой,
+// а не неверным паролем.
+func (s *UserService) authenticate(ctx context.Context, login, password string) (*user.User, error) {
+	var unavailable error
+	for _, a := range s.authenticators {
+		p, err := a.Authenticate(ctx, login, password)
+		if errors.Is(err, errUnknownLogin) {
+			continue
+		}
+		if errors.Is(err, user.ErrDirectoryUnavailable) {
+			unavailable = err
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		if p.User != nil {
+			return p.User, nil
+		}
+		return s.syncExternalUser(ctx, p.Identity, p.Role)
+	}
+	if unavailable != nil {
+		return nil, unavailable
+	}
+	return nil, errUnknownLogin
+}
+
+// syncExternalUser заводит учётную запись пользователя внешнего провайдера или каталога при
+// первом входе, а при следующих приводит её роль к выбранной по группам. Учётные записи,
+// совпавшие с локальными только логином, не связываются.
+func (s *UserService) syncExternalUser(ctx context.Context, ident *auth.ExternalIdentity, role user.Role) (*user.User, error) {
+	if err := s.checkRole(ctx, role); err != nil {
+		wbzlog.Logger.Error().Err(err).Str("role", string(role)).Str("issuer", ident.Issuer).Msg("group role mapping refers to unusable role")
+		return nil, err
+	}
+	if err := s.isValidLogin(ident.Login); err != nil {
+		return nil, errs.Errorf(errs.ErrValidation, "login %q from identity provider: %v", ident.Login, err)
+	}
+	candidate, err := user.NewSSOUser(ident.Login, role)
+	if err != nil {
+		return nil, err
+	}
+	return s.repo.SyncSSOUser(ctx, &user.Identity{Issuer: ident.Issuer, Subject: ident.Subject}, candidate)
+}
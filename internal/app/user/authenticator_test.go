@@ -0,0 +1,181 @@
+package user_test
+
+import (
+	"errors"
+	"testing"
+
+	"warehousecontrol/internal/app/user"
+	"warehousecontrol/internal/auth"
+	"warehousecontrol/internal/auth/ldaptest"
+	"warehousecontrol/internal/config"
+	"warehousecontrol/internal/domain/errs"
+	domain "warehousecontrol/internal/domain/user"
+
+	"github.com/google/uuid"
+)
+
+const (
+	ldapManagersDN = "cn=wh-managers,ou=groups,dc=example,dc=com"
+	ldapAdminsDN   = "cn=wh-admins,ou=groups,dc=example,dc=com"
+)
+
+func ldapCfg() *config.AppConfig {
+	cfg := testCfg()
+	cfg.LDAPConfig = config.LDAPConfig{
+		Enabled:     true,
+		URL:         "ldap://ldap.example.com",
+		UserBaseDN:  "ou=people,dc=example,dc=com",
+		IDAttribute: "entryUUID",
+		GroupRoles: []config.GroupRole{
+			{Group: ldapAdminsDN, Role: "admin"},
+			{Group: ldapManagersDN, Role: "manager"},
+		},
+	}
+	return cfg
+}
+
+// ldapDirectory — каталог с jdoe (менеджер), user (тот же логин, что у локальной учётной
+// записи из lockoutRepo) и staff без сопоставленных ролям групп
+func ldapDirectory() *ldaptest.Directory {
+	dir := ldaptest.NewDirectory()
+	dir.AddEntry("uid=jdoe,ou=people,dc=example,dc=com", "Ldap-pass1", map[string][]string{
+		"uid": {"jdoe"}, "entryUUID": {"uuid-jdoe"}, "memberOf": {"CN=WH-Managers,OU=Groups,DC=example,DC=com"},
+	})
+	dir.AddEntry("uid=user,ou=people,dc=example,dc=com", "Ldap-pass1", map[string][]string{
+		"uid": {"user"}, "entryUUID": {"uuid-user"}, "memberOf": {ldapAdminsDN},
+	})
+	dir.AddEntry("uid=staff,ou=people,dc=example,dc=com", "Ldap-pass1", map[string][]string{
+		"uid": {"staff"}, "entryUUID": {"uuid-staff"}, "memberOf": {"cn=staff,ou=groups,dc=example,dc=com"},
+	})
+	return dir
+}
+
+// ldapService — сервис с источниками из cfg; repo содержит локального пользователя "user"
+func ldapService(t *testing.T, cfg *config.AppConfig, dir *ldaptest.Directory) (*user.UserService, *fakeRepo) {
+	t.Helper()
+	repo, _ := lockoutRepo(t)
+	return user.NewUserService(repo, &fakeJwt{}, cfg, mustAuthenticators(t, repo, cfg, dir)...), repo
+}
+
+func mustAuthenticators(t *testing.T, repo *fakeRepo, cfg *config.AppConfig, dir *ldaptest.Directory) []user.Authenticator {
+	t.Helper()
+	directory, err := auth.NewLDAPDirectoryWithDialer(cfg, dir.Dial)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	chain, err := user.NewAuthenticators(repo, directory, cfg)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	return chain
+}
+
+func TestLogin_LDAPProvisionsUserAndSyncsRole(t *testing.T) {
+	dir := ldapDirectory()
+	svc, repo := ldapService(t, ldapCfg(), dir)
+
+	resp, err := svc.Login(t.Context(), "jdoe", "Ldap-pass1", domain.Client{IP: "10.0.0.1"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	u := repo.users["jdoe"]
+	if u == nil || u.Role != domain.Manager || len(u.Password) != 0 || resp.JWTResponse == nil {
+		t.Fatalf("expected provisioned manager with session, got %+v", u)
+	}
+	if repo.identities["ldap:ou=people,dc=example,dc=com|uuid-jdoe"] != u.Id {
+		t.Fatalf("expected linked identity, got %v", repo.identities)
+	}
+
+	// роль следует группам каталога при каждом входе
+	cfg := ldapCfg()
+	cfg.LDAPConfig.GroupRoles = nil
+	cfg.LDAPConfig.DefaultRole = "viewer"
+	svc = user.NewUserService(repo, &fakeJwt{}, cfg, mustAuthenticators(t, repo, cfg, dir)...)
+	if _, err := svc.Login(t.Context(), "jdoe", "Ldap-pass1", domain.Client{}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if repo.users["jdoe"].Id != u.Id || u.Role != domain.Viewer {
+		t.Fatalf("expected same account demoted to viewer, got %+v", repo.users["jdoe"])
+	}
+}
+
+func TestLogin_LDAPFailures(t *testing.T) {
+	dir := ldapDirectory()
+	svc, repo := ldapService(t, ldapCfg(), dir)
+
+	// неверный пароль каталога учитывается в блокировке так же, как локальный
+	for i := 0; i < 3; i++ {
+		if _, err := svc.Login(t.Context(), "jdoe", "wrong", domain.Client{IP: "10.0.0.1"}); !errors.Is(err, domain.ErrInvalidCredentials) {
+			t.Fatalf("attempt %d: expected invalid credentials, got %v", i, err)
+		}
+	}
+	if _, err := svc.Login(t.Context(), "jdoe", "Ldap-pass1", domain.Client{IP: "10.0.0.2"}); !errors.Is(err, domain.ErrTooManyAttempts) {
+		t.Fatalf("expected lockout, got %v", err)
+	}
+
+	if _, err := svc.Login(t.Context(), "staff", "Ldap-pass1", domain.Client{}); !errors.Is(err, domain.ErrNoSSORole) {
+		t.Fatalf("expected no mapped role, got %v", err)
+	}
+	if _, ok := repo.users["staff"]; ok {
+		t.Fatal("user without role must not be provisioned")
+	}
+	if _, err := svc.Login(t.Context(), "nobody", "Ldap-pass1", domain.Client{}); !errors.Is(err, domain.ErrInvalidCredentials) {
+		t.Fatalf("expected invalid credentials, got %v", err)
+	}
+
+	// каталог недоступен: локальные пользователи входят, остальным — 503, а не неверный пароль
+	dir.SetDown(true)
+	if _, err := svc.Login(t.Context(), "user", "Password1", domain.Client{}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if _, err := svc.Login(t.Context(), "newbie", "Ldap-pass1", domain.Client{}); !errors.Is(err, domain.ErrDirectoryUnavailable) || !errors.Is(err, errs.ErrUnavailable) {
+		t.Fatalf("expected directory unavailable, got %v", err)
+	}
+}
+
+func TestLogin_LDAPDoesNotTakeOverLocalAccount(t *testing.T) {
+	// локальный источник первым: логин "user" решает пароль из базы
+	svc, _ := ldapService(t, ldapCfg(), ldapDirectory())
+	if _, err := svc.Login(t.Context(), "user", "Ldap-pass1", domain.Client{}); !errors.Is(err, domain.ErrInvalidCredentials) {
+		t.Fatalf("expected invalid credentials, got %v", err)
+	}
+
+	// каталог первым: его запись с тем же логином с локальной не связывается
+	cfg := ldapCfg()
+	cfg.AuthConfig.Authenticators = []string{"ldap", "local"}
+	svc, repo := ldapService(t, cfg, ldapDirectory())
+	local := repo.users["user"]
+	if _, err := svc.Login(t.Context(), "user", "Ldap-pass1", domain.Client{}); !errors.Is(err, domain.ErrSSOLoginTaken) {
+		t.Fatalf("expected login taken, got %v", err)
+	}
+	if repo.users["user"] != local || local.Role != domain.Viewer {
+		t.Fatalf("local account must stay untouched, got %+v", repo.users["user"])
+	}
+	// логина нет в каталоге — проверяет следующий источник
+	repo.users["local2"] = &domain.User{Id: uuid.New(), Login: "local2", Password: local.Password, Role: domain.Viewer}
+	if _, err := svc.Login(t.Context(), "local2", "Password1", domain.Client{}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
+
+func TestNewAuthenticators(t *testing.T) {
+	repo, _ := lockoutRepo(t)
+	disabled, _ := auth.NewLDAPDirectory(testCfg())
+	chain, err := user.NewAuthenticators(repo, disabled, testCfg())
+	if err != nil || len(chain) != 1 || chain[0].Name() != user.AuthenticatorLocal {
+		t.Fatalf("expected only local authenticator, got %v (%v)", chain, err)
+	}
+
+	chain = mustAuthenticators(t, repo, ldapCfg(), ldapDirectory())
+	if len(chain) != 2 || chain[0].Name() != user.AuthenticatorLocal || chain[1].Name() != user.AuthenticatorLDAP {
+		t.Fatalf("expected local then ldap, got %v", chain)
+	}
+
+	for _, names := range [][]string{{"local", "kerberos"}, {"local", "local"}, {"ldap"}} {
+		cfg := testCfg()
+		cfg.AuthConfig.Authenticators = names
+		if _, err := user.NewAuthenticators(repo, disabled, cfg); err == nil {
+			t.Fatalf("%v: expected error", names)
+		}
+	}
+}
@@ -0,0 +1,68 @@
+package user
+
+import (
+	"context"
+	"time"
+
+	"warehousecontrol/internal/domain/errs"
+	"warehousecontrol/internal/domain/user"
+
+	"github.com/google/uuid"
+	wbzlog "github.com/wb-go/wbf/zlog"
+)
+
+const (
+	defaultInvitationTTL    = 72 * time.Hour
+	defaultInvitationMaxTTL = 30 * 24 * time.Hour
+)
+
+// CreateInvitation выпускает приглашение с ролью role; ttl = 0 означает срок по умолчанию.
+// Токен возвращается только здесь, в базе хранится его хэш.
+func (s *UserService) CreateInvitation(ctx context.Context, role string, ttl time.Duration, createdBy string) (*user.Invitation, string, error) {
+	creatorID, err := uuid.Parse(createdBy)
+	if err != nil {
+		return nil, "", errs.Errorf(errs.ErrInvalidInput, "invalid UUID format: %v", err)
+	}
+
+	if ttl == 0 {
+		ttl = s.cfg.AuthConfig.InvitationTTL
+		if ttl <= 0 {
+			ttl = defaultInvitationTTL
+		}
+	}
+	maxTTL := s.cfg.AuthConfig.InvitationMaxTTL
+	if maxTTL <= 0 {
+		maxTTL = defaultInvitationMaxTTL
+	}
+	if ttl < 0 || ttl > maxTTL {
+		return nil, "", errs.Errorf(errs.ErrValidation, "invitation ttl must be between 1s and %s", maxTTL)
+	}
+
+	if err := s.checkRole(ctx, user.Role(role)); err != nil {
+		return nil, "", err
+	}
+	inv, token, err := user.NewInvitation(user.Role(role), creatorID, ttl)
+	if err != nil {
+		wbzlog.Logger.Debug().Err(err).Msg("cant create invitation")
+		return nil, "", err
+	}
+	err = s.repo.SaveInvitation(ctx, inv)
+	if err != nil {
+		return nil, "", err
+	}
+	wbzlog.Logger.Info().Str("role", string(inv.Role)).Str("created_by", createdBy).Msg("invitation created")
+	return inv, token, nil
+}
+
+func (s *UserService) GetInvitations(ctx context.Context) ([]*user.Invitation, error) {
+	return s.repo.GetInvitations(ctx)
+}
+
+// RevokeInvitation удаляет ещё не использованное приглашение
+func (s *UserService) RevokeInvitation(ctx context.Context, id string) error {
+	_, err := uuid.Parse(id)
+	if err != nil {
+		return errs.Errorf(errs.ErrInvalidInput, "invalid UUID format: %v", err)
+	}
+	return s.repo.DeleteInvitation(ctx, id)
+}
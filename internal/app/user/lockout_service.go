@@ -0,0 +1,76 @@
+package user
+
+import (
+	"warehousecontrol/internal/domain/errs"
+	"warehousecontrol/internal/domain/user"
+
+	"github.com/google/uuid"
+	wbzlog "github.com/wb-go/wbf/zlog"
+	"golang.org/x/crypto/bcrypt"
+
+	"context"
+)
+
+// dummyPasswordHash сравнивается с паролем, когда логин не найден, чтобы ответ
+// для несуществующего логина не был заметно быстрее
+var dummyPasswordHash, _ = bcrypt.GenerateFromPassword([]byte("dummy-password"), bcrypt.DefaultCost)
+
+// loginFailed учитывает неудачный вход по логину и IP. Клиент всегда получает
+// ErrInvalidCredentials, блокировка сказывается только на следующих попытках.
+func (s *UserService) loginFailed(ctx context.Context, login, ip string) error {
+	if err := s.registerFailure(ctx, login, ip); err != nil {
+		return err
+	}
+	return user.ErrInvalidCredentials
+}
+
+// checkLockout отклоняет попытку, если логин или IP заблокированы
+func (s *UserService) checkLockout(ctx context.Context, login, ip string) error {
+	until, err := s.repo.LoginLockedUntil(ctx, login, ip)
+	if err != nil {
+		return err
+	}
+	if until != nil {
+		wbzlog.Logger.Debug().Str("login", login).Str("ip", ip).Msg("login attempt while locked")
+		return user.ErrTooManyAttempts
+	}
+	return nil
+}
+
+func (s *UserService) registerFailure(ctx context.Context, login, ip string) error {
+	cfg := s.cfg.LockoutConfig
+	counters := []struct {
+		scope, subject string
+		policy         user.LockoutPolicy
+	}{
+		{user.ScopeLogin, login, user.LockoutPolicy{MaxFailures: cfg.LoginMaxFailures, Window: cfg.Window, Cooldown: cfg.LoginCooldown}},
+		{user.ScopeIP, ip, user.LockoutPolicy{MaxFailures: cfg.IPMaxFailures, Window: cfg.Window, Cooldown: cfg.IPCooldown}},
+	}
+	for _, c := range counters {
+		if c.subject == "" || c.policy.MaxFailures <= 0 {
+			continue
+		}
+		locked, err := s.repo.RegisterLoginFailure(ctx, c.scope, c.subject, c.policy)
+		if err != nil {
+			return err
+		}
+		if locked {
+			wbzlog.Logger.Warn().Str("scope", c.scope).Str("subject", c.subject).Dur("cooldown", c.policy.Cooldown).Msg("login locked after failed attempts")
+		}
+	}
+	return nil
+}
+
+// UnlockUser досрочно снимает блокировку входа пользователя (только по логину;
+// блокировка IP снимается по истечении времени)
+func (s *UserService) UnlockUser(ctx context.Context, id, actorID, actorLogin string) error {
+	if _, err := uuid.Parse(id); err != nil {
+		return errs.Errorf(errs.ErrInvalidInput, "invalid UUID format: %v", err)
+	}
+	err := s.repo.UnlockUser(ctx, id, actorID, actorLogin)
+	if err != nil {
+		return err
+	}
+	wbzlog.Logger.Info().Str("user_id", id).Str("actor", actorLogin).Msg("user login unlocked")
+	return nil
+}
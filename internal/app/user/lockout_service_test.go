@@ -0,0 +1,109 @@
+package user_test
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/google/uuid"
+	"golang.org/x/crypto/bcrypt"
+
+	"warehousecontrol/internal/app/user"
+	"warehousecontrol/internal/domain/errs"
+	domain "warehousecontrol/internal/domain/user"
+)
+
+func lockoutRepo(t *testing.T) (*fakeRepo, *domain.User) {
+	t.Helper()
+	hashed, _ := bcrypt.GenerateFromPassword([]byte("Password1"), bcrypt.MinCost)
+	u := &domain.User{Id: uuid.New(), Login: "user", Password: hashed, Role: domain.Viewer}
+	return &fakeRepo{users: map[string]*domain.User{"user": u}}, u
+}
+
+func TestLogin_LocksLoginAfterFailures(t *testing.T) {
+	repo, u := lockoutRepo(t)
+	svc := user.NewUserService(repo, &fakeJwt{}, testCfg())
+
+	for i := 0; i < 3; i++ {
+		if _, err := svc.Login(t.Context(), "user", "wrong", domain.Client{IP: "10.0.0.1"}); !errors.Is(err, domain.ErrInvalidCredentials) {
+			t.Fatalf("attempt %d: expected invalid credentials, got %v", i, err)
+		}
+	}
+	// даже верный пароль не принимается, пока действует блокировка
+	if _, err := svc.Login(t.Context(), "user", "Password1", domain.Client{IP: "10.0.0.2"}); !errors.Is(err, domain.ErrTooManyAttempts) || !errors.Is(err, errs.ErrRateLimited) {
+		t.Fatalf("expected lockout, got %v", err)
+	}
+
+	audit, _ := svc.GetUserAudit(t.Context(), u.Id.String())
+	if len(audit) != 1 || audit[0].Action != domain.AuditLocked || audit[0].ActorLogin != domain.SystemActor {
+		t.Fatalf("expected lock in audit, got %+v", audit)
+	}
+
+	if err := svc.UnlockUser(t.Context(), u.Id.String(), uuid.NewString(), "admin"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if _, err := svc.Login(t.Context(), "user", "Password1", domain.Client{IP: "10.0.0.2"}); err != nil {
+		t.Fatalf("expected login after unlock, got %v", err)
+	}
+	audit, _ = svc.GetUserAudit(t.Context(), u.Id.String())
+	if len(audit) != 2 || audit[1].Action != domain.AuditUnlocked || audit[1].ActorLogin != "admin" {
+		t.Fatalf("expected unlock in audit, got %+v", audit)
+	}
+}
+
+func TestLogin_UnknownLoginLocksTheSameWay(t *testing.T) {
+	repo, _ := lockoutRepo(t)
+	svc := user.NewUserService(repo, &fakeJwt{}, testCfg())
+
+	for i := 0; i < 3; i++ {
+		if _, err := svc.Login(t.Context(), "ghost", "wrong", domain.Client{}); !errors.Is(err, domain.ErrInvalidCredentials) {
+			t.Fatalf("attempt %d: expected invalid credentials, got %v", i, err)
+		}
+	}
+	if _, err := svc.Login(t.Context(), "ghost", "wrong", domain.Client{}); !errors.Is(err, domain.ErrTooManyAttempts) {
+		t.Fatalf("expected unknown login to be locked too, got %v", err)
+	}
+}
+
+func TestLogin_SuccessResetsLoginCounter(t *testing.T) {
+	repo, _ := lockoutRepo(t)
+	svc := user.NewUserService(repo, &fakeJwt{}, testCfg())
+
+	for round := 0; round < 3; round++ {
+		_, _ = svc.Login(t.Context(), "user", "wrong", domain.Client{})
+		_, _ = svc.Login(t.Context(), "user", "wrong", domain.Client{})
+		if _, err := svc.Login(t.Context(), "user", "Password1", domain.Client{}); err != nil {
+			t.Fatalf("round %d: expected counter to be reset by success, got %v", round, err)
+		}
+	}
+}
+
+func TestLogin_LocksIP(t *testing.T) {
+	repo, _ := lockoutRepo(t)
+	svc := user.NewUserService(repo, &fakeJwt{}, testCfg())
+
+	// перебор разных логинов с одного адреса
+	for i := 0; i < 5; i++ {
+		_, _ = svc.Login(t.Context(), uuid.NewString()[:8], "wrong", domain.Client{IP: "10.0.0.9"})
+	}
+	if _, err := svc.Login(t.Context(), "user", "Password1", domain.Client{IP: "10.0.0.9"}); !errors.Is(err, domain.ErrTooManyAttempts) {
+		t.Fatalf("expected IP lockout, got %v", err)
+	}
+	if _, err := svc.Login(t.Context(), "user", "Password1", domain.Client{IP: "10.0.0.10"}); err != nil {
+		t.Fatalf("expected other IP to work, got %v", err)
+	}
+}
+
+func TestLogin_LockoutDisabled(t *testing.T) {
+	repo, _ := lockoutRepo(t)
+	cfg := testCfg()
+	cfg.LockoutConfig.LoginMaxFailures = 0
+	cfg.LockoutConfig.IPMaxFailures = 0
+	svc := user.NewUserService(repo, &fakeJwt{}, cfg)
+
+	for i := 0; i < 10; i++ {
+		_, _ = svc.Login(t.Context(), "user", "wrong", domain.Client{IP: "10.0.0.1"})
+	}
+	if _, err := svc.Login(t.Context(), "user", "Password1", domain.Client{IP: "10.0.0.1"}); err != nil {
+		t.Fatalf("expected no lockout with zero thresholds, got %v", err)
+	}
+}
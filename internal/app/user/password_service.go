@@ -0,0 +1,107 @@
+package user
+
+import (
+	"warehousecontrol/internal/auth"
+	"warehousecontrol/internal/domain/errs"
+	"warehousecontrol/internal/domain/user"
+
+	"github.com/google/uuid"
+	wbzlog "github.com/wb-go/wbf/zlog"
+	"golang.org/x/crypto/bcrypt"
+
+	"context"
+	"time"
+)
+
+// defaultPasswordResetTTL — срок токена сброса пароля, если password_reset_ttl не задан
+const defaultPasswordResetTTL = time.Hour
+
+// ChangePassword меняет пароль пользователя по текущему паролю. Все сессии пользователя
+// завершаются, а вызывающий получает токены новой сессии. Неверный текущий пароль
+// учитывается в блокировке входа так же, как неудачный вход.
+func (s *UserService) ChangePassword(ctx context.Context, userID, current, next string, client user.Client) (*auth.JWTResponse, error) {
+	if _, err := uuid.Parse(userID); err != nil {
+		return nil, errs.Errorf(errs.ErrInvalidInput, "invalid UUID format: %v", err)
+	}
+	if current == "" || next == "" {
+		return nil, errs.New(errs.ErrValidation, "current and new password required")
+	}
+	u, err := s.repo.GetUserByID(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	if u.Disabled() {
+		return nil, user.ErrDisabled
+	}
+	if err := s.checkLockout(ctx, u.Login, client.IP); err != nil {
+		return nil, err
+	}
+	if err := bcrypt.CompareHashAndPassword(u.Password, []byte(current)); err != nil {
+		if err := s.registerFailure(ctx, u.Login, client.IP); err != nil {
+			return nil, err
+		}
+		return nil, user.ErrWrongPassword
+	}
+	if current == next {
+		return nil, user.ErrSamePassword
+	}
+	if err := s.isValidPassword(next); err != nil {
+		return nil, err
+	}
+
+	hashed, err := bcrypt.GenerateFromPassword([]byte(next), bcrypt.DefaultCost)
+	if err != nil {
+		return nil, err
+	}
+	err = s.repo.ChangeUserPassword(ctx, userID, hashed, userID, u.Login)
+	if err != nil {
+		return nil, err
+	}
+	wbzlog.Logger.Info().Str("user_id", userID).Msg("user changed password")
+
+	u.Password = hashed
+	return s.openSession(ctx, u, client)
+}
+
+// IssuePasswordResetToken выпускает для пользователя токен сброса пароля со сроком
+// из auth_config.password_reset_ttl. Токен возвращается один раз, прежние токены аннулируются.
+func (s *UserService) IssuePasswordResetToken(ctx context.Context, id, actorID, actorLogin string) (*user.PasswordResetToken, string, error) {
+	userID, err := uuid.Parse(id)
+	if err != nil {
+		return nil, "", errs.Errorf(errs.ErrInvalidInput, "invalid UUID format: %v", err)
+	}
+	ttl := s.cfg.AuthConfig.PasswordResetTTL
+	if ttl <= 0 {
+		ttl = defaultPasswordResetTTL
+	}
+	rt, token, err := user.NewPasswordResetToken(userID, ttl)
+	if err != nil {
+		return nil, "", err
+	}
+	err = s.repo.SavePasswordResetToken(ctx, rt, actorID, actorLogin)
+	if err != nil {
+		return nil, "", err
+	}
+	wbzlog.Logger.Info().Str("user_id", id).Str("actor", actorLogin).Time("expires_at", rt.ExpiresAt).Msg("password reset token issued")
+	return rt, token, nil
+}
+
+// ResetPasswordWithToken задаёт новый пароль по токену сброса и завершает все сессии пользователя
+func (s *UserService) ResetPasswordWithToken(ctx context.Context, token, password string) error {
+	if token == "" {
+		return errs.New(errs.ErrInvalidInput, "password reset token required")
+	}
+	if err := s.isValidPassword(password); err != nil {
+		return err
+	}
+	hashed, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return err
+	}
+	u, err := s.repo.RedeemPasswordResetToken(ctx, user.HashPasswordResetToken(token), hashed)
+	if err != nil {
+		return err
+	}
+	wbzlog.Logger.Info().Str("user_id", u.Id.String()).Msg("password reset by token")
+	return nil
+}
@@ -0,0 +1,150 @@
+package user_test
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"golang.org/x/crypto/bcrypt"
+
+	"warehousecontrol/internal/app/user"
+	"warehousecontrol/internal/domain/errs"
+	domain "warehousecontrol/internal/domain/user"
+)
+
+func TestChangePassword(t *testing.T) {
+	repo, u := lockoutRepo(t)
+	svc := user.NewUserService(repo, &fakeJwt{}, testCfg())
+
+	old, _ := svc.Login(t.Context(), "user", "Password1", domain.Client{})
+	tokens, err := svc.ChangePassword(t.Context(), u.Id.String(), "Password1", "Newpass22", domain.Client{UserAgent: "laptop"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	// прежние сессии завершены, новая выдана вызывающему
+	if _, err := svc.ValidateTokens(t.Context(), old.AccessToken); !errors.Is(err, domain.ErrSessionRevoked) {
+		t.Fatalf("expected old session to be revoked, got %v", err)
+	}
+	if _, err := svc.RefreshTokens(t.Context(), old.RefreshToken); !errors.Is(err, domain.ErrRefreshTokenInvalid) {
+		t.Fatalf("expected old refresh token to be rejected, got %v", err)
+	}
+	if _, err := svc.ValidateTokens(t.Context(), tokens.AccessToken); err != nil {
+		t.Fatalf("expected new session to be valid, got %v", err)
+	}
+
+	if _, err := svc.Login(t.Context(), "user", "Password1", domain.Client{}); !errors.Is(err, domain.ErrInvalidCredentials) {
+		t.Fatalf("expected old password to stop working, got %v", err)
+	}
+	if _, err := svc.Login(t.Context(), "user", "Newpass22", domain.Client{}); err != nil {
+		t.Fatalf("expected login with new password, got %v", err)
+	}
+
+	audit, _ := svc.GetUserAudit(t.Context(), u.Id.String())
+	if len(audit) != 1 || audit[0].Action != domain.AuditPasswordChanged || audit[0].ActorLogin != "user" {
+		t.Fatalf("unexpected audit: %+v", audit)
+	}
+}
+
+func TestChangePassword_Errors(t *testing.T) {
+	repo, u := lockoutRepo(t)
+	svc := user.NewUserService(repo, &fakeJwt{}, testCfg())
+	id := u.Id.String()
+
+	if _, err := svc.ChangePassword(t.Context(), id, "Password1", "Password1", domain.Client{}); !errors.Is(err, domain.ErrSamePassword) {
+		t.Fatalf("expected same password error, got %v", err)
+	}
+	if _, err := svc.ChangePassword(t.Context(), id, "Password1", "weak", domain.Client{}); !errors.Is(err, errs.ErrValidation) {
+		t.Fatalf("expected password policy error, got %v", err)
+	}
+	if _, err := svc.ChangePassword(t.Context(), "bad", "Password1", "Newpass22", domain.Client{}); !errors.Is(err, errs.ErrInvalidInput) {
+		t.Fatalf("expected invalid input, got %v", err)
+	}
+
+	// неверный текущий пароль учитывается в блокировке входа
+	for i := 0; i < 3; i++ {
+		if _, err := svc.ChangePassword(t.Context(), id, "wrong", "Newpass22", domain.Client{}); !errors.Is(err, domain.ErrWrongPassword) {
+			t.Fatalf("attempt %d: expected wrong password, got %v", i, err)
+		}
+	}
+	if _, err := svc.ChangePassword(t.Context(), id, "Password1", "Newpass22", domain.Client{}); !errors.Is(err, domain.ErrTooManyAttempts) {
+		t.Fatalf("expected lockout, got %v", err)
+	}
+}
+
+func TestPasswordResetToken(t *testing.T) {
+	repo, u := lockoutRepo(t)
+	svc := user.NewUserService(repo, &fakeJwt{}, testCfg())
+
+	session, _ := svc.Login(t.Context(), "user", "Password1", domain.Client{})
+	first, _, err := svc.IssuePasswordResetToken(t.Context(), u.Id.String(), uuid.NewString(), "admin")
+	if err != nil || first.ExpiresAt.Sub(time.Now()) > time.Hour {
+		t.Fatalf("unexpected token: %+v %v", first, err)
+	}
+	_, token, err := svc.IssuePasswordResetToken(t.Context(), u.Id.String(), uuid.NewString(), "admin")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if err := svc.ResetPasswordWithToken(t.Context(), token, "weak"); !errors.Is(err, errs.ErrValidation) {
+		t.Fatalf("expected password policy error, got %v", err)
+	}
+	if err := svc.ResetPasswordWithToken(t.Context(), token, "Newpass22"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := bcrypt.CompareHashAndPassword(u.Password, []byte("Newpass22")); err != nil {
+		t.Fatal("expected password to be replaced")
+	}
+	if _, err := svc.ValidateTokens(t.Context(), session.AccessToken); !errors.Is(err, domain.ErrSessionRevoked) {
+		t.Fatalf("expected sessions to be revoked, got %v", err)
+	}
+
+	// токен одноразовый
+	if err := svc.ResetPasswordWithToken(t.Context(), token, "Other333"); !errors.Is(err, domain.ErrResetTokenInvalid) {
+		t.Fatalf("expected used token to be rejected, got %v", err)
+	}
+	if err := svc.ResetPasswordWithToken(t.Context(), "unknown", "Other333"); !errors.Is(err, domain.ErrResetTokenInvalid) {
+		t.Fatalf("expected unknown token to be rejected, got %v", err)
+	}
+
+	audit, _ := svc.GetUserAudit(t.Context(), u.Id.String())
+	if len(audit) != 3 || audit[0].Action != domain.AuditResetIssued || audit[2].Action != domain.AuditPasswordChanged {
+		t.Fatalf("unexpected audit: %+v", audit)
+	}
+}
+
+func TestPasswordResetToken_DefaultTTL(t *testing.T) {
+	repo, u := lockoutRepo(t)
+	cfg := testCfg()
+	cfg.AuthConfig.PasswordResetTTL = 0
+	svc := user.NewUserService(repo, &fakeJwt{}, cfg)
+
+	rt, _, err := svc.IssuePasswordResetToken(t.Context(), u.Id.String(), uuid.NewString(), "admin")
+	if err != nil {
+		t.Fatalf("expected default ttl, got %v", err)
+	}
+	if rt.ExpiresAt.Before(time.Now().Add(59 * time.Minute)) {
+		t.Fatalf("expected token to live about an hour, got expiry %v", rt.ExpiresAt)
+	}
+}
+
+func TestPasswordResetToken_ReplacedAndExpired(t *testing.T) {
+	repo, u := lockoutRepo(t)
+	svc := user.NewUserService(repo, &fakeJwt{}, testCfg())
+
+	_, first, _ := svc.IssuePasswordResetToken(t.Context(), u.Id.String(), uuid.NewString(), "admin")
+	second, token, _ := svc.IssuePasswordResetToken(t.Context(), u.Id.String(), uuid.NewString(), "admin")
+	if err := svc.ResetPasswordWithToken(t.Context(), first, "Newpass22"); !errors.Is(err, domain.ErrResetTokenInvalid) {
+		t.Fatalf("expected replaced token to be rejected, got %v", err)
+	}
+
+	second.ExpiresAt = time.Now().Add(-time.Second)
+	if err := svc.ResetPasswordWithToken(t.Context(), token, "Newpass22"); !errors.Is(err, domain.ErrResetTokenInvalid) {
+		t.Fatalf("expected expired token to be rejected, got %v", err)
+	}
+
+	if _, _, err := svc.IssuePasswordResetToken(t.Context(), uuid.NewString(), uuid.NewString(), "admin"); !errors.Is(err, domain.ErrNotFound) {
+		t.Fatalf("expected unknown user, got %v", err)
+	}
+}
@@ -0,0 +1,161 @@
+package user
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync"
+	"time"
+
+	"warehousecontrol/internal/auth"
+	"warehousecontrol/internal/domain/errs"
+	"warehousecontrol/internal/domain/user"
+
+	wbzlog "github.com/wb-go/wbf/zlog"
+)
+
+// defaultRoleCacheTTL — сколько держать роли в памяти, если role_cache_ttl не задан
+const defaultRoleCacheTTL = 30 * time.Second
+
+// roleCache — роли в памяти процесса, чтобы не читать их из базы на каждый запрос.
+// Изменения через этот экземпляр сервиса видны сразу, сделанные другими — не позже
+// чем через role_cache_ttl или как только придёт токен с более новой версией роли.
+type roleCache struct {
+	mu       sync.Mutex
+	roles    map[user.Role]*user.RoleDefinition
+	loadedAt time.Time
+}
+
+func (s *UserService) GetRoles(ctx context.Context) ([]*user.RoleDefinition, error) {
+	return s.repo.GetRoles(ctx)
+}
+
+func (s *UserService) GetRole(ctx context.Context, name string) (*user.RoleDefinition, error) {
+	if !user.Role(name).Valid() {
+		return nil, user.ErrRoleNotFound
+	}
+	return s.repo.GetRole(ctx, user.Role(name))
+}
+
+// CreateRole заводит роль с набором прав; назначать её можно сразу
+func (s *UserService) CreateRole(ctx context.Context, name, description string, permissions []string, actorLogin string) (*user.RoleDefinition, error) {
+	r, err := user.NewRole(name, description, permissions)
+	if err != nil {
+		return nil, err
+	}
+	err = s.repo.CreateRole(ctx, r)
+	if err != nil {
+		return nil, err
+	}
+	s.invalidateRoles()
+	wbzlog.Logger.Info().Str("role", name).Strs("permissions", permissions).Str("actor", actorLogin).Msg("role created")
+	return r, nil
+}
+
+// UpdateRole меняет права пользовательской роли. Новые права действуют сразу, в том числе
+// для уже выданных токенов: версия роли в них перестаёт совпадать с актуальной.
+func (s *UserService) UpdateRole(ctx context.Context, name, description string, permissions []string, actorLogin string) (*user.RoleDefinition, error) {
+	if !user.Role(name).Valid() {
+		return nil, user.ErrRoleNotFound
+	}
+	perms, err := user.ParsePermissions(permissions)
+	if err != nil {
+		return nil, err
+	}
+	r, err := s.repo.UpdateRole(ctx, user.Role(name), strings.TrimSpace(description), perms)
+	if err != nil {
+		return nil, err
+	}
+	s.invalidateRoles()
+	wbzlog.Logger.Info().Str("role", name).Strs("permissions", permissions).Int("version", r.Version).Str("actor", actorLogin).Msg("role updated")
+	return r, nil
+}
+
+// DeleteRole удаляет пользовательскую роль, которая никому не назначена
+func (s *UserService) DeleteRole(ctx context.Context, name, actorLogin string) error {
+	if !user.Role(name).Valid() {
+		return user.ErrRoleNotFound
+	}
+	err := s.repo.DeleteRole(ctx, user.Role(name))
+	if err != nil {
+		return err
+	}
+	s.invalidateRoles()
+	wbzlog.Logger.Info().Str("role", name).Str("actor", actorLogin).Msg("role deleted")
+	return nil
+}
+
+// RolePermissions возвращает актуальные права роли, например для запросов по API ключу
+func (s *UserService) RolePermissions(ctx context.Context, role user.Role) (user.Permissions, error) {
+	r, err := s.role(ctx, role, 0)
+	if err != nil {
+		return nil, err
+	}
+	return r.Permissions, nil
+}
+
+// checkRole проверяет, что назначаемая роль существует
+func (s *UserService) checkRole(ctx context.Context, role user.Role) error {
+	if !role.Valid() {
+		return errs.New(errs.ErrValidation, "invalid role type")
+	}
+	_, err := s.role(ctx, role, 0)
+	if errors.Is(err, user.ErrRoleNotFound) {
+		return errs.Errorf(errs.ErrValidation, "unknown role: %s", role)
+	}
+	return err
+}
+
+// resolvePermissions сверяет версию роли в токене с актуальной: если роль с момента
+// выпуска токена менялась, права берутся из неё. У удалённой роли прав нет.
+func (s *UserService) resolvePermissions(ctx context.Context, payload *auth.JWTPayload) error {
+	r, err := s.role(ctx, payload.Role, payload.RoleVersion)
+	if errors.Is(err, user.ErrRoleNotFound) {
+		payload.Permissions = user.Permissions{}
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if r.Version != payload.RoleVersion {
+		payload.Permissions = r.Permissions
+		payload.RoleVersion = r.Version
+	}
+	return nil
+}
+
+// role берёт роль из кэша; кэш перечитывается, если устарел, роли в нём нет
+// или её версия меньше minVersion
+func (s *UserService) role(ctx context.Context, name user.Role, minVersion int) (*user.RoleDefinition, error) {
+	s.roles.mu.Lock()
+	defer s.roles.mu.Unlock()
+
+	ttl := s.cfg.AuthConfig.RoleCacheTTL
+	if ttl <= 0 {
+		ttl = defaultRoleCacheTTL
+	}
+	r, ok := s.roles.roles[name]
+	fresh := s.roles.roles != nil && time.Since(s.roles.loadedAt) < ttl
+	if !fresh || !ok || r.Version < minVersion {
+		roles, err := s.repo.GetRoles(ctx)
+		if err != nil {
+			return nil, err
+		}
+		s.roles.roles = make(map[user.Role]*user.RoleDefinition, len(roles))
+		for _, def := range roles {
+			s.roles.roles[def.Name] = def
+		}
+		s.roles.loadedAt = time.Now()
+		r, ok = s.roles.roles[name]
+	}
+	if !ok {
+		return nil, user.ErrRoleNotFound
+	}
+	return r, nil
+}
+
+func (s *UserService) invalidateRoles() {
+	s.roles.mu.Lock()
+	s.roles.roles = nil
+	s.roles.mu.Unlock()
+}
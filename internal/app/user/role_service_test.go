@@ -0,0 +1,181 @@
+package user_test
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"warehousecontrol/internal/app/user"
+	"warehousecontrol/internal/domain/errs"
+	domain "warehousecontrol/internal/domain/user"
+)
+
+func TestCreateRole_Assignable(t *testing.T) {
+	repo, u := lockoutRepo(t)
+	svc := user.NewUserService(repo, &fakeJwt{}, testCfg())
+
+	if _, err := svc.ChangeRole(t.Context(), u.Id.String(), "auditor", "admin-id", "admin"); !errors.Is(err, errs.ErrValidation) {
+		t.Fatalf("expected validation error for unknown role, got %v", err)
+	}
+	r, err := svc.CreateRole(t.Context(), "auditor", " Reads history ", []string{"history.read", "items.read", "history.read"}, "admin")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if r.Description != "Reads history" || len(r.Permissions) != 2 || r.Version != 1 || r.BuiltIn {
+		t.Fatalf("unexpected role %+v", r)
+	}
+	if _, err := svc.ChangeRole(t.Context(), u.Id.String(), "auditor", "admin-id", "admin"); err != nil {
+		t.Fatalf("custom role must be assignable, got %v", err)
+	}
+	if _, _, err := svc.CreateInvitation(t.Context(), "auditor", 0, u.Id.String()); err != nil {
+		t.Fatalf("custom role must be usable in invitations, got %v", err)
+	}
+
+	if _, err := svc.CreateRole(t.Context(), "auditor", "", []string{"items.read"}, "admin"); !errors.Is(err, domain.ErrRoleExists) {
+		t.Fatalf("expected conflict, got %v", err)
+	}
+	if _, err := svc.CreateRole(t.Context(), "Auditor!", "", []string{"items.read"}, "admin"); !errors.Is(err, errs.ErrValidation) {
+		t.Fatalf("expected invalid name, got %v", err)
+	}
+	if _, err := svc.CreateRole(t.Context(), "clerk", "", []string{"items.destroy"}, "admin"); !errors.Is(err, errs.ErrValidation) {
+		t.Fatalf("expected unknown permission, got %v", err)
+	}
+}
+
+func TestBuiltInRoles_Immutable(t *testing.T) {
+	repo, _ := lockoutRepo(t)
+	svc := user.NewUserService(repo, &fakeJwt{}, testCfg())
+
+	if _, err := svc.UpdateRole(t.Context(), "admin", "", []string{"items.read"}, "admin"); !errors.Is(err, domain.ErrBuiltInRole) {
+		t.Fatalf("expected built-in role error, got %v", err)
+	}
+	if err := svc.DeleteRole(t.Context(), "viewer", "admin"); !errors.Is(err, domain.ErrBuiltInRole) {
+		t.Fatalf("expected built-in role error, got %v", err)
+	}
+	if _, err := svc.UpdateRole(t.Context(), "ghost", "", []string{"items.read"}, "admin"); !errors.Is(err, domain.ErrRoleNotFound) {
+		t.Fatalf("expected not found, got %v", err)
+	}
+
+	roles, err := svc.GetRoles(t.Context())
+	if err != nil || len(roles) != 3 {
+		t.Fatalf("expected built-in roles, got %v (%v)", roles, err)
+	}
+}
+
+func TestDeleteRole_InUse(t *testing.T) {
+	repo, u := lockoutRepo(t)
+	svc := user.NewUserService(repo, &fakeJwt{}, testCfg())
+
+	if _, err := svc.CreateRole(t.Context(), "auditor", "", []string{"history.read"}, "admin"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if _, err := svc.ChangeRole(t.Context(), u.Id.String(), "auditor", "admin-id", "admin"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := svc.DeleteRole(t.Context(), "auditor", "admin"); !errors.Is(err, domain.ErrRoleInUse) {
+		t.Fatalf("expected role in use, got %v", err)
+	}
+	if _, err := svc.ChangeRole(t.Context(), u.Id.String(), "viewer", "admin-id", "admin"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := svc.DeleteRole(t.Context(), "auditor", "admin"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if _, err := svc.GetRole(t.Context(), "auditor"); !errors.Is(err, domain.ErrRoleNotFound) {
+		t.Fatalf("expected role deleted, got %v", err)
+	}
+}
+
+func TestValidateTokens_RoleChangesApplyToIssuedTokens(t *testing.T) {
+	repo, u := lockoutRepo(t)
+	cfg := testCfg()
+	cfg.AuthConfig.RoleCacheTTL = time.Hour
+	svc := user.NewUserService(repo, &fakeJwt{}, cfg)
+	// второй экземпляр сервиса со своим кэшем ролей
+	other := user.NewUserService(repo, &fakeJwt{}, cfg)
+
+	if _, err := svc.CreateRole(t.Context(), "auditor", "", []string{"history.read"}, "admin"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	u.Role = "auditor"
+	tokens, err := svc.Login(t.Context(), "user", "Password1", domain.Client{})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	p, err := other.ValidateTokens(t.Context(), tokens.AccessToken)
+	if err != nil || p.RoleVersion != 1 || !p.Permissions.Has(domain.PermHistoryRead) || p.Permissions.Has(domain.PermItemsRead) {
+		t.Fatalf("unexpected payload %+v (%v)", p, err)
+	}
+
+	if _, err := svc.UpdateRole(t.Context(), "auditor", "", []string{"history.read", "items.read"}, "admin"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	p, err = svc.ValidateTokens(t.Context(), tokens.AccessToken)
+	if err != nil || p.RoleVersion != 2 || !p.Permissions.Has(domain.PermItemsRead) {
+		t.Fatalf("expected updated permissions, got %+v (%v)", p, err)
+	}
+
+	// токен с новой версией роли заставляет другой экземпляр перечитать роли до истечения TTL
+	fresh, err := svc.Login(t.Context(), "user", "Password1", domain.Client{})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	loads := repo.roleLoads
+	p, err = other.ValidateTokens(t.Context(), fresh.AccessToken)
+	if err != nil || p.RoleVersion != 2 || !p.Permissions.Has(domain.PermItemsRead) || repo.roleLoads != loads+1 {
+		t.Fatalf("expected reload on newer role version, got %+v (%v)", p, err)
+	}
+	if _, err := other.ValidateTokens(t.Context(), tokens.AccessToken); err != nil || repo.roleLoads != loads+1 {
+		t.Fatalf("expected cached roles, got %d loads (%v)", repo.roleLoads-loads, err)
+	}
+}
+
+func TestValidateTokens_DeletedRoleHasNoPermissions(t *testing.T) {
+	repo, u := lockoutRepo(t)
+	svc := user.NewUserService(repo, &fakeJwt{}, testCfg())
+
+	if _, err := svc.CreateRole(t.Context(), "auditor", "", []string{"history.read"}, "admin"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	u.Role = "auditor"
+	tokens, err := svc.Login(t.Context(), "user", "Password1", domain.Client{})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	// роль сняли с пользователя и удалили, а выданный с ней токен ещё действует
+	if _, err := svc.ChangeRole(t.Context(), u.Id.String(), "viewer", "admin-id", "admin"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := svc.DeleteRole(t.Context(), "auditor", "admin"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	p, err := svc.ValidateTokens(t.Context(), tokens.AccessToken)
+	if err != nil || len(p.Permissions) != 0 {
+		t.Fatalf("expected no permissions, got %+v (%v)", p, err)
+	}
+	if _, err := svc.RolePermissions(t.Context(), "auditor"); !errors.Is(err, domain.ErrRoleNotFound) {
+		t.Fatalf("expected role not found, got %v", err)
+	}
+}
+
+func TestValidateTokens_RoleCacheDefaultTTL(t *testing.T) {
+	repo, _ := lockoutRepo(t)
+	cfg := testCfg()
+	cfg.AuthConfig.RoleCacheTTL = 0
+	svc := user.NewUserService(repo, &fakeJwt{}, cfg)
+
+	tokens, err := svc.Login(t.Context(), "user", "Password1", domain.Client{})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	loads := repo.roleLoads
+	// без role_cache_ttl роли всё равно кэшируются, а не читаются на каждый запрос
+	for i := 0; i < 3; i++ {
+		if _, err := svc.ValidateTokens(t.Context(), tokens.AccessToken); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+	if repo.roleLoads > loads+1 {
+		t.Fatalf("expected cached roles, got %d loads", repo.roleLoads-loads)
+	}
+}
@@ -0,0 +1,49 @@
+package user
+
+import (
+	"warehousecontrol/internal/domain/errs"
+	"warehousecontrol/internal/domain/user"
+
+	"github.com/google/uuid"
+	wbzlog "github.com/wb-go/wbf/zlog"
+
+	"context"
+)
+
+// GetSessions возвращает активные сессии пользователя
+func (s *UserService) GetSessions(ctx context.Context, userID string) ([]*user.Session, error) {
+	if _, err := uuid.Parse(userID); err != nil {
+		return nil, errs.Errorf(errs.ErrInvalidInput, "invalid UUID format: %v", err)
+	}
+	return s.repo.GetUserSessions(ctx, userID)
+}
+
+// RevokeSession завершает одну сессию пользователя: её refresh и access токены
+// перестают приниматься. Чужая сессия не находится.
+func (s *UserService) RevokeSession(ctx context.Context, userID, sessionID string) error {
+	if _, err := uuid.Parse(userID); err != nil {
+		return errs.Errorf(errs.ErrInvalidInput, "invalid UUID format: %v", err)
+	}
+	if _, err := uuid.Parse(sessionID); err != nil {
+		return errs.Errorf(errs.ErrInvalidInput, "invalid UUID format: %v", err)
+	}
+	err := s.repo.RevokeSession(ctx, userID, sessionID)
+	if err != nil {
+		return err
+	}
+	wbzlog.Logger.Info().Str("user_id", userID).Str("session_id", sessionID).Msg("session revoked")
+	return nil
+}
+
+// RevokeSessions завершает все сессии пользователя
+func (s *UserService) RevokeSessions(ctx context.Context, userID string) error {
+	if _, err := uuid.Parse(userID); err != nil {
+		return errs.Errorf(errs.ErrInvalidInput, "invalid UUID format: %v", err)
+	}
+	err := s.repo.RevokeUserSessions(ctx, userID)
+	if err != nil {
+		return err
+	}
+	wbzlog.Logger.Info().Str("user_id", userID).Msg("all sessions revoked")
+	return nil
+}
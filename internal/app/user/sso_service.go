@@ -0,0 +1,103 @@
+package user
+
+import (
+	"context"
+	"time"
+
+	"warehousecontrol/internal/auth"
+	"warehousecontrol/internal/config"
+	"warehousecontrol/internal/domain/errs"
+	"warehousecontrol/internal/domain/user"
+
+	wbzlog "github.com/wb-go/wbf/zlog"
+)
+
+// defaultSSOStateTTL — сколько ждать возврата пользователя от провайдера, если state_ttl не задан
+const defaultSSOStateTTL = 10 * time.Minute
+
+type SSOProvider interface {
+	Enabled() bool
+	AuthCodeURL(ctx context.Context, state, nonce, verifier string) (string, error)
+	Exchange(ctx context.Context, code, verifier, nonce string) (*auth.ExternalIdentity, error)
+}
+
+// SSOService — вход через OpenID Connect провайдера (authorization code + PKCE). Сессию,
+// как и при входе по паролю, открывает UserService: токены выпускает JWTService, а второй
+// фактор запрашивается по тем же правилам.
+type SSOService struct {
+	users    *UserService
+	provider SSOProvider
+	cfg      *config.AppConfig
+}
+
+func NewSSOService(users *UserService, provider SSOProvider, cfg *config.AppConfig) *SSOService {
+	return &SSOService{
+		users:    users,
+		provider: provider,
+		cfg:      cfg,
+	}
+}
+
+// StartLogin начинает вход: сохраняет state, nonce и code_verifier и возвращает ссылку
+// на страницу входа провайдера, сам state (его нужно привязать к браузеру, начавшему вход)
+// и срок, до которого вход нужно завершить
+func (s *SSOService) StartLogin(ctx context.Context) (string, string, time.Time, error) {
+	if !s.provider.Enabled() {
+		return "", "", time.Time{}, user.ErrSSODisabled
+	}
+	ttl := s.cfg.OIDCConfig.StateTTL
+	if ttl <= 0 {
+		ttl = defaultSSOStateTTL
+	}
+	st, state, err := user.NewSSOState(ttl)
+	if err != nil {
+		return "", "", time.Time{}, err
+	}
+	authURL, err := s.provider.AuthCodeURL(ctx, state, st.Nonce, st.CodeVerifier)
+	if err != nil {
+		wbzlog.Logger.Error().Err(err).Msg("cant build sso authorization url")
+		return "", "", time.Time{}, err
+	}
+	if err := s.users.repo.SaveSSOState(ctx, st); err != nil {
+		return "", "", time.Time{}, err
+	}
+	return authURL, state, st.ExpiresAt, nil
+}
+
+// CompleteLogin завершает вход кодом и state, с которыми провайдер вернул пользователя.
+// При первом входе учётная запись заводится, при следующих её роль приводится к группам
+// провайдера. Учётные записи, совпавшие с локальными только логином, не связываются.
+func (s *SSOService) CompleteLogin(ctx context.Context, code, state string, client user.Client) (*auth.LoginResponse, error) {
+	if !s.provider.Enabled() {
+		return nil, user.ErrSSODisabled
+	}
+	if code == "" || state == "" {
+		return nil, errs.New(errs.ErrValidation, "code and state required")
+	}
+	st, err := s.users.repo.TakeSSOState(ctx, user.HashSSOState(state))
+	if err != nil {
+		return nil, err
+	}
+	ident, err := s.provider.Exchange(ctx, code, st.CodeVerifier, st.Nonce)
+	if err != nil {
+		wbzlog.Logger.Warn().Err(err).Msg("sso code exchange failed")
+		return nil, user.ErrSSOFailed
+	}
+
+	role, ok := groupRole(s.cfg.OIDCConfig.GroupRoles, s.cfg.OIDCConfig.DefaultRole, ident.Groups, func(a, b string) bool { return a == b })
+	if !ok {
+		wbzlog.Logger.Debug().Str("subject", ident.Subject).Strs("groups", ident.Groups).Msg("sso login without role")
+		return nil, user.ErrNoSSORole
+	}
+	u, err := s.users.syncExternalUser(ctx, ident, role)
+	if err != nil {
+		return nil, err
+	}
+	if u.Disabled() {
+		wbzlog.Logger.Debug().Str("login", u.Login).Msg("sso login to disabled account")
+		return nil, user.ErrDisabled
+	}
+
+	// провайдер подтвердил первый фактор так же, как его подтверждает пароль
+	return s.users.passwordVerified(ctx, u, client)
+}
@@ -0,0 +1,208 @@
+package user_test
+
+import (
+	"errors"
+	"testing"
+
+	"warehousecontrol/internal/app/user"
+	"warehousecontrol/internal/auth"
+	"warehousecontrol/internal/auth/oidctest"
+	"warehousecontrol/internal/config"
+	"warehousecontrol/internal/domain/errs"
+	domain "warehousecontrol/internal/domain/user"
+)
+
+func ssoCfg(issuer string) *config.AppConfig {
+	cfg := testCfg()
+	cfg.OIDCConfig = config.OIDCConfig{
+		Enabled:      true,
+		IssuerURL:    issuer,
+		ClientID:     "warehouse",
+		ClientSecret: "s3cret",
+		RedirectURL:  "http://localhost:8080/sso/callback",
+		GroupRoles: []config.GroupRole{
+			{Group: "wh-admins", Role: "admin"},
+			{Group: "wh-managers", Role: "manager"},
+		},
+	}
+	return cfg
+}
+
+// ssoService — сервис входа через тестовый провайдер; repo содержит локального пользователя "user"
+func ssoService(t *testing.T, cfg *config.AppConfig) (*user.SSOService, *oidctest.Provider, *fakeRepo) {
+	t.Helper()
+	idp := oidctest.NewProvider("warehouse", "s3cret")
+	t.Cleanup(idp.Close)
+	if cfg == nil {
+		cfg = ssoCfg(idp.Issuer())
+	}
+	cfg.OIDCConfig.IssuerURL = idp.Issuer()
+	provider, err := auth.NewOIDCProvider(cfg)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	repo, _ := lockoutRepo(t)
+	users := user.NewUserService(repo, &fakeJwt{}, cfg)
+	return user.NewSSOService(users, provider, cfg), idp, repo
+}
+
+// ssoLogin проходит вход целиком: ссылка на провайдера, вход у него, обмен кода
+func ssoLogin(t *testing.T, svc *user.SSOService, idp *oidctest.Provider) (*auth.LoginResponse, error) {
+	t.Helper()
+	authURL, _, _, err := svc.StartLogin(t.Context())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	back, err := idp.Authorize(authURL)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	return svc.CompleteLogin(t.Context(), back.Get("code"), back.Get("state"), domain.Client{IP: "10.0.0.1"})
+}
+
+func TestSSOLogin_ProvisionsUserAndSyncsRole(t *testing.T) {
+	svc, idp, repo := ssoService(t, nil)
+
+	idp.SignIn("sub-1", map[string]any{"preferred_username": "jdoe", "groups": []string{"staff", "wh-managers"}})
+	resp, err := ssoLogin(t, svc, idp)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	u := repo.users["jdoe"]
+	if u == nil || u.Role != domain.Manager || len(u.Password) != 0 {
+		t.Fatalf("expected provisioned manager without password, got %+v", u)
+	}
+	if resp.JWTResponse == nil || len(repo.sessions) != 1 {
+		t.Fatalf("expected local session, got %+v", resp)
+	}
+	if p, err := (&fakeJwt{}).ValidateTokens(resp.AccessToken); err != nil || p.UserID != u.Id.String() || p.Role != domain.Manager {
+		t.Fatalf("unexpected access token payload %+v (%v)", p, err)
+	}
+
+	// при следующем входе роль следует группам провайдера; первое совпадение в group_roles выигрывает
+	idp.SignIn("sub-1", map[string]any{"preferred_username": "jdoe", "groups": []string{"wh-managers", "wh-admins"}})
+	if _, err := ssoLogin(t, svc, idp); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if repo.users["jdoe"].Id != u.Id || u.Role != domain.Admin {
+		t.Fatalf("expected same account promoted to admin, got %+v", repo.users["jdoe"])
+	}
+	audit, _ := repo.GetUserAudit(t.Context(), u.Id.String())
+	if len(audit) != 2 || audit[0].Action != domain.AuditSSOProvisioned || audit[1].Action != domain.AuditRoleChanged || audit[1].ActorLogin != domain.SystemActor {
+		t.Fatalf("unexpected audit %+v", audit)
+	}
+
+	// пароль у такой учётной записи не подходит никакой
+	if _, err := user.NewUserService(repo, &fakeJwt{}, testCfg()).Login(t.Context(), "jdoe", "", domain.Client{}); err == nil {
+		t.Fatal("expected password login to fail")
+	}
+}
+
+func TestSSOLogin_StateIsSingleUse(t *testing.T) {
+	svc, idp, _ := ssoService(t, nil)
+	idp.SignIn("sub-1", map[string]any{"preferred_username": "jdoe", "groups": []string{"wh-managers"}})
+
+	authURL, _, _, err := svc.StartLogin(t.Context())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	back, _ := idp.Authorize(authURL)
+	if _, err := svc.CompleteLogin(t.Context(), back.Get("code"), "forged", domain.Client{}); !errors.Is(err, domain.ErrSSOStateInvalid) {
+		t.Fatalf("expected invalid state, got %v", err)
+	}
+	if _, err := svc.CompleteLogin(t.Context(), back.Get("code"), back.Get("state"), domain.Client{}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if _, err := svc.CompleteLogin(t.Context(), back.Get("code"), back.Get("state"), domain.Client{}); !errors.Is(err, domain.ErrSSOStateInvalid) {
+		t.Fatalf("expected used state to be rejected, got %v", err)
+	}
+}
+
+func TestSSOLogin_RoleMapping(t *testing.T) {
+	svc, idp, _ := ssoService(t, nil)
+	idp.SignIn("sub-1", map[string]any{"preferred_username": "jdoe", "groups": []string{"staff"}})
+	if _, err := ssoLogin(t, svc, idp); !errors.Is(err, domain.ErrNoSSORole) || !errors.Is(err, errs.ErrForbidden) {
+		t.Fatalf("expected no mapped role, got %v", err)
+	}
+
+	cfg := ssoCfg("")
+	cfg.OIDCConfig.DefaultRole = "viewer"
+	svc, idp, repo := ssoService(t, cfg)
+	idp.SignIn("sub-1", map[string]any{"preferred_username": "jdoe"})
+	if _, err := ssoLogin(t, svc, idp); err != nil || repo.users["jdoe"].Role != domain.Viewer {
+		t.Fatalf("expected default role, got %v", err)
+	}
+
+	// сопоставление с несуществующей ролью — ошибка настройки, входа нет
+	cfg = ssoCfg("")
+	cfg.OIDCConfig.GroupRoles = []config.GroupRole{{Group: "staff", Role: "auditor"}}
+	svc, idp, _ = ssoService(t, cfg)
+	idp.SignIn("sub-1", map[string]any{"preferred_username": "jdoe", "groups": "staff"})
+	if _, err := ssoLogin(t, svc, idp); !errors.Is(err, errs.ErrValidation) {
+		t.Fatalf("expected unknown role, got %v", err)
+	}
+}
+
+func TestSSOLogin_DoesNotTakeOverLocalAccount(t *testing.T) {
+	svc, idp, repo := ssoService(t, nil)
+	local := repo.users["user"]
+	idp.SignIn("sub-1", map[string]any{"preferred_username": "user", "groups": []string{"wh-admins"}})
+	if _, err := ssoLogin(t, svc, idp); !errors.Is(err, domain.ErrSSOLoginTaken) {
+		t.Fatalf("expected login taken, got %v", err)
+	}
+	if repo.users["user"] != local || local.Role != domain.Viewer {
+		t.Fatalf("local account must stay untouched, got %+v", repo.users["user"])
+	}
+
+	idp.SignIn("sub-2", map[string]any{"preferred_username": "john.doe", "groups": []string{"wh-admins"}})
+	if _, err := ssoLogin(t, svc, idp); !errors.Is(err, errs.ErrValidation) {
+		t.Fatalf("expected invalid login, got %v", err)
+	}
+}
+
+func TestSSOLogin_SecondFactorAndDisabledAccounts(t *testing.T) {
+	cfg := ssoCfg("")
+	cfg.AuthConfig.RequireAdminTOTP = true
+	svc, idp, repo := ssoService(t, cfg)
+
+	idp.SignIn("sub-1", map[string]any{"preferred_username": "boss", "groups": []string{"wh-admins"}})
+	resp, err := ssoLogin(t, svc, idp)
+	if err != nil || resp.MFA == nil || !resp.MFA.Enrollment || resp.JWTResponse != nil {
+		t.Fatalf("expected second factor enrollment, got %+v (%v)", resp, err)
+	}
+
+	idp.SignIn("sub-2", map[string]any{"preferred_username": "jdoe", "groups": []string{"wh-managers"}})
+	if _, err := ssoLogin(t, svc, idp); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	users := user.NewUserService(repo, &fakeJwt{}, cfg)
+	if _, err := users.SetDisabled(t.Context(), repo.users["jdoe"].Id.String(), true, "admin-id", "admin"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if _, err := ssoLogin(t, svc, idp); !errors.Is(err, domain.ErrDisabled) {
+		t.Fatalf("expected disabled account, got %v", err)
+	}
+}
+
+func TestSSOLogin_ProviderErrors(t *testing.T) {
+	cfg := ssoCfg("")
+	cfg.OIDCConfig.ClientSecret = "wrong"
+	svc, idp, repo := ssoService(t, cfg)
+	idp.SignIn("sub-1", map[string]any{"preferred_username": "jdoe", "groups": []string{"wh-managers"}})
+	if _, err := ssoLogin(t, svc, idp); !errors.Is(err, domain.ErrSSOFailed) || !errors.Is(err, errs.ErrUnauthorized) {
+		t.Fatalf("expected sso failure, got %v", err)
+	}
+	if _, ok := repo.users["jdoe"]; ok {
+		t.Fatal("user must not be provisioned")
+	}
+
+	cfg = ssoCfg("")
+	cfg.OIDCConfig.Enabled = false
+	disabled := user.NewSSOService(user.NewUserService(repo, &fakeJwt{}, cfg), &auth.OIDCProvider{}, cfg)
+	if _, _, _, err := disabled.StartLogin(t.Context()); !errors.Is(err, domain.ErrSSODisabled) {
+		t.Fatalf("expected sso disabled, got %v", err)
+	}
+	if _, err := disabled.CompleteLogin(t.Context(), "code", "state", domain.Client{}); !errors.Is(err, domain.ErrSSODisabled) {
+		t.Fatalf("expected sso disabled, got %v", err)
+	}
+}
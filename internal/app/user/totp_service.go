@@ -0,0 +1,339 @@
+package user
+
+import (
+	"warehousecontrol/internal/auth"
+	"warehousecontrol/internal/domain/errs"
+	"warehousecontrol/internal/domain/user"
+
+	"context"
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+	wbzlog "github.com/wb-go/wbf/zlog"
+)
+
+// passwordVerified завершает вход после проверки пароля: открывает сессию или, если нужен
+// второй фактор, выдаёт токен второго шага
+func (s *UserService) passwordVerified(ctx context.Context, u *user.User, client user.Client) (*auth.LoginResponse, error) {
+	t, err := s.findTOTP(ctx, u.Id.String())
+	if err != nil {
+		return nil, err
+	}
+	enabled := t != nil && t.Enabled()
+	if !enabled && !s.totpRequired(u) {
+		tokens, err := s.openSession(ctx, u, client)
+		if err != nil {
+			return nil, err
+		}
+		return &auth.LoginResponse{JWTResponse: tokens}, nil
+	}
+
+	token, expiresAt, err := s.jwt.GenerateMFAToken(u)
+	if err != nil {
+		return nil, err
+	}
+	return &auth.LoginResponse{MFA: &auth.MFAChallenge{
+		Token:      token,
+		ExpiresAt:  expiresAt,
+		Enrollment: !enabled,
+	}}, nil
+}
+
+// LoginMFA — второй шаг входа: токен второго шага и код TOTP или код восстановления
+// обмениваются на сессию. Если второй фактор обязателен и настраивается при входе, код
+// подтверждает его, а вызывающий получает ещё и коды восстановления. Неверные коды
+// учитываются в блокировке входа так же, как неверный пароль.
+func (s *UserService) LoginMFA(ctx context.Context, mfaToken, code string, client user.Client) (*auth.JWTResponse, []string, error) {
+	u, err := s.mfaUser(ctx, mfaToken)
+	if err != nil {
+		return nil, nil, err
+	}
+	if code == "" {
+		return nil, nil, errs.New(errs.ErrValidation, "two-factor code required")
+	}
+	if err := s.checkLockout(ctx, u.Login, client.IP); err != nil {
+		return nil, nil, err
+	}
+	t, err := s.findTOTP(ctx, u.Id.String())
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var recoveryCodes []string
+	switch {
+	case t != nil && t.Enabled():
+		err = s.verifySecondFactor(ctx, t, code)
+	case s.totpRequired(u):
+		if t == nil {
+			return nil, nil, user.ErrTOTPNotSetUp
+		}
+		recoveryCodes, err = s.confirmTOTP(ctx, u, t, code)
+	default:
+		// второй фактор отключили после выдачи токена
+		return nil, nil, user.ErrMFATokenInvalid
+	}
+	if errors.Is(err, user.ErrTOTPInvalidCode) {
+		if err := s.registerFailure(ctx, u.Login, client.IP); err != nil {
+			return nil, nil, err
+		}
+		return nil, nil, user.ErrTOTPInvalidCode
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+
+	tokens, err := s.openSession(ctx, u, client)
+	if err != nil {
+		return nil, nil, err
+	}
+	if err := s.repo.ResetLoginFailures(ctx, u.Login); err != nil {
+		wbzlog.Logger.Warn().Err(err).Msg("cant reset login failures")
+	}
+	return tokens, recoveryCodes, nil
+}
+
+// LoginMFASetup начинает обязательную настройку второго фактора по токену второго шага,
+// когда пользователь ещё не может войти и получить access токен
+func (s *UserService) LoginMFASetup(ctx context.Context, mfaToken string) (*user.TOTPSetup, error) {
+	u, err := s.mfaUser(ctx, mfaToken)
+	if err != nil {
+		return nil, err
+	}
+	if !s.totpRequired(u) {
+		return nil, user.ErrMFATokenInvalid
+	}
+	return s.setupTOTP(ctx, u)
+}
+
+// TOTPStatus показывает, подключён ли второй фактор и сколько осталось кодов восстановления
+func (s *UserService) TOTPStatus(ctx context.Context, userID string) (*user.TOTPStatus, error) {
+	u, err := s.userByID(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	t, err := s.findTOTP(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	status := &user.TOTPStatus{Required: s.totpRequired(u)}
+	if t != nil && t.Enabled() {
+		status.Enabled = true
+		status.RecoveryCodesLeft = t.RecoveryCodesLeft
+	}
+	return status, nil
+}
+
+// SetupTOTP создаёт новый секрет для приложения-аутентификатора. Второй фактор начинает
+// действовать только после подтверждения кодом в EnableTOTP.
+func (s *UserService) SetupTOTP(ctx context.Context, userID string) (*user.TOTPSetup, error) {
+	u, err := s.userByID(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	return s.setupTOTP(ctx, u)
+}
+
+// EnableTOTP подтверждает настроенный секрет первым кодом и возвращает коды восстановления
+func (s *UserService) EnableTOTP(ctx context.Context, userID, code string) ([]string, error) {
+	u, err := s.userByID(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	if code == "" {
+		return nil, errs.New(errs.ErrValidation, "two-factor code required")
+	}
+	t, err := s.findTOTP(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	if t == nil {
+		return nil, user.ErrTOTPNotSetUp
+	}
+	if t.Enabled() {
+		return nil, user.ErrTOTPAlreadyEnabled
+	}
+	return s.confirmTOTP(ctx, u, t, code)
+}
+
+// DisableTOTP отключает второй фактор по действующему коду. Если второй фактор
+// обязателен для роли пользователя, отключить его нельзя.
+func (s *UserService) DisableTOTP(ctx context.Context, userID, code string, client user.Client) error {
+	u, err := s.userByID(ctx, userID)
+	if err != nil {
+		return err
+	}
+	if s.totpRequired(u) {
+		return user.ErrTOTPRequired
+	}
+	if err := s.checkSecondFactor(ctx, u, code, client); err != nil {
+		return err
+	}
+	err = s.repo.DisableTOTP(ctx, userID, userID, u.Login)
+	if err != nil {
+		return err
+	}
+	wbzlog.Logger.Info().Str("user_id", userID).Msg("two-factor authentication disabled")
+	return nil
+}
+
+// RegenerateRecoveryCodes выдаёт новый набор кодов восстановления по действующему коду
+func (s *UserService) RegenerateRecoveryCodes(ctx context.Context, userID, code string, client user.Client) ([]string, error) {
+	u, err := s.userByID(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.checkSecondFactor(ctx, u, code, client); err != nil {
+		return nil, err
+	}
+	codes, hashes, err := user.NewRecoveryCodes()
+	if err != nil {
+		return nil, err
+	}
+	err = s.repo.ReplaceRecoveryCodes(ctx, userID, hashes, u.Login)
+	if err != nil {
+		return nil, err
+	}
+	return codes, nil
+}
+
+// ResetTOTP — сброс второго фактора администратором, например после потери устройства.
+// Если второй фактор обязателен, пользователь настроит его заново при следующем входе.
+func (s *UserService) ResetTOTP(ctx context.Context, id, actorID, actorLogin string) error {
+	if _, err := uuid.Parse(id); err != nil {
+		return errs.Errorf(errs.ErrInvalidInput, "invalid UUID format: %v", err)
+	}
+	if id == actorID {
+		return user.ErrSelfChange
+	}
+	err := s.repo.DisableTOTP(ctx, id, actorID, actorLogin)
+	if err != nil {
+		return err
+	}
+	wbzlog.Logger.Info().Str("user_id", id).Str("actor", actorLogin).Msg("two-factor authentication reset")
+	return nil
+}
+
+//// Вспомогательные приватные методы
+
+// totpRequired — второй фактор обязателен для администраторов при auth_config.require_admin_totp
+func (s *UserService) totpRequired(u *user.User) bool {
+	return s.cfg.AuthConfig.RequireAdminTOTP && u.Role == user.Admin
+}
+
+// findTOTP возвращает nil, если второй фактор не настраивался
+func (s *UserService) findTOTP(ctx context.Context, userID string) (*user.TOTP, error) {
+	t, err := s.repo.GetTOTP(ctx, userID)
+	if errors.Is(err, user.ErrTOTPNotEnabled) {
+		return nil, nil
+	}
+	return t, err
+}
+
+func (s *UserService) userByID(ctx context.Context, userID string) (*user.User, error) {
+	if _, err := uuid.Parse(userID); err != nil {
+		return nil, errs.Errorf(errs.ErrInvalidInput, "invalid UUID format: %v", err)
+	}
+	u, err := s.repo.GetUserByID(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	if u.Disabled() {
+		return nil, user.ErrDisabled
+	}
+	return u, nil
+}
+
+// mfaUser проверяет токен второго шага и возвращает его пользователя
+func (s *UserService) mfaUser(ctx context.Context, mfaToken string) (*user.User, error) {
+	if mfaToken == "" {
+		return nil, errs.New(errs.ErrInvalidInput, "two-factor login token required")
+	}
+	userID, err := s.jwt.ParseMFAToken(mfaToken)
+	if err != nil {
+		wbzlog.Logger.Debug().Err(err).Msg("invalid mfa token")
+		return nil, user.ErrMFATokenInvalid
+	}
+	if _, err := uuid.Parse(userID); err != nil {
+		return nil, user.ErrMFATokenInvalid
+	}
+	u, err := s.repo.GetUserByID(ctx, userID)
+	if errors.Is(err, user.ErrNotFound) {
+		return nil, user.ErrMFATokenInvalid
+	}
+	if err != nil {
+		return nil, err
+	}
+	if u.Disabled() {
+		return nil, user.ErrDisabled
+	}
+	return u, nil
+}
+
+func (s *UserService) setupTOTP(ctx context.Context, u *user.User) (*user.TOTPSetup, error) {
+	secret, err := auth.GenerateTOTPSecret()
+	if err != nil {
+		return nil, err
+	}
+	err = s.repo.SaveTOTPSecret(ctx, &user.TOTP{UserID: u.Id, Secret: secret, CreatedAt: time.Now()})
+	if err != nil {
+		return nil, err
+	}
+	issuer := s.cfg.AuthConfig.TOTPIssuer
+	return &user.TOTPSetup{Secret: secret, URI: auth.TOTPURI(issuer, u.Login, secret)}, nil
+}
+
+// confirmTOTP подключает настроенный секрет, если код к нему подходит
+func (s *UserService) confirmTOTP(ctx context.Context, u *user.User, t *user.TOTP, code string) ([]string, error) {
+	step, ok := auth.ValidateTOTP(t.Secret, code, time.Now(), s.cfg.AuthConfig.TOTPWindow)
+	if !ok {
+		return nil, user.ErrTOTPInvalidCode
+	}
+	codes, hashes, err := user.NewRecoveryCodes()
+	if err != nil {
+		return nil, err
+	}
+	err = s.repo.EnableTOTP(ctx, u.Id.String(), step, hashes, u.Login)
+	if err != nil {
+		return nil, err
+	}
+	wbzlog.Logger.Info().Str("user_id", u.Id.String()).Msg("two-factor authentication enabled")
+	return codes, nil
+}
+
+// verifySecondFactor принимает код TOTP, ещё не использованный шаг, или код восстановления
+func (s *UserService) verifySecondFactor(ctx context.Context, t *user.TOTP, code string) error {
+	if step, ok := auth.ValidateTOTP(t.Secret, code, time.Now(), s.cfg.AuthConfig.TOTPWindow); ok {
+		if step <= t.LastStep {
+			return user.ErrTOTPInvalidCode
+		}
+		return s.repo.UseTOTPStep(ctx, t.UserID.String(), step)
+	}
+	return s.repo.UseRecoveryCode(ctx, t.UserID.String(), user.HashRecoveryCode(code))
+}
+
+// checkSecondFactor подтверждает чувствительное действие с подключённым вторым фактором;
+// неверный код учитывается в блокировке входа
+func (s *UserService) checkSecondFactor(ctx context.Context, u *user.User, code string, client user.Client) error {
+	if code == "" {
+		return errs.New(errs.ErrValidation, "two-factor code required")
+	}
+	t, err := s.findTOTP(ctx, u.Id.String())
+	if err != nil {
+		return err
+	}
+	if t == nil || !t.Enabled() {
+		return user.ErrTOTPNotEnabled
+	}
+	if err := s.checkLockout(ctx, u.Login, client.IP); err != nil {
+		return err
+	}
+	err = s.verifySecondFactor(ctx, t, code)
+	if errors.Is(err, user.ErrTOTPInvalidCode) {
+		if err := s.registerFailure(ctx, u.Login, client.IP); err != nil {
+			return err
+		}
+		return user.ErrTOTPInvalidCode
+	}
+	return err
+}
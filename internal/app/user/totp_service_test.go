@@ -0,0 +1,246 @@
+package user_test
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+
+	"warehousecontrol/internal/app/user"
+	"warehousecontrol/internal/auth"
+	domain "warehousecontrol/internal/domain/user"
+)
+
+// totpCode — код для шага, смещённого на offset от текущего
+func totpCode(t *testing.T, secret string, offset int64) string {
+	t.Helper()
+	code, err := auth.TOTPCode(secret, auth.TOTPStep(time.Now())+offset)
+	if err != nil {
+		t.Fatalf("cant compute totp code: %v", err)
+	}
+	return code
+}
+
+// enrollTOTP подключает второй фактор и возвращает секрет и коды восстановления
+func enrollTOTP(t *testing.T, svc *user.UserService, userID string) (string, []string) {
+	t.Helper()
+	setup, err := svc.SetupTOTP(t.Context(), userID)
+	if err != nil {
+		t.Fatalf("setup: %v", err)
+	}
+	codes, err := svc.EnableTOTP(t.Context(), userID, totpCode(t, setup.Secret, 0))
+	if err != nil {
+		t.Fatalf("enable: %v", err)
+	}
+	return setup.Secret, codes
+}
+
+func TestTOTP_EnrollAndLogin(t *testing.T) {
+	repo, u := lockoutRepo(t)
+	svc := user.NewUserService(repo, &fakeJwt{}, testCfg())
+	id := u.Id.String()
+
+	setup, err := svc.SetupTOTP(t.Context(), id)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if setup.Secret == "" || setup.URI == "" {
+		t.Fatalf("unexpected setup: %+v", setup)
+	}
+	// до подтверждения вход однофакторный
+	if resp, err := svc.Login(t.Context(), "user", "Password1", domain.Client{}); err != nil || resp.MFA != nil {
+		t.Fatalf("expected plain login before confirmation, got %+v, %v", resp, err)
+	}
+	if _, err := svc.EnableTOTP(t.Context(), id, "000000"); !errors.Is(err, domain.ErrTOTPInvalidCode) {
+		t.Fatalf("expected invalid code, got %v", err)
+	}
+	codes, err := svc.EnableTOTP(t.Context(), id, totpCode(t, setup.Secret, 0))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(codes) != domain.RecoveryCodeCount {
+		t.Fatalf("expected %d recovery codes, got %d", domain.RecoveryCodeCount, len(codes))
+	}
+	if _, err := svc.SetupTOTP(t.Context(), id); !errors.Is(err, domain.ErrTOTPAlreadyEnabled) {
+		t.Fatalf("expected already enabled, got %v", err)
+	}
+
+	resp, err := svc.Login(t.Context(), "user", "Password1", domain.Client{})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if resp.MFA == nil || resp.JWTResponse != nil || resp.MFA.Enrollment {
+		t.Fatalf("expected second step challenge, got %+v", resp)
+	}
+
+	// код уже принятого шага повторно не действует
+	if _, _, err := svc.LoginMFA(t.Context(), resp.MFA.Token, totpCode(t, setup.Secret, 0), domain.Client{}); !errors.Is(err, domain.ErrTOTPInvalidCode) {
+		t.Fatalf("expected replayed code to fail, got %v", err)
+	}
+	tokens, recovery, err := svc.LoginMFA(t.Context(), resp.MFA.Token, totpCode(t, setup.Secret, 1), domain.Client{})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if recovery != nil {
+		t.Fatalf("expected no recovery codes on regular login, got %v", recovery)
+	}
+	if _, err := svc.ValidateTokens(t.Context(), tokens.AccessToken); err != nil {
+		t.Fatalf("expected valid session, got %v", err)
+	}
+
+	// код восстановления принимается в любом написании и только один раз
+	if _, _, err := svc.LoginMFA(t.Context(), resp.MFA.Token, " "+codes[0]+" ", domain.Client{}); err != nil {
+		t.Fatalf("expected recovery code to work, got %v", err)
+	}
+	if _, _, err := svc.LoginMFA(t.Context(), resp.MFA.Token, codes[0], domain.Client{}); !errors.Is(err, domain.ErrTOTPInvalidCode) {
+		t.Fatalf("expected used recovery code to fail, got %v", err)
+	}
+	status, _ := svc.TOTPStatus(t.Context(), id)
+	if !status.Enabled || status.Required || status.RecoveryCodesLeft != domain.RecoveryCodeCount-1 {
+		t.Fatalf("unexpected status: %+v", status)
+	}
+}
+
+func TestLoginMFA_Errors(t *testing.T) {
+	repo, u := lockoutRepo(t)
+	svc := user.NewUserService(repo, &fakeJwt{}, testCfg())
+	enrollTOTP(t, svc, u.Id.String())
+
+	if _, _, err := svc.LoginMFA(t.Context(), refreshToken(u.Id.String(), uuid.NewString(), uuid.NewString()), "123456", domain.Client{}); !errors.Is(err, domain.ErrMFATokenInvalid) {
+		t.Fatalf("expected access-like token to be rejected, got %v", err)
+	}
+	if _, _, err := svc.LoginMFA(t.Context(), "mfa:"+uuid.NewString(), "123456", domain.Client{}); !errors.Is(err, domain.ErrMFATokenInvalid) {
+		t.Fatalf("expected unknown user to be rejected, got %v", err)
+	}
+
+	// неверные коды считаются неудачными попытками входа
+	resp, _ := svc.Login(t.Context(), "user", "Password1", domain.Client{})
+	for i := 0; i < 3; i++ {
+		if _, _, err := svc.LoginMFA(t.Context(), resp.MFA.Token, "000000", domain.Client{}); !errors.Is(err, domain.ErrTOTPInvalidCode) {
+			t.Fatalf("attempt %d: expected invalid code, got %v", i, err)
+		}
+	}
+	if _, _, err := svc.LoginMFA(t.Context(), resp.MFA.Token, "000000", domain.Client{}); !errors.Is(err, domain.ErrTooManyAttempts) {
+		t.Fatalf("expected lockout, got %v", err)
+	}
+}
+
+func TestLoginMFA_PasswordDoesNotResetCodeFailures(t *testing.T) {
+	repo, u := lockoutRepo(t)
+	svc := user.NewUserService(repo, &fakeJwt{}, testCfg())
+	enrollTOTP(t, svc, u.Id.String())
+
+	// повторный ввод пароля с новых адресов не обнуляет счёт неверных кодов
+	for i := 0; i < 3; i++ {
+		client := domain.Client{IP: fmt.Sprintf("10.0.0.%d", i)}
+		resp, err := svc.Login(t.Context(), "user", "Password1", client)
+		if err != nil {
+			t.Fatalf("attempt %d: unexpected error: %v", i, err)
+		}
+		if _, _, err := svc.LoginMFA(t.Context(), resp.MFA.Token, "000000", client); !errors.Is(err, domain.ErrTOTPInvalidCode) {
+			t.Fatalf("attempt %d: expected invalid code, got %v", i, err)
+		}
+	}
+	if _, err := svc.Login(t.Context(), "user", "Password1", domain.Client{IP: "10.0.0.9"}); !errors.Is(err, domain.ErrTooManyAttempts) {
+		t.Fatalf("expected login to be locked, got %v", err)
+	}
+}
+
+func TestTOTP_RequiredForAdmin(t *testing.T) {
+	repo, u := lockoutRepo(t)
+	u.Role = domain.Admin
+	cfg := testCfg()
+	cfg.AuthConfig.RequireAdminTOTP = true
+	svc := user.NewUserService(repo, &fakeJwt{}, cfg)
+
+	resp, err := svc.Login(t.Context(), "user", "Password1", domain.Client{})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if resp.MFA == nil || !resp.MFA.Enrollment {
+		t.Fatalf("expected enrollment challenge, got %+v", resp)
+	}
+	if _, _, err := svc.LoginMFA(t.Context(), resp.MFA.Token, "123456", domain.Client{}); !errors.Is(err, domain.ErrTOTPNotSetUp) {
+		t.Fatalf("expected setup to be required first, got %v", err)
+	}
+	setup, err := svc.LoginMFASetup(t.Context(), resp.MFA.Token)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	tokens, recovery, err := svc.LoginMFA(t.Context(), resp.MFA.Token, totpCode(t, setup.Secret, 0), domain.Client{})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if tokens.AccessToken == "" || len(recovery) != domain.RecoveryCodeCount {
+		t.Fatalf("expected tokens and recovery codes, got %+v, %v", tokens, recovery)
+	}
+
+	// обязательный второй фактор нельзя отключить самому
+	if err := svc.DisableTOTP(t.Context(), u.Id.String(), totpCode(t, setup.Secret, 1), domain.Client{}); !errors.Is(err, domain.ErrTOTPRequired) {
+		t.Fatalf("expected mandatory 2fa error, got %v", err)
+	}
+	status, _ := svc.TOTPStatus(t.Context(), u.Id.String())
+	if !status.Enabled || !status.Required {
+		t.Fatalf("unexpected status: %+v", status)
+	}
+}
+
+func TestTOTP_DisableAndReset(t *testing.T) {
+	repo, u := lockoutRepo(t)
+	svc := user.NewUserService(repo, &fakeJwt{}, testCfg())
+	id := u.Id.String()
+
+	secret, _ := enrollTOTP(t, svc, id)
+	if err := svc.DisableTOTP(t.Context(), id, "000000", domain.Client{}); !errors.Is(err, domain.ErrTOTPInvalidCode) {
+		t.Fatalf("expected invalid code, got %v", err)
+	}
+	if err := svc.DisableTOTP(t.Context(), id, totpCode(t, secret, 1), domain.Client{}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if resp, err := svc.Login(t.Context(), "user", "Password1", domain.Client{}); err != nil || resp.MFA != nil {
+		t.Fatalf("expected plain login after disable, got %+v, %v", resp, err)
+	}
+
+	_, codes := enrollTOTP(t, svc, id)
+	renewed, err := svc.RegenerateRecoveryCodes(t.Context(), id, codes[0], domain.Client{})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if _, err := svc.RegenerateRecoveryCodes(t.Context(), id, codes[1], domain.Client{}); !errors.Is(err, domain.ErrTOTPInvalidCode) {
+		t.Fatalf("expected previous recovery codes to stop working, got %v", err)
+	}
+
+	admin := uuid.NewString()
+	if err := svc.ResetTOTP(t.Context(), id, id, "user"); !errors.Is(err, domain.ErrSelfChange) {
+		t.Fatalf("expected self change error, got %v", err)
+	}
+	if err := svc.ResetTOTP(t.Context(), id, admin, "admin"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := svc.ResetTOTP(t.Context(), id, admin, "admin"); !errors.Is(err, domain.ErrTOTPNotEnabled) {
+		t.Fatalf("expected not enabled, got %v", err)
+	}
+	if _, err := svc.RegenerateRecoveryCodes(t.Context(), id, renewed[0], domain.Client{}); !errors.Is(err, domain.ErrTOTPNotEnabled) {
+		t.Fatalf("expected not enabled, got %v", err)
+	}
+
+	var actions []string
+	audit, _ := svc.GetUserAudit(t.Context(), id)
+	for _, e := range audit {
+		actions = append(actions, e.Action)
+	}
+	want := []string{
+		domain.AuditTOTPEnabled, domain.AuditTOTPDisabled, domain.AuditTOTPEnabled,
+		domain.AuditRecoveryRenewed, domain.AuditTOTPDisabled,
+	}
+	if len(actions) != len(want) {
+		t.Fatalf("unexpected audit: %v", actions)
+	}
+	for i := range want {
+		if actions[i] != want[i] {
+			t.Fatalf("unexpected audit: %v", actions)
+		}
+	}
+}
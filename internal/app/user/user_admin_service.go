@@ -0,0 +1,132 @@
+package user
+
+import (
+	"context"
+	"errors"
+
+	"warehousecontrol/internal/domain/errs"
+	"warehousecontrol/internal/domain/user"
+
+	"github.com/google/uuid"
+	wbzlog "github.com/wb-go/wbf/zlog"
+	"golang.org/x/crypto/bcrypt"
+)
+
+const (
+	defaultUsersPageSize = 50
+	maxUsersPageSize     = 200
+)
+
+// GetUsers возвращает страницу пользователей с фильтрами по логину, роли и статусу
+func (s *UserService) GetUsers(ctx context.Context, opts user.ListOptions) (*user.Page, error) {
+	if opts.Limit <= 0 {
+		opts.Limit = defaultUsersPageSize
+	}
+	if opts.Limit > maxUsersPageSize {
+		opts.Limit = maxUsersPageSize
+	}
+	if opts.Offset < 0 {
+		return nil, errs.New(errs.ErrInvalidInput, "offset must be >= 0")
+	}
+	if opts.Role != "" {
+		err := s.checkRole(ctx, opts.Role)
+		if errors.Is(err, errs.ErrValidation) {
+			return nil, errs.Errorf(errs.ErrInvalidInput, "invalid role filter: %s", opts.Role)
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+	return s.repo.GetUsers(ctx, opts)
+}
+
+func (s *UserService) GetUserByID(ctx context.Context, id string) (*user.User, error) {
+	if _, err := uuid.Parse(id); err != nil {
+		return nil, errs.Errorf(errs.ErrInvalidInput, "invalid UUID format: %v", err)
+	}
+	return s.repo.GetUserByID(ctx, id)
+}
+
+// ChangeRole назначает пользователю роль и завершает его сессии: токены со старой ролью
+// перестают приниматься, новая действует после повторного входа
+func (s *UserService) ChangeRole(ctx context.Context, id, role, actorID, actorLogin string) (*user.User, error) {
+	if err := checkTarget(id, actorID); err != nil {
+		return nil, err
+	}
+	r := user.Role(role)
+	if err := s.checkRole(ctx, r); err != nil {
+		return nil, err
+	}
+	u, err := s.repo.ChangeUserRole(ctx, id, r, actorID, actorLogin)
+	if err != nil {
+		return nil, err
+	}
+	wbzlog.Logger.Info().Str("user_id", id).Str("role", role).Str("actor", actorLogin).Msg("user role changed")
+	return u, nil
+}
+
+// SetDisabled отключает или включает учётную запись. Отключение завершает все сессии
+// пользователя, так что выданные ему токены сразу перестают приниматься.
+func (s *UserService) SetDisabled(ctx context.Context, id string, disabled bool, actorID, actorLogin string) (*user.User, error) {
+	if err := checkTarget(id, actorID); err != nil {
+		return nil, err
+	}
+	u, err := s.repo.SetUserDisabled(ctx, id, disabled, actorID, actorLogin)
+	if err != nil {
+		return nil, err
+	}
+	wbzlog.Logger.Info().Str("user_id", id).Bool("disabled", disabled).Str("actor", actorLogin).Msg("user status changed")
+	return u, nil
+}
+
+// ResetPassword задаёт пользователю новый пароль по правилам парольной политики
+// и завершает все его сессии
+func (s *UserService) ResetPassword(ctx context.Context, id, password, actorID, actorLogin string) error {
+	if _, err := uuid.Parse(id); err != nil {
+		return errs.Errorf(errs.ErrInvalidInput, "invalid UUID format: %v", err)
+	}
+	if err := s.isValidPassword(password); err != nil {
+		return err
+	}
+	hashed, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return err
+	}
+	err = s.repo.ResetUserPassword(ctx, id, hashed, actorID, actorLogin)
+	if err != nil {
+		return err
+	}
+	wbzlog.Logger.Info().Str("user_id", id).Str("actor", actorLogin).Msg("user password reset")
+	return nil
+}
+
+func (s *UserService) DeleteUser(ctx context.Context, id, actorID, actorLogin string) error {
+	if err := checkTarget(id, actorID); err != nil {
+		return err
+	}
+	err := s.repo.DeleteUser(ctx, id, actorID, actorLogin)
+	if err != nil {
+		return err
+	}
+	wbzlog.Logger.Info().Str("user_id", id).Str("actor", actorLogin).Msg("user deleted")
+	return nil
+}
+
+func (s *UserService) GetUserAudit(ctx context.Context, id string) ([]*user.AuditEntry, error) {
+	if _, err := uuid.Parse(id); err != nil {
+		return nil, errs.Errorf(errs.ErrInvalidInput, "invalid UUID format: %v", err)
+	}
+	return s.repo.GetUserAudit(ctx, id)
+}
+
+// checkTarget не даёт администратору изменить роль, статус или удалить самого себя
+func checkTarget(id, actorID string) error {
+	target, err := uuid.Parse(id)
+	if err != nil {
+		return errs.Errorf(errs.ErrInvalidInput, "invalid UUID format: %v", err)
+	}
+	if actor, err := uuid.Parse(actorID); err == nil && actor == target {
+		return user.ErrSelfChange
+	}
+	return nil
+}
@@ -0,0 +1,152 @@
+package user_test
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/google/uuid"
+	"golang.org/x/crypto/bcrypt"
+
+	"warehousecontrol/internal/app/user"
+	"warehousecontrol/internal/domain/errs"
+	domain "warehousecontrol/internal/domain/user"
+)
+
+func adminFixture() (*fakeRepo, *domain.User, *domain.User) {
+	admin := &domain.User{Id: uuid.New(), Login: "admin", Role: domain.Admin}
+	viewer := &domain.User{Id: uuid.New(), Login: "viewer", Role: domain.Viewer}
+	repo := &fakeRepo{users: map[string]*domain.User{"admin": admin, "viewer": viewer}}
+	return repo, admin, viewer
+}
+
+func TestChangeRole(t *testing.T) {
+	repo, admin, viewer := adminFixture()
+	svc := user.NewUserService(repo, &fakeJwt{}, testCfg())
+
+	u, err := svc.ChangeRole(t.Context(), viewer.Id.String(), "manager", admin.Id.String(), admin.Login)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if u.Role != domain.Manager {
+		t.Fatalf("expected manager, got %s", u.Role)
+	}
+	audit, _ := svc.GetUserAudit(t.Context(), viewer.Id.String())
+	if len(audit) != 1 || audit[0].Action != domain.AuditRoleChanged || audit[0].ActorLogin != "admin" {
+		t.Fatalf("expected role change in audit, got %+v", audit)
+	}
+
+	if _, err := svc.ChangeRole(t.Context(), viewer.Id.String(), "root", admin.Id.String(), admin.Login); !errors.Is(err, errs.ErrValidation) {
+		t.Fatalf("expected invalid role to be rejected, got %v", err)
+	}
+	if _, err := svc.ChangeRole(t.Context(), "bad", "viewer", admin.Id.String(), admin.Login); !errors.Is(err, errs.ErrInvalidInput) {
+		t.Fatalf("expected invalid id to be rejected, got %v", err)
+	}
+	if _, err := svc.ChangeRole(t.Context(), admin.Id.String(), "viewer", admin.Id.String(), admin.Login); !errors.Is(err, domain.ErrSelfChange) {
+		t.Fatalf("expected self demotion to be rejected, got %v", err)
+	}
+	if _, err := svc.ChangeRole(t.Context(), admin.Id.String(), "viewer", uuid.NewString(), "other"); !errors.Is(err, domain.ErrLastAdmin) {
+		t.Fatalf("expected last admin demotion to be rejected, got %v", err)
+	}
+}
+
+func TestSetDisabled_BlocksLoginAndRefresh(t *testing.T) {
+	repo, admin, viewer := adminFixture()
+	hashed, _ := bcrypt.GenerateFromPassword([]byte("Password1"), bcrypt.DefaultCost)
+	viewer.Password = hashed
+	svc := user.NewUserService(repo, &fakeJwt{}, testCfg())
+	before, _ := svc.Login(t.Context(), "viewer", "Password1", domain.Client{})
+
+	u, err := svc.SetDisabled(t.Context(), viewer.Id.String(), true, admin.Id.String(), admin.Login)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !u.Disabled() {
+		t.Fatal("expected user to be disabled")
+	}
+	// отключение сразу завершает открытые сессии
+	if _, err := svc.ValidateTokens(t.Context(), before.AccessToken); !errors.Is(err, domain.ErrSessionRevoked) {
+		t.Fatalf("expected access token to be rejected after disable, got %v", err)
+	}
+	if _, err := svc.Login(t.Context(), "viewer", "Password1", domain.Client{}); !errors.Is(err, domain.ErrDisabled) {
+		t.Fatalf("expected login to be rejected, got %v", err)
+	}
+	if _, err := svc.Login(t.Context(), "viewer", "wrong", domain.Client{}); !errors.Is(err, domain.ErrInvalidCredentials) {
+		t.Fatalf("expected wrong password to be reported first, got %v", err)
+	}
+	if _, err := svc.RefreshTokens(t.Context(), anyRefreshToken(viewer.Id.String())); !errors.Is(err, domain.ErrDisabled) {
+		t.Fatalf("expected refresh to be rejected, got %v", err)
+	}
+
+	if _, err := svc.SetDisabled(t.Context(), viewer.Id.String(), false, admin.Id.String(), admin.Login); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if _, err := svc.Login(t.Context(), "viewer", "Password1", domain.Client{}); err != nil {
+		t.Fatalf("expected login after enable, got %v", err)
+	}
+
+	audit, _ := svc.GetUserAudit(t.Context(), viewer.Id.String())
+	if len(audit) != 2 || audit[0].Action != domain.AuditDisabled || audit[1].Action != domain.AuditEnabled {
+		t.Fatalf("unexpected audit: %+v", audit)
+	}
+
+	if _, err := svc.SetDisabled(t.Context(), admin.Id.String(), true, admin.Id.String(), admin.Login); !errors.Is(err, domain.ErrSelfChange) {
+		t.Fatalf("expected self disable to be rejected, got %v", err)
+	}
+}
+
+func TestResetPassword(t *testing.T) {
+	repo, admin, viewer := adminFixture()
+	svc := user.NewUserService(repo, &fakeJwt{}, testCfg())
+
+	if err := svc.ResetPassword(t.Context(), viewer.Id.String(), "weak", admin.Id.String(), admin.Login); !errors.Is(err, errs.ErrValidation) {
+		t.Fatalf("expected password policy to apply, got %v", err)
+	}
+	if err := svc.ResetPassword(t.Context(), viewer.Id.String(), "Password2", admin.Id.String(), admin.Login); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if bcrypt.CompareHashAndPassword(viewer.Password, []byte("Password2")) != nil {
+		t.Fatal("expected new password hash to be stored")
+	}
+	audit, _ := svc.GetUserAudit(t.Context(), viewer.Id.String())
+	if len(audit) != 1 || audit[0].Action != domain.AuditPasswordReset {
+		t.Fatalf("unexpected audit: %+v", audit)
+	}
+}
+
+func TestDeleteUser(t *testing.T) {
+	repo, admin, viewer := adminFixture()
+	svc := user.NewUserService(repo, &fakeJwt{}, testCfg())
+
+	if err := svc.DeleteUser(t.Context(), admin.Id.String(), admin.Id.String(), admin.Login); !errors.Is(err, domain.ErrSelfChange) {
+		t.Fatalf("expected self delete to be rejected, got %v", err)
+	}
+	if err := svc.DeleteUser(t.Context(), viewer.Id.String(), admin.Id.String(), admin.Login); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if _, err := svc.GetUserByID(t.Context(), viewer.Id.String()); !errors.Is(err, domain.ErrNotFound) {
+		t.Fatalf("expected user to be deleted, got %v", err)
+	}
+	audit, _ := svc.GetUserAudit(t.Context(), viewer.Id.String())
+	if len(audit) != 1 || audit[0].Action != domain.AuditDeleted {
+		t.Fatalf("expected audit to outlive the user, got %+v", audit)
+	}
+}
+
+func TestGetUsers_Validation(t *testing.T) {
+	repo, _, _ := adminFixture()
+	svc := user.NewUserService(repo, &fakeJwt{}, testCfg())
+
+	page, err := svc.GetUsers(t.Context(), domain.ListOptions{Role: domain.Admin})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if page.Total != 1 || page.Users[0].Login != "admin" {
+		t.Fatalf("unexpected page: %+v", page)
+	}
+	if _, err := svc.GetUsers(t.Context(), domain.ListOptions{Role: "root"}); !errors.Is(err, errs.ErrInvalidInput) {
+		t.Fatalf("expected invalid role filter to be rejected, got %v", err)
+	}
+	if _, err := svc.GetUsers(t.Context(), domain.ListOptions{Offset: -1}); !errors.Is(err, errs.ErrInvalidInput) {
+		t.Fatalf("expected negative offset to be rejected, got %v", err)
+	}
+}
@@ -3,121 +3,406 @@ package user
 import (
 	"warehousecontrol/internal/auth"
 	"warehousecontrol/internal/config"
+	"warehousecontrol/internal/domain/errs"
 	"warehousecontrol/internal/domain/user"
 
+	"context"
+	"crypto/subtle"
 	"errors"
-	"fmt"
 	"regexp"
+	"time"
 	"unicode"
 	"unicode/utf8"
 
+	"github.com/google/uuid"
 	wbzlog "github.com/wb-go/wbf/zlog"
 	"golang.org/x/crypto/bcrypt"
 )
 
+const sessionTouchInterval = time.Minute
+
 type UserService struct {
-	repo UserStorageProvider
-	jwt  JwtAuthProvider
-	cfg  *config.AppConfig
+	repo           UserStorageProvider
+	jwt            JwtAuthProvider
+	cfg            *config.AppConfig
+	roles          roleCache
+	authenticators []Authenticator
 }
 
 type JwtAuthProvider interface {
-	GenerateTokens(user *user.User) (*auth.JWTResponse, error)
+	GenerateTokens(user *user.User, role *user.RoleDefinition, sessionID string) (*auth.JWTResponse, error)
 	ValidateTokens(tokenStr string) (*auth.JWTPayload, error)
-	RefreshTokens(refreshToken string) (*auth.JWTResponse, error)
+	ParseRefreshToken(refreshToken string) (*auth.JWTPayload, error)
+	GenerateMFAToken(user *user.User) (string, time.Time, error)
+	ParseMFAToken(tokenStr string) (string, error)
 }
 
 type UserStorageProvider interface {
-	GetUser(login string) (*user.User, error)
-	SaveUser(user *user.User) error
+	GetUser(ctx context.Context, login string) (*user.User, error)
+	GetUserByID(ctx context.Context, id string) (*user.User, error)
+	GetUsers(ctx context.Context, opts user.ListOptions) (*user.Page, error)
+	SaveUser(ctx context.Context, user *user.User) error
+	ChangeUserRole(ctx context.Context, id string, role user.Role, actorID, actorLogin string) (*user.User, error)
+	SetUserDisabled(ctx context.Context, id string, disabled bool, actorID, actorLogin string) (*user.User, error)
+	ResetUserPassword(ctx context.Context, id string, password []byte, actorID, actorLogin string) error
+	DeleteUser(ctx context.Context, id string, actorID, actorLogin string) error
+	GetUserAudit(ctx context.Context, id string) ([]*user.AuditEntry, error)
+	CreateFirstAdmin(ctx context.Context, user *user.User) error
+	SaveUserWithInvitation(ctx context.Context, user *user.User, invitationID string) error
+	SaveInvitation(ctx context.Context, inv *user.Invitation) error
+	GetInvitationByHash(ctx context.Context, tokenHash []byte) (*user.Invitation, error)
+	GetInvitations(ctx context.Context) ([]*user.Invitation, error)
+	DeleteInvitation(ctx context.Context, id string) error
+	CreateSession(ctx context.Context, s *user.Session, first *user.RefreshToken) error
+	RotateRefreshToken(ctx context.Context, oldID string, next *user.RefreshToken) error
+	GetSession(ctx context.Context, id string) (*user.Session, error)
+	TouchSession(ctx context.Context, id string) error
+	GetUserSessions(ctx context.Context, userID string) ([]*user.Session, error)
+	RevokeSession(ctx context.Context, userID, sessionID string) error
+	RevokeUserSessions(ctx context.Context, userID string) error
+	LoginLockedUntil(ctx context.Context, login, ip string) (*time.Time, error)
+	RegisterLoginFailure(ctx context.Context, scope, subject string, policy user.LockoutPolicy) (bool, error)
+	ResetLoginFailures(ctx context.Context, login string) error
+	UnlockUser(ctx context.Context, id, actorID, actorLogin string) error
+	ChangeUserPassword(ctx context.Context, id string, password []byte, actorID, actorLogin string) error
+	SavePasswordResetToken(ctx context.Context, t *user.PasswordResetToken, actorID, actorLogin string) error
+	RedeemPasswordResetToken(ctx context.Context, tokenHash []byte, password []byte) (*user.User, error)
+	GetTOTP(ctx context.Context, userID string) (*user.TOTP, error)
+	SaveTOTPSecret(ctx context.Context, t *user.TOTP) error
+	EnableTOTP(ctx context.Context, userID string, step int64, codeHashes [][]byte, actorLogin string) error
+	UseTOTPStep(ctx context.Context, userID string, step int64) error
+	UseRecoveryCode(ctx context.Context, userID string, codeHash []byte) error
+	ReplaceRecoveryCodes(ctx context.Context, userID string, codeHashes [][]byte, actorLogin string) error
+	DisableTOTP(ctx context.Context, userID, actorID, actorLogin string) error
+	CreateAPIKey(ctx context.Context, k *user.APIKey, actorID, actorLogin string) error
+	RotateAPIKey(ctx context.Context, oldID string, next *user.APIKey, grace time.Duration, actorID, actorLogin string) error
+	RevokeAPIKey(ctx context.Context, userID, keyID, actorID, actorLogin string) error
+	GetAPIKey(ctx context.Context, userID, keyID string) (*user.APIKey, error)
+	GetAPIKeyByHash(ctx context.Context, keyHash []byte) (*user.APIKey, error)
+	GetAPIKeys(ctx context.Context, userID string) ([]*user.APIKey, error)
+	TouchAPIKey(ctx context.Context, id string) error
+	GetRoles(ctx context.Context) ([]*user.RoleDefinition, error)
+	GetRole(ctx context.Context, name user.Role) (*user.RoleDefinition, error)
+	CreateRole(ctx context.Context, r *user.RoleDefinition) error
+	UpdateRole(ctx context.Context, name user.Role, description string, perms user.Permissions) (*user.RoleDefinition, error)
+	DeleteRole(ctx context.Context, name user.Role) error
+	SaveSSOState(ctx context.Context, s *user.SSOState) error
+	TakeSSOState(ctx context.Context, stateHash []byte) (*user.SSOState, error)
+	SyncSSOUser(ctx context.Context, ident *user.Identity, candidate *user.User) (*user.User, error)
 }
 
-func NewUserService(repo UserStorageProvider, jwt JwtAuthProvider, cfg *config.AppConfig) *UserService {
+// NewUserService — сервис пользователей; authenticators — источники учётных записей для
+// входа по паролю в порядке опроса, без них пароль проверяется только по базе
+func NewUserService(repo UserStorageProvider, jwt JwtAuthProvider, cfg *config.AppConfig, authenticators ...Authenticator) *UserService {
+	if len(authenticators) == 0 {
+		authenticators = []Authenticator{NewPasswordAuthenticator(repo)}
+	}
 	return &UserService{
-		repo: repo,
-		jwt:  jwt,
-		cfg:  cfg,
+		repo:           repo,
+		jwt:            jwt,
+		cfg:            cfg,
+		authenticators: authenticators,
 	}
 }
 
-func (s *UserService) Login(Login, Password string) (*auth.JWTResponse, error) {
+// Login проверяет учётные данные в источниках учётных записей (база, LDAP) и открывает новую
+// сессию для клиента. Неудачные попытки считаются по логину и IP; при превышении порога
+// вход временно блокируется.
+// Если у пользователя подключён второй фактор (или он обязателен для роли), вместо токенов
+// возвращается токен второго шага, который обменивается на сессию в LoginMFA.
+func (s *UserService) Login(ctx context.Context, Login, Password string, client user.Client) (*auth.LoginResponse, error) {
 	if Login == "" || Password == "" {
 		wbzlog.Logger.Debug().Msg("login or password cant be empty")
-		return nil, errors.New("login or password cant be empty")
+		return nil, errs.New(errs.ErrValidation, "login or password cant be empty")
 	}
 
-	user, err := s.repo.GetUser(Login)
+	if err := s.checkLockout(ctx, Login, client.IP); err != nil {
+		return nil, err
+	}
+
+	u, err := s.authenticate(ctx, Login, Password)
+	if errors.Is(err, errUnknownLogin) {
+		// сравнение с фиктивным хэшем выравнивает время ответа для несуществующего логина
+		_ = bcrypt.CompareHashAndPassword(dummyPasswordHash, []byte(Password))
+		return nil, s.loginFailed(ctx, Login, client.IP)
+	}
+	if errors.Is(err, user.ErrInvalidCredentials) {
+		return nil, s.loginFailed(ctx, Login, client.IP)
+	}
 	if err != nil {
 		return nil, err
 	}
+	if u.Disabled() {
+		wbzlog.Logger.Debug().Str("login", u.Login).Msg("login to disabled account")
+		return nil, user.ErrDisabled
+	}
+	if u.ServiceAccount {
+		return nil, user.ErrServiceAccountLogin
+	}
 
-	err = bcrypt.CompareHashAndPassword(user.Password, []byte(Password))
+	resp, err := s.passwordVerified(ctx, u, client)
 	if err != nil {
-		wbzlog.Logger.Debug().Err(err).Msg("invalid password")
 		return nil, err
 	}
+	// со вторым фактором счётчик сбрасывается только после верного кода в LoginMFA,
+	// иначе повторный ввод пароля обнулял бы счёт неверных кодов
+	if resp.JWTResponse != nil {
+		if err := s.repo.ResetLoginFailures(ctx, Login); err != nil {
+			wbzlog.Logger.Warn().Err(err).Msg("cant reset login failures")
+		}
+	}
+	return resp, nil
+}
 
-	jwtresp, err := s.jwt.GenerateTokens(user)
+// openSession выпускает пару токенов новой сессии
+func (s *UserService) openSession(ctx context.Context, u *user.User, client user.Client) (*auth.JWTResponse, error) {
+	role, err := s.role(ctx, u.Role, 0)
+	if err != nil {
+		return nil, err
+	}
+	jwtresp, err := s.jwt.GenerateTokens(u, role, "")
+	if err != nil {
+		return nil, err
+	}
+	rt, err := refreshRecord(u, jwtresp)
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	sess := &user.Session{
+		ID:         rt.SessionID,
+		UserID:     u.Id,
+		UserAgent:  client.UserAgent,
+		IP:         client.IP,
+		CreatedAt:  now,
+		LastUsedAt: now,
+		ExpiresAt:  rt.ExpiresAt,
+	}
+	err = s.repo.CreateSession(ctx, sess, rt)
 	if err != nil {
 		return nil, err
 	}
 	return jwtresp, nil
 }
 
-func (s *UserService) Registration(Login, Password, Role string) (*user.User, error) {
+// Registration создаёт пользователя по приглашению: роль берётся из приглашения,
+// а само приглашение должно быть не истекшим и ещё не использованным
+func (s *UserService) Registration(ctx context.Context, Login, Password, InviteToken string) (*user.User, error) {
+	if InviteToken == "" {
+		return nil, errs.New(errs.ErrInvalidInput, "invitation token required")
+	}
+
+	// приглашение проверяется до логина: иначе по ответам без приглашения можно перебирать занятые логины
+	inv, err := s.repo.GetInvitationByHash(ctx, user.HashInvitationToken(InviteToken))
+	if err != nil {
+		return nil, err
+	}
+	if err := inv.CheckUsable(time.Now()); err != nil {
+		wbzlog.Logger.Debug().Err(err).Msg("invitation cant be used")
+		return nil, err
+	}
+
+	if err := s.checkNewCredentials(ctx, Login, Password); err != nil {
+		return nil, err
+	}
+
+	u, err := user.NewUser(Login, Password, inv.Role)
+	if err != nil {
+		wbzlog.Logger.Error().Err(err).Msg("cant create new user")
+		return nil, err
+	}
+
+	err = s.repo.SaveUserWithInvitation(ctx, u, inv.ID.String())
+	if err != nil {
+		return nil, err
+	}
+	return u, nil
+}
+
+// Bootstrap создаёт первого администратора по токену установки SETUP_TOKEN.
+// Без настроенного токена и после появления администратора не работает.
+func (s *UserService) Bootstrap(ctx context.Context, SetupToken, Login, Password string) (*user.User, error) {
+	expected := s.cfg.AuthConfig.SetupToken
+	if expected == "" {
+		return nil, errs.New(errs.ErrForbidden, "bootstrap is disabled")
+	}
+	if subtle.ConstantTimeCompare([]byte(SetupToken), []byte(expected)) != 1 {
+		return nil, errs.New(errs.ErrUnauthorized, "invalid setup token")
+	}
+	if err := s.checkNewCredentials(ctx, Login, Password); err != nil {
+		return nil, err
+	}
+
+	u, err := user.NewUser(Login, Password, user.Admin)
+	if err != nil {
+		wbzlog.Logger.Error().Err(err).Msg("cant create admin user")
+		return nil, err
+	}
+	err = s.repo.CreateFirstAdmin(ctx, u)
+	if err != nil {
+		return nil, err
+	}
+	wbzlog.Logger.Info().Str("login", u.Login).Msg("initial admin created")
+	return u, nil
+}
+
+// checkNewCredentials проверяет логин и пароль нового пользователя и занятость логина
+func (s *UserService) checkNewCredentials(ctx context.Context, Login, Password string) error {
 	if err := s.isValidLogin(Login); err != nil {
 		wbzlog.Logger.Debug().Err(err).Msg("invalid login")
-		return nil, err
+		return err
 	}
 
 	if err := s.isValidPassword(Password); err != nil {
 		wbzlog.Logger.Debug().Err(err).Msg("invalid password")
-		return nil, err
+		return err
 	}
 
-	ch, err := s.repo.GetUser(Login)
-	if err != nil && err.Error() != "user not found" {
+	ch, err := s.repo.GetUser(ctx, Login)
+	if err != nil && !errors.Is(err, user.ErrNotFound) {
 		wbzlog.Logger.Error().Err(err).Msg("cant check existing user")
-		return nil, err
+		return err
 	}
 
 	if ch != nil {
 		wbzlog.Logger.Debug().Msg("user with this login already exists")
-		return nil, errors.New("user with this login already exists")
+		return user.ErrAlreadyExists
 	}
+	return nil
+}
 
-	user, err := user.NewUser(Login, Password, user.Role(Role))
+// RefreshTokens обменивает refresh токен на новую пару в той же сессии. Предъявленный токен
+// сверяется с базой и становится использованным; повторное предъявление отзывает сессию.
+// Новая пара строится по актуальным данным пользователя: смена роли применяется сразу,
+// а отключённая учётная запись токены не получает.
+func (s *UserService) RefreshTokens(ctx context.Context, refreshToken string) (*auth.JWTResponse, error) {
+	payload, err := s.parseRefreshToken(refreshToken)
+	if err != nil {
+		return nil, err
+	}
+	u, err := s.repo.GetUserByID(ctx, payload.UserID)
+	if errors.Is(err, user.ErrNotFound) {
+		return nil, user.ErrRefreshTokenInvalid
+	}
+	if err != nil {
+		return nil, err
+	}
+	if u.Disabled() {
+		return nil, user.ErrDisabled
+	}
 
+	role, err := s.role(ctx, u.Role, 0)
+	if err != nil {
+		return nil, err
+	}
+	jwtresp, err := s.jwt.GenerateTokens(u, role, payload.SessionID)
+	if err != nil {
+		return nil, err
+	}
+	next, err := refreshRecord(u, jwtresp)
 	if err != nil {
-		wbzlog.Logger.Error().Err(err).Msg("cant create new user")
 		return nil, err
 	}
+	err = s.repo.RotateRefreshToken(ctx, payload.TokenID, next)
+	if err != nil {
+		return nil, err
+	}
+	return jwtresp, nil
+}
 
-	err = s.repo.SaveUser(user)
+// Logout завершает сессию предъявленного refresh токена, а с all — все сессии пользователя
+func (s *UserService) Logout(ctx context.Context, refreshToken string, all bool) error {
+	payload, err := s.parseRefreshToken(refreshToken)
+	if err != nil {
+		return err
+	}
+	if all {
+		err = s.repo.RevokeUserSessions(ctx, payload.UserID)
+	} else {
+		err = s.repo.RevokeSession(ctx, payload.UserID, payload.SessionID)
+	}
+	if err != nil {
+		return err
+	}
+	wbzlog.Logger.Info().Str("user_id", payload.UserID).Bool("all", all).Msg("user logged out")
+	return nil
+}
 
+// parseRefreshToken проверяет подпись и срок токена; любые ошибки разбора — 401
+func (s *UserService) parseRefreshToken(refreshToken string) (*auth.JWTPayload, error) {
+	payload, err := s.jwt.ParseRefreshToken(refreshToken)
 	if err != nil {
-		return nil, err
+		wbzlog.Logger.Debug().Err(err).Msg("invalid refresh token")
+		return nil, user.ErrRefreshTokenInvalid
+	}
+	for _, id := range []string{payload.UserID, payload.TokenID, payload.SessionID} {
+		if _, err := uuid.Parse(id); err != nil {
+			return nil, user.ErrRefreshTokenInvalid
+		}
 	}
-	return user, nil
+	return payload, nil
 }
 
-func (s *UserService) RefreshTokens(refreshToken string) (*auth.JWTResponse, error) {
-	return s.jwt.RefreshTokens(refreshToken)
+func refreshRecord(u *user.User, resp *auth.JWTResponse) (*user.RefreshToken, error) {
+	id, err := uuid.Parse(resp.RefreshTokenID)
+	if err != nil {
+		return nil, err
+	}
+	sessionID, err := uuid.Parse(resp.SessionID)
+	if err != nil {
+		return nil, err
+	}
+	return &user.RefreshToken{
+		ID:        id,
+		SessionID: sessionID,
+		UserID:    u.Id,
+		IssuedAt:  time.Now(),
+		ExpiresAt: resp.RefreshExpiresAt,
+	}, nil
 }
 
-func (s *UserService) ValidateTokens(tokenStr string) (*auth.JWTPayload, error) {
-	return s.jwt.ValidateTokens(tokenStr)
+// ValidateTokens проверяет access токен и его сессию: токен отозванной сессии не принимается.
+// Права в ответе актуальны, даже если роль изменили после выпуска токена.
+func (s *UserService) ValidateTokens(ctx context.Context, tokenStr string) (*auth.JWTPayload, error) {
+	payload, err := s.jwt.ValidateTokens(tokenStr)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := uuid.Parse(payload.SessionID); err != nil {
+		return nil, user.ErrSessionRevoked
+	}
+	sess, err := s.repo.GetSession(ctx, payload.SessionID)
+	if errors.Is(err, user.ErrSessionNotFound) {
+		return nil, user.ErrSessionRevoked
+	}
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	if !sess.Active(now) || sess.UserID.String() != payload.UserID {
+		return nil, user.ErrSessionRevoked
+	}
+	// время последнего использования обновляется не чаще раза в минуту
+	if now.Sub(sess.LastUsedAt) > sessionTouchInterval {
+		if err := s.repo.TouchSession(ctx, payload.SessionID); err != nil {
+			wbzlog.Logger.Warn().Err(err).Msg("cant update session last use")
+		}
+	}
+	if err := s.resolvePermissions(ctx, payload); err != nil {
+		return nil, err
+	}
+	return payload, nil
 }
 
 func (s *UserService) isValidLogin(login string) error {
 	if utf8.RuneCountInString(login) < s.cfg.UserConfig.MinLength || utf8.RuneCountInString(login) > s.cfg.UserConfig.MaxLength {
-		return fmt.Errorf("invalid login length . Must be between %d and %d characters", s.cfg.UserConfig.MinLength, s.cfg.UserConfig.MaxLength)
+		return errs.Errorf(errs.ErrValidation, "invalid login length . Must be between %d and %d characters", s.cfg.UserConfig.MinLength, s.cfg.UserConfig.MaxLength)
 	}
 
 	escapedChars := regexp.QuoteMeta(s.cfg.UserConfig.AllowedCharacters)
 	loginRegexp := regexp.MustCompile(`^[` + escapedChars + `]+$`)
 	if !loginRegexp.MatchString(login) {
-		return errors.New("invalid login characters. Must contain only letters, digits, underscores, or hyphens and must not contain spaces")
+		return errs.New(errs.ErrValidation, "invalid login characters. Must contain only letters, digits, underscores, or hyphens and must not contain spaces")
 	}
 	return nil
 }
@@ -127,14 +412,14 @@ func (s *UserService) isValidPassword(password string) error {
 
 	l := utf8.RuneCountInString(password)
 	if l < cfg.MinLength || l > cfg.MaxLength {
-		return fmt.Errorf(
+		return errs.Errorf(errs.ErrValidation,
 			"invalid password length: must be %d–%d characters",
 			cfg.MinLength, cfg.MaxLength,
 		)
 	}
 
 	if !utf8.ValidString(password) {
-		return errors.New("password contains invalid UTF-8 characters")
+		return errs.New(errs.ErrValidation, "password contains invalid UTF-8 characters")
 	}
 
 	var hasUpper, hasLower, hasDigit bool
@@ -151,13 +436,13 @@ func (s *UserService) isValidPassword(password string) error {
 	}
 
 	if cfg.RequireUpper && !hasUpper {
-		return errors.New("password must contain at least one uppercase letter")
+		return errs.New(errs.ErrValidation, "password must contain at least one uppercase letter")
 	}
 	if cfg.RequireLower && !hasLower {
-		return errors.New("password must contain at least one lowercase letter")
+		return errs.New(errs.ErrValidation, "password must contain at least one lowercase letter")
 	}
 	if cfg.RequireDigit && !hasDigit {
-		return errors.New("password must contain at least one digit")
+		return errs.New(errs.ErrValidation, "password must contain at least one digit")
 	}
 
 	return nil
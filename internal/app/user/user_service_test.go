@@ -1,34 +1,56 @@
 package user_test
 
 import (
+	"context"
 	"errors"
+	"strconv"
+	"strings"
 	"testing"
+	"time"
+
+	"github.com/google/uuid"
 
 	"golang.org/x/crypto/bcrypt"
 
 	"warehousecontrol/internal/app/user"
 	"warehousecontrol/internal/auth"
 	"warehousecontrol/internal/config"
+	"warehousecontrol/internal/domain/errs"
 	domain "warehousecontrol/internal/domain/user"
 )
 
 type fakeRepo struct {
-	users map[string]*domain.User
-	err   error
+	users       map[string]*domain.User
+	invitations map[string]*domain.Invitation
+	audit       []*domain.AuditEntry
+	tokens      map[uuid.UUID]*domain.RefreshToken
+	sessions    map[uuid.UUID]*domain.Session
+	touched     int
+	failures    map[string]*domain.LoginFailures
+	resets      map[string]*domain.PasswordResetToken
+	totp        map[uuid.UUID]*domain.TOTP
+	recovery    map[uuid.UUID]map[string]bool
+	apiKeys     map[uuid.UUID]*domain.APIKey
+	keyTouches  int
+	roles       map[domain.Role]*domain.RoleDefinition
+	roleLoads   int
+	ssoStates   map[string]*domain.SSOState
+	identities  map[string]uuid.UUID
+	err         error
 }
 
-func (f *fakeRepo) GetUser(login string) (*domain.User, error) {
+func (f *fakeRepo) GetUser(_ context.Context, login string) (*domain.User, error) {
 	if f.err != nil {
 		return nil, f.err
 	}
 	u, ok := f.users[login]
 	if !ok {
-		return nil, errors.New("user not found")
+		return nil, domain.ErrNotFound
 	}
 	return u, nil
 }
 
-func (f *fakeRepo) SaveUser(u *domain.User) error {
+func (f *fakeRepo) SaveUser(_ context.Context, u *domain.User) error {
 	if f.err != nil {
 		return f.err
 	}
@@ -39,16 +61,727 @@ func (f *fakeRepo) SaveUser(u *domain.User) error {
 	return nil
 }
 
+func (f *fakeRepo) CreateFirstAdmin(ctx context.Context, u *domain.User) error {
+	for _, existing := range f.users {
+		if existing.Role == domain.Admin {
+			return domain.ErrAlreadyBootstrapped
+		}
+	}
+	return f.SaveUser(ctx, u)
+}
+
+func (f *fakeRepo) SaveUserWithInvitation(ctx context.Context, u *domain.User, invitationID string) error {
+	for _, inv := range f.invitations {
+		if inv.ID.String() != invitationID {
+			continue
+		}
+		if inv.UsedAt != nil {
+			return domain.ErrInvitationUsed
+		}
+		if err := f.SaveUser(ctx, u); err != nil {
+			return err
+		}
+		now := time.Now()
+		inv.UsedAt = &now
+		inv.UsedBy = &u.Id
+		return nil
+	}
+	return domain.ErrInvitationNotFound
+}
+
+func (f *fakeRepo) SaveInvitation(_ context.Context, inv *domain.Invitation) error {
+	if f.invitations == nil {
+		f.invitations = map[string]*domain.Invitation{}
+	}
+	f.invitations[string(inv.TokenHash)] = inv
+	return nil
+}
+
+func (f *fakeRepo) GetInvitationByHash(_ context.Context, tokenHash []byte) (*domain.Invitation, error) {
+	inv, ok := f.invitations[string(tokenHash)]
+	if !ok {
+		return nil, domain.ErrInvitationNotFound
+	}
+	return inv, nil
+}
+
+func (f *fakeRepo) GetInvitations(_ context.Context) ([]*domain.Invitation, error) {
+	res := make([]*domain.Invitation, 0, len(f.invitations))
+	for _, inv := range f.invitations {
+		res = append(res, inv)
+	}
+	return res, nil
+}
+
+func (f *fakeRepo) DeleteInvitation(_ context.Context, id string) error {
+	for hash, inv := range f.invitations {
+		if inv.ID.String() == id {
+			if inv.UsedAt != nil {
+				return domain.ErrInvitationUsed
+			}
+			delete(f.invitations, hash)
+			return nil
+		}
+	}
+	return domain.ErrInvitationNotFound
+}
+
+func (f *fakeRepo) GetUserByID(_ context.Context, id string) (*domain.User, error) {
+	for _, u := range f.users {
+		if u.Id.String() == id {
+			return u, nil
+		}
+	}
+	return nil, domain.ErrNotFound
+}
+
+func (f *fakeRepo) GetUsers(_ context.Context, opts domain.ListOptions) (*domain.Page, error) {
+	page := &domain.Page{Users: []*domain.User{}}
+	for _, u := range f.users {
+		if opts.Role != "" && u.Role != opts.Role {
+			continue
+		}
+		page.Users = append(page.Users, u)
+	}
+	page.Total = len(page.Users)
+	return page, nil
+}
+
+// otherActiveAdmin повторяет проверку репозитория на последнего активного админа
+func (f *fakeRepo) otherActiveAdmin(u *domain.User) bool {
+	for _, other := range f.users {
+		if other.Id != u.Id && other.Role == domain.Admin && !other.Disabled() {
+			return true
+		}
+	}
+	return false
+}
+
+func (f *fakeRepo) logAudit(u *domain.User, action, actorLogin string) {
+	f.audit = append(f.audit, &domain.AuditEntry{UserID: u.Id, UserLogin: u.Login, Action: action, ActorLogin: actorLogin})
+}
+
+func (f *fakeRepo) ChangeUserRole(ctx context.Context, id string, role domain.Role, actorID, actorLogin string) (*domain.User, error) {
+	u, err := f.GetUserByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if u.Role == domain.Admin && role != domain.Admin && !u.Disabled() && !f.otherActiveAdmin(u) {
+		return nil, domain.ErrLastAdmin
+	}
+	u.Role = role
+	f.logAudit(u, domain.AuditRoleChanged, actorLogin)
+	return u, nil
+}
+
+func (f *fakeRepo) SetUserDisabled(ctx context.Context, id string, disabled bool, actorID, actorLogin string) (*domain.User, error) {
+	u, err := f.GetUserByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if disabled && u.Role == domain.Admin && !f.otherActiveAdmin(u) {
+		return nil, domain.ErrLastAdmin
+	}
+	u.DisabledAt = nil
+	action := domain.AuditEnabled
+	if disabled {
+		now := time.Now()
+		u.DisabledAt = &now
+		action = domain.AuditDisabled
+		_ = f.RevokeUserSessions(ctx, u.Id.String())
+	}
+	f.logAudit(u, action, actorLogin)
+	return u, nil
+}
+
+func (f *fakeRepo) ResetUserPassword(ctx context.Context, id string, password []byte, actorID, actorLogin string) error {
+	u, err := f.GetUserByID(ctx, id)
+	if err != nil {
+		return err
+	}
+	u.Password = password
+	_ = f.RevokeUserSessions(ctx, u.Id.String())
+	f.logAudit(u, domain.AuditPasswordReset, actorLogin)
+	return nil
+}
+
+func (f *fakeRepo) DeleteUser(ctx context.Context, id string, actorID, actorLogin string) error {
+	u, err := f.GetUserByID(ctx, id)
+	if err != nil {
+		return err
+	}
+	if u.Role == domain.Admin && !f.otherActiveAdmin(u) {
+		return domain.ErrLastAdmin
+	}
+	delete(f.users, u.Login)
+	f.logAudit(u, domain.AuditDeleted, actorLogin)
+	return nil
+}
+
+func (f *fakeRepo) GetUserAudit(_ context.Context, id string) ([]*domain.AuditEntry, error) {
+	var res []*domain.AuditEntry
+	for _, e := range f.audit {
+		if e.UserID.String() == id {
+			res = append(res, e)
+		}
+	}
+	return res, nil
+}
+
+func (f *fakeRepo) CreateSession(_ context.Context, s *domain.Session, first *domain.RefreshToken) error {
+	if f.sessions == nil {
+		f.sessions = map[uuid.UUID]*domain.Session{}
+	}
+	if f.tokens == nil {
+		f.tokens = map[uuid.UUID]*domain.RefreshToken{}
+	}
+	f.sessions[s.ID] = s
+	f.tokens[first.ID] = first
+	return nil
+}
+
+func (f *fakeRepo) RotateRefreshToken(_ context.Context, oldID string, next *domain.RefreshToken) error {
+	old, ok := f.tokens[uuid.MustParse(oldID)]
+	if !ok || old.SessionID != next.SessionID {
+		return domain.ErrRefreshTokenInvalid
+	}
+	sess := f.sessions[old.SessionID]
+	if !sess.Active(time.Now()) {
+		return domain.ErrRefreshTokenInvalid
+	}
+	if old.UsedAt != nil {
+		now := time.Now()
+		sess.RevokedAt = &now
+		return domain.ErrRefreshTokenReused
+	}
+	now := time.Now()
+	old.UsedAt = &now
+	f.tokens[next.ID] = next
+	sess.LastUsedAt = now
+	sess.ExpiresAt = next.ExpiresAt
+	return nil
+}
+
+func (f *fakeRepo) GetSession(_ context.Context, id string) (*domain.Session, error) {
+	s, ok := f.sessions[uuid.MustParse(id)]
+	if !ok {
+		return nil, domain.ErrSessionNotFound
+	}
+	return s, nil
+}
+
+func (f *fakeRepo) TouchSession(_ context.Context, id string) error {
+	f.touched++
+	return nil
+}
+
+func (f *fakeRepo) GetUserSessions(_ context.Context, userID string) ([]*domain.Session, error) {
+	var res []*domain.Session
+	now := time.Now()
+	for _, s := range f.sessions {
+		if s.UserID.String() == userID && s.Active(now) {
+			res = append(res, s)
+		}
+	}
+	return res, nil
+}
+
+func (f *fakeRepo) RevokeSession(_ context.Context, userID, sessionID string) error {
+	s, ok := f.sessions[uuid.MustParse(sessionID)]
+	if !ok || s.UserID.String() != userID || s.RevokedAt != nil {
+		return domain.ErrSessionNotFound
+	}
+	now := time.Now()
+	s.RevokedAt = &now
+	return nil
+}
+
+func (f *fakeRepo) RevokeUserSessions(_ context.Context, userID string) error {
+	now := time.Now()
+	for _, s := range f.sessions {
+		if s.UserID.String() == userID && s.RevokedAt == nil {
+			s.RevokedAt = &now
+		}
+	}
+	return nil
+}
+
+func (f *fakeRepo) LoginLockedUntil(_ context.Context, login, ip string) (*time.Time, error) {
+	now := time.Now()
+	for _, key := range []string{domain.ScopeLogin + ":" + login, domain.ScopeIP + ":" + ip} {
+		if c, ok := f.failures[key]; ok && c.Locked(now) {
+			return c.LockedUntil, nil
+		}
+	}
+	return nil, nil
+}
+
+func (f *fakeRepo) RegisterLoginFailure(_ context.Context, scope, subject string, policy domain.LockoutPolicy) (bool, error) {
+	if f.failures == nil {
+		f.failures = map[string]*domain.LoginFailures{}
+	}
+	c, ok := f.failures[scope+":"+subject]
+	if !ok {
+		c = &domain.LoginFailures{Scope: scope, Subject: subject}
+		f.failures[scope+":"+subject] = c
+	}
+	locked := c.Fail(time.Now(), policy)
+	if u, ok := f.users[subject]; ok && locked && scope == domain.ScopeLogin {
+		f.logAudit(u, domain.AuditLocked, domain.SystemActor)
+	}
+	return locked, nil
+}
+
+func (f *fakeRepo) ResetLoginFailures(_ context.Context, login string) error {
+	delete(f.failures, domain.ScopeLogin+":"+login)
+	return nil
+}
+
+func (f *fakeRepo) UnlockUser(ctx context.Context, id, actorID, actorLogin string) error {
+	u, err := f.GetUserByID(ctx, id)
+	if err != nil {
+		return err
+	}
+	c, ok := f.failures[domain.ScopeLogin+":"+u.Login]
+	delete(f.failures, domain.ScopeLogin+":"+u.Login)
+	if ok && c.Locked(time.Now()) {
+		f.logAudit(u, domain.AuditUnlocked, actorLogin)
+	}
+	return nil
+}
+
+func (f *fakeRepo) ChangeUserPassword(ctx context.Context, id string, password []byte, actorID, actorLogin string) error {
+	u, err := f.GetUserByID(ctx, id)
+	if err != nil {
+		return err
+	}
+	u.Password = password
+	_ = f.RevokeUserSessions(ctx, id)
+	f.logAudit(u, domain.AuditPasswordChanged, actorLogin)
+	return nil
+}
+
+func (f *fakeRepo) SavePasswordResetToken(ctx context.Context, t *domain.PasswordResetToken, actorID, actorLogin string) error {
+	u, err := f.GetUserByID(ctx, t.UserID.String())
+	if err != nil {
+		return err
+	}
+	if f.resets == nil {
+		f.resets = map[string]*domain.PasswordResetToken{}
+	}
+	for key, old := range f.resets {
+		if old.UserID == t.UserID && old.UsedAt == nil {
+			delete(f.resets, key)
+		}
+	}
+	f.resets[string(t.TokenHash)] = t
+	f.logAudit(u, domain.AuditResetIssued, actorLogin)
+	return nil
+}
+
+func (f *fakeRepo) RedeemPasswordResetToken(ctx context.Context, tokenHash []byte, password []byte) (*domain.User, error) {
+	t, ok := f.resets[string(tokenHash)]
+	now := time.Now()
+	if !ok || t.UsedAt != nil || !now.Before(t.ExpiresAt) {
+		return nil, domain.ErrResetTokenInvalid
+	}
+	t.UsedAt = &now
+	u, err := f.GetUserByID(ctx, t.UserID.String())
+	if err != nil {
+		return nil, err
+	}
+	u.Password = password
+	_ = f.RevokeUserSessions(ctx, u.Id.String())
+	f.logAudit(u, domain.AuditPasswordChanged, u.Login)
+	return u, nil
+}
+
+func (f *fakeRepo) GetTOTP(_ context.Context, userID string) (*domain.TOTP, error) {
+	t, ok := f.totp[uuid.MustParse(userID)]
+	if !ok {
+		return nil, domain.ErrTOTPNotEnabled
+	}
+	res := *t
+	res.RecoveryCodesLeft = 0
+	for _, used := range f.recovery[t.UserID] {
+		if !used {
+			res.RecoveryCodesLeft++
+		}
+	}
+	return &res, nil
+}
+
+func (f *fakeRepo) SaveTOTPSecret(_ context.Context, t *domain.TOTP) error {
+	if f.totp == nil {
+		f.totp = map[uuid.UUID]*domain.TOTP{}
+	}
+	if old, ok := f.totp[t.UserID]; ok && old.Enabled() {
+		return domain.ErrTOTPAlreadyEnabled
+	}
+	f.totp[t.UserID] = t
+	return nil
+}
+
+func (f *fakeRepo) EnableTOTP(ctx context.Context, userID string, step int64, codeHashes [][]byte, actorLogin string) error {
+	u, err := f.GetUserByID(ctx, userID)
+	if err != nil {
+		return err
+	}
+	t, ok := f.totp[u.Id]
+	if !ok || t.Enabled() {
+		return domain.ErrTOTPInvalidCode
+	}
+	now := time.Now()
+	t.ConfirmedAt = &now
+	t.LastStep = step
+	f.setRecoveryCodes(u.Id, codeHashes)
+	f.logAudit(u, domain.AuditTOTPEnabled, actorLogin)
+	return nil
+}
+
+func (f *fakeRepo) UseTOTPStep(_ context.Context, userID string, step int64) error {
+	t, ok := f.totp[uuid.MustParse(userID)]
+	if !ok || !t.Enabled() || t.LastStep >= step {
+		return domain.ErrTOTPInvalidCode
+	}
+	t.LastStep = step
+	return nil
+}
+
+func (f *fakeRepo) UseRecoveryCode(_ context.Context, userID string, codeHash []byte) error {
+	codes := f.recovery[uuid.MustParse(userID)]
+	used, ok := codes[string(codeHash)]
+	if !ok || used {
+		return domain.ErrTOTPInvalidCode
+	}
+	codes[string(codeHash)] = true
+	return nil
+}
+
+func (f *fakeRepo) ReplaceRecoveryCodes(ctx context.Context, userID string, codeHashes [][]byte, actorLogin string) error {
+	u, err := f.GetUserByID(ctx, userID)
+	if err != nil {
+		return err
+	}
+	if t, ok := f.totp[u.Id]; !ok || !t.Enabled() {
+		return domain.ErrTOTPNotEnabled
+	}
+	f.setRecoveryCodes(u.Id, codeHashes)
+	f.logAudit(u, domain.AuditRecoveryRenewed, actorLogin)
+	return nil
+}
+
+func (f *fakeRepo) DisableTOTP(ctx context.Context, userID, actorID, actorLogin string) error {
+	u, err := f.GetUserByID(ctx, userID)
+	if err != nil {
+		return err
+	}
+	if t, ok := f.totp[u.Id]; !ok || !t.Enabled() {
+		return domain.ErrTOTPNotEnabled
+	}
+	delete(f.totp, u.Id)
+	delete(f.recovery, u.Id)
+	f.logAudit(u, domain.AuditTOTPDisabled, actorLogin)
+	return nil
+}
+
+func (f *fakeRepo) setRecoveryCodes(userID uuid.UUID, codeHashes [][]byte) {
+	if f.recovery == nil {
+		f.recovery = map[uuid.UUID]map[string]bool{}
+	}
+	codes := map[string]bool{}
+	for _, h := range codeHashes {
+		codes[string(h)] = false
+	}
+	f.recovery[userID] = codes
+}
+
+func (f *fakeRepo) CreateAPIKey(ctx context.Context, k *domain.APIKey, actorID, actorLogin string) error {
+	u, err := f.GetUserByID(ctx, k.UserID.String())
+	if err != nil {
+		return err
+	}
+	if !u.ServiceAccount {
+		return domain.ErrNotServiceAccount
+	}
+	if f.apiKeys == nil {
+		f.apiKeys = map[uuid.UUID]*domain.APIKey{}
+	}
+	f.apiKeys[k.ID] = k
+	f.logAudit(u, domain.AuditAPIKeyCreated, actorLogin)
+	return nil
+}
+
+func (f *fakeRepo) RotateAPIKey(ctx context.Context, oldID string, next *domain.APIKey, grace time.Duration, actorID, actorLogin string) error {
+	old, err := f.GetAPIKey(ctx, next.UserID.String(), oldID)
+	if err != nil {
+		return err
+	}
+	if !old.Active(time.Now()) {
+		return domain.ErrAPIKeyInactive
+	}
+	until := time.Now().Add(grace)
+	if grace == 0 {
+		old.RevokedAt = &until
+	} else if old.ExpiresAt == nil || until.Before(*old.ExpiresAt) {
+		old.ExpiresAt = &until
+	}
+	f.apiKeys[next.ID] = next
+	u, _ := f.GetUserByID(ctx, next.UserID.String())
+	f.logAudit(u, domain.AuditAPIKeyRotated, actorLogin)
+	return nil
+}
+
+func (f *fakeRepo) RevokeAPIKey(ctx context.Context, userID, keyID, actorID, actorLogin string) error {
+	k, err := f.GetAPIKey(ctx, userID, keyID)
+	if err != nil {
+		return err
+	}
+	if k.RevokedAt != nil {
+		return domain.ErrAPIKeyNotFound
+	}
+	now := time.Now()
+	k.RevokedAt = &now
+	u, _ := f.GetUserByID(ctx, userID)
+	f.logAudit(u, domain.AuditAPIKeyRevoked, actorLogin)
+	return nil
+}
+
+func (f *fakeRepo) GetAPIKey(_ context.Context, userID, keyID string) (*domain.APIKey, error) {
+	for _, k := range f.apiKeys {
+		if k.ID.String() == keyID && k.UserID.String() == userID {
+			return k, nil
+		}
+	}
+	return nil, domain.ErrAPIKeyNotFound
+}
+
+func (f *fakeRepo) GetAPIKeyByHash(_ context.Context, keyHash []byte) (*domain.APIKey, error) {
+	for _, k := range f.apiKeys {
+		if string(k.KeyHash) == string(keyHash) {
+			return k, nil
+		}
+	}
+	return nil, domain.ErrAPIKeyNotFound
+}
+
+func (f *fakeRepo) GetAPIKeys(_ context.Context, userID string) ([]*domain.APIKey, error) {
+	res := []*domain.APIKey{}
+	for _, k := range f.apiKeys {
+		if k.UserID.String() == userID {
+			res = append(res, k)
+		}
+	}
+	return res, nil
+}
+
+func (f *fakeRepo) TouchAPIKey(_ context.Context, id string) error {
+	for _, k := range f.apiKeys {
+		if k.ID.String() == id {
+			now := time.Now()
+			k.LastUsedAt = &now
+			f.keyTouches++
+		}
+	}
+	return nil
+}
+
+// roleDefs лениво заполняет роли встроенными, как это делает миграция
+func (f *fakeRepo) roleDefs() map[domain.Role]*domain.RoleDefinition {
+	if f.roles == nil {
+		f.roles = map[domain.Role]*domain.RoleDefinition{}
+		for _, r := range domain.BuiltInRoles() {
+			f.roles[r.Name] = r
+		}
+	}
+	return f.roles
+}
+
+func (f *fakeRepo) GetRoles(_ context.Context) ([]*domain.RoleDefinition, error) {
+	if f.err != nil {
+		return nil, f.err
+	}
+	f.roleLoads++
+	res := []*domain.RoleDefinition{}
+	for _, r := range f.roleDefs() {
+		c := *r
+		res = append(res, &c)
+	}
+	return res, nil
+}
+
+func (f *fakeRepo) GetRole(_ context.Context, name domain.Role) (*domain.RoleDefinition, error) {
+	r, ok := f.roleDefs()[name]
+	if !ok {
+		return nil, domain.ErrRoleNotFound
+	}
+	c := *r
+	return &c, nil
+}
+
+func (f *fakeRepo) CreateRole(_ context.Context, r *domain.RoleDefinition) error {
+	if _, ok := f.roleDefs()[r.Name]; ok {
+		return domain.ErrRoleExists
+	}
+	c := *r
+	f.roles[r.Name] = &c
+	return nil
+}
+
+func (f *fakeRepo) UpdateRole(_ context.Context, name domain.Role, description string, perms domain.Permissions) (*domain.RoleDefinition, error) {
+	r, ok := f.roleDefs()[name]
+	if !ok {
+		return nil, domain.ErrRoleNotFound
+	}
+	if r.BuiltIn {
+		return nil, domain.ErrBuiltInRole
+	}
+	r.Description = description
+	r.Permissions = perms
+	r.Version++
+	r.UpdatedAt = time.Now()
+	c := *r
+	return &c, nil
+}
+
+func (f *fakeRepo) DeleteRole(_ context.Context, name domain.Role) error {
+	r, ok := f.roleDefs()[name]
+	if !ok {
+		return domain.ErrRoleNotFound
+	}
+	if r.BuiltIn {
+		return domain.ErrBuiltInRole
+	}
+	for _, u := range f.users {
+		if u.Role == name {
+			return domain.ErrRoleInUse
+		}
+	}
+	for _, inv := range f.invitations {
+		if inv.Role == name {
+			return domain.ErrRoleInUse
+		}
+	}
+	delete(f.roles, name)
+	return nil
+}
+
+func (f *fakeRepo) SaveSSOState(_ context.Context, s *domain.SSOState) error {
+	if f.ssoStates == nil {
+		f.ssoStates = map[string]*domain.SSOState{}
+	}
+	f.ssoStates[string(s.StateHash)] = s
+	return nil
+}
+
+func (f *fakeRepo) TakeSSOState(_ context.Context, stateHash []byte) (*domain.SSOState, error) {
+	s, ok := f.ssoStates[string(stateHash)]
+	delete(f.ssoStates, string(stateHash))
+	if !ok || !time.Now().Before(s.ExpiresAt) {
+		return nil, domain.ErrSSOStateInvalid
+	}
+	return s, nil
+}
+
+func (f *fakeRepo) SyncSSOUser(ctx context.Context, ident *domain.Identity, candidate *domain.User) (*domain.User, error) {
+	key := ident.Issuer + "|" + ident.Subject
+	if id, ok := f.identities[key]; ok {
+		u, err := f.GetUserByID(ctx, id.String())
+		if err != nil {
+			return nil, err
+		}
+		if u.Role != candidate.Role {
+			if u.Role == domain.Admin && !u.Disabled() && !f.otherActiveAdmin(u) {
+				return nil, domain.ErrLastAdmin
+			}
+			u.Role = candidate.Role
+			f.logAudit(u, domain.AuditRoleChanged, domain.SystemActor)
+		}
+		return u, nil
+	}
+	if _, ok := f.users[candidate.Login]; ok {
+		return nil, domain.ErrSSOLoginTaken
+	}
+	if err := f.SaveUser(ctx, candidate); err != nil {
+		return nil, err
+	}
+	if f.identities == nil {
+		f.identities = map[string]uuid.UUID{}
+	}
+	f.identities[key] = candidate.Id
+	f.logAudit(candidate, domain.AuditSSOProvisioned, domain.SystemActor)
+	return candidate, nil
+}
+
 type fakeJwt struct{}
 
-func (f *fakeJwt) GenerateTokens(u *domain.User) (*auth.JWTResponse, error) {
-	return &auth.JWTResponse{AccessToken: "access", RefreshToken: "refresh"}, nil
+// GenerateTokens кодирует в токенах пользователя, jti и сессию через ":";
+// access токен дополнительно несёт роль, её версию и права
+func (f *fakeJwt) GenerateTokens(u *domain.User, role *domain.RoleDefinition, sessionID string) (*auth.JWTResponse, error) {
+	jti := uuid.NewString()
+	if sessionID == "" {
+		sessionID = jti
+	}
+	access := refreshToken(u.Id.String(), uuid.NewString(), sessionID) + ":" + string(role.Name) + ":" + strconv.Itoa(role.Version) + ":" + joinPermissions(role.Permissions)
+	return &auth.JWTResponse{
+		AccessToken:      access,
+		RefreshToken:     refreshToken(u.Id.String(), jti, sessionID),
+		RefreshTokenID:   jti,
+		SessionID:        sessionID,
+		RefreshExpiresAt: time.Now().Add(time.Hour),
+	}, nil
 }
+
 func (f *fakeJwt) ValidateTokens(tokenStr string) (*auth.JWTPayload, error) {
-	return &auth.JWTPayload{UserID: "uid", Login: "login"}, nil
+	return f.ParseRefreshToken(tokenStr)
+}
+
+func (f *fakeJwt) ParseRefreshToken(token string) (*auth.JWTPayload, error) {
+	parts := strings.Split(token, ":")
+	if len(parts) != 3 && len(parts) != 6 {
+		return nil, errors.New("invalid refresh token")
+	}
+	payload := &auth.JWTPayload{UserID: parts[0], Login: "login", TokenID: parts[1], SessionID: parts[2]}
+	if len(parts) == 6 {
+		payload.Role = domain.Role(parts[3])
+		payload.RoleVersion, _ = strconv.Atoi(parts[4])
+		payload.Permissions = domain.Permissions{}
+		for _, p := range strings.Split(parts[5], ",") {
+			if p != "" {
+				payload.Permissions = append(payload.Permissions, domain.Permission(p))
+			}
+		}
+	}
+	return payload, nil
+}
+
+func (f *fakeJwt) GenerateMFAToken(u *domain.User) (string, time.Time, error) {
+	return "mfa:" + u.Id.String(), time.Now().Add(time.Minute), nil
+}
+
+func (f *fakeJwt) ParseMFAToken(token string) (string, error) {
+	userID, ok := strings.CutPrefix(token, "mfa:")
+	if !ok {
+		return "", errors.New("not a two-factor login token")
+	}
+	return userID, nil
+}
+
+func joinPermissions(perms domain.Permissions) string {
+	res := make([]string, 0, len(perms))
+	for _, p := range perms {
+		res = append(res, string(p))
+	}
+	return strings.Join(res, ",")
 }
-func (f *fakeJwt) RefreshTokens(refreshToken string) (*auth.JWTResponse, error) {
-	return &auth.JWTResponse{AccessToken: "access", RefreshToken: "refresh"}, nil
+
+func refreshToken(userID, jti, sessionID string) string {
+	return userID + ":" + jti + ":" + sessionID
+}
+
+// anyRefreshToken — валидный по формату refresh токен, которого нет в хранилище
+func anyRefreshToken(userID string) string {
+	return refreshToken(userID, uuid.NewString(), uuid.NewString())
 }
 
 func testCfg() *config.AppConfig {
@@ -65,19 +798,44 @@ func testCfg() *config.AppConfig {
 			RequireLower: true,
 			RequireDigit: true,
 		},
+		AuthConfig: config.AuthConfig{
+			SetupToken:       "setup-secret",
+			InvitationTTL:    time.Hour,
+			InvitationMaxTTL: 24 * time.Hour,
+			PasswordResetTTL: time.Hour,
+			TOTPIssuer:       "WarehouseControl",
+			TOTPWindow:       1,
+		},
+		LockoutConfig: config.LockoutConfig{
+			Window:           time.Minute,
+			LoginMaxFailures: 3,
+			LoginCooldown:    time.Hour,
+			IPMaxFailures:    5,
+			IPCooldown:       time.Hour,
+		},
+	}
+}
+
+// invite выпускает приглашение от имени произвольного админа и возвращает токен
+func invite(t *testing.T, svc *user.UserService, role string) string {
+	t.Helper()
+	_, token, err := svc.CreateInvitation(t.Context(), role, 0, uuid.NewString())
+	if err != nil {
+		t.Fatalf("cant create invitation: %v", err)
 	}
+	return token
 }
 
 func TestLogin_Success(t *testing.T) {
 	pass := "Password1"
 	hashed, _ := bcrypt.GenerateFromPassword([]byte(pass), bcrypt.DefaultCost)
-	u := &domain.User{Login: "user", Password: hashed}
+	u := &domain.User{Login: "user", Password: hashed, Role: domain.Viewer}
 
 	repo := &fakeRepo{users: map[string]*domain.User{"user": u}}
 	jwt := &fakeJwt{}
 	svc := user.NewUserService(repo, jwt, testCfg())
 
-	tokens, err := svc.Login("user", pass)
+	tokens, err := svc.Login(t.Context(), "user", pass, domain.Client{})
 	if err != nil {
 		t.Fatalf("unexpected error: %v", err)
 	}
@@ -91,22 +849,25 @@ func TestLogin_Errors(t *testing.T) {
 	jwt := &fakeJwt{}
 	svc := user.NewUserService(repo, jwt, testCfg())
 
-	if _, err := svc.Login("", "pass"); err == nil {
+	if _, err := svc.Login(t.Context(), "", "pass", domain.Client{}); err == nil {
 		t.Fatal("expected error for empty login")
 	}
 
-	if _, err := svc.Login("user", ""); err == nil {
+	if _, err := svc.Login(t.Context(), "user", "", domain.Client{}); err == nil {
 		t.Fatal("expected error for empty password")
 	}
 
-	if _, err := svc.Login("unknown", "pass"); err == nil {
+	if _, err := svc.Login(t.Context(), "unknown", "pass", domain.Client{}); err == nil {
 		t.Fatal("expected error for unknown user")
 	}
 
 	hashed, _ := bcrypt.GenerateFromPassword([]byte("Password1"), bcrypt.DefaultCost)
 	repo.users["user"] = &domain.User{Login: "user", Password: hashed}
-	if _, err := svc.Login("user", "wrongpass"); err == nil {
-		t.Fatal("expected error for wrong password")
+	if _, err := svc.Login(t.Context(), "user", "wrongpass", domain.Client{}); !errors.Is(err, domain.ErrInvalidCredentials) {
+		t.Fatalf("expected invalid credentials for wrong password, got %v", err)
+	}
+	if _, err := svc.Login(t.Context(), "unknown", "pass", domain.Client{}); !errors.Is(err, domain.ErrInvalidCredentials) {
+		t.Fatalf("expected the same error for unknown user, got %v", err)
 	}
 }
 
@@ -115,7 +876,7 @@ func TestRegistration_Success(t *testing.T) {
 	jwt := &fakeJwt{}
 	svc := user.NewUserService(repo, jwt, testCfg())
 
-	u, err := svc.Registration("valid", "Password1", "viewer")
+	u, err := svc.Registration(t.Context(), "valid", "Password1", invite(t, svc, "viewer"))
 	if err != nil {
 		t.Fatalf("unexpected error: %v", err)
 	}
@@ -129,19 +890,19 @@ func TestRegistration_Errors(t *testing.T) {
 	jwt := &fakeJwt{}
 	svc := user.NewUserService(repo, jwt, testCfg())
 
-	if _, err := svc.Registration("ab", "Password1", "viewer"); err == nil {
+	if _, err := svc.Registration(t.Context(), "ab", "Password1", invite(t, svc, "viewer")); err == nil {
 		t.Fatal("expected error for login too short")
 	}
 
-	if _, err := svc.Registration("bad$", "Password1", "viewer"); err == nil {
+	if _, err := svc.Registration(t.Context(), "bad$", "Password1", invite(t, svc, "viewer")); err == nil {
 		t.Fatal("expected error for invalid chars")
 	}
 
-	if _, err := svc.Registration("exist", "Password1", "viewer"); err == nil {
+	if _, err := svc.Registration(t.Context(), "exist", "Password1", invite(t, svc, "viewer")); err == nil {
 		t.Fatal("expected error for existing user")
 	}
 
-	if _, err := svc.Registration("newuser", "pass", "viewer"); err == nil {
+	if _, err := svc.Registration(t.Context(), "newuser", "pass", invite(t, svc, "viewer")); err == nil {
 		t.Fatal("expected error for invalid password")
 	}
 }
@@ -150,17 +911,17 @@ func TestIsValidLogin(t *testing.T) {
 	repo := &fakeRepo{}
 	svc := user.NewUserService(repo, &fakeJwt{}, testCfg())
 
-	_, err := svc.Registration("ab", "Password1", "viewer")
+	_, err := svc.Registration(t.Context(), "ab", "Password1", invite(t, svc, "viewer"))
 	if err == nil {
 		t.Fatal("expected error for short login")
 	}
 
-	_, err = svc.Registration("bad$", "Password1", "viewer")
+	_, err = svc.Registration(t.Context(), "bad$", "Password1", invite(t, svc, "viewer"))
 	if err == nil {
 		t.Fatal("expected error for invalid chars")
 	}
 
-	u, err := svc.Registration("good123", "Password1", "viewer")
+	u, err := svc.Registration(t.Context(), "good123", "Password1", invite(t, svc, "viewer"))
 	if err != nil {
 		t.Fatalf("unexpected error: %v", err)
 	}
@@ -187,7 +948,7 @@ func TestIsValidPassword(t *testing.T) {
 
 	for _, tt := range tests {
 
-		_, err := svc.Registration("user123", tt.pwd, "viewer")
+		_, err := svc.Registration(t.Context(), "user123", tt.pwd, invite(t, svc, "viewer"))
 		if tt.ok && err != nil && err.Error() != "user with this login already exists" {
 			t.Fatalf("expected success for pwd %s, got %v", tt.pwd, err)
 		}
@@ -197,16 +958,321 @@ func TestIsValidPassword(t *testing.T) {
 	}
 }
 
+func TestRegistration_RoleFromInvitation(t *testing.T) {
+	repo := &fakeRepo{}
+	svc := user.NewUserService(repo, &fakeJwt{}, testCfg())
+
+	u, err := svc.Registration(t.Context(), "manager1", "Password1", invite(t, svc, "manager"))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if u.Role != domain.Manager {
+		t.Fatalf("expected role from invitation, got %s", u.Role)
+	}
+}
+
+func TestRegistration_InvitationErrors(t *testing.T) {
+	repo := &fakeRepo{}
+	svc := user.NewUserService(repo, &fakeJwt{}, testCfg())
+
+	if _, err := svc.Registration(t.Context(), "newuser", "Password1", ""); !errors.Is(err, errs.ErrInvalidInput) {
+		t.Fatalf("expected invalid input without token, got %v", err)
+	}
+	if _, err := svc.Registration(t.Context(), "newuser", "Password1", "unknown"); !errors.Is(err, domain.ErrInvitationNotFound) {
+		t.Fatalf("expected invitation not found, got %v", err)
+	}
+	// без приглашения занятый логин не отличить от свободного
+	repo.users = map[string]*domain.User{"exist": {Login: "exist"}}
+	if _, err := svc.Registration(t.Context(), "exist", "Password1", "unknown"); !errors.Is(err, domain.ErrInvitationNotFound) {
+		t.Fatalf("expected invitation not found for taken login, got %v", err)
+	}
+	if _, err := svc.Registration(t.Context(), "bad$", "Password1", "unknown"); !errors.Is(err, domain.ErrInvitationNotFound) {
+		t.Fatalf("expected invitation not found for invalid login, got %v", err)
+	}
+
+	token := invite(t, svc, "viewer")
+	if _, err := svc.Registration(t.Context(), "first", "Password1", token); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if _, err := svc.Registration(t.Context(), "second", "Password1", token); !errors.Is(err, domain.ErrInvitationUsed) {
+		t.Fatalf("expected used invitation to be rejected, got %v", err)
+	}
+
+	expired := invite(t, svc, "viewer")
+	for _, inv := range repo.invitations {
+		if inv.UsedAt == nil {
+			inv.ExpiresAt = time.Now().Add(-time.Minute)
+		}
+	}
+	if _, err := svc.Registration(t.Context(), "third", "Password1", expired); !errors.Is(err, domain.ErrInvitationExpired) {
+		t.Fatalf("expected expired invitation to be rejected, got %v", err)
+	}
+}
+
+func TestCreateInvitation(t *testing.T) {
+	repo := &fakeRepo{}
+	svc := user.NewUserService(repo, &fakeJwt{}, testCfg())
+	admin := uuid.NewString()
+
+	inv, token, err := svc.CreateInvitation(t.Context(), "viewer", 0, admin)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if token == "" {
+		t.Fatal("expected token")
+	}
+	if ttl := inv.ExpiresAt.Sub(inv.CreatedAt); ttl != time.Hour {
+		t.Fatalf("expected default ttl from config, got %s", ttl)
+	}
+	if string(repo.invitations[string(inv.TokenHash)].TokenHash) == token {
+		t.Fatal("token must not be stored in plain text")
+	}
+
+	if _, _, err := svc.CreateInvitation(t.Context(), "viewer", 48*time.Hour, admin); !errors.Is(err, errs.ErrValidation) {
+		t.Fatalf("expected ttl above max to be rejected, got %v", err)
+	}
+	if _, _, err := svc.CreateInvitation(t.Context(), "root", 0, admin); !errors.Is(err, errs.ErrValidation) {
+		t.Fatalf("expected invalid role to be rejected, got %v", err)
+	}
+	if _, _, err := svc.CreateInvitation(t.Context(), "viewer", 0, "bad"); !errors.Is(err, errs.ErrInvalidInput) {
+		t.Fatalf("expected invalid creator id to be rejected, got %v", err)
+	}
+}
+
+func TestRevokeInvitation(t *testing.T) {
+	repo := &fakeRepo{}
+	svc := user.NewUserService(repo, &fakeJwt{}, testCfg())
+
+	inv, token, err := svc.CreateInvitation(t.Context(), "viewer", 0, uuid.NewString())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := svc.RevokeInvitation(t.Context(), inv.ID.String()); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if _, err := svc.Registration(t.Context(), "newuser", "Password1", token); !errors.Is(err, domain.ErrInvitationNotFound) {
+		t.Fatalf("expected revoked invitation to be unusable, got %v", err)
+	}
+	if err := svc.RevokeInvitation(t.Context(), "bad"); !errors.Is(err, errs.ErrInvalidInput) {
+		t.Fatalf("expected invalid input, got %v", err)
+	}
+}
+
+func TestBootstrap(t *testing.T) {
+	repo := &fakeRepo{}
+	cfg := testCfg()
+	svc := user.NewUserService(repo, &fakeJwt{}, cfg)
+
+	if _, err := svc.Bootstrap(t.Context(), "wrong", "admin", "Password1"); !errors.Is(err, errs.ErrUnauthorized) {
+		t.Fatalf("expected unauthorized for wrong setup token, got %v", err)
+	}
+
+	u, err := svc.Bootstrap(t.Context(), "setup-secret", "admin", "Password1")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if u.Role != domain.Admin {
+		t.Fatalf("expected admin role, got %s", u.Role)
+	}
+
+	if _, err := svc.Bootstrap(t.Context(), "setup-secret", "admin2", "Password1"); !errors.Is(err, domain.ErrAlreadyBootstrapped) {
+		t.Fatalf("expected second bootstrap to be rejected, got %v", err)
+	}
+
+	cfg.AuthConfig.SetupToken = ""
+	disabled := user.NewUserService(&fakeRepo{}, &fakeJwt{}, cfg)
+	if _, err := disabled.Bootstrap(t.Context(), "", "admin", "Password1"); !errors.Is(err, errs.ErrForbidden) {
+		t.Fatalf("expected bootstrap to be disabled without setup token, got %v", err)
+	}
+}
+
 func TestRefreshAndValidateTokens(t *testing.T) {
-	svc := user.NewUserService(nil, &fakeJwt{}, testCfg())
+	pass := "Password1"
+	hashed, _ := bcrypt.GenerateFromPassword([]byte(pass), bcrypt.DefaultCost)
+	u := &domain.User{Id: uuid.New(), Login: "user", Password: hashed, Role: domain.Viewer}
+	repo := &fakeRepo{users: map[string]*domain.User{"user": u}}
+	svc := user.NewUserService(repo, &fakeJwt{}, testCfg())
 
-	r, err := svc.RefreshTokens("refresh")
+	tokens, err := svc.Login(t.Context(), "user", pass, domain.Client{})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	r, err := svc.RefreshTokens(t.Context(), tokens.RefreshToken)
 	if err != nil || r.AccessToken == "" {
-		t.Fatal("expected valid refresh token response")
+		t.Fatalf("expected valid refresh token response, got %v", err)
 	}
 
-	v, err := svc.ValidateTokens("token")
+	v, err := svc.ValidateTokens(t.Context(), r.AccessToken)
 	if err != nil || v.UserID == "" {
 		t.Fatal("expected valid validate token response")
 	}
 }
+
+func TestRefreshTokens_RotationAndReuse(t *testing.T) {
+	pass := "Password1"
+	hashed, _ := bcrypt.GenerateFromPassword([]byte(pass), bcrypt.DefaultCost)
+	u := &domain.User{Id: uuid.New(), Login: "user", Password: hashed, Role: domain.Viewer}
+	repo := &fakeRepo{users: map[string]*domain.User{"user": u}}
+	svc := user.NewUserService(repo, &fakeJwt{}, testCfg())
+
+	login, _ := svc.Login(t.Context(), "user", pass, domain.Client{})
+	second, err := svc.RefreshTokens(t.Context(), login.RefreshToken)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if second.SessionID != login.SessionID || second.RefreshToken == login.RefreshToken {
+		t.Fatalf("expected rotated token in the same session")
+	}
+
+	// смена роли видна в следующей паре токенов
+	u.Role = domain.Manager
+	third, err := svc.RefreshTokens(t.Context(), second.RefreshToken)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	// повторное использование старого токена отзывает всю сессию
+	if _, err := svc.RefreshTokens(t.Context(), login.RefreshToken); !errors.Is(err, domain.ErrRefreshTokenReused) {
+		t.Fatalf("expected reuse detection, got %v", err)
+	}
+	if _, err := svc.RefreshTokens(t.Context(), third.RefreshToken); !errors.Is(err, domain.ErrRefreshTokenInvalid) {
+		t.Fatalf("expected latest token to be revoked with the session, got %v", err)
+	}
+}
+
+func TestRefreshTokens_UnknownOrDisabledUser(t *testing.T) {
+	now := time.Now()
+	u := &domain.User{Id: uuid.New(), Login: "user", Role: domain.Viewer, DisabledAt: &now}
+	repo := &fakeRepo{users: map[string]*domain.User{"user": u}}
+	svc := user.NewUserService(repo, &fakeJwt{}, testCfg())
+
+	if _, err := svc.RefreshTokens(t.Context(), anyRefreshToken(u.Id.String())); !errors.Is(err, domain.ErrDisabled) {
+		t.Fatalf("expected disabled user to be rejected, got %v", err)
+	}
+	if _, err := svc.RefreshTokens(t.Context(), anyRefreshToken(uuid.NewString())); !errors.Is(err, errs.ErrUnauthorized) {
+		t.Fatalf("expected unknown user to be unauthorized, got %v", err)
+	}
+	if _, err := svc.RefreshTokens(t.Context(), anyRefreshToken("not-a-uuid")); !errors.Is(err, errs.ErrUnauthorized) {
+		t.Fatalf("expected malformed subject to be unauthorized, got %v", err)
+	}
+	if _, err := svc.RefreshTokens(t.Context(), "garbage"); !errors.Is(err, domain.ErrRefreshTokenInvalid) {
+		t.Fatalf("expected invalid token, got %v", err)
+	}
+}
+
+func TestLogout(t *testing.T) {
+	pass := "Password1"
+	hashed, _ := bcrypt.GenerateFromPassword([]byte(pass), bcrypt.DefaultCost)
+	u := &domain.User{Id: uuid.New(), Login: "user", Password: hashed, Role: domain.Viewer}
+	repo := &fakeRepo{users: map[string]*domain.User{"user": u}}
+	svc := user.NewUserService(repo, &fakeJwt{}, testCfg())
+
+	phone, _ := svc.Login(t.Context(), "user", pass, domain.Client{})
+	laptop, _ := svc.Login(t.Context(), "user", pass, domain.Client{})
+
+	if err := svc.Logout(t.Context(), phone.RefreshToken, false); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if _, err := svc.RefreshTokens(t.Context(), phone.RefreshToken); !errors.Is(err, domain.ErrRefreshTokenInvalid) {
+		t.Fatalf("expected logged out token to be rejected, got %v", err)
+	}
+	next, err := svc.RefreshTokens(t.Context(), laptop.RefreshToken)
+	if err != nil {
+		t.Fatalf("expected other session to keep working, got %v", err)
+	}
+
+	if err := svc.Logout(t.Context(), next.RefreshToken, true); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if _, err := svc.RefreshTokens(t.Context(), next.RefreshToken); !errors.Is(err, domain.ErrRefreshTokenInvalid) {
+		t.Fatalf("expected all sessions to be logged out, got %v", err)
+	}
+	if err := svc.Logout(t.Context(), "garbage", false); !errors.Is(err, domain.ErrRefreshTokenInvalid) {
+		t.Fatalf("expected invalid token, got %v", err)
+	}
+}
+
+func TestValidateTokens_Session(t *testing.T) {
+	pass := "Password1"
+	hashed, _ := bcrypt.GenerateFromPassword([]byte(pass), bcrypt.DefaultCost)
+	u := &domain.User{Id: uuid.New(), Login: "user", Password: hashed, Role: domain.Viewer}
+	repo := &fakeRepo{users: map[string]*domain.User{"user": u}}
+	svc := user.NewUserService(repo, &fakeJwt{}, testCfg())
+
+	tokens, _ := svc.Login(t.Context(), "user", pass, domain.Client{UserAgent: "curl", IP: "10.0.0.1"})
+	v, err := svc.ValidateTokens(t.Context(), tokens.AccessToken)
+	if err != nil || v.SessionID != tokens.SessionID {
+		t.Fatalf("expected token of active session to be valid, got %v", err)
+	}
+	if repo.touched != 0 {
+		t.Fatalf("expected fresh session not to be touched")
+	}
+	repo.sessions[uuid.MustParse(tokens.SessionID)].LastUsedAt = time.Now().Add(-time.Hour)
+	if _, err := svc.ValidateTokens(t.Context(), tokens.AccessToken); err != nil || repo.touched != 1 {
+		t.Fatalf("expected stale session to be touched, got %v", err)
+	}
+
+	// токен без сессии и токен неизвестной сессии
+	if _, err := svc.ValidateTokens(t.Context(), refreshToken(u.Id.String(), uuid.NewString(), "")); !errors.Is(err, domain.ErrSessionRevoked) {
+		t.Fatalf("expected token without session to be rejected, got %v", err)
+	}
+	if _, err := svc.ValidateTokens(t.Context(), anyRefreshToken(u.Id.String())); !errors.Is(err, domain.ErrSessionRevoked) {
+		t.Fatalf("expected token of unknown session to be rejected, got %v", err)
+	}
+	// сессия другого пользователя
+	if _, err := svc.ValidateTokens(t.Context(), refreshToken(uuid.NewString(), uuid.NewString(), tokens.SessionID)); !errors.Is(err, domain.ErrSessionRevoked) {
+		t.Fatalf("expected foreign session to be rejected, got %v", err)
+	}
+
+	if err := svc.Logout(t.Context(), tokens.RefreshToken, false); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if _, err := svc.ValidateTokens(t.Context(), tokens.AccessToken); !errors.Is(err, domain.ErrSessionRevoked) {
+		t.Fatalf("expected access token to stop working after logout, got %v", err)
+	}
+}
+
+func TestSessions_ListAndRevoke(t *testing.T) {
+	pass := "Password1"
+	hashed, _ := bcrypt.GenerateFromPassword([]byte(pass), bcrypt.DefaultCost)
+	u := &domain.User{Id: uuid.New(), Login: "user", Password: hashed, Role: domain.Viewer}
+	other := &domain.User{Id: uuid.New(), Login: "other", Password: hashed, Role: domain.Viewer}
+	repo := &fakeRepo{users: map[string]*domain.User{"user": u, "other": other}}
+	svc := user.NewUserService(repo, &fakeJwt{}, testCfg())
+
+	phone, _ := svc.Login(t.Context(), "user", pass, domain.Client{UserAgent: "phone"})
+	laptop, _ := svc.Login(t.Context(), "user", pass, domain.Client{UserAgent: "laptop"})
+	foreign, _ := svc.Login(t.Context(), "other", pass, domain.Client{})
+
+	sessions, err := svc.GetSessions(t.Context(), u.Id.String())
+	if err != nil || len(sessions) != 2 {
+		t.Fatalf("expected 2 sessions, got %d (%v)", len(sessions), err)
+	}
+
+	// чужую сессию отозвать нельзя
+	if err := svc.RevokeSession(t.Context(), u.Id.String(), foreign.SessionID); !errors.Is(err, domain.ErrSessionNotFound) {
+		t.Fatalf("expected foreign session to be not found, got %v", err)
+	}
+	if err := svc.RevokeSession(t.Context(), u.Id.String(), "bad"); !errors.Is(err, errs.ErrInvalidInput) {
+		t.Fatalf("expected invalid input, got %v", err)
+	}
+	if err := svc.RevokeSession(t.Context(), u.Id.String(), phone.SessionID); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if _, err := svc.RefreshTokens(t.Context(), phone.RefreshToken); !errors.Is(err, domain.ErrRefreshTokenInvalid) {
+		t.Fatalf("expected refresh of revoked session to fail, got %v", err)
+	}
+	if _, err := svc.ValidateTokens(t.Context(), laptop.AccessToken); err != nil {
+		t.Fatalf("expected other session to stay valid, got %v", err)
+	}
+
+	if err := svc.RevokeSessions(t.Context(), u.Id.String()); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if _, err := svc.ValidateTokens(t.Context(), laptop.AccessToken); !errors.Is(err, domain.ErrSessionRevoked) {
+		t.Fatalf("expected all sessions to be revoked, got %v", err)
+	}
+	if _, err := svc.ValidateTokens(t.Context(), foreign.AccessToken); err != nil {
+		t.Fatalf("expected other user's session to stay valid, got %v", err)
+	}
+}
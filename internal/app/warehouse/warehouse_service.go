@@ -0,0 +1,222 @@
+package warehouse
+
+import (
+	"warehousecontrol/internal/domain/errs"
+	"warehousecontrol/internal/domain/warehouse"
+
+	"github.com/google/uuid"
+	wbzlog "github.com/wb-go/wbf/zlog"
+
+	"context"
+)
+
+type WarehouseService struct {
+	repo WarehouseStorageProvider
+}
+
+type WarehouseStorageProvider interface {
+	CreateWarehouse(ctx context.Context, w *warehouse.Warehouse) error
+	GetWarehouses(ctx context.Context) ([]*warehouse.Warehouse, error)
+	GetWarehouse(ctx context.Context, id string) (*warehouse.Warehouse, error)
+	PutWarehouse(ctx context.Context, w *warehouse.Warehouse) error
+	DeleteWarehouse(ctx context.Context, id string) error
+
+	CreateZone(ctx context.Context, z *warehouse.Zone) error
+	GetZone(ctx context.Context, id string) (*warehouse.Zone, error)
+	PutZone(ctx context.Context, z *warehouse.Zone) error
+	DeleteZone(ctx context.Context, id string) error
+
+	CreateLocation(ctx context.Context, l *warehouse.Location) error
+	GetLocation(ctx context.Context, id string) (*warehouse.Location, error)
+	PutLocation(ctx context.Context, l *warehouse.Location) error
+	DeleteLocation(ctx context.Context, id string) error
+}
+
+func NewWarehouseService(repo WarehouseStorageProvider) *WarehouseService {
+	return &WarehouseService{repo: repo}
+}
+
+func (s *WarehouseService) Create(ctx context.Context, name, address string) (*warehouse.Warehouse, error) {
+	w, err := warehouse.NewWarehouse(name, address)
+	if err != nil {
+		wbzlog.Logger.Warn().Err(err).Msg("cant create warehouse")
+		return nil, err
+	}
+	err = s.repo.CreateWarehouse(ctx, w)
+	if err != nil {
+		return nil, err
+	}
+	return w, nil
+}
+
+func (s *WarehouseService) GetWarehouses(ctx context.Context) ([]*warehouse.Warehouse, error) {
+	return s.repo.GetWarehouses(ctx)
+}
+
+func (s *WarehouseService) GetWarehouse(ctx context.Context, id string) (*warehouse.Warehouse, error) {
+	if err := validateUUID(id); err != nil {
+		return nil, err
+	}
+	return s.repo.GetWarehouse(ctx, id)
+}
+
+func (s *WarehouseService) PutWarehouse(ctx context.Context, id, name, address string) (*warehouse.Warehouse, error) {
+	if err := validateUUID(id); err != nil {
+		return nil, err
+	}
+	w, err := s.repo.GetWarehouse(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	err = w.ChangeWarehouse(name, address)
+	if err != nil {
+		wbzlog.Logger.Warn().Err(err).Msg("cant change warehouse")
+		return nil, err
+	}
+	err = s.repo.PutWarehouse(ctx, w)
+	if err != nil {
+		return nil, err
+	}
+	return w, nil
+}
+
+func (s *WarehouseService) DeleteWarehouse(ctx context.Context, id string) error {
+	if err := validateUUID(id); err != nil {
+		return err
+	}
+	return s.repo.DeleteWarehouse(ctx, id)
+}
+
+func (s *WarehouseService) CreateZone(ctx context.Context, warehouseID, name string) (*warehouse.Zone, error) {
+	if err := validateUUID(warehouseID); err != nil {
+		return nil, err
+	}
+	w, err := s.repo.GetWarehouse(ctx, warehouseID)
+	if err != nil {
+		return nil, err
+	}
+	z, err := warehouse.NewZone(w.ID, name)
+	if err != nil {
+		wbzlog.Logger.Warn().Err(err).Msg("cant create zone")
+		return nil, err
+	}
+	err = s.repo.CreateZone(ctx, z)
+	if err != nil {
+		return nil, err
+	}
+	return z, nil
+}
+
+func (s *WarehouseService) PutZone(ctx context.Context, warehouseID, zoneID, name string) (*warehouse.Zone, error) {
+	z, err := s.getZone(ctx, warehouseID, zoneID)
+	if err != nil {
+		return nil, err
+	}
+	err = z.ChangeZone(name)
+	if err != nil {
+		wbzlog.Logger.Warn().Err(err).Msg("cant change zone")
+		return nil, err
+	}
+	err = s.repo.PutZone(ctx, z)
+	if err != nil {
+		return nil, err
+	}
+	return z, nil
+}
+
+func (s *WarehouseService) DeleteZone(ctx context.Context, warehouseID, zoneID string) error {
+	z, err := s.getZone(ctx, warehouseID, zoneID)
+	if err != nil {
+		return err
+	}
+	return s.repo.DeleteZone(ctx, z.ID.String())
+}
+
+func (s *WarehouseService) CreateLocation(ctx context.Context, warehouseID, zoneID, code string) (*warehouse.Location, error) {
+	z, err := s.getZone(ctx, warehouseID, zoneID)
+	if err != nil {
+		return nil, err
+	}
+	l, err := warehouse.NewLocation(z.ID, code)
+	if err != nil {
+		wbzlog.Logger.Warn().Err(err).Msg("cant create location")
+		return nil, err
+	}
+	err = s.repo.CreateLocation(ctx, l)
+	if err != nil {
+		return nil, err
+	}
+	return l, nil
+}
+
+func (s *WarehouseService) PutLocation(ctx context.Context, warehouseID, zoneID, locationID, code string) (*warehouse.Location, error) {
+	l, err := s.getLocation(ctx, warehouseID, zoneID, locationID)
+	if err != nil {
+		return nil, err
+	}
+	err = l.ChangeLocation(code)
+	if err != nil {
+		wbzlog.Logger.Warn().Err(err).Msg("cant change location")
+		return nil, err
+	}
+	err = s.repo.PutLocation(ctx, l)
+	if err != nil {
+		return nil, err
+	}
+	return l, nil
+}
+
+func (s *WarehouseService) DeleteLocation(ctx context.Context, warehouseID, zoneID, locationID string) error {
+	l, err := s.getLocation(ctx, warehouseID, zoneID, locationID)
+	if err != nil {
+		return err
+	}
+	return s.repo.DeleteLocation(ctx, l.ID.String())
+}
+
+// getZone загружает зону и проверяет, что она принадлежит указанному складу
+func (s *WarehouseService) getZone(ctx context.Context, warehouseID, zoneID string) (*warehouse.Zone, error) {
+	if err := validateUUID(warehouseID); err != nil {
+		return nil, err
+	}
+	if err := validateUUID(zoneID); err != nil {
+		return nil, err
+	}
+	z, err := s.repo.GetZone(ctx, zoneID)
+	if err != nil {
+		return nil, err
+	}
+	if z.WarehouseID.String() != warehouseID {
+		wbzlog.Logger.Warn().Str("zone", zoneID).Str("warehouse", warehouseID).Msg("zone belongs to another warehouse")
+		return nil, warehouse.ErrZoneNotFound
+	}
+	return z, nil
+}
+
+func (s *WarehouseService) getLocation(ctx context.Context, warehouseID, zoneID, locationID string) (*warehouse.Location, error) {
+	z, err := s.getZone(ctx, warehouseID, zoneID)
+	if err != nil {
+		return nil, err
+	}
+	if err := validateUUID(locationID); err != nil {
+		return nil, err
+	}
+	l, err := s.repo.GetLocation(ctx, locationID)
+	if err != nil {
+		return nil, err
+	}
+	if l.ZoneID != z.ID {
+		wbzlog.Logger.Warn().Str("location", locationID).Str("zone", zoneID).Msg("location belongs to another zone")
+		return nil, warehouse.ErrLocationNotFound
+	}
+	return l, nil
+}
+
+func validateUUID(id string) error {
+	_, err := uuid.Parse(id)
+	if err != nil {
+		wbzlog.Logger.Warn().Err(err).Msg("invalid UUID format")
+		return errs.Errorf(errs.ErrInvalidInput, "invalid UUID format: %v", err)
+	}
+	return nil
+}
@@ -0,0 +1,195 @@
+package warehouse_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"warehousecontrol/internal/app/warehouse"
+	domain "warehousecontrol/internal/domain/warehouse"
+
+	"github.com/google/uuid"
+)
+
+type fakeRepo struct {
+	warehouses map[string]*domain.Warehouse
+	zones      map[string]*domain.Zone
+	locations  map[string]*domain.Location
+
+	deletedZone     string
+	deletedLocation string
+	err             error
+}
+
+func newFakeRepo() *fakeRepo {
+	return &fakeRepo{
+		warehouses: map[string]*domain.Warehouse{},
+		zones:      map[string]*domain.Zone{},
+		locations:  map[string]*domain.Location{},
+	}
+}
+
+func (f *fakeRepo) CreateWarehouse(_ context.Context, w *domain.Warehouse) error {
+	if f.err != nil {
+		return f.err
+	}
+	f.warehouses[w.ID.String()] = w
+	return nil
+}
+func (f *fakeRepo) GetWarehouses(_ context.Context) ([]*domain.Warehouse, error) {
+	res := []*domain.Warehouse{}
+	for _, w := range f.warehouses {
+		res = append(res, w)
+	}
+	return res, f.err
+}
+func (f *fakeRepo) GetWarehouse(_ context.Context, id string) (*domain.Warehouse, error) {
+	w, ok := f.warehouses[id]
+	if !ok {
+		return nil, domain.ErrWarehouseNotFound
+	}
+	return w, nil
+}
+func (f *fakeRepo) PutWarehouse(_ context.Context, w *domain.Warehouse) error { return f.err }
+func (f *fakeRepo) DeleteWarehouse(_ context.Context, id string) error        { return f.err }
+func (f *fakeRepo) CreateZone(_ context.Context, z *domain.Zone) error {
+	f.zones[z.ID.String()] = z
+	return f.err
+}
+func (f *fakeRepo) GetZone(_ context.Context, id string) (*domain.Zone, error) {
+	z, ok := f.zones[id]
+	if !ok {
+		return nil, domain.ErrZoneNotFound
+	}
+	return z, nil
+}
+func (f *fakeRepo) PutZone(_ context.Context, z *domain.Zone) error { return f.err }
+func (f *fakeRepo) DeleteZone(_ context.Context, id string) error {
+	f.deletedZone = id
+	return f.err
+}
+func (f *fakeRepo) CreateLocation(_ context.Context, l *domain.Location) error {
+	f.locations[l.ID.String()] = l
+	return f.err
+}
+func (f *fakeRepo) GetLocation(_ context.Context, id string) (*domain.Location, error) {
+	l, ok := f.locations[id]
+	if !ok {
+		return nil, domain.ErrLocationNotFound
+	}
+	return l, nil
+}
+func (f *fakeRepo) PutLocation(_ context.Context, l *domain.Location) error { return f.err }
+func (f *fakeRepo) DeleteLocation(_ context.Context, id string) error {
+	f.deletedLocation = id
+	return f.err
+}
+
+func TestCreate_Success(t *testing.T) {
+	repo := newFakeRepo()
+	svc := warehouse.NewWarehouseService(repo)
+
+	w, err := svc.Create(t.Context(), "Main", "Moscow")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if _, ok := repo.warehouses[w.ID.String()]; !ok {
+		t.Fatalf("expected warehouse to be saved")
+	}
+}
+
+func TestCreate_InvalidName(t *testing.T) {
+	svc := warehouse.NewWarehouseService(newFakeRepo())
+	if _, err := svc.Create(t.Context(), "", "Moscow"); err == nil {
+		t.Fatalf("expected validation error")
+	}
+}
+
+func TestGetWarehouse_InvalidUUID(t *testing.T) {
+	svc := warehouse.NewWarehouseService(newFakeRepo())
+	if _, err := svc.GetWarehouse(t.Context(), "bad"); err == nil {
+		t.Fatalf("expected uuid error")
+	}
+}
+
+func TestPutWarehouse_Success(t *testing.T) {
+	repo := newFakeRepo()
+	svc := warehouse.NewWarehouseService(repo)
+	w, _ := svc.Create(t.Context(), "Main", "")
+
+	res, err := svc.PutWarehouse(t.Context(), w.ID.String(), "Second", "Kazan")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if res.Name != "Second" || res.Address != "Kazan" {
+		t.Fatalf("unexpected warehouse: %+v", res)
+	}
+}
+
+func TestCreateZone_UnknownWarehouse(t *testing.T) {
+	svc := warehouse.NewWarehouseService(newFakeRepo())
+	if _, err := svc.CreateZone(t.Context(), uuid.NewString(), "A"); err == nil {
+		t.Fatalf("expected not found error")
+	}
+}
+
+func TestZoneAndLocation_Lifecycle(t *testing.T) {
+	repo := newFakeRepo()
+	svc := warehouse.NewWarehouseService(repo)
+	w, _ := svc.Create(t.Context(), "Main", "")
+
+	z, err := svc.CreateZone(t.Context(), w.ID.String(), "A")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	l, err := svc.CreateLocation(t.Context(), w.ID.String(), z.ID.String(), "A-01")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if l.ZoneID != z.ID {
+		t.Fatalf("expected location to belong to zone")
+	}
+
+	if _, err := svc.PutLocation(t.Context(), w.ID.String(), z.ID.String(), l.ID.String(), "A-02"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := svc.DeleteLocation(t.Context(), w.ID.String(), z.ID.String(), l.ID.String()); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if repo.deletedLocation != l.ID.String() {
+		t.Fatalf("expected DeleteLocation to be called")
+	}
+	if err := svc.DeleteZone(t.Context(), w.ID.String(), z.ID.String()); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if repo.deletedZone != z.ID.String() {
+		t.Fatalf("expected DeleteZone to be called")
+	}
+}
+
+func TestZone_WrongWarehouse(t *testing.T) {
+	repo := newFakeRepo()
+	svc := warehouse.NewWarehouseService(repo)
+	w, _ := svc.Create(t.Context(), "Main", "")
+	z, _ := svc.CreateZone(t.Context(), w.ID.String(), "A")
+
+	if _, err := svc.PutZone(t.Context(), uuid.NewString(), z.ID.String(), "B"); !errors.Is(err, domain.ErrZoneNotFound) {
+		t.Fatalf("expected zone not found for zone of another warehouse, got %v", err)
+	}
+	if _, err := svc.CreateLocation(t.Context(), uuid.NewString(), z.ID.String(), "A-01"); err == nil {
+		t.Fatalf("expected error for zone of another warehouse")
+	}
+}
+
+func TestLocation_WrongZone(t *testing.T) {
+	repo := newFakeRepo()
+	svc := warehouse.NewWarehouseService(repo)
+	w, _ := svc.Create(t.Context(), "Main", "")
+	z1, _ := svc.CreateZone(t.Context(), w.ID.String(), "A")
+	z2, _ := svc.CreateZone(t.Context(), w.ID.String(), "B")
+	l, _ := svc.CreateLocation(t.Context(), w.ID.String(), z1.ID.String(), "A-01")
+
+	if err := svc.DeleteLocation(t.Context(), w.ID.String(), z2.ID.String(), l.ID.String()); err == nil {
+		t.Fatalf("expected error for location of another zone")
+	}
+}
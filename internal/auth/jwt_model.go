@@ -1,6 +1,8 @@
 package auth
 
 import (
+	"time"
+
 	"warehousecontrol/internal/domain/user"
 )
 
@@ -10,10 +12,47 @@ type JWTResponse struct {
 	AccessExpiresIn  int64
 	RefreshExpiresIn int64
 	TokenType        string
+	// RefreshTokenID и SessionID нужны, чтобы сохранить refresh токен для ротации
+	RefreshTokenID   string
+	SessionID        string
+	RefreshExpiresAt time.Time
 }
 
 type JWTPayload struct {
 	UserID string
 	Role   user.Role
 	Login  string
+	// SessionID есть в обоих токенах, TokenID — только в refresh
+	SessionID string
+	TokenID   string
+	// Permissions — права роли на момент выпуска access токена, RoleVersion — версия роли
+	Permissions user.Permissions
+	RoleVersion int
+}
+
+// LoginResponse — итог проверки пароля: пара токенов или, если нужен второй фактор,
+// токен второго шага входа
+type LoginResponse struct {
+	*JWTResponse
+	MFA *MFAChallenge
+}
+
+type MFAChallenge struct {
+	Token     string
+	ExpiresAt time.Time
+	// Enrollment — второй фактор обязателен, но ещё не подключён: его нужно настроить
+	// в рамках входа
+	Enrollment bool
+}
+
+// JWK — открытый ключ подписи в формате RFC 7517: N и E заполнены у RSA, Curve и X — у Ed25519
+type JWK struct {
+	KeyType   string
+	KeyID     string
+	Use       string
+	Algorithm string
+	N         string
+	E         string
+	Curve     string
+	X         string
 }
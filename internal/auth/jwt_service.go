@@ -11,44 +11,84 @@ import (
 	"github.com/google/uuid"
 )
 
+const (
+	defaultIssuer   = "warehousecontrol"
+	defaultAudience = "warehousecontrol-api"
+	// defaultMFATokenTTL — срок токена второго шага входа, если mfa_token_ttl не задан
+	defaultMFATokenTTL = 5 * time.Minute
+)
+
 type JWTService struct {
-	accessSecret       string
-	refreshSecret      string
+	keys               *keySet
+	issuer             string
+	audience           string
 	jwtExpAccessToken  int // в минутах
 	jwtExpRefreshToken int // в часах
+	mfaTokenTTL        time.Duration
 }
 
-// Конструктор
-func NewJWTService(cfg *config.AppConfig) *JWTService {
-	return &JWTService{
-		accessSecret:       cfg.JwtConfig.JwtAccessSecret,
-		refreshSecret:      cfg.JwtConfig.JwtRefreshSecret,
+// Конструктор. Ключи читаются при старте: ошибка в них не даёт запустить сервис.
+func NewJWTService(cfg *config.AppConfig) (*JWTService, error) {
+	keys, err := newKeySet(cfg.JwtConfig)
+	if err != nil {
+		return nil, err
+	}
+	s := &JWTService{
+		keys:               keys,
+		issuer:             cfg.JwtConfig.Issuer,
+		audience:           cfg.JwtConfig.Audience,
 		jwtExpAccessToken:  cfg.JwtConfig.JwtExpAccessToken,
 		jwtExpRefreshToken: cfg.JwtConfig.JwtExpRefreshToken,
+		mfaTokenTTL:        cfg.AuthConfig.MFATokenTTL,
+	}
+	if s.issuer == "" {
+		s.issuer = defaultIssuer
+	}
+	if s.audience == "" {
+		s.audience = defaultAudience
+	}
+	if s.mfaTokenTTL <= 0 {
+		s.mfaTokenTTL = defaultMFATokenTTL
 	}
+	return s, nil
 }
 
-// Генерация пары токенов
-func (s *JWTService) GenerateTokens(u *user.User) (*JWTResponse, error) {
-	access, err := s.generateAccessToken(u)
+// JWKS — открытые ключи для проверки access токенов другими сервисами
+func (s *JWTService) JWKS() []JWK {
+	return s.keys.jwks(time.Now())
+}
+
+// Генерация пары токенов в рамках сессии. Оба токена несут идентификатор сессии,
+// refresh токен — ещё и свой jti, access — права роли и её версию. Пустой sessionID
+// начинает новую сессию (вход).
+func (s *JWTService) GenerateTokens(u *user.User, role *user.RoleDefinition, sessionID string) (*JWTResponse, error) {
+	jti := uuid.NewString()
+	if sessionID == "" {
+		sessionID = jti
+	}
+	access, err := s.generateAccessToken(u, role, sessionID)
 	if err != nil {
 		return nil, err
 	}
-	refresh, err := s.generateRefreshToken(u)
+	expiresAt := time.Now().Add(time.Hour * time.Duration(s.jwtExpRefreshToken))
+	refresh, err := s.generateRefreshToken(u, jti, sessionID, expiresAt)
 	if err != nil {
 		return nil, err
 	}
 	return &JWTResponse{
-		AccessToken:  access,
-		RefreshToken: refresh,
+		AccessToken:      access,
+		RefreshToken:     refresh,
+		RefreshTokenID:   jti,
+		SessionID:        sessionID,
+		RefreshExpiresAt: expiresAt,
 	}, nil
 }
 
 // Проверка токена (возвращаем полезную нагрузку)
 func (s *JWTService) ValidateTokens(tokenStr string) (*JWTPayload, error) {
-	claims, err := s.validateAccessToken(tokenStr)
+	claims, err := s.parse(tokenStr, accessTokenType, s.audience)
 	if err != nil {
-		return nil, err
+		return nil, errors.New("invalid access token")
 	}
 
 	uuidStr, ok := claims["uuid"].(string)
@@ -63,18 +103,34 @@ func (s *JWTService) ValidateTokens(tokenStr string) (*JWTPayload, error) {
 	if !ok {
 		return nil, errors.New("invalid token payload")
 	}
+	// sid может отсутствовать у старых токенов — такие отклоняет сервис пользователей
+	sid, _ := claims["sid"].(string)
+	// у токенов, выпущенных до появления прав, их нет: версия 0 не совпадёт с версией роли,
+	// и сервис пользователей подставит актуальные права
+	rv, _ := claims["rv"].(float64)
+	perms, _ := claims["perms"].([]interface{})
+	permissions := make(user.Permissions, 0, len(perms))
+	for _, p := range perms {
+		if str, ok := p.(string); ok {
+			permissions = append(permissions, user.Permission(str))
+		}
+	}
 	return &JWTPayload{
-		UserID: uuidStr,
-		Role:   user.Role(role),
-		Login:  login,
+		UserID:      uuidStr,
+		Role:        user.Role(role),
+		Login:       login,
+		SessionID:   sid,
+		Permissions: permissions,
+		RoleVersion: int(rv),
 	}, nil
 }
 
-// Обновление токенов по refresh
-func (s *JWTService) RefreshTokens(refreshToken string) (*JWTResponse, error) {
-	claims, err := s.validateRefreshToken(refreshToken)
+// Проверка подписи и срока refresh токена. Выпуск новой пары — в сервисе пользователей:
+// он сверяет токен с базой (ротация, отзыв) и берёт актуальные данные пользователя.
+func (s *JWTService) ParseRefreshToken(refreshToken string) (*JWTPayload, error) {
+	claims, err := s.parse(refreshToken, refreshTokenType, s.issuer)
 	if err != nil {
-		return nil, err
+		return nil, errors.New("invalid refresh token")
 	}
 
 	uuidStr, ok := claims["uuid"].(string)
@@ -92,77 +148,139 @@ func (s *JWTService) RefreshTokens(refreshToken string) (*JWTResponse, error) {
 		return nil, errors.New("invalid refresh token payload")
 	}
 
-	// Создаём "фейкового" пользователя только для генерации токенов
-	u := &user.User{
-		Id:    uuid.MustParse(uuidStr),
-		Role:  user.Role(role),
-		Login: login,
+	jti, ok := claims["jti"].(string)
+	if !ok {
+		return nil, errors.New("invalid refresh token payload")
+	}
+
+	// сессия хранится в claim "sid", как в access токене; "fam" — прежнее имя семейства
+	// токенов в refresh токенах, выданных до переименования, они продолжают работать
+	sid, ok := claims["sid"].(string)
+	if !ok {
+		sid, ok = claims["fam"].(string)
+	}
+	if !ok {
+		return nil, errors.New("invalid refresh token payload")
+	}
+
+	return &JWTPayload{
+		UserID:    uuidStr,
+		Role:      user.Role(role),
+		Login:     login,
+		TokenID:   jti,
+		SessionID: sid,
+	}, nil
+}
+
+// GenerateMFAToken выпускает короткоживущий токен второго шага входа. Он подтверждает
+// только проверенный пароль: без логина, роли и сессии как access токен он не принимается.
+func (s *JWTService) GenerateMFAToken(u *user.User) (string, time.Time, error) {
+	expiresAt := time.Now().Add(s.mfaTokenTTL)
+	claims := jwt.MapClaims{
+		"uuid": u.Id.String(),
+	}
+	token, err := s.sign(claims, mfaTokenType, s.issuer, uuid.NewString(), expiresAt)
+	if err != nil {
+		return "", time.Time{}, err
 	}
+	return token, expiresAt, nil
+}
 
-	// Генерируем новую пару токенов
-	return s.GenerateTokens(u)
+// ParseMFAToken проверяет токен второго шага и возвращает идентификатор пользователя
+func (s *JWTService) ParseMFAToken(tokenStr string) (string, error) {
+	claims, err := s.parse(tokenStr, mfaTokenType, s.issuer)
+	if err != nil {
+		return "", errors.New("invalid two-factor login token")
+	}
+	uuidStr, ok := claims["uuid"].(string)
+	if !ok {
+		return "", errors.New("invalid token payload")
+	}
+	return uuidStr, nil
 }
 
 //// Вспомогательные приватные методы
 
-func (s *JWTService) generateAccessToken(u *user.User) (string, error) {
+// Тип токена в claim "typ": токен одного типа не принимается вместо другого
+const (
+	accessTokenType  = "access"
+	refreshTokenType = "refresh"
+	mfaTokenType     = "mfa"
+)
+
+func (s *JWTService) generateAccessToken(u *user.User, role *user.RoleDefinition, sessionID string) (string, error) {
 	claims := jwt.MapClaims{
 		"uuid":  u.Id.String(),
 		"role":  u.Role,
 		"login": u.Login,
-		"exp":   time.Now().Add(time.Minute * time.Duration(s.jwtExpAccessToken)).Unix(),
+		"sid":   sessionID,
+		"perms": role.Permissions,
+		"rv":    role.Version,
 	}
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	return token.SignedString([]byte(s.accessSecret))
+	expiresAt := time.Now().Add(time.Minute * time.Duration(s.jwtExpAccessToken))
+	return s.sign(claims, accessTokenType, s.audience, uuid.NewString(), expiresAt)
 }
 
-func (s *JWTService) generateRefreshToken(u *user.User) (string, error) {
+// refresh токен и токен второго шага принимает только сам сервис, поэтому их aud — issuer
+func (s *JWTService) generateRefreshToken(u *user.User, jti, sessionID string, expiresAt time.Time) (string, error) {
 	claims := jwt.MapClaims{
 		"uuid":  u.Id.String(),
 		"role":  u.Role,
 		"login": u.Login,
-		"exp":   time.Now().Add(time.Hour * time.Duration(s.jwtExpRefreshToken)).Unix(),
+		"sid":   sessionID,
 	}
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	return token.SignedString([]byte(s.refreshSecret))
+	return s.sign(claims, refreshTokenType, s.issuer, jti, expiresAt)
 }
 
-func (s *JWTService) validateAccessToken(tokenStr string) (jwt.MapClaims, error) {
-	token, err := jwt.Parse(tokenStr, func(token *jwt.Token) (interface{}, error) {
-		return []byte(s.accessSecret), nil
-	})
-	if err != nil || !token.Valid {
-		return nil, errors.New("invalid access token")
-	}
-
-	claims, ok := token.Claims.(jwt.MapClaims)
-	if !ok {
-		return nil, errors.New("invalid claims")
-	}
-
-	if exp, ok := claims["exp"].(float64); !ok || time.Unix(int64(exp), 0).Before(time.Now()) {
-		return nil, errors.New("token has expired")
+// sign дополняет claims стандартными iss, aud, iat, exp, jti и типом токена и
+// подписывает текущим ключом, указывая его kid в заголовке
+func (s *JWTService) sign(claims jwt.MapClaims, typ, audience, jti string, expiresAt time.Time) (string, error) {
+	now := time.Now()
+	key, err := s.keys.signer(now)
+	if err != nil {
+		return "", err
 	}
-
-	return claims, nil
+	claims["iss"] = s.issuer
+	claims["aud"] = []string{audience}
+	claims["iat"] = now.Unix()
+	claims["exp"] = expiresAt.Unix()
+	claims["jti"] = jti
+	claims["typ"] = typ
+	token := jwt.NewWithClaims(key.method, claims)
+	token.Header["kid"] = key.kid
+	return token.SignedString(key.private)
 }
 
-func (s *JWTService) validateRefreshToken(tokenStr string) (jwt.MapClaims, error) {
-	token, err := jwt.Parse(tokenStr, func(token *jwt.Token) (interface{}, error) {
-		return []byte(s.refreshSecret), nil
-	})
-	if err != nil || !token.Valid {
-		return nil, errors.New("invalid refresh token")
+// parse проверяет подпись по kid, срок, издателя, получателя, наличие iat и jti и тип токена
+func (s *JWTService) parse(tokenStr, typ, audience string) (jwt.MapClaims, error) {
+	now := time.Now()
+	token, err := jwt.Parse(tokenStr, s.keys.keyFunc(now),
+		jwt.WithValidMethods(s.keys.methods),
+		jwt.WithIssuer(s.issuer),
+		jwt.WithAudience(audience),
+		jwt.WithExpirationRequired(),
+		jwt.WithIssuedAt(),
+		jwt.WithTimeFunc(func() time.Time { return now }),
+	)
+	if err != nil {
+		return nil, err
+	}
+	if !token.Valid {
+		return nil, errors.New("invalid token")
 	}
 
 	claims, ok := token.Claims.(jwt.MapClaims)
 	if !ok {
 		return nil, errors.New("invalid claims")
 	}
-
-	if exp, ok := claims["exp"].(float64); !ok || time.Unix(int64(exp), 0).Before(time.Now()) {
-		return nil, errors.New("refresh token has expired")
+	if _, ok := claims["iat"].(float64); !ok {
+		return nil, errors.New("token has no iat")
+	}
+	if jti, _ := claims["jti"].(string); jti == "" {
+		return nil, errors.New("token has no jti")
+	}
+	if t, _ := claims["typ"].(string); t != typ {
+		return nil, errors.New("unexpected token type")
 	}
-
 	return claims, nil
 }
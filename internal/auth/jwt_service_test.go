@@ -16,17 +16,39 @@ func newTestConfig() *config.AppConfig {
 	return &config.AppConfig{
 		JwtConfig: config.JwtConfig{
 			JwtAccessSecret:    "access-secret",
-			JwtRefreshSecret:   "refresh-secret",
 			JwtExpAccessToken:  1, // 1 минута
 			JwtExpRefreshToken: 1, // 1 час
 		},
+		AuthConfig: config.AuthConfig{
+			MFATokenTTL: time.Minute,
+		},
 	}
 }
 
 func newTestJWT() *auth.JWTService {
-	return auth.NewJWTService(newTestConfig())
+	s, err := auth.NewJWTService(newTestConfig())
+	if err != nil {
+		panic(err)
+	}
+	return s
+}
+
+// signHS256 подписывает claims тестовым секретом, дополняя их стандартными claims
+// токена указанного типа: так проверяется отсутствие именно полезной нагрузки
+func signHS256(claims jwt.MapClaims, typ, aud string) string {
+	claims["iss"] = "warehousecontrol"
+	claims["aud"] = aud
+	claims["iat"] = time.Now().Unix()
+	claims["jti"] = uuid.NewString()
+	claims["typ"] = typ
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
+	token.Header["kid"] = "hs256"
+	tokenStr, _ := token.SignedString([]byte("access-secret"))
+	return tokenStr
 }
 
+var testRole = &user.RoleDefinition{Name: user.Admin, Permissions: user.Permissions{user.PermItemsRead, user.PermUsersManage}, Version: 3}
+
 func newTestUser() *user.User {
 	return &user.User{
 		Id:    uuid.New(),
@@ -39,7 +61,7 @@ func TestGenerateTokens_Success(t *testing.T) {
 	s := newTestJWT()
 	u := newTestUser()
 
-	resp, err := s.GenerateTokens(u)
+	resp, err := s.GenerateTokens(u, testRole, "")
 	if err != nil {
 		t.Fatalf("expected no error, got %v", err)
 	}
@@ -53,7 +75,7 @@ func TestValidateTokens_Success(t *testing.T) {
 	s := newTestJWT()
 	u := newTestUser()
 
-	tokens, _ := s.GenerateTokens(u)
+	tokens, _ := s.GenerateTokens(u, testRole, "")
 
 	payload, err := s.ValidateTokens(tokens.AccessToken)
 	if err != nil {
@@ -69,6 +91,9 @@ func TestValidateTokens_Success(t *testing.T) {
 	if payload.Role != u.Role {
 		t.Fatalf("invalid payload role")
 	}
+	if payload.RoleVersion != 3 || len(payload.Permissions) != 2 || !payload.Permissions.Has(user.PermUsersManage) {
+		t.Fatalf("invalid payload permissions: %v (version %d)", payload.Permissions, payload.RoleVersion)
+	}
 }
 
 func TestValidateTokens_InvalidToken(t *testing.T) {
@@ -89,8 +114,7 @@ func TestValidateTokens_NoUUIDField(t *testing.T) {
 		"exp":   time.Now().Add(time.Minute).Unix(),
 	}
 
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	tokenStr, _ := token.SignedString([]byte("access-secret"))
+	tokenStr := signHS256(claims, "access", "warehousecontrol-api")
 
 	_, err := s.ValidateTokens(tokenStr)
 	if err == nil {
@@ -106,8 +130,7 @@ func TestValidateTokens_NoLoginOrRole(t *testing.T) {
 		"exp":  time.Now().Add(time.Minute).Unix(),
 	}
 
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	tokenStr, _ := token.SignedString([]byte("access-secret"))
+	tokenStr := signHS256(claims, "access", "warehousecontrol-api")
 
 	_, err := s.ValidateTokens(tokenStr)
 	if err == nil {
@@ -115,32 +138,115 @@ func TestValidateTokens_NoLoginOrRole(t *testing.T) {
 	}
 }
 
-func TestRefreshTokens_Success(t *testing.T) {
+func TestGenerateTokens_Session(t *testing.T) {
+	s := newTestJWT()
+	u := newTestUser()
+
+	first, _ := s.GenerateTokens(u, testRole, "")
+	if first.RefreshTokenID == "" || first.SessionID != first.RefreshTokenID {
+		t.Fatalf("expected new session to start with the first token, got %+v", first)
+	}
+	if first.RefreshExpiresAt.Before(time.Now()) {
+		t.Fatal("expected refresh expiry in the future")
+	}
+
+	next, _ := s.GenerateTokens(u, testRole, first.SessionID)
+	if next.SessionID != first.SessionID || next.RefreshTokenID == first.RefreshTokenID {
+		t.Fatalf("expected rotated token in the same session, got %+v", next)
+	}
+
+	payload, err := s.ParseRefreshToken(next.RefreshToken)
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if payload.TokenID != next.RefreshTokenID || payload.SessionID != first.SessionID {
+		t.Fatalf("unexpected payload: %+v", payload)
+	}
+
+	access, err := s.ValidateTokens(next.AccessToken)
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if access.SessionID != first.SessionID {
+		t.Fatalf("expected access token to carry session, got %+v", access)
+	}
+}
+
+func TestMFAToken(t *testing.T) {
+	s := newTestJWT()
+	u := newTestUser()
+
+	token, expiresAt, err := s.GenerateMFAToken(u)
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if !expiresAt.After(time.Now()) {
+		t.Fatal("expected mfa token expiry in the future")
+	}
+	userID, err := s.ParseMFAToken(token)
+	if err != nil || userID != u.Id.String() {
+		t.Fatalf("unexpected result: %q, %v", userID, err)
+	}
+
+	// токен второго шага не заменяет access токен, и наоборот
+	if _, err := s.ValidateTokens(token); err == nil {
+		t.Fatal("expected mfa token to be rejected as access token")
+	}
+	pair, _ := s.GenerateTokens(u, testRole, "")
+	if _, err := s.ParseMFAToken(pair.AccessToken); err == nil {
+		t.Fatal("expected access token to be rejected as mfa token")
+	}
+}
+
+func TestMFAToken_DefaultTTL(t *testing.T) {
+	cfg := newTestConfig()
+	cfg.AuthConfig.MFATokenTTL = 0
+	s, err := auth.NewJWTService(cfg)
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	token, expiresAt, err := s.GenerateMFAToken(newTestUser())
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if expiresAt.Before(time.Now().Add(4 * time.Minute)) {
+		t.Fatalf("expected default ttl, got expiry %v", expiresAt)
+	}
+	if _, err := s.ParseMFAToken(token); err != nil {
+		t.Fatalf("expected token to be valid, got %v", err)
+	}
+}
+
+func TestParseRefreshToken(t *testing.T) {
 	s := newTestJWT()
 	u := newTestUser()
 
-	tokens, _ := s.GenerateTokens(u)
+	tokens, _ := s.GenerateTokens(u, testRole, "")
 
-	newTokens, err := s.RefreshTokens(tokens.RefreshToken)
+	payload, err := s.ParseRefreshToken(tokens.RefreshToken)
 	if err != nil {
 		t.Fatalf("expected no error, got %v", err)
 	}
+	if payload.UserID != u.Id.String() || payload.Login != u.Login || payload.Role != u.Role {
+		t.Fatalf("unexpected payload: %+v", payload)
+	}
 
-	if newTokens.AccessToken == "" || newTokens.RefreshToken == "" {
-		t.Fatal("new tokens must not be empty")
+	if _, err := s.ParseRefreshToken(tokens.AccessToken); err == nil {
+		t.Fatal("expected access token to be rejected as refresh token")
 	}
 }
 
-func TestRefreshTokens_Invalid(t *testing.T) {
+func TestParseRefreshToken_Invalid(t *testing.T) {
 	s := newTestJWT()
 
-	_, err := s.RefreshTokens("invalid_refresh_token")
+	_, err := s.ParseRefreshToken("invalid_refresh_token")
 	if err == nil {
 		t.Fatal("expected error for invalid refresh token")
 	}
 }
 
-func TestRefreshTokens_NoUUIDField(t *testing.T) {
+func TestParseRefreshToken_NoUUIDField(t *testing.T) {
 	s := newTestJWT()
 
 	claims := jwt.MapClaims{
@@ -149,16 +255,15 @@ func TestRefreshTokens_NoUUIDField(t *testing.T) {
 		"exp":   time.Now().Add(time.Hour).Unix(),
 	}
 
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	tokenStr, _ := token.SignedString([]byte("refresh-secret"))
+	tokenStr := signHS256(claims, "refresh", "warehousecontrol")
 
-	_, err := s.RefreshTokens(tokenStr)
+	_, err := s.ParseRefreshToken(tokenStr)
 	if err == nil {
 		t.Fatal("expected error because uuid missing")
 	}
 }
 
-func TestRefreshTokens_NoLoginOrRole(t *testing.T) {
+func TestParseRefreshToken_NoLoginOrRole(t *testing.T) {
 	s := newTestJWT()
 
 	claims := jwt.MapClaims{
@@ -166,30 +271,88 @@ func TestRefreshTokens_NoLoginOrRole(t *testing.T) {
 		"exp":  time.Now().Add(time.Hour).Unix(),
 	}
 
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	tokenStr, _ := token.SignedString([]byte("refresh-secret"))
+	tokenStr := signHS256(claims, "refresh", "warehousecontrol")
 
-	_, err := s.RefreshTokens(tokenStr)
+	_, err := s.ParseRefreshToken(tokenStr)
 	if err == nil {
 		t.Fatal("expected error because login/role missing")
 	}
 }
 
+func TestParseRefreshToken_NoTokenID(t *testing.T) {
+	s := newTestJWT()
+
+	// токены, выпущенные до ротации, не содержат jti и больше не принимаются
+	claims := jwt.MapClaims{
+		"uuid":  uuid.New().String(),
+		"login": "test",
+		"role":  "admin",
+		"sid":   uuid.New().String(),
+		"iss":   "warehousecontrol",
+		"aud":   "warehousecontrol",
+		"iat":   time.Now().Unix(),
+		"typ":   "refresh",
+		"exp":   time.Now().Add(time.Hour).Unix(),
+	}
+
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
+	token.Header["kid"] = "hs256"
+	tokenStr, _ := token.SignedString([]byte("access-secret"))
+
+	_, err := s.ParseRefreshToken(tokenStr)
+	if err == nil {
+		t.Fatal("expected error because jti missing")
+	}
+}
+
+func TestParseRefreshToken_SessionClaim(t *testing.T) {
+	s := newTestJWT()
+	u := newTestUser()
+
+	tokens, _ := s.GenerateTokens(u, testRole, "")
+	claims := jwt.MapClaims{}
+	if _, _, err := jwt.NewParser().ParseUnverified(tokens.RefreshToken, claims); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if claims["sid"] != tokens.SessionID || claims["fam"] != nil {
+		t.Fatalf("expected session in sid claim, got %v", claims)
+	}
+
+	// refresh токены, выданные до переименования, хранят сессию в "fam"
+	sid := uuid.NewString()
+	legacy := signHS256(jwt.MapClaims{
+		"uuid":  u.Id.String(),
+		"login": u.Login,
+		"role":  string(u.Role),
+		"fam":   sid,
+		"exp":   time.Now().Add(time.Hour).Unix(),
+	}, "refresh", "warehousecontrol")
+	payload, err := s.ParseRefreshToken(legacy)
+	if err != nil {
+		t.Fatalf("expected legacy token to be accepted, got %v", err)
+	}
+	if payload.SessionID != sid {
+		t.Fatalf("unexpected payload: %+v", payload)
+	}
+}
+
 func TestValidateAccessToken_Expired(t *testing.T) {
 	cfg := &config.AppConfig{
 		JwtConfig: config.JwtConfig{
 			JwtAccessSecret:   "access-secret",
-			JwtRefreshSecret:  "refresh-secret",
 			JwtExpAccessToken: -1, // уже истёк
 		},
 	}
 
-	s := auth.NewJWTService(cfg)
+	s, err := auth.NewJWTService(cfg)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
 	u := newTestUser()
 
-	expired, _ := s.GenerateTokens(u)
+	expired, _ := s.GenerateTokens(u, testRole, "")
 
-	_, err := s.ValidateTokens(expired.AccessToken)
+	_, err = s.ValidateTokens(expired.AccessToken)
 	if err == nil {
 		t.Fatal("expected error: access token expired")
 	}
@@ -199,18 +362,80 @@ func TestValidateRefreshToken_Expired(t *testing.T) {
 	cfg := &config.AppConfig{
 		JwtConfig: config.JwtConfig{
 			JwtAccessSecret:    "access-secret",
-			JwtRefreshSecret:   "refresh-secret",
 			JwtExpRefreshToken: -1, // час назад истёк
 		},
 	}
 
-	s := auth.NewJWTService(cfg)
+	s, err := auth.NewJWTService(cfg)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
 	u := newTestUser()
 
-	tokens, _ := s.GenerateTokens(u)
+	tokens, _ := s.GenerateTokens(u, testRole, "")
 
-	_, err := s.RefreshTokens(tokens.RefreshToken)
+	_, err = s.ParseRefreshToken(tokens.RefreshToken)
 	if err == nil {
 		t.Fatal("expected error: refresh token expired")
 	}
 }
+
+func TestValidateTokens_StandardClaims(t *testing.T) {
+	s := newTestJWT()
+	u := newTestUser()
+	tokens, _ := s.GenerateTokens(u, testRole, "")
+
+	claims := jwt.MapClaims{}
+	if _, _, err := jwt.NewParser().ParseUnverified(tokens.AccessToken, claims); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	aud, _ := claims.GetAudience()
+	if claims["iss"] != "warehousecontrol" || len(aud) != 1 || aud[0] != "warehousecontrol-api" || claims["iat"] == nil || claims["jti"] == "" {
+		t.Fatalf("unexpected claims: %v", claims)
+	}
+
+	// токен другого издателя или для другого получателя не принимается, даже с верной подписью
+	for _, tc := range []struct{ issuer, audience string }{
+		{"other", "warehousecontrol-api"},
+		{"warehousecontrol", "other-api"},
+	} {
+		cfg := newTestConfig()
+		cfg.JwtConfig.Issuer, cfg.JwtConfig.Audience = tc.issuer, tc.audience
+		other, err := auth.NewJWTService(cfg)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		foreign, _ := other.GenerateTokens(u, testRole, "")
+		if _, err := s.ValidateTokens(foreign.AccessToken); err == nil {
+			t.Fatalf("expected token of %+v to be rejected", tc)
+		}
+	}
+
+	// без iat или jti токен не принимается
+	for _, missing := range []string{"iat", "jti"} {
+		claims := jwt.MapClaims{
+			"uuid":  u.Id.String(),
+			"login": u.Login,
+			"role":  "admin",
+			"sid":   uuid.NewString(),
+			"exp":   time.Now().Add(time.Minute).Unix(),
+		}
+		tokenStr := signHS256(claims, "access", "warehousecontrol-api")
+		if _, err := s.ValidateTokens(tokenStr); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		delete(claims, missing)
+		token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
+		token.Header["kid"] = "hs256"
+		tokenStr, _ = token.SignedString([]byte("access-secret"))
+		if _, err := s.ValidateTokens(tokenStr); err == nil {
+			t.Fatalf("expected token without %s to be rejected", missing)
+		}
+	}
+}
+
+func TestNewJWTService_NoKeys(t *testing.T) {
+	if _, err := auth.NewJWTService(&config.AppConfig{}); err == nil {
+		t.Fatal("expected error without secret and keys")
+	}
+}
@@ -0,0 +1,241 @@
+package auth
+
+import (
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"math/big"
+	"os"
+	"slices"
+	"time"
+
+	"warehousecontrol/internal/config"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// minRSAKeyBits — RSA ключи короче не принимаются
+const minRSAKeyBits = 2048
+
+// hmacKeyID — kid единственного ключа HS256, когда асимметричные ключи не настроены
+const hmacKeyID = "hs256"
+
+// signingKey — ключ подписи токенов. Ключ без закрытой части только проверяет подписи:
+// так принимаются токены, подписанные другим экземпляром сервиса или до ротации.
+type signingKey struct {
+	kid     string
+	method  jwt.SigningMethod
+	private crypto.PrivateKey
+	public  crypto.PublicKey
+	// activateAt — с этого момента ключом подписываются новые токены, retireAt — после
+	// этого момента подписанные им токены не принимаются, а ключ не публикуется
+	activateAt time.Time
+	retireAt   time.Time
+}
+
+func (k *signingKey) retired(now time.Time) bool {
+	return !k.retireAt.IsZero() && !now.Before(k.retireAt)
+}
+
+// symmetric — ключ HS256 нельзя публиковать
+func (k *signingKey) symmetric() bool {
+	return k.method == jwt.SigningMethodHS256
+}
+
+// keySet — ключи подписи, различаемые по kid. Подписывает самый поздно активированный
+// ключ с закрытой частью; прежние ключи продолжают проверять выданные токены до retire_at.
+type keySet struct {
+	keys    []*signingKey
+	methods []string
+}
+
+func newKeySet(cfg config.JwtConfig) (*keySet, error) {
+	if len(cfg.Keys) == 0 {
+		if cfg.JwtAccessSecret == "" {
+			return nil, errors.New("jwt: either jwt.keys or JWT_ACCESS_SECRET must be set")
+		}
+		secret := []byte(cfg.JwtAccessSecret)
+		return &keySet{
+			keys:    []*signingKey{{kid: hmacKeyID, method: jwt.SigningMethodHS256, private: secret, public: secret}},
+			methods: []string{jwt.SigningMethodHS256.Alg()},
+		}, nil
+	}
+
+	ks := &keySet{}
+	seen := map[string]bool{}
+	for _, kc := range cfg.Keys {
+		if kc.KID == "" {
+			return nil, errors.New("jwt: key without kid")
+		}
+		if seen[kc.KID] {
+			return nil, fmt.Errorf("jwt: duplicate kid %q", kc.KID)
+		}
+		seen[kc.KID] = true
+		k, err := loadKey(kc)
+		if err != nil {
+			return nil, fmt.Errorf("jwt: key %q: %w", kc.KID, err)
+		}
+		ks.keys = append(ks.keys, k)
+		if !slices.Contains(ks.methods, k.method.Alg()) {
+			ks.methods = append(ks.methods, k.method.Alg())
+		}
+	}
+	return ks, nil
+}
+
+// signer возвращает ключ для подписи новых токенов
+func (ks *keySet) signer(now time.Time) (*signingKey, error) {
+	var active *signingKey
+	for _, k := range ks.keys {
+		if k.private == nil || k.retired(now) || now.Before(k.activateAt) {
+			continue
+		}
+		if active == nil || k.activateAt.After(active.activateAt) {
+			active = k
+		}
+	}
+	if active == nil {
+		return nil, errors.New("no active signing key")
+	}
+	return active, nil
+}
+
+// verifier возвращает ключ, которым проверяется подпись токена с этим kid
+func (ks *keySet) verifier(kid string, now time.Time) (*signingKey, error) {
+	for _, k := range ks.keys {
+		if k.kid != kid {
+			continue
+		}
+		if k.retired(now) {
+			return nil, fmt.Errorf("signing key %q is retired", kid)
+		}
+		return k, nil
+	}
+	return nil, fmt.Errorf("unknown signing key %q", kid)
+}
+
+func (ks *keySet) keyFunc(now time.Time) jwt.Keyfunc {
+	return func(token *jwt.Token) (interface{}, error) {
+		kid, _ := token.Header["kid"].(string)
+		k, err := ks.verifier(kid, now)
+		if err != nil {
+			return nil, err
+		}
+		// алгоритм задаёт ключ, а не заголовок токена
+		if token.Method.Alg() != k.method.Alg() {
+			return nil, fmt.Errorf("unexpected signing method %s for key %q", token.Method.Alg(), kid)
+		}
+		return k.public, nil
+	}
+}
+
+// jwks — открытые ключи, которые ещё не выведены из оборота, в том числе ещё не
+// активированные: проверяющие сервисы получают их заранее
+func (ks *keySet) jwks(now time.Time) []JWK {
+	res := []JWK{}
+	for _, k := range ks.keys {
+		if k.symmetric() || k.retired(now) {
+			continue
+		}
+		jwk := JWK{KeyID: k.kid, Use: "sig", Algorithm: k.method.Alg()}
+		switch pub := k.public.(type) {
+		case *rsa.PublicKey:
+			jwk.KeyType = "RSA"
+			jwk.N = base64.RawURLEncoding.EncodeToString(pub.N.Bytes())
+			jwk.E = base64.RawURLEncoding.EncodeToString(big.NewInt(int64(pub.E)).Bytes())
+		case ed25519.PublicKey:
+			jwk.KeyType = "OKP"
+			jwk.Curve = "Ed25519"
+			jwk.X = base64.RawURLEncoding.EncodeToString(pub)
+		}
+		res = append(res, jwk)
+	}
+	return res
+}
+
+func loadKey(kc config.JwtKeyConfig) (*signingKey, error) {
+	k := &signingKey{kid: kc.KID}
+	var err error
+	if kc.ActivateAt != "" {
+		if k.activateAt, err = time.Parse(time.RFC3339, kc.ActivateAt); err != nil {
+			return nil, fmt.Errorf("invalid activate_at: %w", err)
+		}
+	}
+	if kc.RetireAt != "" {
+		if k.retireAt, err = time.Parse(time.RFC3339, kc.RetireAt); err != nil {
+			return nil, fmt.Errorf("invalid retire_at: %w", err)
+		}
+	}
+
+	switch {
+	case kc.PrivateKeyFile != "":
+		block, err := readPEM(kc.PrivateKeyFile)
+		if err != nil {
+			return nil, err
+		}
+		private, err := parsePrivateKey(block)
+		if err != nil {
+			return nil, err
+		}
+		signer, ok := private.(crypto.Signer)
+		if !ok {
+			return nil, fmt.Errorf("unsupported key type %T, use RSA or Ed25519", private)
+		}
+		k.private, k.public = signer, signer.Public()
+	case kc.PublicKeyFile != "":
+		block, err := readPEM(kc.PublicKeyFile)
+		if err != nil {
+			return nil, err
+		}
+		k.public, err = parsePublicKey(block)
+		if err != nil {
+			return nil, err
+		}
+	default:
+		return nil, errors.New("private_key_file or public_key_file required")
+	}
+
+	switch pub := k.public.(type) {
+	case *rsa.PublicKey:
+		if pub.N.BitLen() < minRSAKeyBits {
+			return nil, fmt.Errorf("rsa key must be at least %d bits", minRSAKeyBits)
+		}
+		k.method = jwt.SigningMethodRS256
+	case ed25519.PublicKey:
+		k.method = jwt.SigningMethodEdDSA
+	default:
+		return nil, fmt.Errorf("unsupported key type %T, use RSA or Ed25519", pub)
+	}
+	return k, nil
+}
+
+func readPEM(path string) (*pem.Block, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, fmt.Errorf("%s: no PEM data", path)
+	}
+	return block, nil
+}
+
+func parsePrivateKey(block *pem.Block) (crypto.PrivateKey, error) {
+	if block.Type == "RSA PRIVATE KEY" {
+		return x509.ParsePKCS1PrivateKey(block.Bytes)
+	}
+	return x509.ParsePKCS8PrivateKey(block.Bytes)
+}
+
+func parsePublicKey(block *pem.Block) (crypto.PublicKey, error) {
+	if block.Type == "RSA PUBLIC KEY" {
+		return x509.ParsePKCS1PublicKey(block.Bytes)
+	}
+	return x509.ParsePKIXPublicKey(block.Bytes)
+}
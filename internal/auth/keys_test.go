@@ -0,0 +1,227 @@
+package auth_test
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"warehousecontrol/internal/auth"
+	"warehousecontrol/internal/config"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// writeKey сохраняет ключ в PEM: закрытый — в PKCS#8, открытый — в PKIX
+func writeKey(t *testing.T, name string, key any, public bool) string {
+	t.Helper()
+	var block *pem.Block
+	if public {
+		der, err := x509.MarshalPKIXPublicKey(key)
+		if err != nil {
+			t.Fatalf("cant marshal key: %v", err)
+		}
+		block = &pem.Block{Type: "PUBLIC KEY", Bytes: der}
+	} else {
+		der, err := x509.MarshalPKCS8PrivateKey(key)
+		if err != nil {
+			t.Fatalf("cant marshal key: %v", err)
+		}
+		block = &pem.Block{Type: "PRIVATE KEY", Bytes: der}
+	}
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, pem.EncodeToMemory(block), 0o600); err != nil {
+		t.Fatalf("cant write key: %v", err)
+	}
+	return path
+}
+
+func keysConfig(keys ...config.JwtKeyConfig) *config.AppConfig {
+	cfg := newTestConfig()
+	cfg.JwtConfig.JwtAccessSecret = ""
+	cfg.JwtConfig.Keys = keys
+	return cfg
+}
+
+func tokenHeader(t *testing.T, tokenStr string) map[string]any {
+	t.Helper()
+	token, _, err := jwt.NewParser().ParseUnverified(tokenStr, jwt.MapClaims{})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	return token.Header
+}
+
+func TestJWTService_AsymmetricKeys(t *testing.T) {
+	rsaKey, _ := rsa.GenerateKey(rand.Reader, 2048)
+	_, edKey, _ := ed25519.GenerateKey(rand.Reader)
+
+	for _, tc := range []struct {
+		name string
+		key  any
+		alg  string
+	}{
+		{"rsa", rsaKey, "RS256"},
+		{"ed25519", edKey, "EdDSA"},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			s, err := auth.NewJWTService(keysConfig(config.JwtKeyConfig{KID: "k1", PrivateKeyFile: writeKey(t, "k1.pem", tc.key, false)}))
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			u := newTestUser()
+			tokens, err := s.GenerateTokens(u, testRole, "")
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if h := tokenHeader(t, tokens.AccessToken); h["kid"] != "k1" || h["alg"] != tc.alg {
+				t.Fatalf("unexpected header %v", h)
+			}
+			if p, err := s.ValidateTokens(tokens.AccessToken); err != nil || p.UserID != u.Id.String() {
+				t.Fatalf("unexpected result %+v, %v", p, err)
+			}
+			if _, err := s.ParseRefreshToken(tokens.RefreshToken); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			mfa, _, _ := s.GenerateMFAToken(u)
+			if _, err := s.ParseMFAToken(mfa); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			// HS256 токен с тем же kid не принимается: алгоритм задаёт ключ
+			claims := jwt.MapClaims{}
+			_, _, _ = jwt.NewParser().ParseUnverified(tokens.AccessToken, claims)
+			forged := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
+			forged.Header["kid"] = "k1"
+			forgedStr, _ := forged.SignedString([]byte("access-secret"))
+			if _, err := s.ValidateTokens(forgedStr); err == nil {
+				t.Fatal("expected HS256 token to be rejected")
+			}
+		})
+	}
+}
+
+func TestJWTService_KeyRotation(t *testing.T) {
+	oldKey, _ := rsa.GenerateKey(rand.Reader, 2048)
+	_, newKey, _ := ed25519.GenerateKey(rand.Reader)
+	oldPriv := writeKey(t, "old.pem", oldKey, false)
+	oldPub := writeKey(t, "old.pub.pem", &oldKey.PublicKey, true)
+	newPriv := writeKey(t, "new.pem", newKey, false)
+	now := time.Now()
+	u := newTestUser()
+
+	// до ротации подписывает старый ключ, новый уже опубликован
+	before, err := auth.NewJWTService(keysConfig(
+		config.JwtKeyConfig{KID: "old", PrivateKeyFile: oldPriv},
+		config.JwtKeyConfig{KID: "new", PrivateKeyFile: newPriv, ActivateAt: now.Add(time.Hour).Format(time.RFC3339)},
+	))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	oldTokens, _ := before.GenerateTokens(u, testRole, "")
+	if h := tokenHeader(t, oldTokens.AccessToken); h["kid"] != "old" {
+		t.Fatalf("expected old key before activation, got %v", h)
+	}
+	if len(before.JWKS()) != 2 {
+		t.Fatalf("expected both keys published, got %+v", before.JWKS())
+	}
+
+	// после ротации старый ключ только проверяет выданные им токены до retire_at
+	after, err := auth.NewJWTService(keysConfig(
+		config.JwtKeyConfig{KID: "old", PublicKeyFile: oldPub, RetireAt: now.Add(time.Hour).Format(time.RFC3339)},
+		config.JwtKeyConfig{KID: "new", PrivateKeyFile: newPriv, ActivateAt: now.Add(-time.Minute).Format(time.RFC3339)},
+	))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	newTokens, _ := after.GenerateTokens(u, testRole, "")
+	if h := tokenHeader(t, newTokens.AccessToken); h["kid"] != "new" {
+		t.Fatalf("expected new key after activation, got %v", h)
+	}
+	if _, err := after.ValidateTokens(oldTokens.AccessToken); err != nil {
+		t.Fatalf("expected old token accepted during overlap, got %v", err)
+	}
+	if _, err := after.ParseRefreshToken(oldTokens.RefreshToken); err != nil {
+		t.Fatalf("expected old refresh token accepted during overlap, got %v", err)
+	}
+
+	// после retire_at старый ключ не принимается и не публикуется
+	retired, err := auth.NewJWTService(keysConfig(
+		config.JwtKeyConfig{KID: "old", PublicKeyFile: oldPub, RetireAt: now.Add(-time.Second).Format(time.RFC3339)},
+		config.JwtKeyConfig{KID: "new", PrivateKeyFile: newPriv},
+	))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if _, err := retired.ValidateTokens(oldTokens.AccessToken); err == nil {
+		t.Fatal("expected token of retired key to be rejected")
+	}
+	if keys := retired.JWKS(); len(keys) != 1 || keys[0].KeyID != "new" {
+		t.Fatalf("expected only new key published, got %+v", keys)
+	}
+
+	// сервис без закрытого ключа не может подписывать
+	verifyOnly, err := auth.NewJWTService(keysConfig(config.JwtKeyConfig{KID: "old", PublicKeyFile: oldPub}))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if _, err := verifyOnly.GenerateTokens(u, testRole, ""); err == nil {
+		t.Fatal("expected error without signing key")
+	}
+}
+
+func TestJWTService_JWKS(t *testing.T) {
+	rsaKey, _ := rsa.GenerateKey(rand.Reader, 2048)
+	edPub, edKey, _ := ed25519.GenerateKey(rand.Reader)
+	s, err := auth.NewJWTService(keysConfig(
+		config.JwtKeyConfig{KID: "r", PrivateKeyFile: writeKey(t, "r.pem", rsaKey, false)},
+		config.JwtKeyConfig{KID: "e", PrivateKeyFile: writeKey(t, "e.pem", edKey, false)},
+	))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	keys := s.JWKS()
+	if len(keys) != 2 {
+		t.Fatalf("expected 2 keys, got %+v", keys)
+	}
+	r, e := keys[0], keys[1]
+	n, _ := base64.RawURLEncoding.DecodeString(r.N)
+	if r.KeyType != "RSA" || r.Algorithm != "RS256" || r.Use != "sig" || new(big.Int).SetBytes(n).Cmp(rsaKey.N) != 0 || r.E != "AQAB" {
+		t.Fatalf("unexpected rsa jwk %+v", r)
+	}
+	x, _ := base64.RawURLEncoding.DecodeString(e.X)
+	if e.KeyType != "OKP" || e.Curve != "Ed25519" || e.Algorithm != "EdDSA" || !edPub.Equal(ed25519.PublicKey(x)) {
+		t.Fatalf("unexpected ed25519 jwk %+v", e)
+	}
+
+	// секрет HS256 не публикуется
+	if keys := newTestJWT().JWKS(); len(keys) != 0 {
+		t.Fatalf("expected no public keys for HS256, got %+v", keys)
+	}
+}
+
+func TestNewJWTService_InvalidKeys(t *testing.T) {
+	weak, _ := rsa.GenerateKey(rand.Reader, 1024)
+	_, edKey, _ := ed25519.GenerateKey(rand.Reader)
+	edPath := writeKey(t, "e.pem", edKey, false)
+
+	for name, keys := range map[string][]config.JwtKeyConfig{
+		"no kid":        {{PrivateKeyFile: edPath}},
+		"duplicate kid": {{KID: "a", PrivateKeyFile: edPath}, {KID: "a", PrivateKeyFile: edPath}},
+		"no file":       {{KID: "a"}},
+		"missing file":  {{KID: "a", PrivateKeyFile: filepath.Join(t.TempDir(), "none.pem")}},
+		"weak rsa":      {{KID: "a", PrivateKeyFile: writeKey(t, "weak.pem", weak, false)}},
+		"bad time":      {{KID: "a", PrivateKeyFile: edPath, RetireAt: "tomorrow"}},
+	} {
+		if _, err := auth.NewJWTService(keysConfig(keys...)); err == nil {
+			t.Fatalf("%s: expected error", name)
+		}
+	}
+}
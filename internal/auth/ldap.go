@@ -0,0 +1,278 @@
+package auth
+
+import (
+	"crypto/tls"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"warehousecontrol/internal/config"
+
+	"github.com/go-ldap/ldap/v3"
+)
+
+const (
+	defaultLDAPUserFilter     = "(uid=%s)"
+	defaultLDAPLoginAttribute = "uid"
+	defaultLDAPGroupAttribute = "memberOf"
+	defaultLDAPGroupFilter    = "(member=%s)"
+	defaultLDAPTimeout        = 5 * time.Second
+)
+
+var (
+	// ErrLDAPUnknownUser — в каталоге нет записи с таким логином
+	ErrLDAPUnknownUser = errors.New("ldap: user not found")
+	// ErrLDAPInvalidCredentials — пароль не подошёл к записи или логин указывает на несколько записей
+	ErrLDAPInvalidCredentials = errors.New("ldap: invalid credentials")
+)
+
+// LDAPConn — соединение с каталогом. В работе это *ldap.Conn, в тестах — каталог в памяти.
+type LDAPConn interface {
+	Bind(username, password string) error
+	Search(req *ldap.SearchRequest) (*ldap.SearchResult, error)
+	Close() error
+}
+
+// LDAPDialer открывает новое соединение с каталогом
+type LDAPDialer func() (LDAPConn, error)
+
+// LDAPDirectory проверяет пароли в LDAP / Active Directory: ищет запись пользователя, делает
+// bind от её DN и читает группы. На каждый вход открывается отдельное соединение, чтобы
+// bind одного пользователя не влиял на запросы другого.
+type LDAPDirectory struct {
+	cfg    config.LDAPConfig
+	issuer string
+	dial   LDAPDialer
+}
+
+func NewLDAPDirectory(cfg *config.AppConfig) (*LDAPDirectory, error) {
+	return NewLDAPDirectoryWithDialer(cfg, nil)
+}
+
+// NewLDAPDirectoryWithDialer — каталог с собственным способом соединения (для тестов);
+// nil — соединение по url из настроек
+func NewLDAPDirectoryWithDialer(cfg *config.AppConfig, dial LDAPDialer) (*LDAPDirectory, error) {
+	c := cfg.LDAPConfig
+	d := &LDAPDirectory{cfg: c, dial: dial}
+	if !c.Enabled {
+		return d, nil
+	}
+	u, err := url.Parse(c.URL)
+	if err != nil || (u.Scheme != "ldap" && u.Scheme != "ldaps") || u.Host == "" {
+		return nil, fmt.Errorf("ldap: url must be ldap://host[:port] or ldaps://host[:port], got %q", c.URL)
+	}
+	if c.StartTLS && u.Scheme == "ldaps" {
+		return nil, errors.New("ldap: start_tls cannot be used with ldaps://")
+	}
+	if c.UserBaseDN == "" {
+		return nil, errors.New("ldap: user_base_dn is required")
+	}
+	if c.BindDN != "" && c.BindPassword == "" {
+		return nil, errors.New("ldap: bind_dn requires LDAP_BIND_PASSWORD")
+	}
+	for _, gr := range c.GroupRoles {
+		if gr.Group == "" || gr.Role == "" {
+			return nil, errors.New("ldap: group_roles entries need both group and role")
+		}
+	}
+	if d.cfg.UserFilter == "" {
+		d.cfg.UserFilter = defaultLDAPUserFilter
+	}
+	if d.cfg.LoginAttribute == "" {
+		d.cfg.LoginAttribute = defaultLDAPLoginAttribute
+	}
+	if d.cfg.GroupAttribute == "" {
+		d.cfg.GroupAttribute = defaultLDAPGroupAttribute
+	}
+	if d.cfg.GroupFilter == "" {
+		d.cfg.GroupFilter = defaultLDAPGroupFilter
+	}
+	if d.cfg.Timeout <= 0 {
+		d.cfg.Timeout = defaultLDAPTimeout
+	}
+	if strings.Count(d.cfg.UserFilter, "%s") != 1 {
+		return nil, errors.New("ldap: user_filter must contain exactly one %s")
+	}
+	if d.cfg.GroupBaseDN != "" && strings.Count(d.cfg.GroupFilter, "%s") != 1 {
+		return nil, errors.New("ldap: group_filter must contain exactly one %s")
+	}
+	if _, err := ldap.CompileFilter(fmt.Sprintf(d.cfg.UserFilter, "login")); err != nil {
+		return nil, fmt.Errorf("ldap: user_filter: %w", err)
+	}
+	// учётные записи связываются с каталогом по базе поиска, а не по адресу сервера:
+	// смена или резервный контроллер домена не должны заводить пользователей заново
+	d.issuer = "ldap:" + strings.ToLower(c.UserBaseDN)
+	if d.dial == nil {
+		d.dial = d.dialURL
+	}
+	return d, nil
+}
+
+func (d *LDAPDirectory) Enabled() bool {
+	return d.cfg.Enabled
+}
+
+// Authenticate проверяет логин и пароль в каталоге и возвращает запись пользователя с DN его
+// групп. Если записи нет — ErrLDAPUnknownUser, если пароль не подошёл — ErrLDAPInvalidCredentials,
+// остальные ошибки означают, что каталог недоступен или настроен неверно.
+func (d *LDAPDirectory) Authenticate(login, password string) (*ExternalIdentity, error) {
+	if !d.cfg.Enabled {
+		return nil, errors.New("ldap: directory is disabled")
+	}
+	// bind с пустым паролем сервер принимает как анонимный, пароль при этом не проверяется
+	if login == "" || password == "" {
+		return nil, ErrLDAPInvalidCredentials
+	}
+	conn, err := d.dial()
+	if err != nil {
+		return nil, fmt.Errorf("ldap: connect: %w", err)
+	}
+	defer conn.Close()
+
+	if err := d.serviceBind(conn); err != nil {
+		return nil, err
+	}
+	entry, err := d.findUser(conn, login)
+	if err != nil {
+		return nil, err
+	}
+	if err := conn.Bind(entry.DN, password); err != nil {
+		if ldap.IsErrorWithCode(err, ldap.LDAPResultInvalidCredentials) {
+			return nil, ErrLDAPInvalidCredentials
+		}
+		return nil, fmt.Errorf("ldap: bind as user: %w", err)
+	}
+	groups, err := d.groups(conn, entry)
+	if err != nil {
+		return nil, err
+	}
+
+	ident := &ExternalIdentity{
+		Issuer:  d.issuer,
+		Subject: d.subject(entry),
+		Login:   entry.GetEqualFoldAttributeValue(d.cfg.LoginAttribute),
+		Groups:  groups,
+	}
+	if ident.Login == "" {
+		ident.Login = login
+	}
+	return ident, nil
+}
+
+// serviceBind входит в каталог служебной учётной записью; без bind_dn запросы анонимные
+func (d *LDAPDirectory) serviceBind(conn LDAPConn) error {
+	if d.cfg.BindDN == "" {
+		return nil
+	}
+	if err := conn.Bind(d.cfg.BindDN, d.cfg.BindPassword); err != nil {
+		return fmt.Errorf("ldap: bind as %s: %w", d.cfg.BindDN, err)
+	}
+	return nil
+}
+
+func (d *LDAPDirectory) findUser(conn LDAPConn, login string) (*ldap.Entry, error) {
+	attrs := []string{d.cfg.LoginAttribute}
+	if d.cfg.GroupBaseDN == "" {
+		attrs = append(attrs, d.cfg.GroupAttribute)
+	}
+	if d.cfg.IDAttribute != "" {
+		attrs = append(attrs, d.cfg.IDAttribute)
+	}
+	res, err := conn.Search(ldap.NewSearchRequest(
+		d.cfg.UserBaseDN, ldap.ScopeWholeSubtree, ldap.NeverDerefAliases,
+		2, int(d.cfg.Timeout.Seconds()), false,
+		fmt.Sprintf(d.cfg.UserFilter, ldap.EscapeFilter(login)), attrs, nil,
+	))
+	if ldap.IsErrorWithCode(err, ldap.LDAPResultSizeLimitExceeded) || (err == nil && len(res.Entries) > 1) {
+		// фильтр слишком широкий: входить в одну из нескольких записей нельзя
+		return nil, fmt.Errorf("%w: login %q matches several entries", ErrLDAPInvalidCredentials, login)
+	}
+	if ldap.IsErrorWithCode(err, ldap.LDAPResultNoSuchObject) {
+		return nil, fmt.Errorf("ldap: user_base_dn %q not found: %w", d.cfg.UserBaseDN, err)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("ldap: search user: %w", err)
+	}
+	if len(res.Entries) == 0 {
+		return nil, ErrLDAPUnknownUser
+	}
+	return res.Entries[0], nil
+}
+
+// groups возвращает DN групп пользователя: из атрибута записи или поиском по group_base_dn
+func (d *LDAPDirectory) groups(conn LDAPConn, entry *ldap.Entry) ([]string, error) {
+	if d.cfg.GroupBaseDN == "" {
+		return entry.GetEqualFoldAttributeValues(d.cfg.GroupAttribute), nil
+	}
+	// пользователь может не иметь права читать группы, поэтому ищем их служебной учётной записью
+	if err := d.serviceBind(conn); err != nil {
+		return nil, err
+	}
+	res, err := conn.Search(ldap.NewSearchRequest(
+		d.cfg.GroupBaseDN, ldap.ScopeWholeSubtree, ldap.NeverDerefAliases,
+		0, int(d.cfg.Timeout.Seconds()), false,
+		fmt.Sprintf(d.cfg.GroupFilter, ldap.EscapeFilter(entry.DN)), []string{"1.1"}, nil,
+	))
+	if err != nil {
+		return nil, fmt.Errorf("ldap: search groups: %w", err)
+	}
+	groups := make([]string, 0, len(res.Entries))
+	for _, g := range res.Entries {
+		groups = append(groups, g.DN)
+	}
+	return groups, nil
+}
+
+// subject — неизменный идентификатор записи. Двоичные значения (objectGUID) кодируются в hex.
+func (d *LDAPDirectory) subject(entry *ldap.Entry) string {
+	if d.cfg.IDAttribute == "" {
+		return entry.DN
+	}
+	raw := entry.GetEqualFoldRawAttributeValue(d.cfg.IDAttribute)
+	if len(raw) == 0 {
+		return entry.DN
+	}
+	if !utf8.Valid(raw) || strings.ContainsFunc(string(raw), func(r rune) bool { return r < ' ' }) {
+		return hex.EncodeToString(raw)
+	}
+	return string(raw)
+}
+
+func (d *LDAPDirectory) dialURL() (LDAPConn, error) {
+	u, _ := url.Parse(d.cfg.URL)
+	tlsCfg := &tls.Config{
+		ServerName:         u.Hostname(),
+		InsecureSkipVerify: d.cfg.InsecureSkipVerify,
+		MinVersion:         tls.VersionTLS12,
+	}
+	conn, err := ldap.DialURL(d.cfg.URL,
+		ldap.DialWithDialer(&net.Dialer{Timeout: d.cfg.Timeout}),
+		ldap.DialWithTLSConfig(tlsCfg),
+	)
+	if err != nil {
+		return nil, err
+	}
+	conn.SetTimeout(d.cfg.Timeout)
+	if d.cfg.StartTLS {
+		if err := conn.StartTLS(tlsCfg); err != nil {
+			conn.Close()
+			return nil, err
+		}
+	}
+	return conn, nil
+}
+
+// SameDN сравнивает DN без учёта регистра и пробелов между компонентами
+func SameDN(a, b string) bool {
+	da, errA := ldap.ParseDN(a)
+	db, errB := ldap.ParseDN(b)
+	if errA != nil || errB != nil {
+		return strings.EqualFold(a, b)
+	}
+	return da.EqualFold(db)
+}
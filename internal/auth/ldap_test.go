@@ -0,0 +1,186 @@
+package auth_test
+
+import (
+	"errors"
+	"slices"
+	"testing"
+
+	"warehousecontrol/internal/auth"
+	"warehousecontrol/internal/auth/ldaptest"
+	"warehousecontrol/internal/config"
+)
+
+const (
+	ldapServiceDN = "cn=svc,ou=system,dc=example,dc=com"
+	ldapAdminsDN  = "cn=wh-admins,ou=groups,dc=example,dc=com"
+	ldapStaffDN   = "cn=staff,ou=groups,dc=example,dc=com"
+	ldapJDoeDN    = "uid=jdoe,ou=people,dc=example,dc=com"
+)
+
+func ldapConfig() *config.AppConfig {
+	return &config.AppConfig{LDAPConfig: config.LDAPConfig{
+		Enabled:      true,
+		URL:          "ldap://ldap.example.com",
+		BindDN:       ldapServiceDN,
+		BindPassword: "svc-pass",
+		UserBaseDN:   "ou=people,dc=example,dc=com",
+		UserFilter:   "(&(objectClass=person)(uid=%s))",
+		IDAttribute:  "entryUUID",
+	}}
+}
+
+func testDirectory() *ldaptest.Directory {
+	dir := ldaptest.NewDirectory()
+	dir.AddEntry(ldapServiceDN, "svc-pass", map[string][]string{"cn": {"svc"}})
+	dir.AddEntry("ou=people,dc=example,dc=com", "", map[string][]string{"ou": {"people"}})
+	dir.AddEntry(ldapJDoeDN, "Secret1", map[string][]string{
+		"objectClass": {"person"},
+		"uid":         {"JDoe"},
+		"entryUUID":   {"5f0c6d2e-1b7a-4c1e-9d4f-2a3b4c5d6e7f"},
+		"memberOf":    {ldapStaffDN, ldapAdminsDN},
+	})
+	dir.AddEntry(ldapAdminsDN, "", map[string][]string{"member": {ldapJDoeDN}})
+	dir.AddEntry(ldapStaffDN, "", map[string][]string{"member": {"uid=other,ou=people,dc=example,dc=com"}})
+	return dir
+}
+
+func newTestDirectory(t *testing.T, cfg *config.AppConfig, dir *ldaptest.Directory) *auth.LDAPDirectory {
+	t.Helper()
+	d, err := auth.NewLDAPDirectoryWithDialer(cfg, dir.Dial)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	return d
+}
+
+func TestLDAPDirectory_Authenticate(t *testing.T) {
+	dir := testDirectory()
+	d := newTestDirectory(t, ldapConfig(), dir)
+
+	ident, err := d.Authenticate("jdoe", "Secret1")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if ident.Issuer != "ldap:ou=people,dc=example,dc=com" || ident.Subject != "5f0c6d2e-1b7a-4c1e-9d4f-2a3b4c5d6e7f" {
+		t.Fatalf("unexpected identity %+v", ident)
+	}
+	// логин берётся из каталога, группы — из memberOf
+	if ident.Login != "JDoe" || !slices.Equal(ident.Groups, []string{ldapStaffDN, ldapAdminsDN}) {
+		t.Fatalf("unexpected identity %+v", ident)
+	}
+	if binds := dir.Binds(); !slices.Equal(binds, []string{ldapServiceDN, ldapJDoeDN}) {
+		t.Fatalf("expected service then user bind, got %v", binds)
+	}
+
+	if _, err := d.Authenticate("jdoe", "wrong"); !errors.Is(err, auth.ErrLDAPInvalidCredentials) {
+		t.Fatalf("expected invalid credentials, got %v", err)
+	}
+	// пустой пароль был бы анонимным bind'ом
+	if _, err := d.Authenticate("jdoe", ""); !errors.Is(err, auth.ErrLDAPInvalidCredentials) {
+		t.Fatalf("expected invalid credentials, got %v", err)
+	}
+	for _, login := range []string{"nobody", "*", "jdoe)(uid=*"} {
+		if _, err := d.Authenticate(login, "Secret1"); !errors.Is(err, auth.ErrLDAPUnknownUser) {
+			t.Fatalf("%q: expected unknown user, got %v", login, err)
+		}
+	}
+}
+
+func TestLDAPDirectory_GroupSearch(t *testing.T) {
+	dir := testDirectory()
+	cfg := ldapConfig()
+	cfg.LDAPConfig.GroupBaseDN = "ou=groups,dc=example,dc=com"
+	cfg.LDAPConfig.IDAttribute = ""
+	d := newTestDirectory(t, cfg, dir)
+
+	ident, err := d.Authenticate("jdoe", "Secret1")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if ident.Subject != ldapJDoeDN || !slices.Equal(ident.Groups, []string{ldapAdminsDN}) {
+		t.Fatalf("unexpected identity %+v", ident)
+	}
+	// группы ищутся снова от имени служебной учётной записи
+	if binds := dir.Binds(); !slices.Equal(binds, []string{ldapServiceDN, ldapJDoeDN, ldapServiceDN}) {
+		t.Fatalf("unexpected binds %v", binds)
+	}
+}
+
+func TestLDAPDirectory_Failures(t *testing.T) {
+	dir := testDirectory()
+	dir.AddEntry("uid=jdoe2,ou=people,dc=example,dc=com", "Secret1", map[string][]string{"objectClass": {"person"}, "uid": {"jdoe2"}})
+	cfg := ldapConfig()
+	cfg.LDAPConfig.UserFilter = "(&(objectClass=person)(|(uid=%s)(uid=jdoe2)))"
+	d := newTestDirectory(t, cfg, dir)
+	if _, err := d.Authenticate("jdoe", "Secret1"); !errors.Is(err, auth.ErrLDAPInvalidCredentials) {
+		t.Fatalf("expected ambiguous login to be rejected, got %v", err)
+	}
+
+	cfg = ldapConfig()
+	cfg.LDAPConfig.BindPassword = "wrong"
+	d = newTestDirectory(t, cfg, dir)
+	if _, err := d.Authenticate("jdoe", "Secret1"); err == nil || errors.Is(err, auth.ErrLDAPInvalidCredentials) || errors.Is(err, auth.ErrLDAPUnknownUser) {
+		t.Fatalf("expected configuration error, got %v", err)
+	}
+
+	d = newTestDirectory(t, ldapConfig(), dir)
+	dir.SetDown(true)
+	if _, err := d.Authenticate("jdoe", "Secret1"); err == nil || errors.Is(err, auth.ErrLDAPInvalidCredentials) || errors.Is(err, auth.ErrLDAPUnknownUser) {
+		t.Fatalf("expected connection error, got %v", err)
+	}
+}
+
+func TestLDAPDirectory_BinaryID(t *testing.T) {
+	dir := ldaptest.NewDirectory()
+	dir.AddEntry("cn=John Doe,ou=people,dc=example,dc=com", "Secret1", map[string][]string{
+		"sAMAccountName": {"jdoe"},
+		"objectGUID":     {string([]byte{0x01, 0xff, 0x00, 0x7f})},
+	})
+	cfg := ldapConfig()
+	cfg.LDAPConfig.BindDN, cfg.LDAPConfig.BindPassword = "", ""
+	cfg.LDAPConfig.UserFilter = "(sAMAccountName=%s)"
+	cfg.LDAPConfig.LoginAttribute = "sAMAccountName"
+	cfg.LDAPConfig.IDAttribute = "objectGUID"
+	ident, err := newTestDirectory(t, cfg, dir).Authenticate("jdoe", "Secret1")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if ident.Subject != "01ff007f" || ident.Login != "jdoe" {
+		t.Fatalf("unexpected identity %+v", ident)
+	}
+}
+
+func TestNewLDAPDirectory_Config(t *testing.T) {
+	d, err := auth.NewLDAPDirectory(&config.AppConfig{})
+	if err != nil || d.Enabled() {
+		t.Fatalf("expected disabled directory, got %v", err)
+	}
+	if _, err := d.Authenticate("jdoe", "Secret1"); err == nil {
+		t.Fatal("expected error from disabled directory")
+	}
+
+	for name, mutate := range map[string]func(c *config.LDAPConfig){
+		"bad url":          func(c *config.LDAPConfig) { c.URL = "http://ldap.example.com" },
+		"ldaps + starttls": func(c *config.LDAPConfig) { c.URL, c.StartTLS = "ldaps://ldap.example.com", true },
+		"no base dn":       func(c *config.LDAPConfig) { c.UserBaseDN = "" },
+		"no bind password": func(c *config.LDAPConfig) { c.BindPassword = "" },
+		"filter no login":  func(c *config.LDAPConfig) { c.UserFilter = "(uid=jdoe)" },
+		"broken filter":    func(c *config.LDAPConfig) { c.UserFilter = "(uid=%s" },
+		"mapping no role":  func(c *config.LDAPConfig) { c.GroupRoles = []config.GroupRole{{Group: ldapAdminsDN}} },
+	} {
+		cfg := ldapConfig()
+		mutate(&cfg.LDAPConfig)
+		if _, err := auth.NewLDAPDirectory(cfg); err == nil {
+			t.Fatalf("%s: expected error", name)
+		}
+	}
+}
+
+func TestSameDN(t *testing.T) {
+	if !auth.SameDN("CN=WH-Admins, OU=Groups,DC=example,DC=com", ldapAdminsDN) {
+		t.Fatal("expected DNs to match regardless of case and spaces")
+	}
+	if auth.SameDN(ldapStaffDN, ldapAdminsDN) {
+		t.Fatal("expected different DNs")
+	}
+}
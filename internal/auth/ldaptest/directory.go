@@ -0,0 +1,191 @@
+// Package ldaptest — каталог LDAP в памяти для тестов входа через LDAP / Active Directory.
+package ldaptest
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+
+	"warehousecontrol/internal/auth"
+
+	ber "github.com/go-asn1-ber/asn1-ber"
+	"github.com/go-ldap/ldap/v3"
+)
+
+// Directory хранит записи с атрибутами и паролями для bind. Поиск понимает фильтры
+// из &, |, !, равенства и (attr=*); имена атрибутов и значения сравниваются без учёта регистра.
+type Directory struct {
+	mu        sync.Mutex
+	entries   []*ldap.Entry
+	passwords map[string]string
+	down      bool
+	binds     []string
+}
+
+func NewDirectory() *Directory {
+	return &Directory{passwords: make(map[string]string)}
+}
+
+// AddEntry добавляет запись; пустой пароль — в запись нельзя войти bind'ом
+func (d *Directory) AddEntry(dn, password string, attrs map[string][]string) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.entries = append(d.entries, ldap.NewEntry(dn, attrs))
+	if password != "" {
+		d.passwords[strings.ToLower(dn)] = password
+	}
+}
+
+// SetDown делает каталог недоступным: новые соединения не устанавливаются
+func (d *Directory) SetDown(down bool) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.down = down
+}
+
+// Binds — DN всех успешных bind в порядке выполнения
+func (d *Directory) Binds() []string {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return append([]string(nil), d.binds...)
+}
+
+// Dial открывает соединение с каталогом; подходит как auth.LDAPDialer
+func (d *Directory) Dial() (auth.LDAPConn, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.down {
+		return nil, ldap.NewError(ldap.ErrorNetwork, errors.New("connection refused"))
+	}
+	return &conn{dir: d}, nil
+}
+
+type conn struct {
+	dir    *Directory
+	closed bool
+}
+
+func (c *conn) Bind(username, password string) error {
+	if c.closed {
+		return ldap.NewError(ldap.ErrorNetwork, errors.New("connection closed"))
+	}
+	// как и клиент go-ldap, пустой пароль не отправляется
+	if password == "" {
+		return ldap.NewError(ldap.ErrorEmptyPassword, errors.New("empty password not allowed by the client"))
+	}
+	c.dir.mu.Lock()
+	defer c.dir.mu.Unlock()
+	for dn, pw := range c.dir.passwords {
+		if auth.SameDN(dn, username) && pw == password {
+			c.dir.binds = append(c.dir.binds, username)
+			return nil
+		}
+	}
+	return ldap.NewError(ldap.LDAPResultInvalidCredentials, errors.New("invalid credentials"))
+}
+
+func (c *conn) Search(req *ldap.SearchRequest) (*ldap.SearchResult, error) {
+	if c.closed {
+		return nil, ldap.NewError(ldap.ErrorNetwork, errors.New("connection closed"))
+	}
+	filter, err := ldap.CompileFilter(req.Filter)
+	if err != nil {
+		return nil, ldap.NewError(ldap.LDAPResultFilterError, err)
+	}
+	base, err := ldap.ParseDN(req.BaseDN)
+	if err != nil {
+		return nil, ldap.NewError(ldap.LDAPResultInvalidDNSyntax, err)
+	}
+
+	c.dir.mu.Lock()
+	defer c.dir.mu.Unlock()
+	res := &ldap.SearchResult{}
+	baseFound := false
+	for _, e := range c.dir.entries {
+		dn, _ := ldap.ParseDN(e.DN)
+		var inScope bool
+		switch req.Scope {
+		case ldap.ScopeBaseObject:
+			inScope = base.EqualFold(dn)
+		case ldap.ScopeWholeSubtree:
+			inScope = base.EqualFold(dn) || base.AncestorOfFold(dn)
+		default:
+			return nil, ldap.NewError(ldap.LDAPResultUnwillingToPerform, fmt.Errorf("scope %d is not supported", req.Scope))
+		}
+		if inScope || base.AncestorOfFold(dn) {
+			baseFound = true
+		}
+		if !inScope {
+			continue
+		}
+		ok, err := matches(filter, e)
+		if err != nil {
+			return nil, ldap.NewError(ldap.LDAPResultUnwillingToPerform, err)
+		}
+		if !ok {
+			continue
+		}
+		if req.SizeLimit > 0 && len(res.Entries) == req.SizeLimit {
+			return res, ldap.NewError(ldap.LDAPResultSizeLimitExceeded, errors.New("size limit exceeded"))
+		}
+		res.Entries = append(res.Entries, project(e, req.Attributes))
+	}
+	if !baseFound {
+		return nil, ldap.NewError(ldap.LDAPResultNoSuchObject, fmt.Errorf("no such object: %s", req.BaseDN))
+	}
+	return res, nil
+}
+
+func (c *conn) Close() error {
+	c.closed = true
+	return nil
+}
+
+// matches проверяет запись по скомпилированному фильтру
+func matches(f *ber.Packet, e *ldap.Entry) (bool, error) {
+	switch f.Tag {
+	case ldap.FilterAnd, ldap.FilterOr:
+		for _, child := range f.Children {
+			ok, err := matches(child, e)
+			if err != nil {
+				return false, err
+			}
+			if ok == (f.Tag == ldap.FilterOr) {
+				return ok, nil
+			}
+		}
+		return f.Tag == ldap.FilterAnd, nil
+	case ldap.FilterNot:
+		ok, err := matches(f.Children[0], e)
+		return !ok, err
+	case ldap.FilterPresent:
+		return len(e.GetEqualFoldAttributeValues(f.Value.(string))) > 0, nil
+	case ldap.FilterEqualityMatch:
+		attr, value := f.Children[0].Value.(string), f.Children[1].Value.(string)
+		for _, v := range e.GetEqualFoldAttributeValues(attr) {
+			if auth.SameDN(v, value) {
+				return true, nil
+			}
+		}
+		return false, nil
+	}
+	return false, fmt.Errorf("filter %s is not supported", ldap.FilterMap[uint64(f.Tag)])
+}
+
+// project оставляет в записи только запрошенные атрибуты ("1.1" — никаких)
+func project(e *ldap.Entry, attrs []string) *ldap.Entry {
+	out := &ldap.Entry{DN: e.DN}
+	if len(attrs) == 0 {
+		out.Attributes = e.Attributes
+		return out
+	}
+	for _, a := range e.Attributes {
+		for _, want := range attrs {
+			if strings.EqualFold(a.Name, want) {
+				out.Attributes = append(out.Attributes, a)
+			}
+		}
+	}
+	return out
+}
@@ -0,0 +1,422 @@
+package auth
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"warehousecontrol/internal/config"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+const (
+	oidcDiscoveryPath  = "/.well-known/openid-configuration"
+	defaultLoginClaim  = "preferred_username"
+	defaultGroupsClaim = "groups"
+	// oidcKeysRefreshInterval — ключи провайдера перечитываются при незнакомом kid, но не чаще
+	oidcKeysRefreshInterval = time.Minute
+	oidcHTTPTimeout         = 10 * time.Second
+	// oidcMaxResponse ограничивает размер ответов провайдера
+	oidcMaxResponse = 1 << 20
+	// oidcClockSkew — допустимое расхождение часов с провайдером при проверке ID токена
+	oidcClockSkew = time.Minute
+)
+
+var defaultOIDCScopes = []string{"openid", "profile", "email"}
+
+// ExternalIdentity — пользователь, подтверждённый OIDC провайдером или LDAP каталогом
+type ExternalIdentity struct {
+	Issuer  string
+	Subject string
+	Login   string
+	Groups  []string
+}
+
+type oidcMetadata struct {
+	Issuer                string   `json:"issuer"`
+	AuthorizationEndpoint string   `json:"authorization_endpoint"`
+	TokenEndpoint         string   `json:"token_endpoint"`
+	JWKSURI               string   `json:"jwks_uri"`
+	CodeChallengeMethods  []string `json:"code_challenge_methods_supported"`
+}
+
+type oidcJWK struct {
+	KeyType string `json:"kty"`
+	KeyID   string `json:"kid"`
+	Use     string `json:"use"`
+	N       string `json:"n"`
+	E       string `json:"e"`
+	Curve   string `json:"crv"`
+	X       string `json:"x"`
+	Y       string `json:"y"`
+}
+
+// OIDCProvider — клиент OpenID Connect провайдера: ссылка на вход с PKCE (S256), обмен кода
+// на ID токен и его проверка. Настройки провайдера читаются при первом входе, а не при старте,
+// чтобы недоступный провайдер не мешал запуску сервиса. mu защищает только кэш: запросы
+// к провайдеру идут без блокировки, чтобы медленный провайдер не задерживал остальные входы.
+type OIDCProvider struct {
+	cfg    config.OIDCConfig
+	scopes []string
+	client *http.Client
+
+	mu           sync.Mutex
+	meta         *oidcMetadata
+	keys         map[string]crypto.PublicKey
+	keysLoadedAt time.Time
+}
+
+func NewOIDCProvider(cfg *config.AppConfig) (*OIDCProvider, error) {
+	c := cfg.OIDCConfig
+	p := &OIDCProvider{cfg: c, client: &http.Client{Timeout: oidcHTTPTimeout}}
+	if !c.Enabled {
+		return p, nil
+	}
+	if c.IssuerURL == "" || c.ClientID == "" || c.RedirectURL == "" {
+		return nil, errors.New("oidc: issuer_url, client_id and redirect_url are required")
+	}
+	for _, gr := range c.GroupRoles {
+		if gr.Group == "" || gr.Role == "" {
+			return nil, errors.New("oidc: group_roles entries need both group and role")
+		}
+	}
+	if p.cfg.LoginClaim == "" {
+		p.cfg.LoginClaim = defaultLoginClaim
+	}
+	if p.cfg.GroupsClaim == "" {
+		p.cfg.GroupsClaim = defaultGroupsClaim
+	}
+	p.scopes = c.Scopes
+	if len(p.scopes) == 0 {
+		p.scopes = defaultOIDCScopes
+	}
+	if !slices.Contains(p.scopes, "openid") {
+		p.scopes = append([]string{"openid"}, p.scopes...)
+	}
+	return p, nil
+}
+
+func (p *OIDCProvider) Enabled() bool {
+	return p.cfg.Enabled
+}
+
+// AuthCodeURL — ссылка на страницу входа провайдера. В провайдер уходит только хэш verifier
+// (code_challenge), сам verifier предъявляется при обмене кода.
+func (p *OIDCProvider) AuthCodeURL(ctx context.Context, state, nonce, verifier string) (string, error) {
+	meta, err := p.metadata(ctx)
+	if err != nil {
+		return "", err
+	}
+	u, err := url.Parse(meta.AuthorizationEndpoint)
+	if err != nil {
+		return "", fmt.Errorf("oidc: invalid authorization endpoint: %w", err)
+	}
+	q := u.Query()
+	q.Set("response_type", "code")
+	q.Set("client_id", p.cfg.ClientID)
+	q.Set("redirect_uri", p.cfg.RedirectURL)
+	q.Set("scope", strings.Join(p.scopes, " "))
+	q.Set("state", state)
+	q.Set("nonce", nonce)
+	q.Set("code_challenge", pkceChallenge(verifier))
+	q.Set("code_challenge_method", "S256")
+	u.RawQuery = q.Encode()
+	return u.String(), nil
+}
+
+// Exchange обменивает код авторизации на ID токен и возвращает подтверждённого им пользователя.
+// nonce должен совпасть с отправленным в AuthCodeURL: так токен привязан к этому входу.
+func (p *OIDCProvider) Exchange(ctx context.Context, code, verifier, nonce string) (*ExternalIdentity, error) {
+	meta, err := p.metadata(ctx)
+	if err != nil {
+		return nil, err
+	}
+	form := url.Values{}
+	form.Set("grant_type", "authorization_code")
+	form.Set("code", code)
+	form.Set("redirect_uri", p.cfg.RedirectURL)
+	form.Set("code_verifier", verifier)
+	form.Set("client_id", p.cfg.ClientID)
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, meta.TokenEndpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+	if p.cfg.ClientSecret != "" {
+		// client_secret_basic: id и секрет кодируются как form-urlencoded (RFC 6749, 2.3.1)
+		req.SetBasicAuth(url.QueryEscape(p.cfg.ClientID), url.QueryEscape(p.cfg.ClientSecret))
+	}
+
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("oidc: token request: %w", err)
+	}
+	defer func() {
+		_ = resp.Body.Close()
+	}()
+	var body struct {
+		IDToken          string `json:"id_token"`
+		Error            string `json:"error"`
+		ErrorDescription string `json:"error_description"`
+	}
+	if err := json.NewDecoder(io.LimitReader(resp.Body, oidcMaxResponse)).Decode(&body); err != nil {
+		return nil, fmt.Errorf("oidc: token response (status %d): %w", resp.StatusCode, err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("oidc: token endpoint: %s %s", body.Error, body.ErrorDescription)
+	}
+	if body.IDToken == "" {
+		return nil, errors.New("oidc: token response without id_token")
+	}
+	return p.verifyIDToken(ctx, meta, body.IDToken, nonce)
+}
+
+func (p *OIDCProvider) verifyIDToken(ctx context.Context, meta *oidcMetadata, raw, nonce string) (*ExternalIdentity, error) {
+	claims := jwt.MapClaims{}
+	_, err := jwt.ParseWithClaims(raw, claims, p.keyFunc(ctx, meta),
+		jwt.WithValidMethods([]string{"RS256", "ES256", "EdDSA"}),
+		jwt.WithIssuer(meta.Issuer),
+		jwt.WithAudience(p.cfg.ClientID),
+		jwt.WithExpirationRequired(),
+		jwt.WithIssuedAt(),
+		jwt.WithLeeway(oidcClockSkew),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("oidc: invalid id token: %w", err)
+	}
+	// токен для нескольких клиентов должен быть выдан именно этому (OIDC Core, 3.1.3.7)
+	if aud, _ := claims.GetAudience(); len(aud) > 1 {
+		if azp, _ := claims["azp"].(string); azp != p.cfg.ClientID {
+			return nil, errors.New("oidc: id token issued to another client")
+		}
+	}
+	if n, _ := claims["nonce"].(string); n == "" || n != nonce {
+		return nil, errors.New("oidc: id token nonce mismatch")
+	}
+
+	ident := &ExternalIdentity{Issuer: meta.Issuer}
+	ident.Subject, _ = claims["sub"].(string)
+	if ident.Subject == "" {
+		return nil, errors.New("oidc: id token without sub")
+	}
+	ident.Login, _ = claims[p.cfg.LoginClaim].(string)
+	if ident.Login == "" {
+		return nil, fmt.Errorf("oidc: id token without %s claim", p.cfg.LoginClaim)
+	}
+	switch groups := claims[p.cfg.GroupsClaim].(type) {
+	case string:
+		ident.Groups = []string{groups}
+	case []any:
+		for _, g := range groups {
+			if s, ok := g.(string); ok {
+				ident.Groups = append(ident.Groups, s)
+			}
+		}
+	}
+	return ident, nil
+}
+
+// keyFunc выбирает ключ провайдера по kid; незнакомый kid означает ротацию ключей у провайдера
+func (p *OIDCProvider) keyFunc(ctx context.Context, meta *oidcMetadata) jwt.Keyfunc {
+	return func(token *jwt.Token) (interface{}, error) {
+		kid, _ := token.Header["kid"].(string)
+
+		p.mu.Lock()
+		key, ok := p.findKey(kid)
+		stale := time.Since(p.keysLoadedAt) >= oidcKeysRefreshInterval
+		p.mu.Unlock()
+		if !ok && stale {
+			keys, err := p.fetchKeys(ctx, meta.JWKSURI)
+			if err != nil {
+				return nil, err
+			}
+			p.mu.Lock()
+			p.keys = keys
+			p.keysLoadedAt = time.Now()
+			key, ok = p.findKey(kid)
+			p.mu.Unlock()
+		}
+		if !ok {
+			return nil, fmt.Errorf("unknown signing key %q", kid)
+		}
+
+		// алгоритм задаёт тип ключа, а не заголовок токена
+		switch key.(type) {
+		case *rsa.PublicKey:
+			ok = token.Method.Alg() == "RS256"
+		case *ecdsa.PublicKey:
+			ok = token.Method.Alg() == "ES256"
+		case ed25519.PublicKey:
+			ok = token.Method.Alg() == "EdDSA"
+		}
+		if !ok {
+			return nil, fmt.Errorf("unexpected signing method %s for key %q", token.Method.Alg(), kid)
+		}
+		return key, nil
+	}
+}
+
+// findKey — токен без kid принимается, только если у провайдера единственный ключ.
+// Вызывается под mu.
+func (p *OIDCProvider) findKey(kid string) (crypto.PublicKey, bool) {
+	if kid == "" && len(p.keys) == 1 {
+		for _, k := range p.keys {
+			return k, true
+		}
+	}
+	k, ok := p.keys[kid]
+	return k, ok
+}
+
+// fetchKeys читает ключи провайдера (JWKS); сохраняет их в кэш вызывающий
+func (p *OIDCProvider) fetchKeys(ctx context.Context, jwksURI string) (map[string]crypto.PublicKey, error) {
+	var set struct {
+		Keys []oidcJWK `json:"keys"`
+	}
+	if err := p.getJSON(ctx, jwksURI, &set); err != nil {
+		return nil, err
+	}
+	keys := map[string]crypto.PublicKey{}
+	for _, k := range set.Keys {
+		if k.Use != "" && k.Use != "sig" {
+			continue
+		}
+		key, err := parseOIDCKey(k)
+		if err != nil {
+			return nil, fmt.Errorf("oidc: key %q: %w", k.KeyID, err)
+		}
+		if key != nil {
+			keys[k.KeyID] = key
+		}
+	}
+	return keys, nil
+}
+
+// parseOIDCKey разбирает ключ RSA, EC P-256 или Ed25519; ключи других типов пропускаются
+func parseOIDCKey(k oidcJWK) (crypto.PublicKey, error) {
+	switch k.KeyType {
+	case "RSA":
+		n, err := base64.RawURLEncoding.DecodeString(k.N)
+		if err != nil {
+			return nil, err
+		}
+		e, err := base64.RawURLEncoding.DecodeString(k.E)
+		if err != nil {
+			return nil, err
+		}
+		key := &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: int(new(big.Int).SetBytes(e).Int64())}
+		if key.N.BitLen() < minRSAKeyBits {
+			return nil, fmt.Errorf("rsa key must be at least %d bits", minRSAKeyBits)
+		}
+		return key, nil
+	case "EC":
+		if k.Curve != "P-256" {
+			return nil, nil
+		}
+		x, err := base64.RawURLEncoding.DecodeString(k.X)
+		if err != nil {
+			return nil, err
+		}
+		y, err := base64.RawURLEncoding.DecodeString(k.Y)
+		if err != nil {
+			return nil, err
+		}
+		if len(x) != 32 || len(y) != 32 {
+			return nil, errors.New("invalid P-256 point")
+		}
+		return ecdsa.ParseUncompressedPublicKey(elliptic.P256(), append(append([]byte{4}, x...), y...))
+	case "OKP":
+		if k.Curve != "Ed25519" {
+			return nil, nil
+		}
+		x, err := base64.RawURLEncoding.DecodeString(k.X)
+		if err != nil {
+			return nil, err
+		}
+		if len(x) != ed25519.PublicKeySize {
+			return nil, errors.New("invalid Ed25519 key")
+		}
+		return ed25519.PublicKey(x), nil
+	}
+	return nil, nil
+}
+
+// metadata читает настройки провайдера (OpenID Connect Discovery) и кэширует их
+func (p *OIDCProvider) metadata(ctx context.Context) (*oidcMetadata, error) {
+	if !p.cfg.Enabled {
+		return nil, errors.New("oidc: provider is not configured")
+	}
+	p.mu.Lock()
+	cached := p.meta
+	p.mu.Unlock()
+	if cached != nil {
+		return cached, nil
+	}
+
+	var meta oidcMetadata
+	if err := p.getJSON(ctx, strings.TrimSuffix(p.cfg.IssuerURL, "/")+oidcDiscoveryPath, &meta); err != nil {
+		return nil, err
+	}
+	// issuer из настроек должен совпасть с объявленным провайдером, иначе ID токены не сверить
+	if meta.Issuer != p.cfg.IssuerURL {
+		return nil, fmt.Errorf("oidc: issuer mismatch: configured %q, provider reports %q", p.cfg.IssuerURL, meta.Issuer)
+	}
+	if meta.AuthorizationEndpoint == "" || meta.TokenEndpoint == "" || meta.JWKSURI == "" {
+		return nil, errors.New("oidc: provider metadata lacks authorization, token or jwks endpoint")
+	}
+	if len(meta.CodeChallengeMethods) > 0 && !slices.Contains(meta.CodeChallengeMethods, "S256") {
+		return nil, errors.New("oidc: provider does not support PKCE S256")
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	// параллельный вход мог прочитать настройки раньше — остаётся первый результат
+	if p.meta == nil {
+		p.meta = &meta
+	}
+	return p.meta, nil
+}
+
+func (p *OIDCProvider) getJSON(ctx context.Context, u string, v any) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u, nil)
+	if err != nil {
+		return fmt.Errorf("oidc: %w", err)
+	}
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("oidc: %w", err)
+	}
+	defer func() {
+		_ = resp.Body.Close()
+	}()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("oidc: GET %s: status %d", u, resp.StatusCode)
+	}
+	if err := json.NewDecoder(io.LimitReader(resp.Body, oidcMaxResponse)).Decode(v); err != nil {
+		return fmt.Errorf("oidc: GET %s: %w", u, err)
+	}
+	return nil
+}
+
+// pkceChallenge — code_challenge метода S256 (RFC 7636): base64url от SHA-256 verifier
+func pkceChallenge(verifier string) string {
+	sum := sha256.Sum256([]byte(verifier))
+	return base64.RawURLEncoding.EncodeToString(sum[:])
+}
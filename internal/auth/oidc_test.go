@@ -0,0 +1,164 @@
+package auth_test
+
+import (
+	"context"
+	"errors"
+	"net/url"
+	"slices"
+	"testing"
+	"time"
+
+	"warehousecontrol/internal/auth"
+	"warehousecontrol/internal/auth/oidctest"
+	"warehousecontrol/internal/config"
+)
+
+const testVerifier = "0123456789abcdef0123456789abcdef0123456789a"
+
+func oidcConfig(issuer string) *config.AppConfig {
+	return &config.AppConfig{OIDCConfig: config.OIDCConfig{
+		Enabled:      true,
+		IssuerURL:    issuer,
+		ClientID:     "warehouse",
+		ClientSecret: "s3cret",
+		RedirectURL:  "http://localhost:8080/sso/callback",
+		Scopes:       []string{"profile", "groups"},
+	}}
+}
+
+func newTestIdP(t *testing.T) (*oidctest.Provider, *auth.OIDCProvider) {
+	t.Helper()
+	idp := oidctest.NewProvider("warehouse", "s3cret")
+	t.Cleanup(idp.Close)
+	p, err := auth.NewOIDCProvider(oidcConfig(idp.Issuer()))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	return idp, p
+}
+
+// authorize проходит вход у провайдера и возвращает код авторизации
+func authorize(t *testing.T, idp *oidctest.Provider, p *auth.OIDCProvider, nonce string) string {
+	t.Helper()
+	authURL, err := p.AuthCodeURL(t.Context(), "state-1", nonce, testVerifier)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	back, err := idp.Authorize(authURL)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if back.Get("state") != "state-1" || back.Get("code") == "" {
+		t.Fatalf("unexpected redirect %v", back)
+	}
+	return back.Get("code")
+}
+
+func TestOIDCProvider_CodeFlow(t *testing.T) {
+	idp, p := newTestIdP(t)
+	idp.SignIn("sub-1", map[string]any{"preferred_username": "jdoe", "groups": []string{"wh-admins", "staff"}})
+
+	authURL, err := p.AuthCodeURL(t.Context(), "state-1", "nonce-1", testVerifier)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	u, _ := url.Parse(authURL)
+	q := u.Query()
+	if q.Get("code_challenge_method") != "S256" || q.Get("code_challenge") == "" || q.Get("code_challenge") == testVerifier {
+		t.Fatalf("expected S256 challenge, got %v", q)
+	}
+	if q.Get("scope") != "openid profile groups" || q.Get("nonce") != "nonce-1" {
+		t.Fatalf("unexpected query %v", q)
+	}
+
+	code := authorize(t, idp, p, "nonce-1")
+	ident, err := p.Exchange(t.Context(), code, testVerifier, "nonce-1")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if ident.Issuer != idp.Issuer() || ident.Subject != "sub-1" || ident.Login != "jdoe" || !slices.Equal(ident.Groups, []string{"wh-admins", "staff"}) {
+		t.Fatalf("unexpected identity %+v", ident)
+	}
+
+	// код одноразовый
+	if _, err := p.Exchange(t.Context(), code, testVerifier, "nonce-1"); err == nil {
+		t.Fatal("expected reused code to be rejected")
+	}
+	// без verifier, на хэш которого выдан код, обмен не проходит
+	code = authorize(t, idp, p, "nonce-1")
+	if _, err := p.Exchange(t.Context(), code, testVerifier+"x", "nonce-1"); err == nil {
+		t.Fatal("expected wrong code_verifier to be rejected")
+	}
+}
+
+func TestOIDCProvider_RejectsInvalidIDTokens(t *testing.T) {
+	for name, claims := range map[string]map[string]any{
+		"other audience": {"aud": "someone-else"},
+		"other issuer":   {"iss": "https://evil.example.com"},
+		"expired":        {"exp": time.Now().Add(-time.Hour).Unix()},
+		"no login":       {"preferred_username": nil},
+		"foreign azp":    {"aud": []string{"warehouse", "other"}, "azp": "other"},
+		"replayed nonce": {"nonce": "nonce-0"},
+	} {
+		t.Run(name, func(t *testing.T) {
+			idp, p := newTestIdP(t)
+			base := map[string]any{"preferred_username": "jdoe"}
+			for k, v := range claims {
+				base[k] = v
+			}
+			idp.SignIn("sub-1", base)
+			code := authorize(t, idp, p, "nonce-1")
+			if ident, err := p.Exchange(t.Context(), code, testVerifier, "nonce-1"); err == nil {
+				t.Fatalf("expected rejection, got %+v", ident)
+			}
+		})
+	}
+}
+
+func TestOIDCProvider_IssuerMismatch(t *testing.T) {
+	idp := oidctest.NewProvider("warehouse", "s3cret")
+	defer idp.Close()
+	// провайдер объявляет issuer без завершающего слэша
+	p, err := auth.NewOIDCProvider(oidcConfig(idp.Issuer() + "/"))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if _, err := p.AuthCodeURL(t.Context(), "state", "nonce", testVerifier); err == nil {
+		t.Fatal("expected issuer mismatch")
+	}
+}
+
+func TestOIDCProvider_CanceledContext(t *testing.T) {
+	idp, p := newTestIdP(t)
+	ctx, cancel := context.WithCancel(t.Context())
+	cancel()
+	// отменённый запрос клиента не доходит до провайдера и не портит кэш
+	if _, err := p.AuthCodeURL(ctx, "state", "nonce", testVerifier); !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected canceled, got %v", err)
+	}
+	idp.SignIn("sub-1", map[string]any{"preferred_username": "jdoe"})
+	if _, err := p.Exchange(t.Context(), authorize(t, idp, p, "nonce-1"), testVerifier, "nonce-1"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
+
+func TestNewOIDCProvider_Config(t *testing.T) {
+	p, err := auth.NewOIDCProvider(&config.AppConfig{})
+	if err != nil || p.Enabled() {
+		t.Fatalf("expected disabled provider, got %v", err)
+	}
+	if _, err := p.AuthCodeURL(t.Context(), "state", "nonce", testVerifier); err == nil {
+		t.Fatal("expected error from disabled provider")
+	}
+
+	cfg := oidcConfig("https://sso.example.com")
+	cfg.OIDCConfig.ClientID = ""
+	if _, err := auth.NewOIDCProvider(cfg); err == nil {
+		t.Fatal("expected error without client_id")
+	}
+	cfg = oidcConfig("https://sso.example.com")
+	cfg.OIDCConfig.GroupRoles = []config.GroupRole{{Group: "wh-admins"}}
+	if _, err := auth.NewOIDCProvider(cfg); err == nil {
+		t.Fatal("expected error for mapping without role")
+	}
+}
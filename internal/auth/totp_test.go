@@ -0,0 +1,79 @@
+package auth_test
+
+import (
+	"encoding/base32"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"warehousecontrol/internal/auth"
+)
+
+// секрет из приложения B RFC 6238 для SHA1
+var rfcSecret = base32.StdEncoding.EncodeToString([]byte("12345678901234567890"))
+
+func TestTOTPCode_RFC6238(t *testing.T) {
+	// шестизначные коды — младшие разряды восьмизначных из таблицы RFC
+	cases := map[int64]string{
+		59:          "287082",
+		1111111109:  "081804",
+		1111111111:  "050471",
+		1234567890:  "005924",
+		2000000000:  "279037",
+		20000000000: "353130",
+	}
+	for unix, want := range cases {
+		got, err := auth.TOTPCode(rfcSecret, auth.TOTPStep(time.Unix(unix, 0)))
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if got != want {
+			t.Fatalf("time %d: expected %s, got %s", unix, want, got)
+		}
+	}
+}
+
+func TestValidateTOTP_Window(t *testing.T) {
+	now := time.Unix(1111111111, 0)
+	step := auth.TOTPStep(now)
+
+	prev, _ := auth.TOTPCode(rfcSecret, step-1)
+	if got, ok := auth.ValidateTOTP(rfcSecret, prev, now, 1); !ok || got != step-1 {
+		t.Fatalf("expected previous step to be accepted, got %d, %v", got, ok)
+	}
+	if _, ok := auth.ValidateTOTP(rfcSecret, prev, now, 0); ok {
+		t.Fatal("expected previous step to be rejected without window")
+	}
+	old, _ := auth.TOTPCode(rfcSecret, step-2)
+	if _, ok := auth.ValidateTOTP(rfcSecret, old, now, 1); ok {
+		t.Fatal("expected code outside window to be rejected")
+	}
+	if _, ok := auth.ValidateTOTP(rfcSecret, "12345", now, 1); ok {
+		t.Fatal("expected short code to be rejected")
+	}
+}
+
+func TestGenerateTOTPSecret(t *testing.T) {
+	secret, err := auth.GenerateTOTPSecret()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(secret) != 32 || strings.Contains(secret, "=") {
+		t.Fatalf("unexpected secret %q", secret)
+	}
+	if _, err := auth.TOTPCode(secret, 1); err != nil {
+		t.Fatalf("expected secret to be usable, got %v", err)
+	}
+
+	uri, err := url.Parse(auth.TOTPURI("Warehouse Control", "john", secret))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if uri.Scheme != "otpauth" || uri.Host != "totp" || uri.Path != "/Warehouse Control:john" {
+		t.Fatalf("unexpected uri: %s", uri)
+	}
+	if q := uri.Query(); q.Get("secret") != secret || q.Get("issuer") != "Warehouse Control" || q.Get("digits") != "6" || q.Get("period") != "30" {
+		t.Fatalf("unexpected uri params: %s", uri.RawQuery)
+	}
+}
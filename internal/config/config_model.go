@@ -14,6 +14,10 @@ type AppConfig struct {
 	UserConfig     UserConfig     `mapstructure:"username_config"`
 	PasswordConfig PasswordConfig `mapstructure:"password_config"`
 	ItemConfig     ItemConfig     `mapstructure:"item_config"`
+	AuthConfig     AuthConfig     `mapstructure:"auth_config"`
+	LockoutConfig  LockoutConfig  `mapstructure:"lockout"`
+	OIDCConfig     OIDCConfig     `mapstructure:"oidc"`
+	LDAPConfig     LDAPConfig     `mapstructure:"ldap"`
 }
 
 type RetrysConfig struct {
@@ -29,6 +33,8 @@ type ginConfig struct {
 type ServerConfig struct {
 	Host string `mapstructure:"host" default:"localhost"`
 	Port int    `mapstructure:"port" default:"8080"`
+	// TrustedProxies — адреса или подсети прокси, которым доверяется X-Forwarded-For; пусто — никому
+	TrustedProxies []string `mapstructure:"trusted_proxies"`
 }
 
 type loggerConfig struct {
@@ -50,13 +56,44 @@ type dbConfig struct {
 	MaxOpenConns    int              `mapstructure:"max_open_conns"`
 	MaxIdleConns    int              `mapstructure:"max_idle_conns"`
 	ConnMaxLifetime time.Duration    `mapstructure:"conn_max_lifetime"`
+	// QueryTimeout — предел на запросы к базе одного вызова репозитория; 0 — без предела,
+	// запрос прерывается только вместе с HTTP запросом. На потоковый экспорт не действует.
+	QueryTimeout time.Duration `mapstructure:"query_timeout" default:"5s"`
+	Replicas     ReplicaConfig `mapstructure:"replicas"`
+	// AutoMigrate — применять встроенные миграции при старте, до запуска HTTP сервера
+	AutoMigrate bool `mapstructure:"auto_migrate"`
+}
+
+// ReplicaConfig — чтение списков, истории и отчётов с реплик из slaves
+type ReplicaConfig struct {
+	// MaxLag — реплика, отставшая сильнее, не используется до следующей проверки
+	MaxLag        time.Duration `mapstructure:"max_lag" default:"5s"`
+	CheckInterval time.Duration `mapstructure:"check_interval" default:"5s"`
+	// StickyWindow — сколько клиент хранит метку своей последней записи: пока реплики не
+	// применили журнал до неё, клиент читает с мастера
+	StickyWindow time.Duration `mapstructure:"sticky_window" default:"10s"`
 }
 
 type JwtConfig struct {
 	JwtExpAccessToken  int `mapstructure:"jwt_exp_access_token"`
 	JwtExpRefreshToken int `mapstructure:"jwt_exp_refresh_token"`
-	JwtAccessSecret    string
-	JwtRefreshSecret   string
+	// Issuer и Audience попадают в iss и aud access токенов и проверяются при разборе
+	Issuer   string `mapstructure:"issuer" default:"warehousecontrol"`
+	Audience string `mapstructure:"audience" default:"warehousecontrol-api"`
+	// Keys — ключи RS256/EdDSA; без них токены подписываются HS256 секретом JWT_ACCESS_SECRET
+	Keys            []JwtKeyConfig `mapstructure:"keys"`
+	JwtAccessSecret string
+}
+
+// JwtKeyConfig — ключ подписи в PEM. С закрытым ключом сервис подписывает токены,
+// с одним открытым — только проверяет. Время — в RFC3339: с activate_at ключ подписывает
+// новые токены, после retire_at подписанные им токены не принимаются.
+type JwtKeyConfig struct {
+	KID            string `mapstructure:"kid"`
+	PrivateKeyFile string `mapstructure:"private_key_file"`
+	PublicKeyFile  string `mapstructure:"public_key_file"`
+	ActivateAt     string `mapstructure:"activate_at"`
+	RetireAt       string `mapstructure:"retire_at"`
 }
 
 type UserConfig struct {
@@ -75,6 +112,92 @@ type PasswordConfig struct {
 }
 
 type ItemConfig struct {
-	NameMinLength int `mapstructure:"name_min_length"`
-	NameMaxLegth  int `mapstructure:"name_max_length"`
+	NameMinLength   int `mapstructure:"name_min_length"`
+	NameMaxLegth    int `mapstructure:"name_max_length"`
+	DefaultPageSize int `mapstructure:"default_page_size" default:"50"`
+	MaxPageSize     int `mapstructure:"max_page_size" default:"500"`
+}
+
+type AuthConfig struct {
+	// SetupToken — одноразовый токен создания первого администратора, задаётся через SETUP_TOKEN
+	SetupToken       string
+	InvitationTTL    time.Duration `mapstructure:"invitation_ttl" default:"72h"`
+	InvitationMaxTTL time.Duration `mapstructure:"invitation_max_ttl" default:"720h"`
+	PasswordResetTTL time.Duration `mapstructure:"password_reset_ttl" default:"1h"`
+	// TOTP — второй фактор входа. RequireAdminTOTP делает его обязательным для администраторов.
+	TOTPIssuer       string        `mapstructure:"totp_issuer" default:"WarehouseControl"`
+	TOTPWindow       int           `mapstructure:"totp_window" default:"1"`
+	RequireAdminTOTP bool          `mapstructure:"require_admin_totp"`
+	MFATokenTTL      time.Duration `mapstructure:"mfa_token_ttl" default:"5m"`
+	// RoleCacheTTL — как долго сервис держит роли в памяти; изменения ролей на других
+	// экземплярах сервиса видны не позже этого срока. 0 — читать роли из базы каждый раз.
+	RoleCacheTTL time.Duration `mapstructure:"role_cache_ttl" default:"30s"`
+	// Authenticators — источники учётных записей для входа по паролю в порядке опроса:
+	// local (пароли в базе) и ldap. Пусто — local, а при включённом ldap и он следом.
+	Authenticators []string `mapstructure:"authenticators"`
+}
+
+// LockoutConfig — защита входа от перебора: после MaxFailures неудачных попыток за Window
+// логин или IP блокируются на Cooldown. Нулевой порог отключает соответствующий счётчик.
+type LockoutConfig struct {
+	Window           time.Duration `mapstructure:"window" default:"15m"`
+	LoginMaxFailures int           `mapstructure:"login_max_failures" default:"5"`
+	LoginCooldown    time.Duration `mapstructure:"login_cooldown" default:"15m"`
+	IPMaxFailures    int           `mapstructure:"ip_max_failures" default:"50"`
+	IPCooldown       time.Duration `mapstructure:"ip_cooldown" default:"15m"`
+}
+
+// OIDCConfig — вход через OpenID Connect провайдера (authorization code + PKCE).
+// Пользователь заводится при первом входе, роль берётся из групп провайдера по GroupRoles:
+// выигрывает первое совпадение в порядке списка, без совпадений — DefaultRole, а если она
+// пуста, вход запрещён.
+type OIDCConfig struct {
+	Enabled   bool   `mapstructure:"enabled"`
+	IssuerURL string `mapstructure:"issuer_url"`
+	ClientID  string `mapstructure:"client_id"`
+	// ClientSecret задаётся через OIDC_CLIENT_SECRET; пусто — публичный клиент, только PKCE
+	ClientSecret string
+	RedirectURL  string   `mapstructure:"redirect_url"`
+	Scopes       []string `mapstructure:"scopes"`
+	// LoginClaim — claim ID токена с логином пользователя, GroupsClaim — со списком групп
+	LoginClaim  string      `mapstructure:"login_claim" default:"preferred_username"`
+	GroupsClaim string      `mapstructure:"groups_claim" default:"groups"`
+	GroupRoles  []GroupRole `mapstructure:"group_roles"`
+	DefaultRole string      `mapstructure:"default_role"`
+	// StateTTL — сколько ждать возврата пользователя от провайдера
+	StateTTL time.Duration `mapstructure:"state_ttl" default:"10m"`
+}
+
+// LDAPConfig — вход по паролю из LDAP / Active Directory: сервис ищет запись пользователя
+// по UserFilter (от имени BindDN или анонимно), проверяет пароль bind'ом от её DN и берёт
+// группы из GroupAttribute записи или, если задан GroupBaseDN, поиском по GroupFilter.
+// Группы (DN) сопоставляются ролям так же, как у OIDC; учётная запись заводится при первом входе.
+type LDAPConfig struct {
+	Enabled bool `mapstructure:"enabled"`
+	// URL — ldap://host:389 или ldaps://host:636
+	URL                string `mapstructure:"url"`
+	StartTLS           bool   `mapstructure:"start_tls"`
+	InsecureSkipVerify bool   `mapstructure:"insecure_skip_verify"`
+	BindDN             string `mapstructure:"bind_dn"`
+	// BindPassword задаётся через LDAP_BIND_PASSWORD
+	BindPassword string
+	UserBaseDN   string `mapstructure:"user_base_dn"`
+	// UserFilter — фильтр поиска пользователя, %s заменяется экранированным логином
+	UserFilter     string `mapstructure:"user_filter" default:"(uid=%s)"`
+	LoginAttribute string `mapstructure:"login_attribute" default:"uid"`
+	// IDAttribute — неизменный идентификатор записи (entryUUID, objectGUID); пусто — DN
+	IDAttribute    string `mapstructure:"id_attribute"`
+	GroupAttribute string `mapstructure:"group_attribute" default:"memberOf"`
+	GroupBaseDN    string `mapstructure:"group_base_dn"`
+	// GroupFilter — фильтр поиска групп пользователя, %s заменяется экранированным DN пользователя
+	GroupFilter string        `mapstructure:"group_filter" default:"(member=%s)"`
+	GroupRoles  []GroupRole   `mapstructure:"group_roles"`
+	DefaultRole string        `mapstructure:"default_role"`
+	Timeout     time.Duration `mapstructure:"timeout" default:"5s"`
+}
+
+// GroupRole сопоставляет группу внешнего каталога или провайдера роли сервиса
+type GroupRole struct {
+	Group string `mapstructure:"group"`
+	Role  string `mapstructure:"role"`
 }
@@ -33,8 +33,21 @@ func NewAppConfig() (*AppConfig, error) {
 	appCfg.DBConfig.Master.DBName = os.Getenv("POSTGRES_DB")
 	appCfg.DBConfig.Master.User = os.Getenv("POSTGRES_USER")
 	appCfg.DBConfig.Master.Password = os.Getenv("POSTGRES_PASSWORD")
+	// физическая реплика — копия мастера с теми же базой и пользователями
+	for i := range appCfg.DBConfig.Slaves {
+		slave := &appCfg.DBConfig.Slaves[i]
+		if slave.DBName == "" {
+			slave.DBName = appCfg.DBConfig.Master.DBName
+		}
+		if slave.User == "" {
+			slave.User, slave.Password = appCfg.DBConfig.Master.User, appCfg.DBConfig.Master.Password
+		}
+	}
 
 	appCfg.JwtConfig.JwtAccessSecret = os.Getenv("JWT_ACCESS_SECRET")
-	appCfg.JwtConfig.JwtRefreshSecret = os.Getenv("JWT_REFRESH_SECRET")
+
+	appCfg.AuthConfig.SetupToken = os.Getenv("SETUP_TOKEN")
+	appCfg.OIDCConfig.ClientSecret = os.Getenv("OIDC_CLIENT_SECRET")
+	appCfg.LDAPConfig.BindPassword = os.Getenv("LDAP_BIND_PASSWORD")
 	return &appCfg, nil
 }
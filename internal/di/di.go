@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"log"
 	"net/http"
+	"net/url"
 
 	wbgin "github.com/wb-go/wbf/ginext"
 	"go.uber.org/fx"
@@ -15,14 +16,30 @@ import (
 	"warehousecontrol/internal/web/routers"
 )
 
-func StartHTTPServer(lc fx.Lifecycle, userHandler *handlers.UserHandler, itemHandler *handlers.ItemHandler, historyHandler *handlers.HistoryHandler, config *config.AppConfig) {
+func StartHTTPServer(lc fx.Lifecycle, userHandler *handlers.UserHandler, itemHandler *handlers.ItemHandler, historyHandler *handlers.HistoryHandler, warehouseHandler *handlers.WarehouseHandler, keysHandler *handlers.KeysHandler, ssoHandler *handlers.SSOHandler, config *config.AppConfig) error {
 	router := wbgin.New(config.GinConfig.Mode)
+	// адрес клиента из X-Forwarded-For принимается только от перечисленных прокси,
+	// иначе его можно подменить и обойти блокировку входа по IP
+	if err := router.Engine.SetTrustedProxies(config.ServerConfig.TrustedProxies); err != nil {
+		return fmt.Errorf("invalid trusted proxies: %w", err)
+	}
 
 	router.Use(wbgin.Logger(), wbgin.Recovery())
+	ssoOrigin := clientOrigin(config.OIDCConfig.RedirectURL)
 	router.Use(func(c *wbgin.Context) {
-		c.Writer.Header().Set("Access-Control-Allow-Origin", "*")
+		// страница клиента из oidc.redirect_url получает cookie со state входа через провайдера,
+		// поэтому ей запросы с учётными данными разрешены; остальным — только без них
+		if origin := c.GetHeader("Origin"); ssoOrigin != "" && origin == ssoOrigin {
+			c.Writer.Header().Set("Access-Control-Allow-Origin", origin)
+			c.Writer.Header().Set("Access-Control-Allow-Credentials", "true")
+		} else {
+			c.Writer.Header().Set("Access-Control-Allow-Origin", "*")
+		}
+		c.Writer.Header().Add("Vary", "Origin")
 		c.Writer.Header().Set("Access-Control-Allow-Methods", "POST, GET, OPTIONS, PUT, DELETE")
-		c.Writer.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization")
+		c.Writer.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization, If-Match, "+routers.WriteMarkHeader)
+		// ETag нужен клиенту для If-Match в PUT/DELETE товара, метка записи — чтобы читать свои изменения
+		c.Writer.Header().Set("Access-Control-Expose-Headers", "ETag, "+routers.WriteMarkHeader)
 		if c.Request.Method == "OPTIONS" {
 			c.AbortWithStatus(204)
 			return
@@ -30,7 +47,9 @@ func StartHTTPServer(lc fx.Lifecycle, userHandler *handlers.UserHandler, itemHan
 		c.Next()
 	})
 
-	routers.RegisterRoutes(router, userHandler, itemHandler, historyHandler)
+	router.Use(routers.ReadYourWrites(config.DBConfig.Replicas.StickyWindow))
+
+	routers.RegisterRoutes(router, userHandler, itemHandler, historyHandler, warehouseHandler, keysHandler, ssoHandler)
 
 	addres := fmt.Sprintf("%s:%d", config.ServerConfig.Host, config.ServerConfig.Port)
 	server := &http.Server{
@@ -53,6 +72,7 @@ func StartHTTPServer(lc fx.Lifecycle, userHandler *handlers.UserHandler, itemHan
 			return server.Close()
 		},
 	})
+	return nil
 }
 
 func ClosePostgresOnStop(lc fx.Lifecycle, postgres *postgres.Postgres) {
@@ -68,3 +88,12 @@ func ClosePostgresOnStop(lc fx.Lifecycle, postgres *postgres.Postgres) {
 		},
 	})
 }
+
+// clientOrigin — origin страницы клиента (схема, хост и порт) или пустая строка, если адрес не задан
+func clientOrigin(rawURL string) string {
+	u, err := url.Parse(rawURL)
+	if err != nil || u.Scheme == "" || u.Host == "" {
+		return ""
+	}
+	return u.Scheme + "://" + u.Host
+}
@@ -0,0 +1,47 @@
+package di
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log"
+
+	"warehousecontrol/internal/config"
+	"warehousecontrol/internal/storage/migrate"
+	"warehousecontrol/internal/storage/postgres"
+	"warehousecontrol/migrations"
+)
+
+// OpenMigrator подключается к мастеру для встроенных миграций; closeDB закрывает подключение
+func OpenMigrator(cfg *config.AppConfig) (m *migrate.Migrator, closeDB func() error, err error) {
+	db, err := sql.Open("postgres", postgres.MasterDSN(cfg))
+	if err != nil {
+		return nil, nil, err
+	}
+	m, err = migrate.New(db, migrations.FS)
+	if err != nil {
+		_ = db.Close()
+		return nil, nil, err
+	}
+	return m, db.Close, nil
+}
+
+// MigrateOnStart применяет миграции до запуска HTTP сервера, если включён db_config.auto_migrate.
+// Вызывается раньше StartHTTPServer: сервисы не должны обращаться к старой схеме.
+func MigrateOnStart(cfg *config.AppConfig) error {
+	if !cfg.DBConfig.AutoMigrate {
+		return nil
+	}
+	m, closeDB, err := OpenMigrator(cfg)
+	if err != nil {
+		return fmt.Errorf("auto migrate: %w", err)
+	}
+	defer closeDB()
+
+	applied, err := m.Up(context.Background())
+	if err != nil {
+		return fmt.Errorf("auto migrate: %w", err)
+	}
+	log.Printf("Migrations applied: %d", len(applied))
+	return nil
+}
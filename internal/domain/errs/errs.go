@@ -0,0 +1,53 @@
+package errs
+
+import (
+	"errors"
+	"fmt"
+)
+
+// Базовые категории ошибок. Доменные пакеты объявляют свои ошибки поверх них,
+// а хэндлеры по категории выбирают HTTP-статус.
+var (
+	ErrInvalidInput = errors.New("invalid input")
+	ErrValidation   = errors.New("validation failed")
+	ErrNotFound     = errors.New("not found")
+	ErrConflict     = errors.New("conflict")
+	ErrForbidden    = errors.New("forbidden")
+	ErrUnauthorized = errors.New("unauthorized")
+	ErrPrecondition = errors.New("precondition failed")
+	ErrRateLimited  = errors.New("too many requests")
+	// ErrUnavailable — недоступна внешняя система, без которой запрос не выполнить
+	ErrUnavailable = errors.New("service unavailable")
+)
+
+// Error — ошибка с сообщением для клиента, относящаяся к одной из базовых категорий
+type Error struct {
+	kind error
+	msg  string
+}
+
+func New(kind error, msg string) error {
+	return &Error{kind: kind, msg: msg}
+}
+
+func Errorf(kind error, format string, args ...any) error {
+	return &Error{kind: kind, msg: fmt.Sprintf(format, args...)}
+}
+
+func (e *Error) Error() string {
+	return e.msg
+}
+
+func (e *Error) Unwrap() error {
+	return e.kind
+}
+
+// Kind возвращает базовую категорию ошибки или nil, если ошибка не типизирована
+func Kind(err error) error {
+	for _, kind := range []error{ErrInvalidInput, ErrValidation, ErrNotFound, ErrConflict, ErrForbidden, ErrUnauthorized, ErrPrecondition, ErrRateLimited, ErrUnavailable} {
+		if errors.Is(err, kind) {
+			return kind
+		}
+	}
+	return nil
+}
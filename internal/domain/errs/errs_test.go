@@ -0,0 +1,27 @@
+package errs
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+)
+
+func TestError_IsKind(t *testing.T) {
+	err := New(ErrNotFound, "item not found")
+	if err.Error() != "item not found" {
+		t.Fatalf("unexpected message: %s", err.Error())
+	}
+	if !errors.Is(err, ErrNotFound) || errors.Is(err, ErrConflict) {
+		t.Fatalf("expected only ErrNotFound kind")
+	}
+}
+
+func TestKind_Wrapped(t *testing.T) {
+	err := fmt.Errorf("put item: %w", Errorf(ErrValidation, "price must be > %d", 0))
+	if Kind(err) != ErrValidation {
+		t.Fatalf("expected validation kind, got %v", Kind(err))
+	}
+	if Kind(errors.New("db down")) != nil {
+		t.Fatalf("expected nil kind for untyped error")
+	}
+}
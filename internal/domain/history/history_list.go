@@ -0,0 +1,64 @@
+package history
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"time"
+
+	"warehousecontrol/internal/domain/errs"
+	"warehousecontrol/internal/domain/item"
+
+	"github.com/google/uuid"
+)
+
+// Filter — условия выборки истории, общие для постраничного списка и выгрузки CSV
+type Filter struct {
+	ItemID string
+	From   time.Time
+	To     time.Time
+	Action string
+	Login  string
+}
+
+// Page — страница истории; NextCursor пуст, если записей больше нет
+type Page struct {
+	Items      []*History `json:"items"`
+	NextCursor string     `json:"next_cursor,omitempty"`
+}
+
+// Cursor — позиция в истории, упорядоченной по (changed_at, seq): ключ последней записи страницы
+// или граница выборки изменений товара
+type Cursor struct {
+	ChangedAt time.Time `json:"t"`
+	Seq       int64     `json:"s"`
+}
+
+func NewCursor(h *History) *Cursor {
+	return &Cursor{ChangedAt: h.ChangedAt, Seq: h.Seq}
+}
+
+func (c *Cursor) Encode() string {
+	data, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+func DecodeCursor(token string) (*Cursor, error) {
+	data, err := base64.RawURLEncoding.DecodeString(token)
+	if err != nil {
+		return nil, errs.New(errs.ErrInvalidInput, "invalid cursor")
+	}
+	var c Cursor
+	if err := json.Unmarshal(data, &c); err != nil || c.Seq <= 0 {
+		return nil, errs.New(errs.ErrInvalidInput, "invalid cursor")
+	}
+	return &c, nil
+}
+
+// VersionDiff — сравнение состояний товара в двух точках истории и изменения между ними
+type VersionDiff struct {
+	ItemID  uuid.UUID     `json:"item_id"`
+	From    item.Item     `json:"from"`
+	To      item.Item     `json:"to"`
+	Diff    item.ItemDiff `json:"item_diff"`
+	Changes []*History    `json:"changes"`
+}
@@ -0,0 +1,27 @@
+package history
+
+import (
+	"testing"
+	"time"
+)
+
+func TestCursor_RoundTrip(t *testing.T) {
+	at := time.Date(2025, 3, 1, 8, 30, 0, 123000, time.UTC)
+	c := NewCursor(&History{ChangedAt: at, Seq: 7})
+
+	decoded, err := DecodeCursor(c.Encode())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !decoded.ChangedAt.Equal(at) || decoded.Seq != 7 {
+		t.Fatalf("unexpected cursor: %+v", decoded)
+	}
+}
+
+func TestDecodeCursor_Invalid(t *testing.T) {
+	for _, token := range []string{"!!!", "e30"} {
+		if _, err := DecodeCursor(token); err == nil {
+			t.Fatalf("expected error for %q", token)
+		}
+	}
+}
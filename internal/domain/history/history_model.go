@@ -3,12 +3,30 @@ package history
 import (
 	"github.com/google/uuid"
 	"time"
+	"warehousecontrol/internal/domain/errs"
 	"warehousecontrol/internal/domain/item"
 )
 
+// ActionReverted — товар восстановлен из снимка другой записи истории (SourceHistoryID)
+const ActionReverted = "reverted"
+
+// ActionAdjusted — количество товара изменено на величину (приход или списание)
+const ActionAdjusted = "adjusted"
+
+// Действия записей об остатке в ячейке (LocationID): снимки в них содержат только id и count
+const (
+	ActionStockCreated = "stock_created"
+	ActionStockUpdated = "stock_updated"
+	ActionStockRemoved = "stock_removed"
+)
+
+var ErrNotFound = errs.New(errs.ErrNotFound, "history record not found")
+
 type History struct {
 	ID              uuid.UUID     `json:"id"`
+	Seq             int64         `json:"seq"`
 	ItemID          uuid.UUID     `json:"item_id"`
+	LocationID      *uuid.UUID    `json:"location_id,omitempty"`
 	Action          string        `json:"action"`
 	ChangedBy       uuid.UUID     `json:"changed_by"`
 	ChangedByLogin  string        `json:"changed_by_login"`
@@ -16,4 +34,5 @@ type History struct {
 	OldItemSnapshot item.Item     `json:"old_item_snapshot"`
 	NewItemSnapshot item.Item     `json:"new_item_snapshot"`
 	ItemDiff        item.ItemDiff `json:"item_diff"`
+	SourceHistoryID *uuid.UUID    `json:"source_history_id,omitempty"`
 }
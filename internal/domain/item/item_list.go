@@ -0,0 +1,100 @@
+package item
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"time"
+
+	"warehousecontrol/internal/domain/errs"
+
+	"github.com/google/uuid"
+)
+
+// Поля, по которым можно сортировать список товаров
+const (
+	SortByName  = "name"
+	SortByCount = "count"
+	SortByPrice = "price"
+)
+
+// ListOptions — параметры выборки списка товаров. Если задан Cursor, Offset не используется.
+type ListOptions struct {
+	Limit  int
+	Offset int
+	Cursor *Cursor
+	SortBy string
+	Desc   bool
+
+	Name      string
+	MinCount  *int
+	MaxCount  *int
+	MinPrice  *float64
+	MaxPrice  *float64
+	ZeroStock *bool
+
+	// AsOf — если задан, список строится по снимкам из истории на этот момент
+	AsOf *time.Time
+}
+
+// Page — страница списка товаров; Total считается по фильтрам без учёта пагинации
+type Page struct {
+	Items      []*Item `json:"items"`
+	Total      int     `json:"total"`
+	NextCursor string  `json:"next_cursor,omitempty"`
+}
+
+// Cursor — ключ последнего товара страницы для keyset-пагинации.
+// Хранит сортировку, чтобы курсор нельзя было применить к другому порядку.
+type Cursor struct {
+	SortBy string    `json:"s"`
+	Desc   bool      `json:"d,omitempty"`
+	Name   string    `json:"n,omitempty"`
+	Count  int       `json:"c,omitempty"`
+	Price  float64   `json:"p,omitempty"`
+	ID     uuid.UUID `json:"id"`
+}
+
+func NewCursor(it *Item, sortBy string, desc bool) *Cursor {
+	c := &Cursor{SortBy: sortBy, Desc: desc, ID: it.ID}
+	switch sortBy {
+	case SortByCount:
+		c.Count = it.Count
+	case SortByPrice:
+		c.Price = it.Price
+	default:
+		c.Name = it.Name
+	}
+	return c
+}
+
+// Value — значение поля сортировки, с которого продолжается выборка
+func (c *Cursor) Value() any {
+	switch c.SortBy {
+	case SortByCount:
+		return c.Count
+	case SortByPrice:
+		return c.Price
+	default:
+		return c.Name
+	}
+}
+
+func (c *Cursor) Encode() string {
+	data, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+func DecodeCursor(token string) (*Cursor, error) {
+	data, err := base64.RawURLEncoding.DecodeString(token)
+	if err != nil {
+		return nil, errs.New(errs.ErrInvalidInput, "invalid cursor")
+	}
+	var c Cursor
+	if err := json.Unmarshal(data, &c); err != nil || c.ID == uuid.Nil {
+		return nil, errs.New(errs.ErrInvalidInput, "invalid cursor")
+	}
+	if c.SortBy != SortByName && c.SortBy != SortByCount && c.SortBy != SortByPrice {
+		return nil, errs.New(errs.ErrInvalidInput, "invalid cursor")
+	}
+	return &c, nil
+}
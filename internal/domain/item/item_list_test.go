@@ -0,0 +1,28 @@
+package item
+
+import (
+	"testing"
+
+	"github.com/google/uuid"
+)
+
+func TestCursor_RoundTrip(t *testing.T) {
+	it := &Item{ID: uuid.New(), Name: "Bolt", Count: 3, Price: 12.5}
+	c := NewCursor(it, SortByPrice, true)
+
+	decoded, err := DecodeCursor(c.Encode())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if decoded.ID != it.ID || decoded.SortBy != SortByPrice || !decoded.Desc || decoded.Value() != 12.5 {
+		t.Fatalf("unexpected cursor: %+v", decoded)
+	}
+}
+
+func TestDecodeCursor_Invalid(t *testing.T) {
+	for _, token := range []string{"!!!", "e30", NewCursor(&Item{ID: uuid.New()}, "id", false).Encode()} {
+		if _, err := DecodeCursor(token); err == nil {
+			t.Fatalf("expected error for %q", token)
+		}
+	}
+}
@@ -1,38 +1,63 @@
 package item
 
 import (
-	"errors"
 	"github.com/google/uuid"
+
+	"warehousecontrol/internal/domain/errs"
+)
+
+var (
+	ErrNotFound = errs.New(errs.ErrNotFound, "item not found")
+	// ErrVersionMismatch — товар изменён после того, как клиент получил его версию
+	ErrVersionMismatch           = errs.New(errs.ErrPrecondition, "item version mismatch")
+	ErrInsufficientStock         = errs.New(errs.ErrConflict, "insufficient stock")
+	ErrInsufficientLocationStock = errs.New(errs.ErrConflict, "insufficient stock at location")
+	// ErrStockBelowLocations — списание без ячейки оставило бы в ячейках больше, чем общий остаток
+	ErrStockBelowLocations = errs.New(errs.ErrConflict, "total stock cant be less than stock held in locations")
 )
 
 type Item struct {
-	ID    uuid.UUID `json:"id"`
-	Name  string    `json:"name"`
-	Count int       `json:"count"`
-	Price float64   `json:"price"`
+	ID        uuid.UUID       `json:"id"`
+	Name      string          `json:"name"`
+	Count     int             `json:"count"`
+	Price     float64         `json:"price"`
+	Version   int             `json:"version"`
+	Locations []LocationStock `json:"locations,omitempty"`
+}
+
+// LocationStock — остаток товара в конкретной ячейке склада
+type LocationStock struct {
+	LocationID    uuid.UUID `json:"location_id"`
+	LocationCode  string    `json:"location_code"`
+	ZoneID        uuid.UUID `json:"zone_id"`
+	ZoneName      string    `json:"zone_name"`
+	WarehouseID   uuid.UUID `json:"warehouse_id"`
+	WarehouseName string    `json:"warehouse_name"`
+	Quantity      int       `json:"quantity"`
 }
 
 func NewItem(name string, count int, price float64) (*Item, error) {
 	if name == "" {
-		return nil, errors.New("name required")
+		return nil, errs.New(errs.ErrValidation, "name required")
 	}
 	if price <= 0 {
-		return nil, errors.New("price must be > 0")
+		return nil, errs.New(errs.ErrValidation, "price must be > 0")
 	}
 	return &Item{
-		ID:    uuid.New(),
-		Name:  name,
-		Count: count,
-		Price: price,
+		ID:      uuid.New(),
+		Name:    name,
+		Count:   count,
+		Price:   price,
+		Version: 1,
 	}, nil
 }
 
 func (i *Item) ChangeItem(name string, count int, price float64) error {
 	if name == "" {
-		return errors.New("name cant be empty")
+		return errs.New(errs.ErrValidation, "name cant be empty")
 	}
 	if price <= 0 {
-		return errors.New("price must be >0")
+		return errs.New(errs.ErrValidation, "price must be >0")
 	}
 	i.Name = name
 	i.Count = count
@@ -40,6 +65,14 @@ func (i *Item) ChangeItem(name string, count int, price float64) error {
 	return nil
 }
 
+// CheckLocationStock проверяет, что общий остаток покрывает сумму остатков по ячейкам
+func CheckLocationStock(count int, inLocations int) error {
+	if count < inLocations {
+		return ErrStockBelowLocations
+	}
+	return nil
+}
+
 type FieldDiff struct {
 	Old interface{} `json:"old"`
 	New interface{} `json:"new"`
@@ -51,6 +51,19 @@ func TestChangeItem_Invalid(t *testing.T) {
 	}
 }
 
+func TestCheckLocationStock(t *testing.T) {
+	// приход 10 в ячейку, затем списание 5 без ячейки
+	if err := CheckLocationStock(5, 10); err != ErrStockBelowLocations {
+		t.Fatalf("expected ErrStockBelowLocations, got %v", err)
+	}
+	if err := CheckLocationStock(10, 10); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if err := CheckLocationStock(7, 0); err != nil {
+		t.Fatalf("expected no error without location stock, got %v", err)
+	}
+}
+
 func TestDiff(t *testing.T) {
 	a := Item{Name: "A", Count: 1, Price: 1.0}
 	b := Item{Name: "B", Count: 2, Price: 1.5}
@@ -0,0 +1,93 @@
+package movement
+
+import (
+	"time"
+
+	"warehousecontrol/internal/domain/errs"
+
+	"github.com/google/uuid"
+)
+
+type Type string
+
+const (
+	Receipt    Type = "receipt"
+	Issue      Type = "issue"
+	Transfer   Type = "transfer"
+	Adjustment Type = "adjustment"
+)
+
+// Movement — запись журнала движения товара. Журнал только пополняется,
+// Item.Count всегда равен сумме CountDelta() всех движений товара.
+type Movement struct {
+	ID             uuid.UUID  `json:"id"`
+	ItemID         uuid.UUID  `json:"item_id"`
+	Type           Type       `json:"type"`
+	Quantity       int        `json:"quantity"`
+	LocationID     *uuid.UUID `json:"location_id,omitempty"`
+	ToLocationID   *uuid.UUID `json:"to_location_id,omitempty"`
+	Reason         string     `json:"reason"`
+	Reference      string     `json:"reference"`
+	CreatedBy      uuid.UUID  `json:"created_by"`
+	CreatedByLogin string     `json:"created_by_login"`
+	CreatedAt      time.Time  `json:"created_at"`
+}
+
+// NewMovement проверяет параметры движения. quantity для receipt/issue/transfer — положительное
+// количество, для adjustment — знаковая дельта. Для issue в Quantity сохраняется отрицательное значение.
+func NewMovement(t Type, itemID uuid.UUID, locationID, toLocationID *uuid.UUID, quantity int, reason, reference string) (*Movement, error) {
+	if itemID == uuid.Nil {
+		return nil, errs.New(errs.ErrValidation, "item id required")
+	}
+
+	switch t {
+	case Receipt, Issue:
+		if quantity <= 0 {
+			return nil, errs.New(errs.ErrValidation, "quantity must be > 0")
+		}
+		if toLocationID != nil {
+			return nil, errs.New(errs.ErrValidation, "destination location allowed only for transfer")
+		}
+		if t == Issue {
+			quantity = -quantity
+		}
+	case Transfer:
+		if quantity <= 0 {
+			return nil, errs.New(errs.ErrValidation, "quantity must be > 0")
+		}
+		if locationID == nil || toLocationID == nil {
+			return nil, errs.New(errs.ErrValidation, "transfer requires source and destination locations")
+		}
+		if *locationID == *toLocationID {
+			return nil, errs.New(errs.ErrValidation, "source and destination locations must differ")
+		}
+	case Adjustment:
+		if quantity == 0 {
+			return nil, errs.New(errs.ErrValidation, "adjustment delta cant be zero")
+		}
+		if toLocationID != nil {
+			return nil, errs.New(errs.ErrValidation, "destination location allowed only for transfer")
+		}
+	default:
+		return nil, errs.New(errs.ErrValidation, "invalid movement type")
+	}
+
+	return &Movement{
+		ID:           uuid.New(),
+		ItemID:       itemID,
+		Type:         t,
+		Quantity:     quantity,
+		LocationID:   locationID,
+		ToLocationID: toLocationID,
+		Reason:       reason,
+		Reference:    reference,
+	}, nil
+}
+
+// CountDelta — изменение общего остатка товара; перемещение между ячейками его не меняет
+func (m *Movement) CountDelta() int {
+	if m.Type == Transfer {
+		return 0
+	}
+	return m.Quantity
+}
@@ -0,0 +1,61 @@
+package movement
+
+import (
+	"testing"
+
+	"github.com/google/uuid"
+)
+
+func TestNewMovement_Receipt(t *testing.T) {
+	loc := uuid.New()
+	m, err := NewMovement(Receipt, uuid.New(), &loc, nil, 5, "supply", "INV-1")
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if m.Quantity != 5 || m.CountDelta() != 5 {
+		t.Fatalf("unexpected movement: %+v", m)
+	}
+}
+
+func TestNewMovement_IssueIsNegative(t *testing.T) {
+	m, err := NewMovement(Issue, uuid.New(), nil, nil, 3, "", "")
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if m.Quantity != -3 || m.CountDelta() != -3 {
+		t.Fatalf("expected negative delta, got %+v", m)
+	}
+}
+
+func TestNewMovement_TransferKeepsCount(t *testing.T) {
+	from, to := uuid.New(), uuid.New()
+	m, err := NewMovement(Transfer, uuid.New(), &from, &to, 2, "", "")
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if m.CountDelta() != 0 {
+		t.Fatalf("transfer must not change total count")
+	}
+}
+
+func TestNewMovement_Invalid(t *testing.T) {
+	loc := uuid.New()
+	cases := []struct {
+		name string
+		fn   func() (*Movement, error)
+	}{
+		{"nil item", func() (*Movement, error) { return NewMovement(Receipt, uuid.Nil, nil, nil, 1, "", "") }},
+		{"zero receipt", func() (*Movement, error) { return NewMovement(Receipt, uuid.New(), nil, nil, 0, "", "") }},
+		{"negative issue", func() (*Movement, error) { return NewMovement(Issue, uuid.New(), nil, nil, -1, "", "") }},
+		{"receipt with destination", func() (*Movement, error) { return NewMovement(Receipt, uuid.New(), nil, &loc, 1, "", "") }},
+		{"transfer without source", func() (*Movement, error) { return NewMovement(Transfer, uuid.New(), nil, &loc, 1, "", "") }},
+		{"transfer to same location", func() (*Movement, error) { return NewMovement(Transfer, uuid.New(), &loc, &loc, 1, "", "") }},
+		{"zero adjustment", func() (*Movement, error) { return NewMovement(Adjustment, uuid.New(), nil, nil, 0, "", "") }},
+		{"unknown type", func() (*Movement, error) { return NewMovement(Type("bad"), uuid.New(), nil, nil, 1, "", "") }},
+	}
+	for _, c := range cases {
+		if _, err := c.fn(); err == nil {
+			t.Fatalf("%s: expected error", c.name)
+		}
+	}
+}
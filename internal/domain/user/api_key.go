@@ -0,0 +1,110 @@
+package user
+
+import (
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+
+	"warehousecontrol/internal/domain/errs"
+)
+
+var (
+	ErrAPIKeyNotFound = errs.New(errs.ErrNotFound, "api key not found")
+	// ErrAPIKeyInvalid не уточняет, ключ неизвестен, истёк или отозван
+	ErrAPIKeyInvalid       = errs.New(errs.ErrUnauthorized, "invalid, expired or revoked api key")
+	ErrAPIKeyInactive      = errs.New(errs.ErrConflict, "api key is expired or revoked")
+	ErrAPIKeyScope         = errs.New(errs.ErrForbidden, "api key scopes do not allow this request")
+	ErrNotServiceAccount   = errs.New(errs.ErrConflict, "api keys can only be issued to service accounts")
+	ErrServiceAccountLogin = errs.New(errs.ErrForbidden, "service accounts authenticate with api keys only")
+)
+
+// APIKeyPrefix отличает API ключ от JWT в заголовке Authorization
+const APIKeyPrefix = "wck_"
+
+// Области действия API ключа: ресурс и доступ на чтение или запись. Запись включает чтение.
+const (
+	ScopeItemsRead       = "items:read"
+	ScopeItemsWrite      = "items:write"
+	ScopeWarehousesRead  = "warehouses:read"
+	ScopeWarehousesWrite = "warehouses:write"
+	ScopeHistoryRead     = "history:read"
+)
+
+var APIKeyScopes = []string{ScopeItemsRead, ScopeItemsWrite, ScopeWarehousesRead, ScopeWarehousesWrite, ScopeHistoryRead}
+
+// APIKey — долгоживущий ключ сервисной учётной записи для интеграций. В базе хранится
+// только хэш ключа, Prefix — его начало для опознания в списках.
+type APIKey struct {
+	ID         uuid.UUID
+	UserID     uuid.UUID
+	Name       string
+	Prefix     string
+	KeyHash    []byte
+	Scopes     []string
+	CreatedAt  time.Time
+	ExpiresAt  *time.Time
+	LastUsedAt *time.Time
+	RevokedAt  *time.Time
+}
+
+// NewAPIKey создаёт ключ и возвращает его значение — оно показывается один раз.
+// Нулевой ttl — ключ без срока действия.
+func NewAPIKey(userID uuid.UUID, name string, scopes []string, ttl time.Duration) (*APIKey, string, error) {
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return nil, "", errs.New(errs.ErrValidation, "api key name required")
+	}
+	if len(scopes) == 0 {
+		return nil, "", errs.New(errs.ErrValidation, "at least one api key scope required")
+	}
+	for _, s := range scopes {
+		if !slices.Contains(APIKeyScopes, s) {
+			return nil, "", errs.Errorf(errs.ErrValidation, "unknown api key scope: %s", s)
+		}
+	}
+	if ttl < 0 {
+		return nil, "", errs.New(errs.ErrValidation, "api key ttl must be >= 0")
+	}
+	secret, err := newSecretToken()
+	if err != nil {
+		return nil, "", err
+	}
+	token := APIKeyPrefix + secret
+	now := time.Now()
+	k := &APIKey{
+		ID:        uuid.New(),
+		UserID:    userID,
+		Name:      name,
+		Prefix:    token[:len(APIKeyPrefix)+6],
+		KeyHash:   HashAPIKey(token),
+		Scopes:    slices.Compact(slices.Sorted(slices.Values(scopes))),
+		CreatedAt: now,
+	}
+	if ttl > 0 {
+		expiresAt := now.Add(ttl)
+		k.ExpiresAt = &expiresAt
+	}
+	return k, token, nil
+}
+
+func HashAPIKey(token string) []byte {
+	return hashToken(token)
+}
+
+func IsAPIKey(token string) bool {
+	return strings.HasPrefix(token, APIKeyPrefix)
+}
+
+func (k *APIKey) Active(now time.Time) bool {
+	return k.RevokedAt == nil && (k.ExpiresAt == nil || now.Before(*k.ExpiresAt))
+}
+
+// Allows проверяет доступ к ресурсу: для чтения подходит и область записи
+func (k *APIKey) Allows(resource string, write bool) bool {
+	if slices.Contains(k.Scopes, resource+":write") {
+		return true
+	}
+	return !write && slices.Contains(k.Scopes, resource+":read")
+}
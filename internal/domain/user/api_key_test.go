@@ -0,0 +1,70 @@
+package user
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+func TestNewAPIKey(t *testing.T) {
+	k, token, err := NewAPIKey(uuid.New(), " erp ", []string{ScopeItemsWrite, ScopeHistoryRead, ScopeItemsWrite}, time.Hour)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !IsAPIKey(token) || k.Name != "erp" || !bytes.Equal(k.KeyHash, HashAPIKey(token)) {
+		t.Fatalf("unexpected key %+v (%s)", k, token)
+	}
+	if len(k.Prefix) != len(APIKeyPrefix)+6 || token[:len(k.Prefix)] != k.Prefix {
+		t.Fatalf("unexpected prefix %q", k.Prefix)
+	}
+	if len(k.Scopes) != 2 || k.Scopes[0] != ScopeHistoryRead {
+		t.Fatalf("expected sorted unique scopes, got %v", k.Scopes)
+	}
+	if k.ExpiresAt == nil || !k.Active(time.Now()) || k.Active(time.Now().Add(2*time.Hour)) {
+		t.Fatalf("unexpected expiry %v", k.ExpiresAt)
+	}
+
+	for _, tc := range []struct {
+		name   string
+		scopes []string
+		ttl    time.Duration
+	}{
+		{"", []string{ScopeItemsRead}, 0},
+		{"erp", nil, 0},
+		{"erp", []string{"users:write"}, 0},
+		{"erp", []string{ScopeItemsRead}, -time.Hour},
+	} {
+		if _, _, err := NewAPIKey(uuid.New(), tc.name, tc.scopes, tc.ttl); err == nil {
+			t.Fatalf("expected error for %+v", tc)
+		}
+	}
+}
+
+func TestAPIKey_Allows(t *testing.T) {
+	k := &APIKey{Scopes: []string{ScopeItemsWrite, ScopeWarehousesRead}}
+	cases := []struct {
+		resource string
+		write    bool
+		want     bool
+	}{
+		{"items", false, true},
+		{"items", true, true},
+		{"warehouses", false, true},
+		{"warehouses", true, false},
+		{"history", false, false},
+		{"users", false, false},
+	}
+	for _, tc := range cases {
+		if got := k.Allows(tc.resource, tc.write); got != tc.want {
+			t.Fatalf("Allows(%q, %v) = %v, want %v", tc.resource, tc.write, got, tc.want)
+		}
+	}
+
+	now := time.Now()
+	k.RevokedAt = &now
+	if k.Active(now.Add(-time.Minute)) {
+		t.Fatal("revoked key must be inactive")
+	}
+}
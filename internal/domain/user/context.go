@@ -0,0 +1,26 @@
+package user
+
+import "context"
+
+type writeMarkKey struct{}
+
+// WriteMark — позиция журнала мастера (LSN) после последней записи клиента. Метку хранит
+// сам клиент и предъявляет с каждым запросом, поэтому свои изменения он видит через любой
+// экземпляр сервиса.
+type WriteMark struct {
+	// Seen — метка из запроса; пустая — клиент недавно ничего не записывал
+	Seen string
+	// Wrote получает новую метку после записи в этом запросе
+	Wrote func(lsn string)
+}
+
+// WithWriteMark передаёт репозиториям метку записи клиента
+func WithWriteMark(ctx context.Context, mark *WriteMark) context.Context {
+	return context.WithValue(ctx, writeMarkKey{}, mark)
+}
+
+// WriteMarkFromContext — метка из WithWriteMark; nil — запрос без неё
+func WriteMarkFromContext(ctx context.Context) *WriteMark {
+	mark, _ := ctx.Value(writeMarkKey{}).(*WriteMark)
+	return mark
+}
@@ -0,0 +1,67 @@
+package user
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+
+	"warehousecontrol/internal/domain/errs"
+)
+
+var (
+	ErrInvitationNotFound = errs.New(errs.ErrNotFound, "invitation not found")
+	ErrInvitationUsed     = errs.New(errs.ErrConflict, "invitation already used")
+	ErrInvitationExpired  = errs.New(errs.ErrForbidden, "invitation expired")
+	// ErrAlreadyBootstrapped — первый администратор уже создан, токен установки больше не действует
+	ErrAlreadyBootstrapped = errs.New(errs.ErrConflict, "initial admin already exists")
+)
+
+// Invitation — одноразовое приглашение с фиксированной ролью. В базе хранится только хэш токена.
+type Invitation struct {
+	ID        uuid.UUID
+	Role      Role
+	TokenHash []byte
+	CreatedBy uuid.UUID
+	CreatedAt time.Time
+	ExpiresAt time.Time
+	UsedAt    *time.Time
+	UsedBy    *uuid.UUID
+}
+
+// NewInvitation создаёт приглашение и возвращает его токен — он показывается один раз
+func NewInvitation(role Role, createdBy uuid.UUID, ttl time.Duration) (*Invitation, string, error) {
+	if !role.Valid() {
+		return nil, "", errs.New(errs.ErrValidation, "invalid role type")
+	}
+	if ttl <= 0 {
+		return nil, "", errs.New(errs.ErrValidation, "invitation ttl must be > 0")
+	}
+	token, err := newSecretToken()
+	if err != nil {
+		return nil, "", err
+	}
+	now := time.Now()
+	return &Invitation{
+		ID:        uuid.New(),
+		Role:      role,
+		TokenHash: HashInvitationToken(token),
+		CreatedBy: createdBy,
+		CreatedAt: now,
+		ExpiresAt: now.Add(ttl),
+	}, token, nil
+}
+
+func HashInvitationToken(token string) []byte {
+	return hashToken(token)
+}
+
+// CheckUsable проверяет, что приглашение ещё не использовано и не истекло
+func (i *Invitation) CheckUsable(now time.Time) error {
+	if i.UsedAt != nil {
+		return ErrInvitationUsed
+	}
+	if !now.Before(i.ExpiresAt) {
+		return ErrInvitationExpired
+	}
+	return nil
+}
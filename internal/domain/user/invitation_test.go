@@ -0,0 +1,45 @@
+package user
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+func TestNewInvitation(t *testing.T) {
+	inv, token, err := NewInvitation(Manager, uuid.New(), time.Hour)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if token == "" || !bytes.Equal(inv.TokenHash, HashInvitationToken(token)) {
+		t.Fatalf("expected token hash to match token")
+	}
+	if inv.Role != Manager || inv.ExpiresAt.Sub(inv.CreatedAt) != time.Hour {
+		t.Fatalf("unexpected invitation: %+v", inv)
+	}
+
+	if _, _, err := NewInvitation(Role("root!"), uuid.New(), time.Hour); err == nil {
+		t.Fatal("expected error for invalid role")
+	}
+	if _, _, err := NewInvitation(Viewer, uuid.New(), 0); err == nil {
+		t.Fatal("expected error for zero ttl")
+	}
+}
+
+func TestInvitation_CheckUsable(t *testing.T) {
+	now := time.Now()
+	inv := &Invitation{ExpiresAt: now.Add(time.Minute)}
+	if err := inv.CheckUsable(now); err != nil {
+		t.Fatalf("expected usable invitation, got %v", err)
+	}
+	if err := inv.CheckUsable(now.Add(time.Minute)); !errors.Is(err, ErrInvitationExpired) {
+		t.Fatalf("expected expired, got %v", err)
+	}
+	inv.UsedAt = &now
+	if err := inv.CheckUsable(now); !errors.Is(err, ErrInvitationUsed) {
+		t.Fatalf("expected used, got %v", err)
+	}
+}
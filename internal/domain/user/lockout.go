@@ -0,0 +1,58 @@
+package user
+
+import (
+	"time"
+
+	"warehousecontrol/internal/domain/errs"
+)
+
+// ErrTooManyAttempts не уточняет, заблокирован логин или адрес, и не раскрывает, существует ли логин
+var ErrTooManyAttempts = errs.New(errs.ErrRateLimited, "too many failed login attempts, try again later")
+
+// Области счётчиков неудачных входов
+const (
+	ScopeLogin = "login"
+	ScopeIP    = "ip"
+)
+
+// LockoutPolicy — порог неудачных попыток за окно и длительность блокировки.
+// Нулевой MaxFailures отключает блокировку.
+type LockoutPolicy struct {
+	MaxFailures int
+	Window      time.Duration
+	Cooldown    time.Duration
+}
+
+// LoginFailures — счётчик неудачных входов по логину или IP. Логин учитывается,
+// даже если такого пользователя нет, чтобы блокировка не выдавала существование учётной записи.
+type LoginFailures struct {
+	Scope       string
+	Subject     string
+	Failures    int
+	WindowStart time.Time
+	LockedUntil *time.Time
+}
+
+func (f *LoginFailures) Locked(now time.Time) bool {
+	return f.LockedUntil != nil && now.Before(*f.LockedUntil)
+}
+
+// Fail учитывает неудачную попытку и возвращает true, если она привела к блокировке.
+// Счёт начинается заново, когда окно истекло или закончилась прошлая блокировка.
+func (f *LoginFailures) Fail(now time.Time, p LockoutPolicy) bool {
+	if f.Locked(now) {
+		return false
+	}
+	if f.LockedUntil != nil || f.Failures == 0 || now.Sub(f.WindowStart) >= p.Window {
+		f.Failures = 0
+		f.WindowStart = now
+		f.LockedUntil = nil
+	}
+	f.Failures++
+	if p.MaxFailures > 0 && f.Failures >= p.MaxFailures {
+		until := now.Add(p.Cooldown)
+		f.LockedUntil = &until
+		return true
+	}
+	return false
+}
@@ -0,0 +1,50 @@
+package user
+
+import (
+	"testing"
+	"time"
+)
+
+func TestLoginFailures_Fail(t *testing.T) {
+	p := LockoutPolicy{MaxFailures: 3, Window: time.Minute, Cooldown: time.Hour}
+	now := time.Now()
+	f := &LoginFailures{Scope: ScopeLogin, Subject: "bob"}
+
+	if f.Fail(now, p) || f.Fail(now.Add(time.Second), p) {
+		t.Fatal("expected no lock before threshold")
+	}
+	if !f.Fail(now.Add(2*time.Second), p) || !f.Locked(now.Add(3*time.Second)) {
+		t.Fatal("expected lock on third failure")
+	}
+	// во время блокировки попытки не продлевают её
+	until := *f.LockedUntil
+	if f.Fail(now.Add(time.Minute), p) || !f.LockedUntil.Equal(until) {
+		t.Fatal("expected lock to stay unchanged")
+	}
+
+	// после блокировки счёт начинается заново
+	after := until.Add(time.Second)
+	if f.Locked(after) || f.Fail(after, p) || f.Failures != 1 {
+		t.Fatalf("expected counter reset after cooldown, got %+v", f)
+	}
+}
+
+func TestLoginFailures_WindowExpires(t *testing.T) {
+	p := LockoutPolicy{MaxFailures: 2, Window: time.Minute, Cooldown: time.Hour}
+	now := time.Now()
+	f := &LoginFailures{}
+
+	f.Fail(now, p)
+	if f.Fail(now.Add(2*time.Minute), p) || f.Failures != 1 {
+		t.Fatalf("expected window to restart, got %+v", f)
+	}
+}
+
+func TestLoginFailures_Disabled(t *testing.T) {
+	f := &LoginFailures{}
+	for i := 0; i < 100; i++ {
+		if f.Fail(time.Now(), LockoutPolicy{Window: time.Minute}) {
+			t.Fatal("expected zero threshold to never lock")
+		}
+	}
+}
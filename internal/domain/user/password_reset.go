@@ -0,0 +1,50 @@
+package user
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+
+	"warehousecontrol/internal/domain/errs"
+)
+
+var (
+	// ErrResetTokenInvalid не уточняет, токен неизвестен, истёк или уже использован
+	ErrResetTokenInvalid = errs.New(errs.ErrUnauthorized, "invalid or expired password reset token")
+	ErrWrongPassword     = errs.New(errs.ErrForbidden, "current password is incorrect")
+	ErrSamePassword      = errs.New(errs.ErrValidation, "new password must differ from the current one")
+)
+
+// PasswordResetToken — одноразовый токен сброса пароля, который администратор
+// выпускает для пользователя. В базе хранится только хэш токена.
+type PasswordResetToken struct {
+	ID        uuid.UUID
+	UserID    uuid.UUID
+	TokenHash []byte
+	CreatedAt time.Time
+	ExpiresAt time.Time
+	UsedAt    *time.Time
+}
+
+// NewPasswordResetToken создаёт токен сброса и возвращает его значение — оно показывается один раз
+func NewPasswordResetToken(userID uuid.UUID, ttl time.Duration) (*PasswordResetToken, string, error) {
+	if ttl <= 0 {
+		return nil, "", errs.New(errs.ErrValidation, "password reset ttl must be > 0")
+	}
+	token, err := newSecretToken()
+	if err != nil {
+		return nil, "", err
+	}
+	now := time.Now()
+	return &PasswordResetToken{
+		ID:        uuid.New(),
+		UserID:    userID,
+		TokenHash: HashPasswordResetToken(token),
+		CreatedAt: now,
+		ExpiresAt: now.Add(ttl),
+	}, token, nil
+}
+
+func HashPasswordResetToken(token string) []byte {
+	return hashToken(token)
+}
@@ -0,0 +1,30 @@
+package user
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+
+	"warehousecontrol/internal/domain/errs"
+)
+
+func TestNewPasswordResetToken(t *testing.T) {
+	id := uuid.New()
+	rt, token, err := NewPasswordResetToken(id, time.Hour)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if token == "" || rt.UserID != id || !bytes.Equal(rt.TokenHash, HashPasswordResetToken(token)) {
+		t.Fatalf("unexpected token: %+v", rt)
+	}
+	if !rt.ExpiresAt.After(rt.CreatedAt) {
+		t.Fatal("expected expiry after creation")
+	}
+
+	if _, _, err := NewPasswordResetToken(id, 0); !errors.Is(err, errs.ErrValidation) {
+		t.Fatalf("expected validation error for zero ttl, got %v", err)
+	}
+}
@@ -0,0 +1,27 @@
+package user
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+
+	"warehousecontrol/internal/domain/errs"
+)
+
+var (
+	ErrRefreshTokenInvalid = errs.New(errs.ErrUnauthorized, "invalid refresh token")
+	// ErrRefreshTokenReused — уже использованный refresh токен предъявлен повторно;
+	// сессия отозвана, нужен повторный вход
+	ErrRefreshTokenReused = errs.New(errs.ErrUnauthorized, "refresh token reuse detected, please log in again")
+)
+
+// RefreshToken — выданный refresh токен. Каждое обновление помечает токен использованным
+// и выдаёт следующий в той же сессии; цепочка токенов начинается при входе.
+type RefreshToken struct {
+	ID        uuid.UUID
+	SessionID uuid.UUID
+	UserID    uuid.UUID
+	IssuedAt  time.Time
+	ExpiresAt time.Time
+	UsedAt    *time.Time
+}
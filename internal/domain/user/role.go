@@ -0,0 +1,126 @@
+package user
+
+import (
+	"regexp"
+	"slices"
+	"strings"
+	"time"
+
+	"warehousecontrol/internal/domain/errs"
+)
+
+var (
+	ErrRoleNotFound = errs.New(errs.ErrNotFound, "role not found")
+	ErrRoleExists   = errs.New(errs.ErrConflict, "role with this name already exists")
+	// ErrBuiltInRole — встроенные роли admin, manager и viewer не меняются и не удаляются
+	ErrBuiltInRole = errs.New(errs.ErrConflict, "built-in roles cannot be changed or deleted")
+	ErrRoleInUse   = errs.New(errs.ErrConflict, "role is assigned to users or invitations")
+)
+
+// Permission — право на операцию. Маршруты требуют права, а роль — именованный набор прав.
+type Permission string
+
+const (
+	PermItemsRead   Permission = "items.read"
+	PermItemsCreate Permission = "items.create"
+	PermItemsUpdate Permission = "items.update"
+	PermItemsDelete Permission = "items.delete"
+	// PermItemsAdjust — изменение остатков: поступления, отгрузки, перемещения, корректировки
+	PermItemsAdjust Permission = "items.adjust"
+	// PermItemsAdjustForce — корректировка с уходом остатка в минус
+	PermItemsAdjustForce Permission = "items.adjust_force"
+	PermItemsRevert      Permission = "items.revert"
+	PermWarehousesRead   Permission = "warehouses.read"
+	PermWarehousesManage Permission = "warehouses.manage"
+	// PermHistoryRead — история изменений, её выгрузка и сравнение версий товара
+	PermHistoryRead Permission = "history.read"
+	// PermUsersManage — пользователи, приглашения, сервисные учётные записи и их ключи
+	PermUsersManage Permission = "users.manage"
+	PermRolesManage Permission = "roles.manage"
+)
+
+var AllPermissions = []Permission{
+	PermItemsRead, PermItemsCreate, PermItemsUpdate, PermItemsDelete, PermItemsAdjust, PermItemsAdjustForce,
+	PermItemsRevert, PermWarehousesRead, PermWarehousesManage, PermHistoryRead, PermUsersManage, PermRolesManage,
+}
+
+func (p Permission) Valid() bool {
+	return slices.Contains(AllPermissions, p)
+}
+
+type Permissions []Permission
+
+func (ps Permissions) Has(p Permission) bool {
+	return slices.Contains(ps, p)
+}
+
+// RoleDefinition — роль как набор прав. Version растёт при каждом изменении прав:
+// по ней токены, выпущенные до изменения, получают актуальный набор.
+type RoleDefinition struct {
+	Name        Role
+	Description string
+	Permissions Permissions
+	BuiltIn     bool
+	Version     int
+	CreatedAt   time.Time
+	UpdatedAt   time.Time
+}
+
+// BuiltInRoles — встроенные роли; миграция 000023 создаёт их с теми же правами
+func BuiltInRoles() []*RoleDefinition {
+	now := time.Now()
+	return []*RoleDefinition{
+		{Name: Admin, Description: "Full access", Permissions: slices.Clone(AllPermissions), BuiltIn: true, Version: 1, CreatedAt: now, UpdatedAt: now},
+		{
+			Name:        Manager,
+			Description: "Item editing and stock operations",
+			Permissions: Permissions{PermItemsRead, PermItemsUpdate, PermItemsAdjust, PermWarehousesRead},
+			BuiltIn:     true, Version: 1, CreatedAt: now, UpdatedAt: now,
+		},
+		{
+			Name:        Viewer,
+			Description: "Read-only access to items and warehouses",
+			Permissions: Permissions{PermItemsRead, PermWarehousesRead},
+			BuiltIn:     true, Version: 1, CreatedAt: now, UpdatedAt: now,
+		},
+	}
+}
+
+var roleNameRegexp = regexp.MustCompile(`^[a-z][a-z0-9_-]{1,31}$`)
+
+// NewRole создаёт пользовательскую роль с указанными правами
+func NewRole(name, description string, permissions []string) (*RoleDefinition, error) {
+	r := Role(name)
+	if !r.Valid() {
+		return nil, errs.New(errs.ErrValidation, "role name must be 2-32 lowercase latin letters, digits, '_' or '-' and start with a letter")
+	}
+	perms, err := ParsePermissions(permissions)
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	return &RoleDefinition{
+		Name:        r,
+		Description: strings.TrimSpace(description),
+		Permissions: perms,
+		Version:     1,
+		CreatedAt:   now,
+		UpdatedAt:   now,
+	}, nil
+}
+
+// ParsePermissions проверяет права и возвращает их отсортированными без повторов
+func ParsePermissions(permissions []string) (Permissions, error) {
+	if len(permissions) == 0 {
+		return nil, errs.New(errs.ErrValidation, "at least one permission required")
+	}
+	perms := make(Permissions, 0, len(permissions))
+	for _, p := range permissions {
+		if !Permission(p).Valid() {
+			return nil, errs.Errorf(errs.ErrValidation, "unknown permission: %s", p)
+		}
+		perms = append(perms, Permission(p))
+	}
+	slices.Sort(perms)
+	return slices.Compact(perms), nil
+}
@@ -0,0 +1,48 @@
+package user
+
+import (
+	"testing"
+)
+
+func TestNewRole(t *testing.T) {
+	r, err := NewRole("auditor", " History only ", []string{"history.read", "items.read", "history.read"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if r.Name != "auditor" || r.Description != "History only" || r.Version != 1 || r.BuiltIn {
+		t.Fatalf("unexpected role: %+v", r)
+	}
+	if len(r.Permissions) != 2 || r.Permissions[0] != PermHistoryRead || !r.Permissions.Has(PermItemsRead) || r.Permissions.Has(PermItemsUpdate) {
+		t.Fatalf("expected sorted unique permissions, got %v", r.Permissions)
+	}
+
+	for _, tc := range []struct {
+		name  string
+		perms []string
+	}{
+		{"Auditor", []string{"history.read"}},
+		{"a", []string{"history.read"}},
+		{"9lives", []string{"history.read"}},
+		{"auditor", nil},
+		{"auditor", []string{"history.write"}},
+	} {
+		if _, err := NewRole(tc.name, "", tc.perms); err == nil {
+			t.Fatalf("expected error for %+v", tc)
+		}
+	}
+}
+
+func TestBuiltInRoles(t *testing.T) {
+	roles := BuiltInRoles()
+	if len(roles) != 3 || roles[0].Name != Admin || len(roles[0].Permissions) != len(AllPermissions) {
+		t.Fatalf("unexpected built-in roles: %+v", roles)
+	}
+	for _, r := range roles {
+		if !r.BuiltIn || !r.Name.Valid() || !r.Permissions.Has(PermItemsRead) {
+			t.Fatalf("unexpected built-in role: %+v", r)
+		}
+		if r.Name != Admin && (r.Permissions.Has(PermUsersManage) || r.Permissions.Has(PermItemsDelete)) {
+			t.Fatalf("role %s must not manage users or delete items", r.Name)
+		}
+	}
+}
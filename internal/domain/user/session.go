@@ -0,0 +1,37 @@
+package user
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+
+	"warehousecontrol/internal/domain/errs"
+)
+
+var (
+	ErrSessionNotFound = errs.New(errs.ErrNotFound, "session not found")
+	ErrSessionRevoked  = errs.New(errs.ErrUnauthorized, "session revoked or expired")
+)
+
+// Client — откуда пришёл запрос на вход
+type Client struct {
+	UserAgent string
+	IP        string
+}
+
+// Session — вход пользователя на устройстве. Живёт, пока обновляются её refresh токены;
+// отзыв сессии отклоняет и refresh, и access токены этой сессии.
+type Session struct {
+	ID         uuid.UUID
+	UserID     uuid.UUID
+	UserAgent  string
+	IP         string
+	CreatedAt  time.Time
+	LastUsedAt time.Time
+	ExpiresAt  time.Time
+	RevokedAt  *time.Time
+}
+
+func (s *Session) Active(now time.Time) bool {
+	return s.RevokedAt == nil && now.Before(s.ExpiresAt)
+}
@@ -0,0 +1,90 @@
+package user
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+
+	"warehousecontrol/internal/domain/errs"
+)
+
+var (
+	ErrSSODisabled = errs.New(errs.ErrNotFound, "sso login is not configured")
+	// ErrSSOStateInvalid не уточняет, state неизвестен, истёк или уже использован
+	ErrSSOStateInvalid = errs.New(errs.ErrUnauthorized, "sso login expired or already completed, start again")
+	// ErrSSOFailed — провайдер не подтвердил пользователя; подробности только в логе
+	ErrSSOFailed = errs.New(errs.ErrUnauthorized, "sso login failed")
+	ErrNoSSORole = errs.New(errs.ErrForbidden, "no role is mapped to your identity provider groups")
+	// ErrSSOLoginTaken — логин от провайдера занят учётной записью, не связанной с ним;
+	// автоматически такие записи не связываются, иначе провайдер мог бы войти за любого
+	ErrSSOLoginTaken = errs.New(errs.ErrConflict, "login is already used by another account")
+	// ErrDirectoryUnavailable — внешний каталог учётных записей (LDAP) не ответил или отказал
+	ErrDirectoryUnavailable = errs.New(errs.ErrUnavailable, "authentication directory is unavailable, try again later")
+)
+
+// SSOState — незавершённый вход через провайдера. В базе хранится только хэш state;
+// nonce и code_verifier нужны, чтобы обменять код на ID токен и проверить его.
+type SSOState struct {
+	StateHash    []byte
+	Nonce        string
+	CodeVerifier string
+	CreatedAt    time.Time
+	ExpiresAt    time.Time
+}
+
+// NewSSOState создаёт состояние входа и возвращает значение state для провайдера
+func NewSSOState(ttl time.Duration) (*SSOState, string, error) {
+	if ttl <= 0 {
+		return nil, "", errs.New(errs.ErrValidation, "sso state ttl must be > 0")
+	}
+	state, err := newSecretToken()
+	if err != nil {
+		return nil, "", err
+	}
+	nonce, err := newSecretToken()
+	if err != nil {
+		return nil, "", err
+	}
+	// 32 случайных байта в base64url — 43 символа, минимальная длина code_verifier по RFC 7636
+	verifier, err := newSecretToken()
+	if err != nil {
+		return nil, "", err
+	}
+	now := time.Now()
+	return &SSOState{
+		StateHash:    HashSSOState(state),
+		Nonce:        nonce,
+		CodeVerifier: verifier,
+		CreatedAt:    now,
+		ExpiresAt:    now.Add(ttl),
+	}, state, nil
+}
+
+func HashSSOState(state string) []byte {
+	return hashToken(state)
+}
+
+// Identity — связь учётной записи с пользователем провайдера: пара issuer и sub
+// однозначно определяет его, даже если логин у провайдера поменяется
+type Identity struct {
+	Issuer      string
+	Subject     string
+	UserID      uuid.UUID
+	CreatedAt   time.Time
+	LastLoginAt time.Time
+}
+
+// NewSSOUser создаёт учётную запись пользователя провайдера. Пароля у неё нет: пустой
+// хэш не совпадает ни с одним паролем, вход только через провайдера.
+func NewSSOUser(login string, role Role) (*User, error) {
+	if !role.Valid() {
+		return nil, errs.New(errs.ErrValidation, "invalid role type")
+	}
+	return &User{
+		Id:        uuid.New(),
+		Login:     login,
+		Password:  []byte{},
+		CreatedAt: time.Now(),
+		Role:      role,
+	}, nil
+}
@@ -0,0 +1,44 @@
+package user
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+	"time"
+
+	"warehousecontrol/internal/domain/errs"
+)
+
+func TestNewSSOState(t *testing.T) {
+	st, state, err := NewSSOState(time.Minute)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if state == "" || !bytes.Equal(st.StateHash, HashSSOState(state)) || st.Nonce == state || st.CodeVerifier == state {
+		t.Fatalf("unexpected state: %+v", st)
+	}
+	// RFC 7636: code_verifier — от 43 до 128 символов
+	if l := len(st.CodeVerifier); l < 43 || l > 128 {
+		t.Fatalf("invalid code_verifier length %d", l)
+	}
+	if !st.ExpiresAt.After(st.CreatedAt) {
+		t.Fatal("expected expiry after creation")
+	}
+
+	if _, _, err := NewSSOState(0); !errors.Is(err, errs.ErrValidation) {
+		t.Fatalf("expected validation error for zero ttl, got %v", err)
+	}
+}
+
+func TestNewSSOUser(t *testing.T) {
+	u, err := NewSSOUser("jdoe", Manager)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(u.Password) != 0 || u.ServiceAccount || u.Role != Manager {
+		t.Fatalf("unexpected user: %+v", u)
+	}
+	if _, err := NewSSOUser("jdoe", "Bad Role"); !errors.Is(err, errs.ErrValidation) {
+		t.Fatalf("expected validation error, got %v", err)
+	}
+}
@@ -0,0 +1,85 @@
+package user
+
+import (
+	"crypto/rand"
+	"encoding/base32"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+
+	"warehousecontrol/internal/domain/errs"
+)
+
+var (
+	ErrTOTPNotEnabled     = errs.New(errs.ErrNotFound, "two-factor authentication is not enabled")
+	ErrTOTPAlreadyEnabled = errs.New(errs.ErrConflict, "two-factor authentication is already enabled")
+	ErrTOTPNotSetUp       = errs.New(errs.ErrConflict, "two-factor authentication setup has not been started")
+	// ErrTOTPInvalidCode не уточняет, код неверный, уже использованный или это неизвестный код восстановления
+	ErrTOTPInvalidCode = errs.New(errs.ErrUnauthorized, "invalid two-factor code")
+	ErrTOTPRequired    = errs.New(errs.ErrForbidden, "two-factor authentication is mandatory for this account")
+	ErrMFATokenInvalid = errs.New(errs.ErrUnauthorized, "invalid or expired two-factor login token")
+)
+
+// RecoveryCodeCount — сколько кодов восстановления выдаётся за раз
+const RecoveryCodeCount = 10
+
+// TOTP — второй фактор пользователя. Секрет сохраняется при настройке и начинает
+// действовать после подтверждения первым кодом. LastStep — последний принятый шаг:
+// код того же или более раннего шага повторно не принимается.
+type TOTP struct {
+	UserID      uuid.UUID
+	Secret      string
+	CreatedAt   time.Time
+	ConfirmedAt *time.Time
+	LastStep    int64
+	// RecoveryCodesLeft — число неиспользованных кодов восстановления
+	RecoveryCodesLeft int
+}
+
+func (t *TOTP) Enabled() bool {
+	return t.ConfirmedAt != nil
+}
+
+// TOTPStatus — состояние второго фактора для самого пользователя
+type TOTPStatus struct {
+	Enabled           bool
+	Required          bool
+	RecoveryCodesLeft int
+}
+
+// TOTPSetup — данные для подключения приложения-аутентификатора
+type TOTPSetup struct {
+	Secret string
+	URI    string
+}
+
+// NewRecoveryCodes создаёт одноразовые коды восстановления вида xxxxx-xxxxx и их хэши.
+// Коды показываются пользователю один раз, в базе хранятся только хэши.
+func NewRecoveryCodes() ([]string, [][]byte, error) {
+	codes := make([]string, 0, RecoveryCodeCount)
+	hashes := make([][]byte, 0, RecoveryCodeCount)
+	enc := base32.StdEncoding.WithPadding(base32.NoPadding)
+	for i := 0; i < RecoveryCodeCount; i++ {
+		raw := make([]byte, 7)
+		if _, err := rand.Read(raw); err != nil {
+			return nil, nil, err
+		}
+		s := strings.ToLower(enc.EncodeToString(raw))[:10]
+		code := s[:5] + "-" + s[5:]
+		codes = append(codes, code)
+		hashes = append(hashes, HashRecoveryCode(code))
+	}
+	return codes, hashes, nil
+}
+
+// HashRecoveryCode не учитывает регистр, дефисы и пробелы во введённом коде
+func HashRecoveryCode(code string) []byte {
+	normalized := strings.Map(func(r rune) rune {
+		if r == '-' || r == ' ' {
+			return -1
+		}
+		return r
+	}, strings.ToLower(code))
+	return hashToken(normalized)
+}
@@ -0,0 +1,39 @@
+package user
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestNewRecoveryCodes(t *testing.T) {
+	codes, hashes, err := NewRecoveryCodes()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(codes) != RecoveryCodeCount || len(hashes) != RecoveryCodeCount {
+		t.Fatalf("expected %d codes, got %d", RecoveryCodeCount, len(codes))
+	}
+	seen := map[string]bool{}
+	for i, code := range codes {
+		if len(code) != 11 || code[5] != '-' || seen[code] {
+			t.Fatalf("unexpected code %q", code)
+		}
+		seen[code] = true
+		if !bytes.Equal(hashes[i], HashRecoveryCode(code)) {
+			t.Fatalf("hash mismatch for %q", code)
+		}
+	}
+}
+
+func TestHashRecoveryCode_Normalizes(t *testing.T) {
+	want := HashRecoveryCode("abcde-fghij")
+	for _, input := range []string{"ABCDE-FGHIJ", "abcdefghij", " abcde fghij "} {
+		if !bytes.Equal(HashRecoveryCode(input), want) {
+			t.Fatalf("expected %q to match", input)
+		}
+	}
+	if bytes.Equal(HashRecoveryCode(strings.Repeat("a", 10)), want) {
+		t.Fatal("expected different codes to differ")
+	}
+}
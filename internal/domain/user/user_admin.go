@@ -0,0 +1,77 @@
+package user
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+
+	"warehousecontrol/internal/domain/errs"
+)
+
+var (
+	ErrDisabled = errs.New(errs.ErrForbidden, "account disabled")
+	// ErrLastAdmin — нельзя разжаловать, отключить или удалить последнего активного администратора
+	ErrLastAdmin  = errs.New(errs.ErrConflict, "cannot remove the last active admin")
+	ErrSelfChange = errs.New(errs.ErrConflict, "admins cannot change their own role, status or account")
+	// ErrReferenced — на пользователя ссылаются другие записи (например, выданные им приглашения)
+	ErrReferenced = errs.New(errs.ErrConflict, "user is referenced by other records, disable the account instead")
+)
+
+// Действия в журнале аудита пользователей
+const (
+	AuditRoleChanged   = "role_changed"
+	AuditDisabled      = "disabled"
+	AuditEnabled       = "enabled"
+	AuditPasswordReset = "password_reset"
+	AuditDeleted       = "deleted"
+	AuditLocked        = "locked"
+	AuditUnlocked      = "unlocked"
+	// AuditPasswordChanged — пользователь сам сменил пароль, в том числе по токену сброса
+	AuditPasswordChanged = "password_changed"
+	AuditResetIssued     = "reset_token_issued"
+	AuditTOTPEnabled     = "totp_enabled"
+	// AuditTOTPDisabled — второй фактор отключён самим пользователем или сброшен администратором
+	AuditTOTPDisabled    = "totp_disabled"
+	AuditRecoveryRenewed = "recovery_codes_renewed"
+	AuditAPIKeyCreated   = "api_key_created"
+	AuditAPIKeyRotated   = "api_key_rotated"
+	AuditAPIKeyRevoked   = "api_key_revoked"
+	// AuditSSOProvisioned — учётная запись заведена при первом входе через провайдера
+	AuditSSOProvisioned = "sso_provisioned"
+
+	// SystemActor — автор записей аудита, сделанных самой системой
+	SystemActor = "system"
+)
+
+// AuditEntry — запись журнала действий над учётной записью. ActorID пуст у действий
+// самой системы, например блокировки после неудачных входов.
+type AuditEntry struct {
+	ID         uuid.UUID
+	UserID     uuid.UUID
+	UserLogin  string
+	Action     string
+	OldValue   *string
+	NewValue   *string
+	ActorID    *uuid.UUID
+	ActorLogin string
+	ChangedAt  time.Time
+}
+
+// ListOptions — параметры выборки пользователей
+type ListOptions struct {
+	Limit  int
+	Offset int
+
+	// Login — поиск по подстроке без учёта регистра
+	Login    string
+	Role     Role
+	Disabled *bool
+	// ServiceAccount отбирает только сервисные (true) или только обычные (false) учётные записи
+	ServiceAccount *bool
+}
+
+// Page — страница списка пользователей; Total считается по фильтрам без учёта пагинации
+type Page struct {
+	Users []*User
+	Total int
+}
@@ -4,30 +4,52 @@ import (
 	"github.com/google/uuid"
 	"golang.org/x/crypto/bcrypt"
 
-	"errors"
 	"time"
+
+	"warehousecontrol/internal/domain/errs"
+)
+
+var (
+	ErrNotFound      = errs.New(errs.ErrNotFound, "user not found")
+	ErrAlreadyExists = errs.New(errs.ErrConflict, "user with this login already exists")
+	// ErrInvalidCredentials не уточняет, что именно неверно: логин или пароль
+	ErrInvalidCredentials = errs.New(errs.ErrUnauthorized, "invalid login or password")
 )
 
 type Role string
 
+// Встроенные роли; кроме них администратор может завести свои (RoleDefinition)
 const (
 	Admin   Role = "admin"
 	Manager Role = "manager"
 	Viewer  Role = "viewer"
 )
 
+// Valid проверяет только формат имени роли; существует ли роль, проверяет сервис
+func (r Role) Valid() bool {
+	return roleNameRegexp.MatchString(string(r))
+}
+
 type User struct {
 	Id        uuid.UUID
 	Login     string
 	Password  []byte
 	Role      Role
 	CreatedAt time.Time
+	// DisabledAt — момент отключения учётной записи; nil, если она активна
+	DisabledAt *time.Time
+	// ServiceAccount — учётная запись интеграции: входит только по API ключам, без пароля
+	ServiceAccount bool
+}
+
+func (u *User) Disabled() bool {
+	return u.DisabledAt != nil
 }
 
 func NewUser(login, password string, role Role) (*User, error) {
 	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
-	if role != Admin && role != Manager && role != Viewer {
-		return nil, errors.New("invalid role type")
+	if !role.Valid() {
+		return nil, errs.New(errs.ErrValidation, "invalid role type")
 	}
 	if err != nil {
 		return nil, err
@@ -40,3 +62,19 @@ func NewUser(login, password string, role Role) (*User, error) {
 		Role:      role,
 	}, nil
 }
+
+// NewServiceAccount создаёт сервисную учётную запись. Пароля у неё нет: пустой хэш
+// не совпадает ни с одним паролем.
+func NewServiceAccount(login string, role Role) (*User, error) {
+	if !role.Valid() {
+		return nil, errs.New(errs.ErrValidation, "invalid role type")
+	}
+	return &User{
+		Id:             uuid.New(),
+		Login:          login,
+		Password:       []byte{},
+		CreatedAt:      time.Now(),
+		Role:           role,
+		ServiceAccount: true,
+	}, nil
+}
@@ -20,7 +20,7 @@ func TestNewUser_ValidRoles(t *testing.T) {
 }
 
 func TestNewUser_InvalidRole(t *testing.T) {
-	_, err := NewUser("john", "secret123", Role("bad"))
+	_, err := NewUser("john", "secret123", Role("Bad Role"))
 	if err == nil {
 		t.Fatalf("expected error for invalid role")
 	}
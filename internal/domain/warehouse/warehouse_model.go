@@ -0,0 +1,105 @@
+package warehouse
+
+import (
+	"warehousecontrol/internal/domain/errs"
+
+	"github.com/google/uuid"
+)
+
+var (
+	ErrWarehouseNotFound = errs.New(errs.ErrNotFound, "warehouse not found")
+	ErrZoneNotFound      = errs.New(errs.ErrNotFound, "zone not found")
+	ErrLocationNotFound  = errs.New(errs.ErrNotFound, "location not found")
+	ErrWarehouseExists   = errs.New(errs.ErrConflict, "warehouse with this name already exists")
+	ErrZoneExists        = errs.New(errs.ErrConflict, "zone with this name already exists in warehouse")
+	ErrLocationExists    = errs.New(errs.ErrConflict, "location with this code already exists in zone")
+	// ErrLocationNotEmpty — в ячейке ещё лежит товар, удалить её нельзя
+	ErrLocationNotEmpty = errs.New(errs.ErrConflict, "location still holds stock")
+	// ErrWarehouseNotEmpty — в ячейках склада ещё лежит товар, удалить его нельзя
+	ErrWarehouseNotEmpty = errs.New(errs.ErrConflict, "warehouse still holds stock")
+)
+
+type Warehouse struct {
+	ID      uuid.UUID `json:"id"`
+	Name    string    `json:"name"`
+	Address string    `json:"address"`
+	Zones   []*Zone   `json:"zones,omitempty"`
+}
+
+type Zone struct {
+	ID          uuid.UUID   `json:"id"`
+	WarehouseID uuid.UUID   `json:"warehouse_id"`
+	Name        string      `json:"name"`
+	Locations   []*Location `json:"locations,omitempty"`
+}
+
+// Location — конкретная ячейка хранения (bin) внутри зоны склада
+type Location struct {
+	ID     uuid.UUID `json:"id"`
+	ZoneID uuid.UUID `json:"zone_id"`
+	Code   string    `json:"code"`
+}
+
+func NewWarehouse(name, address string) (*Warehouse, error) {
+	if name == "" {
+		return nil, errs.New(errs.ErrValidation, "warehouse name required")
+	}
+	return &Warehouse{
+		ID:      uuid.New(),
+		Name:    name,
+		Address: address,
+	}, nil
+}
+
+func (w *Warehouse) ChangeWarehouse(name, address string) error {
+	if name == "" {
+		return errs.New(errs.ErrValidation, "warehouse name cant be empty")
+	}
+	w.Name = name
+	w.Address = address
+	return nil
+}
+
+func NewZone(warehouseID uuid.UUID, name string) (*Zone, error) {
+	if warehouseID == uuid.Nil {
+		return nil, errs.New(errs.ErrValidation, "warehouse id required")
+	}
+	if name == "" {
+		return nil, errs.New(errs.ErrValidation, "zone name required")
+	}
+	return &Zone{
+		ID:          uuid.New(),
+		WarehouseID: warehouseID,
+		Name:        name,
+	}, nil
+}
+
+func (z *Zone) ChangeZone(name string) error {
+	if name == "" {
+		return errs.New(errs.ErrValidation, "zone name cant be empty")
+	}
+	z.Name = name
+	return nil
+}
+
+func NewLocation(zoneID uuid.UUID, code string) (*Location, error) {
+	if zoneID == uuid.Nil {
+		return nil, errs.New(errs.ErrValidation, "zone id required")
+	}
+	if code == "" {
+		return nil, errs.New(errs.ErrValidation, "location code required")
+	}
+	return &Location{
+		ID:     uuid.New(),
+		ZoneID: zoneID,
+		Code:   code,
+	}, nil
+}
+
+func (l *Location) ChangeLocation(code string) error {
+	if code == "" {
+		return errs.New(errs.ErrValidation, "location code cant be empty")
+	}
+	l.Code = code
+	return nil
+}
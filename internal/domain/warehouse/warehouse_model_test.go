@@ -0,0 +1,68 @@
+package warehouse
+
+import (
+	"testing"
+
+	"github.com/google/uuid"
+)
+
+func TestNewWarehouse_Valid(t *testing.T) {
+	w, err := NewWarehouse("Main", "Moscow")
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if w.Name != "Main" || w.Address != "Moscow" || w.ID == uuid.Nil {
+		t.Fatalf("unexpected warehouse fields: %+v", w)
+	}
+}
+
+func TestNewWarehouse_InvalidName(t *testing.T) {
+	if _, err := NewWarehouse("", "Moscow"); err == nil {
+		t.Fatalf("expected error for empty name")
+	}
+}
+
+func TestChangeWarehouse(t *testing.T) {
+	w, _ := NewWarehouse("Main", "")
+	if err := w.ChangeWarehouse("", "x"); err == nil {
+		t.Fatalf("expected error for empty name")
+	}
+	if err := w.ChangeWarehouse("Second", "Kazan"); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if w.Name != "Second" || w.Address != "Kazan" {
+		t.Fatalf("unexpected warehouse after change: %+v", w)
+	}
+}
+
+func TestNewZone(t *testing.T) {
+	if _, err := NewZone(uuid.Nil, "A"); err == nil {
+		t.Fatalf("expected error for nil warehouse id")
+	}
+	if _, err := NewZone(uuid.New(), ""); err == nil {
+		t.Fatalf("expected error for empty name")
+	}
+	z, err := NewZone(uuid.New(), "A")
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if err := z.ChangeZone(""); err == nil {
+		t.Fatalf("expected error for empty name")
+	}
+}
+
+func TestNewLocation(t *testing.T) {
+	if _, err := NewLocation(uuid.Nil, "A-01"); err == nil {
+		t.Fatalf("expected error for nil zone id")
+	}
+	if _, err := NewLocation(uuid.New(), ""); err == nil {
+		t.Fatalf("expected error for empty code")
+	}
+	l, err := NewLocation(uuid.New(), "A-01")
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if err := l.ChangeLocation("A-02"); err != nil || l.Code != "A-02" {
+		t.Fatalf("unexpected location after change: %+v, %v", l, err)
+	}
+}
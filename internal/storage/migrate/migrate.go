@@ -0,0 +1,287 @@
+// Package migrate применяет и откатывает SQL-миграции схемы. Номер версии хранится в таблице
+// schema_migrations в том же виде, что у утилиты golang-migrate, поэтому базы, размеченные ею,
+// продолжают мигрировать с того же места.
+package migrate
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"io/fs"
+	"regexp"
+	"sort"
+	"strconv"
+
+	wbzlog "github.com/wb-go/wbf/zlog"
+)
+
+// lockID — ключ advisory lock, под которым миграции выполняет только один процесс
+const lockID int64 = 7_301_945_626_281_117
+
+var fileName = regexp.MustCompile(`^(\d+)_(\w+)\.(up|down)\.sql$`)
+
+// ErrDirty — миграция, выполненная golang-migrate, прервалась на середине (свои миграции
+// выполняются в транзакции и пометку dirty не оставляют); после ручной проверки схемы нужен force
+var ErrDirty = errors.New("database is dirty, fix the schema and run migrate force")
+
+type Migration struct {
+	Version uint64
+	Name    string
+	Up      string
+	Down    string
+}
+
+func (m Migration) String() string {
+	return fmt.Sprintf("%06d_%s", m.Version, m.Name)
+}
+
+// Status — состояние схемы: текущая версия (0 — миграций не было) и ещё не применённые миграции
+type Status struct {
+	Version uint64
+	Dirty   bool
+	Pending []Migration
+}
+
+type Migrator struct {
+	db         *sql.DB
+	migrations []Migration
+}
+
+func New(db *sql.DB, fsys fs.FS) (*Migrator, error) {
+	migrations, err := Load(fsys)
+	if err != nil {
+		return nil, err
+	}
+	return &Migrator{db: db, migrations: migrations}, nil
+}
+
+// Load читает пары up/down из корня fsys и сортирует их по версии
+func Load(fsys fs.FS) ([]Migration, error) {
+	entries, err := fs.ReadDir(fsys, ".")
+	if err != nil {
+		return nil, err
+	}
+	byVersion := map[uint64]*Migration{}
+	for _, e := range entries {
+		if e.IsDir() {
+			continue
+		}
+		parts := fileName.FindStringSubmatch(e.Name())
+		if parts == nil {
+			continue
+		}
+		version, err := strconv.ParseUint(parts[1], 10, 64)
+		if err != nil || version == 0 {
+			return nil, fmt.Errorf("migration %s: invalid version", e.Name())
+		}
+		body, err := fs.ReadFile(fsys, e.Name())
+		if err != nil {
+			return nil, err
+		}
+		m := byVersion[version]
+		if m == nil {
+			m = &Migration{Version: version, Name: parts[2]}
+			byVersion[version] = m
+		}
+		if m.Name != parts[2] {
+			return nil, fmt.Errorf("migration %d has two names: %s and %s", version, m.Name, parts[2])
+		}
+		if parts[3] == "up" {
+			m.Up = string(body)
+		} else {
+			m.Down = string(body)
+		}
+	}
+
+	migrations := make([]Migration, 0, len(byVersion))
+	for _, m := range byVersion {
+		if m.Up == "" || m.Down == "" {
+			return nil, fmt.Errorf("migration %s needs non-empty up and down files", m)
+		}
+		migrations = append(migrations, *m)
+	}
+	sort.Slice(migrations, func(i, j int) bool { return migrations[i].Version < migrations[j].Version })
+	return migrations, nil
+}
+
+// Up применяет все ещё не применённые миграции и возвращает их
+func (m *Migrator) Up(ctx context.Context) ([]Migration, error) {
+	var applied []Migration
+	err := m.locked(ctx, func(conn *sql.Conn) error {
+		version, dirty, err := m.version(ctx, conn)
+		if err != nil {
+			return err
+		}
+		if dirty {
+			return fmt.Errorf("version %d: %w", version, ErrDirty)
+		}
+		for _, mg := range pending(m.migrations, version) {
+			if err := m.apply(ctx, conn, mg.Up, mg.Version); err != nil {
+				return fmt.Errorf("migrate up %s: %w", mg, err)
+			}
+			wbzlog.Logger.Info().Str("migration", mg.String()).Msg("migration applied")
+			applied = append(applied, mg)
+		}
+		return nil
+	})
+	return applied, err
+}
+
+// Down откатывает steps последних применённых миграций и возвращает их
+func (m *Migrator) Down(ctx context.Context, steps int) ([]Migration, error) {
+	var reverted []Migration
+	err := m.locked(ctx, func(conn *sql.Conn) error {
+		version, dirty, err := m.version(ctx, conn)
+		if err != nil {
+			return err
+		}
+		if dirty {
+			return fmt.Errorf("version %d: %w", version, ErrDirty)
+		}
+		plan, err := revertPlan(m.migrations, version, steps)
+		if err != nil {
+			return err
+		}
+		for _, mg := range plan {
+			if err := m.apply(ctx, conn, mg.Down, previous(m.migrations, mg.Version)); err != nil {
+				return fmt.Errorf("migrate down %s: %w", mg, err)
+			}
+			wbzlog.Logger.Info().Str("migration", mg.String()).Msg("migration reverted")
+			reverted = append(reverted, mg)
+		}
+		return nil
+	})
+	return reverted, err
+}
+
+func (m *Migrator) Status(ctx context.Context) (*Status, error) {
+	var st Status
+	err := m.locked(ctx, func(conn *sql.Conn) error {
+		version, dirty, err := m.version(ctx, conn)
+		if err != nil {
+			return err
+		}
+		st = Status{Version: version, Dirty: dirty, Pending: pending(m.migrations, version)}
+		return nil
+	})
+	return &st, err
+}
+
+// Force записывает версию без выполнения миграций и снимает пометку dirty. 0 — схема пуста.
+func (m *Migrator) Force(ctx context.Context, version uint64) error {
+	if version != 0 && find(m.migrations, version) < 0 {
+		return fmt.Errorf("unknown migration version %d", version)
+	}
+	return m.locked(ctx, func(conn *sql.Conn) error {
+		return m.apply(ctx, conn, "", version)
+	})
+}
+
+// locked выполняет fn на одном соединении под advisory lock: экземпляры сервиса, запущенные
+// одновременно с auto_migrate, применяют миграции по очереди, и следующие ничего не находят
+func (m *Migrator) locked(ctx context.Context, fn func(conn *sql.Conn) error) error {
+	conn, err := m.db.Conn(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	if _, err := conn.ExecContext(ctx, `SELECT pg_advisory_lock($1)`, lockID); err != nil {
+		return fmt.Errorf("acquire migration lock: %w", err)
+	}
+	defer func() {
+		// блокировка сессионная: при ошибке её снимет закрытие соединения
+		if _, err := conn.ExecContext(context.Background(), `SELECT pg_advisory_unlock($1)`, lockID); err != nil {
+			wbzlog.Logger.Warn().Err(err).Msg("Failed to release migration lock")
+		}
+	}()
+
+	_, err = conn.ExecContext(ctx, `CREATE TABLE IF NOT EXISTS schema_migrations (version BIGINT NOT NULL PRIMARY KEY, dirty BOOLEAN NOT NULL)`)
+	if err != nil {
+		return fmt.Errorf("create schema_migrations: %w", err)
+	}
+	return fn(conn)
+}
+
+func (m *Migrator) version(ctx context.Context, conn *sql.Conn) (uint64, bool, error) {
+	var version uint64
+	var dirty bool
+	err := conn.QueryRowContext(ctx, `SELECT version, dirty FROM schema_migrations LIMIT 1`).Scan(&version, &dirty)
+	if errors.Is(err, sql.ErrNoRows) {
+		return 0, false, nil
+	}
+	if err != nil {
+		return 0, false, fmt.Errorf("read schema version: %w", err)
+	}
+	return version, dirty, nil
+}
+
+// apply выполняет SQL миграции и записывает новую версию в одной транзакции: упавшая
+// миграция откатывается целиком и оставляет прежнюю версию
+func (m *Migrator) apply(ctx context.Context, conn *sql.Conn, query string, version uint64) error {
+	tx, err := conn.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = tx.Rollback()
+	}()
+
+	if query != "" {
+		if _, err := tx.ExecContext(ctx, query); err != nil {
+			return err
+		}
+	}
+	if _, err := tx.ExecContext(ctx, `DELETE FROM schema_migrations`); err != nil {
+		return err
+	}
+	if version != 0 {
+		if _, err := tx.ExecContext(ctx, `INSERT INTO schema_migrations (version, dirty) VALUES ($1, false)`, version); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// pending — миграции новее version по возрастанию. База новее бинарника (после отката
+// релиза) ничего не получает.
+func pending(migrations []Migration, version uint64) []Migration {
+	i := sort.Search(len(migrations), func(i int) bool { return migrations[i].Version > version })
+	return migrations[i:]
+}
+
+// revertPlan — steps миграций от version вниз; version должна быть известна бинарнику
+func revertPlan(migrations []Migration, version uint64, steps int) ([]Migration, error) {
+	if steps <= 0 {
+		return nil, fmt.Errorf("steps must be positive, got %d", steps)
+	}
+	if version == 0 {
+		return nil, nil
+	}
+	i := find(migrations, version)
+	if i < 0 {
+		return nil, fmt.Errorf("database version %d is unknown to this build", version)
+	}
+	plan := make([]Migration, 0, steps)
+	for ; i >= 0 && len(plan) < steps; i-- {
+		plan = append(plan, migrations[i])
+	}
+	return plan, nil
+}
+
+// previous — версия перед version или 0
+func previous(migrations []Migration, version uint64) uint64 {
+	if i := find(migrations, version); i > 0 {
+		return migrations[i-1].Version
+	}
+	return 0
+}
+
+func find(migrations []Migration, version uint64) int {
+	i := sort.Search(len(migrations), func(i int) bool { return migrations[i].Version >= version })
+	if i < len(migrations) && migrations[i].Version == version {
+		return i
+	}
+	return -1
+}
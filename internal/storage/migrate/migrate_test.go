@@ -0,0 +1,110 @@
+package migrate
+
+import (
+	"strings"
+	"testing"
+	"testing/fstest"
+
+	"warehousecontrol/migrations"
+)
+
+func file(body string) *fstest.MapFile {
+	return &fstest.MapFile{Data: []byte(body)}
+}
+
+func TestLoad_SortsPairs(t *testing.T) {
+	ms, err := Load(fstest.MapFS{
+		"000010_second.up.sql":   file("CREATE TABLE b ();"),
+		"000010_second.down.sql": file("DROP TABLE b;"),
+		"000002_first.up.sql":    file("CREATE TABLE a ();"),
+		"000002_first.down.sql":  file("DROP TABLE a;"),
+		"migrations.go":          file("package migrations"),
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(ms) != 2 || ms[0].Version != 2 || ms[1].Version != 10 {
+		t.Fatalf("expected versions 2, 10, got %v", ms)
+	}
+	if ms[0].String() != "000002_first" || ms[1].Down != "DROP TABLE b;" {
+		t.Fatalf("unexpected migration %+v", ms[0])
+	}
+}
+
+func TestLoad_Invalid(t *testing.T) {
+	cases := map[string]fstest.MapFS{
+		"missing down": {"000001_a.up.sql": file("SELECT 1;")},
+		"empty down": {
+			"000001_a.up.sql":   file("SELECT 1;"),
+			"000001_a.down.sql": file(""),
+		},
+		"name mismatch": {
+			"000001_a.up.sql":   file("SELECT 1;"),
+			"000001_b.down.sql": file("SELECT 1;"),
+		},
+		"zero version": {
+			"000000_a.up.sql":   file("SELECT 1;"),
+			"000000_a.down.sql": file("SELECT 1;"),
+		},
+	}
+	for name, fsys := range cases {
+		t.Run(name, func(t *testing.T) {
+			if _, err := Load(fsys); err == nil {
+				t.Fatal("expected error")
+			}
+		})
+	}
+}
+
+func TestLoad_Embedded(t *testing.T) {
+	ms, err := Load(migrations.FS)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(ms) == 0 {
+		t.Fatal("no migrations embedded")
+	}
+	for i, m := range ms {
+		if m.Version != uint64(i+1) {
+			t.Fatalf("expected version %d, got %s", i+1, m)
+		}
+		if strings.TrimSpace(m.Down) == "" {
+			t.Fatalf("%s has no rollback", m)
+		}
+	}
+}
+
+func TestPendingAndRevertPlan(t *testing.T) {
+	ms := []Migration{{Version: 1}, {Version: 2}, {Version: 5}}
+
+	if got := pending(ms, 0); len(got) != 3 {
+		t.Fatalf("expected all pending, got %v", got)
+	}
+	if got := pending(ms, 2); len(got) != 1 || got[0].Version != 5 {
+		t.Fatalf("expected only 5 pending, got %v", got)
+	}
+	// база новее бинарника
+	if got := pending(ms, 7); len(got) != 0 {
+		t.Fatalf("expected nothing pending, got %v", got)
+	}
+
+	plan, err := revertPlan(ms, 5, 2)
+	if err != nil || len(plan) != 2 || plan[0].Version != 5 || plan[1].Version != 2 {
+		t.Fatalf("expected 5, 2, got %v (%v)", plan, err)
+	}
+	if plan, err := revertPlan(ms, 2, 10); err != nil || len(plan) != 2 {
+		t.Fatalf("expected down to empty schema, got %v (%v)", plan, err)
+	}
+	if plan, err := revertPlan(ms, 0, 1); err != nil || len(plan) != 0 {
+		t.Fatalf("expected nothing to revert, got %v (%v)", plan, err)
+	}
+	if _, err := revertPlan(ms, 3, 1); err == nil {
+		t.Fatal("expected error for unknown version")
+	}
+	if _, err := revertPlan(ms, 5, 0); err == nil {
+		t.Fatal("expected error for non-positive steps")
+	}
+	if previous(ms, 5) != 2 || previous(ms, 1) != 0 {
+		t.Fatal("unexpected previous version")
+	}
+}
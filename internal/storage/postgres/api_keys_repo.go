@@ -0,0 +1,209 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"warehousecontrol/internal/domain/user"
+
+	"github.com/lib/pq"
+	"github.com/wb-go/wbf/retry"
+	wbzlog "github.com/wb-go/wbf/zlog"
+)
+
+const apiKeyColumns = `id, user_id, name, prefix, key_hash, scopes, created_at, expires_at, last_used_at, revoked_at`
+
+func scanAPIKey(row rowScanner) (*user.APIKey, error) {
+	var k user.APIKey
+	err := row.Scan(
+		&k.ID,
+		&k.UserID,
+		&k.Name,
+		&k.Prefix,
+		&k.KeyHash,
+		pq.Array(&k.Scopes),
+		&k.CreatedAt,
+		&k.ExpiresAt,
+		&k.LastUsedAt,
+		&k.RevokedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return &k, nil
+}
+
+// CreateAPIKey сохраняет ключ сервисной учётной записи
+func (p *Postgres) CreateAPIKey(ctx context.Context, k *user.APIKey, actorID, actorLogin string) error {
+	return p.modifyUser(ctx, k.UserID.String(), actorID, actorLogin, func(ctx context.Context, tx *sql.Tx, u *user.User) (*user.AuditEntry, error) {
+		if !u.ServiceAccount {
+			return nil, user.ErrNotServiceAccount
+		}
+		if err := insertAPIKey(ctx, tx, k); err != nil {
+			return nil, err
+		}
+		return &user.AuditEntry{Action: user.AuditAPIKeyCreated, NewValue: apiKeyLabel(k)}, nil
+	})
+}
+
+// RotateAPIKey выпускает ключ next на замену oldID. Старый ключ действует ещё grace,
+// но не дольше своего срока; при нулевом grace он отзывается сразу.
+func (p *Postgres) RotateAPIKey(ctx context.Context, oldID string, next *user.APIKey, grace time.Duration, actorID, actorLogin string) error {
+	return p.modifyUser(ctx, next.UserID.String(), actorID, actorLogin, func(ctx context.Context, tx *sql.Tx, u *user.User) (*user.AuditEntry, error) {
+		old, err := scanAPIKey(tx.QueryRowContext(ctx, `
+			SELECT `+apiKeyColumns+` FROM api_keys WHERE id = $1 AND user_id = $2 FOR UPDATE
+		`, oldID, u.Id))
+		if err == sql.ErrNoRows {
+			return nil, user.ErrAPIKeyNotFound
+		}
+		if err != nil {
+			wbzlog.Logger.Error().Err(err).Msg("Failed to scan api key row")
+			return nil, err
+		}
+		if !old.Active(time.Now()) {
+			return nil, user.ErrAPIKeyInactive
+		}
+
+		if grace > 0 {
+			_, err = tx.ExecContext(ctx, `
+				UPDATE api_keys SET expires_at = LEAST(COALESCE(expires_at, $2), $2) WHERE id = $1
+			`, old.ID, time.Now().Add(grace))
+		} else {
+			_, err = tx.ExecContext(ctx, `UPDATE api_keys SET revoked_at = now() WHERE id = $1`, old.ID)
+		}
+		if err != nil {
+			wbzlog.Logger.Error().Err(err).Msg("Failed to retire rotated api key")
+			return nil, err
+		}
+		if err := insertAPIKey(ctx, tx, next); err != nil {
+			return nil, err
+		}
+		return &user.AuditEntry{Action: user.AuditAPIKeyRotated, OldValue: apiKeyLabel(old), NewValue: apiKeyLabel(next)}, nil
+	})
+}
+
+// RevokeAPIKey отзывает ключ; отозванный или чужой ключ — ErrAPIKeyNotFound
+func (p *Postgres) RevokeAPIKey(ctx context.Context, userID, keyID, actorID, actorLogin string) error {
+	return p.modifyUser(ctx, userID, actorID, actorLogin, func(ctx context.Context, tx *sql.Tx, u *user.User) (*user.AuditEntry, error) {
+		k, err := scanAPIKey(tx.QueryRowContext(ctx, `
+			UPDATE api_keys SET revoked_at = now()
+			WHERE id = $1 AND user_id = $2 AND revoked_at IS NULL
+			RETURNING `+apiKeyColumns, keyID, u.Id))
+		if err == sql.ErrNoRows {
+			return nil, user.ErrAPIKeyNotFound
+		}
+		if err != nil {
+			wbzlog.Logger.Error().Err(err).Msg("Failed to revoke api key")
+			return nil, err
+		}
+		return &user.AuditEntry{Action: user.AuditAPIKeyRevoked, OldValue: apiKeyLabel(k)}, nil
+	})
+}
+
+func (p *Postgres) GetAPIKey(ctx context.Context, userID, keyID string) (*user.APIKey, error) {
+	ctx, cancel := p.withTimeout(ctx)
+	defer cancel()
+
+	query := `SELECT ` + apiKeyColumns + ` FROM api_keys WHERE id = $1 AND user_id = $2`
+	row, err := p.db.QueryRowWithRetry(ctx, retry.Strategy{Attempts: p.cfg.Attempts, Delay: p.cfg.Delay, Backoff: p.cfg.Backoffs}, query, keyID, userID)
+	if err != nil {
+		wbzlog.Logger.Error().Err(err).Msg("Failed to execute get api key query")
+		return nil, err
+	}
+	k, err := scanAPIKey(row)
+	if err == sql.ErrNoRows {
+		return nil, user.ErrAPIKeyNotFound
+	}
+	if err != nil {
+		wbzlog.Logger.Error().Err(err).Msg("Failed to scan api key row")
+		return nil, err
+	}
+	return k, nil
+}
+
+func (p *Postgres) GetAPIKeyByHash(ctx context.Context, keyHash []byte) (*user.APIKey, error) {
+	ctx, cancel := p.withTimeout(ctx)
+	defer cancel()
+
+	query := `SELECT ` + apiKeyColumns + ` FROM api_keys WHERE key_hash = $1`
+	row, err := p.db.QueryRowWithRetry(ctx, retry.Strategy{Attempts: p.cfg.Attempts, Delay: p.cfg.Delay, Backoff: p.cfg.Backoffs}, query, keyHash)
+	if err != nil {
+		wbzlog.Logger.Error().Err(err).Msg("Failed to execute get api key by hash query")
+		return nil, err
+	}
+	k, err := scanAPIKey(row)
+	if err == sql.ErrNoRows {
+		return nil, user.ErrAPIKeyNotFound
+	}
+	if err != nil {
+		wbzlog.Logger.Error().Err(err).Msg("Failed to scan api key row")
+		return nil, err
+	}
+	return k, nil
+}
+
+// GetAPIKeys возвращает все ключи учётной записи, включая отозванные, новые — первыми
+func (p *Postgres) GetAPIKeys(ctx context.Context, userID string) ([]*user.APIKey, error) {
+	ctx, cancel := p.withTimeout(ctx)
+	defer cancel()
+
+	query := `SELECT ` + apiKeyColumns + ` FROM api_keys WHERE user_id = $1 ORDER BY created_at DESC`
+	rows, err := p.db.QueryWithRetry(ctx, retry.Strategy{Attempts: p.cfg.Attempts, Delay: p.cfg.Delay, Backoff: p.cfg.Backoffs}, query, userID)
+	if err != nil {
+		wbzlog.Logger.Error().Err(err).Msg("Failed to execute get api keys query")
+		return nil, err
+	}
+	defer func() {
+		err = rows.Close()
+		if err != nil {
+			wbzlog.Logger.Error().Err(err).Msg("Failed to close api key rows")
+		}
+	}()
+
+	keys := []*user.APIKey{}
+	for rows.Next() {
+		k, err := scanAPIKey(rows)
+		if err != nil {
+			wbzlog.Logger.Error().Err(err).Msg("Failed to scan api key row")
+			return nil, err
+		}
+		keys = append(keys, k)
+	}
+	if err := rows.Err(); err != nil {
+		wbzlog.Logger.Error().Err(err).Msg("Failed to iterate api key rows")
+		return nil, err
+	}
+	return keys, nil
+}
+
+// TouchAPIKey отмечает использование ключа
+func (p *Postgres) TouchAPIKey(ctx context.Context, id string) error {
+	ctx, cancel := p.withTimeout(ctx)
+	defer cancel()
+
+	_, err := p.db.ExecWithRetry(ctx, retry.Strategy{Attempts: p.cfg.Attempts, Delay: p.cfg.Delay, Backoff: p.cfg.Backoffs},
+		`UPDATE api_keys SET last_used_at = now() WHERE id = $1`, id)
+	if err != nil {
+		wbzlog.Logger.Error().Err(err).Msg("Failed to touch api key")
+		return err
+	}
+	return nil
+}
+
+func insertAPIKey(ctx context.Context, tx *sql.Tx, k *user.APIKey) error {
+	_, err := tx.ExecContext(ctx, `
+		INSERT INTO api_keys (id, user_id, name, prefix, key_hash, scopes, created_at, expires_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+	`, k.ID, k.UserID, k.Name, k.Prefix, k.KeyHash, pq.Array(k.Scopes), k.CreatedAt, k.ExpiresAt)
+	if err != nil {
+		wbzlog.Logger.Error().Err(err).Msg("Failed to execute insert api key query")
+		return err
+	}
+	return nil
+}
+
+// apiKeyLabel — ключ в журнале аудита: имя и префикс, по которым его можно опознать
+func apiKeyLabel(k *user.APIKey) *string {
+	return strPtr(k.Name + " (" + k.Prefix + "…)")
+}
@@ -2,10 +2,9 @@ package postgres
 
 import (
 	"context"
+	"database/sql"
 	"encoding/json"
 	"fmt"
-	"strings"
-	"time"
 
 	"github.com/wb-go/wbf/retry"
 	wbzlog "github.com/wb-go/wbf/zlog"
@@ -14,50 +13,110 @@ import (
 	"warehousecontrol/internal/domain/item"
 )
 
-func (p *Postgres) GetItemsHistory(id string, from, to time.Time, action string, login string) ([]*history.History, error) {
-	ctx := context.Background()
+const historyColumns = `id, seq, item_id, location_id, action, changed_by, changed_by_login, changed_at, old_data, new_data, source_history_id`
 
-	queryBuilder := strings.Builder{}
-	queryBuilder.WriteString(`
-        SELECT id, item_id, action, changed_by, changed_by_login, changed_at,
-               old_data, new_data
-        FROM history
-        WHERE 1 = 1
-    `)
+// GetItemsHistory возвращает страницу истории по возрастанию (changed_at, seq), начиная после cursor
+func (p *Postgres) GetItemsHistory(ctx context.Context, f history.Filter, limit int, cursor *history.Cursor) (*history.Page, error) {
+	ctx, cancel := p.withTimeout(ctx)
+	defer cancel()
+	db := p.replicaReader(ctx)
 
-	args := []interface{}{}
-	argIndex := 1
+	conds, args := historyFilters(f)
+	if cursor != nil {
+		args = append(args, cursor.ChangedAt, cursor.Seq)
+		conds = append(conds, fmt.Sprintf("(changed_at, seq) > ($%d, $%d)", len(args)-1, len(args)))
+	}
+	// берём на одну строку больше, чтобы понять, есть ли следующая страница
+	args = append(args, limit+1)
+	query := fmt.Sprintf(`
+		SELECT %s
+		FROM history%s
+		ORDER BY changed_at, seq
+		LIMIT $%d
+	`, historyColumns, whereClause(conds), len(args))
 
-	if !from.IsZero() {
-		queryBuilder.WriteString(fmt.Sprintf(" AND changed_at >= $%d", argIndex))
-		args = append(args, from)
-		argIndex++
+	rows, err := db.query(ctx, query, args...)
+	if err != nil {
+		wbzlog.Logger.Error().Err(err).Msg("Failed to execute get items history query")
+		return nil, err
 	}
-	if !to.IsZero() {
-		queryBuilder.WriteString(fmt.Sprintf(" AND changed_at <= $%d", argIndex))
-		args = append(args, to)
-		argIndex++
+	defer func() {
+		err = rows.Close()
+		if err != nil {
+			wbzlog.Logger.Error().Err(err).Msg("Failed to close history rows")
+		}
+	}()
+
+	page := &history.Page{Items: []*history.History{}}
+	for rows.Next() {
+		h, err := scanHistory(rows)
+		if err != nil {
+			return nil, err
+		}
+		page.Items = append(page.Items, h)
 	}
-	if id != "" {
-		queryBuilder.WriteString(fmt.Sprintf(" AND item_id = $%d", argIndex))
-		args = append(args, id)
-		argIndex++
+	if err := rows.Err(); err != nil {
+		wbzlog.Logger.Error().Err(err).Msg("Failed to iterate history rows")
+		return nil, err
 	}
-	if action != "" {
-		queryBuilder.WriteString(fmt.Sprintf(" AND action = $%d", argIndex))
-		args = append(args, action)
-		argIndex++
+
+	if len(page.Items) > limit {
+		page.Items = page.Items[:limit]
+		page.NextCursor = history.NewCursor(page.Items[limit-1]).Encode()
 	}
-	if login != "" {
-		queryBuilder.WriteString(fmt.Sprintf(" AND changed_by_login ILIKE $%d", argIndex))
-		args = append(args, "%"+login+"%")
+
+	return page, nil
+}
+
+// StreamItemsHistory отдаёт записи истории в fn по одной, не накапливая их в памяти.
+// Ошибка fn прерывает выборку и возвращается как есть. Выгрузка может идти дольше
+// query_timeout, поэтому её прерывает только отмена ctx.
+func (p *Postgres) StreamItemsHistory(ctx context.Context, f history.Filter, fn func(*history.History) error) error {
+	conds, args := historyFilters(f)
+	query := fmt.Sprintf(`
+		SELECT %s
+		FROM history%s
+		ORDER BY changed_at, seq
+	`, historyColumns, whereClause(conds))
+
+	rows, err := p.replicaReader(ctx).query(ctx, query, args...)
+	if err != nil {
+		wbzlog.Logger.Error().Err(err).Msg("Failed to execute stream items history query")
+		return err
 	}
+	defer func() {
+		err = rows.Close()
+		if err != nil {
+			wbzlog.Logger.Error().Err(err).Msg("Failed to close history rows")
+		}
+	}()
+
+	for rows.Next() {
+		h, err := scanHistory(rows)
+		if err != nil {
+			return err
+		}
+		if err := fn(h); err != nil {
+			return err
+		}
+	}
+	if err := rows.Err(); err != nil {
+		wbzlog.Logger.Error().Err(err).Msg("Failed to iterate history rows")
+		return err
+	}
+	return nil
+}
 
-	query := queryBuilder.String()
+// GetHistoryRecord возвращает одну запись истории по её ID
+func (p *Postgres) GetHistoryRecord(ctx context.Context, id string) (*history.History, error) {
+	ctx, cancel := p.withTimeout(ctx)
+	defer cancel()
 
-	rows, err := p.db.QueryWithRetry(ctx, retry.Strategy{Attempts: p.cfg.Attempts, Delay: p.cfg.Delay, Backoff: p.cfg.Backoffs}, query, args...)
+	query := `SELECT ` + historyColumns + ` FROM history WHERE id = $1`
+
+	rows, err := p.db.QueryWithRetry(ctx, retry.Strategy{Attempts: p.cfg.Attempts, Delay: p.cfg.Delay, Backoff: p.cfg.Backoffs}, query, id)
 	if err != nil {
-		wbzlog.Logger.Error().Err(err).Msg("Failed to execute get items history query")
+		wbzlog.Logger.Error().Err(err).Msg("Failed to execute get history record query")
 		return nil, err
 	}
 	defer func() {
@@ -67,46 +126,155 @@ func (p *Postgres) GetItemsHistory(id string, from, to time.Time, action string,
 		}
 	}()
 
-	histories := []*history.History{}
+	if !rows.Next() {
+		if err := rows.Err(); err != nil {
+			return nil, err
+		}
+		return nil, history.ErrNotFound
+	}
+	return scanHistory(rows)
+}
 
-	for rows.Next() {
-		h := &history.History{}
-
-		var oldJSON, newJSON []byte
-
-		err := rows.Scan(
-			&h.ID,
-			&h.ItemID,
-			&h.Action,
-			&h.ChangedBy,
-			&h.ChangedByLogin,
-			&h.ChangedAt,
-			&oldJSON,
-			&newJSON,
-		)
+// GetItemStateAt возвращает последнюю запись о самом товаре (не о ячейках) не позже позиции at
+func (p *Postgres) GetItemStateAt(ctx context.Context, itemID string, at history.Cursor) (*history.History, error) {
+	ctx, cancel := p.withTimeout(ctx)
+	defer cancel()
+	db := p.replicaReader(ctx)
+
+	query := `
+		SELECT ` + historyColumns + `
+		FROM history
+		WHERE item_id = $1 AND location_id IS NULL AND (changed_at, seq) <= ($2, $3)
+		ORDER BY changed_at DESC, seq DESC
+		LIMIT 1
+	`
+
+	rows, err := db.query(ctx, query, itemID, at.ChangedAt, at.Seq)
+	if err != nil {
+		wbzlog.Logger.Error().Err(err).Msg("Failed to execute get item state query")
+		return nil, err
+	}
+	defer func() {
+		err = rows.Close()
 		if err != nil {
-			wbzlog.Logger.Error().Err(err).Msg("Failed to scan history row")
+			wbzlog.Logger.Error().Err(err).Msg("Failed to close history rows")
+		}
+	}()
+
+	if !rows.Next() {
+		if err := rows.Err(); err != nil {
 			return nil, err
 		}
+		return nil, history.ErrNotFound
+	}
+	return scanHistory(rows)
+}
 
-		if len(oldJSON) > 0 {
-			var old item.Item
-			if err := json.Unmarshal(oldJSON, &old); err != nil {
-				return nil, err
-			}
-			h.OldItemSnapshot = old
+// GetItemChanges возвращает изменения самого товара после позиции after и не позже upTo в хронологическом порядке
+func (p *Postgres) GetItemChanges(ctx context.Context, itemID string, after history.Cursor, upTo history.Cursor) ([]*history.History, error) {
+	ctx, cancel := p.withTimeout(ctx)
+	defer cancel()
+	db := p.replicaReader(ctx)
+
+	query := `
+		SELECT ` + historyColumns + `
+		FROM history
+		WHERE item_id = $1 AND location_id IS NULL
+		  AND (changed_at, seq) > ($2, $3) AND (changed_at, seq) <= ($4, $5)
+		ORDER BY changed_at, seq
+	`
+
+	rows, err := db.query(ctx, query,
+		itemID, after.ChangedAt, after.Seq, upTo.ChangedAt, upTo.Seq)
+	if err != nil {
+		wbzlog.Logger.Error().Err(err).Msg("Failed to execute get item changes query")
+		return nil, err
+	}
+	defer func() {
+		err = rows.Close()
+		if err != nil {
+			wbzlog.Logger.Error().Err(err).Msg("Failed to close history rows")
+		}
+	}()
+
+	changes := []*history.History{}
+	for rows.Next() {
+		h, err := scanHistory(rows)
+		if err != nil {
+			return nil, err
 		}
+		changes = append(changes, h)
+	}
+	if err := rows.Err(); err != nil {
+		wbzlog.Logger.Error().Err(err).Msg("Failed to iterate history rows")
+		return nil, err
+	}
+	return changes, nil
+}
+
+func historyFilters(f history.Filter) ([]string, []any) {
+	var conds []string
+	var args []any
+	add := func(cond string, arg any) {
+		args = append(args, arg)
+		conds = append(conds, fmt.Sprintf(cond, len(args)))
+	}
 
-		if len(newJSON) > 0 {
-			var nw item.Item
-			if err := json.Unmarshal(newJSON, &nw); err != nil {
-				return nil, err
-			}
-			h.NewItemSnapshot = nw
+	if !f.From.IsZero() {
+		add("changed_at >= $%d", f.From)
+	}
+	if !f.To.IsZero() {
+		add("changed_at <= $%d", f.To)
+	}
+	if f.ItemID != "" {
+		add("item_id = $%d", f.ItemID)
+	}
+	if f.Action != "" {
+		add("action = $%d", f.Action)
+	}
+	if f.Login != "" {
+		add(`changed_by_login ILIKE $%d ESCAPE '\'`, "%"+likeEscaper.Replace(f.Login)+"%")
+	}
+	return conds, args
+}
+
+func scanHistory(rows *sql.Rows) (*history.History, error) {
+	h := &history.History{}
+	var oldJSON, newJSON []byte
+
+	err := rows.Scan(
+		&h.ID,
+		&h.Seq,
+		&h.ItemID,
+		&h.LocationID,
+		&h.Action,
+		&h.ChangedBy,
+		&h.ChangedByLogin,
+		&h.ChangedAt,
+		&oldJSON,
+		&newJSON,
+		&h.SourceHistoryID,
+	)
+	if err != nil {
+		wbzlog.Logger.Error().Err(err).Msg("Failed to scan history row")
+		return nil, err
+	}
+
+	if len(oldJSON) > 0 {
+		var old item.Item
+		if err := json.Unmarshal(oldJSON, &old); err != nil {
+			return nil, err
 		}
-		h.ItemDiff = h.OldItemSnapshot.Diff(h.NewItemSnapshot)
-		histories = append(histories, h)
+		h.OldItemSnapshot = old
 	}
 
-	return histories, nil
+	if len(newJSON) > 0 {
+		var nw item.Item
+		if err := json.Unmarshal(newJSON, &nw); err != nil {
+			return nil, err
+		}
+		h.NewItemSnapshot = nw
+	}
+	h.ItemDiff = h.OldItemSnapshot.Diff(h.NewItemSnapshot)
+	return h, nil
 }
@@ -0,0 +1,234 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+
+	"warehousecontrol/internal/domain/user"
+
+	"github.com/wb-go/wbf/retry"
+	wbzlog "github.com/wb-go/wbf/zlog"
+)
+
+const invitationColumns = `id, role, token_hash, created_by, created_at, expires_at, used_at, used_by`
+
+type rowScanner interface {
+	Scan(dest ...any) error
+}
+
+func scanInvitation(row rowScanner) (*user.Invitation, error) {
+	var inv user.Invitation
+	err := row.Scan(
+		&inv.ID,
+		&inv.Role,
+		&inv.TokenHash,
+		&inv.CreatedBy,
+		&inv.CreatedAt,
+		&inv.ExpiresAt,
+		&inv.UsedAt,
+		&inv.UsedBy,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return &inv, nil
+}
+
+func (p *Postgres) SaveInvitation(ctx context.Context, inv *user.Invitation) error {
+	ctx, cancel := p.withTimeout(ctx)
+	defer cancel()
+
+	query := `
+		INSERT INTO invitations (id, role, token_hash, created_by, created_at, expires_at)
+		VALUES ($1, $2, $3, $4, $5, $6)
+	`
+
+	_, err := p.db.ExecWithRetry(ctx, retry.Strategy{Attempts: p.cfg.Attempts, Delay: p.cfg.Delay, Backoff: p.cfg.Backoffs}, query,
+		inv.ID,
+		inv.Role,
+		inv.TokenHash,
+		inv.CreatedBy,
+		inv.CreatedAt,
+		inv.ExpiresAt,
+	)
+	if err != nil {
+		wbzlog.Logger.Error().Err(err).Msg("Failed to execute insert invitation query")
+		return err
+	}
+	return nil
+}
+
+func (p *Postgres) GetInvitationByHash(ctx context.Context, tokenHash []byte) (*user.Invitation, error) {
+	ctx, cancel := p.withTimeout(ctx)
+	defer cancel()
+
+	query := `SELECT ` + invitationColumns + ` FROM invitations WHERE token_hash = $1`
+
+	row, err := p.db.QueryRowWithRetry(ctx, retry.Strategy{Attempts: p.cfg.Attempts, Delay: p.cfg.Delay, Backoff: p.cfg.Backoffs}, query, tokenHash)
+	if err != nil {
+		wbzlog.Logger.Error().Err(err).Msg("Failed to execute get invitation query")
+		return nil, err
+	}
+	inv, err := scanInvitation(row)
+	if err == sql.ErrNoRows {
+		return nil, user.ErrInvitationNotFound
+	}
+	if err != nil {
+		wbzlog.Logger.Error().Err(err).Msg("Failed to scan invitation row")
+		return nil, err
+	}
+	return inv, nil
+}
+
+func (p *Postgres) GetInvitations(ctx context.Context) ([]*user.Invitation, error) {
+	ctx, cancel := p.withTimeout(ctx)
+	defer cancel()
+
+	query := `SELECT ` + invitationColumns + ` FROM invitations ORDER BY created_at DESC`
+
+	rows, err := p.db.QueryWithRetry(ctx, retry.Strategy{Attempts: p.cfg.Attempts, Delay: p.cfg.Delay, Backoff: p.cfg.Backoffs}, query)
+	if err != nil {
+		wbzlog.Logger.Error().Err(err).Msg("Failed to execute get invitations query")
+		return nil, err
+	}
+	defer func() {
+		err = rows.Close()
+		if err != nil {
+			wbzlog.Logger.Error().Err(err).Msg("Failed to close invitation rows")
+		}
+	}()
+
+	invitations := []*user.Invitation{}
+	for rows.Next() {
+		inv, err := scanInvitation(rows)
+		if err != nil {
+			wbzlog.Logger.Error().Err(err).Msg("Failed to scan invitation row")
+			return nil, err
+		}
+		invitations = append(invitations, inv)
+	}
+	return invitations, nil
+}
+
+// DeleteInvitation отзывает неиспользованное приглашение; использованные остаются для аудита
+func (p *Postgres) DeleteInvitation(ctx context.Context, id string) error {
+	ctx, cancel := p.withTimeout(ctx)
+	defer cancel()
+
+	res, err := p.db.ExecWithRetry(ctx, retry.Strategy{Attempts: p.cfg.Attempts, Delay: p.cfg.Delay, Backoff: p.cfg.Backoffs},
+		`DELETE FROM invitations WHERE id = $1 AND used_at IS NULL`, id)
+	if err != nil {
+		wbzlog.Logger.Error().Err(err).Msg("Failed to execute delete invitation query")
+		return err
+	}
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if affected > 0 {
+		return nil
+	}
+
+	var exists bool
+	row, err := p.db.QueryRowWithRetry(ctx, retry.Strategy{Attempts: p.cfg.Attempts, Delay: p.cfg.Delay, Backoff: p.cfg.Backoffs},
+		`SELECT EXISTS(SELECT 1 FROM invitations WHERE id = $1)`, id)
+	if err != nil {
+		return err
+	}
+	if err := row.Scan(&exists); err != nil {
+		wbzlog.Logger.Error().Err(err).Msg("Failed to check invitation existence")
+		return err
+	}
+	if !exists {
+		return user.ErrInvitationNotFound
+	}
+	return user.ErrInvitationUsed
+}
+
+// SaveUserWithInvitation создаёт пользователя и в той же транзакции гасит приглашение.
+// Условие в UPDATE не даёт использовать одно приглашение дважды при одновременных регистрациях.
+func (p *Postgres) SaveUserWithInvitation(ctx context.Context, u *user.User, invitationID string) error {
+	ctx, cancel := p.withTimeout(ctx)
+	defer cancel()
+
+	tx, err := p.db.Master.BeginTx(ctx, nil)
+	if err != nil {
+		wbzlog.Logger.Error().Err(err).Msg("cant start transaction in save user with invitation")
+		return err
+	}
+	defer func() {
+		_ = tx.Rollback()
+	}()
+
+	err = insertUser(ctx, tx, u)
+	if err != nil {
+		return err
+	}
+
+	res, err := tx.ExecContext(ctx, `
+		UPDATE invitations
+		SET used_at = now(), used_by = $2
+		WHERE id = $1 AND used_at IS NULL AND expires_at > now()
+	`, invitationID, u.Id)
+	if err != nil {
+		wbzlog.Logger.Error().Err(err).Msg("Failed to redeem invitation")
+		return err
+	}
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if affected == 0 {
+		return user.ErrInvitationUsed
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		wbzlog.Logger.Error().Err(err).Msg("Failed to commit transaction")
+		return err
+	}
+	return nil
+}
+
+// CreateFirstAdmin создаёт администратора, только если в системе ещё нет ни одного.
+// Блокировка таблицы исключает двух «первых» администраторов при одновременных запросах.
+func (p *Postgres) CreateFirstAdmin(ctx context.Context, u *user.User) error {
+	ctx, cancel := p.withTimeout(ctx)
+	defer cancel()
+
+	tx, err := p.db.Master.BeginTx(ctx, nil)
+	if err != nil {
+		wbzlog.Logger.Error().Err(err).Msg("cant start transaction in create first admin")
+		return err
+	}
+	defer func() {
+		_ = tx.Rollback()
+	}()
+
+	_, err = tx.ExecContext(ctx, `LOCK TABLE users IN SHARE ROW EXCLUSIVE MODE`)
+	if err != nil {
+		wbzlog.Logger.Error().Err(err).Msg("Failed to lock users table")
+		return err
+	}
+	var exists bool
+	err = tx.QueryRowContext(ctx, `SELECT EXISTS(SELECT 1 FROM users WHERE role = $1)`, user.Admin).Scan(&exists)
+	if err != nil {
+		wbzlog.Logger.Error().Err(err).Msg("Failed to check existing admin")
+		return err
+	}
+	if exists {
+		return user.ErrAlreadyBootstrapped
+	}
+
+	err = insertUser(ctx, tx, u)
+	if err != nil {
+		return err
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		wbzlog.Logger.Error().Err(err).Msg("Failed to commit transaction")
+		return err
+	}
+	return nil
+}
@@ -3,15 +3,24 @@ package postgres
 import (
 	"context"
 	"database/sql"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
 
+	"warehousecontrol/internal/domain/history"
 	"warehousecontrol/internal/domain/item"
+	"warehousecontrol/internal/domain/movement"
 
+	"github.com/google/uuid"
 	"github.com/wb-go/wbf/retry"
 	wbzlog "github.com/wb-go/wbf/zlog"
 )
 
-func (p *Postgres) CreateItem(item *item.Item, userID string, login string) error {
-	ctx := context.Background()
+func (p *Postgres) CreateItem(ctx context.Context, item *item.Item, userID string, login string) error {
+	ctx, cancel := p.withTimeout(ctx)
+	defer cancel()
+	defer p.replicas.wrote(ctx)
 
 	tx, err := p.setHistoryConfig(ctx, userID, login)
 	if err != nil {
@@ -43,6 +52,19 @@ func (p *Postgres) CreateItem(item *item.Item, userID string, login string) erro
 		return err
 	}
 
+	if item.Count != 0 {
+		// начальный остаток тоже проходит через журнал движений
+		kind := movement.Receipt
+		if item.Count < 0 {
+			kind = movement.Adjustment
+		}
+		m := &movement.Movement{ID: uuid.New(), ItemID: item.ID, Type: kind, Quantity: item.Count, Reason: "item created"}
+		err = insertMovement(ctx, tx, m)
+		if err != nil {
+			return err
+		}
+	}
+
 	err = tx.Commit()
 	if err != nil {
 		wbzlog.Logger.Error().Err(err).Msg("Failed to commit transaction")
@@ -52,15 +74,82 @@ func (p *Postgres) CreateItem(item *item.Item, userID string, login string) erro
 	return nil
 }
 
-func (p *Postgres) GetItems() ([]*item.Item, error) {
-	ctx := context.Background()
+// itemSortColumns — белый список колонок сортировки, подставляемых в ORDER BY
+var itemSortColumns = map[string]string{
+	item.SortByName:  "name",
+	item.SortByCount: "count",
+	item.SortByPrice: "price",
+}
 
-	query := `
-		SELECT id, name, count, price
-		FROM items
-	`
+// itemsAsOfSource — товары на момент $1: последний снимок new_data каждого товара из истории.
+// У удалённого к этому моменту товара последний снимок пустой, и он не попадает в выборку.
+// Снимки до появления версии считаются версией 1.
+const itemsAsOfSource = `(
+		SELECT (s.new_data->>'id')::uuid AS id,
+		       s.new_data->>'name' AS name,
+		       (s.new_data->>'count')::int AS count,
+		       (s.new_data->>'price')::numeric AS price,
+		       COALESCE((s.new_data->>'version')::int, 1) AS version
+		FROM (
+			SELECT DISTINCT ON (item_id) new_data
+			FROM history
+			WHERE location_id IS NULL AND changed_at <= $1
+			ORDER BY item_id, changed_at DESC, seq DESC
+		) s
+		WHERE s.new_data IS NOT NULL
+	) AS items`
+
+func (p *Postgres) GetItems(ctx context.Context, opts item.ListOptions) (*item.Page, error) {
+	ctx, cancel := p.withTimeout(ctx)
+	defer cancel()
+	db := p.replicaReader(ctx)
+
+	source, args := "items", []any(nil)
+	if opts.AsOf != nil {
+		source, args = itemsAsOfSource, []any{*opts.AsOf}
+	}
+	conds, args := itemListFilters(opts, args)
+
+	countQuery := `SELECT COUNT(*) FROM ` + source + whereClause(conds)
+	page := &item.Page{Items: []*item.Item{}}
+	row, err := db.queryRow(ctx, countQuery, args...)
+	if err != nil {
+		wbzlog.Logger.Error().Err(err).Msg("Failed to execute count items query")
+		return nil, err
+	}
+	err = row.Scan(&page.Total)
+	if err != nil {
+		wbzlog.Logger.Error().Err(err).Msg("Failed to scan items count")
+		return nil, err
+	}
 
-	rows, err := p.db.QueryWithRetry(ctx, retry.Strategy{Attempts: p.cfg.Attempts, Delay: p.cfg.Delay, Backoff: p.cfg.Backoffs}, query)
+	column, ok := itemSortColumns[opts.SortBy]
+	if !ok {
+		column = "name"
+	}
+	dir, cmp := "ASC", ">"
+	if opts.Desc {
+		dir, cmp = "DESC", "<"
+	}
+	if opts.Cursor != nil {
+		args = append(args, opts.Cursor.Value(), opts.Cursor.ID)
+		conds = append(conds, fmt.Sprintf("(%s, id) %s ($%d, $%d)", column, cmp, len(args)-1, len(args)))
+	}
+
+	// берём на одну строку больше, чтобы понять, есть ли следующая страница
+	args = append(args, opts.Limit+1)
+	query := fmt.Sprintf(`
+		SELECT id, name, count, price, version
+		FROM %s%s
+		ORDER BY %s %s, id %s
+		LIMIT $%d
+	`, source, whereClause(conds), column, dir, dir, len(args))
+	if opts.Cursor == nil && opts.Offset > 0 {
+		args = append(args, opts.Offset)
+		query += fmt.Sprintf(" OFFSET $%d", len(args))
+	}
+
+	rows, err := db.query(ctx, query, args...)
 	if err != nil {
 		wbzlog.Logger.Error().Err(err).Msg("Failed to execute get items query")
 		return nil, err
@@ -68,11 +157,10 @@ func (p *Postgres) GetItems() ([]*item.Item, error) {
 	defer func() {
 		err = rows.Close()
 		if err != nil {
-			wbzlog.Logger.Error().Err(err).Msg("Failed to close history rows")
+			wbzlog.Logger.Error().Err(err).Msg("Failed to close item rows")
 		}
 	}()
 
-	var items []*item.Item
 	for rows.Next() {
 		var it item.Item
 		err := rows.Scan(
@@ -80,21 +168,71 @@ func (p *Postgres) GetItems() ([]*item.Item, error) {
 			&it.Name,
 			&it.Count,
 			&it.Price,
+			&it.Version,
 		)
 		if err != nil {
 			wbzlog.Logger.Error().Err(err).Msg("Failed to scan item row")
 			return nil, err
 		}
-		items = append(items, &it)
+		page.Items = append(page.Items, &it)
+	}
+
+	if len(page.Items) > opts.Limit {
+		page.Items = page.Items[:opts.Limit]
+		page.NextCursor = item.NewCursor(page.Items[opts.Limit-1], opts.SortBy, opts.Desc).Encode()
+	}
+
+	return page, nil
+}
+
+// itemListFilters дописывает условия фильтра к уже занятым параметрам args
+func itemListFilters(opts item.ListOptions, args []any) ([]string, []any) {
+	var conds []string
+	add := func(cond string, arg any) {
+		args = append(args, arg)
+		conds = append(conds, fmt.Sprintf(cond, len(args)))
+	}
+
+	if opts.Name != "" {
+		add(`name ILIKE $%d ESCAPE '\'`, "%"+likeEscaper.Replace(opts.Name)+"%")
+	}
+	if opts.MinCount != nil {
+		add("count >= $%d", *opts.MinCount)
+	}
+	if opts.MaxCount != nil {
+		add("count <= $%d", *opts.MaxCount)
+	}
+	if opts.MinPrice != nil {
+		add("price >= $%d", *opts.MinPrice)
+	}
+	if opts.MaxPrice != nil {
+		add("price <= $%d", *opts.MaxPrice)
+	}
+	if opts.ZeroStock != nil {
+		if *opts.ZeroStock {
+			conds = append(conds, "count = 0")
+		} else {
+			conds = append(conds, "count <> 0")
+		}
 	}
+	return conds, args
+}
+
+var likeEscaper = strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`)
 
-	return items, nil
+func whereClause(conds []string) string {
+	if len(conds) == 0 {
+		return ""
+	}
+	return " WHERE " + strings.Join(conds, " AND ")
 }
-func (p *Postgres) GetItem(uuid string) (*item.Item, error) {
-	ctx := context.Background()
+
+func (p *Postgres) GetItem(ctx context.Context, uuid string) (*item.Item, error) {
+	ctx, cancel := p.withTimeout(ctx)
+	defer cancel()
 
 	query := `
-		SELECT id, name, count, price
+		SELECT id, name, count, price, version
 		FROM items
 		WHERE id = $1
 	`
@@ -111,15 +249,67 @@ func (p *Postgres) GetItem(uuid string) (*item.Item, error) {
 		&it.Name,
 		&it.Count,
 		&it.Price,
+		&it.Version,
 	)
-	if err != nil && err != sql.ErrNoRows {
+	if err == sql.ErrNoRows {
+		return nil, item.ErrNotFound
+	}
+	if err != nil {
 		wbzlog.Logger.Error().Err(err).Msg("Failed to scan item row")
 		return nil, err
 	}
 	return &it, nil
 }
-func (p *Postgres) PutItem(item *item.Item, userID string, login string) error {
-	ctx := context.Background()
+
+// GetItemAsOf восстанавливает товар по последнему снимку в истории не позже asOf
+func (p *Postgres) GetItemAsOf(ctx context.Context, uuid string, asOf time.Time) (*item.Item, error) {
+	ctx, cancel := p.withTimeout(ctx)
+	defer cancel()
+	db := p.replicaReader(ctx)
+
+	query := `
+		SELECT new_data
+		FROM history
+		WHERE item_id = $1 AND location_id IS NULL AND changed_at <= $2
+		ORDER BY changed_at DESC, seq DESC
+		LIMIT 1
+	`
+
+	var snapshot []byte
+	row, err := db.queryRow(ctx, query, uuid, asOf)
+	if err != nil {
+		wbzlog.Logger.Error().Err(err).Msg("Failed to execute get item as of query")
+		return nil, err
+	}
+	err = row.Scan(&snapshot)
+	if err == sql.ErrNoRows {
+		return nil, item.ErrNotFound
+	}
+	if err != nil {
+		wbzlog.Logger.Error().Err(err).Msg("Failed to scan item snapshot")
+		return nil, err
+	}
+	// пустой снимок — к этому моменту товар уже удалён
+	if len(snapshot) == 0 {
+		return nil, item.ErrNotFound
+	}
+
+	var it item.Item
+	err = json.Unmarshal(snapshot, &it)
+	if err != nil {
+		wbzlog.Logger.Error().Err(err).Msg("Failed to unmarshal item snapshot")
+		return nil, err
+	}
+	if it.Version == 0 {
+		it.Version = 1
+	}
+	return &it, nil
+}
+
+func (p *Postgres) PutItem(ctx context.Context, it *item.Item, userID string, login string) error {
+	ctx, cancel := p.withTimeout(ctx)
+	defer cancel()
+	defer p.replicas.wrote(ctx)
 
 	tx, err := p.setHistoryConfig(ctx, userID, login)
 	if err != nil {
@@ -130,20 +320,39 @@ func (p *Postgres) PutItem(item *item.Item, userID string, login string) error {
 		_ = tx.Rollback()
 	}()
 
+	var oldCount, version int
+	err = tx.QueryRowContext(ctx, `SELECT count, version FROM items WHERE id = $1 FOR UPDATE`, it.ID).Scan(&oldCount, &version)
+	if err == sql.ErrNoRows {
+		return item.ErrNotFound
+	}
+	if err != nil {
+		wbzlog.Logger.Error().Err(err).Msg("Failed to lock item row")
+		return err
+	}
+	if version != it.Version {
+		return item.ErrVersionMismatch
+	}
+	if it.Count < oldCount {
+		err = checkLocationStock(ctx, tx, it.ID, it.Count)
+		if err != nil {
+			return err
+		}
+	}
+
 	query := `
 		UPDATE items
 		SET name = $2, count = $3, price = $4
 		WHERE id = $1
+		RETURNING version
 	`
 
 	err = retry.DoContext(ctx, retry.Strategy{Attempts: p.cfg.Attempts, Delay: p.cfg.Delay, Backoff: p.cfg.Backoffs}, func() error {
-		_, err := tx.ExecContext(ctx, query,
-			item.ID,
-			item.Name,
-			item.Count,
-			item.Price,
-		)
-		return err
+		return tx.QueryRowContext(ctx, query,
+			it.ID,
+			it.Name,
+			it.Count,
+			it.Price,
+		).Scan(&it.Version)
 	})
 
 	if err != nil {
@@ -151,6 +360,14 @@ func (p *Postgres) PutItem(item *item.Item, userID string, login string) error {
 		return err
 	}
 
+	if delta := it.Count - oldCount; delta != 0 {
+		m := &movement.Movement{ID: uuid.New(), ItemID: it.ID, Type: movement.Adjustment, Quantity: delta, Reason: "item update"}
+		err = insertMovement(ctx, tx, m)
+		if err != nil {
+			return err
+		}
+	}
+
 	err = tx.Commit()
 	if err != nil {
 		wbzlog.Logger.Error().Err(err).Msg("Failed to commit transaction")
@@ -159,8 +376,111 @@ func (p *Postgres) PutItem(item *item.Item, userID string, login string) error {
 
 	return nil
 }
-func (p *Postgres) DeleteItem(uuid string, userID string, login string) error {
-	ctx := context.Background()
+
+// RevertItem приводит товар к состоянию it, восстановленному из записи истории sourceID.
+// Удалённый товар создаётся заново с тем же ID. Если expectedVersion > 0, текущая версия должна с ней совпадать.
+// Разница в count проводится через журнал движения, в истории изменение пишется как reverted со ссылкой на sourceID.
+func (p *Postgres) RevertItem(ctx context.Context, it *item.Item, expectedVersion int, sourceID string, userID string, login string) error {
+	ctx, cancel := p.withTimeout(ctx)
+	defer cancel()
+	defer p.replicas.wrote(ctx)
+
+	tx, err := p.setHistoryConfig(ctx, userID, login)
+	if err != nil {
+		wbzlog.Logger.Error().Err(err).Msg("Failed to set history config")
+		return err
+	}
+	defer func() {
+		_ = tx.Rollback()
+	}()
+
+	err = setHistoryAction(ctx, tx, history.ActionReverted)
+	if err != nil {
+		return err
+	}
+	_, err = tx.ExecContext(ctx, `SELECT set_config('app.source_history_id', $1, true)`, sourceID)
+	if err != nil {
+		wbzlog.Logger.Error().Err(err).Msg("Failed to execute set source history query")
+		return err
+	}
+
+	var oldCount, version int
+	err = tx.QueryRowContext(ctx, `SELECT count, version FROM items WHERE id = $1 FOR UPDATE`, it.ID).Scan(&oldCount, &version)
+	switch {
+	case err == sql.ErrNoRows:
+		// версия продолжает ту, что была до удаления, чтобы старые ETag не совпали с новой строкой
+		err = tx.QueryRowContext(ctx, `
+			SELECT COALESCE(MAX(GREATEST((old_data->>'version')::int, (new_data->>'version')::int)), 0) + 1
+			FROM history
+			WHERE item_id = $1 AND location_id IS NULL
+		`, it.ID).Scan(&it.Version)
+		if err != nil {
+			wbzlog.Logger.Error().Err(err).Msg("Failed to get last item version")
+			return err
+		}
+		_, err = tx.ExecContext(ctx, `
+			INSERT INTO items (id, name, count, price, version)
+			VALUES ($1, $2, $3, $4, $5)
+		`, it.ID, it.Name, it.Count, it.Price, it.Version)
+		if err != nil {
+			wbzlog.Logger.Error().Err(err).Msg("Failed to execute recreate item query")
+			return err
+		}
+		// журнал удалённого товара сохранился: новую разницу считаем от его остатка
+		err = tx.QueryRowContext(ctx, `
+			SELECT COALESCE(SUM(quantity), 0)
+			FROM stock_movements
+			WHERE item_id = $1 AND type <> 'transfer'
+		`, it.ID).Scan(&oldCount)
+		if err != nil {
+			wbzlog.Logger.Error().Err(err).Msg("Failed to get item ledger balance")
+			return err
+		}
+	case err != nil:
+		wbzlog.Logger.Error().Err(err).Msg("Failed to lock item row")
+		return err
+	default:
+		if expectedVersion > 0 && version != expectedVersion {
+			return item.ErrVersionMismatch
+		}
+		err = checkLocationStock(ctx, tx, it.ID, it.Count)
+		if err != nil {
+			return err
+		}
+		err = tx.QueryRowContext(ctx, `
+			UPDATE items
+			SET name = $2, count = $3, price = $4
+			WHERE id = $1
+			RETURNING version
+		`, it.ID, it.Name, it.Count, it.Price).Scan(&it.Version)
+		if err != nil {
+			wbzlog.Logger.Error().Err(err).Msg("Failed to execute revert item query")
+			return err
+		}
+	}
+
+	if delta := it.Count - oldCount; delta != 0 {
+		m := &movement.Movement{ID: uuid.New(), ItemID: it.ID, Type: movement.Adjustment, Quantity: delta, Reason: "item reverted", Reference: sourceID}
+		err = insertMovement(ctx, tx, m)
+		if err != nil {
+			return err
+		}
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		wbzlog.Logger.Error().Err(err).Msg("Failed to commit transaction")
+		return err
+	}
+
+	return nil
+}
+
+// DeleteItem удаляет товар, только если его версия совпадает с version
+func (p *Postgres) DeleteItem(ctx context.Context, uuid string, version int, userID string, login string) error {
+	ctx, cancel := p.withTimeout(ctx)
+	defer cancel()
+	defer p.replicas.wrote(ctx)
 
 	tx, err := p.setHistoryConfig(ctx, userID, login)
 	if err != nil {
@@ -173,12 +493,15 @@ func (p *Postgres) DeleteItem(uuid string, userID string, login string) error {
 
 	query := `
 		DELETE FROM items
-		WHERE id = $1
+		WHERE id = $1 AND version = $2
 	`
 
+	var res sql.Result
 	err = retry.DoContext(ctx, retry.Strategy{Attempts: p.cfg.Attempts, Delay: p.cfg.Delay, Backoff: p.cfg.Backoffs}, func() error {
-		_, err := tx.ExecContext(ctx, query,
+		var err error
+		res, err = tx.ExecContext(ctx, query,
 			uuid,
+			version,
 		)
 		return err
 	})
@@ -186,6 +509,156 @@ func (p *Postgres) DeleteItem(uuid string, userID string, login string) error {
 		wbzlog.Logger.Error().Err(err).Msg("Failed to execute delete item query")
 		return err
 	}
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if affected == 0 {
+		var exists bool
+		err = tx.QueryRowContext(ctx, `SELECT EXISTS(SELECT 1 FROM items WHERE id = $1)`, uuid).Scan(&exists)
+		if err != nil {
+			wbzlog.Logger.Error().Err(err).Msg("Failed to check item existence")
+			return err
+		}
+		if !exists {
+			return item.ErrNotFound
+		}
+		return item.ErrVersionMismatch
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		wbzlog.Logger.Error().Err(err).Msg("Failed to commit transaction")
+		return err
+	}
+
+	return nil
+}
+
+// GetItemStocks возвращает разбивку остатков товара по ячейкам складов
+func (p *Postgres) GetItemStocks(ctx context.Context, uuid string) ([]item.LocationStock, error) {
+	query := `
+		SELECT s.location_id, l.code, z.id, z.name, w.id, w.name, s.quantity
+		FROM item_stocks s
+		JOIN locations l ON l.id = s.location_id
+		JOIN zones z ON z.id = l.zone_id
+		JOIN warehouses w ON w.id = z.warehouse_id
+		WHERE s.item_id = $1
+		ORDER BY w.name, z.name, l.code
+	`
+	return p.queryItemStocks(ctx, p.master(), query, uuid)
+}
+
+// GetItemStocksAsOf восстанавливает остатки по ячейкам на момент asOf из истории item_stocks.
+// Ячейки, удалённые позже, в разбивку не попадают.
+func (p *Postgres) GetItemStocksAsOf(ctx context.Context, uuid string, asOf time.Time) ([]item.LocationStock, error) {
+	query := `
+		SELECT s.location_id, l.code, z.id, z.name, w.id, w.name, s.quantity
+		FROM (
+			SELECT location_id, (new_data->>'count')::int AS quantity
+			FROM (
+				SELECT DISTINCT ON (location_id) location_id, new_data
+				FROM history
+				WHERE item_id = $1 AND location_id IS NOT NULL AND changed_at <= $2
+				ORDER BY location_id, changed_at DESC, seq DESC
+			) last
+			WHERE new_data IS NOT NULL
+		) s
+		JOIN locations l ON l.id = s.location_id
+		JOIN zones z ON z.id = l.zone_id
+		JOIN warehouses w ON w.id = z.warehouse_id
+		WHERE s.quantity <> 0
+		ORDER BY w.name, z.name, l.code
+	`
+	return p.queryItemStocks(ctx, p.replicaReader(ctx), query, uuid, asOf)
+}
+
+func (p *Postgres) queryItemStocks(ctx context.Context, db reader, query string, args ...any) ([]item.LocationStock, error) {
+	ctx, cancel := p.withTimeout(ctx)
+	defer cancel()
+
+	rows, err := db.query(ctx, query, args...)
+	if err != nil {
+		wbzlog.Logger.Error().Err(err).Msg("Failed to execute get item stocks query")
+		return nil, err
+	}
+	defer func() {
+		err = rows.Close()
+		if err != nil {
+			wbzlog.Logger.Error().Err(err).Msg("Failed to close item stock rows")
+		}
+	}()
+
+	var stocks []item.LocationStock
+	for rows.Next() {
+		var st item.LocationStock
+		err := rows.Scan(
+			&st.LocationID,
+			&st.LocationCode,
+			&st.ZoneID,
+			&st.ZoneName,
+			&st.WarehouseID,
+			&st.WarehouseName,
+			&st.Quantity,
+		)
+		if err != nil {
+			wbzlog.Logger.Error().Err(err).Msg("Failed to scan item stock row")
+			return nil, err
+		}
+		stocks = append(stocks, st)
+	}
+
+	return stocks, nil
+}
+
+// SetItemStock выставляет остаток товара в ячейке; разница проводится через журнал как корректировка
+func (p *Postgres) SetItemStock(ctx context.Context, itemID string, locationID string, quantity int, userID string, login string) error {
+	ctx, cancel := p.withTimeout(ctx)
+	defer cancel()
+	defer p.replicas.wrote(ctx)
+
+	tx, err := p.setHistoryConfig(ctx, userID, login)
+	if err != nil {
+		wbzlog.Logger.Error().Err(err).Msg("Failed to set history config")
+		return err
+	}
+	defer func() {
+		_ = tx.Rollback()
+	}()
+
+	itemUUID, err := uuid.Parse(itemID)
+	if err != nil {
+		return err
+	}
+	locationUUID, err := uuid.Parse(locationID)
+	if err != nil {
+		return err
+	}
+
+	var count int
+	err = tx.QueryRowContext(ctx, `SELECT count FROM items WHERE id = $1 FOR UPDATE`, itemUUID).Scan(&count)
+	if err == sql.ErrNoRows {
+		return item.ErrNotFound
+	}
+	if err != nil {
+		wbzlog.Logger.Error().Err(err).Msg("Failed to lock item row")
+		return err
+	}
+
+	var current int
+	err = tx.QueryRowContext(ctx, `SELECT quantity FROM item_stocks WHERE item_id = $1 AND location_id = $2`, itemUUID, locationUUID).Scan(&current)
+	if err != nil && err != sql.ErrNoRows {
+		wbzlog.Logger.Error().Err(err).Msg("Failed to get current item stock")
+		return err
+	}
+
+	if delta := quantity - current; delta != 0 {
+		m := &movement.Movement{ID: uuid.New(), ItemID: itemUUID, Type: movement.Adjustment, Quantity: delta, LocationID: &locationUUID, Reason: "stock count"}
+		_, err = p.applyMovement(ctx, tx, m, false)
+		if err != nil {
+			return err
+		}
+	}
 
 	err = tx.Commit()
 	if err != nil {
@@ -0,0 +1,149 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"warehousecontrol/internal/domain/user"
+
+	"github.com/wb-go/wbf/retry"
+	wbzlog "github.com/wb-go/wbf/zlog"
+)
+
+// LoginLockedUntil возвращает окончание действующей блокировки логина или IP; nil, если блокировки нет
+func (p *Postgres) LoginLockedUntil(ctx context.Context, login, ip string) (*time.Time, error) {
+	ctx, cancel := p.withTimeout(ctx)
+	defer cancel()
+
+	query := `
+		SELECT max(locked_until)
+		FROM login_failures
+		WHERE ((scope = $1 AND subject = $2) OR (scope = $3 AND subject = $4))
+		  AND locked_until > now()
+	`
+	row, err := p.db.QueryRowWithRetry(ctx, retry.Strategy{Attempts: p.cfg.Attempts, Delay: p.cfg.Delay, Backoff: p.cfg.Backoffs}, query, user.ScopeLogin, login, user.ScopeIP, ip)
+	if err != nil {
+		wbzlog.Logger.Error().Err(err).Msg("Failed to execute get login lock query")
+		return nil, err
+	}
+	var until *time.Time
+	err = row.Scan(&until)
+	if err != nil {
+		wbzlog.Logger.Error().Err(err).Msg("Failed to scan login lock")
+		return nil, err
+	}
+	return until, nil
+}
+
+// RegisterLoginFailure учитывает неудачный вход и возвращает true, если счётчик достиг порога.
+// Блокировка существующего логина пишется в аудит пользователя от имени системы.
+func (p *Postgres) RegisterLoginFailure(ctx context.Context, scope, subject string, policy user.LockoutPolicy) (bool, error) {
+	ctx, cancel := p.withTimeout(ctx)
+	defer cancel()
+
+	tx, err := p.db.Master.BeginTx(ctx, nil)
+	if err != nil {
+		wbzlog.Logger.Error().Err(err).Msg("cant start transaction in register login failure")
+		return false, err
+	}
+	defer func() {
+		_ = tx.Rollback()
+	}()
+
+	now := time.Now()
+	// устаревшие счётчики той же области удаляются, чтобы таблица не росла от перебора
+	_, err = tx.ExecContext(ctx, `
+		DELETE FROM login_failures
+		WHERE scope = $1 AND window_start < $2 AND (locked_until IS NULL OR locked_until < $3)
+	`, scope, now.Add(-policy.Window), now)
+	if err != nil {
+		wbzlog.Logger.Error().Err(err).Msg("Failed to delete stale login failures")
+		return false, err
+	}
+	_, err = tx.ExecContext(ctx, `
+		INSERT INTO login_failures (scope, subject) VALUES ($1, $2)
+		ON CONFLICT (scope, subject) DO NOTHING
+	`, scope, subject)
+	if err != nil {
+		wbzlog.Logger.Error().Err(err).Msg("Failed to insert login failures")
+		return false, err
+	}
+
+	f := user.LoginFailures{Scope: scope, Subject: subject}
+	err = tx.QueryRowContext(ctx, `
+		SELECT failures, window_start, locked_until
+		FROM login_failures
+		WHERE scope = $1 AND subject = $2
+		FOR UPDATE
+	`, scope, subject).Scan(&f.Failures, &f.WindowStart, &f.LockedUntil)
+	if err != nil {
+		wbzlog.Logger.Error().Err(err).Msg("Failed to scan login failures")
+		return false, err
+	}
+
+	locked := f.Fail(now, policy)
+	_, err = tx.ExecContext(ctx, `
+		UPDATE login_failures SET failures = $3, window_start = $4, locked_until = $5
+		WHERE scope = $1 AND subject = $2
+	`, scope, subject, f.Failures, f.WindowStart, f.LockedUntil)
+	if err != nil {
+		wbzlog.Logger.Error().Err(err).Msg("Failed to update login failures")
+		return false, err
+	}
+	if locked && scope == user.ScopeLogin {
+		_, err = tx.ExecContext(ctx, `
+			INSERT INTO user_audit (user_id, user_login, action, new_value, actor_login)
+			SELECT id, login, $2, $3, $4 FROM users WHERE login = $1
+		`, subject, user.AuditLocked, f.LockedUntil.UTC().Format(time.RFC3339), user.SystemActor)
+		if err != nil {
+			wbzlog.Logger.Error().Err(err).Msg("Failed to insert lock audit record")
+			return false, err
+		}
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		wbzlog.Logger.Error().Err(err).Msg("Failed to commit transaction")
+		return false, err
+	}
+	return locked, nil
+}
+
+// ResetLoginFailures сбрасывает счётчик логина после успешного входа. Счётчик IP
+// не сбрасывается: иначе вход в свою учётную запись позволял бы перебирать чужие.
+func (p *Postgres) ResetLoginFailures(ctx context.Context, login string) error {
+	ctx, cancel := p.withTimeout(ctx)
+	defer cancel()
+
+	_, err := p.db.ExecWithRetry(ctx, retry.Strategy{Attempts: p.cfg.Attempts, Delay: p.cfg.Delay, Backoff: p.cfg.Backoffs},
+		`DELETE FROM login_failures WHERE scope = $1 AND subject = $2`, user.ScopeLogin, login)
+	if err != nil {
+		wbzlog.Logger.Error().Err(err).Msg("Failed to execute reset login failures query")
+		return err
+	}
+	return nil
+}
+
+// UnlockUser снимает блокировку входа пользователя досрочно. Аудит пишется,
+// только если блокировка действовала.
+func (p *Postgres) UnlockUser(ctx context.Context, id, actorID, actorLogin string) error {
+	return p.modifyUser(ctx, id, actorID, actorLogin, func(ctx context.Context, tx *sql.Tx, u *user.User) (*user.AuditEntry, error) {
+		var until *time.Time
+		err := tx.QueryRowContext(ctx, `
+			DELETE FROM login_failures WHERE scope = $1 AND subject = $2
+			RETURNING locked_until
+		`, user.ScopeLogin, u.Login).Scan(&until)
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		if err != nil {
+			wbzlog.Logger.Error().Err(err).Msg("Failed to execute unlock user query")
+			return nil, err
+		}
+		if until == nil || !until.After(time.Now()) {
+			return nil, nil
+		}
+		return &user.AuditEntry{Action: user.AuditUnlocked, OldValue: strPtr(until.UTC().Format(time.RFC3339))}, nil
+	})
+}
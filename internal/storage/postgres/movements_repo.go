@@ -0,0 +1,264 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+
+	"warehousecontrol/internal/domain/history"
+	"warehousecontrol/internal/domain/item"
+	"warehousecontrol/internal/domain/movement"
+	"warehousecontrol/internal/domain/warehouse"
+
+	"github.com/google/uuid"
+	wbzlog "github.com/wb-go/wbf/zlog"
+)
+
+// ApplyMovement записывает движение в журнал и применяет его к остаткам в одной транзакции с историей.
+// allowNegative снимает проверку на уход общего остатка в минус. Возвращает новый общий остаток.
+func (p *Postgres) ApplyMovement(ctx context.Context, m *movement.Movement, allowNegative bool, userID string, login string) (int, error) {
+	ctx, cancel := p.withTimeout(ctx)
+	defer cancel()
+	defer p.replicas.wrote(ctx)
+
+	tx, err := p.setHistoryConfig(ctx, userID, login)
+	if err != nil {
+		wbzlog.Logger.Error().Err(err).Msg("Failed to set history config")
+		return 0, err
+	}
+	defer func() {
+		_ = tx.Rollback()
+	}()
+
+	count, err := p.applyMovement(ctx, tx, m, allowNegative)
+	if err != nil {
+		return 0, err
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		wbzlog.Logger.Error().Err(err).Msg("Failed to commit transaction")
+		return 0, err
+	}
+
+	return count, nil
+}
+
+func (p *Postgres) GetItemMovements(ctx context.Context, itemID string) ([]*movement.Movement, error) {
+	ctx, cancel := p.withTimeout(ctx)
+	defer cancel()
+	db := p.replicaReader(ctx)
+
+	query := `
+		SELECT id, item_id, type, quantity, location_id, to_location_id,
+		       reason, reference, created_by, created_by_login, created_at
+		FROM stock_movements
+		WHERE item_id = $1
+		ORDER BY created_at, id
+	`
+
+	rows, err := db.query(ctx, query, itemID)
+	if err != nil {
+		wbzlog.Logger.Error().Err(err).Msg("Failed to execute get item movements query")
+		return nil, err
+	}
+	defer func() {
+		err = rows.Close()
+		if err != nil {
+			wbzlog.Logger.Error().Err(err).Msg("Failed to close movement rows")
+		}
+	}()
+
+	movements := []*movement.Movement{}
+	for rows.Next() {
+		var m movement.Movement
+		err := rows.Scan(
+			&m.ID,
+			&m.ItemID,
+			&m.Type,
+			&m.Quantity,
+			&m.LocationID,
+			&m.ToLocationID,
+			&m.Reason,
+			&m.Reference,
+			&m.CreatedBy,
+			&m.CreatedByLogin,
+			&m.CreatedAt,
+		)
+		if err != nil {
+			wbzlog.Logger.Error().Err(err).Msg("Failed to scan movement row")
+			return nil, err
+		}
+		movements = append(movements, &m)
+	}
+
+	return movements, nil
+}
+
+// applyMovement меняет общий остаток товара, остатки в ячейках и добавляет запись в журнал.
+// Вызывается внутри транзакции, открытой setHistoryConfig. Корректировки пишутся в историю с действием adjusted.
+func (p *Postgres) applyMovement(ctx context.Context, tx *sql.Tx, m *movement.Movement, allowNegative bool) (int, error) {
+	if m.Type == movement.Adjustment {
+		err := setHistoryAction(ctx, tx, history.ActionAdjusted)
+		if err != nil {
+			return 0, err
+		}
+	}
+
+	var count int
+	var err error
+	if delta := m.CountDelta(); delta != 0 {
+		err = tx.QueryRowContext(ctx, `
+			UPDATE items
+			SET count = count + $2
+			WHERE id = $1 AND (count + $2 >= 0 OR $3)
+			RETURNING count
+		`, m.ItemID, delta, allowNegative).Scan(&count)
+	} else {
+		err = tx.QueryRowContext(ctx, `SELECT count FROM items WHERE id = $1 FOR UPDATE`, m.ItemID).Scan(&count)
+	}
+	if err == sql.ErrNoRows {
+		return 0, p.missingItemOrInsufficient(ctx, tx, m.ItemID)
+	}
+	if err != nil {
+		wbzlog.Logger.Error().Err(err).Msg("Failed to update item count")
+		return 0, err
+	}
+
+	switch m.Type {
+	case movement.Transfer:
+		err = changeLocationStock(ctx, tx, m.ItemID, *m.LocationID, -m.Quantity)
+		if err == nil {
+			err = changeLocationStock(ctx, tx, m.ItemID, *m.ToLocationID, m.Quantity)
+		}
+	default:
+		if m.LocationID != nil {
+			err = changeLocationStock(ctx, tx, m.ItemID, *m.LocationID, m.Quantity)
+		}
+	}
+	if err != nil {
+		return 0, err
+	}
+
+	// списание без ячейки не уменьшает остатки в ячейках, поэтому их сумма не должна превысить общий остаток
+	if m.LocationID == nil && m.CountDelta() < 0 {
+		err = checkLocationStock(ctx, tx, m.ItemID, count)
+		if err != nil {
+			return 0, err
+		}
+	}
+
+	return count, insertMovement(ctx, tx, m)
+}
+
+// setHistoryAction переопределяет действие, которое триггеры истории запишут до конца транзакции
+func setHistoryAction(ctx context.Context, tx *sql.Tx, action string) error {
+	_, err := tx.ExecContext(ctx, `SELECT set_config('app.current_action', $1, true)`, action)
+	if err != nil {
+		wbzlog.Logger.Error().Err(err).Msg("Failed to execute set current action query")
+	}
+	return err
+}
+
+func (p *Postgres) missingItemOrInsufficient(ctx context.Context, tx *sql.Tx, itemID uuid.UUID) error {
+	var exists bool
+	err := tx.QueryRowContext(ctx, `SELECT EXISTS(SELECT 1 FROM items WHERE id = $1)`, itemID).Scan(&exists)
+	if err != nil {
+		wbzlog.Logger.Error().Err(err).Msg("Failed to check item existence")
+		return err
+	}
+	if !exists {
+		return item.ErrNotFound
+	}
+	return item.ErrInsufficientStock
+}
+
+// checkLocationStock проверяет, что общий остаток count покрывает остатки товара в ячейках.
+// Вызывается внутри транзакции, где строка товара уже заблокирована.
+func checkLocationStock(ctx context.Context, tx *sql.Tx, itemID uuid.UUID, count int) error {
+	var inLocations int
+	err := tx.QueryRowContext(ctx, `SELECT COALESCE(SUM(quantity), 0) FROM item_stocks WHERE item_id = $1`, itemID).Scan(&inLocations)
+	if err != nil {
+		wbzlog.Logger.Error().Err(err).Msg("Failed to sum location stock")
+		return err
+	}
+	return item.CheckLocationStock(count, inLocations)
+}
+
+func missingLocationOrInsufficient(ctx context.Context, tx *sql.Tx, locationID uuid.UUID) error {
+	var exists bool
+	err := tx.QueryRowContext(ctx, `SELECT EXISTS(SELECT 1 FROM locations WHERE id = $1)`, locationID).Scan(&exists)
+	if err != nil {
+		wbzlog.Logger.Error().Err(err).Msg("Failed to check location existence")
+		return err
+	}
+	if !exists {
+		return warehouse.ErrLocationNotFound
+	}
+	return item.ErrInsufficientLocationStock
+}
+
+func changeLocationStock(ctx context.Context, tx *sql.Tx, itemID, locationID uuid.UUID, delta int) error {
+	if delta > 0 {
+		_, err := tx.ExecContext(ctx, `
+			INSERT INTO item_stocks (item_id, location_id, quantity)
+			VALUES ($1, $2, $3)
+			ON CONFLICT (item_id, location_id) DO UPDATE SET quantity = item_stocks.quantity + EXCLUDED.quantity
+		`, itemID, locationID, delta)
+		if isPgError(err, pgForeignKeyViolation) {
+			return warehouse.ErrLocationNotFound
+		}
+		if err != nil {
+			wbzlog.Logger.Error().Err(err).Msg("Failed to increase location stock")
+		}
+		return err
+	}
+
+	res, err := tx.ExecContext(ctx, `
+		UPDATE item_stocks
+		SET quantity = quantity + $3
+		WHERE item_id = $1 AND location_id = $2 AND quantity + $3 >= 0
+	`, itemID, locationID, delta)
+	if err != nil {
+		wbzlog.Logger.Error().Err(err).Msg("Failed to decrease location stock")
+		return err
+	}
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if affected == 0 {
+		return missingLocationOrInsufficient(ctx, tx, locationID)
+	}
+
+	_, err = tx.ExecContext(ctx, `DELETE FROM item_stocks WHERE item_id = $1 AND location_id = $2 AND quantity = 0`, itemID, locationID)
+	if err != nil {
+		wbzlog.Logger.Error().Err(err).Msg("Failed to remove empty location stock")
+	}
+	return err
+}
+
+// insertMovement пишет запись журнала; автор берётся из настроек транзакции, как и в триггерах истории
+func insertMovement(ctx context.Context, tx *sql.Tx, m *movement.Movement) error {
+	query := `
+		INSERT INTO stock_movements (id, item_id, type, quantity, location_id, to_location_id,
+		                             reason, reference, created_by, created_by_login)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, app_current_user(), app_current_user_login())
+		RETURNING created_by, created_by_login, created_at
+	`
+
+	err := tx.QueryRowContext(ctx, query,
+		m.ID,
+		m.ItemID,
+		m.Type,
+		m.Quantity,
+		m.LocationID,
+		m.ToLocationID,
+		m.Reason,
+		m.Reference,
+	).Scan(&m.CreatedBy, &m.CreatedByLogin, &m.CreatedAt)
+	if err != nil {
+		wbzlog.Logger.Error().Err(err).Msg("Failed to insert stock movement")
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,116 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"warehousecontrol/internal/domain/user"
+
+	wbzlog "github.com/wb-go/wbf/zlog"
+)
+
+// ChangeUserPassword меняет пароль по запросу самого пользователя и завершает все его сессии
+func (p *Postgres) ChangeUserPassword(ctx context.Context, id string, password []byte, actorID, actorLogin string) error {
+	return p.modifyUser(ctx, id, actorID, actorLogin, func(ctx context.Context, tx *sql.Tx, u *user.User) (*user.AuditEntry, error) {
+		if err := setPassword(ctx, tx, u, password); err != nil {
+			return nil, err
+		}
+		return &user.AuditEntry{Action: user.AuditPasswordChanged}, nil
+	})
+}
+
+// SavePasswordResetToken сохраняет новый токен сброса; прежние неиспользованные токены пользователя удаляются
+func (p *Postgres) SavePasswordResetToken(ctx context.Context, t *user.PasswordResetToken, actorID, actorLogin string) error {
+	return p.modifyUser(ctx, t.UserID.String(), actorID, actorLogin, func(ctx context.Context, tx *sql.Tx, u *user.User) (*user.AuditEntry, error) {
+		_, err := tx.ExecContext(ctx, `DELETE FROM password_reset_tokens WHERE user_id = $1 AND used_at IS NULL`, u.Id)
+		if err != nil {
+			wbzlog.Logger.Error().Err(err).Msg("Failed to delete previous password reset tokens")
+			return nil, err
+		}
+		_, err = tx.ExecContext(ctx, `
+			INSERT INTO password_reset_tokens (id, user_id, token_hash, created_at, expires_at)
+			VALUES ($1, $2, $3, $4, $5)
+		`, t.ID, t.UserID, t.TokenHash, t.CreatedAt, t.ExpiresAt)
+		if err != nil {
+			wbzlog.Logger.Error().Err(err).Msg("Failed to execute insert password reset token query")
+			return nil, err
+		}
+		return &user.AuditEntry{Action: user.AuditResetIssued, NewValue: strPtr(t.ExpiresAt.UTC().Format(time.RFC3339))}, nil
+	})
+}
+
+// RedeemPasswordResetToken гасит токен сброса и в той же транзакции задаёт новый пароль.
+// Неизвестный, истёкший и использованный токены неотличимы.
+func (p *Postgres) RedeemPasswordResetToken(ctx context.Context, tokenHash []byte, password []byte) (*user.User, error) {
+	ctx, cancel := p.withTimeout(ctx)
+	defer cancel()
+
+	tx, err := p.db.Master.BeginTx(ctx, nil)
+	if err != nil {
+		wbzlog.Logger.Error().Err(err).Msg("cant start transaction in redeem password reset token")
+		return nil, err
+	}
+	defer func() {
+		_ = tx.Rollback()
+	}()
+
+	var userID string
+	err = tx.QueryRowContext(ctx, `
+		UPDATE password_reset_tokens SET used_at = now()
+		WHERE token_hash = $1 AND used_at IS NULL AND expires_at > now()
+		RETURNING user_id
+	`, tokenHash).Scan(&userID)
+	if err == sql.ErrNoRows {
+		return nil, user.ErrResetTokenInvalid
+	}
+	if err != nil {
+		wbzlog.Logger.Error().Err(err).Msg("Failed to redeem password reset token")
+		return nil, err
+	}
+	u, err := scanUser(tx.QueryRowContext(ctx, `SELECT `+userColumns+` FROM users WHERE id = $1 FOR UPDATE`, userID))
+	if err != nil {
+		wbzlog.Logger.Error().Err(err).Msg("Failed to scan user row")
+		return nil, err
+	}
+	if err := setPassword(ctx, tx, u, password); err != nil {
+		return nil, err
+	}
+	_, err = tx.ExecContext(ctx, `
+		INSERT INTO user_audit (user_id, user_login, action, actor_id, actor_login)
+		VALUES ($1, $2, $3, $1, $2)
+	`, u.Id, u.Login, user.AuditPasswordChanged)
+	if err != nil {
+		wbzlog.Logger.Error().Err(err).Msg("Failed to insert user audit record")
+		return nil, err
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		wbzlog.Logger.Error().Err(err).Msg("Failed to commit transaction")
+		return nil, err
+	}
+	return u, nil
+}
+
+// setPassword заменяет хэш пароля и завершает все сессии пользователя, чтобы выданные
+// по старому паролю токены перестали действовать. Блокировка входа по логину снимается.
+func setPassword(ctx context.Context, tx *sql.Tx, u *user.User, password []byte) error {
+	_, err := tx.ExecContext(ctx, `UPDATE users SET password = $2 WHERE id = $1`, u.Id, password)
+	if err != nil {
+		wbzlog.Logger.Error().Err(err).Msg("Failed to execute update user password query")
+		return err
+	}
+	_, err = tx.ExecContext(ctx, revokeUserSessionsQuery, u.Id)
+	if err != nil {
+		wbzlog.Logger.Error().Err(err).Msg("Failed to revoke sessions after password change")
+		return err
+	}
+	_, err = tx.ExecContext(ctx, `DELETE FROM login_failures WHERE scope = $1 AND subject = $2`, user.ScopeLogin, u.Login)
+	if err != nil {
+		wbzlog.Logger.Error().Err(err).Msg("Failed to reset login failures after password change")
+		return err
+	}
+	u.Password = password
+	return nil
+}
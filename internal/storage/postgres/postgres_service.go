@@ -3,8 +3,13 @@ package postgres
 import (
 	"warehousecontrol/internal/config"
 
+	"context"
+	"database/sql"
+	"errors"
 	"fmt"
+	"time"
 
+	"github.com/lib/pq"
 	wbdb "github.com/wb-go/wbf/dbpg"
 	wbzlog "github.com/wb-go/wbf/zlog"
 )
@@ -12,10 +17,14 @@ import (
 type Postgres struct {
 	db  *wbdb.DB
 	cfg *config.RetrysConfig
+	// timeout — предел на все запросы одного вызова репозитория, включая повторы
+	timeout  time.Duration
+	replicas *replicaSet
 }
 
-func NewPostgres(cfg *config.AppConfig) (*Postgres, error) {
-	masterDSN := fmt.Sprintf(
+// MasterDSN — строка подключения к мастеру из db_config.postgres
+func MasterDSN(cfg *config.AppConfig) string {
+	return fmt.Sprintf(
 		"host=%s port=%d user=%s password=%s dbname=%s sslmode=disable",
 		cfg.DBConfig.Master.Host,
 		cfg.DBConfig.Master.Port,
@@ -23,8 +32,23 @@ func NewPostgres(cfg *config.AppConfig) (*Postgres, error) {
 		cfg.DBConfig.Master.Password,
 		cfg.DBConfig.Master.DBName,
 	)
+}
 
-	slaveDSNs := make([]string, 0, len(cfg.DBConfig.Slaves))
+func NewPostgres(cfg *config.AppConfig) (*Postgres, error) {
+	masterDSN := MasterDSN(cfg)
+	var opts wbdb.Options
+	opts.ConnMaxLifetime = cfg.DBConfig.ConnMaxLifetime
+	opts.MaxIdleConns = cfg.DBConfig.MaxIdleConns
+	opts.MaxOpenConns = cfg.DBConfig.MaxOpenConns
+	// реплики не передаются в dbpg: он отправлял бы на них любое чтение, включая проверки
+	// перед записью. Какие запросы читают с реплик, решает replicaSet.
+	db, err := wbdb.New(masterDSN, nil, &opts)
+	if err != nil {
+		wbzlog.Logger.Debug().Msg("Failed to connect to Postgres")
+		return nil, err
+	}
+
+	replicas := make([]*replica, 0, len(cfg.DBConfig.Slaves))
 	for _, slave := range cfg.DBConfig.Slaves {
 		dsn := fmt.Sprintf(
 			"host=%s port=%d user=%s password=%s dbname=%s sslmode=disable",
@@ -34,19 +58,41 @@ func NewPostgres(cfg *config.AppConfig) (*Postgres, error) {
 			slave.Password,
 			slave.DBName,
 		)
-		slaveDSNs = append(slaveDSNs, dsn)
+		slaveDB, err := sql.Open("postgres", dsn)
+		if err != nil {
+			wbzlog.Logger.Debug().Msg("Failed to connect to Postgres slave")
+			return nil, err
+		}
+		if opts.MaxOpenConns > 0 {
+			slaveDB.SetMaxOpenConns(opts.MaxOpenConns)
+		}
+		if opts.MaxIdleConns > 0 {
+			slaveDB.SetMaxIdleConns(opts.MaxIdleConns)
+		}
+		if opts.ConnMaxLifetime > 0 {
+			slaveDB.SetConnMaxLifetime(opts.ConnMaxLifetime)
+		}
+		replicas = append(replicas, &replica{name: fmt.Sprintf("%s:%d", slave.Host, slave.Port), db: slaveDB})
 	}
-	var opts wbdb.Options
-	opts.ConnMaxLifetime = cfg.DBConfig.ConnMaxLifetime
-	opts.MaxIdleConns = cfg.DBConfig.MaxIdleConns
-	opts.MaxOpenConns = cfg.DBConfig.MaxOpenConns
-	db, err := wbdb.New(masterDSN, slaveDSNs, &opts)
-	if err != nil {
-		wbzlog.Logger.Debug().Msg("Failed to connect to Postgres")
-		return nil, err
+	rs := newReplicaSet(cfg.DBConfig.Replicas, replicas)
+	rs.currentLSN = func(ctx context.Context) (string, error) {
+		var lsn string
+		err := db.Master.QueryRowContext(ctx, currentLSNQuery).Scan(&lsn)
+		return lsn, err
+	}
+	rs.start()
+
+	wbzlog.Logger.Info().Int("replicas", len(replicas)).Msg("Connected to Postgres")
+	return &Postgres{db: db, cfg: &cfg.RetrysConfig, timeout: cfg.DBConfig.QueryTimeout, replicas: rs}, nil
+}
+
+// withTimeout ограничивает контекст запроса к базе сроком db_config.query_timeout. Запрос
+// прерывается и раньше, если отменён контекст HTTP запроса (например, клиент отключился).
+func (p *Postgres) withTimeout(ctx context.Context) (context.Context, context.CancelFunc) {
+	if p.timeout <= 0 {
+		return context.WithCancel(ctx)
 	}
-	wbzlog.Logger.Info().Msg("Connected to Postgres")
-	return &Postgres{db: db, cfg: &cfg.RetrysConfig}, nil
+	return context.WithTimeout(ctx, p.timeout)
 }
 
 func (p *Postgres) Close() error {
@@ -55,14 +101,16 @@ func (p *Postgres) Close() error {
 		wbzlog.Logger.Debug().Msg("Failed to close Postgres connection")
 		return err
 	}
-	for _, slave := range p.db.Slaves {
-		if slave != nil {
-			err := slave.Close()
-			if err != nil {
-				wbzlog.Logger.Debug().Msg("Failed to close Postgres slave connection")
-				return err
-			}
-		}
-	}
-	return nil
+	return p.replicas.close()
+}
+
+const (
+	pgUniqueViolation     = "23505"
+	pgForeignKeyViolation = "23503"
+)
+
+// isPgError проверяет код ошибки PostgreSQL, чтобы репозитории могли вернуть доменную ошибку
+func isPgError(err error, code string) bool {
+	var pqErr *pq.Error
+	return errors.As(err, &pqErr) && string(pqErr.Code) == code
 }
@@ -0,0 +1,240 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"sync/atomic"
+	"time"
+
+	"warehousecontrol/internal/config"
+	"warehousecontrol/internal/domain/user"
+
+	"github.com/wb-go/wbf/retry"
+	wbzlog "github.com/wb-go/wbf/zlog"
+)
+
+const (
+	defaultReplicaMaxLag        = 5 * time.Second
+	defaultReplicaCheckInterval = 5 * time.Second
+)
+
+// replicaLagQuery — отставание реплики в секундах и применённая ею позиция журнала. Если всё
+// полученное уже применено и реплика получает WAL потоком, она не отстаёт; иначе отставание
+// считается от последней применённой транзакции (NULL — неизвестно, реплика не используется).
+// Не реплика — 0 и текущая позиция.
+const replicaLagQuery = `
+	SELECT CASE
+		WHEN NOT pg_is_in_recovery() THEN 0
+		WHEN pg_last_wal_receive_lsn() = pg_last_wal_replay_lsn()
+			AND EXISTS (SELECT 1 FROM pg_stat_wal_receiver WHERE status = 'streaming') THEN 0
+		ELSE EXTRACT(EPOCH FROM now() - pg_last_xact_replay_timestamp())
+	END,
+	CASE WHEN pg_is_in_recovery() THEN pg_last_wal_replay_lsn() ELSE pg_current_wal_lsn() END
+`
+
+const currentLSNQuery = `SELECT pg_current_wal_lsn()`
+
+type replica struct {
+	name    string
+	db      *sql.DB
+	healthy atomic.Bool
+	// replayed — позиция журнала, применённая репликой к последней проверке
+	replayed atomic.Uint64
+}
+
+// replicaSet направляет чтение списков, истории и отчётов на реплики из db_config.slaves.
+// Реплика используется, пока отвечает и отстаёт не больше чем на max_lag. После записи
+// клиент получает позицию журнала мастера (user.WriteMark) и, пока реплики её не применили,
+// читает с мастера, чтобы сразу видеть свои изменения. Метка приходит с запросом, поэтому
+// это работает и при нескольких экземплярах сервиса.
+type replicaSet struct {
+	replicas []*replica
+	next     atomic.Uint64
+
+	maxLag   time.Duration
+	interval time.Duration
+	// currentLSN — позиция журнала мастера
+	currentLSN func(ctx context.Context) (string, error)
+
+	stop chan struct{}
+	done chan struct{}
+}
+
+func newReplicaSet(cfg config.ReplicaConfig, replicas []*replica) *replicaSet {
+	rs := &replicaSet{
+		replicas: replicas,
+		maxLag:   cfg.MaxLag,
+		interval: cfg.CheckInterval,
+		stop:     make(chan struct{}),
+		done:     make(chan struct{}),
+	}
+	if rs.maxLag <= 0 {
+		rs.maxLag = defaultReplicaMaxLag
+	}
+	if rs.interval <= 0 {
+		rs.interval = defaultReplicaCheckInterval
+	}
+	return rs
+}
+
+// start проверяет реплики сразу, чтобы чтение не шло на отстающую до первой проверки,
+// и дальше каждые check_interval
+func (rs *replicaSet) start() {
+	if len(rs.replicas) == 0 {
+		close(rs.done)
+		return
+	}
+	rs.check()
+	go func() {
+		defer close(rs.done)
+		ticker := time.NewTicker(rs.interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-rs.stop:
+				return
+			case <-ticker.C:
+				rs.check()
+			}
+		}
+	}()
+}
+
+func (rs *replicaSet) check() {
+	for _, r := range rs.replicas {
+		ctx, cancel := context.WithTimeout(context.Background(), rs.interval)
+		var lag sql.NullFloat64
+		var replayed sql.NullString
+		err := r.db.QueryRowContext(ctx, replicaLagQuery).Scan(&lag, &replayed)
+		cancel()
+		if lsn, ok := parseLSN(replayed.String); err == nil && ok {
+			r.replayed.Store(lsn)
+		}
+
+		healthy := err == nil && lag.Valid && time.Duration(lag.Float64*float64(time.Second)) <= rs.maxLag
+		if healthy == r.healthy.Swap(healthy) {
+			continue
+		}
+		switch {
+		case healthy:
+			wbzlog.Logger.Info().Str("replica", r.name).Msg("replica is back in rotation")
+		case err != nil:
+			wbzlog.Logger.Warn().Err(err).Str("replica", r.name).Msg("replica is unavailable, reading from master")
+		default:
+			wbzlog.Logger.Warn().Str("replica", r.name).Float64("lag_seconds", lag.Float64).Bool("lag_known", lag.Valid).Msg("replica lags behind, reading from master")
+		}
+	}
+}
+
+// pick выбирает по кругу исправную реплику, которая применила последнюю запись клиента;
+// nil — читать с мастера
+func (rs *replicaSet) pick(ctx context.Context) *replica {
+	if len(rs.replicas) == 0 {
+		return nil
+	}
+	var seen uint64
+	if mark := user.WriteMarkFromContext(ctx); mark != nil {
+		// испорченная метка не мешает читать с реплик
+		seen, _ = parseLSN(mark.Seen)
+	}
+	start := rs.next.Add(1)
+	for i := range rs.replicas {
+		r := rs.replicas[(start+uint64(i))%uint64(len(rs.replicas))]
+		if r.healthy.Load() && r.replayed.Load() >= seen {
+			return r
+		}
+	}
+	return nil
+}
+
+// wrote передаёт клиенту позицию журнала мастера после записи. Без реплик метка не нужна.
+func (rs *replicaSet) wrote(ctx context.Context) {
+	mark := user.WriteMarkFromContext(ctx)
+	if len(rs.replicas) == 0 || mark == nil || mark.Wrote == nil {
+		return
+	}
+	lsn, err := rs.currentLSN(ctx)
+	if err != nil {
+		wbzlog.Logger.Warn().Err(err).Msg("Failed to read master WAL position, the client may read stale data from replicas")
+		return
+	}
+	mark.Wrote(lsn)
+}
+
+// parseLSN разбирает позицию журнала в записи PostgreSQL (pg_lsn), например 16/B374D848
+func parseLSN(s string) (uint64, bool) {
+	var hi, lo uint32
+	if _, err := fmt.Sscanf(s, "%X/%X", &hi, &lo); err != nil {
+		return 0, false
+	}
+	return uint64(hi)<<32 | uint64(lo), true
+}
+
+func (rs *replicaSet) close() error {
+	if len(rs.replicas) > 0 {
+		close(rs.stop)
+	}
+	<-rs.done
+	var firstErr error
+	for _, r := range rs.replicas {
+		if err := r.db.Close(); err != nil {
+			wbzlog.Logger.Debug().Str("replica", r.name).Msg("Failed to close Postgres replica connection")
+			if firstErr == nil {
+				firstErr = err
+			}
+		}
+	}
+	return firstErr
+}
+
+// reader выполняет запросы на чтение одного вызова репозитория: на реплике, если она
+// выбрана, иначе на мастере с повторами из retry_strategy
+type reader struct {
+	p       *Postgres
+	replica *replica
+}
+
+// master — чтение, которое должно видеть последние записи (проверки перед записью, вход)
+func (p *Postgres) master() reader {
+	return reader{p: p}
+}
+
+// replicaReader — чтение, которому допустимо отставание до max_lag: списки, история, отчёты
+func (p *Postgres) replicaReader(ctx context.Context) reader {
+	return reader{p: p, replica: p.replicas.pick(ctx)}
+}
+
+func (r reader) query(ctx context.Context, query string, args ...any) (*sql.Rows, error) {
+	if r.replica != nil {
+		rows, err := r.replica.db.QueryContext(ctx, query, args...)
+		if err == nil || ctx.Err() != nil {
+			return rows, err
+		}
+		r.fallback(err)
+	}
+	return r.p.db.QueryWithRetry(ctx, r.p.strategy(), query, args...)
+}
+
+func (r reader) queryRow(ctx context.Context, query string, args ...any) (*sql.Row, error) {
+	if r.replica != nil {
+		row := r.replica.db.QueryRowContext(ctx, query, args...)
+		err := row.Err()
+		if err == nil || ctx.Err() != nil {
+			return row, err
+		}
+		r.fallback(err)
+	}
+	return r.p.db.QueryRowWithRetry(ctx, r.p.strategy(), query, args...)
+}
+
+// fallback выводит не ответившую реплику из ротации до следующей проверки
+func (r reader) fallback(err error) {
+	if r.replica.healthy.Swap(false) {
+		wbzlog.Logger.Warn().Err(err).Str("replica", r.replica.name).Msg("replica query failed, reading from master")
+	}
+}
+
+func (p *Postgres) strategy() retry.Strategy {
+	return retry.Strategy{Attempts: p.cfg.Attempts, Delay: p.cfg.Delay, Backoff: p.cfg.Backoffs}
+}
@@ -0,0 +1,105 @@
+package postgres
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"warehousecontrol/internal/config"
+	"warehousecontrol/internal/domain/user"
+)
+
+func testReplicaSet(names ...string) *replicaSet {
+	replicas := make([]*replica, 0, len(names))
+	for _, name := range names {
+		r := &replica{name: name}
+		r.healthy.Store(true)
+		replicas = append(replicas, r)
+	}
+	return newReplicaSet(config.ReplicaConfig{StickyWindow: time.Minute}, replicas)
+}
+
+func TestReplicaSet_PickRoundRobinSkipsUnhealthy(t *testing.T) {
+	rs := testReplicaSet("a", "b", "c")
+	seen := map[string]int{}
+	for i := 0; i < 6; i++ {
+		seen[rs.pick(t.Context()).name]++
+	}
+	if seen["a"] != 2 || seen["b"] != 2 || seen["c"] != 2 {
+		t.Fatalf("expected even spread, got %v", seen)
+	}
+
+	rs.replicas[1].healthy.Store(false)
+	for i := 0; i < 4; i++ {
+		if r := rs.pick(t.Context()); r.name == "b" {
+			t.Fatal("unhealthy replica must not be picked")
+		}
+	}
+
+	// все реплики недоступны — чтение идёт на мастер
+	reader{replica: rs.replicas[0]}.fallback(errors.New("connection refused"))
+	rs.replicas[2].healthy.Store(false)
+	if r := rs.pick(t.Context()); r != nil {
+		t.Fatalf("expected master, got %s", r.name)
+	}
+	if r := newReplicaSet(config.ReplicaConfig{}, nil).pick(t.Context()); r != nil {
+		t.Fatal("expected master without replicas")
+	}
+}
+
+func TestReplicaSet_ReadYourWrites(t *testing.T) {
+	rs := testReplicaSet("a", "b")
+	rs.replicas[0].replayed.Store(0x16_B374D848)
+	rs.replicas[1].replayed.Store(0x16_B374D000)
+	master := "16/B374D848"
+	rs.currentLSN = func(context.Context) (string, error) { return master, nil }
+
+	var got string
+	writer := &user.WriteMark{Wrote: func(lsn string) { got = lsn }}
+	rs.wrote(user.WithWriteMark(context.Background(), writer))
+	if got != master {
+		t.Fatalf("expected write mark %s, got %q", master, got)
+	}
+
+	// клиент с меткой читает только с реплики, применившей его запись
+	writer.Seen = got
+	for i := 0; i < 4; i++ {
+		if r := rs.pick(user.WithWriteMark(context.Background(), writer)); r == nil || r.name != "a" {
+			t.Fatalf("expected caught up replica, got %v", r)
+		}
+	}
+	writer.Seen = "16/B374D900"
+	if r := rs.pick(user.WithWriteMark(context.Background(), writer)); r != nil {
+		t.Fatalf("expected master until replicas catch up, got %s", r.name)
+	}
+
+	// без метки или с испорченной меткой чтение идёт с реплик
+	if r := rs.pick(context.Background()); r == nil {
+		t.Fatal("reads without a mark keep using replicas")
+	}
+	if r := rs.pick(user.WithWriteMark(context.Background(), &user.WriteMark{Seen: "garbage"})); r == nil {
+		t.Fatal("invalid mark must be ignored")
+	}
+
+	// позицию мастера не прочитать — метка не выдаётся
+	got = ""
+	rs.currentLSN = func(context.Context) (string, error) { return "", errors.New("connection refused") }
+	rs.wrote(user.WithWriteMark(context.Background(), writer))
+	if got != "" {
+		t.Fatalf("expected no mark, got %q", got)
+	}
+}
+
+func TestParseLSN(t *testing.T) {
+	for in, want := range map[string]uint64{"0/0": 0, "16/B374D848": 0x16_B374D848, "FFFFFFFF/FFFFFFFF": 1<<64 - 1} {
+		if got, ok := parseLSN(in); !ok || got != want {
+			t.Fatalf("parseLSN(%q) = %x, %v; want %x", in, got, ok, want)
+		}
+	}
+	for _, in := range []string{"", "16", "x/1", "100000000/0"} {
+		if _, ok := parseLSN(in); ok {
+			t.Fatalf("expected %q to be rejected", in)
+		}
+	}
+}
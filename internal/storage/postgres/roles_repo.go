@@ -0,0 +1,185 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+
+	"warehousecontrol/internal/domain/user"
+
+	"github.com/lib/pq"
+	"github.com/wb-go/wbf/retry"
+	wbzlog "github.com/wb-go/wbf/zlog"
+)
+
+const roleColumns = `name, description, permissions, built_in, version, created_at, updated_at`
+
+func scanRole(row rowScanner) (*user.RoleDefinition, error) {
+	var r user.RoleDefinition
+	var perms []string
+	err := row.Scan(
+		&r.Name,
+		&r.Description,
+		pq.Array(&perms),
+		&r.BuiltIn,
+		&r.Version,
+		&r.CreatedAt,
+		&r.UpdatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	r.Permissions = make(user.Permissions, 0, len(perms))
+	for _, p := range perms {
+		r.Permissions = append(r.Permissions, user.Permission(p))
+	}
+	return &r, nil
+}
+
+func permissionStrings(perms user.Permissions) []string {
+	res := make([]string, 0, len(perms))
+	for _, p := range perms {
+		res = append(res, string(p))
+	}
+	return res
+}
+
+func (p *Postgres) GetRoles(ctx context.Context) ([]*user.RoleDefinition, error) {
+	ctx, cancel := p.withTimeout(ctx)
+	defer cancel()
+
+	query := `SELECT ` + roleColumns + ` FROM roles ORDER BY built_in DESC, name`
+	rows, err := p.db.QueryWithRetry(ctx, retry.Strategy{Attempts: p.cfg.Attempts, Delay: p.cfg.Delay, Backoff: p.cfg.Backoffs}, query)
+	if err != nil {
+		wbzlog.Logger.Error().Err(err).Msg("Failed to execute get roles query")
+		return nil, err
+	}
+	defer func() {
+		err = rows.Close()
+		if err != nil {
+			wbzlog.Logger.Error().Err(err).Msg("Failed to close role rows")
+		}
+	}()
+
+	roles := []*user.RoleDefinition{}
+	for rows.Next() {
+		r, err := scanRole(rows)
+		if err != nil {
+			wbzlog.Logger.Error().Err(err).Msg("Failed to scan role row")
+			return nil, err
+		}
+		roles = append(roles, r)
+	}
+	if err := rows.Err(); err != nil {
+		wbzlog.Logger.Error().Err(err).Msg("Failed to iterate role rows")
+		return nil, err
+	}
+	return roles, nil
+}
+
+func (p *Postgres) GetRole(ctx context.Context, name user.Role) (*user.RoleDefinition, error) {
+	ctx, cancel := p.withTimeout(ctx)
+	defer cancel()
+
+	query := `SELECT ` + roleColumns + ` FROM roles WHERE name = $1`
+	row, err := p.db.QueryRowWithRetry(ctx, retry.Strategy{Attempts: p.cfg.Attempts, Delay: p.cfg.Delay, Backoff: p.cfg.Backoffs}, query, name)
+	if err != nil {
+		wbzlog.Logger.Error().Err(err).Msg("Failed to execute get role query")
+		return nil, err
+	}
+	r, err := scanRole(row)
+	if err == sql.ErrNoRows {
+		return nil, user.ErrRoleNotFound
+	}
+	if err != nil {
+		wbzlog.Logger.Error().Err(err).Msg("Failed to scan role row")
+		return nil, err
+	}
+	return r, nil
+}
+
+func (p *Postgres) CreateRole(ctx context.Context, r *user.RoleDefinition) error {
+	ctx, cancel := p.withTimeout(ctx)
+	defer cancel()
+
+	query := `
+		INSERT INTO roles (name, description, permissions, version, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, $5, $6)
+	`
+	_, err := p.db.ExecWithRetry(ctx, retry.Strategy{Attempts: p.cfg.Attempts, Delay: p.cfg.Delay, Backoff: p.cfg.Backoffs}, query,
+		r.Name,
+		r.Description,
+		pq.Array(permissionStrings(r.Permissions)),
+		r.Version,
+		r.CreatedAt,
+		r.UpdatedAt,
+	)
+	if isPgError(err, pgUniqueViolation) {
+		return user.ErrRoleExists
+	}
+	if err != nil {
+		wbzlog.Logger.Error().Err(err).Msg("Failed to execute insert role query")
+		return err
+	}
+	return nil
+}
+
+// UpdateRole меняет описание и права пользовательской роли и увеличивает её версию
+func (p *Postgres) UpdateRole(ctx context.Context, name user.Role, description string, perms user.Permissions) (*user.RoleDefinition, error) {
+	ctx, cancel := p.withTimeout(ctx)
+	defer cancel()
+
+	query := `
+		UPDATE roles SET description = $2, permissions = $3, version = version + 1, updated_at = now()
+		WHERE name = $1 AND NOT built_in
+		RETURNING ` + roleColumns
+	row, err := p.db.QueryRowWithRetry(ctx, retry.Strategy{Attempts: p.cfg.Attempts, Delay: p.cfg.Delay, Backoff: p.cfg.Backoffs}, query,
+		name, description, pq.Array(permissionStrings(perms)))
+	if err != nil {
+		wbzlog.Logger.Error().Err(err).Msg("Failed to execute update role query")
+		return nil, err
+	}
+	r, err := scanRole(row)
+	if err == sql.ErrNoRows {
+		return nil, p.missingOrBuiltInRole(ctx, name)
+	}
+	if err != nil {
+		wbzlog.Logger.Error().Err(err).Msg("Failed to scan role row")
+		return nil, err
+	}
+	return r, nil
+}
+
+// DeleteRole удаляет пользовательскую роль, если она никому не назначена
+func (p *Postgres) DeleteRole(ctx context.Context, name user.Role) error {
+	ctx, cancel := p.withTimeout(ctx)
+	defer cancel()
+
+	res, err := p.db.ExecWithRetry(ctx, retry.Strategy{Attempts: p.cfg.Attempts, Delay: p.cfg.Delay, Backoff: p.cfg.Backoffs},
+		`DELETE FROM roles WHERE name = $1 AND NOT built_in`, name)
+	if isPgError(err, pgForeignKeyViolation) {
+		return user.ErrRoleInUse
+	}
+	if err != nil {
+		wbzlog.Logger.Error().Err(err).Msg("Failed to execute delete role query")
+		return err
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return p.missingOrBuiltInRole(ctx, name)
+	}
+	return nil
+}
+
+func (p *Postgres) missingOrBuiltInRole(ctx context.Context, name user.Role) error {
+	r, err := p.GetRole(ctx, name)
+	if err != nil {
+		return err
+	}
+	if r.BuiltIn {
+		return user.ErrBuiltInRole
+	}
+	return user.ErrRoleNotFound
+}
@@ -0,0 +1,284 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"warehousecontrol/internal/domain/user"
+
+	"github.com/wb-go/wbf/retry"
+	wbzlog "github.com/wb-go/wbf/zlog"
+)
+
+const sessionColumns = `id, user_id, user_agent, ip, created_at, last_used_at, expires_at, revoked_at`
+
+func scanSession(row rowScanner) (*user.Session, error) {
+	var s user.Session
+	err := row.Scan(
+		&s.ID,
+		&s.UserID,
+		&s.UserAgent,
+		&s.IP,
+		&s.CreatedAt,
+		&s.LastUsedAt,
+		&s.ExpiresAt,
+		&s.RevokedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return &s, nil
+}
+
+// CreateSession сохраняет сессию вместе с первым refresh токеном и заодно удаляет
+// истёкшие сессии пользователя, чтобы таблицы не росли бесконечно
+func (p *Postgres) CreateSession(ctx context.Context, s *user.Session, first *user.RefreshToken) error {
+	ctx, cancel := p.withTimeout(ctx)
+	defer cancel()
+
+	tx, err := p.db.Master.BeginTx(ctx, nil)
+	if err != nil {
+		wbzlog.Logger.Error().Err(err).Msg("cant start transaction in create session")
+		return err
+	}
+	defer func() {
+		_ = tx.Rollback()
+	}()
+
+	_, err = tx.ExecContext(ctx, `DELETE FROM sessions WHERE user_id = $1 AND expires_at < now()`, s.UserID)
+	if err != nil {
+		wbzlog.Logger.Error().Err(err).Msg("Failed to delete expired sessions")
+		return err
+	}
+	_, err = tx.ExecContext(ctx, `
+		INSERT INTO sessions (id, user_id, user_agent, ip, created_at, last_used_at, expires_at)
+		VALUES ($1, $2, $3, $4, $5, $5, $6)
+	`, s.ID, s.UserID, s.UserAgent, s.IP, s.CreatedAt, s.ExpiresAt)
+	if err != nil {
+		wbzlog.Logger.Error().Err(err).Msg("Failed to execute insert session query")
+		return err
+	}
+	err = insertRefreshToken(ctx, tx, first)
+	if err != nil {
+		return err
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		wbzlog.Logger.Error().Err(err).Msg("Failed to commit transaction")
+		return err
+	}
+	return nil
+}
+
+// RotateRefreshToken помечает токен oldID использованным, сохраняет следующий токен сессии
+// и продлевает её. Повторное предъявление использованного токена отзывает сессию:
+// так обнаруживается кража токена, ведь легитимный клиент уже получил следующий.
+func (p *Postgres) RotateRefreshToken(ctx context.Context, oldID string, next *user.RefreshToken) error {
+	ctx, cancel := p.withTimeout(ctx)
+	defer cancel()
+
+	tx, err := p.db.Master.BeginTx(ctx, nil)
+	if err != nil {
+		wbzlog.Logger.Error().Err(err).Msg("cant start transaction in rotate refresh token")
+		return err
+	}
+	defer func() {
+		_ = tx.Rollback()
+	}()
+
+	var old user.RefreshToken
+	var revokedAt *time.Time
+	err = tx.QueryRowContext(ctx, `
+		SELECT rt.id, rt.session_id, rt.user_id, rt.expires_at, rt.used_at, s.revoked_at
+		FROM refresh_tokens rt
+		JOIN sessions s ON s.id = rt.session_id
+		WHERE rt.id = $1
+		FOR UPDATE
+	`, oldID).Scan(&old.ID, &old.SessionID, &old.UserID, &old.ExpiresAt, &old.UsedAt, &revokedAt)
+	if err == sql.ErrNoRows {
+		return user.ErrRefreshTokenInvalid
+	}
+	if err != nil {
+		wbzlog.Logger.Error().Err(err).Msg("Failed to scan refresh token row")
+		return err
+	}
+
+	if revokedAt != nil || old.SessionID != next.SessionID || old.UserID != next.UserID {
+		return user.ErrRefreshTokenInvalid
+	}
+	if old.UsedAt != nil {
+		_, err = tx.ExecContext(ctx, `UPDATE sessions SET revoked_at = now() WHERE id = $1`, old.SessionID)
+		if err != nil {
+			wbzlog.Logger.Error().Err(err).Msg("Failed to revoke session")
+			return err
+		}
+		err = tx.Commit()
+		if err != nil {
+			wbzlog.Logger.Error().Err(err).Msg("Failed to commit transaction")
+			return err
+		}
+		wbzlog.Logger.Warn().Str("session_id", old.SessionID.String()).Str("user_id", old.UserID.String()).Msg("refresh token reuse detected, session revoked")
+		return user.ErrRefreshTokenReused
+	}
+	if !old.ExpiresAt.After(time.Now()) {
+		return user.ErrRefreshTokenInvalid
+	}
+
+	_, err = tx.ExecContext(ctx, `UPDATE refresh_tokens SET used_at = now() WHERE id = $1`, old.ID)
+	if err != nil {
+		wbzlog.Logger.Error().Err(err).Msg("Failed to mark refresh token used")
+		return err
+	}
+	err = insertRefreshToken(ctx, tx, next)
+	if err != nil {
+		return err
+	}
+	_, err = tx.ExecContext(ctx, `
+		UPDATE sessions SET last_used_at = now(), expires_at = $2 WHERE id = $1
+	`, next.SessionID, next.ExpiresAt)
+	if err != nil {
+		wbzlog.Logger.Error().Err(err).Msg("Failed to extend session")
+		return err
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		wbzlog.Logger.Error().Err(err).Msg("Failed to commit transaction")
+		return err
+	}
+	return nil
+}
+
+func (p *Postgres) GetSession(ctx context.Context, id string) (*user.Session, error) {
+	ctx, cancel := p.withTimeout(ctx)
+	defer cancel()
+
+	query := `SELECT ` + sessionColumns + ` FROM sessions WHERE id = $1`
+	row, err := p.db.QueryRowWithRetry(ctx, retry.Strategy{Attempts: p.cfg.Attempts, Delay: p.cfg.Delay, Backoff: p.cfg.Backoffs}, query, id)
+	if err != nil {
+		wbzlog.Logger.Error().Err(err).Msg("Failed to execute get session query")
+		return nil, err
+	}
+	s, err := scanSession(row)
+	if err == sql.ErrNoRows {
+		return nil, user.ErrSessionNotFound
+	}
+	if err != nil {
+		wbzlog.Logger.Error().Err(err).Msg("Failed to scan session row")
+		return nil, err
+	}
+	return s, nil
+}
+
+// TouchSession отмечает использование сессии access токеном
+func (p *Postgres) TouchSession(ctx context.Context, id string) error {
+	ctx, cancel := p.withTimeout(ctx)
+	defer cancel()
+
+	_, err := p.db.ExecWithRetry(ctx, retry.Strategy{Attempts: p.cfg.Attempts, Delay: p.cfg.Delay, Backoff: p.cfg.Backoffs},
+		`UPDATE sessions SET last_used_at = now() WHERE id = $1`, id)
+	if err != nil {
+		wbzlog.Logger.Error().Err(err).Msg("Failed to touch session")
+		return err
+	}
+	return nil
+}
+
+// GetUserSessions возвращает активные сессии пользователя, последние использованные — первыми
+func (p *Postgres) GetUserSessions(ctx context.Context, userID string) ([]*user.Session, error) {
+	ctx, cancel := p.withTimeout(ctx)
+	defer cancel()
+
+	query := `
+		SELECT ` + sessionColumns + `
+		FROM sessions
+		WHERE user_id = $1 AND revoked_at IS NULL AND expires_at > now()
+		ORDER BY last_used_at DESC
+	`
+	rows, err := p.db.QueryWithRetry(ctx, retry.Strategy{Attempts: p.cfg.Attempts, Delay: p.cfg.Delay, Backoff: p.cfg.Backoffs}, query, userID)
+	if err != nil {
+		wbzlog.Logger.Error().Err(err).Msg("Failed to execute get sessions query")
+		return nil, err
+	}
+	defer func() {
+		err = rows.Close()
+		if err != nil {
+			wbzlog.Logger.Error().Err(err).Msg("Failed to close session rows")
+		}
+	}()
+
+	res := []*user.Session{}
+	for rows.Next() {
+		s, err := scanSession(rows)
+		if err != nil {
+			wbzlog.Logger.Error().Err(err).Msg("Failed to scan session row")
+			return nil, err
+		}
+		res = append(res, s)
+	}
+	if err := rows.Err(); err != nil {
+		wbzlog.Logger.Error().Err(err).Msg("Failed to iterate session rows")
+		return nil, err
+	}
+	return res, nil
+}
+
+// RevokeSession отзывает сессию пользователя; повторный отзыв ничего не меняет
+func (p *Postgres) RevokeSession(ctx context.Context, userID, sessionID string) error {
+	ctx, cancel := p.withTimeout(ctx)
+	defer cancel()
+
+	row, err := p.db.QueryRowWithRetry(ctx, retry.Strategy{Attempts: p.cfg.Attempts, Delay: p.cfg.Delay, Backoff: p.cfg.Backoffs}, `
+		WITH revoked AS (
+			UPDATE sessions SET revoked_at = now()
+			WHERE id = $1 AND user_id = $2 AND revoked_at IS NULL
+			RETURNING id
+		)
+		SELECT EXISTS(SELECT 1 FROM revoked)
+			OR EXISTS(SELECT 1 FROM sessions WHERE id = $1 AND user_id = $2)
+	`, sessionID, userID)
+	if err != nil {
+		wbzlog.Logger.Error().Err(err).Msg("Failed to execute revoke session query")
+		return err
+	}
+	var found bool
+	err = row.Scan(&found)
+	if err != nil {
+		wbzlog.Logger.Error().Err(err).Msg("Failed to scan revoke session result")
+		return err
+	}
+	if !found {
+		return user.ErrSessionNotFound
+	}
+	return nil
+}
+
+// RevokeUserSessions отзывает все сессии пользователя (выход со всех устройств)
+func (p *Postgres) RevokeUserSessions(ctx context.Context, userID string) error {
+	ctx, cancel := p.withTimeout(ctx)
+	defer cancel()
+
+	_, err := p.db.ExecWithRetry(ctx, retry.Strategy{Attempts: p.cfg.Attempts, Delay: p.cfg.Delay, Backoff: p.cfg.Backoffs},
+		revokeUserSessionsQuery, userID)
+	if err != nil {
+		wbzlog.Logger.Error().Err(err).Msg("Failed to revoke user sessions")
+		return err
+	}
+	return nil
+}
+
+const revokeUserSessionsQuery = `UPDATE sessions SET revoked_at = now() WHERE user_id = $1 AND revoked_at IS NULL`
+
+func insertRefreshToken(ctx context.Context, tx *sql.Tx, t *user.RefreshToken) error {
+	_, err := tx.ExecContext(ctx, `
+		INSERT INTO refresh_tokens (id, session_id, user_id, issued_at, expires_at)
+		VALUES ($1, $2, $3, $4, $5)
+	`, t.ID, t.SessionID, t.UserID, t.IssuedAt, t.ExpiresAt)
+	if err != nil {
+		wbzlog.Logger.Error().Err(err).Msg("Failed to execute insert refresh token query")
+		return err
+	}
+	return nil
+}
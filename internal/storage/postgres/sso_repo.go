@@ -0,0 +1,173 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+
+	"warehousecontrol/internal/domain/user"
+
+	"github.com/wb-go/wbf/retry"
+	wbzlog "github.com/wb-go/wbf/zlog"
+)
+
+// SaveSSOState сохраняет состояние начатого входа через провайдера; заодно удаляются истёкшие
+func (p *Postgres) SaveSSOState(ctx context.Context, s *user.SSOState) error {
+	ctx, cancel := p.withTimeout(ctx)
+	defer cancel()
+
+	query := `
+		WITH expired AS (DELETE FROM sso_login_states WHERE expires_at <= now())
+		INSERT INTO sso_login_states (state_hash, nonce, code_verifier, created_at, expires_at)
+		VALUES ($1, $2, $3, $4, $5)
+	`
+	_, err := p.db.ExecWithRetry(ctx, retry.Strategy{Attempts: p.cfg.Attempts, Delay: p.cfg.Delay, Backoff: p.cfg.Backoffs}, query,
+		s.StateHash, s.Nonce, s.CodeVerifier, s.CreatedAt, s.ExpiresAt)
+	if err != nil {
+		wbzlog.Logger.Error().Err(err).Msg("Failed to execute insert sso state query")
+		return err
+	}
+	return nil
+}
+
+// TakeSSOState удаляет состояние входа и возвращает его: каждый state завершает не больше одного входа.
+// Неизвестный, истёкший и использованный state неотличимы.
+func (p *Postgres) TakeSSOState(ctx context.Context, stateHash []byte) (*user.SSOState, error) {
+	ctx, cancel := p.withTimeout(ctx)
+	defer cancel()
+
+	query := `
+		DELETE FROM sso_login_states
+		WHERE state_hash = $1 AND expires_at > now()
+		RETURNING state_hash, nonce, code_verifier, created_at, expires_at
+	`
+	row, err := p.db.QueryRowWithRetry(ctx, retry.Strategy{Attempts: p.cfg.Attempts, Delay: p.cfg.Delay, Backoff: p.cfg.Backoffs}, query, stateHash)
+	if err != nil {
+		wbzlog.Logger.Error().Err(err).Msg("Failed to execute take sso state query")
+		return nil, err
+	}
+	var s user.SSOState
+	err = row.Scan(&s.StateHash, &s.Nonce, &s.CodeVerifier, &s.CreatedAt, &s.ExpiresAt)
+	if err == sql.ErrNoRows {
+		return nil, user.ErrSSOStateInvalid
+	}
+	if err != nil {
+		wbzlog.Logger.Error().Err(err).Msg("Failed to scan sso state row")
+		return nil, err
+	}
+	return &s, nil
+}
+
+// SyncSSOUser находит учётную запись, связанную с пользователем провайдера, и приводит её роль
+// к роли candidate, а если связи ещё нет — заводит candidate и связывает с ним. Роль меняется
+// от имени системы с записью в аудит; последнего администратора провайдер разжаловать не может.
+func (p *Postgres) SyncSSOUser(ctx context.Context, ident *user.Identity, candidate *user.User) (*user.User, error) {
+	ctx, cancel := p.withTimeout(ctx)
+	defer cancel()
+
+	tx, err := p.db.Master.BeginTx(ctx, nil)
+	if err != nil {
+		wbzlog.Logger.Error().Err(err).Msg("cant start transaction in sync sso user")
+		return nil, err
+	}
+	defer func() {
+		_ = tx.Rollback()
+	}()
+
+	// та же блокировка, что и при изменении пользователей администратором
+	_, err = tx.ExecContext(ctx, `LOCK TABLE users IN SHARE ROW EXCLUSIVE MODE`)
+	if err != nil {
+		wbzlog.Logger.Error().Err(err).Msg("Failed to lock users table")
+		return nil, err
+	}
+
+	u, err := scanUser(tx.QueryRowContext(ctx, `
+		SELECT `+userColumns+` FROM users
+		WHERE id = (SELECT user_id FROM user_identities WHERE issuer = $1 AND subject = $2)
+	`, ident.Issuer, ident.Subject))
+	switch {
+	case err == sql.ErrNoRows:
+		u, err = provisionSSOUser(ctx, tx, ident, candidate)
+	case err != nil:
+		wbzlog.Logger.Error().Err(err).Msg("Failed to scan user row")
+	default:
+		err = syncSSORole(ctx, tx, ident, u, candidate.Role)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		wbzlog.Logger.Error().Err(err).Msg("Failed to commit transaction")
+		return nil, err
+	}
+	return u, nil
+}
+
+func provisionSSOUser(ctx context.Context, tx *sql.Tx, ident *user.Identity, u *user.User) (*user.User, error) {
+	err := insertUser(ctx, tx, u)
+	if errors.Is(err, user.ErrAlreadyExists) {
+		return nil, user.ErrSSOLoginTaken
+	}
+	if err != nil {
+		return nil, err
+	}
+	_, err = tx.ExecContext(ctx, `
+		INSERT INTO user_identities (issuer, subject, user_id) VALUES ($1, $2, $3)
+	`, ident.Issuer, ident.Subject, u.Id)
+	if err != nil {
+		wbzlog.Logger.Error().Err(err).Msg("Failed to execute insert user identity query")
+		return nil, err
+	}
+	err = insertSystemAudit(ctx, tx, u, user.AuditSSOProvisioned, nil, strPtr(string(u.Role)))
+	if err != nil {
+		return nil, err
+	}
+	return u, nil
+}
+
+func syncSSORole(ctx context.Context, tx *sql.Tx, ident *user.Identity, u *user.User, role user.Role) error {
+	_, err := tx.ExecContext(ctx, `
+		UPDATE user_identities SET last_login_at = now() WHERE issuer = $1 AND subject = $2
+	`, ident.Issuer, ident.Subject)
+	if err != nil {
+		wbzlog.Logger.Error().Err(err).Msg("Failed to update user identity last login")
+		return err
+	}
+	if u.Role == role {
+		return nil
+	}
+	if u.Role == user.Admin && !u.Disabled() {
+		if err := ensureOtherActiveAdmin(ctx, tx, u.Id); err != nil {
+			return err
+		}
+	}
+	_, err = tx.ExecContext(ctx, `UPDATE users SET role = $2 WHERE id = $1`, u.Id, role)
+	if err != nil {
+		wbzlog.Logger.Error().Err(err).Msg("Failed to execute update user role query")
+		return err
+	}
+	// сессии со старой ролью завершаются, как при смене роли администратором; сессия этого входа создаётся позже
+	_, err = tx.ExecContext(ctx, revokeUserSessionsQuery, u.Id)
+	if err != nil {
+		wbzlog.Logger.Error().Err(err).Msg("Failed to revoke sessions of user with changed role")
+		return err
+	}
+	old := string(u.Role)
+	u.Role = role
+	return insertSystemAudit(ctx, tx, u, user.AuditRoleChanged, &old, strPtr(string(role)))
+}
+
+// insertSystemAudit пишет в аудит действие самой системы: автора у такой записи нет
+func insertSystemAudit(ctx context.Context, tx *sql.Tx, u *user.User, action string, oldValue, newValue *string) error {
+	_, err := tx.ExecContext(ctx, `
+		INSERT INTO user_audit (user_id, user_login, action, old_value, new_value, actor_login)
+		VALUES ($1, $2, $3, $4, $5, $6)
+	`, u.Id, u.Login, action, oldValue, newValue, user.SystemActor)
+	if err != nil {
+		wbzlog.Logger.Error().Err(err).Msg("Failed to insert user audit record")
+		return err
+	}
+	return nil
+}
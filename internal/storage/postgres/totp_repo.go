@@ -0,0 +1,198 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+
+	"warehousecontrol/internal/domain/user"
+
+	"github.com/wb-go/wbf/retry"
+	wbzlog "github.com/wb-go/wbf/zlog"
+)
+
+// GetTOTP возвращает второй фактор пользователя вместе с числом оставшихся кодов восстановления
+func (p *Postgres) GetTOTP(ctx context.Context, userID string) (*user.TOTP, error) {
+	ctx, cancel := p.withTimeout(ctx)
+	defer cancel()
+
+	query := `
+		SELECT t.user_id, t.secret, t.created_at, t.confirmed_at, t.last_step,
+		       (SELECT count(*) FROM user_recovery_codes c WHERE c.user_id = t.user_id AND c.used_at IS NULL)
+		FROM user_totp t
+		WHERE t.user_id = $1
+	`
+	row, err := p.db.QueryRowWithRetry(ctx, retry.Strategy{Attempts: p.cfg.Attempts, Delay: p.cfg.Delay, Backoff: p.cfg.Backoffs}, query, userID)
+	if err != nil {
+		wbzlog.Logger.Error().Err(err).Msg("Failed to execute get totp query")
+		return nil, err
+	}
+	var t user.TOTP
+	err = row.Scan(&t.UserID, &t.Secret, &t.CreatedAt, &t.ConfirmedAt, &t.LastStep, &t.RecoveryCodesLeft)
+	if err == sql.ErrNoRows {
+		return nil, user.ErrTOTPNotEnabled
+	}
+	if err != nil {
+		wbzlog.Logger.Error().Err(err).Msg("Failed to scan totp row")
+		return nil, err
+	}
+	return &t, nil
+}
+
+// SaveTOTPSecret сохраняет секрет на время настройки, заменяя прежний неподтверждённый.
+// Подключённый второй фактор не перезаписывается.
+func (p *Postgres) SaveTOTPSecret(ctx context.Context, t *user.TOTP) error {
+	ctx, cancel := p.withTimeout(ctx)
+	defer cancel()
+
+	res, err := p.db.ExecWithRetry(ctx, retry.Strategy{Attempts: p.cfg.Attempts, Delay: p.cfg.Delay, Backoff: p.cfg.Backoffs}, `
+		INSERT INTO user_totp (user_id, secret, created_at)
+		VALUES ($1, $2, $3)
+		ON CONFLICT (user_id) DO UPDATE
+		SET secret = EXCLUDED.secret, created_at = EXCLUDED.created_at, last_step = 0
+		WHERE user_totp.confirmed_at IS NULL
+	`, t.UserID, t.Secret, t.CreatedAt)
+	if isPgError(err, pgForeignKeyViolation) {
+		return user.ErrNotFound
+	}
+	if err != nil {
+		wbzlog.Logger.Error().Err(err).Msg("Failed to execute save totp secret query")
+		return err
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return user.ErrTOTPAlreadyEnabled
+	}
+	return nil
+}
+
+// EnableTOTP подтверждает настроенный секрет кодом шага step и выдаёт коды восстановления
+func (p *Postgres) EnableTOTP(ctx context.Context, userID string, step int64, codeHashes [][]byte, actorLogin string) error {
+	return p.modifyUser(ctx, userID, userID, actorLogin, func(ctx context.Context, tx *sql.Tx, u *user.User) (*user.AuditEntry, error) {
+		res, err := tx.ExecContext(ctx, `
+			UPDATE user_totp SET confirmed_at = now(), last_step = $2
+			WHERE user_id = $1 AND confirmed_at IS NULL
+		`, u.Id, step)
+		if err != nil {
+			wbzlog.Logger.Error().Err(err).Msg("Failed to execute enable totp query")
+			return nil, err
+		}
+		n, err := res.RowsAffected()
+		if err != nil {
+			return nil, err
+		}
+		if n == 0 {
+			// секрет не настроен или второй фактор уже подключён параллельным запросом
+			return nil, user.ErrTOTPInvalidCode
+		}
+		if err := replaceRecoveryCodes(ctx, tx, u, codeHashes); err != nil {
+			return nil, err
+		}
+		return &user.AuditEntry{Action: user.AuditTOTPEnabled}, nil
+	})
+}
+
+// UseTOTPStep принимает код шага step, если шаг позже последнего принятого
+func (p *Postgres) UseTOTPStep(ctx context.Context, userID string, step int64) error {
+	ctx, cancel := p.withTimeout(ctx)
+	defer cancel()
+
+	res, err := p.db.ExecWithRetry(ctx, retry.Strategy{Attempts: p.cfg.Attempts, Delay: p.cfg.Delay, Backoff: p.cfg.Backoffs}, `
+		UPDATE user_totp SET last_step = $2
+		WHERE user_id = $1 AND confirmed_at IS NOT NULL AND last_step < $2
+	`, userID, step)
+	if err != nil {
+		wbzlog.Logger.Error().Err(err).Msg("Failed to execute use totp step query")
+		return err
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return user.ErrTOTPInvalidCode
+	}
+	return nil
+}
+
+// UseRecoveryCode гасит код восстановления; каждый код действует один раз
+func (p *Postgres) UseRecoveryCode(ctx context.Context, userID string, codeHash []byte) error {
+	ctx, cancel := p.withTimeout(ctx)
+	defer cancel()
+
+	res, err := p.db.ExecWithRetry(ctx, retry.Strategy{Attempts: p.cfg.Attempts, Delay: p.cfg.Delay, Backoff: p.cfg.Backoffs}, `
+		UPDATE user_recovery_codes SET used_at = now()
+		WHERE user_id = $1 AND code_hash = $2 AND used_at IS NULL
+	`, userID, codeHash)
+	if err != nil {
+		wbzlog.Logger.Error().Err(err).Msg("Failed to execute use recovery code query")
+		return err
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return user.ErrTOTPInvalidCode
+	}
+	wbzlog.Logger.Info().Str("user_id", userID).Msg("recovery code used")
+	return nil
+}
+
+// ReplaceRecoveryCodes выдаёт новый набор кодов восстановления, прежние перестают действовать
+func (p *Postgres) ReplaceRecoveryCodes(ctx context.Context, userID string, codeHashes [][]byte, actorLogin string) error {
+	return p.modifyUser(ctx, userID, userID, actorLogin, func(ctx context.Context, tx *sql.Tx, u *user.User) (*user.AuditEntry, error) {
+		var enabled bool
+		err := tx.QueryRowContext(ctx, `
+			SELECT EXISTS(SELECT 1 FROM user_totp WHERE user_id = $1 AND confirmed_at IS NOT NULL)
+		`, u.Id).Scan(&enabled)
+		if err != nil {
+			wbzlog.Logger.Error().Err(err).Msg("Failed to check totp state")
+			return nil, err
+		}
+		if !enabled {
+			return nil, user.ErrTOTPNotEnabled
+		}
+		if err := replaceRecoveryCodes(ctx, tx, u, codeHashes); err != nil {
+			return nil, err
+		}
+		return &user.AuditEntry{Action: user.AuditRecoveryRenewed}, nil
+	})
+}
+
+// DisableTOTP отключает второй фактор и удаляет коды восстановления. Вызывается самим
+// пользователем или администратором, когда пользователь потерял устройство.
+func (p *Postgres) DisableTOTP(ctx context.Context, userID, actorID, actorLogin string) error {
+	return p.modifyUser(ctx, userID, actorID, actorLogin, func(ctx context.Context, tx *sql.Tx, u *user.User) (*user.AuditEntry, error) {
+		var confirmed bool
+		err := tx.QueryRowContext(ctx, `
+			DELETE FROM user_totp WHERE user_id = $1 RETURNING confirmed_at IS NOT NULL
+		`, u.Id).Scan(&confirmed)
+		if err == sql.ErrNoRows || (err == nil && !confirmed) {
+			return nil, user.ErrTOTPNotEnabled
+		}
+		if err != nil {
+			wbzlog.Logger.Error().Err(err).Msg("Failed to execute delete totp query")
+			return nil, err
+		}
+		return &user.AuditEntry{Action: user.AuditTOTPDisabled}, nil
+	})
+}
+
+func replaceRecoveryCodes(ctx context.Context, tx *sql.Tx, u *user.User, codeHashes [][]byte) error {
+	_, err := tx.ExecContext(ctx, `DELETE FROM user_recovery_codes WHERE user_id = $1`, u.Id)
+	if err != nil {
+		wbzlog.Logger.Error().Err(err).Msg("Failed to delete recovery codes")
+		return err
+	}
+	for _, h := range codeHashes {
+		_, err = tx.ExecContext(ctx, `INSERT INTO user_recovery_codes (user_id, code_hash) VALUES ($1, $2)`, u.Id, h)
+		if err != nil {
+			wbzlog.Logger.Error().Err(err).Msg("Failed to insert recovery code")
+			return err
+		}
+	}
+	return nil
+}
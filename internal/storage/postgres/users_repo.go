@@ -3,67 +3,411 @@ package postgres
 import (
 	"context"
 	"database/sql"
-	"errors"
+	"fmt"
 
 	"warehousecontrol/internal/domain/user"
 
+	"github.com/google/uuid"
 	"github.com/wb-go/wbf/retry"
 	wbzlog "github.com/wb-go/wbf/zlog"
 )
 
-func (p *Postgres) GetUser(login string) (*user.User, error) {
-	ctx := context.Background()
+const userColumns = `id, login, password, created_at, role, disabled_at, service_account`
 
-	query := `
-		SELECT id, login, password, created_at, role
-		FROM users
-		WHERE login = $1
-	`
+func scanUser(row rowScanner) (*user.User, error) {
 	var u user.User
-	row, err := p.db.QueryRowWithRetry(ctx, retry.Strategy{Attempts: p.cfg.Attempts, Delay: p.cfg.Delay, Backoff: p.cfg.Backoffs}, query, login)
-	if err != nil {
-		wbzlog.Logger.Error().Err(err).Msg("Failed to execute get user query")
-		return nil, err
-	}
-
-	err = row.Scan(
+	err := row.Scan(
 		&u.Id,
 		&u.Login,
 		&u.Password,
 		&u.CreatedAt,
 		&u.Role,
+		&u.DisabledAt,
+		&u.ServiceAccount,
 	)
-	if err != nil && err != sql.ErrNoRows {
+	if err != nil {
+		return nil, err
+	}
+	return &u, nil
+}
+
+func (p *Postgres) GetUser(ctx context.Context, login string) (*user.User, error) {
+	ctx, cancel := p.withTimeout(ctx)
+	defer cancel()
+
+	query := `SELECT ` + userColumns + ` FROM users WHERE login = $1`
+	row, err := p.db.QueryRowWithRetry(ctx, retry.Strategy{Attempts: p.cfg.Attempts, Delay: p.cfg.Delay, Backoff: p.cfg.Backoffs}, query, login)
+	if err != nil {
+		wbzlog.Logger.Error().Err(err).Msg("Failed to execute get user query")
+		return nil, err
+	}
+
+	u, err := scanUser(row)
+	if err == sql.ErrNoRows {
+		return nil, user.ErrNotFound
+	}
+	if err != nil {
 		wbzlog.Logger.Error().Err(err).Msg("Failed to scan user row")
 		return nil, err
 	}
+	return u, nil
+}
+
+func (p *Postgres) GetUserByID(ctx context.Context, id string) (*user.User, error) {
+	ctx, cancel := p.withTimeout(ctx)
+	defer cancel()
+
+	query := `SELECT ` + userColumns + ` FROM users WHERE id = $1`
+	row, err := p.db.QueryRowWithRetry(ctx, retry.Strategy{Attempts: p.cfg.Attempts, Delay: p.cfg.Delay, Backoff: p.cfg.Backoffs}, query, id)
+	if err != nil {
+		wbzlog.Logger.Error().Err(err).Msg("Failed to execute get user by id query")
+		return nil, err
+	}
 
+	u, err := scanUser(row)
 	if err == sql.ErrNoRows {
-		return nil, errors.New("user not found")
+		return nil, user.ErrNotFound
+	}
+	if err != nil {
+		wbzlog.Logger.Error().Err(err).Msg("Failed to scan user row")
+		return nil, err
 	}
+	return u, nil
+}
 
-	return &u, nil
+func (p *Postgres) GetUsers(ctx context.Context, opts user.ListOptions) (*user.Page, error) {
+	ctx, cancel := p.withTimeout(ctx)
+	defer cancel()
+
+	var conds []string
+	var args []any
+	add := func(cond string, arg any) {
+		args = append(args, arg)
+		conds = append(conds, fmt.Sprintf(cond, len(args)))
+	}
+	if opts.Login != "" {
+		add(`login ILIKE $%d ESCAPE '\'`, "%"+likeEscaper.Replace(opts.Login)+"%")
+	}
+	if opts.Role != "" {
+		add("role = $%d", opts.Role)
+	}
+	if opts.Disabled != nil {
+		if *opts.Disabled {
+			conds = append(conds, "disabled_at IS NOT NULL")
+		} else {
+			conds = append(conds, "disabled_at IS NULL")
+		}
+	}
+	if opts.ServiceAccount != nil {
+		add("service_account = $%d", *opts.ServiceAccount)
+	}
+
+	page := &user.Page{Users: []*user.User{}}
+	countQuery := `SELECT COUNT(*) FROM users` + whereClause(conds)
+	row, err := p.db.QueryRowWithRetry(ctx, retry.Strategy{Attempts: p.cfg.Attempts, Delay: p.cfg.Delay, Backoff: p.cfg.Backoffs}, countQuery, args...)
+	if err != nil {
+		wbzlog.Logger.Error().Err(err).Msg("Failed to execute count users query")
+		return nil, err
+	}
+	err = row.Scan(&page.Total)
+	if err != nil {
+		wbzlog.Logger.Error().Err(err).Msg("Failed to scan users count")
+		return nil, err
+	}
+
+	args = append(args, opts.Limit, opts.Offset)
+	query := fmt.Sprintf(`
+		SELECT %s
+		FROM users%s
+		ORDER BY login
+		LIMIT $%d OFFSET $%d
+	`, userColumns, whereClause(conds), len(args)-1, len(args))
+
+	rows, err := p.db.QueryWithRetry(ctx, retry.Strategy{Attempts: p.cfg.Attempts, Delay: p.cfg.Delay, Backoff: p.cfg.Backoffs}, query, args...)
+	if err != nil {
+		wbzlog.Logger.Error().Err(err).Msg("Failed to execute get users query")
+		return nil, err
+	}
+	defer func() {
+		err = rows.Close()
+		if err != nil {
+			wbzlog.Logger.Error().Err(err).Msg("Failed to close user rows")
+		}
+	}()
+
+	for rows.Next() {
+		u, err := scanUser(rows)
+		if err != nil {
+			wbzlog.Logger.Error().Err(err).Msg("Failed to scan user row")
+			return nil, err
+		}
+		page.Users = append(page.Users, u)
+	}
+	if err := rows.Err(); err != nil {
+		wbzlog.Logger.Error().Err(err).Msg("Failed to iterate user rows")
+		return nil, err
+	}
+	return page, nil
+}
+
+func insertUser(ctx context.Context, tx *sql.Tx, u *user.User) error {
+	_, err := tx.ExecContext(ctx, `
+		INSERT INTO users (id, login, password, created_at, role, service_account)
+		VALUES ($1, $2, $3, $4, $5, $6)
+	`, u.Id, u.Login, u.Password, u.CreatedAt, u.Role, u.ServiceAccount)
+	if isPgError(err, pgUniqueViolation) {
+		return user.ErrAlreadyExists
+	}
+	if err != nil {
+		wbzlog.Logger.Error().Err(err).Msg("Failed to execute insert user query")
+		return err
+	}
+	return nil
 }
 
-func (p *Postgres) SaveUser(user *user.User) error {
-	ctx := context.Background()
+func (p *Postgres) SaveUser(ctx context.Context, u *user.User) error {
+	ctx, cancel := p.withTimeout(ctx)
+	defer cancel()
 
 	query := `
-		INSERT INTO users (id, login, password, created_at, role)
-		VALUES ($1, $2, $3, $4, $5)
+		INSERT INTO users (id, login, password, created_at, role, service_account)
+		VALUES ($1, $2, $3, $4, $5, $6)
 	`
 
 	_, err := p.db.ExecWithRetry(ctx, retry.Strategy{Attempts: p.cfg.Attempts, Delay: p.cfg.Delay, Backoff: p.cfg.Backoffs}, query,
-		user.Id,
-		user.Login,
-		user.Password,
-		user.CreatedAt,
-		user.Role,
+		u.Id,
+		u.Login,
+		u.Password,
+		u.CreatedAt,
+		u.Role,
+		u.ServiceAccount,
 	)
 
+	if isPgError(err, pgUniqueViolation) {
+		return user.ErrAlreadyExists
+	}
 	if err != nil {
 		wbzlog.Logger.Error().Err(err).Msg("Failed to execute insert user query")
 		return err
 	}
 	return nil
 }
+
+// ChangeUserRole меняет роль пользователя, завершает его сессии и пишет изменение в журнал аудита
+func (p *Postgres) ChangeUserRole(ctx context.Context, id string, role user.Role, actorID, actorLogin string) (*user.User, error) {
+	var res *user.User
+	err := p.modifyUser(ctx, id, actorID, actorLogin, func(ctx context.Context, tx *sql.Tx, u *user.User) (*user.AuditEntry, error) {
+		if u.Role == role {
+			res = u
+			return nil, nil
+		}
+		if u.Role == user.Admin && !u.Disabled() {
+			if err := ensureOtherActiveAdmin(ctx, tx, u.Id); err != nil {
+				return nil, err
+			}
+		}
+		_, err := tx.ExecContext(ctx, `UPDATE users SET role = $2 WHERE id = $1`, u.Id, role)
+		if err != nil {
+			wbzlog.Logger.Error().Err(err).Msg("Failed to execute update user role query")
+			return nil, err
+		}
+		// роль записана в выданные токены: без отзыва сессий понижение вступило бы в силу
+		// только с истечением access токена
+		_, err = tx.ExecContext(ctx, revokeUserSessionsQuery, u.Id)
+		if err != nil {
+			wbzlog.Logger.Error().Err(err).Msg("Failed to revoke sessions of user with changed role")
+			return nil, err
+		}
+		old := string(u.Role)
+		u.Role = role
+		res = u
+		return &user.AuditEntry{Action: user.AuditRoleChanged, OldValue: &old, NewValue: strPtr(string(role))}, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+// SetUserDisabled отключает или включает учётную запись; повторный вызов с тем же состоянием ничего не меняет
+func (p *Postgres) SetUserDisabled(ctx context.Context, id string, disabled bool, actorID, actorLogin string) (*user.User, error) {
+	var res *user.User
+	err := p.modifyUser(ctx, id, actorID, actorLogin, func(ctx context.Context, tx *sql.Tx, u *user.User) (*user.AuditEntry, error) {
+		res = u
+		if u.Disabled() == disabled {
+			return nil, nil
+		}
+		action := user.AuditEnabled
+		if disabled {
+			action = user.AuditDisabled
+			if u.Role == user.Admin {
+				if err := ensureOtherActiveAdmin(ctx, tx, u.Id); err != nil {
+					return nil, err
+				}
+			}
+		}
+		err := tx.QueryRowContext(ctx, `
+			UPDATE users SET disabled_at = CASE WHEN $2 THEN now() END
+			WHERE id = $1
+			RETURNING disabled_at
+		`, u.Id, disabled).Scan(&u.DisabledAt)
+		if err != nil {
+			wbzlog.Logger.Error().Err(err).Msg("Failed to execute update user status query")
+			return nil, err
+		}
+		if disabled {
+			// отключение сразу завершает все сессии: их access токены тоже перестают приниматься
+			_, err = tx.ExecContext(ctx, revokeUserSessionsQuery, u.Id)
+			if err != nil {
+				wbzlog.Logger.Error().Err(err).Msg("Failed to revoke sessions of disabled user")
+				return nil, err
+			}
+		}
+		return &user.AuditEntry{Action: action}, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+// ResetUserPassword заменяет хэш пароля и завершает сессии пользователя; в аудит попадает только факт сброса
+func (p *Postgres) ResetUserPassword(ctx context.Context, id string, password []byte, actorID, actorLogin string) error {
+	return p.modifyUser(ctx, id, actorID, actorLogin, func(ctx context.Context, tx *sql.Tx, u *user.User) (*user.AuditEntry, error) {
+		if err := setPassword(ctx, tx, u, password); err != nil {
+			return nil, err
+		}
+		return &user.AuditEntry{Action: user.AuditPasswordReset}, nil
+	})
+}
+
+func (p *Postgres) DeleteUser(ctx context.Context, id string, actorID, actorLogin string) error {
+	return p.modifyUser(ctx, id, actorID, actorLogin, func(ctx context.Context, tx *sql.Tx, u *user.User) (*user.AuditEntry, error) {
+		if u.Role == user.Admin && !u.Disabled() {
+			if err := ensureOtherActiveAdmin(ctx, tx, u.Id); err != nil {
+				return nil, err
+			}
+		}
+		_, err := tx.ExecContext(ctx, `DELETE FROM users WHERE id = $1`, u.Id)
+		if isPgError(err, pgForeignKeyViolation) {
+			return nil, user.ErrReferenced
+		}
+		if err != nil {
+			wbzlog.Logger.Error().Err(err).Msg("Failed to execute delete user query")
+			return nil, err
+		}
+		return &user.AuditEntry{Action: user.AuditDeleted, OldValue: strPtr(string(u.Role))}, nil
+	})
+}
+
+func (p *Postgres) GetUserAudit(ctx context.Context, id string) ([]*user.AuditEntry, error) {
+	ctx, cancel := p.withTimeout(ctx)
+	defer cancel()
+
+	query := `
+		SELECT id, user_id, user_login, action, old_value, new_value, actor_id, actor_login, changed_at
+		FROM user_audit
+		WHERE user_id = $1
+		ORDER BY changed_at, id
+	`
+	rows, err := p.db.QueryWithRetry(ctx, retry.Strategy{Attempts: p.cfg.Attempts, Delay: p.cfg.Delay, Backoff: p.cfg.Backoffs}, query, id)
+	if err != nil {
+		wbzlog.Logger.Error().Err(err).Msg("Failed to execute get user audit query")
+		return nil, err
+	}
+	defer func() {
+		err = rows.Close()
+		if err != nil {
+			wbzlog.Logger.Error().Err(err).Msg("Failed to close user audit rows")
+		}
+	}()
+
+	res := []*user.AuditEntry{}
+	for rows.Next() {
+		var e user.AuditEntry
+		err := rows.Scan(&e.ID, &e.UserID, &e.UserLogin, &e.Action, &e.OldValue, &e.NewValue, &e.ActorID, &e.ActorLogin, &e.ChangedAt)
+		if err != nil {
+			wbzlog.Logger.Error().Err(err).Msg("Failed to scan user audit row")
+			return nil, err
+		}
+		res = append(res, &e)
+	}
+	if err := rows.Err(); err != nil {
+		wbzlog.Logger.Error().Err(err).Msg("Failed to iterate user audit rows")
+		return nil, err
+	}
+	return res, nil
+}
+
+// modifyUser выполняет изменение пользователя в транзакции и пишет аудит, если fn вернула запись.
+// Таблица users блокируется от параллельных изменений, чтобы проверка «последнего администратора»
+// не давала двум запросам одновременно разжаловать друг друга.
+func (p *Postgres) modifyUser(ctx context.Context, id, actorID, actorLogin string, fn func(ctx context.Context, tx *sql.Tx, u *user.User) (*user.AuditEntry, error)) error {
+	ctx, cancel := p.withTimeout(ctx)
+	defer cancel()
+
+	tx, err := p.db.Master.BeginTx(ctx, nil)
+	if err != nil {
+		wbzlog.Logger.Error().Err(err).Msg("cant start transaction in modify user")
+		return err
+	}
+	defer func() {
+		_ = tx.Rollback()
+	}()
+
+	_, err = tx.ExecContext(ctx, `LOCK TABLE users IN SHARE ROW EXCLUSIVE MODE`)
+	if err != nil {
+		wbzlog.Logger.Error().Err(err).Msg("Failed to lock users table")
+		return err
+	}
+	u, err := scanUser(tx.QueryRowContext(ctx, `SELECT `+userColumns+` FROM users WHERE id = $1`, id))
+	if err == sql.ErrNoRows {
+		return user.ErrNotFound
+	}
+	if err != nil {
+		wbzlog.Logger.Error().Err(err).Msg("Failed to scan user row")
+		return err
+	}
+
+	entry, err := fn(ctx, tx, u)
+	if err != nil {
+		return err
+	}
+	if entry != nil {
+		_, err = tx.ExecContext(ctx, `
+			INSERT INTO user_audit (user_id, user_login, action, old_value, new_value, actor_id, actor_login)
+			VALUES ($1, $2, $3, $4, $5, $6, $7)
+		`, u.Id, u.Login, entry.Action, entry.OldValue, entry.NewValue, actorID, actorLogin)
+		if err != nil {
+			wbzlog.Logger.Error().Err(err).Msg("Failed to insert user audit record")
+			return err
+		}
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		wbzlog.Logger.Error().Err(err).Msg("Failed to commit transaction")
+		return err
+	}
+	return nil
+}
+
+// ensureOtherActiveAdmin проверяет, что помимо id останется хотя бы один активный администратор
+func ensureOtherActiveAdmin(ctx context.Context, tx *sql.Tx, id uuid.UUID) error {
+	var exists bool
+	err := tx.QueryRowContext(ctx, `
+		SELECT EXISTS(SELECT 1 FROM users WHERE role = $1 AND disabled_at IS NULL AND id <> $2)
+	`, user.Admin, id).Scan(&exists)
+	if err != nil {
+		wbzlog.Logger.Error().Err(err).Msg("Failed to check other active admins")
+		return err
+	}
+	if !exists {
+		return user.ErrLastAdmin
+	}
+	return nil
+}
+
+func strPtr(s string) *string {
+	return &s
+}
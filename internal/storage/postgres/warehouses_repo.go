@@ -0,0 +1,417 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+
+	"warehousecontrol/internal/domain/warehouse"
+
+	"github.com/google/uuid"
+	"github.com/wb-go/wbf/retry"
+	wbzlog "github.com/wb-go/wbf/zlog"
+)
+
+func (p *Postgres) CreateWarehouse(ctx context.Context, w *warehouse.Warehouse) error {
+	ctx, cancel := p.withTimeout(ctx)
+	defer cancel()
+	defer p.replicas.wrote(ctx)
+
+	query := `
+		INSERT INTO warehouses (id, name, address)
+		VALUES ($1, $2, $3)
+	`
+
+	_, err := p.db.ExecWithRetry(ctx, retry.Strategy{Attempts: p.cfg.Attempts, Delay: p.cfg.Delay, Backoff: p.cfg.Backoffs}, query,
+		w.ID,
+		w.Name,
+		w.Address,
+	)
+	if isPgError(err, pgUniqueViolation) {
+		return warehouse.ErrWarehouseExists
+	}
+	if err != nil {
+		wbzlog.Logger.Error().Err(err).Msg("Failed to execute create warehouse query")
+		return err
+	}
+	return nil
+}
+
+func (p *Postgres) GetWarehouses(ctx context.Context) ([]*warehouse.Warehouse, error) {
+	ctx, cancel := p.withTimeout(ctx)
+	defer cancel()
+	db := p.replicaReader(ctx)
+
+	query := `
+		SELECT id, name, address
+		FROM warehouses
+		ORDER BY name
+	`
+
+	rows, err := db.query(ctx, query)
+	if err != nil {
+		wbzlog.Logger.Error().Err(err).Msg("Failed to execute get warehouses query")
+		return nil, err
+	}
+	defer func() {
+		err = rows.Close()
+		if err != nil {
+			wbzlog.Logger.Error().Err(err).Msg("Failed to close warehouse rows")
+		}
+	}()
+
+	warehouses := []*warehouse.Warehouse{}
+	for rows.Next() {
+		var w warehouse.Warehouse
+		err := rows.Scan(
+			&w.ID,
+			&w.Name,
+			&w.Address,
+		)
+		if err != nil {
+			wbzlog.Logger.Error().Err(err).Msg("Failed to scan warehouse row")
+			return nil, err
+		}
+		warehouses = append(warehouses, &w)
+	}
+
+	return warehouses, nil
+}
+
+// GetWarehouse возвращает склад вместе с его зонами и ячейками
+func (p *Postgres) GetWarehouse(ctx context.Context, id string) (*warehouse.Warehouse, error) {
+	ctx, cancel := p.withTimeout(ctx)
+	defer cancel()
+
+	query := `
+		SELECT id, name, address
+		FROM warehouses
+		WHERE id = $1
+	`
+
+	var w warehouse.Warehouse
+	row, err := p.db.QueryRowWithRetry(ctx, retry.Strategy{Attempts: p.cfg.Attempts, Delay: p.cfg.Delay, Backoff: p.cfg.Backoffs}, query, id)
+	if err != nil {
+		wbzlog.Logger.Error().Err(err).Msg("Failed to execute get warehouse query")
+		return nil, err
+	}
+
+	err = row.Scan(
+		&w.ID,
+		&w.Name,
+		&w.Address,
+	)
+	if err != nil && err != sql.ErrNoRows {
+		wbzlog.Logger.Error().Err(err).Msg("Failed to scan warehouse row")
+		return nil, err
+	}
+	if err == sql.ErrNoRows {
+		return nil, warehouse.ErrWarehouseNotFound
+	}
+
+	layoutQuery := `
+		SELECT z.id, z.name, l.id, l.code
+		FROM zones z
+		LEFT JOIN locations l ON l.zone_id = z.id
+		WHERE z.warehouse_id = $1
+		ORDER BY z.name, l.code
+	`
+
+	rows, err := p.db.QueryWithRetry(ctx, retry.Strategy{Attempts: p.cfg.Attempts, Delay: p.cfg.Delay, Backoff: p.cfg.Backoffs}, layoutQuery, id)
+	if err != nil {
+		wbzlog.Logger.Error().Err(err).Msg("Failed to execute get warehouse layout query")
+		return nil, err
+	}
+	defer func() {
+		err = rows.Close()
+		if err != nil {
+			wbzlog.Logger.Error().Err(err).Msg("Failed to close warehouse layout rows")
+		}
+	}()
+
+	zones := map[uuid.UUID]*warehouse.Zone{}
+	for rows.Next() {
+		var (
+			zoneID       uuid.UUID
+			zoneName     string
+			locationID   *uuid.UUID
+			locationCode sql.NullString
+		)
+		err := rows.Scan(&zoneID, &zoneName, &locationID, &locationCode)
+		if err != nil {
+			wbzlog.Logger.Error().Err(err).Msg("Failed to scan warehouse layout row")
+			return nil, err
+		}
+
+		z, ok := zones[zoneID]
+		if !ok {
+			z = &warehouse.Zone{ID: zoneID, WarehouseID: w.ID, Name: zoneName}
+			zones[zoneID] = z
+			w.Zones = append(w.Zones, z)
+		}
+		if locationID != nil {
+			z.Locations = append(z.Locations, &warehouse.Location{ID: *locationID, ZoneID: zoneID, Code: locationCode.String})
+		}
+	}
+
+	return &w, nil
+}
+
+func (p *Postgres) PutWarehouse(ctx context.Context, w *warehouse.Warehouse) error {
+	ctx, cancel := p.withTimeout(ctx)
+	defer cancel()
+	defer p.replicas.wrote(ctx)
+
+	query := `
+		UPDATE warehouses
+		SET name = $2, address = $3
+		WHERE id = $1
+	`
+
+	_, err := p.db.ExecWithRetry(ctx, retry.Strategy{Attempts: p.cfg.Attempts, Delay: p.cfg.Delay, Backoff: p.cfg.Backoffs}, query,
+		w.ID,
+		w.Name,
+		w.Address,
+	)
+	if isPgError(err, pgUniqueViolation) {
+		return warehouse.ErrWarehouseExists
+	}
+	if err != nil {
+		wbzlog.Logger.Error().Err(err).Msg("Failed to execute update warehouse query")
+		return err
+	}
+	return nil
+}
+
+func (p *Postgres) DeleteWarehouse(ctx context.Context, id string) error {
+	ctx, cancel := p.withTimeout(ctx)
+	defer cancel()
+	defer p.replicas.wrote(ctx)
+
+	query := `
+		DELETE FROM warehouses
+		WHERE id = $1
+	`
+
+	res, err := p.db.ExecWithRetry(ctx, retry.Strategy{Attempts: p.cfg.Attempts, Delay: p.cfg.Delay, Backoff: p.cfg.Backoffs}, query, id)
+	if isPgError(err, pgForeignKeyViolation) {
+		return warehouse.ErrWarehouseNotEmpty
+	}
+	if err != nil {
+		wbzlog.Logger.Error().Err(err).Msg("Failed to execute delete warehouse query")
+		return err
+	}
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if affected == 0 {
+		return warehouse.ErrWarehouseNotFound
+	}
+	return nil
+}
+
+func (p *Postgres) CreateZone(ctx context.Context, z *warehouse.Zone) error {
+	ctx, cancel := p.withTimeout(ctx)
+	defer cancel()
+	defer p.replicas.wrote(ctx)
+
+	query := `
+		INSERT INTO zones (id, warehouse_id, name)
+		VALUES ($1, $2, $3)
+	`
+
+	_, err := p.db.ExecWithRetry(ctx, retry.Strategy{Attempts: p.cfg.Attempts, Delay: p.cfg.Delay, Backoff: p.cfg.Backoffs}, query,
+		z.ID,
+		z.WarehouseID,
+		z.Name,
+	)
+	if isPgError(err, pgUniqueViolation) {
+		return warehouse.ErrZoneExists
+	}
+	if err != nil {
+		wbzlog.Logger.Error().Err(err).Msg("Failed to execute create zone query")
+		return err
+	}
+	return nil
+}
+
+func (p *Postgres) GetZone(ctx context.Context, id string) (*warehouse.Zone, error) {
+	ctx, cancel := p.withTimeout(ctx)
+	defer cancel()
+
+	query := `
+		SELECT id, warehouse_id, name
+		FROM zones
+		WHERE id = $1
+	`
+
+	var z warehouse.Zone
+	row, err := p.db.QueryRowWithRetry(ctx, retry.Strategy{Attempts: p.cfg.Attempts, Delay: p.cfg.Delay, Backoff: p.cfg.Backoffs}, query, id)
+	if err != nil {
+		wbzlog.Logger.Error().Err(err).Msg("Failed to execute get zone query")
+		return nil, err
+	}
+
+	err = row.Scan(
+		&z.ID,
+		&z.WarehouseID,
+		&z.Name,
+	)
+	if err != nil && err != sql.ErrNoRows {
+		wbzlog.Logger.Error().Err(err).Msg("Failed to scan zone row")
+		return nil, err
+	}
+	if err == sql.ErrNoRows {
+		return nil, warehouse.ErrZoneNotFound
+	}
+	return &z, nil
+}
+
+func (p *Postgres) PutZone(ctx context.Context, z *warehouse.Zone) error {
+	ctx, cancel := p.withTimeout(ctx)
+	defer cancel()
+	defer p.replicas.wrote(ctx)
+
+	query := `
+		UPDATE zones
+		SET name = $2
+		WHERE id = $1
+	`
+
+	_, err := p.db.ExecWithRetry(ctx, retry.Strategy{Attempts: p.cfg.Attempts, Delay: p.cfg.Delay, Backoff: p.cfg.Backoffs}, query,
+		z.ID,
+		z.Name,
+	)
+	if isPgError(err, pgUniqueViolation) {
+		return warehouse.ErrZoneExists
+	}
+	if err != nil {
+		wbzlog.Logger.Error().Err(err).Msg("Failed to execute update zone query")
+		return err
+	}
+	return nil
+}
+
+func (p *Postgres) DeleteZone(ctx context.Context, id string) error {
+	ctx, cancel := p.withTimeout(ctx)
+	defer cancel()
+	defer p.replicas.wrote(ctx)
+
+	query := `
+		DELETE FROM zones
+		WHERE id = $1
+	`
+
+	_, err := p.db.ExecWithRetry(ctx, retry.Strategy{Attempts: p.cfg.Attempts, Delay: p.cfg.Delay, Backoff: p.cfg.Backoffs}, query, id)
+	if isPgError(err, pgForeignKeyViolation) {
+		return warehouse.ErrLocationNotEmpty
+	}
+	if err != nil {
+		wbzlog.Logger.Error().Err(err).Msg("Failed to execute delete zone query")
+		return err
+	}
+	return nil
+}
+
+func (p *Postgres) CreateLocation(ctx context.Context, l *warehouse.Location) error {
+	ctx, cancel := p.withTimeout(ctx)
+	defer cancel()
+	defer p.replicas.wrote(ctx)
+
+	query := `
+		INSERT INTO locations (id, zone_id, code)
+		VALUES ($1, $2, $3)
+	`
+
+	_, err := p.db.ExecWithRetry(ctx, retry.Strategy{Attempts: p.cfg.Attempts, Delay: p.cfg.Delay, Backoff: p.cfg.Backoffs}, query,
+		l.ID,
+		l.ZoneID,
+		l.Code,
+	)
+	if isPgError(err, pgUniqueViolation) {
+		return warehouse.ErrLocationExists
+	}
+	if err != nil {
+		wbzlog.Logger.Error().Err(err).Msg("Failed to execute create location query")
+		return err
+	}
+	return nil
+}
+
+func (p *Postgres) GetLocation(ctx context.Context, id string) (*warehouse.Location, error) {
+	ctx, cancel := p.withTimeout(ctx)
+	defer cancel()
+
+	query := `
+		SELECT id, zone_id, code
+		FROM locations
+		WHERE id = $1
+	`
+
+	var l warehouse.Location
+	row, err := p.db.QueryRowWithRetry(ctx, retry.Strategy{Attempts: p.cfg.Attempts, Delay: p.cfg.Delay, Backoff: p.cfg.Backoffs}, query, id)
+	if err != nil {
+		wbzlog.Logger.Error().Err(err).Msg("Failed to execute get location query")
+		return nil, err
+	}
+
+	err = row.Scan(
+		&l.ID,
+		&l.ZoneID,
+		&l.Code,
+	)
+	if err != nil && err != sql.ErrNoRows {
+		wbzlog.Logger.Error().Err(err).Msg("Failed to scan location row")
+		return nil, err
+	}
+	if err == sql.ErrNoRows {
+		return nil, warehouse.ErrLocationNotFound
+	}
+	return &l, nil
+}
+
+func (p *Postgres) PutLocation(ctx context.Context, l *warehouse.Location) error {
+	ctx, cancel := p.withTimeout(ctx)
+	defer cancel()
+	defer p.replicas.wrote(ctx)
+
+	query := `
+		UPDATE locations
+		SET code = $2
+		WHERE id = $1
+	`
+
+	_, err := p.db.ExecWithRetry(ctx, retry.Strategy{Attempts: p.cfg.Attempts, Delay: p.cfg.Delay, Backoff: p.cfg.Backoffs}, query,
+		l.ID,
+		l.Code,
+	)
+	if isPgError(err, pgUniqueViolation) {
+		return warehouse.ErrLocationExists
+	}
+	if err != nil {
+		wbzlog.Logger.Error().Err(err).Msg("Failed to execute update location query")
+		return err
+	}
+	return nil
+}
+
+func (p *Postgres) DeleteLocation(ctx context.Context, id string) error {
+	ctx, cancel := p.withTimeout(ctx)
+	defer cancel()
+	defer p.replicas.wrote(ctx)
+
+	query := `
+		DELETE FROM locations
+		WHERE id = $1
+	`
+
+	_, err := p.db.ExecWithRetry(ctx, retry.Strategy{Attempts: p.cfg.Attempts, Delay: p.cfg.Delay, Backoff: p.cfg.Backoffs}, query, id)
+	if isPgError(err, pgForeignKeyViolation) {
+		return warehouse.ErrLocationNotEmpty
+	}
+	if err != nil {
+		wbzlog.Logger.Error().Err(err).Msg("Failed to execute delete location query")
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,7 @@
+package dto
+
+// ErrorResponse — единый формат ошибки API; Code не зависит от текста и подходит для обработки на клиенте
+type ErrorResponse struct {
+	Error string `json:"error"`
+	Code  string `json:"code"`
+}
@@ -11,3 +11,57 @@ type ItemUpdateRequest struct {
 	Count int     `json:"count" binding:"required"`
 	Price float64 `json:"price" binding:"required"`
 }
+
+type ItemListQuery struct {
+	Limit     int      `form:"limit" binding:"omitempty,min=1"`
+	Offset    int      `form:"offset" binding:"omitempty,min=0"`
+	Cursor    string   `form:"cursor"`
+	Sort      string   `form:"sort" binding:"omitempty,oneof=name count price"`
+	Order     string   `form:"order" binding:"omitempty,oneof=asc desc"`
+	Name      string   `form:"name"`
+	MinCount  *int     `form:"min_count"`
+	MaxCount  *int     `form:"max_count"`
+	MinPrice  *float64 `form:"min_price"`
+	MaxPrice  *float64 `form:"max_price"`
+	ZeroStock *bool    `form:"zero_stock"`
+	AsOf      string   `form:"as_of"`
+}
+
+type ItemStockRequest struct {
+	LocationID string `json:"location_id" binding:"required"`
+	Quantity   int    `json:"quantity" binding:"min=0"`
+}
+
+type ItemMovementRequest struct {
+	LocationID string `json:"location_id"`
+	Quantity   int    `json:"quantity" binding:"required,gt=0"`
+	Reason     string `json:"reason"`
+	Reference  string `json:"reference"`
+}
+
+type ItemTransferRequest struct {
+	FromLocationID string `json:"from_location_id" binding:"required"`
+	ToLocationID   string `json:"to_location_id" binding:"required"`
+	Quantity       int    `json:"quantity" binding:"required,gt=0"`
+	Reason         string `json:"reason"`
+	Reference      string `json:"reference"`
+}
+
+// ItemRevertRequest — запись истории и её снимок (old|new), из которого восстанавливается товар
+type ItemRevertRequest struct {
+	HistoryID string `json:"history_id" binding:"required"`
+	Snapshot  string `json:"snapshot" binding:"omitempty,oneof=old new"`
+}
+
+type ItemAdjustRequest struct {
+	Delta      int    `json:"delta" binding:"required"`
+	LocationID string `json:"location_id"`
+	Reason     string `json:"reason"`
+	Reference  string `json:"reference"`
+	Force      bool   `json:"force"`
+}
+
+type ItemAdjustResponse struct {
+	ID    string `json:"id"`
+	Count int    `json:"count"`
+}
@@ -1,27 +1,260 @@
 package dto
 
+import "time"
+
 type UserLoginRequest struct {
 	Login    string `json:"login" binding:"required"`
 	Password string `json:"password" binding:"required"`
 }
 
+// UserRegistrationRequest — регистрация по приглашению; роль задаётся приглашением
 type UserRegistrationRequest struct {
-	Login    string `json:"login" binding:"required"`
-	Password string `json:"password" binding:"required"`
-	Role     string `json:"role" binding:"required"`
+	Login       string `json:"login" binding:"required"`
+	Password    string `json:"password" binding:"required"`
+	InviteToken string `json:"invite_token" binding:"required"`
+}
+
+type BootstrapRequest struct {
+	SetupToken string `json:"setup_token" binding:"required"`
+	Login      string `json:"login" binding:"required"`
+	Password   string `json:"password" binding:"required"`
+}
+
+type InvitationCreateRequest struct {
+	Role string `json:"role" binding:"required"`
+	// TTLHours — срок действия в часах, 0 — по умолчанию
+	TTLHours int `json:"ttl_hours" binding:"min=0"`
+}
+
+// InvitationResponse — приглашение; Token заполнен только в ответе на создание
+type InvitationResponse struct {
+	ID        string     `json:"id"`
+	Role      string     `json:"role"`
+	Token     string     `json:"token,omitempty"`
+	CreatedBy string     `json:"created_by"`
+	CreatedAt time.Time  `json:"created_at"`
+	ExpiresAt time.Time  `json:"expires_at"`
+	UsedAt    *time.Time `json:"used_at,omitempty"`
+	UsedBy    string     `json:"used_by,omitempty"`
 }
 
 type TokenRefreshRequest struct {
 	RefreshToken string `json:"refresh_token" binding:"required"`
 }
 
+// LogoutRequest — выход по refresh токену; all отзывает токены на всех устройствах
+type LogoutRequest struct {
+	RefreshToken string `json:"refresh_token" binding:"required"`
+	All          bool   `json:"all"`
+}
+
 type UserResponse struct {
-	ID    string `json:"id"`
-	Login string `json:"login"`
-	Role  string `json:"role"`
+	ID         string     `json:"id"`
+	Login      string     `json:"login"`
+	Role       string     `json:"role"`
+	CreatedAt  time.Time  `json:"created_at"`
+	Disabled   bool       `json:"disabled"`
+	DisabledAt *time.Time `json:"disabled_at,omitempty"`
+	// ServiceAccount — учётная запись интеграции, работающая по API ключам
+	ServiceAccount bool `json:"service_account"`
+}
+
+// UserListQuery — параметры списка пользователей; login ищет по подстроке
+type UserListQuery struct {
+	Limit          int    `form:"limit" binding:"omitempty,min=1"`
+	Offset         int    `form:"offset" binding:"omitempty,min=0"`
+	Login          string `form:"login"`
+	Role           string `form:"role"`
+	Disabled       *bool  `form:"disabled"`
+	ServiceAccount *bool  `form:"service_account"`
+}
+
+type UserListResponse struct {
+	Users []UserResponse `json:"users"`
+	Total int            `json:"total"`
+}
+
+type UserRoleRequest struct {
+	Role string `json:"role" binding:"required"`
+}
+
+type UserPasswordResetRequest struct {
+	Password string `json:"password" binding:"required"`
+}
+
+type UserAuditResponse struct {
+	ID         string    `json:"id"`
+	UserID     string    `json:"user_id"`
+	UserLogin  string    `json:"user_login"`
+	Action     string    `json:"action"`
+	OldValue   *string   `json:"old_value,omitempty"`
+	NewValue   *string   `json:"new_value,omitempty"`
+	ActorID    *string   `json:"actor_id,omitempty"`
+	ActorLogin string    `json:"actor_login"`
+	ChangedAt  time.Time `json:"changed_at"`
 }
 
 type JWTResponse struct {
 	AccessToken  string `json:"access_token"`
 	RefreshToken string `json:"refresh_token"`
+	SessionID    string `json:"session_id"`
+}
+
+type SessionResponse struct {
+	ID         string    `json:"id"`
+	UserAgent  string    `json:"user_agent"`
+	IP         string    `json:"ip"`
+	CreatedAt  time.Time `json:"created_at"`
+	LastUsedAt time.Time `json:"last_used_at"`
+	ExpiresAt  time.Time `json:"expires_at"`
+	// Current — сессия, которой принадлежит токен запроса
+	Current bool `json:"current"`
+}
+
+type PasswordChangeRequest struct {
+	CurrentPassword string `json:"current_password" binding:"required"`
+	NewPassword     string `json:"new_password" binding:"required"`
+}
+
+type PasswordResetRequest struct {
+	Token       string `json:"token" binding:"required"`
+	NewPassword string `json:"new_password" binding:"required"`
+}
+
+// PasswordResetTokenResponse — токен сброса возвращается только при выпуске
+type PasswordResetTokenResponse struct {
+	Token     string    `json:"token"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+// LoginResponse — пара токенов либо, при mfa_required, токен второго шага входа
+type LoginResponse struct {
+	AccessToken  string `json:"access_token,omitempty"`
+	RefreshToken string `json:"refresh_token,omitempty"`
+	SessionID    string `json:"session_id,omitempty"`
+	MFARequired  bool   `json:"mfa_required"`
+	MFAToken     string `json:"mfa_token,omitempty"`
+	// MFAEnrollment — второй фактор обязателен, но не настроен: его нужно подключить при входе
+	MFAEnrollment bool       `json:"mfa_enrollment,omitempty"`
+	MFAExpiresAt  *time.Time `json:"mfa_expires_at,omitempty"`
+}
+
+type MFALoginRequest struct {
+	MFAToken string `json:"mfa_token" binding:"required"`
+	Code     string `json:"code" binding:"required"`
+}
+
+// MFALoginResponse — токены сессии; коды восстановления есть, только если второй фактор
+// был подключён на этом шаге
+type MFALoginResponse struct {
+	AccessToken   string   `json:"access_token"`
+	RefreshToken  string   `json:"refresh_token"`
+	SessionID     string   `json:"session_id"`
+	RecoveryCodes []string `json:"recovery_codes,omitempty"`
+}
+
+type MFASetupRequest struct {
+	MFAToken string `json:"mfa_token" binding:"required"`
+}
+
+type TOTPCodeRequest struct {
+	Code string `json:"code" binding:"required"`
+}
+
+type TOTPSetupResponse struct {
+	Secret     string `json:"secret"`
+	OtpauthURI string `json:"otpauth_uri"`
+}
+
+type TOTPStatusResponse struct {
+	Enabled           bool `json:"enabled"`
+	Required          bool `json:"required"`
+	RecoveryCodesLeft int  `json:"recovery_codes_left"`
+}
+
+type RecoveryCodesResponse struct {
+	RecoveryCodes []string `json:"recovery_codes"`
+}
+
+type ServiceAccountCreateRequest struct {
+	Login string `json:"login" binding:"required"`
+	Role  string `json:"role" binding:"required"`
+}
+
+type APIKeyCreateRequest struct {
+	Name   string   `json:"name" binding:"required"`
+	Scopes []string `json:"scopes" binding:"required,min=1"`
+	// TTLDays — срок действия в днях, 0 — бессрочный ключ
+	TTLDays int `json:"ttl_days" binding:"min=0"`
+}
+
+type APIKeyRotateRequest struct {
+	// TTLDays — срок действия нового ключа в днях, 0 — бессрочный ключ
+	TTLDays int `json:"ttl_days" binding:"min=0"`
+	// GraceHours — сколько часов продолжает действовать старый ключ, 0 — отзывается сразу
+	GraceHours int `json:"grace_hours" binding:"min=0"`
+}
+
+// APIKeyResponse — API ключ; Key заполнен только в ответах на создание и ротацию
+type APIKeyResponse struct {
+	ID         string     `json:"id"`
+	Name       string     `json:"name"`
+	Prefix     string     `json:"prefix"`
+	Key        string     `json:"key,omitempty"`
+	Scopes     []string   `json:"scopes"`
+	Active     bool       `json:"active"`
+	CreatedAt  time.Time  `json:"created_at"`
+	ExpiresAt  *time.Time `json:"expires_at,omitempty"`
+	LastUsedAt *time.Time `json:"last_used_at,omitempty"`
+	RevokedAt  *time.Time `json:"revoked_at,omitempty"`
+}
+
+type RoleCreateRequest struct {
+	Name        string   `json:"name" binding:"required"`
+	Description string   `json:"description"`
+	Permissions []string `json:"permissions" binding:"required,min=1"`
+}
+
+type RoleUpdateRequest struct {
+	Description string   `json:"description"`
+	Permissions []string `json:"permissions" binding:"required,min=1"`
+}
+
+// RoleResponse — роль с правами; version растёт при каждом изменении прав
+type RoleResponse struct {
+	Name        string    `json:"name"`
+	Description string    `json:"description"`
+	Permissions []string  `json:"permissions"`
+	BuiltIn     bool      `json:"built_in"`
+	Version     int       `json:"version"`
+	CreatedAt   time.Time `json:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at"`
+}
+
+// JWKSResponse — набор открытых ключей подписи токенов (RFC 7517)
+type JWKSResponse struct {
+	Keys []JWK `json:"keys"`
+}
+
+type JWK struct {
+	KeyType   string `json:"kty"`
+	KeyID     string `json:"kid"`
+	Use       string `json:"use"`
+	Algorithm string `json:"alg"`
+	N         string `json:"n,omitempty"`
+	E         string `json:"e,omitempty"`
+	Curve     string `json:"crv,omitempty"`
+	X         string `json:"x,omitempty"`
+}
+
+// SSOLoginResponse — ссылка на страницу входа провайдера; вернуться с неё нужно до expires_at
+type SSOLoginResponse struct {
+	AuthorizationURL string    `json:"authorization_url"`
+	ExpiresAt        time.Time `json:"expires_at"`
+}
+
+// SSOCallbackRequest — параметры code и state, с которыми провайдер вернул пользователя на redirect_url
+type SSOCallbackRequest struct {
+	Code  string `json:"code" binding:"required"`
+	State string `json:"state" binding:"required"`
 }
@@ -0,0 +1,14 @@
+package dto
+
+type WarehouseRequest struct {
+	Name    string `json:"name" binding:"required"`
+	Address string `json:"address"`
+}
+
+type ZoneRequest struct {
+	Name string `json:"name" binding:"required"`
+}
+
+type LocationRequest struct {
+	Code string `json:"code" binding:"required"`
+}
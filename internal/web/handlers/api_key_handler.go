@@ -0,0 +1,175 @@
+package handlers
+
+import (
+	"net/http"
+	"time"
+
+	"warehousecontrol/internal/domain/user"
+	"warehousecontrol/internal/web/dto"
+
+	wbgin "github.com/wb-go/wbf/ginext"
+)
+
+// CreateServiceAccount
+// @Summary Create service account
+// @Description Create a user for machine integrations (users.manage). Service accounts have no password and cannot log in; they authenticate with API keys. The role limits what their keys can do
+// @Tags service-accounts
+// @Accept json
+// @Produce json
+// @Param body body dto.ServiceAccountCreateRequest true "Login and role"
+// @Success 201 {object} dto.UserResponse
+// @Failure 400 {object} dto.ErrorResponse
+// @Failure 409 {object} dto.ErrorResponse "Login taken"
+// @Failure 422 {object} dto.ErrorResponse
+// @Security BearerAuth
+// @Router /api/service-accounts [post]
+func (h *UserHandler) CreateServiceAccount(ctx *wbgin.Context) {
+	var req dto.ServiceAccountCreateRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		badRequest(ctx, err)
+		return
+	}
+	u, err := h.Service.CreateServiceAccount(ctx.Request.Context(), req.Login, req.Role)
+	if err != nil {
+		RespondError(ctx, err)
+		return
+	}
+	ctx.JSON(http.StatusCreated, userResponse(u))
+}
+
+// CreateAPIKey
+// @Summary Create API key
+// @Description Issue an API key for a service account (users.manage). The key is shown only in this response; send it as "Authorization: Bearer wck_...". Scopes: items:read, items:write, warehouses:read, warehouses:write, history:read; a write scope includes read
+// @Tags service-accounts
+// @Accept json
+// @Produce json
+// @Param id path string true "Service account UUID"
+// @Param body body dto.APIKeyCreateRequest true "Name, scopes and TTL"
+// @Success 201 {object} dto.APIKeyResponse
+// @Failure 400 {object} dto.ErrorResponse
+// @Failure 404 {object} dto.ErrorResponse
+// @Failure 409 {object} dto.ErrorResponse "Not a service account"
+// @Failure 422 {object} dto.ErrorResponse
+// @Security BearerAuth
+// @Router /api/service-accounts/{id}/keys [post]
+func (h *UserHandler) CreateAPIKey(ctx *wbgin.Context) {
+	var req dto.APIKeyCreateRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		badRequest(ctx, err)
+		return
+	}
+	actorID, actorLogin, ok := userFromContext(ctx)
+	if !ok {
+		return
+	}
+	ttl := time.Duration(req.TTLDays) * 24 * time.Hour
+	k, key, err := h.Service.CreateAPIKey(ctx.Request.Context(), ctx.Param("id"), req.Name, req.Scopes, ttl, actorID, actorLogin)
+	if err != nil {
+		RespondError(ctx, err)
+		return
+	}
+	res := apiKeyResponse(k)
+	res.Key = key
+	ctx.JSON(http.StatusCreated, res)
+}
+
+// GetAPIKeys
+// @Summary List API keys
+// @Description List API keys of a service account, including expired and revoked ones, newest first (users.manage)
+// @Tags service-accounts
+// @Produce json
+// @Param id path string true "Service account UUID"
+// @Success 200 {array} dto.APIKeyResponse
+// @Failure 400 {object} dto.ErrorResponse
+// @Failure 404 {object} dto.ErrorResponse
+// @Security BearerAuth
+// @Router /api/service-accounts/{id}/keys [get]
+func (h *UserHandler) GetAPIKeys(ctx *wbgin.Context) {
+	keys, err := h.Service.GetAPIKeys(ctx.Request.Context(), ctx.Param("id"))
+	if err != nil {
+		RespondError(ctx, err)
+		return
+	}
+	res := make([]dto.APIKeyResponse, 0, len(keys))
+	for _, k := range keys {
+		res = append(res, apiKeyResponse(k))
+	}
+	ctx.JSON(http.StatusOK, res)
+}
+
+// RotateAPIKey
+// @Summary Rotate API key
+// @Description Replace an active API key with a new one with the same name and scopes (users.manage). The old key keeps working for grace_hours (at most 7 days) or is revoked at once when it is 0
+// @Tags service-accounts
+// @Accept json
+// @Produce json
+// @Param id path string true "Service account UUID"
+// @Param key_id path string true "API key UUID"
+// @Param body body dto.APIKeyRotateRequest true "TTL of the new key and grace period of the old one"
+// @Success 201 {object} dto.APIKeyResponse
+// @Failure 400 {object} dto.ErrorResponse
+// @Failure 404 {object} dto.ErrorResponse
+// @Failure 409 {object} dto.ErrorResponse "Key expired or revoked"
+// @Failure 422 {object} dto.ErrorResponse
+// @Security BearerAuth
+// @Router /api/service-accounts/{id}/keys/{key_id}/rotate [post]
+func (h *UserHandler) RotateAPIKey(ctx *wbgin.Context) {
+	var req dto.APIKeyRotateRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		badRequest(ctx, err)
+		return
+	}
+	actorID, actorLogin, ok := userFromContext(ctx)
+	if !ok {
+		return
+	}
+	ttl := time.Duration(req.TTLDays) * 24 * time.Hour
+	grace := time.Duration(req.GraceHours) * time.Hour
+	k, key, err := h.Service.RotateAPIKey(ctx.Request.Context(), ctx.Param("id"), ctx.Param("key_id"), ttl, grace, actorID, actorLogin)
+	if err != nil {
+		RespondError(ctx, err)
+		return
+	}
+	res := apiKeyResponse(k)
+	res.Key = key
+	ctx.JSON(http.StatusCreated, res)
+}
+
+// RevokeAPIKey
+// @Summary Revoke API key
+// @Description Revoke an API key immediately (users.manage)
+// @Tags service-accounts
+// @Produce json
+// @Param id path string true "Service account UUID"
+// @Param key_id path string true "API key UUID"
+// @Success 200 {object} map[string]string
+// @Failure 400 {object} dto.ErrorResponse
+// @Failure 404 {object} dto.ErrorResponse "Key not found or already revoked"
+// @Security BearerAuth
+// @Router /api/service-accounts/{id}/keys/{key_id} [delete]
+func (h *UserHandler) RevokeAPIKey(ctx *wbgin.Context) {
+	actorID, actorLogin, ok := userFromContext(ctx)
+	if !ok {
+		return
+	}
+	err := h.Service.RevokeAPIKey(ctx.Request.Context(), ctx.Param("id"), ctx.Param("key_id"), actorID, actorLogin)
+	if err != nil {
+		RespondError(ctx, err)
+		return
+	}
+	ctx.JSON(http.StatusOK, wbgin.H{"status": "revoked"})
+}
+
+func apiKeyResponse(k *user.APIKey) dto.APIKeyResponse {
+	return dto.APIKeyResponse{
+		ID:         k.ID.String(),
+		Name:       k.Name,
+		Prefix:     k.Prefix,
+		Scopes:     k.Scopes,
+		Active:     k.Active(time.Now()),
+		CreatedAt:  k.CreatedAt,
+		ExpiresAt:  k.ExpiresAt,
+		LastUsedAt: k.LastUsedAt,
+		RevokedAt:  k.RevokedAt,
+	}
+}
@@ -0,0 +1,109 @@
+package handlers_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+	"time"
+
+	"warehousecontrol/internal/domain/errs"
+	"warehousecontrol/internal/domain/user"
+	"warehousecontrol/internal/web/dto"
+	"warehousecontrol/internal/web/handlers"
+
+	"github.com/google/uuid"
+	wbgin "github.com/wb-go/wbf/ginext"
+)
+
+func TestUserHandler_CreateServiceAccount(t *testing.T) {
+	mock := &MockUserService{
+		CreateServiceAccountFn: func(login, role string) (*user.User, error) {
+			if role == "root" {
+				return nil, errs.Errorf(errs.ErrValidation, "unknown role: %s", role)
+			}
+			return user.NewServiceAccount(login, user.Role(role))
+		},
+	}
+	h := handlers.NewUserHandler(mock)
+
+	rr := performJSON(h.CreateServiceAccount, http.MethodPost, "/api/service-accounts", dto.ServiceAccountCreateRequest{Login: "erp-sync", Role: "manager"}, asAdmin(""))
+	if rr.Code != http.StatusCreated {
+		t.Fatalf("expected 201, got %d", rr.Code)
+	}
+	var res dto.UserResponse
+	_ = json.Unmarshal(rr.Body.Bytes(), &res)
+	if res.Login != "erp-sync" || res.Role != "manager" || !res.ServiceAccount {
+		t.Fatalf("unexpected response: %s", rr.Body.String())
+	}
+
+	rr = performJSON(h.CreateServiceAccount, http.MethodPost, "/api/service-accounts", dto.ServiceAccountCreateRequest{Login: "erp-sync", Role: "root"}, asAdmin(""))
+	if rr.Code != http.StatusUnprocessableEntity {
+		t.Fatalf("expected 422 for unknown role, got %d", rr.Code)
+	}
+}
+
+func TestUserHandler_APIKeys(t *testing.T) {
+	accountID := uuid.New()
+	var gotTTL, gotGrace time.Duration
+	mock := &MockUserService{
+		CreateAPIKeyFn: func(userID, name string, scopes []string, ttl time.Duration, actorID, actorLogin string) (*user.APIKey, string, error) {
+			if userID != accountID.String() || actorLogin != "admin" {
+				t.Fatalf("unexpected args %q %q", userID, actorLogin)
+			}
+			gotTTL = ttl
+			return user.NewAPIKey(accountID, name, scopes, ttl)
+		},
+		RotateAPIKeyFn: func(userID, keyID string, ttl, grace time.Duration, actorID, actorLogin string) (*user.APIKey, string, error) {
+			gotGrace = grace
+			return user.NewAPIKey(accountID, "scanner", []string{user.ScopeItemsRead}, ttl)
+		},
+		GetAPIKeysFn: func(userID string) ([]*user.APIKey, error) {
+			k, _, _ := user.NewAPIKey(accountID, "scanner", []string{user.ScopeItemsRead}, 0)
+			return []*user.APIKey{k}, nil
+		},
+		RevokeAPIKeyFn: func(userID, keyID, actorID, actorLogin string) error {
+			return user.ErrAPIKeyNotFound
+		},
+	}
+	h := handlers.NewUserHandler(mock)
+	withKey := func(c *wbgin.Context) {
+		asAdmin(accountID.String())(c)
+		c.AddParam("key_id", uuid.NewString())
+	}
+
+	rr := performJSON(h.CreateAPIKey, http.MethodPost, "/api/service-accounts/x/keys", dto.APIKeyCreateRequest{
+		Name: "scanner", Scopes: []string{user.ScopeItemsWrite}, TTLDays: 30,
+	}, asAdmin(accountID.String()))
+	if rr.Code != http.StatusCreated {
+		t.Fatalf("expected 201, got %d: %s", rr.Code, rr.Body.String())
+	}
+	var created dto.APIKeyResponse
+	_ = json.Unmarshal(rr.Body.Bytes(), &created)
+	if !user.IsAPIKey(created.Key) || created.Prefix == "" || !created.Active || created.ExpiresAt == nil || gotTTL != 30*24*time.Hour {
+		t.Fatalf("unexpected response: %s", rr.Body.String())
+	}
+
+	rr = performJSON(h.CreateAPIKey, http.MethodPost, "/api/service-accounts/x/keys", dto.APIKeyCreateRequest{Name: "scanner"}, asAdmin(accountID.String()))
+	if rr.Code != http.StatusBadRequest {
+		t.Fatalf("expected 400 without scopes, got %d", rr.Code)
+	}
+
+	rr = performJSON(h.GetAPIKeys, http.MethodGet, "/api/service-accounts/x/keys", nil, asAdmin(accountID.String()))
+	var list []dto.APIKeyResponse
+	_ = json.Unmarshal(rr.Body.Bytes(), &list)
+	if rr.Code != http.StatusOK || len(list) != 1 || list[0].Key != "" {
+		t.Fatalf("expected key list without key values, got %d: %s", rr.Code, rr.Body.String())
+	}
+
+	rr = performJSON(h.RotateAPIKey, http.MethodPost, "/api/service-accounts/x/keys/y/rotate", dto.APIKeyRotateRequest{GraceHours: 24}, withKey)
+	var rotated dto.APIKeyResponse
+	_ = json.Unmarshal(rr.Body.Bytes(), &rotated)
+	if rr.Code != http.StatusCreated || !user.IsAPIKey(rotated.Key) || gotGrace != 24*time.Hour {
+		t.Fatalf("unexpected rotate response %d: %s", rr.Code, rr.Body.String())
+	}
+
+	rr = performJSON(h.RevokeAPIKey, http.MethodDelete, "/api/service-accounts/x/keys/y", nil, withKey)
+	if rr.Code != http.StatusNotFound {
+		t.Fatalf("expected 404, got %d", rr.Code)
+	}
+}
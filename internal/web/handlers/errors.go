@@ -0,0 +1,96 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"net/http"
+
+	"warehousecontrol/internal/domain/errs"
+	"warehousecontrol/internal/web/dto"
+
+	wbgin "github.com/wb-go/wbf/ginext"
+	wbzlog "github.com/wb-go/wbf/zlog"
+)
+
+const (
+	CodeInvalidInput         = "invalid_input"
+	CodeValidationFailed     = "validation_failed"
+	CodeNotFound             = "not_found"
+	CodeConflict             = "conflict"
+	CodeForbidden            = "forbidden"
+	CodeUnauthorized         = "unauthorized"
+	CodePreconditionFailed   = "precondition_failed"
+	CodePreconditionRequired = "precondition_required"
+	CodeTooManyRequests      = "too_many_requests"
+	CodeServiceUnavailable   = "service_unavailable"
+	CodeInternal             = "internal_error"
+)
+
+// StatusClientClosedRequest — клиент отключился, не дождавшись ответа (как в nginx)
+const StatusClientClosedRequest = 499
+
+// RespondError отвечает статусом и кодом по категории ошибки из пакета errs.
+// Текст нетипизированных ошибок наружу не отдаётся, только пишется в лог.
+// Запрос к базе, не уложившийся в db_config.query_timeout, — 503.
+func RespondError(ctx *wbgin.Context, err error) {
+	switch {
+	case errors.Is(err, context.Canceled):
+		// отвечать некому, и это не ошибка сервера
+		wbzlog.Logger.Debug().Err(err).Msg("request canceled by client")
+		ctx.AbortWithStatus(StatusClientClosedRequest)
+		return
+	case errors.Is(err, context.DeadlineExceeded):
+		wbzlog.Logger.Warn().Err(err).Msg("database query timed out")
+		err = errs.New(errs.ErrUnavailable, "database query timed out")
+	}
+	var status int
+	var code string
+	switch errs.Kind(err) {
+	case errs.ErrInvalidInput:
+		status, code = http.StatusBadRequest, CodeInvalidInput
+	case errs.ErrValidation:
+		status, code = http.StatusUnprocessableEntity, CodeValidationFailed
+	case errs.ErrNotFound:
+		status, code = http.StatusNotFound, CodeNotFound
+	case errs.ErrConflict:
+		status, code = http.StatusConflict, CodeConflict
+	case errs.ErrForbidden:
+		status, code = http.StatusForbidden, CodeForbidden
+	case errs.ErrUnauthorized:
+		status, code = http.StatusUnauthorized, CodeUnauthorized
+	case errs.ErrPrecondition:
+		status, code = http.StatusPreconditionFailed, CodePreconditionFailed
+	case errs.ErrRateLimited:
+		status, code = http.StatusTooManyRequests, CodeTooManyRequests
+	case errs.ErrUnavailable:
+		status, code = http.StatusServiceUnavailable, CodeServiceUnavailable
+	default:
+		wbzlog.Logger.Error().Err(err).Msg("internal error")
+		ctx.AbortWithStatusJSON(http.StatusInternalServerError, dto.ErrorResponse{Error: "internal server error", Code: CodeInternal})
+		return
+	}
+	ctx.AbortWithStatusJSON(status, dto.ErrorResponse{Error: err.Error(), Code: code})
+}
+
+// badRequest — ошибка разбора запроса (тело, параметры, заголовки)
+func badRequest(ctx *wbgin.Context, err error) {
+	RespondError(ctx, errs.New(errs.ErrInvalidInput, err.Error()))
+}
+
+// IsCanceled — запрос прерван: клиент отключился или база не ответила за db_config.query_timeout.
+// Такие ошибки не означают неверные учётные данные.
+func IsCanceled(err error) bool {
+	return errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded)
+}
+
+// unauthorized — для эндпоинтов аутентификации любая нетипизированная ошибка означает 401
+func unauthorized(ctx *wbgin.Context, err error) {
+	if errs.Kind(err) == nil && !IsCanceled(err) {
+		err = errs.New(errs.ErrUnauthorized, err.Error())
+	}
+	RespondError(ctx, err)
+}
+
+func internalError(ctx *wbgin.Context, msg string) {
+	RespondError(ctx, errors.New(msg))
+}
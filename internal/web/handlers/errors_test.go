@@ -0,0 +1,73 @@
+package handlers_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"testing"
+
+	wbgin "github.com/wb-go/wbf/ginext"
+
+	"warehousecontrol/internal/domain/errs"
+	ditem "warehousecontrol/internal/domain/item"
+	"warehousecontrol/internal/web/dto"
+	"warehousecontrol/internal/web/handlers"
+)
+
+func TestRespondError_Mapping(t *testing.T) {
+	cases := []struct {
+		err    error
+		status int
+		code   string
+	}{
+		{errs.New(errs.ErrInvalidInput, "bad uuid"), http.StatusBadRequest, handlers.CodeInvalidInput},
+		{errs.New(errs.ErrValidation, "price must be > 0"), http.StatusUnprocessableEntity, handlers.CodeValidationFailed},
+		{ditem.ErrNotFound, http.StatusNotFound, handlers.CodeNotFound},
+		{fmt.Errorf("issue: %w", ditem.ErrInsufficientStock), http.StatusConflict, handlers.CodeConflict},
+		{errs.New(errs.ErrForbidden, "no"), http.StatusForbidden, handlers.CodeForbidden},
+		{errs.New(errs.ErrUnauthorized, "no"), http.StatusUnauthorized, handlers.CodeUnauthorized},
+		{errs.New(errs.ErrUnavailable, "directory down"), http.StatusServiceUnavailable, handlers.CodeServiceUnavailable},
+		{fmt.Errorf("get item: %w", context.DeadlineExceeded), http.StatusServiceUnavailable, handlers.CodeServiceUnavailable},
+		{errors.New("pq: connection refused"), http.StatusInternalServerError, handlers.CodeInternal},
+	}
+	for _, c := range cases {
+		rr := performJSON(func(ctx *wbgin.Context) { handlers.RespondError(ctx, c.err) }, http.MethodGet, "/", nil, nil)
+		if rr.Code != c.status {
+			t.Fatalf("%v: expected %d, got %d", c.err, c.status, rr.Code)
+		}
+		var body dto.ErrorResponse
+		if err := json.Unmarshal(rr.Body.Bytes(), &body); err != nil {
+			t.Fatalf("invalid body: %v", err)
+		}
+		if body.Code != c.code || body.Error == "" {
+			t.Fatalf("%v: unexpected body %+v", c.err, body)
+		}
+	}
+}
+
+func TestRespondError_HidesInternalMessage(t *testing.T) {
+	rr := performJSON(func(ctx *wbgin.Context) { handlers.RespondError(ctx, errors.New("pq: password authentication failed")) }, http.MethodGet, "/", nil, nil)
+	var body dto.ErrorResponse
+	_ = json.Unmarshal(rr.Body.Bytes(), &body)
+	if body.Error != "internal server error" {
+		t.Fatalf("expected generic message, got %q", body.Error)
+	}
+}
+
+func TestRespondError_ClientGone(t *testing.T) {
+	rr := performJSON(func(ctx *wbgin.Context) { handlers.RespondError(ctx, context.Canceled) }, http.MethodGet, "/", nil, nil)
+	if rr.Code != handlers.StatusClientClosedRequest || rr.Body.Len() != 0 {
+		t.Fatalf("expected %d without body, got %d %q", handlers.StatusClientClosedRequest, rr.Code, rr.Body.String())
+	}
+}
+
+func TestItemHandler_GetItem_NotFound(t *testing.T) {
+	mock := &MockItemService{GetItemFn: func(id string) (*ditem.Item, error) { return nil, ditem.ErrNotFound }}
+	h := handlers.NewItemHandler(mock)
+	rr := performJSON(h.GetItem, http.MethodGet, "/api/items/123", nil, func(c *wbgin.Context) { c.AddParam("id", "123") })
+	if rr.Code != http.StatusNotFound {
+		t.Fatalf("expected 404, got %d", rr.Code)
+	}
+}
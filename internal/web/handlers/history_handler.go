@@ -1,13 +1,17 @@
 package handlers
 
 import (
+	"context"
 	"io"
 	"net/http"
+	"strconv"
 	"time"
 
+	"warehousecontrol/internal/domain/errs"
 	"warehousecontrol/internal/domain/history"
 
 	wbgin "github.com/wb-go/wbf/ginext"
+	wbzlog "github.com/wb-go/wbf/zlog"
 )
 
 type HistoryHandler struct {
@@ -15,8 +19,8 @@ type HistoryHandler struct {
 }
 
 type HistoryIFace interface {
-	GetItems(id string, from, to time.Time, action string, login string) ([]*history.History, error)
-	GetItemsCSV(id string, from, to time.Time, action string, login string, output io.Writer) error
+	GetItems(ctx context.Context, f history.Filter, limit int, cursor *history.Cursor) (*history.Page, error)
+	GetItemsCSV(ctx context.Context, f history.Filter, output io.Writer) error
 }
 
 func NewHistoryHandler(service HistoryIFace) *HistoryHandler {
@@ -27,86 +31,109 @@ func NewHistoryHandler(service HistoryIFace) *HistoryHandler {
 
 // GetItems
 // @Summary List history
-// @Description Get item change history filtered by date range and optional filters
+// @Description Get item change history filtered by date range and optional filters, ordered by change time. Use next_cursor to fetch the following page.
 // @Tags history
 // @Produce json
 // @Param from query string true "From date (YYYY-MM-DD)"
 // @Param to query string true "To date (YYYY-MM-DD)"
 // @Param id query string false "Item UUID"
-// @Param action query string false "created|updated|deleted"
+// @Param action query string false "created|updated|deleted|adjusted|reverted|stock_created|stock_updated|stock_removed"
 // @Param login query string false "login substring"
-// @Success 200 {array} history.History
-// @Failure 400 {object} map[string]string
+// @Param limit query int false "Page size (default 100, max 1000)"
+// @Param cursor query string false "next_cursor from the previous page"
+// @Success 200 {object} history.Page
+// @Failure 400 {object} dto.ErrorResponse
 // @Security BearerAuth
 // @Router /api/history [get]
 func (h *HistoryHandler) GetItems(ctx *wbgin.Context) {
-	from := ctx.Query("from")
-	to := ctx.Query("to")
-	action := ctx.Query("action")
-	login := ctx.Query("login")
-	id := ctx.Query("id")
-
-	layout := "2006-01-02"
-	fromParsed, err := time.ParseInLocation(layout, from, time.Local)
+	f, err := historyFilter(ctx)
 	if err != nil {
-		ctx.JSON(http.StatusBadRequest, wbgin.H{"error": "invalid from date format"})
+		RespondError(ctx, err)
 		return
 	}
-	toParsed, err := time.ParseInLocation(layout, to, time.Local)
-	if err != nil {
-		ctx.JSON(http.StatusBadRequest, wbgin.H{"error": "invalid to date format"})
-		return
+
+	limit := 0
+	if raw := ctx.Query("limit"); raw != "" {
+		limit, err = strconv.Atoi(raw)
+		if err != nil {
+			RespondError(ctx, errs.New(errs.ErrInvalidInput, "invalid limit"))
+			return
+		}
+	}
+	var cursor *history.Cursor
+	if raw := ctx.Query("cursor"); raw != "" {
+		cursor, err = history.DecodeCursor(raw)
+		if err != nil {
+			RespondError(ctx, err)
+			return
+		}
 	}
 
-	histories, err := h.Service.GetItems(id, fromParsed, toParsed, action, login)
+	page, err := h.Service.GetItems(ctx.Request.Context(), f, limit, cursor)
 
 	if err != nil {
-		ctx.JSON(http.StatusBadRequest, wbgin.H{"error": err.Error()})
+		RespondError(ctx, err)
 		return
 	}
 
-	ctx.JSON(http.StatusOK, histories)
+	ctx.JSON(http.StatusOK, page)
 }
 
 // GetItemsCSV
 // @Summary Export history CSV
-// @Description Download item change history as CSV with same filters
+// @Description Download item change history as CSV with same filters. Rows are streamed as they are read.
 // @Tags history
 // @Produce text/csv
 // @Param from query string true "From date (YYYY-MM-DD)"
 // @Param to query string true "To date (YYYY-MM-DD)"
 // @Param id query string false "Item UUID"
-// @Param action query string false "created|updated|deleted"
+// @Param action query string false "created|updated|deleted|adjusted|reverted|stock_created|stock_updated|stock_removed"
 // @Param login query string false "login substring"
 // @Success 200 "CSV file"
-// @Failure 400 {object} map[string]string
-// @Failure 500 {object} map[string]string
+// @Failure 400 {object} dto.ErrorResponse
+// @Failure 500 {object} dto.ErrorResponse
 // @Security BearerAuth
 // @Router /api/history/csv [get]
 func (h *HistoryHandler) GetItemsCSV(ctx *wbgin.Context) {
-	from := ctx.Query("from")
-	to := ctx.Query("to")
-	action := ctx.Query("action")
-	login := ctx.Query("login")
-	id := ctx.Query("id")
-
-	layout := "2006-01-02"
-	fromParsed, err := time.ParseInLocation(layout, from, time.Local)
-	if err != nil {
-		ctx.JSON(http.StatusBadRequest, wbgin.H{"error": "invalid from date format"})
-		return
-	}
-	toParsed, err := time.ParseInLocation(layout, to, time.Local)
+	f, err := historyFilter(ctx)
 	if err != nil {
-		ctx.JSON(http.StatusBadRequest, wbgin.H{"error": "invalid to date format"})
+		RespondError(ctx, err)
 		return
 	}
 
 	ctx.Writer.Header().Set("Content-Disposition", "attachment; filename=transactions.csv")
 	ctx.Writer.Header().Set("Content-Type", "text/csv")
-	err = h.Service.GetItemsCSV(id, fromParsed, toParsed, action, login, ctx.Writer)
+	err = h.Service.GetItemsCSV(ctx.Request.Context(), f, ctx.Writer)
 	if err != nil {
-		ctx.JSON(http.StatusInternalServerError, wbgin.H{"error": err.Error()})
+		if ctx.Writer.Written() {
+			// статус и часть файла уже отправлены, ответить ошибкой нельзя — обрываем выгрузку
+			wbzlog.Logger.Error().Err(err).Msg("history CSV export interrupted")
+			ctx.Abort()
+			return
+		}
+		ctx.Writer.Header().Del("Content-Disposition")
+		ctx.Writer.Header().Del("Content-Type")
+		RespondError(ctx, err)
 		return
 	}
 }
+
+// historyFilter разбирает общие для списка и CSV параметры запроса
+func historyFilter(ctx *wbgin.Context) (history.Filter, error) {
+	layout := "2006-01-02"
+	from, err := time.ParseInLocation(layout, ctx.Query("from"), time.Local)
+	if err != nil {
+		return history.Filter{}, errs.New(errs.ErrInvalidInput, "invalid from date format")
+	}
+	to, err := time.ParseInLocation(layout, ctx.Query("to"), time.Local)
+	if err != nil {
+		return history.Filter{}, errs.New(errs.ErrInvalidInput, "invalid to date format")
+	}
+	return history.Filter{
+		ItemID: ctx.Query("id"),
+		From:   from,
+		To:     to,
+		Action: ctx.Query("action"),
+		Login:  ctx.Query("login"),
+	}, nil
+}
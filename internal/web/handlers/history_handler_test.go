@@ -1,6 +1,7 @@
 package handlers_test
 
 import (
+	"context"
 	"io"
 	"net/http"
 	"net/http/httptest"
@@ -10,20 +11,22 @@ import (
 
 	"github.com/gin-gonic/gin"
 
+	apphist "warehousecontrol/internal/app/history"
+	"warehousecontrol/internal/domain/errs"
 	dhist "warehousecontrol/internal/domain/history"
 	"warehousecontrol/internal/web/handlers"
 )
 
 type MockHistoryService struct {
-	GetItemsFn    func(id string, from, to time.Time, action string, login string) ([]*dhist.History, error)
-	GetItemsCSVFn func(id string, from, to time.Time, action string, login string, output io.Writer) error
+	GetItemsFn    func(f dhist.Filter, limit int, cursor *dhist.Cursor) (*dhist.Page, error)
+	GetItemsCSVFn func(f dhist.Filter, output io.Writer) error
 }
 
-func (m *MockHistoryService) GetItems(id string, from, to time.Time, action string, login string) ([]*dhist.History, error) {
-	return m.GetItemsFn(id, from, to, action, login)
+func (m *MockHistoryService) GetItems(_ context.Context, f dhist.Filter, limit int, cursor *dhist.Cursor) (*dhist.Page, error) {
+	return m.GetItemsFn(f, limit, cursor)
 }
-func (m *MockHistoryService) GetItemsCSV(id string, from, to time.Time, action string, login string, output io.Writer) error {
-	return m.GetItemsCSVFn(id, from, to, action, login, output)
+func (m *MockHistoryService) GetItemsCSV(_ context.Context, f dhist.Filter, output io.Writer) error {
+	return m.GetItemsCSVFn(f, output)
 }
 
 func TestHistoryHandler_GetItems_InvalidFromDate(t *testing.T) {
@@ -51,8 +54,8 @@ func TestHistoryHandler_GetItems_InvalidToDate(t *testing.T) {
 }
 
 func TestHistoryHandler_GetItems_ServiceError(t *testing.T) {
-	mock := &MockHistoryService{GetItemsFn: func(id string, from, to time.Time, action string, login string) ([]*dhist.History, error) {
-		return nil, assertErr("boom")
+	mock := &MockHistoryService{GetItemsFn: func(f dhist.Filter, limit int, cursor *dhist.Cursor) (*dhist.Page, error) {
+		return nil, errs.New(errs.ErrInvalidInput, "invalid action filter")
 	}}
 	h := handlers.NewHistoryHandler(mock)
 	rr := httptest.NewRecorder()
@@ -66,18 +69,39 @@ func TestHistoryHandler_GetItems_ServiceError(t *testing.T) {
 }
 
 func TestHistoryHandler_GetItems_Success(t *testing.T) {
-	mock := &MockHistoryService{GetItemsFn: func(id string, from, to time.Time, action string, login string) ([]*dhist.History, error) {
-		return []*dhist.History{}, nil
+	var gotFilter dhist.Filter
+	var gotLimit int
+	var gotCursor *dhist.Cursor
+	mock := &MockHistoryService{GetItemsFn: func(f dhist.Filter, limit int, cursor *dhist.Cursor) (*dhist.Page, error) {
+		gotFilter, gotLimit, gotCursor = f, limit, cursor
+		return &dhist.Page{Items: []*dhist.History{}}, nil
 	}}
+	cursor := &dhist.Cursor{ChangedAt: time.Date(2025, 1, 1, 10, 0, 0, 0, time.UTC), Seq: 42}
 	h := handlers.NewHistoryHandler(mock)
 	rr := httptest.NewRecorder()
 	ctx, _ := gin.CreateTestContext(rr)
-	req := httptest.NewRequest(http.MethodGet, "/api/history?from=2025-01-01&to=2025-01-02&action=updated&login=john&id=", nil)
+	req := httptest.NewRequest(http.MethodGet, "/api/history?from=2025-01-01&to=2025-01-02&action=updated&login=john&id=&limit=20&cursor="+cursor.Encode(), nil)
 	ctx.Request = req
 	h.GetItems(ctx)
 	if rr.Code != http.StatusOK {
 		t.Fatalf("expected 200, got %d", rr.Code)
 	}
+	if gotFilter.Action != "updated" || gotFilter.Login != "john" || gotLimit != 20 || gotCursor == nil || gotCursor.Seq != 42 {
+		t.Fatalf("unexpected params: %+v %d %+v", gotFilter, gotLimit, gotCursor)
+	}
+}
+
+func TestHistoryHandler_GetItems_InvalidPaging(t *testing.T) {
+	h := handlers.NewHistoryHandler(&MockHistoryService{})
+	for _, q := range []string{"limit=abc", "cursor=bad!"} {
+		rr := httptest.NewRecorder()
+		ctx, _ := gin.CreateTestContext(rr)
+		ctx.Request = httptest.NewRequest(http.MethodGet, "/api/history?from=2025-01-01&to=2025-01-02&"+q, nil)
+		h.GetItems(ctx)
+		if rr.Code != http.StatusBadRequest {
+			t.Fatalf("%s: expected 400, got %d", q, rr.Code)
+		}
+	}
 }
 
 func TestHistoryHandler_GetItemsCSV_InvalidFromDate(t *testing.T) {
@@ -105,7 +129,7 @@ func TestHistoryHandler_GetItemsCSV_InvalidToDate(t *testing.T) {
 }
 
 func TestHistoryHandler_GetItemsCSV_ServiceError(t *testing.T) {
-	mock := &MockHistoryService{GetItemsCSVFn: func(id string, from, to time.Time, action string, login string, output io.Writer) error {
+	mock := &MockHistoryService{GetItemsCSVFn: func(f dhist.Filter, output io.Writer) error {
 		return assertErr("boom")
 	}}
 	h := handlers.NewHistoryHandler(mock)
@@ -120,7 +144,7 @@ func TestHistoryHandler_GetItemsCSV_ServiceError(t *testing.T) {
 }
 
 func TestHistoryHandler_GetItemsCSV_Success(t *testing.T) {
-	mock := &MockHistoryService{GetItemsCSVFn: func(id string, from, to time.Time, action string, login string, output io.Writer) error {
+	mock := &MockHistoryService{GetItemsCSVFn: func(f dhist.Filter, output io.Writer) error {
 		_, _ = output.Write([]byte("id,item_id,action\n"))
 		_, _ = output.Write([]byte("1,abc,updated\n"))
 		return nil
@@ -148,6 +172,70 @@ func TestHistoryHandler_GetItemsCSV_Success(t *testing.T) {
 	}
 }
 
+func TestHistoryHandler_GetItemsCSV_ErrorAfterWrite(t *testing.T) {
+	mock := &MockHistoryService{GetItemsCSVFn: func(f dhist.Filter, output io.Writer) error {
+		_, _ = output.Write([]byte("ID,ItemID\n"))
+		return assertErr("connection lost")
+	}}
+	h := handlers.NewHistoryHandler(mock)
+	rr := httptest.NewRecorder()
+	ctx, _ := gin.CreateTestContext(rr)
+	ctx.Request = httptest.NewRequest(http.MethodGet, "/api/history/csv?from=2025-01-01&to=2025-01-02", nil)
+	h.GetItemsCSV(ctx)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected already sent 200, got %d", rr.Code)
+	}
+	if strings.Contains(rr.Body.String(), "internal") {
+		t.Fatalf("expected no JSON error appended to CSV, got %s", rr.Body.String())
+	}
+}
+
+// failingHistoryRepo — база, запрос к которой падает до первой строки
+type failingHistoryRepo struct{ err error }
+
+func (r failingHistoryRepo) GetItemsHistory(_ context.Context, _ dhist.Filter, _ int, _ *dhist.Cursor) (*dhist.Page, error) {
+	return nil, r.err
+}
+func (r failingHistoryRepo) StreamItemsHistory(_ context.Context, _ dhist.Filter, _ func(*dhist.History) error) error {
+	return r.err
+}
+
+func TestHistoryHandler_GetItemsCSV_RepoFailsBeforeRows(t *testing.T) {
+	svc := apphist.NewHistoryService(failingHistoryRepo{err: errs.New(errs.ErrUnavailable, "database is down")})
+	h := handlers.NewHistoryHandler(svc)
+	rr := httptest.NewRecorder()
+	ctx, _ := gin.CreateTestContext(rr)
+	ctx.Request = httptest.NewRequest(http.MethodGet, "/api/history/csv?from=2025-01-01&to=2025-01-02", nil)
+	h.GetItemsCSV(ctx)
+	if rr.Code != http.StatusServiceUnavailable {
+		t.Fatalf("expected 503, got %d", rr.Code)
+	}
+	if strings.Contains(rr.Body.String(), "ItemID") {
+		t.Fatalf("expected no CSV header in error response, got %s", rr.Body.String())
+	}
+	if ct := rr.Header().Get("Content-Type"); !strings.Contains(ct, "application/json") {
+		t.Fatalf("expected JSON error, got Content-Type %s", ct)
+	}
+	if disp := rr.Header().Get("Content-Disposition"); disp != "" {
+		t.Fatalf("expected no attachment on error, got %s", disp)
+	}
+}
+
+func TestHistoryHandler_GetItemsCSV_InvalidFilterIsJSON(t *testing.T) {
+	svc := apphist.NewHistoryService(failingHistoryRepo{err: assertErr("must not be called")})
+	h := handlers.NewHistoryHandler(svc)
+	rr := httptest.NewRecorder()
+	ctx, _ := gin.CreateTestContext(rr)
+	ctx.Request = httptest.NewRequest(http.MethodGet, "/api/history/csv?from=2025-01-01&to=2025-01-02&action=bogus", nil)
+	h.GetItemsCSV(ctx)
+	if rr.Code != http.StatusBadRequest {
+		t.Fatalf("expected 400, got %d", rr.Code)
+	}
+	if ct := rr.Header().Get("Content-Type"); !strings.Contains(ct, "application/json") {
+		t.Fatalf("expected JSON error, got Content-Type %s", ct)
+	}
+}
+
 // minimal error type
 type assertErr string
 
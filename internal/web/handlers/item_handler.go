@@ -1,9 +1,18 @@
 package handlers
 
 import (
+	"context"
+	"errors"
 	"net/http"
+	"strconv"
+	"strings"
+	"time"
 
+	"warehousecontrol/internal/domain/errs"
+	"warehousecontrol/internal/domain/history"
 	"warehousecontrol/internal/domain/item"
+	"warehousecontrol/internal/domain/movement"
+	"warehousecontrol/internal/domain/user"
 	"warehousecontrol/internal/web/dto"
 
 	wbgin "github.com/wb-go/wbf/ginext"
@@ -14,11 +23,20 @@ type ItemHandler struct {
 }
 
 type ItemIFace interface {
-	Create(name string, count int, price float64, userID string, login string) (*item.Item, error)
-	GetItems() ([]*item.Item, error)
-	GetItem(id string) (*item.Item, error)
-	PutItem(id string, name string, count int, price float64, userID string, login string) (*item.Item, error)
-	DeleteItem(id string, userID string, login string) error
+	Create(ctx context.Context, name string, count int, price float64, userID string, login string) (*item.Item, error)
+	GetItems(ctx context.Context, opts item.ListOptions) (*item.Page, error)
+	GetItem(ctx context.Context, id string) (*item.Item, error)
+	GetItemAsOf(ctx context.Context, id string, asOf time.Time) (*item.Item, error)
+	PutItem(ctx context.Context, id string, version int, name string, count int, price float64, userID string, login string) (*item.Item, error)
+	DeleteItem(ctx context.Context, id string, version int, userID string, login string) error
+	SetStock(ctx context.Context, id string, locationID string, quantity int, userID string, login string) (*item.Item, error)
+	Receive(ctx context.Context, id string, locationID string, quantity int, reason string, reference string, userID string, login string) (*item.Item, error)
+	Issue(ctx context.Context, id string, locationID string, quantity int, reason string, reference string, userID string, login string) (*item.Item, error)
+	Transfer(ctx context.Context, id string, fromLocationID string, toLocationID string, quantity int, reason string, reference string, userID string, login string) (*item.Item, error)
+	Adjust(ctx context.Context, id string, locationID string, delta int, force bool, reason string, reference string, userID string, login string) (int, error)
+	GetMovements(ctx context.Context, id string) ([]*movement.Movement, error)
+	Revert(ctx context.Context, id string, historyID string, snapshot string, version int, userID string, login string) (*item.Item, error)
+	Diff(ctx context.Context, id string, from string, to string) (*history.VersionDiff, error)
 }
 
 func NewItemHandler(service ItemIFace) *ItemHandler {
@@ -29,35 +47,36 @@ func NewItemHandler(service ItemIFace) *ItemHandler {
 
 // CreateItem
 // @Summary Create item
-// @Description Create a new inventory item (admin only)
+// @Description Create a new inventory item (items.create)
 // @Tags items
 // @Accept json
 // @Produce json
 // @Param body body dto.ItemCreateRequest true "Item payload"
 // @Success 200 {object} item.Item
-// @Failure 400 {object} map[string]string
-// @Failure 500 {object} map[string]string
+// @Failure 400 {object} dto.ErrorResponse
+// @Failure 422 {object} dto.ErrorResponse
+// @Failure 500 {object} dto.ErrorResponse
 // @Security BearerAuth
 // @Router /api/items [post]
 func (h *ItemHandler) CreateItem(ctx *wbgin.Context) {
 	var req dto.ItemCreateRequest
 	if err := ctx.ShouldBindJSON(&req); err != nil {
-		ctx.JSON(http.StatusBadRequest, wbgin.H{"error": err.Error()})
+		badRequest(ctx, err)
 		return
 	}
 	userID, ok := ctx.Get("userId")
 	if !ok {
-		ctx.JSON(http.StatusInternalServerError, wbgin.H{"error": "userId not found in context"})
+		internalError(ctx, "userId not found in context")
 		return
 	}
 	login, ok := ctx.Get("login")
 	if !ok {
-		ctx.JSON(http.StatusInternalServerError, wbgin.H{"error": "login not found in context"})
+		internalError(ctx, "login not found in context")
 		return
 	}
-	item, err := h.Service.Create(req.Name, req.Count, req.Price, userID.(string), login.(string))
+	item, err := h.Service.Create(ctx.Request.Context(), req.Name, req.Count, req.Price, userID.(string), login.(string))
 	if err != nil {
-		ctx.JSON(http.StatusInternalServerError, wbgin.H{"error": err.Error()})
+		RespondError(ctx, err)
 		return
 	}
 	ctx.JSON(http.StatusOK, item)
@@ -65,106 +84,541 @@ func (h *ItemHandler) CreateItem(ctx *wbgin.Context) {
 
 // GetItems
 // @Summary List items
-// @Description Get list of items
+// @Description Get a page of items. Use next_cursor from the response as cursor to get the next page; limit/offset work as a fallback
 // @Tags items
 // @Produce json
-// @Success 200 {array} item.Item
-// @Failure 500 {object} map[string]string
+// @Param limit query int false "Page size"
+// @Param offset query int false "Offset (ignored when cursor is set)"
+// @Param cursor query string false "next_cursor from the previous page"
+// @Param sort query string false "name|count|price"
+// @Param order query string false "asc|desc"
+// @Param name query string false "Name substring"
+// @Param min_count query int false "Minimal count"
+// @Param max_count query int false "Maximal count"
+// @Param min_price query number false "Minimal price"
+// @Param max_price query number false "Maximal price"
+// @Param zero_stock query bool false "true — only items with zero count, false — only items in stock"
+// @Param as_of query string false "Point in time (RFC3339): list is rebuilt from history as it stood at that moment"
+// @Success 200 {object} item.Page
+// @Failure 400 {object} dto.ErrorResponse
+// @Failure 500 {object} dto.ErrorResponse
 // @Security BearerAuth
 // @Router /api/items [get]
 func (h *ItemHandler) GetItems(ctx *wbgin.Context) {
-	items, err := h.Service.GetItems()
+	var req dto.ItemListQuery
+	if err := ctx.ShouldBindQuery(&req); err != nil {
+		badRequest(ctx, err)
+		return
+	}
+	opts := item.ListOptions{
+		Limit:     req.Limit,
+		Offset:    req.Offset,
+		SortBy:    req.Sort,
+		Desc:      req.Order == "desc",
+		Name:      req.Name,
+		MinCount:  req.MinCount,
+		MaxCount:  req.MaxCount,
+		MinPrice:  req.MinPrice,
+		MaxPrice:  req.MaxPrice,
+		ZeroStock: req.ZeroStock,
+	}
+	if req.Cursor != "" {
+		cursor, err := item.DecodeCursor(req.Cursor)
+		if err != nil {
+			RespondError(ctx, err)
+			return
+		}
+		opts.Cursor = cursor
+	}
+	if req.AsOf != "" {
+		asOf, err := parseAsOf(req.AsOf)
+		if err != nil {
+			RespondError(ctx, err)
+			return
+		}
+		opts.AsOf = &asOf
+	}
+	page, err := h.Service.GetItems(ctx.Request.Context(), opts)
 	if err != nil {
-		ctx.JSON(http.StatusInternalServerError, wbgin.H{"error": err.Error()})
+		RespondError(ctx, err)
 		return
 	}
-	ctx.JSON(http.StatusOK, items)
+	ctx.JSON(http.StatusOK, page)
 }
 
 // GetItem
 // @Summary Get item
-// @Description Get a single item by ID
+// @Description Get a single item by ID with per-location stock breakdown. With as_of the item is rebuilt from history as it stood at that moment.
 // @Tags items
 // @Produce json
 // @Param id path string true "Item UUID"
+// @Param as_of query string false "Point in time (RFC3339), e.g. 2026-01-31T23:59:59Z"
 // @Success 200 {object} item.Item
-// @Failure 500 {object} map[string]string
+// @Header 200 {string} ETag "Item version"
+// @Failure 404 {object} dto.ErrorResponse
+// @Failure 500 {object} dto.ErrorResponse
 // @Security BearerAuth
 // @Router /api/items/{id} [get]
 func (h *ItemHandler) GetItem(ctx *wbgin.Context) {
 	id := ctx.Param("id")
-	item, err := h.Service.GetItem(id)
+	if raw := ctx.Query("as_of"); raw != "" {
+		asOf, err := parseAsOf(raw)
+		if err != nil {
+			RespondError(ctx, err)
+			return
+		}
+		// исторический снимок не участвует в оптимистичной блокировке, поэтому без ETag
+		it, err := h.Service.GetItemAsOf(ctx.Request.Context(), id, asOf)
+		if err != nil {
+			RespondError(ctx, err)
+			return
+		}
+		ctx.JSON(http.StatusOK, it)
+		return
+	}
+	item, err := h.Service.GetItem(ctx.Request.Context(), id)
 	if err != nil {
-		ctx.JSON(http.StatusInternalServerError, wbgin.H{"error": err.Error()})
+		RespondError(ctx, err)
 		return
 	}
+	setETag(ctx, item)
 	ctx.JSON(http.StatusOK, item)
 }
 
 // PutItem
 // @Summary Update item
-// @Description Update item fields (admin/manager)
+// @Description Update item fields (items.update). Requires If-Match with the version from ETag
 // @Tags items
 // @Accept json
 // @Produce json
 // @Param id path string true "Item UUID"
+// @Param If-Match header string true "Item version (ETag)"
 // @Param body body dto.ItemUpdateRequest true "Updated fields"
 // @Success 200 {object} item.Item
-// @Failure 400 {object} map[string]string
-// @Failure 500 {object} map[string]string
+// @Header 200 {string} ETag "New item version"
+// @Failure 400 {object} dto.ErrorResponse
+// @Failure 404 {object} dto.ErrorResponse
+// @Failure 409 {object} dto.ErrorResponse
+// @Failure 412 {object} map[string]interface{} "Stale version, current item in 'current'"
+// @Failure 422 {object} dto.ErrorResponse
+// @Failure 428 {object} dto.ErrorResponse
+// @Failure 500 {object} dto.ErrorResponse
 // @Security BearerAuth
 // @Router /api/items/{id} [put]
 func (h *ItemHandler) PutItem(ctx *wbgin.Context) {
 	id := ctx.Param("id")
 	var req dto.ItemUpdateRequest
 	if err := ctx.ShouldBindJSON(&req); err != nil {
-		ctx.JSON(http.StatusBadRequest, wbgin.H{"error": err.Error()})
+		badRequest(ctx, err)
 		return
 	}
 	userID, ok := ctx.Get("userId")
 	if !ok {
-		ctx.JSON(http.StatusInternalServerError, wbgin.H{"error": "userId not found in context"})
+		internalError(ctx, "userId not found in context")
 		return
 	}
 	login, ok := ctx.Get("login")
 	if !ok {
-		ctx.JSON(http.StatusInternalServerError, wbgin.H{"error": "login not found in context"})
+		internalError(ctx, "login not found in context")
 		return
 	}
-	item, err := h.Service.PutItem(id, req.Name, req.Count, req.Price, userID.(string), login.(string))
+	version, ok := ifMatchVersion(ctx)
+	if !ok {
+		return
+	}
+	item, err := h.Service.PutItem(ctx.Request.Context(), id, version, req.Name, req.Count, req.Price, userID.(string), login.(string))
 	if err != nil {
-		ctx.JSON(http.StatusInternalServerError, wbgin.H{"error": err.Error()})
+		if h.versionConflict(ctx, id, err) {
+			return
+		}
+		RespondError(ctx, err)
 		return
 	}
+	setETag(ctx, item)
 	ctx.JSON(http.StatusOK, item)
 }
 
 // DeleteItem
 // @Summary Delete item
-// @Description Delete item (admin only)
+// @Description Delete item (items.delete). Requires If-Match with the version from ETag
 // @Tags items
 // @Produce json
 // @Param id path string true "Item UUID"
+// @Param If-Match header string true "Item version (ETag)"
 // @Success 200 {object} map[string]string
-// @Failure 500 {object} map[string]string
+// @Failure 404 {object} dto.ErrorResponse
+// @Failure 412 {object} map[string]interface{} "Stale version, current item in 'current'"
+// @Failure 428 {object} dto.ErrorResponse
+// @Failure 500 {object} dto.ErrorResponse
 // @Security BearerAuth
 // @Router /api/items/{id} [delete]
 func (h *ItemHandler) DeleteItem(ctx *wbgin.Context) {
 	id := ctx.Param("id")
 	userID, ok := ctx.Get("userId")
 	if !ok {
-		ctx.JSON(http.StatusInternalServerError, wbgin.H{"error": "userId not found in context"})
+		internalError(ctx, "userId not found in context")
 		return
 	}
 	login, ok := ctx.Get("login")
 	if !ok {
-		ctx.JSON(http.StatusInternalServerError, wbgin.H{"error": "login not found in context"})
+		internalError(ctx, "login not found in context")
+		return
+	}
+	version, ok := ifMatchVersion(ctx)
+	if !ok {
 		return
 	}
-	err := h.Service.DeleteItem(id, userID.(string), login.(string))
+	err := h.Service.DeleteItem(ctx.Request.Context(), id, version, userID.(string), login.(string))
 	if err != nil {
-		ctx.JSON(http.StatusInternalServerError, wbgin.H{"error": err.Error()})
+		if h.versionConflict(ctx, id, err) {
+			return
+		}
+		RespondError(ctx, err)
 		return
 	}
 	ctx.JSON(http.StatusOK, wbgin.H{"message": "item deleted"})
 }
+
+// SetStock
+// @Summary Set item stock at location
+// @Description Set item quantity in a bin location; total count shifts by the same difference (items.adjust)
+// @Tags items
+// @Accept json
+// @Produce json
+// @Param id path string true "Item UUID"
+// @Param body body dto.ItemStockRequest true "Location quantity"
+// @Success 200 {object} item.Item
+// @Failure 400 {object} dto.ErrorResponse
+// @Failure 404 {object} dto.ErrorResponse
+// @Failure 409 {object} dto.ErrorResponse
+// @Failure 422 {object} dto.ErrorResponse
+// @Failure 500 {object} dto.ErrorResponse
+// @Security BearerAuth
+// @Router /api/items/{id}/stock [put]
+func (h *ItemHandler) SetStock(ctx *wbgin.Context) {
+	id := ctx.Param("id")
+	var req dto.ItemStockRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		badRequest(ctx, err)
+		return
+	}
+	userID, ok := ctx.Get("userId")
+	if !ok {
+		internalError(ctx, "userId not found in context")
+		return
+	}
+	login, ok := ctx.Get("login")
+	if !ok {
+		internalError(ctx, "login not found in context")
+		return
+	}
+	item, err := h.Service.SetStock(ctx.Request.Context(), id, req.LocationID, req.Quantity, userID.(string), login.(string))
+	if err != nil {
+		RespondError(ctx, err)
+		return
+	}
+	ctx.JSON(http.StatusOK, item)
+}
+
+// Receive
+// @Summary Receive stock
+// @Description Record a goods receipt in the movement ledger (items.adjust)
+// @Tags items
+// @Accept json
+// @Produce json
+// @Param id path string true "Item UUID"
+// @Param body body dto.ItemMovementRequest true "Receipt"
+// @Success 200 {object} item.Item
+// @Failure 400 {object} dto.ErrorResponse
+// @Failure 404 {object} dto.ErrorResponse
+// @Failure 409 {object} dto.ErrorResponse
+// @Failure 422 {object} dto.ErrorResponse
+// @Failure 500 {object} dto.ErrorResponse
+// @Security BearerAuth
+// @Router /api/items/{id}/receipts [post]
+func (h *ItemHandler) Receive(ctx *wbgin.Context) {
+	var req dto.ItemMovementRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		badRequest(ctx, err)
+		return
+	}
+	userID, login, ok := userFromContext(ctx)
+	if !ok {
+		return
+	}
+	item, err := h.Service.Receive(ctx.Request.Context(), ctx.Param("id"), req.LocationID, req.Quantity, req.Reason, req.Reference, userID, login)
+	if err != nil {
+		RespondError(ctx, err)
+		return
+	}
+	ctx.JSON(http.StatusOK, item)
+}
+
+// Issue
+// @Summary Issue stock
+// @Description Record a goods issue in the movement ledger (items.adjust)
+// @Tags items
+// @Accept json
+// @Produce json
+// @Param id path string true "Item UUID"
+// @Param body body dto.ItemMovementRequest true "Issue"
+// @Success 200 {object} item.Item
+// @Failure 400 {object} dto.ErrorResponse
+// @Failure 404 {object} dto.ErrorResponse
+// @Failure 409 {object} dto.ErrorResponse
+// @Failure 422 {object} dto.ErrorResponse
+// @Failure 500 {object} dto.ErrorResponse
+// @Security BearerAuth
+// @Router /api/items/{id}/issues [post]
+func (h *ItemHandler) Issue(ctx *wbgin.Context) {
+	var req dto.ItemMovementRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		badRequest(ctx, err)
+		return
+	}
+	userID, login, ok := userFromContext(ctx)
+	if !ok {
+		return
+	}
+	item, err := h.Service.Issue(ctx.Request.Context(), ctx.Param("id"), req.LocationID, req.Quantity, req.Reason, req.Reference, userID, login)
+	if err != nil {
+		RespondError(ctx, err)
+		return
+	}
+	ctx.JSON(http.StatusOK, item)
+}
+
+// Transfer
+// @Summary Transfer stock
+// @Description Move stock between bin locations (items.adjust)
+// @Tags items
+// @Accept json
+// @Produce json
+// @Param id path string true "Item UUID"
+// @Param body body dto.ItemTransferRequest true "Transfer"
+// @Success 200 {object} item.Item
+// @Failure 400 {object} dto.ErrorResponse
+// @Failure 404 {object} dto.ErrorResponse
+// @Failure 409 {object} dto.ErrorResponse
+// @Failure 422 {object} dto.ErrorResponse
+// @Failure 500 {object} dto.ErrorResponse
+// @Security BearerAuth
+// @Router /api/items/{id}/transfers [post]
+func (h *ItemHandler) Transfer(ctx *wbgin.Context) {
+	var req dto.ItemTransferRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		badRequest(ctx, err)
+		return
+	}
+	userID, login, ok := userFromContext(ctx)
+	if !ok {
+		return
+	}
+	item, err := h.Service.Transfer(ctx.Request.Context(), ctx.Param("id"), req.FromLocationID, req.ToLocationID, req.Quantity, req.Reason, req.Reference, userID, login)
+	if err != nil {
+		RespondError(ctx, err)
+		return
+	}
+	ctx.JSON(http.StatusOK, item)
+}
+
+// AdjustItem
+// @Summary Adjust item count
+// @Description Atomically add a signed delta to the item count. Going below zero is rejected unless force is set, which needs items.adjust_force (items.adjust)
+// @Tags items
+// @Accept json
+// @Produce json
+// @Param id path string true "Item UUID"
+// @Param body body dto.ItemAdjustRequest true "Adjustment"
+// @Success 200 {object} dto.ItemAdjustResponse
+// @Failure 400 {object} dto.ErrorResponse
+// @Failure 403 {object} dto.ErrorResponse
+// @Failure 404 {object} dto.ErrorResponse
+// @Failure 409 {object} dto.ErrorResponse
+// @Failure 422 {object} dto.ErrorResponse
+// @Failure 500 {object} dto.ErrorResponse
+// @Security BearerAuth
+// @Router /api/items/{id}/adjust [post]
+func (h *ItemHandler) AdjustItem(ctx *wbgin.Context) {
+	var req dto.ItemAdjustRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		badRequest(ctx, err)
+		return
+	}
+	userID, login, ok := userFromContext(ctx)
+	if !ok {
+		return
+	}
+	if req.Force {
+		if !hasPermission(ctx, user.PermItemsAdjustForce) {
+			RespondError(ctx, errs.Errorf(errs.ErrForbidden, "force override requires %s permission", user.PermItemsAdjustForce))
+			return
+		}
+	}
+	id := ctx.Param("id")
+	count, err := h.Service.Adjust(ctx.Request.Context(), id, req.LocationID, req.Delta, req.Force, req.Reason, req.Reference, userID, login)
+	if err != nil {
+		RespondError(ctx, err)
+		return
+	}
+	ctx.JSON(http.StatusOK, dto.ItemAdjustResponse{ID: id, Count: count})
+}
+
+// RevertItem
+// @Summary Revert item to a history version
+// @Description Restore the item from the old or new snapshot of a history record, re-creating it if it was deleted (items.revert). Recorded in history as 'reverted' with source_history_id. If-Match is optional; when present the current version must match
+// @Tags items
+// @Accept json
+// @Produce json
+// @Param id path string true "Item UUID"
+// @Param If-Match header string false "Item version (ETag)"
+// @Param body body dto.ItemRevertRequest true "History record and snapshot"
+// @Success 200 {object} item.Item
+// @Header 200 {string} ETag "New item version"
+// @Failure 400 {object} dto.ErrorResponse
+// @Failure 404 {object} dto.ErrorResponse
+// @Failure 409 {object} dto.ErrorResponse
+// @Failure 412 {object} map[string]interface{} "Stale version, current item in 'current'"
+// @Failure 422 {object} dto.ErrorResponse
+// @Failure 500 {object} dto.ErrorResponse
+// @Security BearerAuth
+// @Router /api/items/{id}/revert [post]
+func (h *ItemHandler) RevertItem(ctx *wbgin.Context) {
+	var req dto.ItemRevertRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		badRequest(ctx, err)
+		return
+	}
+	userID, login, ok := userFromContext(ctx)
+	if !ok {
+		return
+	}
+	version := 0
+	if ctx.GetHeader("If-Match") != "" {
+		version, ok = ifMatchVersion(ctx)
+		if !ok {
+			return
+		}
+	}
+	id := ctx.Param("id")
+	it, err := h.Service.Revert(ctx.Request.Context(), id, req.HistoryID, req.Snapshot, version, userID, login)
+	if err != nil {
+		if h.versionConflict(ctx, id, err) {
+			return
+		}
+		RespondError(ctx, err)
+		return
+	}
+	setETag(ctx, it)
+	ctx.JSON(http.StatusOK, it)
+}
+
+// DiffItem
+// @Summary Diff between two item versions
+// @Description Compare the item state at two points of its history and list the changes in between with their authors (history.read). A point is a history record ID (state right after it) or an RFC3339 time
+// @Tags items
+// @Produce json
+// @Param id path string true "Item UUID"
+// @Param from query string true "History record ID or RFC3339 time"
+// @Param to query string false "History record ID or RFC3339 time (default now)"
+// @Success 200 {object} history.VersionDiff
+// @Failure 400 {object} dto.ErrorResponse
+// @Failure 404 {object} dto.ErrorResponse
+// @Failure 422 {object} dto.ErrorResponse
+// @Failure 500 {object} dto.ErrorResponse
+// @Security BearerAuth
+// @Router /api/items/{id}/diff [get]
+func (h *ItemHandler) DiffItem(ctx *wbgin.Context) {
+	diff, err := h.Service.Diff(ctx.Request.Context(), ctx.Param("id"), ctx.Query("from"), ctx.Query("to"))
+	if err != nil {
+		RespondError(ctx, err)
+		return
+	}
+	ctx.JSON(http.StatusOK, diff)
+}
+
+// GetMovements
+// @Summary Item movement ledger
+// @Description Get stock movements of an item in chronological order
+// @Tags items
+// @Produce json
+// @Param id path string true "Item UUID"
+// @Success 200 {array} movement.Movement
+// @Failure 404 {object} dto.ErrorResponse
+// @Failure 500 {object} dto.ErrorResponse
+// @Security BearerAuth
+// @Router /api/items/{id}/movements [get]
+func (h *ItemHandler) GetMovements(ctx *wbgin.Context) {
+	movements, err := h.Service.GetMovements(ctx.Request.Context(), ctx.Param("id"))
+	if err != nil {
+		RespondError(ctx, err)
+		return
+	}
+	ctx.JSON(http.StatusOK, movements)
+}
+
+// userFromContext достаёт автора изменения, проставленного AuthMiddleware
+func userFromContext(ctx *wbgin.Context) (string, string, bool) {
+	userID, ok := ctx.Get("userId")
+	if !ok {
+		internalError(ctx, "userId not found in context")
+		return "", "", false
+	}
+	login, ok := ctx.Get("login")
+	if !ok {
+		internalError(ctx, "login not found in context")
+		return "", "", false
+	}
+	return userID.(string), login.(string), true
+}
+
+// hasPermission проверяет право, которое middleware положил в контекст запроса
+func hasPermission(ctx *wbgin.Context, perm user.Permission) bool {
+	v, _ := ctx.Get("permissions")
+	perms, ok := v.(user.Permissions)
+	return ok && perms.Has(perm)
+}
+
+func setETag(ctx *wbgin.Context, it *item.Item) {
+	ctx.Header("ETag", strconv.Quote(strconv.Itoa(it.Version)))
+}
+
+// parseAsOf разбирает момент времени для as_of в формате RFC3339
+func parseAsOf(raw string) (time.Time, error) {
+	asOf, err := time.Parse(time.RFC3339, raw)
+	if err != nil {
+		return time.Time{}, errs.New(errs.ErrInvalidInput, "invalid as_of, expected RFC3339 time")
+	}
+	return asOf, nil
+}
+
+// ifMatchVersion разбирает версию товара из If-Match ("3", W/"3" или 3)
+func ifMatchVersion(ctx *wbgin.Context) (int, bool) {
+	header := strings.TrimSpace(ctx.GetHeader("If-Match"))
+	if header == "" {
+		ctx.AbortWithStatusJSON(http.StatusPreconditionRequired, dto.ErrorResponse{Error: "If-Match header required", Code: CodePreconditionRequired})
+		return 0, false
+	}
+	header = strings.Trim(strings.TrimPrefix(header, "W/"), `"`)
+	version, err := strconv.Atoi(header)
+	if err != nil {
+		RespondError(ctx, errs.New(errs.ErrInvalidInput, "invalid If-Match header"))
+		return 0, false
+	}
+	return version, true
+}
+
+// versionConflict отвечает 412 с актуальным состоянием товара, если версия клиента устарела
+func (h *ItemHandler) versionConflict(ctx *wbgin.Context, id string, err error) bool {
+	if !errors.Is(err, item.ErrVersionMismatch) {
+		return false
+	}
+	current, getErr := h.Service.GetItem(ctx.Request.Context(), id)
+	if getErr != nil {
+		RespondError(ctx, getErr)
+		return true
+	}
+	setETag(ctx, current)
+	ctx.AbortWithStatusJSON(http.StatusPreconditionFailed, wbgin.H{"error": err.Error(), "code": CodePreconditionFailed, "current": current})
+	return true
+}
@@ -2,37 +2,89 @@ package handlers_test
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"errors"
 	"net/http"
 	"net/http/httptest"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
 	wbgin "github.com/wb-go/wbf/ginext"
 
+	"warehousecontrol/internal/domain/errs"
+	dhist "warehousecontrol/internal/domain/history"
 	ditem "warehousecontrol/internal/domain/item"
+	dmove "warehousecontrol/internal/domain/movement"
+	duser "warehousecontrol/internal/domain/user"
+	dwh "warehousecontrol/internal/domain/warehouse"
 	"warehousecontrol/internal/web/handlers"
 )
 
 type MockItemService struct {
 	CreateFn   func(name string, count int, price float64, userID string, login string) (*ditem.Item, error)
-	GetItemsFn func() ([]*ditem.Item, error)
+	GetItemsFn func(opts ditem.ListOptions) (*ditem.Page, error)
 	GetItemFn  func(id string) (*ditem.Item, error)
-	PutItemFn  func(id string, name string, count int, price float64, userID string, login string) (*ditem.Item, error)
-	DelItemFn  func(id string, userID string, login string) error
+	AsOfFn     func(id string, asOf time.Time) (*ditem.Item, error)
+	PutItemFn  func(id string, version int, name string, count int, price float64, userID string, login string) (*ditem.Item, error)
+	DelItemFn  func(id string, version int, userID string, login string) error
+	SetStockFn func(id string, locationID string, quantity int, userID string, login string) (*ditem.Item, error)
+	ReceiveFn  func(id string, locationID string, quantity int, reason string, reference string, userID string, login string) (*ditem.Item, error)
+	IssueFn    func(id string, locationID string, quantity int, reason string, reference string, userID string, login string) (*ditem.Item, error)
+	TransferFn func(id string, fromLocationID string, toLocationID string, quantity int, reason string, reference string, userID string, login string) (*ditem.Item, error)
+	AdjustFn   func(id string, locationID string, delta int, force bool, reason string, reference string, userID string, login string) (int, error)
+	MovesFn    func(id string) ([]*dmove.Movement, error)
+	RevertFn   func(id string, historyID string, snapshot string, version int, userID string, login string) (*ditem.Item, error)
+	DiffFn     func(id string, from string, to string) (*dhist.VersionDiff, error)
 }
 
-func (m *MockItemService) Create(name string, count int, price float64, userID string, login string) (*ditem.Item, error) {
+func (m *MockItemService) Diff(_ context.Context, id string, from string, to string) (*dhist.VersionDiff, error) {
+	return m.DiffFn(id, from, to)
+}
+
+func (m *MockItemService) Revert(_ context.Context, id string, historyID string, snapshot string, version int, userID string, login string) (*ditem.Item, error) {
+	return m.RevertFn(id, historyID, snapshot, version, userID, login)
+}
+
+func (m *MockItemService) Create(_ context.Context, name string, count int, price float64, userID string, login string) (*ditem.Item, error) {
 	return m.CreateFn(name, count, price, userID, login)
 }
-func (m *MockItemService) GetItems() ([]*ditem.Item, error)       { return m.GetItemsFn() }
-func (m *MockItemService) GetItem(id string) (*ditem.Item, error) { return m.GetItemFn(id) }
-func (m *MockItemService) PutItem(id string, name string, count int, price float64, userID string, login string) (*ditem.Item, error) {
-	return m.PutItemFn(id, name, count, price, userID, login)
+func (m *MockItemService) GetItems(_ context.Context, opts ditem.ListOptions) (*ditem.Page, error) {
+	return m.GetItemsFn(opts)
+}
+func (m *MockItemService) GetItem(_ context.Context, id string) (*ditem.Item, error) {
+	return m.GetItemFn(id)
+}
+func (m *MockItemService) GetItemAsOf(_ context.Context, id string, asOf time.Time) (*ditem.Item, error) {
+	return m.AsOfFn(id, asOf)
+}
+func (m *MockItemService) PutItem(_ context.Context, id string, version int, name string, count int, price float64, userID string, login string) (*ditem.Item, error) {
+	return m.PutItemFn(id, version, name, count, price, userID, login)
 }
-func (m *MockItemService) DeleteItem(id string, userID string, login string) error {
-	return m.DelItemFn(id, userID, login)
+func (m *MockItemService) DeleteItem(_ context.Context, id string, version int, userID string, login string) error {
+	return m.DelItemFn(id, version, userID, login)
+}
+
+func (m *MockItemService) SetStock(_ context.Context, id string, locationID string, quantity int, userID string, login string) (*ditem.Item, error) {
+	return m.SetStockFn(id, locationID, quantity, userID, login)
+}
+func (m *MockItemService) Receive(_ context.Context, id string, locationID string, quantity int, reason string, reference string, userID string, login string) (*ditem.Item, error) {
+	return m.ReceiveFn(id, locationID, quantity, reason, reference, userID, login)
+}
+func (m *MockItemService) Issue(_ context.Context, id string, locationID string, quantity int, reason string, reference string, userID string, login string) (*ditem.Item, error) {
+	return m.IssueFn(id, locationID, quantity, reason, reference, userID, login)
+}
+func (m *MockItemService) Transfer(_ context.Context, id string, fromLocationID string, toLocationID string, quantity int, reason string, reference string, userID string, login string) (*ditem.Item, error) {
+	return m.TransferFn(id, fromLocationID, toLocationID, quantity, reason, reference, userID, login)
+}
+func (m *MockItemService) Adjust(_ context.Context, id string, locationID string, delta int, force bool, reason string, reference string, userID string, login string) (int, error) {
+	return m.AdjustFn(id, locationID, delta, force, reason, reference, userID, login)
+}
+func (m *MockItemService) GetMovements(_ context.Context, id string) ([]*dmove.Movement, error) {
+	return m.MovesFn(id)
 }
 
 func performJSON(hf func(*wbgin.Context), method, path string, body any, setCtx func(*wbgin.Context)) *httptest.ResponseRecorder {
@@ -103,16 +155,55 @@ func TestItemHandler_CreateItem_ServiceError(t *testing.T) {
 }
 
 func TestItemHandler_GetItems_Success(t *testing.T) {
-	mock := &MockItemService{GetItemsFn: func() ([]*ditem.Item, error) { return []*ditem.Item{{Name: "A"}}, nil }}
+	var got ditem.ListOptions
+	mock := &MockItemService{GetItemsFn: func(opts ditem.ListOptions) (*ditem.Page, error) {
+		got = opts
+		return &ditem.Page{Items: []*ditem.Item{{Name: "A"}}, Total: 1}, nil
+	}}
 	h := handlers.NewItemHandler(mock)
-	rr := performJSON(h.GetItems, http.MethodGet, "/api/items", nil, nil)
+	rr := performJSON(h.GetItems, http.MethodGet, "/api/items?limit=10&sort=price&order=desc&name=bo&min_count=1&zero_stock=false&as_of=2026-01-31T23:59:59Z", nil, nil)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d", rr.Code)
+	}
+	if got.Limit != 10 || got.SortBy != "price" || !got.Desc || got.Name != "bo" || got.MinCount == nil || *got.MinCount != 1 || got.ZeroStock == nil || *got.ZeroStock || got.AsOf == nil {
+		t.Fatalf("unexpected options: %+v", got)
+	}
+	var page ditem.Page
+	_ = json.Unmarshal(rr.Body.Bytes(), &page)
+	if page.Total != 1 || len(page.Items) != 1 {
+		t.Fatalf("unexpected page: %s", rr.Body.String())
+	}
+}
+
+func TestItemHandler_GetItems_Cursor(t *testing.T) {
+	cursor := ditem.NewCursor(&ditem.Item{ID: uuid.New(), Name: "A"}, ditem.SortByName, false)
+	var got ditem.ListOptions
+	mock := &MockItemService{GetItemsFn: func(opts ditem.ListOptions) (*ditem.Page, error) {
+		got = opts
+		return &ditem.Page{}, nil
+	}}
+	h := handlers.NewItemHandler(mock)
+	rr := performJSON(h.GetItems, http.MethodGet, "/api/items?cursor="+cursor.Encode(), nil, nil)
 	if rr.Code != http.StatusOK {
 		t.Fatalf("expected 200, got %d", rr.Code)
 	}
+	if got.Cursor == nil || got.Cursor.ID != cursor.ID {
+		t.Fatalf("expected decoded cursor, got %+v", got.Cursor)
+	}
+}
+
+func TestItemHandler_GetItems_InvalidQuery(t *testing.T) {
+	h := handlers.NewItemHandler(&MockItemService{})
+	for _, q := range []string{"sort=id", "limit=abc", "cursor=bad!", "order=up"} {
+		rr := performJSON(h.GetItems, http.MethodGet, "/api/items?"+q, nil, nil)
+		if rr.Code != http.StatusBadRequest {
+			t.Fatalf("%s: expected 400, got %d", q, rr.Code)
+		}
+	}
 }
 
 func TestItemHandler_GetItems_ServiceError(t *testing.T) {
-	mock := &MockItemService{GetItemsFn: func() ([]*ditem.Item, error) { return nil, errors.New("svc err") }}
+	mock := &MockItemService{GetItemsFn: func(opts ditem.ListOptions) (*ditem.Page, error) { return nil, errors.New("svc err") }}
 	h := handlers.NewItemHandler(mock)
 	rr := performJSON(h.GetItems, http.MethodGet, "/api/items", nil, nil)
 	if rr.Code != http.StatusInternalServerError {
@@ -121,7 +212,7 @@ func TestItemHandler_GetItems_ServiceError(t *testing.T) {
 }
 
 func TestItemHandler_GetItem_Success(t *testing.T) {
-	mock := &MockItemService{GetItemFn: func(id string) (*ditem.Item, error) { return &ditem.Item{Name: "A"}, nil }}
+	mock := &MockItemService{GetItemFn: func(id string) (*ditem.Item, error) { return &ditem.Item{Name: "A", Version: 7}, nil }}
 	h := handlers.NewItemHandler(mock)
 	req := httptest.NewRequest(http.MethodGet, "/api/items/123", nil)
 	rr := httptest.NewRecorder()
@@ -132,6 +223,49 @@ func TestItemHandler_GetItem_Success(t *testing.T) {
 	if rr.Code != http.StatusOK {
 		t.Fatalf("expected 200, got %d", rr.Code)
 	}
+	if rr.Header().Get("ETag") != `"7"` {
+		t.Fatalf("expected ETag \"7\", got %q", rr.Header().Get("ETag"))
+	}
+}
+
+func TestItemHandler_GetItem_AsOf(t *testing.T) {
+	var gotAsOf time.Time
+	mock := &MockItemService{AsOfFn: func(id string, asOf time.Time) (*ditem.Item, error) {
+		gotAsOf = asOf
+		return &ditem.Item{Name: "A", Version: 3}, nil
+	}}
+	h := handlers.NewItemHandler(mock)
+	rr := httptest.NewRecorder()
+	ctx, _ := gin.CreateTestContext(rr)
+	ctx.Request = httptest.NewRequest(http.MethodGet, "/api/items/123?as_of=2026-01-31T23:59:59Z", nil)
+	ctx.AddParam("id", "123")
+	h.GetItem(ctx)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d", rr.Code)
+	}
+	if !gotAsOf.Equal(time.Date(2026, 1, 31, 23, 59, 59, 0, time.UTC)) {
+		t.Fatalf("unexpected as_of: %v", gotAsOf)
+	}
+	if rr.Header().Get("ETag") != "" {
+		t.Fatalf("expected no ETag for historical snapshot")
+	}
+}
+
+func TestItemHandler_AsOf_Invalid(t *testing.T) {
+	h := handlers.NewItemHandler(&MockItemService{})
+	rr := httptest.NewRecorder()
+	ctx, _ := gin.CreateTestContext(rr)
+	ctx.Request = httptest.NewRequest(http.MethodGet, "/api/items/123?as_of=2026-01-31", nil)
+	ctx.AddParam("id", "123")
+	h.GetItem(ctx)
+	if rr.Code != http.StatusBadRequest {
+		t.Fatalf("expected 400, got %d", rr.Code)
+	}
+
+	rr = performJSON(h.GetItems, http.MethodGet, "/api/items?as_of=yesterday", nil, nil)
+	if rr.Code != http.StatusBadRequest {
+		t.Fatalf("expected 400 for list, got %d", rr.Code)
+	}
 }
 
 func TestItemHandler_GetItem_ServiceError(t *testing.T) {
@@ -149,14 +283,17 @@ func TestItemHandler_GetItem_ServiceError(t *testing.T) {
 }
 
 func TestItemHandler_PutItem_Success(t *testing.T) {
-	mock := &MockItemService{PutItemFn: func(id string, name string, count int, price float64, userID string, login string) (*ditem.Item, error) {
-		return &ditem.Item{Name: name, Count: count, Price: price}, nil
+	var gotVersion int
+	mock := &MockItemService{PutItemFn: func(id string, version int, name string, count int, price float64, userID string, login string) (*ditem.Item, error) {
+		gotVersion = version
+		return &ditem.Item{Name: name, Count: count, Price: price, Version: version + 1}, nil
 	}}
 	h := handlers.NewItemHandler(mock)
 	body := map[string]any{"name": "B", "count": 2, "price": 2.5}
 	reqBody, _ := json.Marshal(body)
 	req := httptest.NewRequest(http.MethodPut, "/api/items/123", bytes.NewReader(reqBody))
 	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("If-Match", `"3"`)
 	rr := httptest.NewRecorder()
 	ctx, _ := gin.CreateTestContext(rr)
 	ctx.Request = req
@@ -167,6 +304,50 @@ func TestItemHandler_PutItem_Success(t *testing.T) {
 	if rr.Code != http.StatusOK {
 		t.Fatalf("expected 200, got %d", rr.Code)
 	}
+	if gotVersion != 3 {
+		t.Fatalf("expected version 3 from If-Match, got %d", gotVersion)
+	}
+	if rr.Header().Get("ETag") != `"4"` {
+		t.Fatalf("expected new ETag, got %q", rr.Header().Get("ETag"))
+	}
+}
+
+func TestItemHandler_PutItem_MissingIfMatch(t *testing.T) {
+	h := handlers.NewItemHandler(&MockItemService{})
+	body := map[string]any{"name": "B", "count": 2, "price": 2.5}
+	rr := performJSON(h.PutItem, http.MethodPut, "/api/items/123", body, func(c *wbgin.Context) { c.AddParam("id", "123"); c.Set("userId", "uid"); c.Set("login", "john") })
+	if rr.Code != http.StatusPreconditionRequired {
+		t.Fatalf("expected 428, got %d", rr.Code)
+	}
+}
+
+func TestItemHandler_PutItem_StaleVersion(t *testing.T) {
+	mock := &MockItemService{
+		PutItemFn: func(id string, version int, name string, count int, price float64, userID string, login string) (*ditem.Item, error) {
+			return nil, ditem.ErrVersionMismatch
+		},
+		GetItemFn: func(id string) (*ditem.Item, error) {
+			return &ditem.Item{Name: "Current", Version: 5}, nil
+		},
+	}
+	h := handlers.NewItemHandler(mock)
+	body := map[string]any{"name": "B", "count": 2, "price": 2.5}
+	rr := performJSON(h.PutItem, http.MethodPut, "/api/items/123", body, func(c *wbgin.Context) {
+		c.AddParam("id", "123")
+		c.Set("userId", "uid")
+		c.Set("login", "john")
+		c.Request.Header.Set("If-Match", `"4"`)
+	})
+	if rr.Code != http.StatusPreconditionFailed {
+		t.Fatalf("expected 412, got %d", rr.Code)
+	}
+	var resp struct {
+		Current ditem.Item `json:"current"`
+	}
+	_ = json.Unmarshal(rr.Body.Bytes(), &resp)
+	if resp.Current.Name != "Current" || resp.Current.Version != 5 {
+		t.Fatalf("expected current item in body, got %s", rr.Body.String())
+	}
 }
 
 func TestItemHandler_PutItem_InvalidJSON(t *testing.T) {
@@ -193,21 +374,27 @@ func TestItemHandler_PutItem_MissingContext(t *testing.T) {
 }
 
 func TestItemHandler_PutItem_ServiceError(t *testing.T) {
-	mock := &MockItemService{PutItemFn: func(id string, name string, count int, price float64, userID string, login string) (*ditem.Item, error) {
+	mock := &MockItemService{PutItemFn: func(id string, version int, name string, count int, price float64, userID string, login string) (*ditem.Item, error) {
 		return nil, errors.New("svc err")
 	}}
 	h := handlers.NewItemHandler(mock)
 	body := map[string]any{"name": "B", "count": 2, "price": 2.5}
-	rr := performJSON(h.PutItem, http.MethodPut, "/api/items/123", body, func(c *wbgin.Context) { c.AddParam("id", "123"); c.Set("userId", "uid"); c.Set("login", "john") })
+	rr := performJSON(h.PutItem, http.MethodPut, "/api/items/123", body, func(c *wbgin.Context) {
+		c.AddParam("id", "123")
+		c.Set("userId", "uid")
+		c.Set("login", "john")
+		c.Request.Header.Set("If-Match", `"1"`)
+	})
 	if rr.Code != http.StatusInternalServerError {
 		t.Fatalf("expected 500, got %d", rr.Code)
 	}
 }
 
 func TestItemHandler_DeleteItem_Success(t *testing.T) {
-	mock := &MockItemService{DelItemFn: func(id string, userID string, login string) error { return nil }}
+	mock := &MockItemService{DelItemFn: func(id string, version int, userID string, login string) error { return nil }}
 	h := handlers.NewItemHandler(mock)
 	req := httptest.NewRequest(http.MethodDelete, "/api/items/123", nil)
+	req.Header.Set("If-Match", `W/"2"`)
 	rr := httptest.NewRecorder()
 	ctx, _ := gin.CreateTestContext(rr)
 	ctx.Request = req
@@ -220,6 +407,19 @@ func TestItemHandler_DeleteItem_Success(t *testing.T) {
 	}
 }
 
+func TestItemHandler_DeleteItem_InvalidIfMatch(t *testing.T) {
+	h := handlers.NewItemHandler(&MockItemService{})
+	rr := performJSON(h.DeleteItem, http.MethodDelete, "/api/items/123", nil, func(c *wbgin.Context) {
+		c.AddParam("id", "123")
+		c.Set("userId", "uid")
+		c.Set("login", "john")
+		c.Request.Header.Set("If-Match", "*")
+	})
+	if rr.Code != http.StatusBadRequest {
+		t.Fatalf("expected 400, got %d", rr.Code)
+	}
+}
+
 func TestItemHandler_DeleteItem_MissingContext(t *testing.T) {
 	h := handlers.NewItemHandler(&MockItemService{})
 	req := httptest.NewRequest(http.MethodDelete, "/api/items/123", nil)
@@ -234,9 +434,10 @@ func TestItemHandler_DeleteItem_MissingContext(t *testing.T) {
 }
 
 func TestItemHandler_DeleteItem_ServiceError(t *testing.T) {
-	mock := &MockItemService{DelItemFn: func(id string, userID string, login string) error { return errors.New("svc err") }}
+	mock := &MockItemService{DelItemFn: func(id string, version int, userID string, login string) error { return errors.New("svc err") }}
 	h := handlers.NewItemHandler(mock)
 	req := httptest.NewRequest(http.MethodDelete, "/api/items/123", nil)
+	req.Header.Set("If-Match", "2")
 	rr := httptest.NewRecorder()
 	ctx, _ := gin.CreateTestContext(rr)
 	ctx.Request = req
@@ -248,3 +449,241 @@ func TestItemHandler_DeleteItem_ServiceError(t *testing.T) {
 		t.Fatalf("expected 500, got %d", rr.Code)
 	}
 }
+
+func TestItemHandler_SetStock_Success(t *testing.T) {
+	mock := &MockItemService{SetStockFn: func(id string, locationID string, quantity int, userID string, login string) (*ditem.Item, error) {
+		return &ditem.Item{Name: "A", Count: quantity}, nil
+	}}
+	h := handlers.NewItemHandler(mock)
+	body := map[string]any{"location_id": "loc", "quantity": 0}
+	rr := performJSON(h.SetStock, http.MethodPut, "/api/items/123/stock", body, func(c *wbgin.Context) { c.AddParam("id", "123"); c.Set("userId", "uid"); c.Set("login", "john") })
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d", rr.Code)
+	}
+}
+
+func TestItemHandler_SetStock_NegativeQuantity(t *testing.T) {
+	h := handlers.NewItemHandler(&MockItemService{})
+	body := map[string]any{"location_id": "loc", "quantity": -1}
+	rr := performJSON(h.SetStock, http.MethodPut, "/api/items/123/stock", body, func(c *wbgin.Context) { c.AddParam("id", "123"); c.Set("userId", "uid"); c.Set("login", "john") })
+	if rr.Code != http.StatusBadRequest {
+		t.Fatalf("expected 400, got %d", rr.Code)
+	}
+}
+
+func TestItemHandler_SetStock_ServiceError(t *testing.T) {
+	mock := &MockItemService{SetStockFn: func(id string, locationID string, quantity int, userID string, login string) (*ditem.Item, error) {
+		return nil, errors.New("svc err")
+	}}
+	h := handlers.NewItemHandler(mock)
+	body := map[string]any{"location_id": "loc", "quantity": 2}
+	rr := performJSON(h.SetStock, http.MethodPut, "/api/items/123/stock", body, func(c *wbgin.Context) { c.AddParam("id", "123"); c.Set("userId", "uid"); c.Set("login", "john") })
+	if rr.Code != http.StatusInternalServerError {
+		t.Fatalf("expected 500, got %d", rr.Code)
+	}
+}
+
+func TestItemHandler_Receive_Success(t *testing.T) {
+	var gotQty int
+	mock := &MockItemService{ReceiveFn: func(id string, locationID string, quantity int, reason string, reference string, userID string, login string) (*ditem.Item, error) {
+		gotQty = quantity
+		return &ditem.Item{Name: "A", Count: quantity}, nil
+	}}
+	h := handlers.NewItemHandler(mock)
+	body := map[string]any{"quantity": 4, "reason": "supply", "reference": "INV-1"}
+	rr := performJSON(h.Receive, http.MethodPost, "/api/items/123/receipts", body, func(c *wbgin.Context) { c.AddParam("id", "123"); c.Set("userId", "uid"); c.Set("login", "john") })
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d", rr.Code)
+	}
+	if gotQty != 4 {
+		t.Fatalf("expected quantity 4, got %d", gotQty)
+	}
+}
+
+func TestItemHandler_Receive_MissingLocation(t *testing.T) {
+	mock := &MockItemService{ReceiveFn: func(id string, locationID string, quantity int, reason string, reference string, userID string, login string) (*ditem.Item, error) {
+		return nil, dwh.ErrLocationNotFound
+	}}
+	h := handlers.NewItemHandler(mock)
+	body := map[string]any{"location_id": uuid.NewString(), "quantity": 4}
+	rr := performJSON(h.Receive, http.MethodPost, "/api/items/123/receipts", body, func(c *wbgin.Context) { c.AddParam("id", "123"); c.Set("userId", "uid"); c.Set("login", "john") })
+	if rr.Code != http.StatusNotFound {
+		t.Fatalf("expected 404, got %d", rr.Code)
+	}
+}
+
+func TestItemHandler_Issue_BelowLocationStock(t *testing.T) {
+	mock := &MockItemService{IssueFn: func(id string, locationID string, quantity int, reason string, reference string, userID string, login string) (*ditem.Item, error) {
+		return nil, ditem.ErrStockBelowLocations
+	}}
+	h := handlers.NewItemHandler(mock)
+	body := map[string]any{"quantity": 5}
+	rr := performJSON(h.Issue, http.MethodPost, "/api/items/123/issues", body, func(c *wbgin.Context) { c.AddParam("id", "123"); c.Set("userId", "uid"); c.Set("login", "john") })
+	if rr.Code != http.StatusConflict {
+		t.Fatalf("expected 409, got %d", rr.Code)
+	}
+}
+
+func TestItemHandler_Issue_ZeroQuantity(t *testing.T) {
+	h := handlers.NewItemHandler(&MockItemService{})
+	body := map[string]any{"quantity": 0}
+	rr := performJSON(h.Issue, http.MethodPost, "/api/items/123/issues", body, func(c *wbgin.Context) { c.AddParam("id", "123"); c.Set("userId", "uid"); c.Set("login", "john") })
+	if rr.Code != http.StatusBadRequest {
+		t.Fatalf("expected 400, got %d", rr.Code)
+	}
+}
+
+func TestItemHandler_Transfer_MissingContext(t *testing.T) {
+	h := handlers.NewItemHandler(&MockItemService{})
+	body := map[string]any{"from_location_id": "a", "to_location_id": "b", "quantity": 1}
+	rr := performJSON(h.Transfer, http.MethodPost, "/api/items/123/transfers", body, func(c *wbgin.Context) { c.AddParam("id", "123") })
+	if rr.Code != http.StatusInternalServerError {
+		t.Fatalf("expected 500, got %d", rr.Code)
+	}
+}
+
+func TestItemHandler_GetMovements_Success(t *testing.T) {
+	mock := &MockItemService{MovesFn: func(id string) ([]*dmove.Movement, error) { return []*dmove.Movement{}, nil }}
+	h := handlers.NewItemHandler(mock)
+	rr := performJSON(h.GetMovements, http.MethodGet, "/api/items/123/movements", nil, func(c *wbgin.Context) { c.AddParam("id", "123") })
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d", rr.Code)
+	}
+}
+
+func TestItemHandler_AdjustItem_Success(t *testing.T) {
+	mock := &MockItemService{AdjustFn: func(id string, locationID string, delta int, force bool, reason string, reference string, userID string, login string) (int, error) {
+		return 10 + delta, nil
+	}}
+	h := handlers.NewItemHandler(mock)
+	body := map[string]any{"delta": -3, "reason": "recount"}
+	rr := performJSON(h.AdjustItem, http.MethodPost, "/api/items/123/adjust", body, func(c *wbgin.Context) {
+		c.AddParam("id", "123")
+		c.Set("userId", "uid")
+		c.Set("login", "john")
+		c.Set("permissions", duser.Permissions{duser.PermItemsRead, duser.PermItemsAdjust})
+	})
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d", rr.Code)
+	}
+	var resp map[string]any
+	_ = json.Unmarshal(rr.Body.Bytes(), &resp)
+	if resp["count"] != float64(7) || resp["id"] != "123" {
+		t.Fatalf("unexpected response: %v", resp)
+	}
+}
+
+func TestItemHandler_AdjustItem_ForceRequiresPermission(t *testing.T) {
+	h := handlers.NewItemHandler(&MockItemService{})
+	body := map[string]any{"delta": -30, "force": true}
+	rr := performJSON(h.AdjustItem, http.MethodPost, "/api/items/123/adjust", body, func(c *wbgin.Context) {
+		c.AddParam("id", "123")
+		c.Set("userId", "uid")
+		c.Set("login", "john")
+		c.Set("permissions", duser.Permissions{duser.PermItemsRead, duser.PermItemsAdjust})
+	})
+	if rr.Code != http.StatusForbidden {
+		t.Fatalf("expected 403, got %d", rr.Code)
+	}
+}
+
+func TestItemHandler_AdjustItem_ForceWithPermission(t *testing.T) {
+	var gotForce bool
+	mock := &MockItemService{AdjustFn: func(id string, locationID string, delta int, force bool, reason string, reference string, userID string, login string) (int, error) {
+		gotForce = force
+		return -20, nil
+	}}
+	h := handlers.NewItemHandler(mock)
+	body := map[string]any{"delta": -30, "force": true}
+	rr := performJSON(h.AdjustItem, http.MethodPost, "/api/items/123/adjust", body, func(c *wbgin.Context) {
+		c.AddParam("id", "123")
+		c.Set("userId", "uid")
+		c.Set("login", "john")
+		c.Set("permissions", duser.Permissions{duser.PermItemsAdjust, duser.PermItemsAdjustForce})
+	})
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d", rr.Code)
+	}
+	if !gotForce {
+		t.Fatalf("expected force to be passed to service")
+	}
+}
+
+func TestItemHandler_AdjustItem_ZeroDelta(t *testing.T) {
+	h := handlers.NewItemHandler(&MockItemService{})
+	rr := performJSON(h.AdjustItem, http.MethodPost, "/api/items/123/adjust", map[string]any{"delta": 0}, func(c *wbgin.Context) { c.AddParam("id", "123") })
+	if rr.Code != http.StatusBadRequest {
+		t.Fatalf("expected 400, got %d", rr.Code)
+	}
+}
+
+func TestItemHandler_RevertItem(t *testing.T) {
+	var gotHistory, gotSnapshot string
+	var gotVersion int
+	mock := &MockItemService{RevertFn: func(id string, historyID string, snapshot string, version int, userID string, login string) (*ditem.Item, error) {
+		gotHistory, gotSnapshot, gotVersion = historyID, snapshot, version
+		return &ditem.Item{Name: "A", Version: 6}, nil
+	}}
+	h := handlers.NewItemHandler(mock)
+	setCtx := func(c *wbgin.Context) { c.Set("userId", "uid"); c.Set("login", "john") }
+
+	rr := performJSON(h.RevertItem, http.MethodPost, "/api/items/123/revert", map[string]any{"history_id": "h1", "snapshot": "old"}, setCtx)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d", rr.Code)
+	}
+	if gotHistory != "h1" || gotSnapshot != "old" || gotVersion != 0 {
+		t.Fatalf("unexpected args: %s %s %d", gotHistory, gotSnapshot, gotVersion)
+	}
+	if rr.Header().Get("ETag") != `"6"` {
+		t.Fatalf("expected ETag, got %q", rr.Header().Get("ETag"))
+	}
+
+	rr = performJSON(h.RevertItem, http.MethodPost, "/api/items/123/revert", map[string]any{"history_id": "h1"}, func(c *wbgin.Context) {
+		setCtx(c)
+		c.Request.Header.Set("If-Match", `"5"`)
+	})
+	if rr.Code != http.StatusOK || gotVersion != 5 {
+		t.Fatalf("expected If-Match version to be passed, got %d, %d", rr.Code, gotVersion)
+	}
+}
+
+func TestItemHandler_RevertItem_BadRequest(t *testing.T) {
+	h := handlers.NewItemHandler(&MockItemService{})
+	setCtx := func(c *wbgin.Context) { c.Set("userId", "uid"); c.Set("login", "john") }
+	for _, body := range []map[string]any{{}, {"history_id": "h1", "snapshot": "latest"}} {
+		rr := performJSON(h.RevertItem, http.MethodPost, "/api/items/123/revert", body, setCtx)
+		if rr.Code != http.StatusBadRequest {
+			t.Fatalf("%v: expected 400, got %d", body, rr.Code)
+		}
+	}
+}
+
+func TestItemHandler_DiffItem(t *testing.T) {
+	var gotFrom, gotTo string
+	mock := &MockItemService{DiffFn: func(id string, from string, to string) (*dhist.VersionDiff, error) {
+		gotFrom, gotTo = from, to
+		return &dhist.VersionDiff{Diff: ditem.ItemDiff{"count": {Old: 1, New: 5}}, Changes: []*dhist.History{}}, nil
+	}}
+	h := handlers.NewItemHandler(mock)
+	rr := performJSON(h.DiffItem, http.MethodGet, "/api/items/123/diff?from=2026-01-05T00:00:00Z&to=2026-01-09T23:59:59Z", nil, nil)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d", rr.Code)
+	}
+	if gotFrom != "2026-01-05T00:00:00Z" || gotTo != "2026-01-09T23:59:59Z" {
+		t.Fatalf("unexpected points: %s %s", gotFrom, gotTo)
+	}
+	if !strings.Contains(rr.Body.String(), `"item_diff":{"count"`) {
+		t.Fatalf("expected aggregated diff, got %s", rr.Body.String())
+	}
+}
+
+func TestItemHandler_DiffItem_ServiceError(t *testing.T) {
+	mock := &MockItemService{DiffFn: func(id string, from string, to string) (*dhist.VersionDiff, error) {
+		return nil, errs.New(errs.ErrInvalidInput, "from required")
+	}}
+	h := handlers.NewItemHandler(mock)
+	rr := performJSON(h.DiffItem, http.MethodGet, "/api/items/123/diff", nil, nil)
+	if rr.Code != http.StatusBadRequest {
+		t.Fatalf("expected 400, got %d", rr.Code)
+	}
+}
@@ -0,0 +1,54 @@
+package handlers
+
+import (
+	"net/http"
+
+	"warehousecontrol/internal/auth"
+	"warehousecontrol/internal/web/dto"
+
+	wbgin "github.com/wb-go/wbf/ginext"
+)
+
+// jwksMaxAge — сколько проверяющие сервисы могут кэшировать набор ключей; новый ключ
+// публикуется заранее, поэтому кэш не мешает ротации
+const jwksMaxAge = "max-age=300"
+
+type KeysHandler struct {
+	Service KeysIFace
+}
+
+type KeysIFace interface {
+	JWKS() []auth.JWK
+}
+
+func NewKeysHandler(service KeysIFace) *KeysHandler {
+	return &KeysHandler{
+		Service: service,
+	}
+}
+
+// GetJWKS
+// @Summary JSON Web Key Set
+// @Description Public keys for verifying access tokens (RS256/EdDSA), selected by the kid token header. Keys are published before they start signing and removed after retirement. Empty when tokens are signed with a shared HS256 secret
+// @Tags auth
+// @Produce json
+// @Success 200 {object} dto.JWKSResponse
+// @Router /.well-known/jwks.json [get]
+func (h *KeysHandler) GetJWKS(ctx *wbgin.Context) {
+	keys := h.Service.JWKS()
+	res := dto.JWKSResponse{Keys: make([]dto.JWK, 0, len(keys))}
+	for _, k := range keys {
+		res.Keys = append(res.Keys, dto.JWK{
+			KeyType:   k.KeyType,
+			KeyID:     k.KeyID,
+			Use:       k.Use,
+			Algorithm: k.Algorithm,
+			N:         k.N,
+			E:         k.E,
+			Curve:     k.Curve,
+			X:         k.X,
+		})
+	}
+	ctx.Header("Cache-Control", "public, "+jwksMaxAge)
+	ctx.JSON(http.StatusOK, res)
+}
@@ -0,0 +1,45 @@
+package handlers_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"warehousecontrol/internal/auth"
+	"warehousecontrol/internal/web/dto"
+	"warehousecontrol/internal/web/handlers"
+)
+
+type mockKeys struct {
+	keys []auth.JWK
+}
+
+func (m *mockKeys) JWKS() []auth.JWK {
+	return m.keys
+}
+
+func TestKeysHandler_GetJWKS(t *testing.T) {
+	h := handlers.NewKeysHandler(&mockKeys{keys: []auth.JWK{
+		{KeyType: "OKP", KeyID: "2026-10", Use: "sig", Algorithm: "EdDSA", Curve: "Ed25519", X: "abc"},
+	}})
+
+	rr := performJSON(h.GetJWKS, http.MethodGet, "/.well-known/jwks.json", nil, nil)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d", rr.Code)
+	}
+	if rr.Header().Get("Cache-Control") == "" {
+		t.Fatal("expected Cache-Control header")
+	}
+	var res dto.JWKSResponse
+	_ = json.Unmarshal(rr.Body.Bytes(), &res)
+	if len(res.Keys) != 1 || res.Keys[0].KeyID != "2026-10" || res.Keys[0].Curve != "Ed25519" || res.Keys[0].N != "" {
+		t.Fatalf("unexpected response: %s", rr.Body.String())
+	}
+
+	// без асимметричных ключей набор пуст, но остаётся массивом
+	h = handlers.NewKeysHandler(&mockKeys{})
+	rr = performJSON(h.GetJWKS, http.MethodGet, "/.well-known/jwks.json", nil, nil)
+	if rr.Body.String() != `{"keys":[]}` {
+		t.Fatalf("unexpected response: %s", rr.Body.String())
+	}
+}
@@ -0,0 +1,95 @@
+package handlers
+
+import (
+	"net/http"
+
+	"warehousecontrol/internal/web/dto"
+
+	wbgin "github.com/wb-go/wbf/ginext"
+)
+
+// ChangePassword
+// @Summary Change my password
+// @Description Change the current user's password. All sessions are signed out and tokens of a new session are returned. A wrong current password counts towards the login lockout
+// @Tags users
+// @Accept json
+// @Produce json
+// @Param body body dto.PasswordChangeRequest true "Current and new password"
+// @Success 200 {object} dto.JWTResponse
+// @Failure 400 {object} dto.ErrorResponse
+// @Failure 403 {object} dto.ErrorResponse "Wrong current password"
+// @Failure 422 {object} dto.ErrorResponse
+// @Failure 429 {object} dto.ErrorResponse
+// @Security BearerAuth
+// @Router /api/auth/password [post]
+func (h *UserHandler) ChangePassword(ctx *wbgin.Context) {
+	var req dto.PasswordChangeRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		badRequest(ctx, err)
+		return
+	}
+	userID, _, ok := userFromContext(ctx)
+	if !ok {
+		return
+	}
+	jwtResp, err := h.Service.ChangePassword(ctx.Request.Context(), userID, req.CurrentPassword, req.NewPassword, clientInfo(ctx))
+	if err != nil {
+		RespondError(ctx, err)
+		return
+	}
+	ctx.JSON(http.StatusOK, dto.JWTResponse{
+		AccessToken:  jwtResp.AccessToken,
+		RefreshToken: jwtResp.RefreshToken,
+		SessionID:    jwtResp.SessionID,
+	})
+}
+
+// ResetPasswordWithToken
+// @Summary Reset password by token
+// @Description Set a new password using a reset token issued by an admin. The token is single-use; all sessions of the user are signed out
+// @Tags users
+// @Accept json
+// @Produce json
+// @Param body body dto.PasswordResetRequest true "Reset token and new password"
+// @Success 200 {object} map[string]string
+// @Failure 400 {object} dto.ErrorResponse
+// @Failure 401 {object} dto.ErrorResponse "Invalid, expired or used token"
+// @Failure 422 {object} dto.ErrorResponse
+// @Router /api/auth/password/reset [post]
+func (h *UserHandler) ResetPasswordWithToken(ctx *wbgin.Context) {
+	var req dto.PasswordResetRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		badRequest(ctx, err)
+		return
+	}
+	err := h.Service.ResetPasswordWithToken(ctx.Request.Context(), req.Token, req.NewPassword)
+	if err != nil {
+		RespondError(ctx, err)
+		return
+	}
+	ctx.JSON(http.StatusOK, wbgin.H{"status": "password changed"})
+}
+
+// IssuePasswordResetToken
+// @Summary Issue password reset token
+// @Description Issue a single-use, time-limited password reset token for a user (users.manage). The token is returned only once; previously issued tokens stop working
+// @Tags users-admin
+// @Produce json
+// @Param id path string true "User UUID"
+// @Success 201 {object} dto.PasswordResetTokenResponse
+// @Failure 400 {object} dto.ErrorResponse
+// @Failure 404 {object} dto.ErrorResponse
+// @Security BearerAuth
+// @Router /api/users/{id}/password-reset-token [post]
+func (h *UserHandler) IssuePasswordResetToken(ctx *wbgin.Context) {
+	actorID, actorLogin, ok := userFromContext(ctx)
+	if !ok {
+		return
+	}
+	rt, token, err := h.Service.IssuePasswordResetToken(ctx.Request.Context(), ctx.Param("id"), actorID, actorLogin)
+	if err != nil {
+		RespondError(ctx, err)
+		return
+	}
+	ctx.JSON(http.StatusCreated, dto.PasswordResetTokenResponse{Token: token, ExpiresAt: rt.ExpiresAt})
+}
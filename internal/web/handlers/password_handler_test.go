@@ -0,0 +1,92 @@
+package handlers_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+	"time"
+
+	"warehousecontrol/internal/auth"
+	"warehousecontrol/internal/domain/user"
+	"warehousecontrol/internal/web/dto"
+	"warehousecontrol/internal/web/handlers"
+
+	wbgin "github.com/wb-go/wbf/ginext"
+)
+
+func TestUserHandler_ChangePassword(t *testing.T) {
+	mock := &MockUserService{
+		ChangePasswordFn: func(userID, current, next string, client user.Client) (*auth.JWTResponse, error) {
+			if userID != "user-id" || current != "Password1" || next != "Newpass22" {
+				t.Fatalf("unexpected args %q %q %q", userID, current, next)
+			}
+			return &auth.JWTResponse{AccessToken: "a", RefreshToken: "r", SessionID: "s"}, nil
+		},
+	}
+	h := handlers.NewUserHandler(mock)
+	asUser := func(c *wbgin.Context) {
+		c.Set("userId", "user-id")
+		c.Set("login", "bob")
+	}
+
+	rr := performJSON(h.ChangePassword, http.MethodPost, "/api/auth/password", dto.PasswordChangeRequest{CurrentPassword: "Password1", NewPassword: "Newpass22"}, asUser)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d", rr.Code)
+	}
+	var res dto.JWTResponse
+	_ = json.Unmarshal(rr.Body.Bytes(), &res)
+	if res.AccessToken != "a" || res.SessionID != "s" {
+		t.Fatalf("unexpected response: %s", rr.Body.String())
+	}
+
+	rr = performJSON(h.ChangePassword, http.MethodPost, "/api/auth/password", map[string]string{"new_password": "Newpass22"}, asUser)
+	if rr.Code != http.StatusBadRequest {
+		t.Fatalf("expected 400 without current password, got %d", rr.Code)
+	}
+
+	mock.ChangePasswordFn = func(userID, current, next string, client user.Client) (*auth.JWTResponse, error) {
+		return nil, user.ErrWrongPassword
+	}
+	rr = performJSON(h.ChangePassword, http.MethodPost, "/api/auth/password", dto.PasswordChangeRequest{CurrentPassword: "x", NewPassword: "Newpass22"}, asUser)
+	if rr.Code != http.StatusForbidden {
+		t.Fatalf("expected 403 for wrong password, got %d", rr.Code)
+	}
+}
+
+func TestUserHandler_PasswordResetToken(t *testing.T) {
+	expires := time.Now().Add(time.Hour)
+	mock := &MockUserService{
+		IssuePasswordResetTokenFn: func(id, actorID, actorLogin string) (*user.PasswordResetToken, string, error) {
+			if id != "target" || actorLogin != "admin" {
+				t.Fatalf("unexpected args %q %q", id, actorLogin)
+			}
+			return &user.PasswordResetToken{ExpiresAt: expires}, "secret", nil
+		},
+		ResetPasswordWithTokenFn: func(token, password string) error {
+			if token != "secret" {
+				return user.ErrResetTokenInvalid
+			}
+			return nil
+		},
+	}
+	h := handlers.NewUserHandler(mock)
+
+	rr := performJSON(h.IssuePasswordResetToken, http.MethodPost, "/api/users/target/password-reset-token", nil, asAdmin("target"))
+	if rr.Code != http.StatusCreated {
+		t.Fatalf("expected 201, got %d", rr.Code)
+	}
+	var res dto.PasswordResetTokenResponse
+	_ = json.Unmarshal(rr.Body.Bytes(), &res)
+	if res.Token != "secret" || !res.ExpiresAt.Equal(expires) {
+		t.Fatalf("unexpected response: %s", rr.Body.String())
+	}
+
+	rr = performJSON(h.ResetPasswordWithToken, http.MethodPost, "/api/auth/password/reset", dto.PasswordResetRequest{Token: "secret", NewPassword: "Newpass22"}, nil)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d", rr.Code)
+	}
+	rr = performJSON(h.ResetPasswordWithToken, http.MethodPost, "/api/auth/password/reset", dto.PasswordResetRequest{Token: "used", NewPassword: "Newpass22"}, nil)
+	if rr.Code != http.StatusUnauthorized {
+		t.Fatalf("expected 401 for invalid token, got %d", rr.Code)
+	}
+}
@@ -0,0 +1,154 @@
+package handlers
+
+import (
+	"net/http"
+
+	"warehousecontrol/internal/domain/user"
+	"warehousecontrol/internal/web/dto"
+
+	wbgin "github.com/wb-go/wbf/ginext"
+)
+
+// GetRoles
+// @Summary List roles
+// @Description Built-in and custom roles with their permissions, built-in first (roles.manage)
+// @Tags roles
+// @Produce json
+// @Success 200 {array} dto.RoleResponse
+// @Security BearerAuth
+// @Router /api/roles [get]
+func (h *UserHandler) GetRoles(ctx *wbgin.Context) {
+	roles, err := h.Service.GetRoles(ctx.Request.Context())
+	if err != nil {
+		RespondError(ctx, err)
+		return
+	}
+	res := make([]dto.RoleResponse, 0, len(roles))
+	for _, r := range roles {
+		res = append(res, roleResponse(r))
+	}
+	ctx.JSON(http.StatusOK, res)
+}
+
+// GetRole
+// @Summary Get role
+// @Description Get a role with its permissions (roles.manage)
+// @Tags roles
+// @Produce json
+// @Param name path string true "Role name"
+// @Success 200 {object} dto.RoleResponse
+// @Failure 404 {object} dto.ErrorResponse
+// @Security BearerAuth
+// @Router /api/roles/{name} [get]
+func (h *UserHandler) GetRole(ctx *wbgin.Context) {
+	r, err := h.Service.GetRole(ctx.Request.Context(), ctx.Param("name"))
+	if err != nil {
+		RespondError(ctx, err)
+		return
+	}
+	ctx.JSON(http.StatusOK, roleResponse(r))
+}
+
+// CreateRole
+// @Summary Create role
+// @Description Create a custom role as a named set of permissions (roles.manage). Permissions: items.read, items.create, items.update, items.delete, items.adjust, items.adjust_force, items.revert, warehouses.read, warehouses.manage, history.read, users.manage, roles.manage
+// @Tags roles
+// @Accept json
+// @Produce json
+// @Param body body dto.RoleCreateRequest true "Name, description and permissions"
+// @Success 201 {object} dto.RoleResponse
+// @Failure 400 {object} dto.ErrorResponse
+// @Failure 409 {object} dto.ErrorResponse "Role exists"
+// @Failure 422 {object} dto.ErrorResponse
+// @Security BearerAuth
+// @Router /api/roles [post]
+func (h *UserHandler) CreateRole(ctx *wbgin.Context) {
+	var req dto.RoleCreateRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		badRequest(ctx, err)
+		return
+	}
+	_, actorLogin, ok := userFromContext(ctx)
+	if !ok {
+		return
+	}
+	r, err := h.Service.CreateRole(ctx.Request.Context(), req.Name, req.Description, req.Permissions, actorLogin)
+	if err != nil {
+		RespondError(ctx, err)
+		return
+	}
+	ctx.JSON(http.StatusCreated, roleResponse(r))
+}
+
+// UpdateRole
+// @Summary Update role
+// @Description Replace description and permissions of a custom role (roles.manage). The change applies at once, including to already issued tokens. Built-in roles cannot be changed
+// @Tags roles
+// @Accept json
+// @Produce json
+// @Param name path string true "Role name"
+// @Param body body dto.RoleUpdateRequest true "Description and permissions"
+// @Success 200 {object} dto.RoleResponse
+// @Failure 400 {object} dto.ErrorResponse
+// @Failure 404 {object} dto.ErrorResponse
+// @Failure 409 {object} dto.ErrorResponse "Built-in role"
+// @Failure 422 {object} dto.ErrorResponse
+// @Security BearerAuth
+// @Router /api/roles/{name} [put]
+func (h *UserHandler) UpdateRole(ctx *wbgin.Context) {
+	var req dto.RoleUpdateRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		badRequest(ctx, err)
+		return
+	}
+	_, actorLogin, ok := userFromContext(ctx)
+	if !ok {
+		return
+	}
+	r, err := h.Service.UpdateRole(ctx.Request.Context(), ctx.Param("name"), req.Description, req.Permissions, actorLogin)
+	if err != nil {
+		RespondError(ctx, err)
+		return
+	}
+	ctx.JSON(http.StatusOK, roleResponse(r))
+}
+
+// DeleteRole
+// @Summary Delete role
+// @Description Delete a custom role (roles.manage). Roles assigned to users or pending invitations and built-in roles cannot be deleted
+// @Tags roles
+// @Produce json
+// @Param name path string true "Role name"
+// @Success 200 {object} map[string]string
+// @Failure 404 {object} dto.ErrorResponse
+// @Failure 409 {object} dto.ErrorResponse "Built-in or assigned role"
+// @Security BearerAuth
+// @Router /api/roles/{name} [delete]
+func (h *UserHandler) DeleteRole(ctx *wbgin.Context) {
+	_, actorLogin, ok := userFromContext(ctx)
+	if !ok {
+		return
+	}
+	err := h.Service.DeleteRole(ctx.Request.Context(), ctx.Param("name"), actorLogin)
+	if err != nil {
+		RespondError(ctx, err)
+		return
+	}
+	ctx.JSON(http.StatusOK, wbgin.H{"status": "deleted"})
+}
+
+func roleResponse(r *user.RoleDefinition) dto.RoleResponse {
+	perms := make([]string, 0, len(r.Permissions))
+	for _, p := range r.Permissions {
+		perms = append(perms, string(p))
+	}
+	return dto.RoleResponse{
+		Name:        string(r.Name),
+		Description: r.Description,
+		Permissions: perms,
+		BuiltIn:     r.BuiltIn,
+		Version:     r.Version,
+		CreatedAt:   r.CreatedAt,
+		UpdatedAt:   r.UpdatedAt,
+	}
+}
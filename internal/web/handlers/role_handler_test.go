@@ -0,0 +1,136 @@
+package handlers_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"warehousecontrol/internal/domain/user"
+	"warehousecontrol/internal/web/dto"
+	"warehousecontrol/internal/web/handlers"
+
+	wbgin "github.com/wb-go/wbf/ginext"
+)
+
+func asRoleAdmin(name string) func(*wbgin.Context) {
+	return func(c *wbgin.Context) {
+		c.Set("userId", "admin-id")
+		c.Set("login", "admin")
+		if name != "" {
+			c.AddParam("name", name)
+		}
+	}
+}
+
+func TestUserHandler_GetRoles(t *testing.T) {
+	mock := &MockUserService{
+		GetRolesFn: func() ([]*user.RoleDefinition, error) {
+			return user.BuiltInRoles(), nil
+		},
+		GetRoleFn: func(name string) (*user.RoleDefinition, error) {
+			return nil, user.ErrRoleNotFound
+		},
+	}
+	h := handlers.NewUserHandler(mock)
+
+	rr := performJSON(h.GetRoles, http.MethodGet, "/api/roles", nil, nil)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d", rr.Code)
+	}
+	var res []dto.RoleResponse
+	_ = json.Unmarshal(rr.Body.Bytes(), &res)
+	if len(res) != 3 || res[0].Name != "admin" || !res[0].BuiltIn || len(res[0].Permissions) != len(user.AllPermissions) {
+		t.Fatalf("unexpected response: %s", rr.Body.String())
+	}
+
+	rr = performJSON(h.GetRole, http.MethodGet, "/api/roles/ghost", nil, asRoleAdmin("ghost"))
+	if rr.Code != http.StatusNotFound {
+		t.Fatalf("expected 404, got %d", rr.Code)
+	}
+}
+
+func TestUserHandler_CreateRole(t *testing.T) {
+	var gotActor string
+	mock := &MockUserService{
+		CreateRoleFn: func(name, description string, permissions []string, actorLogin string) (*user.RoleDefinition, error) {
+			gotActor = actorLogin
+			if name == "viewer" {
+				return nil, user.ErrRoleExists
+			}
+			return user.NewRole(name, description, permissions)
+		},
+	}
+	h := handlers.NewUserHandler(mock)
+
+	body := dto.RoleCreateRequest{Name: "auditor", Description: "Audit", Permissions: []string{"history.read"}}
+	rr := performJSON(h.CreateRole, http.MethodPost, "/api/roles", body, asRoleAdmin(""))
+	if rr.Code != http.StatusCreated {
+		t.Fatalf("expected 201, got %d: %s", rr.Code, rr.Body.String())
+	}
+	var res dto.RoleResponse
+	_ = json.Unmarshal(rr.Body.Bytes(), &res)
+	if res.Name != "auditor" || res.Version != 1 || len(res.Permissions) != 1 || gotActor != "admin" {
+		t.Fatalf("unexpected response: %s", rr.Body.String())
+	}
+
+	body.Permissions = []string{"items.destroy"}
+	rr = performJSON(h.CreateRole, http.MethodPost, "/api/roles", body, asRoleAdmin(""))
+	if rr.Code != http.StatusUnprocessableEntity {
+		t.Fatalf("expected 422 for unknown permission, got %d", rr.Code)
+	}
+	body = dto.RoleCreateRequest{Name: "viewer", Permissions: []string{"items.read"}}
+	rr = performJSON(h.CreateRole, http.MethodPost, "/api/roles", body, asRoleAdmin(""))
+	if rr.Code != http.StatusConflict {
+		t.Fatalf("expected 409, got %d", rr.Code)
+	}
+	rr = performJSON(h.CreateRole, http.MethodPost, "/api/roles", map[string]any{"name": "auditor"}, asRoleAdmin(""))
+	if rr.Code != http.StatusBadRequest {
+		t.Fatalf("expected 400 without permissions, got %d", rr.Code)
+	}
+}
+
+func TestUserHandler_UpdateDeleteRole(t *testing.T) {
+	mock := &MockUserService{
+		UpdateRoleFn: func(name, description string, permissions []string, actorLogin string) (*user.RoleDefinition, error) {
+			if name == "admin" {
+				return nil, user.ErrBuiltInRole
+			}
+			perms, err := user.ParsePermissions(permissions)
+			if err != nil {
+				return nil, err
+			}
+			return &user.RoleDefinition{Name: user.Role(name), Description: description, Permissions: perms, Version: 2}, nil
+		},
+		DeleteRoleFn: func(name, actorLogin string) error {
+			if name == "auditor" {
+				return user.ErrRoleInUse
+			}
+			return nil
+		},
+	}
+	h := handlers.NewUserHandler(mock)
+
+	body := dto.RoleUpdateRequest{Permissions: []string{"history.read", "items.read"}}
+	rr := performJSON(h.UpdateRole, http.MethodPut, "/api/roles/clerk", body, asRoleAdmin("clerk"))
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d", rr.Code)
+	}
+	var res dto.RoleResponse
+	_ = json.Unmarshal(rr.Body.Bytes(), &res)
+	if res.Version != 2 || len(res.Permissions) != 2 {
+		t.Fatalf("unexpected response: %s", rr.Body.String())
+	}
+	rr = performJSON(h.UpdateRole, http.MethodPut, "/api/roles/admin", body, asRoleAdmin("admin"))
+	if rr.Code != http.StatusConflict {
+		t.Fatalf("expected 409 for built-in role, got %d", rr.Code)
+	}
+
+	rr = performJSON(h.DeleteRole, http.MethodDelete, "/api/roles/auditor", nil, asRoleAdmin("auditor"))
+	if rr.Code != http.StatusConflict {
+		t.Fatalf("expected 409 for assigned role, got %d", rr.Code)
+	}
+	rr = performJSON(h.DeleteRole, http.MethodDelete, "/api/roles/clerk", nil, asRoleAdmin("clerk"))
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d", rr.Code)
+	}
+}
@@ -0,0 +1,156 @@
+package handlers
+
+import (
+	"net/http"
+
+	"warehousecontrol/internal/domain/user"
+	"warehousecontrol/internal/web/dto"
+
+	wbgin "github.com/wb-go/wbf/ginext"
+)
+
+// GetSessions
+// @Summary My sessions
+// @Description Active sessions of the current user, most recently used first. The session of the request token is marked current
+// @Tags sessions
+// @Produce json
+// @Success 200 {array} dto.SessionResponse
+// @Failure 401 {object} dto.ErrorResponse
+// @Security BearerAuth
+// @Router /api/auth/sessions [get]
+func (h *UserHandler) GetSessions(ctx *wbgin.Context) {
+	userID, _, ok := userFromContext(ctx)
+	if !ok {
+		return
+	}
+	h.respondSessions(ctx, userID)
+}
+
+// RevokeSession
+// @Summary Revoke my session
+// @Description Sign out one of the current user's sessions; its refresh and access tokens stop working
+// @Tags sessions
+// @Produce json
+// @Param id path string true "Session UUID"
+// @Success 200 {object} map[string]string
+// @Failure 400 {object} dto.ErrorResponse
+// @Failure 404 {object} dto.ErrorResponse
+// @Security BearerAuth
+// @Router /api/auth/sessions/{id} [delete]
+func (h *UserHandler) RevokeSession(ctx *wbgin.Context) {
+	userID, _, ok := userFromContext(ctx)
+	if !ok {
+		return
+	}
+	err := h.Service.RevokeSession(ctx.Request.Context(), userID, ctx.Param("id"))
+	if err != nil {
+		RespondError(ctx, err)
+		return
+	}
+	ctx.JSON(http.StatusOK, wbgin.H{"status": "revoked"})
+}
+
+// RevokeSessions
+// @Summary Revoke all my sessions
+// @Description Sign out every session of the current user, including the one making the request
+// @Tags sessions
+// @Produce json
+// @Success 200 {object} map[string]string
+// @Security BearerAuth
+// @Router /api/auth/sessions [delete]
+func (h *UserHandler) RevokeSessions(ctx *wbgin.Context) {
+	userID, _, ok := userFromContext(ctx)
+	if !ok {
+		return
+	}
+	err := h.Service.RevokeSessions(ctx.Request.Context(), userID)
+	if err != nil {
+		RespondError(ctx, err)
+		return
+	}
+	ctx.JSON(http.StatusOK, wbgin.H{"status": "revoked"})
+}
+
+// GetUserSessions
+// @Summary User sessions
+// @Description Active sessions of any user (users.manage)
+// @Tags users-admin
+// @Produce json
+// @Param id path string true "User UUID"
+// @Success 200 {array} dto.SessionResponse
+// @Failure 400 {object} dto.ErrorResponse
+// @Security BearerAuth
+// @Router /api/users/{id}/sessions [get]
+func (h *UserHandler) GetUserSessions(ctx *wbgin.Context) {
+	h.respondSessions(ctx, ctx.Param("id"))
+}
+
+// RevokeUserSession
+// @Summary Revoke user session
+// @Description Force sign-out of one session of any user (users.manage)
+// @Tags users-admin
+// @Produce json
+// @Param id path string true "User UUID"
+// @Param session_id path string true "Session UUID"
+// @Success 200 {object} map[string]string
+// @Failure 400 {object} dto.ErrorResponse
+// @Failure 404 {object} dto.ErrorResponse
+// @Security BearerAuth
+// @Router /api/users/{id}/sessions/{session_id} [delete]
+func (h *UserHandler) RevokeUserSession(ctx *wbgin.Context) {
+	err := h.Service.RevokeSession(ctx.Request.Context(), ctx.Param("id"), ctx.Param("session_id"))
+	if err != nil {
+		RespondError(ctx, err)
+		return
+	}
+	ctx.JSON(http.StatusOK, wbgin.H{"status": "revoked"})
+}
+
+// RevokeUserSessions
+// @Summary Revoke all user sessions
+// @Description Force sign-out of every session of any user (users.manage)
+// @Tags users-admin
+// @Produce json
+// @Param id path string true "User UUID"
+// @Success 200 {object} map[string]string
+// @Failure 400 {object} dto.ErrorResponse
+// @Security BearerAuth
+// @Router /api/users/{id}/sessions [delete]
+func (h *UserHandler) RevokeUserSessions(ctx *wbgin.Context) {
+	err := h.Service.RevokeSessions(ctx.Request.Context(), ctx.Param("id"))
+	if err != nil {
+		RespondError(ctx, err)
+		return
+	}
+	ctx.JSON(http.StatusOK, wbgin.H{"status": "revoked"})
+}
+
+func (h *UserHandler) respondSessions(ctx *wbgin.Context, userID string) {
+	sessions, err := h.Service.GetSessions(ctx.Request.Context(), userID)
+	if err != nil {
+		RespondError(ctx, err)
+		return
+	}
+	current := ctx.GetString("sessionId")
+	res := make([]dto.SessionResponse, 0, len(sessions))
+	for _, s := range sessions {
+		res = append(res, dto.SessionResponse{
+			ID:         s.ID.String(),
+			UserAgent:  s.UserAgent,
+			IP:         s.IP,
+			CreatedAt:  s.CreatedAt,
+			LastUsedAt: s.LastUsedAt,
+			ExpiresAt:  s.ExpiresAt,
+			Current:    s.ID.String() == current,
+		})
+	}
+	ctx.JSON(http.StatusOK, res)
+}
+
+// clientInfo — данные клиента для новой сессии
+func clientInfo(ctx *wbgin.Context) user.Client {
+	return user.Client{
+		UserAgent: ctx.Request.UserAgent(),
+		IP:        ctx.ClientIP(),
+	}
+}
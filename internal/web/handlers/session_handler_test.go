@@ -0,0 +1,129 @@
+package handlers_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+	"time"
+
+	"warehousecontrol/internal/auth"
+	"warehousecontrol/internal/domain/user"
+	"warehousecontrol/internal/web/dto"
+	"warehousecontrol/internal/web/handlers"
+
+	"github.com/google/uuid"
+	wbgin "github.com/wb-go/wbf/ginext"
+)
+
+func TestUserHandler_LoginUser_PassesClient(t *testing.T) {
+	var got user.Client
+	mock := &MockUserService{
+		LoginFn: func(login, password string, client user.Client) (*auth.LoginResponse, error) {
+			got = client
+			return &auth.LoginResponse{JWTResponse: &auth.JWTResponse{AccessToken: "a", RefreshToken: "r"}}, nil
+		},
+	}
+	h := handlers.NewUserHandler(mock)
+
+	rr := performJSON(h.LoginUser, http.MethodPost, "/api/auth/login", dto.UserLoginRequest{Login: "bob", Password: "Password1"}, func(c *wbgin.Context) {
+		c.Request.Header.Set("User-Agent", "curl/8.0")
+		c.Request.RemoteAddr = "10.0.0.7:5555"
+	})
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d", rr.Code)
+	}
+	if got.UserAgent != "curl/8.0" || got.IP != "10.0.0.7" {
+		t.Fatalf("unexpected client: %+v", got)
+	}
+}
+
+func TestUserHandler_GetSessions(t *testing.T) {
+	current, other := uuid.New(), uuid.New()
+	now := time.Now()
+	mock := &MockUserService{
+		GetSessionsFn: func(userID string) ([]*user.Session, error) {
+			if userID != "user-id" {
+				t.Fatalf("unexpected user %q", userID)
+			}
+			return []*user.Session{
+				{ID: current, UserAgent: "laptop", LastUsedAt: now, ExpiresAt: now.Add(time.Hour)},
+				{ID: other, UserAgent: "phone", LastUsedAt: now, ExpiresAt: now.Add(time.Hour)},
+			}, nil
+		},
+	}
+	h := handlers.NewUserHandler(mock)
+
+	rr := performJSON(h.GetSessions, http.MethodGet, "/api/auth/sessions", nil, func(c *wbgin.Context) {
+		c.Set("userId", "user-id")
+		c.Set("login", "bob")
+		c.Set("sessionId", current.String())
+	})
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d", rr.Code)
+	}
+	var res []dto.SessionResponse
+	_ = json.Unmarshal(rr.Body.Bytes(), &res)
+	if len(res) != 2 || !res[0].Current || res[1].Current || res[1].UserAgent != "phone" {
+		t.Fatalf("unexpected response: %s", rr.Body.String())
+	}
+}
+
+func TestUserHandler_RevokeSession(t *testing.T) {
+	mock := &MockUserService{
+		RevokeSessionFn: func(userID, sessionID string) error {
+			if userID != "user-id" {
+				t.Fatalf("unexpected user %q", userID)
+			}
+			if sessionID == "foreign" {
+				return user.ErrSessionNotFound
+			}
+			return nil
+		},
+	}
+	h := handlers.NewUserHandler(mock)
+	asUser := func(id string) func(*wbgin.Context) {
+		return func(c *wbgin.Context) {
+			c.Set("userId", "user-id")
+			c.Set("login", "bob")
+			c.AddParam("id", id)
+		}
+	}
+
+	rr := performJSON(h.RevokeSession, http.MethodDelete, "/api/auth/sessions/mine", nil, asUser("mine"))
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d", rr.Code)
+	}
+	rr = performJSON(h.RevokeSession, http.MethodDelete, "/api/auth/sessions/foreign", nil, asUser("foreign"))
+	if rr.Code != http.StatusNotFound {
+		t.Fatalf("expected 404, got %d", rr.Code)
+	}
+}
+
+func TestUserHandler_RevokeUserSessions(t *testing.T) {
+	var revoked string
+	mock := &MockUserService{
+		RevokeSessionsFn: func(userID string) error {
+			revoked = userID
+			return nil
+		},
+		RevokeSessionFn: func(userID, sessionID string) error {
+			if userID != "target" || sessionID != "sess" {
+				t.Fatalf("unexpected args %q %q", userID, sessionID)
+			}
+			return nil
+		},
+	}
+	h := handlers.NewUserHandler(mock)
+
+	rr := performJSON(h.RevokeUserSessions, http.MethodDelete, "/api/users/target/sessions", nil, asAdmin("target"))
+	if rr.Code != http.StatusOK || revoked != "target" {
+		t.Fatalf("expected sessions of target to be revoked, got %d %q", rr.Code, revoked)
+	}
+	rr = performJSON(h.RevokeUserSession, http.MethodDelete, "/api/users/target/sessions/sess", nil, func(c *wbgin.Context) {
+		asAdmin("target")(c)
+		c.AddParam("session_id", "sess")
+	})
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d", rr.Code)
+	}
+}
@@ -0,0 +1,105 @@
+package handlers
+
+import (
+	"context"
+	"crypto/subtle"
+	"net/http"
+	"time"
+
+	"warehousecontrol/internal/auth"
+	"warehousecontrol/internal/domain/user"
+	"warehousecontrol/internal/web/dto"
+
+	wbgin "github.com/wb-go/wbf/ginext"
+)
+
+// ssoStateCookie привязывает вход через провайдера к браузеру, который его начал: без неё
+// чужую ссылку возврата с кодом и state можно подсунуть жертве и войти за неё в свою учётную запись
+const (
+	ssoStateCookie     = "sso_state"
+	ssoStateCookiePath = "/api/auth/sso"
+)
+
+type SSOHandler struct {
+	Service SSOIFace
+}
+
+type SSOIFace interface {
+	StartLogin(ctx context.Context) (string, string, time.Time, error)
+	CompleteLogin(ctx context.Context, code, state string, client user.Client) (*auth.LoginResponse, error)
+}
+
+func NewSSOHandler(service SSOIFace) *SSOHandler {
+	return &SSOHandler{
+		Service: service,
+	}
+}
+
+// StartSSOLogin
+// @Summary Start SSO login
+// @Description Start login through the OpenID Connect provider (authorization code + PKCE). Open authorization_url in the browser; the provider returns the user to the configured redirect_url with code and state, which are passed to /api/auth/sso/callback. The state is also set in an HttpOnly sso_state cookie; the callback must be sent from the same browser with credentials
+// @Tags auth
+// @Produce json
+// @Success 200 {object} dto.SSOLoginResponse
+// @Failure 404 {object} dto.ErrorResponse "SSO is not configured"
+// @Router /api/auth/sso/login [get]
+func (h *SSOHandler) StartSSOLogin(ctx *wbgin.Context) {
+	authURL, state, expiresAt, err := h.Service.StartLogin(ctx.Request.Context())
+	if err != nil {
+		RespondError(ctx, err)
+		return
+	}
+	setSSOStateCookie(ctx, state, int(time.Until(expiresAt).Seconds()))
+	ctx.Header("Cache-Control", "no-store")
+	ctx.JSON(http.StatusOK, dto.SSOLoginResponse{AuthorizationURL: authURL, ExpiresAt: expiresAt})
+}
+
+// SSOCallback
+// @Summary Complete SSO login
+// @Description Exchange the code returned by the OpenID Connect provider for a local session. The account is created on first login; its role follows the provider groups (oidc.group_roles) on every login. Like password login, the response may require a second factor (mfa_required). The state must match the sso_state cookie set by /api/auth/sso/login
+// @Tags auth
+// @Accept json
+// @Produce json
+// @Param body body dto.SSOCallbackRequest true "Code and state from the provider redirect"
+// @Success 200 {object} dto.LoginResponse
+// @Failure 400 {object} dto.ErrorResponse
+// @Failure 401 {object} dto.ErrorResponse "State expired, not started in this browser or the provider rejected the code"
+// @Failure 403 {object} dto.ErrorResponse "No role mapped to the provider groups or account disabled"
+// @Failure 404 {object} dto.ErrorResponse "SSO is not configured"
+// @Failure 409 {object} dto.ErrorResponse "Login used by another account"
+// @Failure 422 {object} dto.ErrorResponse
+// @Router /api/auth/sso/callback [post]
+func (h *SSOHandler) SSOCallback(ctx *wbgin.Context) {
+	var req dto.SSOCallbackRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		badRequest(ctx, err)
+		return
+	}
+	// state одноразовый: cookie больше не нужна при любом исходе
+	cookie, err := ctx.Cookie(ssoStateCookie)
+	setSSOStateCookie(ctx, "", -1)
+	if err != nil || subtle.ConstantTimeCompare([]byte(cookie), []byte(req.State)) != 1 {
+		RespondError(ctx, user.ErrSSOStateInvalid)
+		return
+	}
+	loginResp, err := h.Service.CompleteLogin(ctx.Request.Context(), req.Code, req.State, clientInfo(ctx))
+	if err != nil {
+		RespondError(ctx, err)
+		return
+	}
+	ctx.JSON(http.StatusOK, loginResponse(loginResp))
+}
+
+// setSSOStateCookie ставит или (maxAge < 0) удаляет cookie со state. SameSite=Lax: клиент на
+// другом порту того же хоста остаётся тем же сайтом, а с чужих сайтов cookie не отправляется.
+func setSSOStateCookie(ctx *wbgin.Context, state string, maxAge int) {
+	http.SetCookie(ctx.Writer, &http.Cookie{
+		Name:     ssoStateCookie,
+		Value:    state,
+		Path:     ssoStateCookiePath,
+		MaxAge:   maxAge,
+		HttpOnly: true,
+		Secure:   ctx.Request.TLS != nil || ctx.GetHeader("X-Forwarded-Proto") == "https",
+		SameSite: http.SameSiteLaxMode,
+	})
+}
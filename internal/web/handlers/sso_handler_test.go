@@ -0,0 +1,120 @@
+package handlers_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"testing"
+	"time"
+
+	wbgin "github.com/wb-go/wbf/ginext"
+
+	"warehousecontrol/internal/auth"
+	"warehousecontrol/internal/domain/user"
+	"warehousecontrol/internal/web/dto"
+	"warehousecontrol/internal/web/handlers"
+)
+
+type mockSSO struct {
+	StartFn    func() (string, string, time.Time, error)
+	CompleteFn func(code, state string, client user.Client) (*auth.LoginResponse, error)
+}
+
+func (m *mockSSO) StartLogin(_ context.Context) (string, string, time.Time, error) {
+	return m.StartFn()
+}
+
+func (m *mockSSO) CompleteLogin(_ context.Context, code, state string, client user.Client) (*auth.LoginResponse, error) {
+	return m.CompleteFn(code, state, client)
+}
+
+// withStateCookie добавляет к запросу cookie, которую ставит StartSSOLogin
+func withStateCookie(state string) func(*wbgin.Context) {
+	return func(c *wbgin.Context) {
+		c.Request.AddCookie(&http.Cookie{Name: "sso_state", Value: state})
+	}
+}
+
+func TestSSOHandler_StartSSOLogin(t *testing.T) {
+	h := handlers.NewSSOHandler(&mockSSO{StartFn: func() (string, string, time.Time, error) {
+		return "https://sso.example.com/authorize?state=s", "s", time.Now().Add(time.Minute), nil
+	}})
+	rr := performJSON(h.StartSSOLogin, http.MethodGet, "/api/auth/sso/login", nil, nil)
+	if rr.Code != http.StatusOK || rr.Header().Get("Cache-Control") != "no-store" {
+		t.Fatalf("expected 200 without caching, got %d %v", rr.Code, rr.Header())
+	}
+	var res dto.SSOLoginResponse
+	_ = json.Unmarshal(rr.Body.Bytes(), &res)
+	if res.AuthorizationURL != "https://sso.example.com/authorize?state=s" || res.ExpiresAt.IsZero() {
+		t.Fatalf("unexpected response: %s", rr.Body.String())
+	}
+	cookies := rr.Result().Cookies()
+	if len(cookies) != 1 || cookies[0].Name != "sso_state" || cookies[0].Value != "s" || !cookies[0].HttpOnly || cookies[0].SameSite != http.SameSiteLaxMode || cookies[0].MaxAge <= 0 {
+		t.Fatalf("expected state cookie, got %+v", cookies)
+	}
+
+	h = handlers.NewSSOHandler(&mockSSO{StartFn: func() (string, string, time.Time, error) {
+		return "", "", time.Time{}, user.ErrSSODisabled
+	}})
+	if rr := performJSON(h.StartSSOLogin, http.MethodGet, "/api/auth/sso/login", nil, nil); rr.Code != http.StatusNotFound {
+		t.Fatalf("expected 404, got %d", rr.Code)
+	}
+}
+
+func TestSSOHandler_SSOCallback(t *testing.T) {
+	var gotCode, gotState string
+	h := handlers.NewSSOHandler(&mockSSO{CompleteFn: func(code, state string, client user.Client) (*auth.LoginResponse, error) {
+		gotCode, gotState = code, state
+		if state == "expired" {
+			return nil, user.ErrSSOStateInvalid
+		}
+		if state == "staff" {
+			return nil, user.ErrNoSSORole
+		}
+		return &auth.LoginResponse{JWTResponse: &auth.JWTResponse{AccessToken: "access", RefreshToken: "refresh", SessionID: "sid"}}, nil
+	}})
+
+	rr := performJSON(h.SSOCallback, http.MethodPost, "/api/auth/sso/callback", dto.SSOCallbackRequest{Code: "c", State: "s"}, withStateCookie("s"))
+	if rr.Code != http.StatusOK || gotCode != "c" || gotState != "s" {
+		t.Fatalf("expected 200, got %d: %s", rr.Code, rr.Body.String())
+	}
+	var res dto.LoginResponse
+	_ = json.Unmarshal(rr.Body.Bytes(), &res)
+	if res.AccessToken != "access" || res.SessionID != "sid" || res.MFARequired {
+		t.Fatalf("unexpected response: %s", rr.Body.String())
+	}
+
+	for state, status := range map[string]int{"expired": http.StatusUnauthorized, "staff": http.StatusForbidden} {
+		rr = performJSON(h.SSOCallback, http.MethodPost, "/api/auth/sso/callback", dto.SSOCallbackRequest{Code: "c", State: state}, withStateCookie(state))
+		if rr.Code != status {
+			t.Fatalf("%s: expected %d, got %d", state, status, rr.Code)
+		}
+	}
+	rr = performJSON(h.SSOCallback, http.MethodPost, "/api/auth/sso/callback", map[string]string{"code": "c"}, nil)
+	if rr.Code != http.StatusBadRequest {
+		t.Fatalf("expected 400 without state, got %d", rr.Code)
+	}
+}
+
+func TestSSOHandler_SSOCallback_StateCookie(t *testing.T) {
+	called := false
+	h := handlers.NewSSOHandler(&mockSSO{CompleteFn: func(code, state string, client user.Client) (*auth.LoginResponse, error) {
+		called = true
+		return &auth.LoginResponse{JWTResponse: &auth.JWTResponse{AccessToken: "access"}}, nil
+	}})
+
+	// вход, начатый в другом браузере: cookie нет или в ней чужой state
+	for name, setCtx := range map[string]func(*wbgin.Context){"missing": nil, "mismatch": withStateCookie("victim")} {
+		rr := performJSON(h.SSOCallback, http.MethodPost, "/api/auth/sso/callback", dto.SSOCallbackRequest{Code: "c", State: "attacker"}, setCtx)
+		if rr.Code != http.StatusUnauthorized {
+			t.Fatalf("%s: expected 401, got %d", name, rr.Code)
+		}
+		if called {
+			t.Fatalf("%s: login must not reach the service", name)
+		}
+		cookies := rr.Result().Cookies()
+		if len(cookies) != 1 || cookies[0].Name != "sso_state" || cookies[0].MaxAge >= 0 {
+			t.Fatalf("%s: expected state cookie to be cleared, got %+v", name, cookies)
+		}
+	}
+}
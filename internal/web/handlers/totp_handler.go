@@ -0,0 +1,241 @@
+package handlers
+
+import (
+	"net/http"
+
+	"warehousecontrol/internal/domain/user"
+	"warehousecontrol/internal/web/dto"
+
+	wbgin "github.com/wb-go/wbf/ginext"
+)
+
+// LoginMFA
+// @Summary Complete login with a two-factor code
+// @Description Second login step: exchange the mfa_token from /api/auth/login and a TOTP code or a recovery code for tokens. When two-factor setup is mandatory and was started with /api/auth/login/2fa/setup, the code confirms it and the response also carries recovery codes. Wrong codes count towards the login lockout
+// @Tags users
+// @Accept json
+// @Produce json
+// @Param body body dto.MFALoginRequest true "Second step token and code"
+// @Success 200 {object} dto.MFALoginResponse
+// @Failure 400 {object} dto.ErrorResponse
+// @Failure 401 {object} dto.ErrorResponse "Invalid code or expired mfa_token"
+// @Failure 403 {object} dto.ErrorResponse "Account disabled"
+// @Failure 409 {object} dto.ErrorResponse "Two-factor setup not started"
+// @Failure 429 {object} dto.ErrorResponse "Too many failed attempts"
+// @Router /api/auth/login/2fa [post]
+func (h *UserHandler) LoginMFA(ctx *wbgin.Context) {
+	var req dto.MFALoginRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		badRequest(ctx, err)
+		return
+	}
+	jwtResp, codes, err := h.Service.LoginMFA(ctx.Request.Context(), req.MFAToken, req.Code, clientInfo(ctx))
+	if err != nil {
+		unauthorized(ctx, err)
+		return
+	}
+	ctx.JSON(http.StatusOK, dto.MFALoginResponse{
+		AccessToken:   jwtResp.AccessToken,
+		RefreshToken:  jwtResp.RefreshToken,
+		SessionID:     jwtResp.SessionID,
+		RecoveryCodes: codes,
+	})
+}
+
+// LoginMFASetup
+// @Summary Start mandatory two-factor setup during login
+// @Description For accounts that must use two-factor authentication but have not set it up yet (mfa_enrollment=true at login): returns a new TOTP secret for the mfa_token's user. Finish with /api/auth/login/2fa
+// @Tags users
+// @Accept json
+// @Produce json
+// @Param body body dto.MFASetupRequest true "Second step token"
+// @Success 200 {object} dto.TOTPSetupResponse
+// @Failure 400 {object} dto.ErrorResponse
+// @Failure 401 {object} dto.ErrorResponse "Invalid or expired mfa_token"
+// @Failure 409 {object} dto.ErrorResponse "Two-factor already enabled"
+// @Router /api/auth/login/2fa/setup [post]
+func (h *UserHandler) LoginMFASetup(ctx *wbgin.Context) {
+	var req dto.MFASetupRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		badRequest(ctx, err)
+		return
+	}
+	setup, err := h.Service.LoginMFASetup(ctx.Request.Context(), req.MFAToken)
+	if err != nil {
+		unauthorized(ctx, err)
+		return
+	}
+	ctx.JSON(http.StatusOK, totpSetupResponse(setup))
+}
+
+// GetTOTPStatus
+// @Summary My two-factor status
+// @Description Whether two-factor authentication is enabled or mandatory for the current user, and how many recovery codes are left
+// @Tags users
+// @Produce json
+// @Success 200 {object} dto.TOTPStatusResponse
+// @Security BearerAuth
+// @Router /api/auth/2fa [get]
+func (h *UserHandler) GetTOTPStatus(ctx *wbgin.Context) {
+	userID, _, ok := userFromContext(ctx)
+	if !ok {
+		return
+	}
+	status, err := h.Service.TOTPStatus(ctx.Request.Context(), userID)
+	if err != nil {
+		RespondError(ctx, err)
+		return
+	}
+	ctx.JSON(http.StatusOK, dto.TOTPStatusResponse{
+		Enabled:           status.Enabled,
+		Required:          status.Required,
+		RecoveryCodesLeft: status.RecoveryCodesLeft,
+	})
+}
+
+// SetupTOTP
+// @Summary Start two-factor setup
+// @Description Generate a new TOTP secret and an otpauth:// URI for a QR code. Two-factor authentication is enabled only after /api/auth/2fa/enable confirms a code
+// @Tags users
+// @Produce json
+// @Success 200 {object} dto.TOTPSetupResponse
+// @Failure 409 {object} dto.ErrorResponse "Two-factor already enabled"
+// @Security BearerAuth
+// @Router /api/auth/2fa/setup [post]
+func (h *UserHandler) SetupTOTP(ctx *wbgin.Context) {
+	userID, _, ok := userFromContext(ctx)
+	if !ok {
+		return
+	}
+	setup, err := h.Service.SetupTOTP(ctx.Request.Context(), userID)
+	if err != nil {
+		RespondError(ctx, err)
+		return
+	}
+	ctx.JSON(http.StatusOK, totpSetupResponse(setup))
+}
+
+// EnableTOTP
+// @Summary Enable two-factor authentication
+// @Description Confirm the secret from /api/auth/2fa/setup with a current code. Returns single-use recovery codes, shown only once
+// @Tags users
+// @Accept json
+// @Produce json
+// @Param body body dto.TOTPCodeRequest true "TOTP code"
+// @Success 200 {object} dto.RecoveryCodesResponse
+// @Failure 400 {object} dto.ErrorResponse
+// @Failure 401 {object} dto.ErrorResponse "Invalid code"
+// @Failure 409 {object} dto.ErrorResponse "Setup not started or already enabled"
+// @Security BearerAuth
+// @Router /api/auth/2fa/enable [post]
+func (h *UserHandler) EnableTOTP(ctx *wbgin.Context) {
+	var req dto.TOTPCodeRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		badRequest(ctx, err)
+		return
+	}
+	userID, _, ok := userFromContext(ctx)
+	if !ok {
+		return
+	}
+	codes, err := h.Service.EnableTOTP(ctx.Request.Context(), userID, req.Code)
+	if err != nil {
+		RespondError(ctx, err)
+		return
+	}
+	ctx.JSON(http.StatusOK, dto.RecoveryCodesResponse{RecoveryCodes: codes})
+}
+
+// DisableTOTP
+// @Summary Disable two-factor authentication
+// @Description Turn two-factor authentication off with a current TOTP code or a recovery code. Not allowed when it is mandatory for the user's role
+// @Tags users
+// @Accept json
+// @Produce json
+// @Param body body dto.TOTPCodeRequest true "TOTP or recovery code"
+// @Success 200 {object} map[string]string
+// @Failure 400 {object} dto.ErrorResponse
+// @Failure 401 {object} dto.ErrorResponse "Invalid code"
+// @Failure 403 {object} dto.ErrorResponse "Two-factor is mandatory"
+// @Failure 404 {object} dto.ErrorResponse "Two-factor not enabled"
+// @Failure 429 {object} dto.ErrorResponse
+// @Security BearerAuth
+// @Router /api/auth/2fa/disable [post]
+func (h *UserHandler) DisableTOTP(ctx *wbgin.Context) {
+	var req dto.TOTPCodeRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		badRequest(ctx, err)
+		return
+	}
+	userID, _, ok := userFromContext(ctx)
+	if !ok {
+		return
+	}
+	err := h.Service.DisableTOTP(ctx.Request.Context(), userID, req.Code, clientInfo(ctx))
+	if err != nil {
+		RespondError(ctx, err)
+		return
+	}
+	ctx.JSON(http.StatusOK, wbgin.H{"status": "disabled"})
+}
+
+// RegenerateRecoveryCodes
+// @Summary Regenerate recovery codes
+// @Description Issue a new set of recovery codes with a current TOTP code or a recovery code. Previous codes stop working
+// @Tags users
+// @Accept json
+// @Produce json
+// @Param body body dto.TOTPCodeRequest true "TOTP or recovery code"
+// @Success 200 {object} dto.RecoveryCodesResponse
+// @Failure 400 {object} dto.ErrorResponse
+// @Failure 401 {object} dto.ErrorResponse "Invalid code"
+// @Failure 404 {object} dto.ErrorResponse "Two-factor not enabled"
+// @Failure 429 {object} dto.ErrorResponse
+// @Security BearerAuth
+// @Router /api/auth/2fa/recovery-codes [post]
+func (h *UserHandler) RegenerateRecoveryCodes(ctx *wbgin.Context) {
+	var req dto.TOTPCodeRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		badRequest(ctx, err)
+		return
+	}
+	userID, _, ok := userFromContext(ctx)
+	if !ok {
+		return
+	}
+	codes, err := h.Service.RegenerateRecoveryCodes(ctx.Request.Context(), userID, req.Code, clientInfo(ctx))
+	if err != nil {
+		RespondError(ctx, err)
+		return
+	}
+	ctx.JSON(http.StatusOK, dto.RecoveryCodesResponse{RecoveryCodes: codes})
+}
+
+// ResetUserTOTP
+// @Summary Reset user's two-factor authentication
+// @Description Turn off two-factor authentication of another user who lost their device (users.manage). If it is mandatory, the user sets it up again at the next login
+// @Tags users-admin
+// @Produce json
+// @Param id path string true "User UUID"
+// @Success 200 {object} map[string]string
+// @Failure 400 {object} dto.ErrorResponse
+// @Failure 404 {object} dto.ErrorResponse "User not found or two-factor not enabled"
+// @Failure 409 {object} dto.ErrorResponse "Own account"
+// @Security BearerAuth
+// @Router /api/users/{id}/2fa [delete]
+func (h *UserHandler) ResetUserTOTP(ctx *wbgin.Context) {
+	actorID, actorLogin, ok := userFromContext(ctx)
+	if !ok {
+		return
+	}
+	err := h.Service.ResetTOTP(ctx.Request.Context(), ctx.Param("id"), actorID, actorLogin)
+	if err != nil {
+		RespondError(ctx, err)
+		return
+	}
+	ctx.JSON(http.StatusOK, wbgin.H{"status": "two-factor reset"})
+}
+
+func totpSetupResponse(setup *user.TOTPSetup) dto.TOTPSetupResponse {
+	return dto.TOTPSetupResponse{Secret: setup.Secret, OtpauthURI: setup.URI}
+}
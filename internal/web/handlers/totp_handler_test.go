@@ -0,0 +1,146 @@
+package handlers_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+	"time"
+
+	"warehousecontrol/internal/auth"
+	"warehousecontrol/internal/domain/user"
+	"warehousecontrol/internal/web/dto"
+	"warehousecontrol/internal/web/handlers"
+
+	wbgin "github.com/wb-go/wbf/ginext"
+)
+
+func asTOTPUser(c *wbgin.Context) {
+	c.Set("userId", "user-id")
+	c.Set("login", "bob")
+}
+
+func TestUserHandler_LoginUser_MFARequired(t *testing.T) {
+	expires := time.Now().Add(5 * time.Minute)
+	mock := &MockUserService{
+		LoginFn: func(login, password string, client user.Client) (*auth.LoginResponse, error) {
+			return &auth.LoginResponse{MFA: &auth.MFAChallenge{Token: "mfa", ExpiresAt: expires, Enrollment: true}}, nil
+		},
+	}
+	h := handlers.NewUserHandler(mock)
+
+	rr := performJSON(h.LoginUser, http.MethodPost, "/api/auth/login", dto.UserLoginRequest{Login: "bob", Password: "Password1"}, nil)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d", rr.Code)
+	}
+	var res dto.LoginResponse
+	_ = json.Unmarshal(rr.Body.Bytes(), &res)
+	if !res.MFARequired || res.MFAToken != "mfa" || !res.MFAEnrollment || res.AccessToken != "" || res.MFAExpiresAt == nil {
+		t.Fatalf("unexpected response: %s", rr.Body.String())
+	}
+}
+
+func TestUserHandler_LoginMFA(t *testing.T) {
+	var got user.Client
+	mock := &MockUserService{
+		LoginMFAFn: func(mfaToken, code string, client user.Client) (*auth.JWTResponse, []string, error) {
+			got = client
+			if mfaToken != "mfa" || code != "123456" {
+				return nil, nil, user.ErrTOTPInvalidCode
+			}
+			return &auth.JWTResponse{AccessToken: "a", RefreshToken: "r", SessionID: "s"}, []string{"abcde-fghij"}, nil
+		},
+	}
+	h := handlers.NewUserHandler(mock)
+
+	rr := performJSON(h.LoginMFA, http.MethodPost, "/api/auth/login/2fa", dto.MFALoginRequest{MFAToken: "mfa", Code: "123456"}, func(c *wbgin.Context) {
+		c.Request.RemoteAddr = "10.0.0.7:5555"
+	})
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d", rr.Code)
+	}
+	var res dto.MFALoginResponse
+	_ = json.Unmarshal(rr.Body.Bytes(), &res)
+	if res.AccessToken != "a" || res.SessionID != "s" || len(res.RecoveryCodes) != 1 || got.IP != "10.0.0.7" {
+		t.Fatalf("unexpected response: %s (client %+v)", rr.Body.String(), got)
+	}
+
+	rr = performJSON(h.LoginMFA, http.MethodPost, "/api/auth/login/2fa", dto.MFALoginRequest{MFAToken: "mfa", Code: "000000"}, nil)
+	if rr.Code != http.StatusUnauthorized {
+		t.Fatalf("expected 401 for wrong code, got %d", rr.Code)
+	}
+	rr = performJSON(h.LoginMFA, http.MethodPost, "/api/auth/login/2fa", map[string]string{"code": "123456"}, nil)
+	if rr.Code != http.StatusBadRequest {
+		t.Fatalf("expected 400 without mfa token, got %d", rr.Code)
+	}
+}
+
+func TestUserHandler_TOTPEnrollment(t *testing.T) {
+	mock := &MockUserService{
+		SetupTOTPFn: func(userID string) (*user.TOTPSetup, error) {
+			if userID != "user-id" {
+				t.Fatalf("unexpected user %q", userID)
+			}
+			return &user.TOTPSetup{Secret: "SECRET", URI: "otpauth://totp/x"}, nil
+		},
+		EnableTOTPFn: func(userID, code string) ([]string, error) {
+			return []string{"abcde-fghij", "klmno-pqrst"}, nil
+		},
+		TOTPStatusFn: func(userID string) (*user.TOTPStatus, error) {
+			return &user.TOTPStatus{Enabled: true, Required: true, RecoveryCodesLeft: 2}, nil
+		},
+	}
+	h := handlers.NewUserHandler(mock)
+
+	rr := performJSON(h.SetupTOTP, http.MethodPost, "/api/auth/2fa/setup", nil, asTOTPUser)
+	var setup dto.TOTPSetupResponse
+	_ = json.Unmarshal(rr.Body.Bytes(), &setup)
+	if rr.Code != http.StatusOK || setup.Secret != "SECRET" || setup.OtpauthURI != "otpauth://totp/x" {
+		t.Fatalf("unexpected setup response %d: %s", rr.Code, rr.Body.String())
+	}
+
+	rr = performJSON(h.EnableTOTP, http.MethodPost, "/api/auth/2fa/enable", dto.TOTPCodeRequest{Code: "123456"}, asTOTPUser)
+	var codes dto.RecoveryCodesResponse
+	_ = json.Unmarshal(rr.Body.Bytes(), &codes)
+	if rr.Code != http.StatusOK || len(codes.RecoveryCodes) != 2 {
+		t.Fatalf("unexpected enable response %d: %s", rr.Code, rr.Body.String())
+	}
+
+	rr = performJSON(h.GetTOTPStatus, http.MethodGet, "/api/auth/2fa", nil, asTOTPUser)
+	var status dto.TOTPStatusResponse
+	_ = json.Unmarshal(rr.Body.Bytes(), &status)
+	if rr.Code != http.StatusOK || !status.Enabled || !status.Required || status.RecoveryCodesLeft != 2 {
+		t.Fatalf("unexpected status response %d: %s", rr.Code, rr.Body.String())
+	}
+
+	mock.SetupTOTPFn = func(userID string) (*user.TOTPSetup, error) {
+		return nil, user.ErrTOTPAlreadyEnabled
+	}
+	rr = performJSON(h.SetupTOTP, http.MethodPost, "/api/auth/2fa/setup", nil, asTOTPUser)
+	if rr.Code != http.StatusConflict {
+		t.Fatalf("expected 409, got %d", rr.Code)
+	}
+}
+
+func TestUserHandler_DisableTOTP(t *testing.T) {
+	mock := &MockUserService{
+		DisableTOTPFn: func(userID, code string, client user.Client) error {
+			return user.ErrTOTPRequired
+		},
+		ResetTOTPFn: func(id, actorID, actorLogin string) error {
+			if id != "target" || actorLogin != "admin" {
+				t.Fatalf("unexpected args %q %q", id, actorLogin)
+			}
+			return nil
+		},
+	}
+	h := handlers.NewUserHandler(mock)
+
+	rr := performJSON(h.DisableTOTP, http.MethodPost, "/api/auth/2fa/disable", dto.TOTPCodeRequest{Code: "123456"}, asTOTPUser)
+	if rr.Code != http.StatusForbidden {
+		t.Fatalf("expected 403 for mandatory 2fa, got %d", rr.Code)
+	}
+	rr = performJSON(h.ResetUserTOTP, http.MethodDelete, "/api/users/target/2fa", nil, asAdmin("target"))
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d", rr.Code)
+	}
+}
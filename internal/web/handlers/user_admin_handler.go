@@ -0,0 +1,268 @@
+package handlers
+
+import (
+	"net/http"
+
+	"warehousecontrol/internal/domain/user"
+	"warehousecontrol/internal/web/dto"
+
+	wbgin "github.com/wb-go/wbf/ginext"
+)
+
+// GetUsers
+// @Summary List users
+// @Description Paginated list of users ordered by login with search by login substring, role and status filters (users.manage)
+// @Tags users-admin
+// @Produce json
+// @Param limit query int false "Page size (default 50, max 200)"
+// @Param offset query int false "Offset"
+// @Param login query string false "Login substring, case-insensitive"
+// @Param role query string false "Role filter"
+// @Param disabled query bool false "Status filter"
+// @Param service_account query bool false "Service accounts only (true) or regular users only (false)"
+// @Success 200 {object} dto.UserListResponse
+// @Failure 400 {object} dto.ErrorResponse
+// @Failure 500 {object} dto.ErrorResponse
+// @Security BearerAuth
+// @Router /api/users [get]
+func (h *UserHandler) GetUsers(ctx *wbgin.Context) {
+	var q dto.UserListQuery
+	if err := ctx.ShouldBindQuery(&q); err != nil {
+		badRequest(ctx, err)
+		return
+	}
+	page, err := h.Service.GetUsers(ctx.Request.Context(), user.ListOptions{
+		Limit:          q.Limit,
+		Offset:         q.Offset,
+		Login:          q.Login,
+		Role:           user.Role(q.Role),
+		Disabled:       q.Disabled,
+		ServiceAccount: q.ServiceAccount,
+	})
+	if err != nil {
+		RespondError(ctx, err)
+		return
+	}
+	res := dto.UserListResponse{Users: make([]dto.UserResponse, 0, len(page.Users)), Total: page.Total}
+	for _, u := range page.Users {
+		res.Users = append(res.Users, userResponse(u))
+	}
+	ctx.JSON(http.StatusOK, res)
+}
+
+// GetUser
+// @Summary Get user
+// @Description Get user by UUID (users.manage)
+// @Tags users-admin
+// @Produce json
+// @Param id path string true "User UUID"
+// @Success 200 {object} dto.UserResponse
+// @Failure 400 {object} dto.ErrorResponse
+// @Failure 404 {object} dto.ErrorResponse
+// @Security BearerAuth
+// @Router /api/users/{id} [get]
+func (h *UserHandler) GetUser(ctx *wbgin.Context) {
+	u, err := h.Service.GetUserByID(ctx.Request.Context(), ctx.Param("id"))
+	if err != nil {
+		RespondError(ctx, err)
+		return
+	}
+	ctx.JSON(http.StatusOK, userResponse(u))
+}
+
+// ChangeUserRole
+// @Summary Change user role
+// @Description Change the role of another user (users.manage). The new role applies to tokens issued after the change. The last active admin cannot be demoted
+// @Tags users-admin
+// @Accept json
+// @Produce json
+// @Param id path string true "User UUID"
+// @Param body body dto.UserRoleRequest true "New role"
+// @Success 200 {object} dto.UserResponse
+// @Failure 400 {object} dto.ErrorResponse
+// @Failure 404 {object} dto.ErrorResponse
+// @Failure 409 {object} dto.ErrorResponse "Own account or last active admin"
+// @Security BearerAuth
+// @Router /api/users/{id}/role [put]
+func (h *UserHandler) ChangeUserRole(ctx *wbgin.Context) {
+	var req dto.UserRoleRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		badRequest(ctx, err)
+		return
+	}
+	actorID, actorLogin, ok := userFromContext(ctx)
+	if !ok {
+		return
+	}
+	u, err := h.Service.ChangeRole(ctx.Request.Context(), ctx.Param("id"), req.Role, actorID, actorLogin)
+	if err != nil {
+		RespondError(ctx, err)
+		return
+	}
+	ctx.JSON(http.StatusOK, userResponse(u))
+}
+
+// DisableUser
+// @Summary Disable user
+// @Description Disable another user's account (users.manage): login and token refresh are rejected. The last active admin cannot be disabled
+// @Tags users-admin
+// @Produce json
+// @Param id path string true "User UUID"
+// @Success 200 {object} dto.UserResponse
+// @Failure 400 {object} dto.ErrorResponse
+// @Failure 404 {object} dto.ErrorResponse
+// @Failure 409 {object} dto.ErrorResponse "Own account or last active admin"
+// @Security BearerAuth
+// @Router /api/users/{id}/disable [post]
+func (h *UserHandler) DisableUser(ctx *wbgin.Context) {
+	h.setDisabled(ctx, true)
+}
+
+// EnableUser
+// @Summary Enable user
+// @Description Re-enable a disabled account (users.manage)
+// @Tags users-admin
+// @Produce json
+// @Param id path string true "User UUID"
+// @Success 200 {object} dto.UserResponse
+// @Failure 400 {object} dto.ErrorResponse
+// @Failure 404 {object} dto.ErrorResponse
+// @Failure 409 {object} dto.ErrorResponse "Own account"
+// @Security BearerAuth
+// @Router /api/users/{id}/enable [post]
+func (h *UserHandler) EnableUser(ctx *wbgin.Context) {
+	h.setDisabled(ctx, false)
+}
+
+func (h *UserHandler) setDisabled(ctx *wbgin.Context, disabled bool) {
+	actorID, actorLogin, ok := userFromContext(ctx)
+	if !ok {
+		return
+	}
+	u, err := h.Service.SetDisabled(ctx.Request.Context(), ctx.Param("id"), disabled, actorID, actorLogin)
+	if err != nil {
+		RespondError(ctx, err)
+		return
+	}
+	ctx.JSON(http.StatusOK, userResponse(u))
+}
+
+// ResetUserPassword
+// @Summary Reset user password
+// @Description Set a new password for a user (users.manage); the password policy applies. All sessions of the user are signed out
+// @Tags users-admin
+// @Accept json
+// @Produce json
+// @Param id path string true "User UUID"
+// @Param body body dto.UserPasswordResetRequest true "New password"
+// @Success 200 {object} map[string]string
+// @Failure 400 {object} dto.ErrorResponse
+// @Failure 404 {object} dto.ErrorResponse
+// @Failure 422 {object} dto.ErrorResponse
+// @Security BearerAuth
+// @Router /api/users/{id}/password [post]
+func (h *UserHandler) ResetUserPassword(ctx *wbgin.Context) {
+	var req dto.UserPasswordResetRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		badRequest(ctx, err)
+		return
+	}
+	actorID, actorLogin, ok := userFromContext(ctx)
+	if !ok {
+		return
+	}
+	err := h.Service.ResetPassword(ctx.Request.Context(), ctx.Param("id"), req.Password, actorID, actorLogin)
+	if err != nil {
+		RespondError(ctx, err)
+		return
+	}
+	ctx.JSON(http.StatusOK, wbgin.H{"status": "password reset"})
+}
+
+// DeleteUser
+// @Summary Delete user
+// @Description Delete another user's account (users.manage). Users referenced by other records (e.g. issued invitations) must be disabled instead
+// @Tags users-admin
+// @Produce json
+// @Param id path string true "User UUID"
+// @Success 200 {object} map[string]string
+// @Failure 400 {object} dto.ErrorResponse
+// @Failure 404 {object} dto.ErrorResponse
+// @Failure 409 {object} dto.ErrorResponse
+// @Security BearerAuth
+// @Router /api/users/{id} [delete]
+func (h *UserHandler) DeleteUser(ctx *wbgin.Context) {
+	actorID, actorLogin, ok := userFromContext(ctx)
+	if !ok {
+		return
+	}
+	err := h.Service.DeleteUser(ctx.Request.Context(), ctx.Param("id"), actorID, actorLogin)
+	if err != nil {
+		RespondError(ctx, err)
+		return
+	}
+	ctx.JSON(http.StatusOK, wbgin.H{"status": "deleted"})
+}
+
+// UnlockUser
+// @Summary Unlock user login
+// @Description Lift a lockout caused by failed login attempts before it expires (users.manage). IP lockouts are not affected
+// @Tags users-admin
+// @Produce json
+// @Param id path string true "User UUID"
+// @Success 200 {object} map[string]string
+// @Failure 400 {object} dto.ErrorResponse
+// @Failure 404 {object} dto.ErrorResponse
+// @Security BearerAuth
+// @Router /api/users/{id}/unlock [post]
+func (h *UserHandler) UnlockUser(ctx *wbgin.Context) {
+	actorID, actorLogin, ok := userFromContext(ctx)
+	if !ok {
+		return
+	}
+	err := h.Service.UnlockUser(ctx.Request.Context(), ctx.Param("id"), actorID, actorLogin)
+	if err != nil {
+		RespondError(ctx, err)
+		return
+	}
+	ctx.JSON(http.StatusOK, wbgin.H{"status": "unlocked"})
+}
+
+// GetUserAudit
+// @Summary User audit trail
+// @Description Role changes, disables/enables, password resets, deletion, lockouts and unlocks, two-factor changes of a user, oldest first (users.manage)
+// @Tags users-admin
+// @Produce json
+// @Param id path string true "User UUID"
+// @Success 200 {array} dto.UserAuditResponse
+// @Failure 400 {object} dto.ErrorResponse
+// @Security BearerAuth
+// @Router /api/users/{id}/audit [get]
+func (h *UserHandler) GetUserAudit(ctx *wbgin.Context) {
+	entries, err := h.Service.GetUserAudit(ctx.Request.Context(), ctx.Param("id"))
+	if err != nil {
+		RespondError(ctx, err)
+		return
+	}
+	res := make([]dto.UserAuditResponse, 0, len(entries))
+	for _, e := range entries {
+		// у действий системы автора нет
+		var actorID *string
+		if e.ActorID != nil {
+			id := e.ActorID.String()
+			actorID = &id
+		}
+		res = append(res, dto.UserAuditResponse{
+			ID:         e.ID.String(),
+			UserID:     e.UserID.String(),
+			UserLogin:  e.UserLogin,
+			Action:     e.Action,
+			OldValue:   e.OldValue,
+			NewValue:   e.NewValue,
+			ActorID:    actorID,
+			ActorLogin: e.ActorLogin,
+			ChangedAt:  e.ChangedAt,
+		})
+	}
+	ctx.JSON(http.StatusOK, res)
+}
@@ -0,0 +1,213 @@
+package handlers_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+	"time"
+
+	"warehousecontrol/internal/domain/errs"
+	"warehousecontrol/internal/domain/user"
+	"warehousecontrol/internal/web/dto"
+	"warehousecontrol/internal/web/handlers"
+
+	"github.com/google/uuid"
+	wbgin "github.com/wb-go/wbf/ginext"
+)
+
+func asAdmin(id string) func(*wbgin.Context) {
+	return func(c *wbgin.Context) {
+		c.Set("userId", "admin-id")
+		c.Set("login", "admin")
+		if id != "" {
+			c.AddParam("id", id)
+		}
+	}
+}
+
+func TestUserHandler_GetUsers(t *testing.T) {
+	var got user.ListOptions
+	now := time.Now()
+	mock := &MockUserService{
+		GetUsersFn: func(opts user.ListOptions) (*user.Page, error) {
+			if opts.Role == "root" {
+				return nil, errs.Errorf(errs.ErrInvalidInput, "invalid role filter: %s", opts.Role)
+			}
+			got = opts
+			return &user.Page{
+				Users: []*user.User{{Id: uuid.New(), Login: "bob", Role: user.Viewer, DisabledAt: &now}},
+				Total: 7,
+			}, nil
+		},
+	}
+	h := handlers.NewUserHandler(mock)
+
+	rr := performJSON(h.GetUsers, http.MethodGet, "/api/users?login=bo&role=viewer&disabled=true&limit=10&offset=5", nil, nil)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", rr.Code, rr.Body.String())
+	}
+	if got.Login != "bo" || got.Role != user.Viewer || got.Disabled == nil || !*got.Disabled || got.Limit != 10 || got.Offset != 5 {
+		t.Fatalf("unexpected options: %+v", got)
+	}
+	var res dto.UserListResponse
+	_ = json.Unmarshal(rr.Body.Bytes(), &res)
+	if res.Total != 7 || len(res.Users) != 1 || !res.Users[0].Disabled {
+		t.Fatalf("unexpected response: %s", rr.Body.String())
+	}
+
+	rr = performJSON(h.GetUsers, http.MethodGet, "/api/users?role=root", nil, nil)
+	if rr.Code != http.StatusBadRequest {
+		t.Fatalf("expected 400 for invalid role, got %d", rr.Code)
+	}
+}
+
+func TestUserHandler_ChangeUserRole(t *testing.T) {
+	mock := &MockUserService{
+		ChangeRoleFn: func(id, role, actorID, actorLogin string) (*user.User, error) {
+			if actorID != "admin-id" || actorLogin != "admin" {
+				t.Fatalf("unexpected actor %s/%s", actorID, actorLogin)
+			}
+			if id == "last" {
+				return nil, user.ErrLastAdmin
+			}
+			if role == "root" {
+				return nil, errs.Errorf(errs.ErrValidation, "unknown role: %s", role)
+			}
+			return &user.User{Id: uuid.New(), Login: "bob", Role: user.Role(role)}, nil
+		},
+	}
+	h := handlers.NewUserHandler(mock)
+
+	rr := performJSON(h.ChangeUserRole, http.MethodPut, "/api/users/x/role", dto.UserRoleRequest{Role: "manager"}, asAdmin("x"))
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d", rr.Code)
+	}
+	rr = performJSON(h.ChangeUserRole, http.MethodPut, "/api/users/last/role", dto.UserRoleRequest{Role: "viewer"}, asAdmin("last"))
+	if rr.Code != http.StatusConflict {
+		t.Fatalf("expected 409 for last admin, got %d", rr.Code)
+	}
+	rr = performJSON(h.ChangeUserRole, http.MethodPut, "/api/users/x/role", map[string]string{"role": "root"}, asAdmin("x"))
+	if rr.Code != http.StatusUnprocessableEntity {
+		t.Fatalf("expected 422 for unknown role, got %d", rr.Code)
+	}
+	rr = performJSON(h.ChangeUserRole, http.MethodPut, "/api/users/x/role", map[string]string{}, asAdmin("x"))
+	if rr.Code != http.StatusBadRequest {
+		t.Fatalf("expected 400 for missing role, got %d", rr.Code)
+	}
+}
+
+func TestUserHandler_DisableEnableUser(t *testing.T) {
+	var calls []bool
+	mock := &MockUserService{
+		SetDisabledFn: func(id string, disabled bool, actorID, actorLogin string) (*user.User, error) {
+			calls = append(calls, disabled)
+			if id == "admin-id" {
+				return nil, user.ErrSelfChange
+			}
+			return &user.User{Id: uuid.New(), Login: "bob", Role: user.Viewer}, nil
+		},
+	}
+	h := handlers.NewUserHandler(mock)
+
+	if rr := performJSON(h.DisableUser, http.MethodPost, "/api/users/x/disable", nil, asAdmin("x")); rr.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d", rr.Code)
+	}
+	if rr := performJSON(h.EnableUser, http.MethodPost, "/api/users/x/enable", nil, asAdmin("x")); rr.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d", rr.Code)
+	}
+	if len(calls) != 2 || !calls[0] || calls[1] {
+		t.Fatalf("unexpected calls: %v", calls)
+	}
+	if rr := performJSON(h.DisableUser, http.MethodPost, "/api/users/admin-id/disable", nil, asAdmin("admin-id")); rr.Code != http.StatusConflict {
+		t.Fatalf("expected 409 for own account, got %d", rr.Code)
+	}
+}
+
+func TestUserHandler_ResetUserPassword(t *testing.T) {
+	var gotPassword string
+	mock := &MockUserService{
+		ResetPasswordFn: func(id, password, actorID, actorLogin string) error {
+			gotPassword = password
+			return nil
+		},
+	}
+	h := handlers.NewUserHandler(mock)
+
+	rr := performJSON(h.ResetUserPassword, http.MethodPost, "/api/users/x/password", dto.UserPasswordResetRequest{Password: "Password2"}, asAdmin("x"))
+	if rr.Code != http.StatusOK || gotPassword != "Password2" {
+		t.Fatalf("expected 200 with password passed through, got %d (%q)", rr.Code, gotPassword)
+	}
+	rr = performJSON(h.ResetUserPassword, http.MethodPost, "/api/users/x/password", map[string]string{}, asAdmin("x"))
+	if rr.Code != http.StatusBadRequest {
+		t.Fatalf("expected 400 without password, got %d", rr.Code)
+	}
+}
+
+func TestUserHandler_DeleteUser_Referenced(t *testing.T) {
+	mock := &MockUserService{
+		DeleteUserFn: func(id, actorID, actorLogin string) error {
+			return user.ErrReferenced
+		},
+	}
+	h := handlers.NewUserHandler(mock)
+
+	rr := performJSON(h.DeleteUser, http.MethodDelete, "/api/users/x", nil, asAdmin("x"))
+	if rr.Code != http.StatusConflict {
+		t.Fatalf("expected 409, got %d", rr.Code)
+	}
+}
+
+func TestUserHandler_UnlockUser(t *testing.T) {
+	var got string
+	mock := &MockUserService{
+		UnlockUserFn: func(id, actorID, actorLogin string) error {
+			got = id + "/" + actorLogin
+			return nil
+		},
+	}
+	h := handlers.NewUserHandler(mock)
+
+	rr := performJSON(h.UnlockUser, http.MethodPost, "/api/users/x/unlock", nil, asAdmin("x"))
+	if rr.Code != http.StatusOK || got != "x/admin" {
+		t.Fatalf("expected unlock by admin, got %d %q", rr.Code, got)
+	}
+}
+
+func TestUserHandler_GetUserAudit(t *testing.T) {
+	old, role := "viewer", "manager"
+	actor := uuid.New()
+	mock := &MockUserService{
+		GetUserAuditFn: func(id string) ([]*user.AuditEntry, error) {
+			return []*user.AuditEntry{{
+				ID:         uuid.New(),
+				UserID:     uuid.New(),
+				UserLogin:  "bob",
+				Action:     user.AuditRoleChanged,
+				OldValue:   &old,
+				NewValue:   &role,
+				ActorID:    &actor,
+				ActorLogin: "admin",
+			}, {
+				ID:         uuid.New(),
+				UserID:     uuid.New(),
+				UserLogin:  "bob",
+				Action:     user.AuditLocked,
+				ActorLogin: "system",
+			}}, nil
+		},
+	}
+	h := handlers.NewUserHandler(mock)
+
+	rr := performJSON(h.GetUserAudit, http.MethodGet, "/api/users/x/audit", nil, asAdmin("x"))
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d", rr.Code)
+	}
+	var res []dto.UserAuditResponse
+	_ = json.Unmarshal(rr.Body.Bytes(), &res)
+	if len(res) != 2 || res[0].Action != "role_changed" || *res[0].NewValue != "manager" || *res[0].ActorID != actor.String() {
+		t.Fatalf("unexpected response: %s", rr.Body.String())
+	}
+	if res[1].ActorID != nil {
+		t.Fatalf("unexpected response: %s", rr.Body.String())
+	}
+}
@@ -1,7 +1,9 @@
 package handlers
 
 import (
+	"context"
 	"net/http"
+	"time"
 
 	"warehousecontrol/internal/auth"
 	"warehousecontrol/internal/domain/user"
@@ -15,10 +17,49 @@ type UserHandler struct {
 }
 
 type UserIFace interface {
-	Login(Login, Password string) (*auth.JWTResponse, error)
-	Registration(Login, Password, Role string) (*user.User, error)
-	RefreshTokens(tokenStr string) (*auth.JWTResponse, error)
-	ValidateTokens(tokenStr string) (*auth.JWTPayload, error)
+	Login(ctx context.Context, Login, Password string, client user.Client) (*auth.LoginResponse, error)
+	LoginMFA(ctx context.Context, mfaToken, code string, client user.Client) (*auth.JWTResponse, []string, error)
+	LoginMFASetup(ctx context.Context, mfaToken string) (*user.TOTPSetup, error)
+	TOTPStatus(ctx context.Context, userID string) (*user.TOTPStatus, error)
+	SetupTOTP(ctx context.Context, userID string) (*user.TOTPSetup, error)
+	EnableTOTP(ctx context.Context, userID, code string) ([]string, error)
+	DisableTOTP(ctx context.Context, userID, code string, client user.Client) error
+	RegenerateRecoveryCodes(ctx context.Context, userID, code string, client user.Client) ([]string, error)
+	ResetTOTP(ctx context.Context, id, actorID, actorLogin string) error
+	Registration(ctx context.Context, Login, Password, InviteToken string) (*user.User, error)
+	Bootstrap(ctx context.Context, SetupToken, Login, Password string) (*user.User, error)
+	CreateInvitation(ctx context.Context, role string, ttl time.Duration, createdBy string) (*user.Invitation, string, error)
+	GetInvitations(ctx context.Context) ([]*user.Invitation, error)
+	RevokeInvitation(ctx context.Context, id string) error
+	GetUsers(ctx context.Context, opts user.ListOptions) (*user.Page, error)
+	GetUserByID(ctx context.Context, id string) (*user.User, error)
+	ChangeRole(ctx context.Context, id, role, actorID, actorLogin string) (*user.User, error)
+	SetDisabled(ctx context.Context, id string, disabled bool, actorID, actorLogin string) (*user.User, error)
+	ResetPassword(ctx context.Context, id, password, actorID, actorLogin string) error
+	DeleteUser(ctx context.Context, id, actorID, actorLogin string) error
+	GetUserAudit(ctx context.Context, id string) ([]*user.AuditEntry, error)
+	UnlockUser(ctx context.Context, id, actorID, actorLogin string) error
+	ChangePassword(ctx context.Context, userID, current, next string, client user.Client) (*auth.JWTResponse, error)
+	IssuePasswordResetToken(ctx context.Context, id, actorID, actorLogin string) (*user.PasswordResetToken, string, error)
+	ResetPasswordWithToken(ctx context.Context, token, password string) error
+	RefreshTokens(ctx context.Context, tokenStr string) (*auth.JWTResponse, error)
+	Logout(ctx context.Context, refreshToken string, all bool) error
+	GetSessions(ctx context.Context, userID string) ([]*user.Session, error)
+	RevokeSession(ctx context.Context, userID, sessionID string) error
+	RevokeSessions(ctx context.Context, userID string) error
+	ValidateTokens(ctx context.Context, tokenStr string) (*auth.JWTPayload, error)
+	CreateServiceAccount(ctx context.Context, login, role string) (*user.User, error)
+	CreateAPIKey(ctx context.Context, userID, name string, scopes []string, ttl time.Duration, actorID, actorLogin string) (*user.APIKey, string, error)
+	GetAPIKeys(ctx context.Context, userID string) ([]*user.APIKey, error)
+	RotateAPIKey(ctx context.Context, userID, keyID string, ttl, grace time.Duration, actorID, actorLogin string) (*user.APIKey, string, error)
+	RevokeAPIKey(ctx context.Context, userID, keyID, actorID, actorLogin string) error
+	AuthenticateAPIKey(ctx context.Context, token string) (*user.APIKey, *user.User, error)
+	RolePermissions(ctx context.Context, role user.Role) (user.Permissions, error)
+	GetRoles(ctx context.Context) ([]*user.RoleDefinition, error)
+	GetRole(ctx context.Context, name string) (*user.RoleDefinition, error)
+	CreateRole(ctx context.Context, name, description string, permissions []string, actorLogin string) (*user.RoleDefinition, error)
+	UpdateRole(ctx context.Context, name, description string, permissions []string, actorLogin string) (*user.RoleDefinition, error)
+	DeleteRole(ctx context.Context, name, actorLogin string) error
 }
 
 func NewUserHandler(service UserIFace) *UserHandler {
@@ -29,88 +70,261 @@ func NewUserHandler(service UserIFace) *UserHandler {
 
 // RegisterUser
 // @Summary Register a new user
-// @Description Create a new user account with role
+// @Description Create a new user account from an admin-issued invitation. The role is fixed by the invitation, which is single-use and expires
 // @Tags users
 // @Accept json
 // @Produce json
 // @Param body body dto.UserRegistrationRequest true "User registration info"
 // @Success 200 {object} dto.UserResponse
-// @Failure 400 {object} map[string]string
-// @Failure 500 {object} map[string]string
+// @Failure 400 {object} dto.ErrorResponse
+// @Failure 403 {object} dto.ErrorResponse "Invitation expired"
+// @Failure 404 {object} dto.ErrorResponse "Invitation not found"
+// @Failure 409 {object} dto.ErrorResponse "Login taken or invitation already used"
+// @Failure 422 {object} dto.ErrorResponse
+// @Failure 500 {object} dto.ErrorResponse
 // @Router /api/auth/register [post]
 func (h *UserHandler) RegisterUser(ctx *wbgin.Context) {
 	var req dto.UserRegistrationRequest
 	if err := ctx.ShouldBindJSON(&req); err != nil {
-		ctx.JSON(http.StatusBadRequest, wbgin.H{"error": err.Error()})
+		badRequest(ctx, err)
 		return
 	}
-	user, err := h.Service.Registration(req.Login, req.Password, req.Role)
+	user, err := h.Service.Registration(ctx.Request.Context(), req.Login, req.Password, req.InviteToken)
 	if err != nil {
-		ctx.JSON(http.StatusInternalServerError, wbgin.H{"error": err.Error()})
+		RespondError(ctx, err)
 		return
 	}
-	res := dto.UserResponse{
-		ID:    user.Id.String(),
-		Login: user.Login,
-		Role:  string(user.Role),
+	ctx.JSON(http.StatusOK, userResponse(user))
+}
+
+// Bootstrap
+// @Summary Create the first admin
+// @Description One-time setup: create the initial admin using SETUP_TOKEN. Disabled when the token is not configured or an admin already exists
+// @Tags users
+// @Accept json
+// @Produce json
+// @Param body body dto.BootstrapRequest true "Setup token and admin credentials"
+// @Success 200 {object} dto.UserResponse
+// @Failure 400 {object} dto.ErrorResponse
+// @Failure 401 {object} dto.ErrorResponse
+// @Failure 403 {object} dto.ErrorResponse
+// @Failure 409 {object} dto.ErrorResponse
+// @Failure 422 {object} dto.ErrorResponse
+// @Router /api/auth/bootstrap [post]
+func (h *UserHandler) Bootstrap(ctx *wbgin.Context) {
+	var req dto.BootstrapRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		badRequest(ctx, err)
+		return
+	}
+	user, err := h.Service.Bootstrap(ctx.Request.Context(), req.SetupToken, req.Login, req.Password)
+	if err != nil {
+		RespondError(ctx, err)
+		return
+	}
+	ctx.JSON(http.StatusOK, userResponse(user))
+}
+
+// CreateInvitation
+// @Summary Create invitation
+// @Description Issue a single-use invitation with a fixed role (users.manage). The token is returned only once
+// @Tags invitations
+// @Accept json
+// @Produce json
+// @Param body body dto.InvitationCreateRequest true "Role and TTL"
+// @Success 201 {object} dto.InvitationResponse
+// @Failure 400 {object} dto.ErrorResponse
+// @Failure 422 {object} dto.ErrorResponse
+// @Failure 500 {object} dto.ErrorResponse
+// @Security BearerAuth
+// @Router /api/invitations [post]
+func (h *UserHandler) CreateInvitation(ctx *wbgin.Context) {
+	var req dto.InvitationCreateRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		badRequest(ctx, err)
+		return
+	}
+	userID, _, ok := userFromContext(ctx)
+	if !ok {
+		return
+	}
+	inv, token, err := h.Service.CreateInvitation(ctx.Request.Context(), req.Role, time.Duration(req.TTLHours)*time.Hour, userID)
+	if err != nil {
+		RespondError(ctx, err)
+		return
+	}
+	res := invitationResponse(inv)
+	res.Token = token
+	ctx.JSON(http.StatusCreated, res)
+}
+
+// GetInvitations
+// @Summary List invitations
+// @Description List issued invitations with their state, newest first (users.manage)
+// @Tags invitations
+// @Produce json
+// @Success 200 {array} dto.InvitationResponse
+// @Failure 500 {object} dto.ErrorResponse
+// @Security BearerAuth
+// @Router /api/invitations [get]
+func (h *UserHandler) GetInvitations(ctx *wbgin.Context) {
+	invitations, err := h.Service.GetInvitations(ctx.Request.Context())
+	if err != nil {
+		RespondError(ctx, err)
+		return
+	}
+	res := make([]dto.InvitationResponse, 0, len(invitations))
+	for _, inv := range invitations {
+		res = append(res, invitationResponse(inv))
 	}
 	ctx.JSON(http.StatusOK, res)
 }
 
+// RevokeInvitation
+// @Summary Revoke invitation
+// @Description Delete an unused invitation (users.manage)
+// @Tags invitations
+// @Produce json
+// @Param id path string true "Invitation UUID"
+// @Success 200 {object} map[string]string
+// @Failure 400 {object} dto.ErrorResponse
+// @Failure 404 {object} dto.ErrorResponse
+// @Failure 409 {object} dto.ErrorResponse "Invitation already used"
+// @Security BearerAuth
+// @Router /api/invitations/{id} [delete]
+func (h *UserHandler) RevokeInvitation(ctx *wbgin.Context) {
+	err := h.Service.RevokeInvitation(ctx.Request.Context(), ctx.Param("id"))
+	if err != nil {
+		RespondError(ctx, err)
+		return
+	}
+	ctx.JSON(http.StatusOK, wbgin.H{"status": "revoked"})
+}
+
+func userResponse(u *user.User) dto.UserResponse {
+	return dto.UserResponse{
+		ID:             u.Id.String(),
+		Login:          u.Login,
+		Role:           string(u.Role),
+		CreatedAt:      u.CreatedAt,
+		Disabled:       u.Disabled(),
+		DisabledAt:     u.DisabledAt,
+		ServiceAccount: u.ServiceAccount,
+	}
+}
+
+func invitationResponse(inv *user.Invitation) dto.InvitationResponse {
+	res := dto.InvitationResponse{
+		ID:        inv.ID.String(),
+		Role:      string(inv.Role),
+		CreatedBy: inv.CreatedBy.String(),
+		CreatedAt: inv.CreatedAt,
+		ExpiresAt: inv.ExpiresAt,
+		UsedAt:    inv.UsedAt,
+	}
+	if inv.UsedBy != nil {
+		res.UsedBy = inv.UsedBy.String()
+	}
+	return res
+}
+
 // LoginUser
 // @Summary Login user
-// @Description Authenticate user and return JWT tokens
+// @Description Authenticate user and return JWT tokens. The password is checked by the configured authenticators in order (local database, LDAP); an LDAP user gets an account on first login with the role mapped from directory groups. Repeated failures temporarily lock the login and the client IP. When two-factor authentication is on (or mandatory for the role) the response has mfa_required=true and an mfa_token for /api/auth/login/2fa instead of tokens
 // @Tags users
 // @Accept json
 // @Produce json
 // @Param body body dto.UserLoginRequest true "User login info"
-// @Success 200 {object} dto.JWTResponse
-// @Failure 400 {object} map[string]string
-// @Failure 401 {object} map[string]string
+// @Success 200 {object} dto.LoginResponse
+// @Failure 400 {object} dto.ErrorResponse
+// @Failure 401 {object} dto.ErrorResponse
+// @Failure 403 {object} dto.ErrorResponse "Account disabled or no role mapped to the LDAP groups"
+// @Failure 409 {object} dto.ErrorResponse "LDAP login used by another account"
+// @Failure 429 {object} dto.ErrorResponse "Too many failed attempts"
+// @Failure 503 {object} dto.ErrorResponse "LDAP directory unavailable"
 // @Router /api/auth/login [post]
 func (h *UserHandler) LoginUser(ctx *wbgin.Context) {
 	var req dto.UserLoginRequest
 	if err := ctx.ShouldBindJSON(&req); err != nil {
-		ctx.JSON(http.StatusBadRequest, wbgin.H{"error": err.Error()})
+		badRequest(ctx, err)
 		return
 	}
-	jwtResp, err := h.Service.Login(req.Login, req.Password)
+	loginResp, err := h.Service.Login(ctx.Request.Context(), req.Login, req.Password, clientInfo(ctx))
 	if err != nil {
-		ctx.JSON(http.StatusUnauthorized, wbgin.H{"error": err.Error()})
+		unauthorized(ctx, err)
 		return
 	}
-	res := dto.JWTResponse{
-		AccessToken:  jwtResp.AccessToken,
-		RefreshToken: jwtResp.RefreshToken,
+	ctx.JSON(http.StatusOK, loginResponse(loginResp))
+}
+
+func loginResponse(resp *auth.LoginResponse) dto.LoginResponse {
+	if resp.MFA != nil {
+		return dto.LoginResponse{
+			MFARequired:   true,
+			MFAToken:      resp.MFA.Token,
+			MFAEnrollment: resp.MFA.Enrollment,
+			MFAExpiresAt:  &resp.MFA.ExpiresAt,
+		}
+	}
+	return dto.LoginResponse{
+		AccessToken:  resp.AccessToken,
+		RefreshToken: resp.RefreshToken,
+		SessionID:    resp.SessionID,
 	}
-	ctx.JSON(http.StatusOK, res)
 }
 
 // RefreshToken
 // @Summary Refresh JWT token
-// @Description Refresh access and refresh tokens using existing refresh token
+// @Description Exchange a refresh token for a new pair. Each refresh token is single-use: the response carries the next one, and presenting a used token revokes the whole token family. The new pair reflects the user's current role; disabled accounts are rejected
 // @Tags users
 // @Accept json
 // @Produce json
 // @Param body body dto.TokenRefreshRequest true "Refresh token request"
 // @Success 200 {object} dto.JWTResponse
-// @Failure 400 {object} map[string]string
-// @Failure 401 {object} map[string]string
-// @Router /api/auth/refresh-token [post]
+// @Failure 400 {object} dto.ErrorResponse
+// @Failure 401 {object} dto.ErrorResponse
+// @Failure 403 {object} dto.ErrorResponse "Account disabled"
+// @Router /api/auth/refresh [post]
 func (h *UserHandler) RefreshToken(ctx *wbgin.Context) {
 	var req dto.TokenRefreshRequest
 	if err := ctx.ShouldBindJSON(&req); err != nil {
-		ctx.JSON(http.StatusBadRequest, wbgin.H{"error": err.Error()})
+		badRequest(ctx, err)
 		return
 	}
-	jwtResp, err := h.Service.RefreshTokens(req.RefreshToken)
+	jwtResp, err := h.Service.RefreshTokens(ctx.Request.Context(), req.RefreshToken)
 	if err != nil {
-		ctx.JSON(http.StatusUnauthorized, wbgin.H{"error": err.Error()})
+		unauthorized(ctx, err)
 		return
 	}
 	res := dto.JWTResponse{
 		AccessToken:  jwtResp.AccessToken,
 		RefreshToken: jwtResp.RefreshToken,
+		SessionID:    jwtResp.SessionID,
 	}
 	ctx.JSON(http.StatusOK, res)
 }
+
+// Logout
+// @Summary Logout
+// @Description Revoke the refresh token family of the presented token, or every refresh token of the user with all=true. Access tokens stay valid until they expire
+// @Tags users
+// @Accept json
+// @Produce json
+// @Param body body dto.LogoutRequest true "Refresh token"
+// @Success 200 {object} map[string]string
+// @Failure 400 {object} dto.ErrorResponse
+// @Failure 401 {object} dto.ErrorResponse
+// @Router /api/auth/logout [post]
+func (h *UserHandler) Logout(ctx *wbgin.Context) {
+	var req dto.LogoutRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		badRequest(ctx, err)
+		return
+	}
+	err := h.Service.Logout(ctx.Request.Context(), req.RefreshToken, req.All)
+	if err != nil {
+		unauthorized(ctx, err)
+		return
+	}
+	ctx.JSON(http.StatusOK, wbgin.H{"status": "logged out"})
+}
@@ -2,13 +2,16 @@ package handlers_test
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"errors"
 	"net/http"
 	"net/http/httptest"
 	"testing"
+	"time"
 
 	"warehousecontrol/internal/auth"
+	"warehousecontrol/internal/domain/errs"
 	"warehousecontrol/internal/domain/user"
 	"warehousecontrol/internal/web/dto"
 	"warehousecontrol/internal/web/handlers"
@@ -19,28 +22,230 @@ import (
 )
 
 type MockUserService struct {
-	LoginFn          func(login, password string) (*auth.JWTResponse, error)
-	RegistrationFn   func(login, password, role string) (*user.User, error)
+	LoginFn          func(login, password string, client user.Client) (*auth.LoginResponse, error)
+	RegistrationFn   func(login, password, inviteToken string) (*user.User, error)
+	BootstrapFn      func(setupToken, login, password string) (*user.User, error)
 	RefreshTokensFn  func(tokenStr string) (*auth.JWTResponse, error)
+	LogoutFn         func(refreshToken string, all bool) error
 	ValidateTokensFn func(tokenStr string) (*auth.JWTPayload, error)
+
+	CreateInvitationFn func(role string, ttl time.Duration, createdBy string) (*user.Invitation, string, error)
+	GetInvitationsFn   func() ([]*user.Invitation, error)
+	RevokeInvitationFn func(id string) error
+
+	GetUsersFn      func(opts user.ListOptions) (*user.Page, error)
+	GetUserByIDFn   func(id string) (*user.User, error)
+	ChangeRoleFn    func(id, role, actorID, actorLogin string) (*user.User, error)
+	SetDisabledFn   func(id string, disabled bool, actorID, actorLogin string) (*user.User, error)
+	ResetPasswordFn func(id, password, actorID, actorLogin string) error
+	DeleteUserFn    func(id, actorID, actorLogin string) error
+	GetUserAuditFn  func(id string) ([]*user.AuditEntry, error)
+	UnlockUserFn    func(id, actorID, actorLogin string) error
+
+	ChangePasswordFn          func(userID, current, next string, client user.Client) (*auth.JWTResponse, error)
+	IssuePasswordResetTokenFn func(id, actorID, actorLogin string) (*user.PasswordResetToken, string, error)
+	ResetPasswordWithTokenFn  func(token, password string) error
+
+	GetSessionsFn    func(userID string) ([]*user.Session, error)
+	RevokeSessionFn  func(userID, sessionID string) error
+	RevokeSessionsFn func(userID string) error
+
+	LoginMFAFn                func(mfaToken, code string, client user.Client) (*auth.JWTResponse, []string, error)
+	LoginMFASetupFn           func(mfaToken string) (*user.TOTPSetup, error)
+	TOTPStatusFn              func(userID string) (*user.TOTPStatus, error)
+	SetupTOTPFn               func(userID string) (*user.TOTPSetup, error)
+	EnableTOTPFn              func(userID, code string) ([]string, error)
+	DisableTOTPFn             func(userID, code string, client user.Client) error
+	RegenerateRecoveryCodesFn func(userID, code string, client user.Client) ([]string, error)
+	ResetTOTPFn               func(id, actorID, actorLogin string) error
+
+	CreateServiceAccountFn func(login, role string) (*user.User, error)
+	CreateAPIKeyFn         func(userID, name string, scopes []string, ttl time.Duration, actorID, actorLogin string) (*user.APIKey, string, error)
+	GetAPIKeysFn           func(userID string) ([]*user.APIKey, error)
+	RotateAPIKeyFn         func(userID, keyID string, ttl, grace time.Duration, actorID, actorLogin string) (*user.APIKey, string, error)
+	RevokeAPIKeyFn         func(userID, keyID, actorID, actorLogin string) error
+	AuthenticateAPIKeyFn   func(token string) (*user.APIKey, *user.User, error)
+
+	RolePermissionsFn func(role user.Role) (user.Permissions, error)
+	GetRolesFn        func() ([]*user.RoleDefinition, error)
+	GetRoleFn         func(name string) (*user.RoleDefinition, error)
+	CreateRoleFn      func(name, description string, permissions []string, actorLogin string) (*user.RoleDefinition, error)
+	UpdateRoleFn      func(name, description string, permissions []string, actorLogin string) (*user.RoleDefinition, error)
+	DeleteRoleFn      func(name, actorLogin string) error
+}
+
+func (m *MockUserService) Login(_ context.Context, login, password string, client user.Client) (*auth.LoginResponse, error) {
+	return m.LoginFn(login, password, client)
+}
+
+func (m *MockUserService) LoginMFA(_ context.Context, mfaToken, code string, client user.Client) (*auth.JWTResponse, []string, error) {
+	return m.LoginMFAFn(mfaToken, code, client)
+}
+
+func (m *MockUserService) LoginMFASetup(_ context.Context, mfaToken string) (*user.TOTPSetup, error) {
+	return m.LoginMFASetupFn(mfaToken)
+}
+
+func (m *MockUserService) TOTPStatus(_ context.Context, userID string) (*user.TOTPStatus, error) {
+	return m.TOTPStatusFn(userID)
+}
+
+func (m *MockUserService) SetupTOTP(_ context.Context, userID string) (*user.TOTPSetup, error) {
+	return m.SetupTOTPFn(userID)
+}
+
+func (m *MockUserService) EnableTOTP(_ context.Context, userID, code string) ([]string, error) {
+	return m.EnableTOTPFn(userID, code)
+}
+
+func (m *MockUserService) DisableTOTP(_ context.Context, userID, code string, client user.Client) error {
+	return m.DisableTOTPFn(userID, code, client)
+}
+
+func (m *MockUserService) RegenerateRecoveryCodes(_ context.Context, userID, code string, client user.Client) ([]string, error) {
+	return m.RegenerateRecoveryCodesFn(userID, code, client)
+}
+
+func (m *MockUserService) ResetTOTP(_ context.Context, id, actorID, actorLogin string) error {
+	return m.ResetTOTPFn(id, actorID, actorLogin)
+}
+
+func (m *MockUserService) Registration(_ context.Context, login, password, inviteToken string) (*user.User, error) {
+	return m.RegistrationFn(login, password, inviteToken)
+}
+
+func (m *MockUserService) Bootstrap(_ context.Context, setupToken, login, password string) (*user.User, error) {
+	return m.BootstrapFn(setupToken, login, password)
+}
+
+func (m *MockUserService) CreateInvitation(_ context.Context, role string, ttl time.Duration, createdBy string) (*user.Invitation, string, error) {
+	return m.CreateInvitationFn(role, ttl, createdBy)
+}
+
+func (m *MockUserService) GetInvitations(_ context.Context) ([]*user.Invitation, error) {
+	return m.GetInvitationsFn()
+}
+
+func (m *MockUserService) RevokeInvitation(_ context.Context, id string) error {
+	return m.RevokeInvitationFn(id)
+}
+
+func (m *MockUserService) GetUsers(_ context.Context, opts user.ListOptions) (*user.Page, error) {
+	return m.GetUsersFn(opts)
+}
+
+func (m *MockUserService) GetUserByID(_ context.Context, id string) (*user.User, error) {
+	return m.GetUserByIDFn(id)
+}
+
+func (m *MockUserService) ChangeRole(_ context.Context, id, role, actorID, actorLogin string) (*user.User, error) {
+	return m.ChangeRoleFn(id, role, actorID, actorLogin)
+}
+
+func (m *MockUserService) SetDisabled(_ context.Context, id string, disabled bool, actorID, actorLogin string) (*user.User, error) {
+	return m.SetDisabledFn(id, disabled, actorID, actorLogin)
+}
+
+func (m *MockUserService) ResetPassword(_ context.Context, id, password, actorID, actorLogin string) error {
+	return m.ResetPasswordFn(id, password, actorID, actorLogin)
+}
+
+func (m *MockUserService) DeleteUser(_ context.Context, id, actorID, actorLogin string) error {
+	return m.DeleteUserFn(id, actorID, actorLogin)
 }
 
-func (m *MockUserService) Login(login, password string) (*auth.JWTResponse, error) {
-	return m.LoginFn(login, password)
+func (m *MockUserService) GetUserAudit(_ context.Context, id string) ([]*user.AuditEntry, error) {
+	return m.GetUserAuditFn(id)
 }
 
-func (m *MockUserService) Registration(login, password, role string) (*user.User, error) {
-	return m.RegistrationFn(login, password, role)
+func (m *MockUserService) UnlockUser(_ context.Context, id, actorID, actorLogin string) error {
+	return m.UnlockUserFn(id, actorID, actorLogin)
 }
 
-func (m *MockUserService) RefreshTokens(tokenStr string) (*auth.JWTResponse, error) {
+func (m *MockUserService) ChangePassword(_ context.Context, userID, current, next string, client user.Client) (*auth.JWTResponse, error) {
+	return m.ChangePasswordFn(userID, current, next, client)
+}
+
+func (m *MockUserService) IssuePasswordResetToken(_ context.Context, id, actorID, actorLogin string) (*user.PasswordResetToken, string, error) {
+	return m.IssuePasswordResetTokenFn(id, actorID, actorLogin)
+}
+
+func (m *MockUserService) ResetPasswordWithToken(_ context.Context, token, password string) error {
+	return m.ResetPasswordWithTokenFn(token, password)
+}
+
+func (m *MockUserService) GetSessions(_ context.Context, userID string) ([]*user.Session, error) {
+	return m.GetSessionsFn(userID)
+}
+
+func (m *MockUserService) RevokeSession(_ context.Context, userID, sessionID string) error {
+	return m.RevokeSessionFn(userID, sessionID)
+}
+
+func (m *MockUserService) RevokeSessions(_ context.Context, userID string) error {
+	return m.RevokeSessionsFn(userID)
+}
+
+func (m *MockUserService) Logout(_ context.Context, refreshToken string, all bool) error {
+	return m.LogoutFn(refreshToken, all)
+}
+
+func (m *MockUserService) RefreshTokens(_ context.Context, tokenStr string) (*auth.JWTResponse, error) {
 	return m.RefreshTokensFn(tokenStr)
 }
 
-func (m *MockUserService) ValidateTokens(tokenStr string) (*auth.JWTPayload, error) {
+func (m *MockUserService) ValidateTokens(_ context.Context, tokenStr string) (*auth.JWTPayload, error) {
 	return m.ValidateTokensFn(tokenStr)
 }
 
+func (m *MockUserService) CreateServiceAccount(_ context.Context, login, role string) (*user.User, error) {
+	return m.CreateServiceAccountFn(login, role)
+}
+
+func (m *MockUserService) CreateAPIKey(_ context.Context, userID, name string, scopes []string, ttl time.Duration, actorID, actorLogin string) (*user.APIKey, string, error) {
+	return m.CreateAPIKeyFn(userID, name, scopes, ttl, actorID, actorLogin)
+}
+
+func (m *MockUserService) GetAPIKeys(_ context.Context, userID string) ([]*user.APIKey, error) {
+	return m.GetAPIKeysFn(userID)
+}
+
+func (m *MockUserService) RotateAPIKey(_ context.Context, userID, keyID string, ttl, grace time.Duration, actorID, actorLogin string) (*user.APIKey, string, error) {
+	return m.RotateAPIKeyFn(userID, keyID, ttl, grace, actorID, actorLogin)
+}
+
+func (m *MockUserService) RevokeAPIKey(_ context.Context, userID, keyID, actorID, actorLogin string) error {
+	return m.RevokeAPIKeyFn(userID, keyID, actorID, actorLogin)
+}
+
+func (m *MockUserService) AuthenticateAPIKey(_ context.Context, token string) (*user.APIKey, *user.User, error) {
+	return m.AuthenticateAPIKeyFn(token)
+}
+
+func (m *MockUserService) RolePermissions(_ context.Context, role user.Role) (user.Permissions, error) {
+	return m.RolePermissionsFn(role)
+}
+
+func (m *MockUserService) GetRoles(_ context.Context) ([]*user.RoleDefinition, error) {
+	return m.GetRolesFn()
+}
+
+func (m *MockUserService) GetRole(_ context.Context, name string) (*user.RoleDefinition, error) {
+	return m.GetRoleFn(name)
+}
+
+func (m *MockUserService) CreateRole(_ context.Context, name, description string, permissions []string, actorLogin string) (*user.RoleDefinition, error) {
+	return m.CreateRoleFn(name, description, permissions, actorLogin)
+}
+
+func (m *MockUserService) UpdateRole(_ context.Context, name, description string, permissions []string, actorLogin string) (*user.RoleDefinition, error) {
+	return m.UpdateRoleFn(name, description, permissions, actorLogin)
+}
+
+func (m *MockUserService) DeleteRole(_ context.Context, name, actorLogin string) error {
+	return m.DeleteRoleFn(name, actorLogin)
+}
+
 func performRequestUser(hf func(*wbgin.Context), method, path string, body any) *httptest.ResponseRecorder {
 	var buf bytes.Buffer
 	if body != nil {
@@ -57,20 +262,23 @@ func performRequestUser(hf func(*wbgin.Context), method, path string, body any)
 
 func TestUserHandler_RegisterUser_Success(t *testing.T) {
 	mockService := &MockUserService{
-		RegistrationFn: func(login, password, role string) (*user.User, error) {
+		RegistrationFn: func(login, password, inviteToken string) (*user.User, error) {
+			if inviteToken != "invite" {
+				t.Fatalf("unexpected invite token %q", inviteToken)
+			}
 			return &user.User{
 				Id:    uuid.New(),
 				Login: login,
-				Role:  user.Role(role),
+				Role:  user.Viewer,
 			}, nil
 		},
 	}
 	h := handlers.NewUserHandler(mockService)
 
 	req := dto.UserRegistrationRequest{
-		Login:    "testuser",
-		Password: "Password123",
-		Role:     "viewer",
+		Login:       "testuser",
+		Password:    "Password123",
+		InviteToken: "invite",
 	}
 
 	w := performRequestUser(h.RegisterUser, "POST", "/register", req)
@@ -81,16 +289,16 @@ func TestUserHandler_RegisterUser_Success(t *testing.T) {
 
 func TestUserHandler_RegisterUser_ServiceError(t *testing.T) {
 	mockService := &MockUserService{
-		RegistrationFn: func(login, password, role string) (*user.User, error) {
+		RegistrationFn: func(login, password, inviteToken string) (*user.User, error) {
 			return nil, errors.New("service error")
 		},
 	}
 	h := handlers.NewUserHandler(mockService)
 
 	req := dto.UserRegistrationRequest{
-		Login:    "testuser",
-		Password: "Password123",
-		Role:     "viewer",
+		Login:       "testuser",
+		Password:    "Password123",
+		InviteToken: "invite",
 	}
 
 	w := performRequestUser(h.RegisterUser, "POST", "/register", req)
@@ -109,11 +317,11 @@ func TestUserHandler_RegisterUser_InvalidJSON(t *testing.T) {
 
 func TestUserHandler_LoginUser_Success(t *testing.T) {
 	mockService := &MockUserService{
-		LoginFn: func(login, password string) (*auth.JWTResponse, error) {
-			return &auth.JWTResponse{
+		LoginFn: func(login, password string, client user.Client) (*auth.LoginResponse, error) {
+			return &auth.LoginResponse{JWTResponse: &auth.JWTResponse{
 				AccessToken:  "access123",
 				RefreshToken: "refresh123",
-			}, nil
+			}}, nil
 		},
 	}
 	h := handlers.NewUserHandler(mockService)
@@ -131,7 +339,7 @@ func TestUserHandler_LoginUser_Success(t *testing.T) {
 
 func TestUserHandler_LoginUser_Unauthorized(t *testing.T) {
 	mockService := &MockUserService{
-		LoginFn: func(login, password string) (*auth.JWTResponse, error) {
+		LoginFn: func(login, password string, client user.Client) (*auth.LoginResponse, error) {
 			return nil, errors.New("invalid credentials")
 		},
 	}
@@ -148,6 +356,40 @@ func TestUserHandler_LoginUser_Unauthorized(t *testing.T) {
 	}
 }
 
+func TestUserHandler_LoginUser_DatabaseTimeout(t *testing.T) {
+	mockService := &MockUserService{
+		LoginFn: func(login, password string, client user.Client) (*auth.LoginResponse, error) {
+			return nil, context.DeadlineExceeded
+		},
+	}
+	h := handlers.NewUserHandler(mockService)
+
+	// база не ответила вовремя — это не неверный пароль
+	w := performRequestUser(h.LoginUser, "POST", "/login", dto.UserLoginRequest{Login: "testuser", Password: "Password1"})
+	if w.Code != http.StatusServiceUnavailable {
+		t.Fatalf("expected 503, got %d", w.Code)
+	}
+}
+
+func TestUserHandler_LoginUser_Locked(t *testing.T) {
+	mockService := &MockUserService{
+		LoginFn: func(login, password string, client user.Client) (*auth.LoginResponse, error) {
+			return nil, user.ErrTooManyAttempts
+		},
+	}
+	h := handlers.NewUserHandler(mockService)
+
+	w := performRequestUser(h.LoginUser, "POST", "/login", dto.UserLoginRequest{Login: "testuser", Password: "Password1"})
+	if w.Code != http.StatusTooManyRequests {
+		t.Fatalf("expected 429, got %d", w.Code)
+	}
+	var res dto.ErrorResponse
+	_ = json.Unmarshal(w.Body.Bytes(), &res)
+	if res.Code != handlers.CodeTooManyRequests {
+		t.Fatalf("unexpected error code %q", res.Code)
+	}
+}
+
 func TestUserHandler_LoginUser_InvalidJSON(t *testing.T) {
 	h := handlers.NewUserHandler(&MockUserService{})
 	w := performRequestUser(h.LoginUser, "POST", "/login", "{bad json")
@@ -202,3 +444,169 @@ func TestUserHandler_RefreshToken_InvalidJSON(t *testing.T) {
 		t.Fatalf("expected 400, got %d", w.Code)
 	}
 }
+
+func TestUserHandler_Bootstrap(t *testing.T) {
+	mockService := &MockUserService{
+		BootstrapFn: func(setupToken, login, password string) (*user.User, error) {
+			if setupToken != "secret" {
+				return nil, errs.New(errs.ErrUnauthorized, "invalid setup token")
+			}
+			return &user.User{Id: uuid.New(), Login: login, Role: user.Admin}, nil
+		},
+	}
+	h := handlers.NewUserHandler(mockService)
+
+	w := performRequestUser(h.Bootstrap, "POST", "/bootstrap", dto.BootstrapRequest{SetupToken: "secret", Login: "admin", Password: "Password1"})
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d", w.Code)
+	}
+	var res dto.UserResponse
+	_ = json.Unmarshal(w.Body.Bytes(), &res)
+	if res.Role != "admin" {
+		t.Fatalf("expected admin role, got %q", res.Role)
+	}
+
+	w = performRequestUser(h.Bootstrap, "POST", "/bootstrap", dto.BootstrapRequest{SetupToken: "wrong", Login: "admin", Password: "Password1"})
+	if w.Code != http.StatusUnauthorized {
+		t.Fatalf("expected 401, got %d", w.Code)
+	}
+
+	w = performRequestUser(h.Bootstrap, "POST", "/bootstrap", map[string]string{"login": "admin"})
+	if w.Code != http.StatusBadRequest {
+		t.Fatalf("expected 400, got %d", w.Code)
+	}
+}
+
+func TestUserHandler_CreateInvitation(t *testing.T) {
+	var gotTTL time.Duration
+	var gotCreator string
+	mockService := &MockUserService{
+		CreateInvitationFn: func(role string, ttl time.Duration, createdBy string) (*user.Invitation, string, error) {
+			if role == "root" {
+				return nil, "", errs.Errorf(errs.ErrValidation, "unknown role: %s", role)
+			}
+			gotTTL, gotCreator = ttl, createdBy
+			now := time.Now()
+			return &user.Invitation{
+				ID:        uuid.New(),
+				Role:      user.Role(role),
+				CreatedBy: uuid.New(),
+				CreatedAt: now,
+				ExpiresAt: now.Add(ttl),
+			}, "plain-token", nil
+		},
+	}
+	h := handlers.NewUserHandler(mockService)
+
+	body := dto.InvitationCreateRequest{Role: "manager", TTLHours: 2}
+	w := performJSON(h.CreateInvitation, http.MethodPost, "/api/invitations", body, func(c *wbgin.Context) {
+		c.Set("userId", "admin-id")
+		c.Set("login", "admin")
+	})
+	if w.Code != http.StatusCreated {
+		t.Fatalf("expected 201, got %d: %s", w.Code, w.Body.String())
+	}
+	var res dto.InvitationResponse
+	_ = json.Unmarshal(w.Body.Bytes(), &res)
+	if res.Token != "plain-token" || res.Role != "manager" {
+		t.Fatalf("unexpected response: %+v", res)
+	}
+	if gotTTL != 2*time.Hour || gotCreator != "admin-id" {
+		t.Fatalf("unexpected service args: ttl=%s creator=%s", gotTTL, gotCreator)
+	}
+
+	w = performJSON(h.CreateInvitation, http.MethodPost, "/api/invitations", map[string]any{"role": "root"}, func(c *wbgin.Context) {
+		c.Set("userId", "admin-id")
+		c.Set("login", "admin")
+	})
+	if w.Code != http.StatusUnprocessableEntity {
+		t.Fatalf("expected 422 for unknown role, got %d", w.Code)
+	}
+	w = performJSON(h.CreateInvitation, http.MethodPost, "/api/invitations", map[string]any{}, nil)
+	if w.Code != http.StatusBadRequest {
+		t.Fatalf("expected 400 for missing role, got %d", w.Code)
+	}
+}
+
+func TestUserHandler_GetInvitations_HidesToken(t *testing.T) {
+	usedBy := uuid.New()
+	usedAt := time.Now()
+	mockService := &MockUserService{
+		GetInvitationsFn: func() ([]*user.Invitation, error) {
+			return []*user.Invitation{{
+				ID:        uuid.New(),
+				Role:      user.Viewer,
+				TokenHash: []byte("hash"),
+				CreatedBy: uuid.New(),
+				UsedAt:    &usedAt,
+				UsedBy:    &usedBy,
+			}}, nil
+		},
+	}
+	h := handlers.NewUserHandler(mockService)
+
+	w := performJSON(h.GetInvitations, http.MethodGet, "/api/invitations", nil, nil)
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d", w.Code)
+	}
+	var res []dto.InvitationResponse
+	_ = json.Unmarshal(w.Body.Bytes(), &res)
+	if len(res) != 1 || res[0].Token != "" || res[0].UsedBy != usedBy.String() {
+		t.Fatalf("unexpected response: %s", w.Body.String())
+	}
+}
+
+func TestUserHandler_RevokeInvitation_Used(t *testing.T) {
+	mockService := &MockUserService{
+		RevokeInvitationFn: func(id string) error {
+			return user.ErrInvitationUsed
+		},
+	}
+	h := handlers.NewUserHandler(mockService)
+
+	w := performJSON(h.RevokeInvitation, http.MethodDelete, "/api/invitations/x", nil, nil)
+	if w.Code != http.StatusConflict {
+		t.Fatalf("expected 409, got %d", w.Code)
+	}
+}
+
+func TestUserHandler_Logout(t *testing.T) {
+	var gotAll bool
+	mockService := &MockUserService{
+		LogoutFn: func(refreshToken string, all bool) error {
+			if refreshToken != "refresh" {
+				return user.ErrRefreshTokenInvalid
+			}
+			gotAll = all
+			return nil
+		},
+	}
+	h := handlers.NewUserHandler(mockService)
+
+	w := performRequestUser(h.Logout, "POST", "/logout", dto.LogoutRequest{RefreshToken: "refresh", All: true})
+	if w.Code != http.StatusOK || !gotAll {
+		t.Fatalf("expected 200 with all=true, got %d", w.Code)
+	}
+	w = performRequestUser(h.Logout, "POST", "/logout", dto.LogoutRequest{RefreshToken: "bad"})
+	if w.Code != http.StatusUnauthorized {
+		t.Fatalf("expected 401, got %d", w.Code)
+	}
+	w = performRequestUser(h.Logout, "POST", "/logout", map[string]string{})
+	if w.Code != http.StatusBadRequest {
+		t.Fatalf("expected 400, got %d", w.Code)
+	}
+}
+
+func TestUserHandler_RefreshToken_Reused(t *testing.T) {
+	mockService := &MockUserService{
+		RefreshTokensFn: func(tokenStr string) (*auth.JWTResponse, error) {
+			return nil, user.ErrRefreshTokenReused
+		},
+	}
+	h := handlers.NewUserHandler(mockService)
+
+	w := performRequestUser(h.RefreshToken, "POST", "/refresh", dto.TokenRefreshRequest{RefreshToken: "used"})
+	if w.Code != http.StatusUnauthorized {
+		t.Fatalf("expected 401, got %d", w.Code)
+	}
+}
@@ -0,0 +1,321 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+
+	"warehousecontrol/internal/domain/warehouse"
+	"warehousecontrol/internal/web/dto"
+
+	wbgin "github.com/wb-go/wbf/ginext"
+)
+
+type WarehouseHandler struct {
+	Service WarehouseIFace
+}
+
+type WarehouseIFace interface {
+	Create(ctx context.Context, name, address string) (*warehouse.Warehouse, error)
+	GetWarehouses(ctx context.Context) ([]*warehouse.Warehouse, error)
+	GetWarehouse(ctx context.Context, id string) (*warehouse.Warehouse, error)
+	PutWarehouse(ctx context.Context, id, name, address string) (*warehouse.Warehouse, error)
+	DeleteWarehouse(ctx context.Context, id string) error
+	CreateZone(ctx context.Context, warehouseID, name string) (*warehouse.Zone, error)
+	PutZone(ctx context.Context, warehouseID, zoneID, name string) (*warehouse.Zone, error)
+	DeleteZone(ctx context.Context, warehouseID, zoneID string) error
+	CreateLocation(ctx context.Context, warehouseID, zoneID, code string) (*warehouse.Location, error)
+	PutLocation(ctx context.Context, warehouseID, zoneID, locationID, code string) (*warehouse.Location, error)
+	DeleteLocation(ctx context.Context, warehouseID, zoneID, locationID string) error
+}
+
+func NewWarehouseHandler(service WarehouseIFace) *WarehouseHandler {
+	return &WarehouseHandler{
+		Service: service,
+	}
+}
+
+// CreateWarehouse
+// @Summary Create warehouse
+// @Description Create a new warehouse (warehouses.manage)
+// @Tags warehouses
+// @Accept json
+// @Produce json
+// @Param body body dto.WarehouseRequest true "Warehouse payload"
+// @Success 200 {object} warehouse.Warehouse
+// @Failure 400 {object} dto.ErrorResponse
+// @Failure 409 {object} dto.ErrorResponse
+// @Failure 422 {object} dto.ErrorResponse
+// @Failure 500 {object} dto.ErrorResponse
+// @Security BearerAuth
+// @Router /api/warehouses [post]
+func (h *WarehouseHandler) CreateWarehouse(ctx *wbgin.Context) {
+	var req dto.WarehouseRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		badRequest(ctx, err)
+		return
+	}
+	w, err := h.Service.Create(ctx.Request.Context(), req.Name, req.Address)
+	if err != nil {
+		RespondError(ctx, err)
+		return
+	}
+	ctx.JSON(http.StatusOK, w)
+}
+
+// GetWarehouses
+// @Summary List warehouses
+// @Description Get list of warehouses
+// @Tags warehouses
+// @Produce json
+// @Success 200 {array} warehouse.Warehouse
+// @Failure 500 {object} dto.ErrorResponse
+// @Security BearerAuth
+// @Router /api/warehouses [get]
+func (h *WarehouseHandler) GetWarehouses(ctx *wbgin.Context) {
+	warehouses, err := h.Service.GetWarehouses(ctx.Request.Context())
+	if err != nil {
+		RespondError(ctx, err)
+		return
+	}
+	ctx.JSON(http.StatusOK, warehouses)
+}
+
+// GetWarehouse
+// @Summary Get warehouse
+// @Description Get a warehouse with its zones and bin locations
+// @Tags warehouses
+// @Produce json
+// @Param id path string true "Warehouse UUID"
+// @Success 200 {object} warehouse.Warehouse
+// @Failure 404 {object} dto.ErrorResponse
+// @Failure 500 {object} dto.ErrorResponse
+// @Security BearerAuth
+// @Router /api/warehouses/{id} [get]
+func (h *WarehouseHandler) GetWarehouse(ctx *wbgin.Context) {
+	w, err := h.Service.GetWarehouse(ctx.Request.Context(), ctx.Param("id"))
+	if err != nil {
+		RespondError(ctx, err)
+		return
+	}
+	ctx.JSON(http.StatusOK, w)
+}
+
+// PutWarehouse
+// @Summary Update warehouse
+// @Description Update warehouse name and address (warehouses.manage)
+// @Tags warehouses
+// @Accept json
+// @Produce json
+// @Param id path string true "Warehouse UUID"
+// @Param body body dto.WarehouseRequest true "Updated fields"
+// @Success 200 {object} warehouse.Warehouse
+// @Failure 400 {object} dto.ErrorResponse
+// @Failure 404 {object} dto.ErrorResponse
+// @Failure 409 {object} dto.ErrorResponse
+// @Failure 422 {object} dto.ErrorResponse
+// @Failure 500 {object} dto.ErrorResponse
+// @Security BearerAuth
+// @Router /api/warehouses/{id} [put]
+func (h *WarehouseHandler) PutWarehouse(ctx *wbgin.Context) {
+	var req dto.WarehouseRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		badRequest(ctx, err)
+		return
+	}
+	w, err := h.Service.PutWarehouse(ctx.Request.Context(), ctx.Param("id"), req.Name, req.Address)
+	if err != nil {
+		RespondError(ctx, err)
+		return
+	}
+	ctx.JSON(http.StatusOK, w)
+}
+
+// DeleteWarehouse
+// @Summary Delete warehouse
+// @Description Delete warehouse with its zones and locations (warehouses.manage)
+// @Tags warehouses
+// @Produce json
+// @Param id path string true "Warehouse UUID"
+// @Success 200 {object} map[string]string
+// @Failure 404 {object} dto.ErrorResponse
+// @Failure 409 {object} dto.ErrorResponse
+// @Failure 500 {object} dto.ErrorResponse
+// @Security BearerAuth
+// @Router /api/warehouses/{id} [delete]
+func (h *WarehouseHandler) DeleteWarehouse(ctx *wbgin.Context) {
+	err := h.Service.DeleteWarehouse(ctx.Request.Context(), ctx.Param("id"))
+	if err != nil {
+		RespondError(ctx, err)
+		return
+	}
+	ctx.JSON(http.StatusOK, wbgin.H{"message": "warehouse deleted"})
+}
+
+// CreateZone
+// @Summary Create zone
+// @Description Create a zone inside a warehouse (warehouses.manage)
+// @Tags warehouses
+// @Accept json
+// @Produce json
+// @Param id path string true "Warehouse UUID"
+// @Param body body dto.ZoneRequest true "Zone payload"
+// @Success 200 {object} warehouse.Zone
+// @Failure 400 {object} dto.ErrorResponse
+// @Failure 404 {object} dto.ErrorResponse
+// @Failure 409 {object} dto.ErrorResponse
+// @Failure 422 {object} dto.ErrorResponse
+// @Failure 500 {object} dto.ErrorResponse
+// @Security BearerAuth
+// @Router /api/warehouses/{id}/zones [post]
+func (h *WarehouseHandler) CreateZone(ctx *wbgin.Context) {
+	var req dto.ZoneRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		badRequest(ctx, err)
+		return
+	}
+	z, err := h.Service.CreateZone(ctx.Request.Context(), ctx.Param("id"), req.Name)
+	if err != nil {
+		RespondError(ctx, err)
+		return
+	}
+	ctx.JSON(http.StatusOK, z)
+}
+
+// PutZone
+// @Summary Update zone
+// @Description Rename a warehouse zone (warehouses.manage)
+// @Tags warehouses
+// @Accept json
+// @Produce json
+// @Param id path string true "Warehouse UUID"
+// @Param zone_id path string true "Zone UUID"
+// @Param body body dto.ZoneRequest true "Updated fields"
+// @Success 200 {object} warehouse.Zone
+// @Failure 400 {object} dto.ErrorResponse
+// @Failure 404 {object} dto.ErrorResponse
+// @Failure 409 {object} dto.ErrorResponse
+// @Failure 422 {object} dto.ErrorResponse
+// @Failure 500 {object} dto.ErrorResponse
+// @Security BearerAuth
+// @Router /api/warehouses/{id}/zones/{zone_id} [put]
+func (h *WarehouseHandler) PutZone(ctx *wbgin.Context) {
+	var req dto.ZoneRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		badRequest(ctx, err)
+		return
+	}
+	z, err := h.Service.PutZone(ctx.Request.Context(), ctx.Param("id"), ctx.Param("zone_id"), req.Name)
+	if err != nil {
+		RespondError(ctx, err)
+		return
+	}
+	ctx.JSON(http.StatusOK, z)
+}
+
+// DeleteZone
+// @Summary Delete zone
+// @Description Delete a warehouse zone with its locations (warehouses.manage)
+// @Tags warehouses
+// @Produce json
+// @Param id path string true "Warehouse UUID"
+// @Param zone_id path string true "Zone UUID"
+// @Success 200 {object} map[string]string
+// @Failure 404 {object} dto.ErrorResponse
+// @Failure 409 {object} dto.ErrorResponse
+// @Failure 500 {object} dto.ErrorResponse
+// @Security BearerAuth
+// @Router /api/warehouses/{id}/zones/{zone_id} [delete]
+func (h *WarehouseHandler) DeleteZone(ctx *wbgin.Context) {
+	err := h.Service.DeleteZone(ctx.Request.Context(), ctx.Param("id"), ctx.Param("zone_id"))
+	if err != nil {
+		RespondError(ctx, err)
+		return
+	}
+	ctx.JSON(http.StatusOK, wbgin.H{"message": "zone deleted"})
+}
+
+// CreateLocation
+// @Summary Create bin location
+// @Description Create a bin location inside a zone (warehouses.manage)
+// @Tags warehouses
+// @Accept json
+// @Produce json
+// @Param id path string true "Warehouse UUID"
+// @Param zone_id path string true "Zone UUID"
+// @Param body body dto.LocationRequest true "Location payload"
+// @Success 200 {object} warehouse.Location
+// @Failure 400 {object} dto.ErrorResponse
+// @Failure 404 {object} dto.ErrorResponse
+// @Failure 409 {object} dto.ErrorResponse
+// @Failure 422 {object} dto.ErrorResponse
+// @Failure 500 {object} dto.ErrorResponse
+// @Security BearerAuth
+// @Router /api/warehouses/{id}/zones/{zone_id}/locations [post]
+func (h *WarehouseHandler) CreateLocation(ctx *wbgin.Context) {
+	var req dto.LocationRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		badRequest(ctx, err)
+		return
+	}
+	l, err := h.Service.CreateLocation(ctx.Request.Context(), ctx.Param("id"), ctx.Param("zone_id"), req.Code)
+	if err != nil {
+		RespondError(ctx, err)
+		return
+	}
+	ctx.JSON(http.StatusOK, l)
+}
+
+// PutLocation
+// @Summary Update bin location
+// @Description Change bin location code (warehouses.manage)
+// @Tags warehouses
+// @Accept json
+// @Produce json
+// @Param id path string true "Warehouse UUID"
+// @Param zone_id path string true "Zone UUID"
+// @Param location_id path string true "Location UUID"
+// @Param body body dto.LocationRequest true "Updated fields"
+// @Success 200 {object} warehouse.Location
+// @Failure 400 {object} dto.ErrorResponse
+// @Failure 404 {object} dto.ErrorResponse
+// @Failure 409 {object} dto.ErrorResponse
+// @Failure 422 {object} dto.ErrorResponse
+// @Failure 500 {object} dto.ErrorResponse
+// @Security BearerAuth
+// @Router /api/warehouses/{id}/zones/{zone_id}/locations/{location_id} [put]
+func (h *WarehouseHandler) PutLocation(ctx *wbgin.Context) {
+	var req dto.LocationRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		badRequest(ctx, err)
+		return
+	}
+	l, err := h.Service.PutLocation(ctx.Request.Context(), ctx.Param("id"), ctx.Param("zone_id"), ctx.Param("location_id"), req.Code)
+	if err != nil {
+		RespondError(ctx, err)
+		return
+	}
+	ctx.JSON(http.StatusOK, l)
+}
+
+// DeleteLocation
+// @Summary Delete bin location
+// @Description Delete an empty bin location (warehouses.manage)
+// @Tags warehouses
+// @Produce json
+// @Param id path string true "Warehouse UUID"
+// @Param zone_id path string true "Zone UUID"
+// @Param location_id path string true "Location UUID"
+// @Success 200 {object} map[string]string
+// @Failure 404 {object} dto.ErrorResponse
+// @Failure 409 {object} dto.ErrorResponse
+// @Failure 500 {object} dto.ErrorResponse
+// @Security BearerAuth
+// @Router /api/warehouses/{id}/zones/{zone_id}/locations/{location_id} [delete]
+func (h *WarehouseHandler) DeleteLocation(ctx *wbgin.Context) {
+	err := h.Service.DeleteLocation(ctx.Request.Context(), ctx.Param("id"), ctx.Param("zone_id"), ctx.Param("location_id"))
+	if err != nil {
+		RespondError(ctx, err)
+		return
+	}
+	ctx.JSON(http.StatusOK, wbgin.H{"message": "location deleted"})
+}
@@ -0,0 +1,154 @@
+package handlers_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+
+	"github.com/google/uuid"
+	wbgin "github.com/wb-go/wbf/ginext"
+
+	dwh "warehousecontrol/internal/domain/warehouse"
+	"warehousecontrol/internal/web/handlers"
+)
+
+type MockWarehouseService struct {
+	CreateFn          func(name, address string) (*dwh.Warehouse, error)
+	GetWarehousesFn   func() ([]*dwh.Warehouse, error)
+	GetWarehouseFn    func(id string) (*dwh.Warehouse, error)
+	PutWarehouseFn    func(id, name, address string) (*dwh.Warehouse, error)
+	DeleteWarehouseFn func(id string) error
+	CreateZoneFn      func(warehouseID, name string) (*dwh.Zone, error)
+	PutZoneFn         func(warehouseID, zoneID, name string) (*dwh.Zone, error)
+	DeleteZoneFn      func(warehouseID, zoneID string) error
+	CreateLocationFn  func(warehouseID, zoneID, code string) (*dwh.Location, error)
+	PutLocationFn     func(warehouseID, zoneID, locationID, code string) (*dwh.Location, error)
+	DeleteLocationFn  func(warehouseID, zoneID, locationID string) error
+}
+
+func (m *MockWarehouseService) Create(_ context.Context, name, address string) (*dwh.Warehouse, error) {
+	return m.CreateFn(name, address)
+}
+func (m *MockWarehouseService) GetWarehouses(_ context.Context) ([]*dwh.Warehouse, error) {
+	return m.GetWarehousesFn()
+}
+func (m *MockWarehouseService) GetWarehouse(_ context.Context, id string) (*dwh.Warehouse, error) {
+	return m.GetWarehouseFn(id)
+}
+func (m *MockWarehouseService) PutWarehouse(_ context.Context, id, name, address string) (*dwh.Warehouse, error) {
+	return m.PutWarehouseFn(id, name, address)
+}
+func (m *MockWarehouseService) DeleteWarehouse(_ context.Context, id string) error {
+	return m.DeleteWarehouseFn(id)
+}
+func (m *MockWarehouseService) CreateZone(_ context.Context, warehouseID, name string) (*dwh.Zone, error) {
+	return m.CreateZoneFn(warehouseID, name)
+}
+func (m *MockWarehouseService) PutZone(_ context.Context, warehouseID, zoneID, name string) (*dwh.Zone, error) {
+	return m.PutZoneFn(warehouseID, zoneID, name)
+}
+func (m *MockWarehouseService) DeleteZone(_ context.Context, warehouseID, zoneID string) error {
+	return m.DeleteZoneFn(warehouseID, zoneID)
+}
+func (m *MockWarehouseService) CreateLocation(_ context.Context, warehouseID, zoneID, code string) (*dwh.Location, error) {
+	return m.CreateLocationFn(warehouseID, zoneID, code)
+}
+func (m *MockWarehouseService) PutLocation(_ context.Context, warehouseID, zoneID, locationID, code string) (*dwh.Location, error) {
+	return m.PutLocationFn(warehouseID, zoneID, locationID, code)
+}
+func (m *MockWarehouseService) DeleteLocation(_ context.Context, warehouseID, zoneID, locationID string) error {
+	return m.DeleteLocationFn(warehouseID, zoneID, locationID)
+}
+
+func TestWarehouseHandler_CreateWarehouse_Success(t *testing.T) {
+	mock := &MockWarehouseService{CreateFn: func(name, address string) (*dwh.Warehouse, error) {
+		return &dwh.Warehouse{ID: uuid.New(), Name: name, Address: address}, nil
+	}}
+	h := handlers.NewWarehouseHandler(mock)
+	body := map[string]any{"name": "Main", "address": "Moscow"}
+	rr := performJSON(h.CreateWarehouse, http.MethodPost, "/api/warehouses", body, nil)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d", rr.Code)
+	}
+}
+
+func TestWarehouseHandler_CreateWarehouse_InvalidJSON(t *testing.T) {
+	h := handlers.NewWarehouseHandler(&MockWarehouseService{})
+	rr := performJSON(h.CreateWarehouse, http.MethodPost, "/api/warehouses", map[string]any{"address": "x"}, nil)
+	if rr.Code != http.StatusBadRequest {
+		t.Fatalf("expected 400, got %d", rr.Code)
+	}
+}
+
+func TestWarehouseHandler_GetWarehouses_ServiceError(t *testing.T) {
+	mock := &MockWarehouseService{GetWarehousesFn: func() ([]*dwh.Warehouse, error) { return nil, errors.New("svc err") }}
+	h := handlers.NewWarehouseHandler(mock)
+	rr := performJSON(h.GetWarehouses, http.MethodGet, "/api/warehouses", nil, nil)
+	if rr.Code != http.StatusInternalServerError {
+		t.Fatalf("expected 500, got %d", rr.Code)
+	}
+}
+
+func TestWarehouseHandler_GetWarehouse_Success(t *testing.T) {
+	mock := &MockWarehouseService{GetWarehouseFn: func(id string) (*dwh.Warehouse, error) {
+		return &dwh.Warehouse{Name: "Main"}, nil
+	}}
+	h := handlers.NewWarehouseHandler(mock)
+	rr := performJSON(h.GetWarehouse, http.MethodGet, "/api/warehouses/1", nil, func(c *wbgin.Context) { c.AddParam("id", "1") })
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d", rr.Code)
+	}
+}
+
+func TestWarehouseHandler_CreateZone_PassesParams(t *testing.T) {
+	var gotWarehouse string
+	mock := &MockWarehouseService{CreateZoneFn: func(warehouseID, name string) (*dwh.Zone, error) {
+		gotWarehouse = warehouseID
+		return &dwh.Zone{Name: name}, nil
+	}}
+	h := handlers.NewWarehouseHandler(mock)
+	rr := performJSON(h.CreateZone, http.MethodPost, "/api/warehouses/w1/zones", map[string]any{"name": "A"}, func(c *wbgin.Context) { c.AddParam("id", "w1") })
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d", rr.Code)
+	}
+	if gotWarehouse != "w1" {
+		t.Fatalf("expected warehouse id w1, got %s", gotWarehouse)
+	}
+}
+
+func TestWarehouseHandler_CreateLocation_ServiceError(t *testing.T) {
+	mock := &MockWarehouseService{CreateLocationFn: func(warehouseID, zoneID, code string) (*dwh.Location, error) {
+		return nil, errors.New("svc err")
+	}}
+	h := handlers.NewWarehouseHandler(mock)
+	rr := performJSON(h.CreateLocation, http.MethodPost, "/api/warehouses/w1/zones/z1/locations", map[string]any{"code": "A-01"}, func(c *wbgin.Context) {
+		c.AddParam("id", "w1")
+		c.AddParam("zone_id", "z1")
+	})
+	if rr.Code != http.StatusInternalServerError {
+		t.Fatalf("expected 500, got %d", rr.Code)
+	}
+}
+
+func TestWarehouseHandler_DeleteLocation_Success(t *testing.T) {
+	mock := &MockWarehouseService{DeleteLocationFn: func(warehouseID, zoneID, locationID string) error { return nil }}
+	h := handlers.NewWarehouseHandler(mock)
+	rr := performJSON(h.DeleteLocation, http.MethodDelete, "/api/warehouses/w1/zones/z1/locations/l1", nil, func(c *wbgin.Context) {
+		c.AddParam("id", "w1")
+		c.AddParam("zone_id", "z1")
+		c.AddParam("location_id", "l1")
+	})
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d", rr.Code)
+	}
+}
+
+func TestWarehouseHandler_DeleteWarehouse_NotFound(t *testing.T) {
+	mock := &MockWarehouseService{DeleteWarehouseFn: func(id string) error { return dwh.ErrWarehouseNotFound }}
+	h := handlers.NewWarehouseHandler(mock)
+	rr := performJSON(h.DeleteWarehouse, http.MethodDelete, "/api/warehouses/w1", nil, func(c *wbgin.Context) { c.AddParam("id", "w1") })
+	if rr.Code != http.StatusNotFound {
+		t.Fatalf("expected 404, got %d", rr.Code)
+	}
+}
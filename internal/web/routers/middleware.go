@@ -1,8 +1,12 @@
 package routers
 
 import (
+	"errors"
+	"net/http"
 	"strings"
+	"time"
 
+	"warehousecontrol/internal/domain/errs"
 	"warehousecontrol/internal/domain/user"
 	"warehousecontrol/internal/web/handlers"
 
@@ -10,62 +14,157 @@ import (
 )
 
 const (
-	CtxUserID = "userId"
-	CtxRole   = "role"
-	CtxLogin  = "login"
+	CtxUserID    = "userId"
+	CtxRole      = "role"
+	CtxLogin     = "login"
+	CtxSessionID = "sessionId"
+	CtxAPIKeyID  = "apiKeyId"
+	// CtxPermissions — права роли пользователя (user.Permissions), их проверяет RequirePermission
+	CtxPermissions = "permissions"
+)
+
+const (
+	// WriteMarkHeader — метка последней записи клиента (user.WriteMark) для клиентов без cookie
+	WriteMarkHeader = "X-Write-Mark"
+	writeMarkCookie = "write_mark"
 )
 
 func AuthMiddleware(userService handlers.UserIFace) wbgin.HandlerFunc {
 	return func(c *wbgin.Context) {
 		token := c.GetHeader("Authorization")
 		if token == "" {
-			c.AbortWithStatusJSON(401, wbgin.H{"error": "missing token"})
+			handlers.RespondError(c, errs.New(errs.ErrUnauthorized, "missing token"))
 			return
 		}
 
 		token = strings.TrimPrefix(token, "Bearer ")
 
 		if token == "" {
-			c.AbortWithStatusJSON(401, wbgin.H{"error": "empty token"})
+			handlers.RespondError(c, errs.New(errs.ErrUnauthorized, "empty token"))
+			return
+		}
+
+		if user.IsAPIKey(token) {
+			authenticateAPIKey(c, userService, token)
 			return
 		}
 
-		payload, err := userService.ValidateTokens(token)
+		payload, err := userService.ValidateTokens(c.Request.Context(), token)
+		if errs.Kind(err) == errs.ErrUnauthorized || handlers.IsCanceled(err) {
+			// например, сессия токена отозвана или база не ответила вовремя
+			handlers.RespondError(c, err)
+			return
+		}
 		if err != nil {
-			c.AbortWithStatusJSON(401, wbgin.H{"error": "invalid token"})
+			handlers.RespondError(c, errs.New(errs.ErrUnauthorized, "invalid token"))
 			return
 		}
 
 		c.Set(CtxUserID, payload.UserID)
 		c.Set(CtxRole, payload.Role)
 		c.Set(CtxLogin, payload.Login)
+		c.Set(CtxSessionID, payload.SessionID)
+		c.Set(CtxPermissions, payload.Permissions)
 
 		c.Next()
 	}
 }
 
-func RequireRoles(roles ...user.Role) wbgin.HandlerFunc {
-	return func(c *wbgin.Context) {
+// authenticateAPIKey пропускает запрос с ключом сервисной учётной записи, если области ключа
+// покрывают ресурс: первый сегмент пути после /api и чтение (GET, HEAD) или запись.
+// Маршрутам без своей области, например управлению пользователями, ключи не подходят.
+func authenticateAPIKey(c *wbgin.Context, userService handlers.UserIFace, token string) {
+	key, u, err := userService.AuthenticateAPIKey(c.Request.Context(), token)
+	if err != nil {
+		if errs.Kind(err) != errs.ErrUnauthorized && errs.Kind(err) != errs.ErrForbidden && !handlers.IsCanceled(err) {
+			err = errs.New(errs.ErrUnauthorized, "invalid api key")
+		}
+		handlers.RespondError(c, err)
+		return
+	}
+
+	resource, _, _ := strings.Cut(strings.TrimPrefix(c.FullPath(), "/api/"), "/")
+	write := c.Request.Method != http.MethodGet && c.Request.Method != http.MethodHead
+	if !key.Allows(resource, write) {
+		handlers.RespondError(c, user.ErrAPIKeyScope)
+		return
+	}
+	perms, err := userService.RolePermissions(c.Request.Context(), u.Role)
+	if err != nil {
+		if !handlers.IsCanceled(err) {
+			err = errs.New(errs.ErrUnauthorized, "invalid api key")
+		}
+		handlers.RespondError(c, err)
+		return
+	}
+
+	c.Set(CtxUserID, u.Id.String())
+	c.Set(CtxRole, u.Role)
+	c.Set(CtxLogin, u.Login)
+	c.Set(CtxAPIKeyID, key.ID.String())
+	c.Set(CtxPermissions, perms)
 
-		strRole, exists := c.Get(CtxRole)
+	c.Next()
+}
+
+// RequirePermission пропускает запрос, только если роль пользователя даёт право perm
+func RequirePermission(perm user.Permission) wbgin.HandlerFunc {
+	return func(c *wbgin.Context) {
+		v, exists := c.Get(CtxPermissions)
 		if !exists {
-			c.AbortWithStatusJSON(500, wbgin.H{"error": "role not found in context"})
+			handlers.RespondError(c, errors.New("permissions not found in context"))
 			return
 		}
 
-		role, ok := strRole.(user.Role)
+		perms, ok := v.(user.Permissions)
 		if !ok {
-			c.AbortWithStatusJSON(500, wbgin.H{"error": "invalid role type in context"})
+			handlers.RespondError(c, errors.New("invalid permissions type in context"))
 			return
 		}
 
-		for _, r := range roles {
-			if r == role {
-				c.Next()
-				return
-			}
+		if !perms.Has(perm) {
+			handlers.RespondError(c, errs.Errorf(errs.ErrForbidden, "forbidden: %s permission required", perm))
+			return
 		}
+		c.Next()
+	}
+}
 
-		c.AbortWithStatusJSON(403, wbgin.H{"error": "forbidden"})
+// ReadYourWrites передаёт репозиториям метку последней записи клиента из заголовка
+// X-Write-Mark или cookie и возвращает новую метку в ответе на запись. Cookie живёт window:
+// за это время реплики успевают догнать мастер.
+func ReadYourWrites(window time.Duration) wbgin.HandlerFunc {
+	return func(c *wbgin.Context) {
+		mark := &user.WriteMark{Seen: c.GetHeader(WriteMarkHeader)}
+		if mark.Seen == "" {
+			mark.Seen, _ = c.Cookie(writeMarkCookie)
+		}
+		mark.Wrote = func(lsn string) {
+			c.Header(WriteMarkHeader, lsn)
+			setWriteMarkCookie(c, lsn, window)
+		}
+		c.Request = c.Request.WithContext(user.WithWriteMark(c.Request.Context(), mark))
+		c.Next()
+	}
+}
+
+// setWriteMarkCookie заменяет метку, выставленную предыдущей записью того же запроса
+func setWriteMarkCookie(c *wbgin.Context, lsn string, window time.Duration) {
+	h := c.Writer.Header()
+	cookies := h.Values("Set-Cookie")
+	h.Del("Set-Cookie")
+	for _, v := range cookies {
+		if !strings.HasPrefix(v, writeMarkCookie+"=") {
+			h.Add("Set-Cookie", v)
+		}
 	}
+	http.SetCookie(c.Writer, &http.Cookie{
+		Name:     writeMarkCookie,
+		Value:    lsn,
+		Path:     "/api",
+		MaxAge:   int(window.Seconds()),
+		HttpOnly: true,
+		Secure:   c.Request.TLS != nil || c.GetHeader("X-Forwarded-Proto") == "https",
+		SameSite: http.SameSiteLaxMode,
+	})
 }
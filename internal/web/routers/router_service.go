@@ -9,28 +9,113 @@ import (
 	wbgin "github.com/wb-go/wbf/ginext"
 )
 
-func RegisterRoutes(engine *wbgin.Engine, userHandler *handlers.UserHandler, itemHandler *handlers.ItemHandler, historyHandler *handlers.HistoryHandler) {
+func RegisterRoutes(engine *wbgin.Engine, userHandler *handlers.UserHandler, itemHandler *handlers.ItemHandler, historyHandler *handlers.HistoryHandler, warehouseHandler *handlers.WarehouseHandler, keysHandler *handlers.KeysHandler, ssoHandler *handlers.SSOHandler) {
+	// открытые ключи для проверки access токенов другими сервисами
+	engine.GET("/.well-known/jwks.json", keysHandler.GetJWKS)
+
 	api := engine.Group("/api")
 	api.GET("/swagger/*any", func(c *wbgin.Context) {
 		httpSwagger.WrapHandler(c.Writer, c.Request)
 	})
 
-	// публичные маршруты авторизации
+	// маршруты авторизации; смена пароля требует токена, второй шаг входа — токена второго шага
 	auth := api.Group("/auth")
+	auth.POST("/bootstrap", userHandler.Bootstrap)
 	auth.POST("/register", userHandler.RegisterUser)
 	auth.POST("/login", userHandler.LoginUser)
+	auth.POST("/login/2fa", userHandler.LoginMFA)
+	auth.POST("/login/2fa/setup", userHandler.LoginMFASetup)
 	auth.POST("/refresh", userHandler.RefreshToken)
+	auth.POST("/logout", userHandler.Logout)
+	auth.POST("/password", AuthMiddleware(userHandler.Service), userHandler.ChangePassword)
+	auth.POST("/password/reset", userHandler.ResetPasswordWithToken)
+	// вход через OpenID Connect провайдера
+	auth.GET("/sso/login", ssoHandler.StartSSOLogin)
+	auth.POST("/sso/callback", ssoHandler.SSOCallback)
+
+	// свои сессии доступны любому вошедшему пользователю
+	sessions := auth.Group("/sessions", AuthMiddleware(userHandler.Service))
+	sessions.GET("", userHandler.GetSessions)
+	sessions.DELETE("", userHandler.RevokeSessions)
+	sessions.DELETE("/:id", userHandler.RevokeSession)
+
+	// свой второй фактор
+	totp := auth.Group("/2fa", AuthMiddleware(userHandler.Service))
+	totp.GET("", userHandler.GetTOTPStatus)
+	totp.POST("/setup", userHandler.SetupTOTP)
+	totp.POST("/enable", userHandler.EnableTOTP)
+	totp.POST("/disable", userHandler.DisableTOTP)
+	totp.POST("/recovery-codes", userHandler.RegenerateRecoveryCodes)
+
+	// приглашения, пользователи и сервисные учётные записи — право users.manage;
+	// регистрация без приглашения невозможна
+	invitations := api.Group("/invitations", AuthMiddleware(userHandler.Service), RequirePermission(user.PermUsersManage))
+	invitations.POST("", userHandler.CreateInvitation)
+	invitations.GET("", userHandler.GetInvitations)
+	invitations.DELETE("/:id", userHandler.RevokeInvitation)
+
+	users := api.Group("/users", AuthMiddleware(userHandler.Service), RequirePermission(user.PermUsersManage))
+	users.GET("", userHandler.GetUsers)
+	users.GET("/:id", userHandler.GetUser)
+	users.PUT("/:id/role", userHandler.ChangeUserRole)
+	users.POST("/:id/disable", userHandler.DisableUser)
+	users.POST("/:id/enable", userHandler.EnableUser)
+	users.POST("/:id/password", userHandler.ResetUserPassword)
+	users.POST("/:id/password-reset-token", userHandler.IssuePasswordResetToken)
+	users.DELETE("/:id", userHandler.DeleteUser)
+	users.POST("/:id/unlock", userHandler.UnlockUser)
+	users.DELETE("/:id/2fa", userHandler.ResetUserTOTP)
+	users.GET("/:id/audit", userHandler.GetUserAudit)
+	users.GET("/:id/sessions", userHandler.GetUserSessions)
+	users.DELETE("/:id/sessions", userHandler.RevokeUserSessions)
+	users.DELETE("/:id/sessions/:session_id", userHandler.RevokeUserSession)
 
-	// защищённая группа предметов
+	serviceAccounts := api.Group("/service-accounts", AuthMiddleware(userHandler.Service), RequirePermission(user.PermUsersManage))
+	serviceAccounts.POST("", userHandler.CreateServiceAccount)
+	serviceAccounts.GET("/:id/keys", userHandler.GetAPIKeys)
+	serviceAccounts.POST("/:id/keys", userHandler.CreateAPIKey)
+	serviceAccounts.POST("/:id/keys/:key_id/rotate", userHandler.RotateAPIKey)
+	serviceAccounts.DELETE("/:id/keys/:key_id", userHandler.RevokeAPIKey)
+
+	// роли как наборы прав; встроенные роли только для просмотра
+	roles := api.Group("/roles", AuthMiddleware(userHandler.Service), RequirePermission(user.PermRolesManage))
+	roles.GET("", userHandler.GetRoles)
+	roles.POST("", userHandler.CreateRole)
+	roles.GET("/:name", userHandler.GetRole)
+	roles.PUT("/:name", userHandler.UpdateRole)
+	roles.DELETE("/:name", userHandler.DeleteRole)
+
+	// защищённая группа предметов; API ключам нужны области items:read и items:write
 	items := api.Group("/items", AuthMiddleware(userHandler.Service))
-	items.POST("", RequireRoles(user.Admin), itemHandler.CreateItem)
-	items.GET("", RequireRoles(user.Admin, user.Manager, user.Viewer), itemHandler.GetItems)
-	items.GET("/:id", RequireRoles(user.Admin, user.Manager, user.Viewer), itemHandler.GetItem)
-	items.PUT("/:id", RequireRoles(user.Admin, user.Manager), itemHandler.PutItem)
-	items.DELETE("/:id", RequireRoles(user.Admin), itemHandler.DeleteItem)
-
-	// просмотр истории только для админа
-	history := api.Group("/history", AuthMiddleware(userHandler.Service), RequireRoles(user.Admin))
+	items.POST("", RequirePermission(user.PermItemsCreate), itemHandler.CreateItem)
+	items.GET("", RequirePermission(user.PermItemsRead), itemHandler.GetItems)
+	items.GET("/:id", RequirePermission(user.PermItemsRead), itemHandler.GetItem)
+	items.PUT("/:id", RequirePermission(user.PermItemsUpdate), itemHandler.PutItem)
+	items.DELETE("/:id", RequirePermission(user.PermItemsDelete), itemHandler.DeleteItem)
+	items.PUT("/:id/stock", RequirePermission(user.PermItemsAdjust), itemHandler.SetStock)
+	items.GET("/:id/movements", RequirePermission(user.PermItemsRead), itemHandler.GetMovements)
+	items.POST("/:id/receipts", RequirePermission(user.PermItemsAdjust), itemHandler.Receive)
+	items.POST("/:id/issues", RequirePermission(user.PermItemsAdjust), itemHandler.Issue)
+	items.POST("/:id/transfers", RequirePermission(user.PermItemsAdjust), itemHandler.Transfer)
+	items.POST("/:id/adjust", RequirePermission(user.PermItemsAdjust), itemHandler.AdjustItem)
+	items.POST("/:id/revert", RequirePermission(user.PermItemsRevert), itemHandler.RevertItem)
+	items.GET("/:id/diff", RequirePermission(user.PermHistoryRead), itemHandler.DiffItem)
+
+	// склады, зоны и ячейки; для API ключей — области warehouses
+	warehouses := api.Group("/warehouses", AuthMiddleware(userHandler.Service))
+	warehouses.GET("", RequirePermission(user.PermWarehousesRead), warehouseHandler.GetWarehouses)
+	warehouses.POST("", RequirePermission(user.PermWarehousesManage), warehouseHandler.CreateWarehouse)
+	warehouses.GET("/:id", RequirePermission(user.PermWarehousesRead), warehouseHandler.GetWarehouse)
+	warehouses.PUT("/:id", RequirePermission(user.PermWarehousesManage), warehouseHandler.PutWarehouse)
+	warehouses.DELETE("/:id", RequirePermission(user.PermWarehousesManage), warehouseHandler.DeleteWarehouse)
+	warehouses.POST("/:id/zones", RequirePermission(user.PermWarehousesManage), warehouseHandler.CreateZone)
+	warehouses.PUT("/:id/zones/:zone_id", RequirePermission(user.PermWarehousesManage), warehouseHandler.PutZone)
+	warehouses.DELETE("/:id/zones/:zone_id", RequirePermission(user.PermWarehousesManage), warehouseHandler.DeleteZone)
+	warehouses.POST("/:id/zones/:zone_id/locations", RequirePermission(user.PermWarehousesManage), warehouseHandler.CreateLocation)
+	warehouses.PUT("/:id/zones/:zone_id/locations/:location_id", RequirePermission(user.PermWarehousesManage), warehouseHandler.PutLocation)
+	warehouses.DELETE("/:id/zones/:zone_id/locations/:location_id", RequirePermission(user.PermWarehousesManage), warehouseHandler.DeleteLocation)
+
+	history := api.Group("/history", AuthMiddleware(userHandler.Service), RequirePermission(user.PermHistoryRead))
 	history.GET("", historyHandler.GetItems)
 	history.GET("/csv", historyHandler.GetItemsCSV)
 }
@@ -1 +1 @@
-DROP TABLE users IF EXISTS;
\ No newline at end of file
+DROP TABLE IF EXISTS users;
\ No newline at end of file
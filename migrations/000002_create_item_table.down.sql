@@ -1 +1 @@
-DROP TABLE items IF EXISTS;
\ No newline at end of file
+DROP TABLE IF EXISTS items;
\ No newline at end of file
@@ -1 +1 @@
-DROP TABLE history IF EXISTS;
\ No newline at end of file
+DROP TABLE IF EXISTS history;
\ No newline at end of file
@@ -0,0 +1,4 @@
+DROP TABLE IF EXISTS item_stocks;
+DROP TABLE IF EXISTS locations;
+DROP TABLE IF EXISTS zones;
+DROP TABLE IF EXISTS warehouses;
\ No newline at end of file
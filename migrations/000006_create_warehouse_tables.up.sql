@@ -0,0 +1,28 @@
+CREATE TABLE warehouses (
+    id UUID PRIMARY KEY,
+    name TEXT UNIQUE NOT NULL,
+    address TEXT NOT NULL DEFAULT ''
+);
+
+CREATE TABLE zones (
+    id UUID PRIMARY KEY,
+    warehouse_id UUID NOT NULL REFERENCES warehouses(id) ON DELETE CASCADE,
+    name TEXT NOT NULL,
+    UNIQUE (warehouse_id, name)
+);
+
+CREATE TABLE locations (
+    id UUID PRIMARY KEY,
+    zone_id UUID NOT NULL REFERENCES zones(id) ON DELETE CASCADE,
+    code TEXT NOT NULL,
+    UNIQUE (zone_id, code)
+);
+
+CREATE TABLE item_stocks (
+    item_id UUID NOT NULL REFERENCES items(id) ON DELETE CASCADE,
+    location_id UUID NOT NULL REFERENCES locations(id) ON DELETE RESTRICT,
+    quantity INTEGER NOT NULL CHECK (quantity >= 0),
+    PRIMARY KEY (item_id, location_id)
+);
+
+CREATE INDEX item_stocks_location_id_idx ON item_stocks(location_id);
\ No newline at end of file
@@ -0,0 +1,10 @@
+DROP TRIGGER IF EXISTS item_stock_insert_history ON item_stocks;
+DROP TRIGGER IF EXISTS item_stock_update_history ON item_stocks;
+DROP TRIGGER IF EXISTS item_stock_delete_history ON item_stocks;
+
+DROP FUNCTION IF EXISTS trg_item_stock_insert();
+DROP FUNCTION IF EXISTS trg_item_stock_update();
+DROP FUNCTION IF EXISTS trg_item_stock_delete();
+
+DELETE FROM history WHERE location_id IS NOT NULL;
+ALTER TABLE history DROP COLUMN IF EXISTS location_id;
\ No newline at end of file
@@ -0,0 +1,62 @@
+ALTER TABLE history ADD COLUMN location_id UUID;
+
+-- остатки по ячейкам пишутся со своими действиями: снимок в них неполный ({id, count}),
+-- и фильтр по created/updated/deleted должен находить только записи о самих товарах
+CREATE OR REPLACE FUNCTION trg_item_stock_insert()
+RETURNS trigger AS $$
+DECLARE
+    uid UUID;
+    login TEXT;
+BEGIN
+    uid := app_current_user();
+    login := app_current_user_login();
+    INSERT INTO history(item_id, location_id, action, changed_by, changed_by_login, old_data, new_data)
+    VALUES (NEW.item_id, NEW.location_id, 'stock_created', uid, login, NULL,
+            jsonb_build_object('id', NEW.item_id, 'count', NEW.quantity));
+    RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE OR REPLACE FUNCTION trg_item_stock_update()
+RETURNS trigger AS $$
+DECLARE
+    uid UUID;
+    login TEXT;
+BEGIN
+    uid := app_current_user();
+    login := app_current_user_login();
+    INSERT INTO history(item_id, location_id, action, changed_by, changed_by_login, old_data, new_data)
+    VALUES (NEW.item_id, NEW.location_id, 'stock_updated', uid, login,
+            jsonb_build_object('id', OLD.item_id, 'count', OLD.quantity),
+            jsonb_build_object('id', NEW.item_id, 'count', NEW.quantity));
+    RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE OR REPLACE FUNCTION trg_item_stock_delete()
+RETURNS trigger AS $$
+DECLARE
+    uid UUID;
+    login TEXT;
+BEGIN
+    uid := app_current_user();
+    login := app_current_user_login();
+    INSERT INTO history(item_id, location_id, action, changed_by, changed_by_login, old_data, new_data)
+    VALUES (OLD.item_id, OLD.location_id, 'stock_removed', uid, login,
+            jsonb_build_object('id', OLD.item_id, 'count', OLD.quantity), NULL);
+    RETURN OLD;
+END;
+$$ LANGUAGE plpgsql;
+
+
+CREATE TRIGGER item_stock_insert_history
+AFTER INSERT ON item_stocks
+FOR EACH ROW EXECUTE FUNCTION trg_item_stock_insert();
+
+CREATE TRIGGER item_stock_update_history
+AFTER UPDATE ON item_stocks
+FOR EACH ROW EXECUTE FUNCTION trg_item_stock_update();
+
+CREATE TRIGGER item_stock_delete_history
+AFTER DELETE ON item_stocks
+FOR EACH ROW EXECUTE FUNCTION trg_item_stock_delete();
\ No newline at end of file
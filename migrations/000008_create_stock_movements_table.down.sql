@@ -0,0 +1,3 @@
+DROP TRIGGER IF EXISTS stock_movements_append_only ON stock_movements;
+DROP FUNCTION IF EXISTS trg_stock_movements_append_only();
+DROP TABLE IF EXISTS stock_movements;
\ No newline at end of file
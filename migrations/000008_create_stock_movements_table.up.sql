@@ -0,0 +1,33 @@
+CREATE TABLE stock_movements (
+    id UUID PRIMARY KEY,
+    item_id UUID NOT NULL,
+    type VARCHAR(20) NOT NULL CHECK (type IN ('receipt', 'issue', 'transfer', 'adjustment')),
+    quantity INTEGER NOT NULL,
+    location_id UUID,
+    to_location_id UUID,
+    reason TEXT NOT NULL DEFAULT '',
+    reference TEXT NOT NULL DEFAULT '',
+    created_by UUID NOT NULL,
+    created_by_login VARCHAR(100) NOT NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT now()
+);
+
+CREATE INDEX stock_movements_item_id_idx ON stock_movements(item_id, created_at);
+
+-- журнал только пополняется: любые UPDATE/DELETE запрещены
+CREATE OR REPLACE FUNCTION trg_stock_movements_append_only()
+RETURNS trigger AS $$
+BEGIN
+    RAISE EXCEPTION 'stock_movements is append-only';
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER stock_movements_append_only
+BEFORE UPDATE OR DELETE ON stock_movements
+FOR EACH ROW EXECUTE FUNCTION trg_stock_movements_append_only();
+
+-- входящий остаток для уже существующих товаров, чтобы сумма журнала совпадала с items.count
+INSERT INTO stock_movements (id, item_id, type, quantity, reason, created_by, created_by_login)
+SELECT gen_random_uuid(), id, 'adjustment', count, 'opening balance', '00000000-0000-0000-0000-000000000000', 'system'
+FROM items
+WHERE count <> 0;
\ No newline at end of file
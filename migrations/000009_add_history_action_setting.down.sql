@@ -0,0 +1,29 @@
+CREATE OR REPLACE FUNCTION trg_item_insert()
+RETURNS trigger AS $$
+DECLARE
+    uid UUID;
+    login TEXT;
+BEGIN
+    uid := app_current_user();
+    login := app_current_user_login();
+    INSERT INTO history(item_id, action, changed_by, changed_by_login, old_data, new_data)
+    VALUES (NEW.id, 'created', uid, login, NULL, to_jsonb(NEW));
+    RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE OR REPLACE FUNCTION trg_item_update()
+RETURNS trigger AS $$
+DECLARE
+    uid UUID;
+    login TEXT;
+BEGIN
+    uid := app_current_user();
+    login := app_current_user_login();
+    INSERT INTO history(item_id, action, changed_by, changed_by_login, old_data, new_data)
+    VALUES (NEW.id, 'updated', uid, login, to_jsonb(OLD), to_jsonb(NEW));
+    RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+
+DROP FUNCTION IF EXISTS app_current_action(text);
\ No newline at end of file
@@ -0,0 +1,36 @@
+-- действие для истории товара можно переопределить на время транзакции через app.current_action;
+-- записи об остатках в ячейках пишутся со своими действиями stock_*
+CREATE OR REPLACE FUNCTION app_current_action(fallback text)
+RETURNS text AS $$
+BEGIN
+  RETURN COALESCE(NULLIF(current_setting('app.current_action', true), ''), fallback);
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE OR REPLACE FUNCTION trg_item_insert()
+RETURNS trigger AS $$
+DECLARE
+    uid UUID;
+    login TEXT;
+BEGIN
+    uid := app_current_user();
+    login := app_current_user_login();
+    INSERT INTO history(item_id, action, changed_by, changed_by_login, old_data, new_data)
+    VALUES (NEW.id, app_current_action('created'), uid, login, NULL, to_jsonb(NEW));
+    RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE OR REPLACE FUNCTION trg_item_update()
+RETURNS trigger AS $$
+DECLARE
+    uid UUID;
+    login TEXT;
+BEGIN
+    uid := app_current_user();
+    login := app_current_user_login();
+    INSERT INTO history(item_id, action, changed_by, changed_by_login, old_data, new_data)
+    VALUES (NEW.id, app_current_action('updated'), uid, login, to_jsonb(OLD), to_jsonb(NEW));
+    RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
\ No newline at end of file
@@ -0,0 +1,3 @@
+DROP TRIGGER IF EXISTS item_bump_version ON items;
+DROP FUNCTION IF EXISTS trg_item_bump_version();
+ALTER TABLE items DROP COLUMN IF EXISTS version;
\ No newline at end of file
@@ -0,0 +1,14 @@
+ALTER TABLE items ADD COLUMN version INTEGER NOT NULL DEFAULT 1;
+
+-- версия растёт при любом изменении строки товара, в том числе при движении остатков
+CREATE OR REPLACE FUNCTION trg_item_bump_version()
+RETURNS trigger AS $$
+BEGIN
+    NEW.version := OLD.version + 1;
+    RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER item_bump_version
+BEFORE UPDATE ON items
+FOR EACH ROW EXECUTE FUNCTION trg_item_bump_version();
\ No newline at end of file
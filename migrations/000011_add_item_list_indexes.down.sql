@@ -0,0 +1,3 @@
+DROP INDEX IF EXISTS idx_items_price_id;
+DROP INDEX IF EXISTS idx_items_count_id;
+DROP INDEX IF EXISTS idx_items_name_id;
\ No newline at end of file
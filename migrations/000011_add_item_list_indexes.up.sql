@@ -0,0 +1,3 @@
+CREATE INDEX IF NOT EXISTS idx_items_name_id ON items (name, id);
+CREATE INDEX IF NOT EXISTS idx_items_count_id ON items (count, id);
+CREATE INDEX IF NOT EXISTS idx_items_price_id ON items (price, id);
\ No newline at end of file
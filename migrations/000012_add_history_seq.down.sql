@@ -0,0 +1,3 @@
+DROP INDEX IF EXISTS idx_history_item_changed_at_seq;
+DROP INDEX IF EXISTS idx_history_changed_at_seq;
+ALTER TABLE history DROP COLUMN IF EXISTS seq;
\ No newline at end of file
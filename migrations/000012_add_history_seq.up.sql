@@ -0,0 +1,4 @@
+ALTER TABLE history ADD COLUMN IF NOT EXISTS seq BIGSERIAL;
+
+CREATE INDEX IF NOT EXISTS idx_history_changed_at_seq ON history (changed_at, seq);
+CREATE INDEX IF NOT EXISTS idx_history_item_changed_at_seq ON history (item_id, changed_at, seq);
\ No newline at end of file
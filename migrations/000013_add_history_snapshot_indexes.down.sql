@@ -0,0 +1,2 @@
+DROP INDEX IF EXISTS idx_history_stock_snapshots;
+DROP INDEX IF EXISTS idx_history_item_snapshots;
\ No newline at end of file
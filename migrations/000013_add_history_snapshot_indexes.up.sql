@@ -0,0 +1,3 @@
+-- снимки товаров и остатков по ячейкам для запросов as_of
+CREATE INDEX IF NOT EXISTS idx_history_item_snapshots ON history (item_id, changed_at DESC, seq DESC) WHERE location_id IS NULL;
+CREATE INDEX IF NOT EXISTS idx_history_stock_snapshots ON history (item_id, location_id, changed_at DESC, seq DESC) WHERE location_id IS NOT NULL;
\ No newline at end of file
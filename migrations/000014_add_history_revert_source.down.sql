@@ -0,0 +1,3 @@
+DROP TRIGGER IF EXISTS history_source ON history;
+DROP FUNCTION IF EXISTS trg_history_source();
+ALTER TABLE history DROP COLUMN IF EXISTS source_history_id;
\ No newline at end of file
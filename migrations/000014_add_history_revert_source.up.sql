@@ -0,0 +1,16 @@
+ALTER TABLE history ADD COLUMN IF NOT EXISTS source_history_id UUID REFERENCES history(id);
+
+-- запись истории, из которой восстановлено состояние (для action = 'reverted'), передаётся через app.source_history_id
+CREATE OR REPLACE FUNCTION trg_history_source()
+RETURNS trigger AS $$
+BEGIN
+    IF NEW.source_history_id IS NULL THEN
+        NEW.source_history_id := NULLIF(current_setting('app.source_history_id', true), '')::uuid;
+    END IF;
+    RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER history_source
+BEFORE INSERT ON history
+FOR EACH ROW EXECUTE FUNCTION trg_history_source();
\ No newline at end of file
@@ -0,0 +1,10 @@
+CREATE TABLE invitations (
+    id UUID PRIMARY KEY,
+    token_hash BYTEA UNIQUE NOT NULL,
+    role TEXT NOT NULL CHECK (role IN ('admin', 'manager', 'viewer')),
+    created_by UUID NOT NULL REFERENCES users(id),
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    expires_at TIMESTAMPTZ NOT NULL,
+    used_at TIMESTAMPTZ,
+    used_by UUID REFERENCES users(id)
+);
\ No newline at end of file
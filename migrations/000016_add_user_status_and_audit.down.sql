@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS user_audit;
+ALTER TABLE users DROP COLUMN IF EXISTS disabled_at;
\ No newline at end of file
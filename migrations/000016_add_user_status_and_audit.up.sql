@@ -0,0 +1,17 @@
+ALTER TABLE users ADD COLUMN disabled_at TIMESTAMPTZ;
+
+-- журнал действий администраторов над учётными записями; без внешнего ключа,
+-- чтобы записи сохранялись после удаления пользователя
+CREATE TABLE user_audit (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    user_id UUID NOT NULL,
+    user_login TEXT NOT NULL,
+    action VARCHAR(20) NOT NULL CHECK (action IN ('role_changed', 'disabled', 'enabled', 'password_reset', 'deleted')),
+    old_value TEXT,
+    new_value TEXT,
+    actor_id UUID NOT NULL,
+    actor_login TEXT NOT NULL,
+    changed_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+CREATE INDEX idx_user_audit_user_changed ON user_audit (user_id, changed_at);
\ No newline at end of file
@@ -0,0 +1,8 @@
+ALTER TABLE refresh_tokens ADD COLUMN IF NOT EXISTS revoked_at TIMESTAMPTZ;
+UPDATE refresh_tokens rt SET revoked_at = s.revoked_at
+FROM sessions s
+WHERE s.id = rt.session_id AND s.revoked_at IS NOT NULL;
+ALTER TABLE refresh_tokens DROP CONSTRAINT IF EXISTS refresh_tokens_session_fk;
+ALTER INDEX IF EXISTS idx_refresh_tokens_session RENAME TO idx_refresh_tokens_family;
+ALTER TABLE refresh_tokens RENAME COLUMN session_id TO family_id;
+DROP TABLE IF EXISTS sessions;
\ No newline at end of file
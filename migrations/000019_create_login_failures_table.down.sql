@@ -0,0 +1,6 @@
+DELETE FROM user_audit WHERE action IN ('locked', 'unlocked') OR actor_id IS NULL;
+ALTER TABLE user_audit DROP CONSTRAINT IF EXISTS user_audit_action_check;
+ALTER TABLE user_audit ADD CONSTRAINT user_audit_action_check
+    CHECK (action IN ('role_changed', 'disabled', 'enabled', 'password_reset', 'deleted'));
+ALTER TABLE user_audit ALTER COLUMN actor_id SET NOT NULL;
+DROP TABLE IF EXISTS login_failures;
\ No newline at end of file
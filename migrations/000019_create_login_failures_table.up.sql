@@ -0,0 +1,14 @@
+CREATE TABLE login_failures (
+    scope VARCHAR(10) NOT NULL CHECK (scope IN ('login', 'ip')),
+    subject TEXT NOT NULL,
+    failures INT NOT NULL DEFAULT 0,
+    window_start TIMESTAMPTZ NOT NULL DEFAULT now(),
+    locked_until TIMESTAMPTZ,
+    PRIMARY KEY (scope, subject)
+);
+
+-- блокировки пишутся в аудит от имени системы, у таких записей нет автора
+ALTER TABLE user_audit ALTER COLUMN actor_id DROP NOT NULL;
+ALTER TABLE user_audit DROP CONSTRAINT user_audit_action_check;
+ALTER TABLE user_audit ADD CONSTRAINT user_audit_action_check
+    CHECK (action IN ('role_changed', 'disabled', 'enabled', 'password_reset', 'deleted', 'locked', 'unlocked'));
\ No newline at end of file
@@ -0,0 +1,5 @@
+DELETE FROM user_audit WHERE action IN ('password_changed', 'reset_token_issued');
+ALTER TABLE user_audit DROP CONSTRAINT IF EXISTS user_audit_action_check;
+ALTER TABLE user_audit ADD CONSTRAINT user_audit_action_check
+    CHECK (action IN ('role_changed', 'disabled', 'enabled', 'password_reset', 'deleted', 'locked', 'unlocked'));
+DROP TABLE IF EXISTS password_reset_tokens;
\ No newline at end of file
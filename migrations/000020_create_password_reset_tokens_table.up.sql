@@ -0,0 +1,15 @@
+CREATE TABLE password_reset_tokens (
+    id UUID PRIMARY KEY,
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    token_hash BYTEA NOT NULL UNIQUE,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    expires_at TIMESTAMPTZ NOT NULL,
+    used_at TIMESTAMPTZ
+);
+
+CREATE INDEX idx_password_reset_tokens_user ON password_reset_tokens (user_id);
+
+ALTER TABLE user_audit DROP CONSTRAINT user_audit_action_check;
+ALTER TABLE user_audit ADD CONSTRAINT user_audit_action_check
+    CHECK (action IN ('role_changed', 'disabled', 'enabled', 'password_reset', 'deleted', 'locked', 'unlocked',
+                      'password_changed', 'reset_token_issued'));
\ No newline at end of file
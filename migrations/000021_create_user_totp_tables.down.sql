@@ -0,0 +1,8 @@
+DELETE FROM user_audit WHERE action IN ('totp_enabled', 'totp_disabled', 'recovery_codes_renewed');
+ALTER TABLE user_audit DROP CONSTRAINT IF EXISTS user_audit_action_check;
+ALTER TABLE user_audit ADD CONSTRAINT user_audit_action_check
+    CHECK (action IN ('role_changed', 'disabled', 'enabled', 'password_reset', 'deleted', 'locked', 'unlocked',
+                      'password_changed', 'reset_token_issued'));
+ALTER TABLE user_audit ALTER COLUMN action TYPE VARCHAR(20);
+DROP TABLE IF EXISTS user_recovery_codes;
+DROP TABLE IF EXISTS user_totp;
\ No newline at end of file
@@ -0,0 +1,21 @@
+CREATE TABLE user_totp (
+    user_id UUID PRIMARY KEY REFERENCES users(id) ON DELETE CASCADE,
+    secret TEXT NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    confirmed_at TIMESTAMPTZ,
+    last_step BIGINT NOT NULL DEFAULT 0
+);
+
+CREATE TABLE user_recovery_codes (
+    user_id UUID NOT NULL REFERENCES user_totp(user_id) ON DELETE CASCADE,
+    code_hash BYTEA NOT NULL,
+    used_at TIMESTAMPTZ,
+    PRIMARY KEY (user_id, code_hash)
+);
+
+-- recovery_codes_renewed длиннее 20 символов
+ALTER TABLE user_audit ALTER COLUMN action TYPE TEXT;
+ALTER TABLE user_audit DROP CONSTRAINT user_audit_action_check;
+ALTER TABLE user_audit ADD CONSTRAINT user_audit_action_check
+    CHECK (action IN ('role_changed', 'disabled', 'enabled', 'password_reset', 'deleted', 'locked', 'unlocked',
+                      'password_changed', 'reset_token_issued', 'totp_enabled', 'totp_disabled', 'recovery_codes_renewed'));
\ No newline at end of file
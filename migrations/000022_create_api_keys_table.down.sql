@@ -0,0 +1,7 @@
+DELETE FROM user_audit WHERE action IN ('api_key_created', 'api_key_rotated', 'api_key_revoked');
+ALTER TABLE user_audit DROP CONSTRAINT IF EXISTS user_audit_action_check;
+ALTER TABLE user_audit ADD CONSTRAINT user_audit_action_check
+    CHECK (action IN ('role_changed', 'disabled', 'enabled', 'password_reset', 'deleted', 'locked', 'unlocked',
+                      'password_changed', 'reset_token_issued', 'totp_enabled', 'totp_disabled', 'recovery_codes_renewed'));
+DROP TABLE IF EXISTS api_keys;
+ALTER TABLE users DROP COLUMN IF EXISTS service_account;
\ No newline at end of file
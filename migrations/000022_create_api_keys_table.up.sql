@@ -0,0 +1,22 @@
+ALTER TABLE users ADD COLUMN service_account BOOLEAN NOT NULL DEFAULT false;
+
+CREATE TABLE api_keys (
+    id UUID PRIMARY KEY,
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    name TEXT NOT NULL,
+    prefix TEXT NOT NULL,
+    key_hash BYTEA NOT NULL UNIQUE,
+    scopes TEXT[] NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    expires_at TIMESTAMPTZ,
+    last_used_at TIMESTAMPTZ,
+    revoked_at TIMESTAMPTZ
+);
+
+CREATE INDEX idx_api_keys_user ON api_keys (user_id);
+
+ALTER TABLE user_audit DROP CONSTRAINT user_audit_action_check;
+ALTER TABLE user_audit ADD CONSTRAINT user_audit_action_check
+    CHECK (action IN ('role_changed', 'disabled', 'enabled', 'password_reset', 'deleted', 'locked', 'unlocked',
+                      'password_changed', 'reset_token_issued', 'totp_enabled', 'totp_disabled', 'recovery_codes_renewed',
+                      'api_key_created', 'api_key_rotated', 'api_key_revoked'));
\ No newline at end of file
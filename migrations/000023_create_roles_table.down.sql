@@ -0,0 +1,8 @@
+ALTER TABLE invitations DROP CONSTRAINT IF EXISTS invitations_role_fkey;
+ALTER TABLE users DROP CONSTRAINT IF EXISTS users_role_fkey;
+-- пользовательские роли исчезают: их владельцы становятся viewer, приглашения удаляются
+UPDATE users SET role = 'viewer' WHERE role NOT IN ('admin', 'manager', 'viewer');
+DELETE FROM invitations WHERE role NOT IN ('admin', 'manager', 'viewer');
+ALTER TABLE invitations ADD CONSTRAINT invitations_role_check CHECK (role IN ('admin', 'manager', 'viewer'));
+ALTER TABLE users ALTER COLUMN role TYPE VARCHAR(20);
+DROP TABLE IF EXISTS roles;
\ No newline at end of file
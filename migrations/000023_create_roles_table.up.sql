@@ -0,0 +1,23 @@
+CREATE TABLE roles (
+    name TEXT PRIMARY KEY,
+    description TEXT NOT NULL DEFAULT '',
+    permissions TEXT[] NOT NULL,
+    built_in BOOLEAN NOT NULL DEFAULT false,
+    version INT NOT NULL DEFAULT 1,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+INSERT INTO roles (name, description, permissions, built_in) VALUES
+    ('admin', 'Full access',
+     ARRAY['items.read', 'items.create', 'items.update', 'items.delete', 'items.adjust', 'items.adjust_force',
+           'items.revert', 'warehouses.read', 'warehouses.manage', 'history.read', 'users.manage', 'roles.manage'], true),
+    ('manager', 'Item editing and stock operations',
+     ARRAY['items.read', 'items.update', 'items.adjust', 'warehouses.read'], true),
+    ('viewer', 'Read-only access to items and warehouses',
+     ARRAY['items.read', 'warehouses.read'], true);
+
+ALTER TABLE users ALTER COLUMN role TYPE TEXT;
+ALTER TABLE users ADD CONSTRAINT users_role_fkey FOREIGN KEY (role) REFERENCES roles(name);
+ALTER TABLE invitations DROP CONSTRAINT IF EXISTS invitations_role_check;
+ALTER TABLE invitations ADD CONSTRAINT invitations_role_fkey FOREIGN KEY (role) REFERENCES roles(name);
\ No newline at end of file
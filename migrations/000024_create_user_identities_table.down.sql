@@ -0,0 +1,8 @@
+DELETE FROM user_audit WHERE action = 'sso_provisioned';
+ALTER TABLE user_audit DROP CONSTRAINT IF EXISTS user_audit_action_check;
+ALTER TABLE user_audit ADD CONSTRAINT user_audit_action_check
+    CHECK (action IN ('role_changed', 'disabled', 'enabled', 'password_reset', 'deleted', 'locked', 'unlocked',
+                      'password_changed', 'reset_token_issued', 'totp_enabled', 'totp_disabled', 'recovery_codes_renewed',
+                      'api_key_created', 'api_key_rotated', 'api_key_revoked'));
+DROP TABLE IF EXISTS sso_login_states;
+DROP TABLE IF EXISTS user_identities;
\ No newline at end of file
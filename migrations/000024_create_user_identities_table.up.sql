@@ -0,0 +1,27 @@
+CREATE TABLE user_identities (
+    issuer TEXT NOT NULL,
+    subject TEXT NOT NULL,
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    last_login_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    PRIMARY KEY (issuer, subject)
+);
+
+CREATE INDEX idx_user_identities_user ON user_identities (user_id);
+
+-- незавершённые входы через провайдера; хранится хэш state, записи живут минуты
+CREATE TABLE sso_login_states (
+    state_hash BYTEA PRIMARY KEY,
+    nonce TEXT NOT NULL,
+    code_verifier TEXT NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    expires_at TIMESTAMPTZ NOT NULL
+);
+
+CREATE INDEX idx_sso_login_states_expires ON sso_login_states (expires_at);
+
+ALTER TABLE user_audit DROP CONSTRAINT user_audit_action_check;
+ALTER TABLE user_audit ADD CONSTRAINT user_audit_action_check
+    CHECK (action IN ('role_changed', 'disabled', 'enabled', 'password_reset', 'deleted', 'locked', 'unlocked',
+                      'password_changed', 'reset_token_issued', 'totp_enabled', 'totp_disabled', 'recovery_codes_renewed',
+                      'api_key_created', 'api_key_rotated', 'api_key_revoked', 'sso_provisioned'));
\ No newline at end of file